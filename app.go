@@ -29,10 +29,11 @@ import (
 type NetworkStatus string
 
 const (
-	NetworkStatusOffline    NetworkStatus = "offline"
-	NetworkStatusConnecting NetworkStatus = "connecting"
-	NetworkStatusOnline     NetworkStatus = "online"
-	NetworkStatusError      NetworkStatus = "error"
+	NetworkStatusOffline      NetworkStatus = "offline"
+	NetworkStatusConnecting   NetworkStatus = "connecting"
+	NetworkStatusOnline       NetworkStatus = "online"
+	NetworkStatusReconnecting NetworkStatus = "reconnecting"
+	NetworkStatusError        NetworkStatus = "error"
 )
 
 // FolderInfo информация о папке
@@ -54,10 +55,14 @@ type ContactInfo struct {
 	I2PAddress      string
 	LastMessage     string
 	LastMessageTime int64
-	LastSeen        string
+	LastSeen        int64
 	IsOnline        bool
 	ChatID          string
 	UnreadCount     int
+	ReadReceipts    bool
+	HidePresence    bool
+	IsBlocked       bool
+	IsPending       bool
 }
 
 // MessageInfo сообщение для фронтенда
@@ -74,6 +79,41 @@ type MessageInfo struct {
 	Attachments  []map[string]interface{}
 	ReplyToID    string
 	ReplyPreview *appcore.ReplyPreview
+	EditedAt     int64
+	SenderID     string
+	SenderName   string
+}
+
+// GroupMemberInfo участник группы для фронтенда
+type GroupMemberInfo struct {
+	PublicKey string
+	Nickname  string
+	IsAdmin   bool
+	IsMe      bool
+}
+
+// GroupInfo информация о группе для фронтенда
+type GroupInfo struct {
+	ID              string
+	Name            string
+	Members         []*GroupMemberInfo
+	IsAdmin         bool
+	IsMember        bool
+	LastMessage     string
+	LastMessageTime int64
+	UnreadCount     int
+}
+
+// ChannelInfo информация о канале для фронтенда
+type ChannelInfo struct {
+	ID              string
+	Name            string
+	Link            string
+	IsOwner         bool
+	SubscriberCount int
+	LastMessage     string
+	LastMessageTime int64
+	UnreadCount     int
 }
 
 // UserInfo информация о текущем пользователе
@@ -98,8 +138,51 @@ type AppAboutInfo struct {
 
 // RouterSettings настройки роутера
 type RouterSettings struct {
-	TunnelLength int // 1, 3, 5
-	LogToFile    bool
+	SecurityProfile string // fast, default, invisible или custom
+	TunnelLength    int
+	TunnelQuantity  int
+	TunnelVariance  int
+	BackupQuantity  int
+	LeaseSetType    int
+	LeaseSetAuth    bool // Зашифрованный leaseSet открыт только контактам
+	LogToFile       bool
+	MessageTTLHours int // Сколько часов пытаться доставить сообщение
+
+	ClockSkewMinutes int // Допустимое расхождение часов с собеседником
+}
+
+// Diagnostics счётчики входящих пакетов
+type Diagnostics struct {
+	PacketsReceived    uint64
+	RejectedSignature  uint64
+	RejectedStale      uint64
+	RejectedReplay     uint64
+	RejectedDowngrade  uint64
+	RejectedDecrypt    uint64
+	RejectedRecipient  uint64
+	RejectedStamp      uint64
+	RejectedBlocked    uint64
+	RejectedRate       uint64
+	RejectedOversize   uint64
+	RejectedConnection uint64
+	RejectedInFlight   uint64
+	LegacyUnprotected  uint64
+}
+
+// PrivacySettings настройки приватности
+type PrivacySettings struct {
+	ReadReceipts bool
+
+	StampAlgorithm  string // "sha256" или "argon2"
+	StampDifficulty int    // Сложность штампа в битах (0 — по умолчанию)
+}
+
+// MailboxSettings почтовый сервер GhostMail и его квоты
+type MailboxSettings struct {
+	Server      string
+	MaxMessages int
+	MaxBytes    int64
+	TTLHours    int
 }
 
 // App основная структура приложения
@@ -0,0 +1,56 @@
+package main
+
+import "teleghost/internal/appcore"
+
+// CreateChannel создаёт канал.
+func (a *App) CreateChannel(name string) (*ChannelInfo, error) {
+	c, err := a.core.CreateChannel(name)
+	if err != nil {
+		return nil, err
+	}
+	return toChannelInfo(c), nil
+}
+
+// GetChannels возвращает свои каналы и подписки.
+func (a *App) GetChannels() ([]*ChannelInfo, error) {
+	coreChannels, err := a.core.GetChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*ChannelInfo, len(coreChannels))
+	for i, c := range coreChannels {
+		result[i] = toChannelInfo(c)
+	}
+	return result, nil
+}
+
+// SubscribeChannel подписывается на канал по ссылке.
+func (a *App) SubscribeChannel(link string) (*ChannelInfo, error) {
+	c, err := a.core.SubscribeChannel(link)
+	if err != nil {
+		return nil, err
+	}
+	return toChannelInfo(c), nil
+}
+
+// UnsubscribeChannel отписывается от канала (свой канал удаляется).
+func (a *App) UnsubscribeChannel(channelID string) error {
+	return a.core.UnsubscribeChannel(channelID)
+}
+
+func toChannelInfo(c *appcore.ChannelInfo) *ChannelInfo {
+	info := &ChannelInfo{
+		ID:              c.ID,
+		Name:            c.Name,
+		Link:            c.Link,
+		IsOwner:         c.IsOwner,
+		SubscriberCount: c.SubscriberCount,
+		LastMessage:     c.LastMessage,
+		UnreadCount:     c.UnreadCount,
+	}
+	if c.LastMessageTime != nil {
+		info.LastMessageTime = c.LastMessageTime.UnixMilli()
+	}
+	return info
+}
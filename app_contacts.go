@@ -1,5 +1,7 @@
 package main
 
+import "teleghost/internal/appcore"
+
 // GetContacts возвращает список контактов.
 func (a *App) GetContacts() ([]*ContactInfo, error) {
 	coreContacts, err := a.core.GetContacts()
@@ -10,26 +12,67 @@ func (a *App) GetContacts() ([]*ContactInfo, error) {
 	// Маппим внутреннюю структуру в структуру фронтенда
 	result := make([]*ContactInfo, len(coreContacts))
 	for i, c := range coreContacts {
-		info := &ContactInfo{
-			ID:          c.ID,
-			Nickname:    c.Nickname,
-			PublicKey:   c.PublicKey,
-			Avatar:      c.Avatar,
-			I2PAddress:  c.I2PAddress,
-			ChatID:      c.ChatID,
-			UnreadCount: c.UnreadCount,
-		}
-		if c.LastMessage != "" {
-			info.LastMessage = c.LastMessage
-		}
-		if c.LastMessageTime != nil {
-			info.LastMessageTime = c.LastMessageTime.UnixMilli()
-		}
-		result[i] = info
+		result[i] = toContactInfo(c)
+	}
+	return result, nil
+}
+
+// toContactInfo переводит контакт ядра в структуру фронтенда
+func toContactInfo(c *appcore.ContactInfo) *ContactInfo {
+	info := &ContactInfo{
+		ID:           c.ID,
+		Nickname:     c.Nickname,
+		PublicKey:    c.PublicKey,
+		Avatar:       c.Avatar,
+		I2PAddress:   c.I2PAddress,
+		ChatID:       c.ChatID,
+		UnreadCount:  c.UnreadCount,
+		ReadReceipts: c.ReadReceipts,
+		IsOnline:     c.IsOnline,
+		HidePresence: c.HidePresence,
+		IsBlocked:    c.IsBlocked,
+		IsPending:    c.IsPending,
+	}
+	if c.LastSeen != nil {
+		info.LastSeen = c.LastSeen.UnixMilli()
+	}
+	if c.LastMessage != "" {
+		info.LastMessage = c.LastMessage
+	}
+	if c.LastMessageTime != nil {
+		info.LastMessageTime = c.LastMessageTime.UnixMilli()
+	}
+	return info
+}
+
+// GetMessageRequests возвращает входящие запросы переписки от незнакомцев.
+func (a *App) GetMessageRequests() ([]*ContactInfo, error) {
+	requests, err := a.core.GetMessageRequests()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*ContactInfo, len(requests))
+	for i, c := range requests {
+		result[i] = toContactInfo(c)
 	}
 	return result, nil
 }
 
+// AcceptMessageRequest принимает запрос переписки.
+func (a *App) AcceptMessageRequest(id string) error {
+	return a.core.AcceptMessageRequest(id)
+}
+
+// DeclineMessageRequest отклоняет запрос переписки и удаляет его сообщения.
+func (a *App) DeclineMessageRequest(id string) error {
+	return a.core.DeclineMessageRequest(id)
+}
+
+// BlockMessageRequest отклоняет запрос переписки и блокирует отправителя.
+func (a *App) BlockMessageRequest(id string) error {
+	return a.core.BlockMessageRequest(id)
+}
+
 // AddContact добавляет контакт.
 func (a *App) AddContact(name, dest string) (*ContactInfo, error) {
 	c, err := a.core.AddContact(name, dest)
@@ -62,3 +105,18 @@ func (a *App) AddContactFromClipboard(name string) (*ContactInfo, error) {
 func (a *App) DeleteContact(id string) error {
 	return a.core.DeleteContact(id)
 }
+
+// SetContactReadReceipts включает или выключает отчёты о прочтении для контакта.
+func (a *App) SetContactReadReceipts(id string, enabled bool) error {
+	return a.core.SetContactReadReceipts(id, enabled)
+}
+
+// SetContactBlocked блокирует или разблокирует контакт.
+func (a *App) SetContactBlocked(id string, blocked bool) error {
+	return a.core.SetContactBlocked(id, blocked)
+}
+
+// SetContactHidePresence скрывает от контакта наш статус в сети.
+func (a *App) SetContactHidePresence(id string, hide bool) error {
+	return a.core.SetContactHidePresence(id, hide)
+}
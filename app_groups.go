@@ -0,0 +1,89 @@
+package main
+
+import "teleghost/internal/appcore"
+
+// CreateGroup создаёт группу из выбранных контактов.
+func (a *App) CreateGroup(name string, contactIDs []string) (*GroupInfo, error) {
+	g, err := a.core.CreateGroup(name, contactIDs)
+	if err != nil {
+		return nil, err
+	}
+	return toGroupInfo(g), nil
+}
+
+// GetGroups возвращает список групп.
+func (a *App) GetGroups() ([]*GroupInfo, error) {
+	coreGroups, err := a.core.GetGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*GroupInfo, len(coreGroups))
+	for i, g := range coreGroups {
+		result[i] = toGroupInfo(g)
+	}
+	return result, nil
+}
+
+// GetGroupInvites возвращает приглашения в группы не от наших контактов.
+func (a *App) GetGroupInvites() ([]*GroupInfo, error) {
+	invites, err := a.core.GetGroupInvites()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*GroupInfo, len(invites))
+	for i, g := range invites {
+		result[i] = toGroupInfo(g)
+	}
+	return result, nil
+}
+
+// AcceptGroupInvite принимает приглашение в группу.
+func (a *App) AcceptGroupInvite(groupID string) error {
+	return a.core.AcceptGroupInvite(groupID)
+}
+
+// DeclineGroupInvite отклоняет приглашение и удаляет группу.
+func (a *App) DeclineGroupInvite(groupID string) error {
+	return a.core.DeclineGroupInvite(groupID)
+}
+
+// InviteToGroup добавляет контакт в группу.
+func (a *App) InviteToGroup(groupID, contactID string) error {
+	return a.core.InviteToGroup(groupID, contactID)
+}
+
+// KickFromGroup исключает участника из группы.
+func (a *App) KickFromGroup(groupID, memberPubKey string) error {
+	return a.core.KickFromGroup(groupID, memberPubKey)
+}
+
+// LeaveGroup выходит из группы.
+func (a *App) LeaveGroup(groupID string) error {
+	return a.core.LeaveGroup(groupID)
+}
+
+func toGroupInfo(g *appcore.GroupInfo) *GroupInfo {
+	info := &GroupInfo{
+		ID:          g.ID,
+		Name:        g.Name,
+		Members:     make([]*GroupMemberInfo, len(g.Members)),
+		IsAdmin:     g.IsAdmin,
+		IsMember:    g.IsMember,
+		LastMessage: g.LastMessage,
+		UnreadCount: g.UnreadCount,
+	}
+	if g.LastMessageTime != nil {
+		info.LastMessageTime = g.LastMessageTime.UnixMilli()
+	}
+	for i, m := range g.Members {
+		info.Members[i] = &GroupMemberInfo{
+			PublicKey: m.PublicKey,
+			Nickname:  m.Nickname,
+			IsAdmin:   m.IsAdmin,
+			IsMe:      m.IsMe,
+		}
+	}
+	return info
+}
@@ -29,6 +29,9 @@ func (a *App) GetMessages(contactID string, limit, offset int) ([]*MessageInfo,
 			Attachments:  m.Attachments,
 			ReplyToID:    m.ReplyToID,
 			ReplyPreview: m.ReplyPreview,
+			EditedAt:     m.EditedAt,
+			SenderID:     m.SenderID,
+			SenderName:   m.SenderName,
 		}
 		result[i] = info
 	}
@@ -45,11 +48,31 @@ func (a *App) DeleteMessage(messageID string) error {
 	return a.core.DeleteMessage(messageID)
 }
 
+// DeleteMessageForAll удаляет сообщение у себя и у собеседника.
+func (a *App) DeleteMessageForAll(messageID string) error {
+	return a.core.DeleteMessageForAll(messageID)
+}
+
+// RetryMessage повторяет отправку недоставленного сообщения.
+func (a *App) RetryMessage(messageID string) error {
+	return a.core.RetryMessage(messageID)
+}
+
+// CancelMessage отменяет отправку сообщения.
+func (a *App) CancelMessage(messageID string) error {
+	return a.core.CancelMessage(messageID)
+}
+
 // MarkChatAsRead помечает чат прочитанным.
 func (a *App) MarkChatAsRead(chatID string) error {
 	return a.core.MarkChatAsRead(chatID)
 }
 
+// SendTyping сообщает собеседнику, что мы набираем текст.
+func (a *App) SendTyping(chatID string, typing bool) error {
+	return a.core.SendTyping(chatID, typing)
+}
+
 // GetUnreadCount возвращает общее количество непрочитанных.
 func (a *App) GetUnreadCount() (int, error) {
 	return a.core.GetUnreadCount()
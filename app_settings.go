@@ -1,5 +1,7 @@
 package main
 
+import "teleghost/internal/appcore"
+
 // GetMyDestination возвращает I2P адрес.
 func (a *App) GetMyDestination() string {
 	return a.core.GetMyDestination()
@@ -9,8 +11,38 @@ func (a *App) GetMyDestination() string {
 func (a *App) GetRouterSettings() *RouterSettings {
 	coreSettings := a.core.GetRouterSettings()
 	return &RouterSettings{
-		TunnelLength: coreSettings.TunnelLength,
-		LogToFile:    coreSettings.LogToFile,
+		SecurityProfile: coreSettings.SecurityProfile,
+		TunnelLength:    coreSettings.TunnelLength,
+		TunnelQuantity:  coreSettings.TunnelQuantity,
+		TunnelVariance:  coreSettings.TunnelVariance,
+		BackupQuantity:  coreSettings.BackupQuantity,
+		LeaseSetType:    coreSettings.LeaseSetType,
+		LeaseSetAuth:    coreSettings.LeaseSetAuth,
+		LogToFile:       coreSettings.LogToFile,
+		MessageTTLHours: coreSettings.MessageTTLHours,
+
+		ClockSkewMinutes: coreSettings.ClockSkewMinutes,
+	}
+}
+
+// GetDiagnostics возвращает счётчики входящих пакетов.
+func (a *App) GetDiagnostics() *Diagnostics {
+	d := a.core.GetDiagnostics()
+	return &Diagnostics{
+		PacketsReceived:    d.PacketsReceived,
+		RejectedSignature:  d.RejectedSignature,
+		RejectedStale:      d.RejectedStale,
+		RejectedReplay:     d.RejectedReplay,
+		RejectedDowngrade:  d.RejectedDowngrade,
+		RejectedDecrypt:    d.RejectedDecrypt,
+		RejectedRecipient:  d.RejectedRecipient,
+		RejectedStamp:      d.RejectedStamp,
+		RejectedBlocked:    d.RejectedBlocked,
+		RejectedRate:       d.RejectedRate,
+		RejectedOversize:   d.RejectedOversize,
+		RejectedConnection: d.RejectedConnection,
+		RejectedInFlight:   d.RejectedInFlight,
+		LegacyUnprotected:  d.LegacyUnprotected,
 	}
 }
 
@@ -42,6 +74,44 @@ func (a *App) SaveRouterSettings(settings map[string]interface{}) error {
 	return a.core.SaveRouterSettings(settings)
 }
 
+// GetPrivacySettings возвращает настройки приватности.
+func (a *App) GetPrivacySettings() *PrivacySettings {
+	coreSettings := a.core.GetPrivacySettings()
+	return &PrivacySettings{
+		ReadReceipts:    coreSettings.ReadReceipts,
+		StampAlgorithm:  coreSettings.StampAlgorithm,
+		StampDifficulty: coreSettings.StampDifficulty,
+	}
+}
+
+// SavePrivacySettings сохраняет настройки приватности.
+func (a *App) SavePrivacySettings(settings map[string]interface{}) error {
+	return a.core.SavePrivacySettings(settings)
+}
+
+// GetMailboxSettings возвращает почтовый сервер профиля.
+func (a *App) GetMailboxSettings() *MailboxSettings {
+	return toMailboxSettings(a.core.GetMailboxSettings())
+}
+
+// SetMailboxServer регистрирует ящик на почтовом сервере (пустой адрес — отключить).
+func (a *App) SetMailboxServer(address string) (*MailboxSettings, error) {
+	settings, err := a.core.SetMailboxServer(address)
+	if err != nil {
+		return nil, err
+	}
+	return toMailboxSettings(settings), nil
+}
+
+func toMailboxSettings(s *appcore.MailboxSettings) *MailboxSettings {
+	return &MailboxSettings{
+		Server:      s.Server,
+		MaxMessages: s.MaxMessages,
+		MaxBytes:    s.MaxBytes,
+		TTLHours:    s.TTLHours,
+	}
+}
+
 // CheckForUpdates (заглушка)
 func (a *App) CheckForUpdates() string {
 	return "У вас установлена последняя версия"
@@ -0,0 +1,128 @@
+// Package main — GhostMail, почтовый сервер TeleGhost без GUI.
+// Хранит зашифрованные письма для пользователей, пока они не в сети.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"teleghost/internal/network/envelope"
+	"teleghost/internal/network/mailbox"
+	"teleghost/internal/network/router"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+func main() {
+	def := mailbox.DefaultConfig()
+
+	samAddr := flag.String("sam", "127.0.0.1:7656", "адрес SAM bridge")
+	dataDir := flag.String("data", "ghostmail-data", "директория для ключей и базы")
+	maxMailboxes := flag.Int("max-mailboxes", def.MaxMailboxes, "сколько ящиков принимает сервер")
+	maxMessages := flag.Int("max-messages", def.MaxMessages, "писем в одном ящике")
+	maxMB := flag.Int64("max-mb", def.MaxBytes/(1024*1024), "размер ящика, МБ")
+	ttl := flag.Duration("ttl", def.TTL, "сколько письмо ждёт владельца")
+	maxPerSender := flag.Int("max-per-sender", def.MaxPerSender, "писем одного отправителя в ящике")
+	stampBits := flag.Uint("stamp-bits", uint(def.Stamp.Bits), "сложность штампа SHA-256 для отправителей не из контактов")
+	peersFile := flag.String("peers", "", "файл доверенных серверов («I2P-адрес ключ» в строке); пусто — без федерации")
+	maxHops := flag.Int("max-hops", mailbox.DefaultMaxHops, "сколько серверов может пройти письмо")
+	queueMB := flag.Int64("relay-queue-mb", mailbox.DefaultRelayQueueBytes/(1024*1024), "очередь писем к одному серверу федерации, МБ")
+	statusAddr := flag.String("status", "", "адрес HTTP со статусом сервера (например, 127.0.0.1:7670)")
+	flag.Parse()
+
+	if err := os.MkdirAll(*dataDir, 0700); err != nil {
+		log.Fatalf("[GhostMail] Failed to create data dir: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	store, err := mailbox.NewSQLiteStore(filepath.Join(*dataDir, "ghostmail.db"))
+	if err != nil {
+		log.Fatalf("[GhostMail] %v", err)
+	}
+	defer store.Close()
+
+	cfg := router.DefaultConfig()
+	cfg.SAMAddress = *samAddr
+	cfg.SessionName = fmt.Sprintf("GhostMail-%d", time.Now().Unix())
+	r := router.NewSAMRouter(cfg)
+
+	// Адрес сервера должен быть постоянным: его знают все контакты владельцев ящиков
+	keysPath := filepath.Join(*dataDir, "i2p_keys.dat")
+	if keys, err := i2pkeys.LoadKeys(keysPath); err == nil {
+		r.SetKeys(keys)
+	}
+
+	if err := r.Start(ctx); err != nil {
+		log.Fatalf("[GhostMail] I2P connection failed: %v", err)
+	}
+	defer func() { _ = r.Stop() }()
+
+	if _, err := os.Stat(keysPath); os.IsNotExist(err) {
+		if err := i2pkeys.StoreKeys(r.GetKeys(), keysPath); err != nil {
+			log.Printf("[GhostMail] Failed to save I2P keys: %v", err)
+		}
+	}
+
+	ln, err := r.Listen()
+	if err != nil {
+		log.Fatalf("[GhostMail] %v", err)
+	}
+
+	srv := mailbox.NewServer(store, mailbox.Config{
+		MaxMailboxes: *maxMailboxes,
+		MaxMessages:  *maxMessages,
+		MaxBytes:     *maxMB * 1024 * 1024,
+		TTL:          *ttl,
+		MaxPerSender: *maxPerSender,
+		Stamp:        envelope.StampPolicy{Algorithm: def.Stamp.Algorithm, Bits: uint32(*stampBits)}, // #nosec G115
+	})
+
+	// Ключ сервера в федерации: его передают администраторам доверенных серверов
+	serverKey, err := mailbox.LoadServerKey(filepath.Join(*dataDir, "server_key"))
+	if err != nil {
+		log.Fatalf("[GhostMail] %v", err)
+	}
+	if *peersFile != "" {
+		peers, err := mailbox.LoadPeers(*peersFile)
+		if err != nil {
+			log.Fatalf("[GhostMail] %v", err)
+		}
+		if err := srv.EnableFederation(mailbox.FederationConfig{
+			Key:        serverKey,
+			Peers:      peers,
+			Dial:       r.Dial,
+			MaxHops:    *maxHops,
+			QueueBytes: *queueMB * 1024 * 1024,
+		}); err != nil {
+			log.Fatalf("[GhostMail] %v", err)
+		}
+		log.Printf("[GhostMail] Federation enabled with %d trusted servers, server key %s", len(peers), srv.ServerPubKey())
+	}
+
+	if *statusAddr != "" {
+		statusSrv := &http.Server{Addr: *statusAddr, Handler: srv.StatusHandler(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := statusSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("[GhostMail] Status server failed: %v", err)
+			}
+		}()
+		defer func() { _ = statusSrv.Close() }()
+		log.Printf("[GhostMail] Status at http://%s/status", *statusAddr)
+	}
+
+	log.Printf("[GhostMail] Serving mailboxes at %s", r.GetDestination())
+
+	if err := srv.Serve(ctx, ln); err != nil {
+		log.Printf("[GhostMail] Server stopped: %v", err)
+	}
+}
@@ -85,6 +85,7 @@ const METHODS = [
     'Logout',
     'GetMyInfo',
     'GetCurrentProfile',
+    'GetDiagnostics',
     'UpdateMyProfile',
     'RequestProfileUpdate',
 
@@ -93,6 +94,13 @@ const METHODS = [
     'AddContactFromClipboard',
     'DeleteContact',
     'GetContacts',
+    'SetContactReadReceipts',
+    'SetContactHidePresence',
+    'SetContactBlocked',
+    'GetMessageRequests',
+    'AcceptMessageRequest',
+    'DeclineMessageRequest',
+    'BlockMessageRequest',
 
     // === Folders ===
     'CreateFolder',
@@ -102,6 +110,22 @@ const METHODS = [
     'AddChatToFolder',
     'RemoveChatFromFolder',
 
+    // === Groups ===
+    'CreateGroup',
+    'GetGroups',
+    'GetGroupInvites',
+    'AcceptGroupInvite',
+    'DeclineGroupInvite',
+    'InviteToGroup',
+    'KickFromGroup',
+    'LeaveGroup',
+
+    // === Channels ===
+    'CreateChannel',
+    'GetChannels',
+    'SubscribeChannel',
+    'UnsubscribeChannel',
+
     // === Messages ===
     'SendText',
     'SendFileMessage',
@@ -109,6 +133,8 @@ const METHODS = [
     'EditMessage',
     'DeleteMessage',
     'DeleteMessageForAll',
+    'RetryMessage',
+    'CancelMessage',
     'AcceptFileTransfer',
     'DeclineFileTransfer',
 
@@ -119,6 +145,10 @@ const METHODS = [
     'GetAppAboutInfo',
     'CheckForUpdates',
     'GetNetworkStatus',
+    'GetPrivacySettings',
+    'SavePrivacySettings',
+    'GetMailboxSettings',
+    'SetMailboxServer',
 
     // === Reseed ===
     'ExportReseed',
@@ -143,6 +173,7 @@ const METHODS = [
     // === Notifications ===
     'GetUnreadCount',
     'MarkChatAsRead',
+    'SendTyping',
 ];
 
 // ─── Генерируем объект Api ──────────────────────────────────────────────────
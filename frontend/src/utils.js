@@ -34,10 +34,19 @@ export function formatTime(timestamp) {
     return date.toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
 }
 
+export function formatLastSeen(timestamp) {
+    if (!timestamp) return 'Оффлайн';
+    const date = new Date(timestamp);
+    const time = date.toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
+    if (date.toDateString() === new Date().toDateString()) return `Был(а) в ${time}`;
+    return `Был(а) ${date.toLocaleDateString()} в ${time}`;
+}
+
 export function getStatusColor(status) {
     switch (status) {
         case 'online': return '#4CAF50';
         case 'connecting': return '#FFC107';
+        case 'reconnecting': return '#FF9800';
         case 'starting': return '#9C27B0';
         case 'error': return '#F44336';
         default: return '#9E9E9E';
@@ -48,6 +57,7 @@ export function getStatusText(status) {
     switch (status) {
         case 'online': return 'В сети';
         case 'connecting': return 'Подключение...';
+        case 'reconnecting': return 'Переподключение...';
         case 'starting': return 'Запуск I2P...';
         case 'error': return 'Ошибка I2P';
         default: return 'Оффлайн';
@@ -5,12 +5,20 @@ import {http} from '../models';
 
 export function AcceptFileTransfer(arg1:string):Promise<void>;
 
+export function AcceptGroupInvite(arg1:string):Promise<void>;
+
+export function AcceptMessageRequest(arg1:string):Promise<void>;
+
 export function AddChatToFolder(arg1:string,arg2:string):Promise<void>;
 
 export function AddContact(arg1:string,arg2:string):Promise<main.ContactInfo>;
 
 export function AddContactFromClipboard(arg1:string):Promise<main.ContactInfo>;
 
+export function BlockMessageRequest(arg1:string):Promise<void>;
+
+export function CancelMessage(arg1:string):Promise<void>;
+
 export function CheckForUpdates():Promise<string>;
 
 export function ClipboardGet():Promise<string>;
@@ -21,18 +29,28 @@ export function CopyToClipboard(arg1:string):Promise<void>;
 
 export function CreateAccount():Promise<string>;
 
+export function CreateChannel(arg1:string):Promise<main.ChannelInfo>;
+
 export function CreateFolder(arg1:string,arg2:string):Promise<void>;
 
+export function CreateGroup(arg1:string,arg2:Array<string>):Promise<main.GroupInfo>;
+
 export function CreateProfile(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string,arg6:boolean):Promise<void>;
 
 export function DeclineFileTransfer(arg1:string):Promise<void>;
 
+export function DeclineGroupInvite(arg1:string):Promise<void>;
+
+export function DeclineMessageRequest(arg1:string):Promise<void>;
+
 export function DeleteContact(arg1:string):Promise<void>;
 
 export function DeleteFolder(arg1:string):Promise<void>;
 
 export function DeleteMessage(arg1:string):Promise<void>;
 
+export function DeleteMessageForAll(arg1:string):Promise<void>;
+
 export function DeleteProfile(arg1:string):Promise<void>;
 
 export function EditMessage(arg1:string,arg2:string):Promise<void>;
@@ -43,18 +61,30 @@ export function ExportReseed():Promise<string>;
 
 export function GetAppAboutInfo():Promise<main.AppAboutInfo>;
 
+export function GetChannels():Promise<Array<main.ChannelInfo>>;
+
 export function GetContacts():Promise<Array<main.ContactInfo>>;
 
 export function GetCurrentProfile():Promise<Record<string, any>>;
 
+export function GetDiagnostics():Promise<main.Diagnostics>;
+
 export function GetFileBase64(arg1:string):Promise<string>;
 
 export function GetFolders():Promise<Array<main.FolderInfo>>;
 
+export function GetGroupInvites():Promise<Array<main.GroupInfo>>;
+
+export function GetGroups():Promise<Array<main.GroupInfo>>;
+
 export function GetImageThumbnail(arg1:string):Promise<string>;
 
+export function GetMailboxSettings():Promise<main.MailboxSettings>;
+
 export function GetMediaHandler():Promise<http.Handler>;
 
+export function GetMessageRequests():Promise<Array<main.ContactInfo>>;
+
 export function GetMessages(arg1:string,arg2:number,arg3:number):Promise<Array<main.MessageInfo>>;
 
 export function GetMyDestination():Promise<string>;
@@ -63,6 +93,8 @@ export function GetMyInfo():Promise<Record<string, any>>;
 
 export function GetNetworkStatus():Promise<string>;
 
+export function GetPrivacySettings():Promise<main.PrivacySettings>;
+
 export function GetRouterSettings():Promise<main.RouterSettings>;
 
 export function GetUnreadCount():Promise<number>;
@@ -71,6 +103,12 @@ export function ImportAccount(arg1:string):Promise<void>;
 
 export function ImportReseed(arg1:string):Promise<void>;
 
+export function InviteToGroup(arg1:string,arg2:string):Promise<void>;
+
+export function KickFromGroup(arg1:string,arg2:string):Promise<void>;
+
+export function LeaveGroup(arg1:string):Promise<void>;
+
 export function ListProfiles():Promise<Array<Record<string, any>>>;
 
 export function Login(arg1:string):Promise<void>;
@@ -87,8 +125,12 @@ export function RemoveChatFromFolder(arg1:string,arg2:string):Promise<void>;
 
 export function RequestProfile(arg1:string):Promise<void>;
 
+export function RetryMessage(arg1:string):Promise<void>;
+
 export function SaveFileToLocation(arg1:string,arg2:string):Promise<string>;
 
+export function SavePrivacySettings(arg1:Record<string, any>):Promise<void>;
+
 export function SaveRouterSettings(arg1:Record<string, any>):Promise<void>;
 
 export function SaveTempImage(arg1:string,arg2:string):Promise<string>;
@@ -101,20 +143,34 @@ export function SendFileMessage(arg1:string,arg2:string,arg3:string,arg4:Array<s
 
 export function SendText(arg1:string,arg2:string,arg3:string):Promise<void>;
 
+export function SendTyping(arg1:string,arg2:boolean):Promise<void>;
+
 export function SetActiveChat(arg1:string):Promise<void>;
 
 export function SetAppFocus(arg1:boolean):Promise<void>;
 
+export function SetContactBlocked(arg1:string,arg2:boolean):Promise<void>;
+
+export function SetContactHidePresence(arg1:string,arg2:boolean):Promise<void>;
+
+export function SetContactReadReceipts(arg1:string,arg2:boolean):Promise<void>;
+
 export function SetFileSelector(arg1:main.FileSelector):Promise<void>;
 
+export function SetMailboxServer(arg1:string):Promise<main.MailboxSettings>;
+
 export function ShareFile(arg1:string):Promise<void>;
 
 export function ShowInFolder(arg1:string):Promise<void>;
 
 export function ShowWindow():Promise<void>;
 
+export function SubscribeChannel(arg1:string):Promise<main.ChannelInfo>;
+
 export function UnlockProfile(arg1:string,arg2:string):Promise<string>;
 
+export function UnsubscribeChannel(arg1:string):Promise<void>;
+
 export function UpdateFolder(arg1:string,arg2:string,arg3:string):Promise<void>;
 
 export function UpdateMyProfile(arg1:string,arg2:string,arg3:string):Promise<void>;
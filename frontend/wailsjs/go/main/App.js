@@ -6,6 +6,14 @@ export function AcceptFileTransfer(arg1) {
   return window['go']['main']['App']['AcceptFileTransfer'](arg1);
 }
 
+export function AcceptGroupInvite(arg1) {
+  return window['go']['main']['App']['AcceptGroupInvite'](arg1);
+}
+
+export function AcceptMessageRequest(arg1) {
+  return window['go']['main']['App']['AcceptMessageRequest'](arg1);
+}
+
 export function AddChatToFolder(arg1, arg2) {
   return window['go']['main']['App']['AddChatToFolder'](arg1, arg2);
 }
@@ -18,6 +26,14 @@ export function AddContactFromClipboard(arg1) {
   return window['go']['main']['App']['AddContactFromClipboard'](arg1);
 }
 
+export function BlockMessageRequest(arg1) {
+  return window['go']['main']['App']['BlockMessageRequest'](arg1);
+}
+
+export function CancelMessage(arg1) {
+  return window['go']['main']['App']['CancelMessage'](arg1);
+}
+
 export function CheckForUpdates() {
   return window['go']['main']['App']['CheckForUpdates']();
 }
@@ -38,10 +54,18 @@ export function CreateAccount() {
   return window['go']['main']['App']['CreateAccount']();
 }
 
+export function CreateChannel(arg1) {
+  return window['go']['main']['App']['CreateChannel'](arg1);
+}
+
 export function CreateFolder(arg1, arg2) {
   return window['go']['main']['App']['CreateFolder'](arg1, arg2);
 }
 
+export function CreateGroup(arg1, arg2) {
+  return window['go']['main']['App']['CreateGroup'](arg1, arg2);
+}
+
 export function CreateProfile(arg1, arg2, arg3, arg4, arg5, arg6) {
   return window['go']['main']['App']['CreateProfile'](arg1, arg2, arg3, arg4, arg5, arg6);
 }
@@ -50,6 +74,14 @@ export function DeclineFileTransfer(arg1) {
   return window['go']['main']['App']['DeclineFileTransfer'](arg1);
 }
 
+export function DeclineGroupInvite(arg1) {
+  return window['go']['main']['App']['DeclineGroupInvite'](arg1);
+}
+
+export function DeclineMessageRequest(arg1) {
+  return window['go']['main']['App']['DeclineMessageRequest'](arg1);
+}
+
 export function DeleteContact(arg1) {
   return window['go']['main']['App']['DeleteContact'](arg1);
 }
@@ -62,6 +94,10 @@ export function DeleteMessage(arg1) {
   return window['go']['main']['App']['DeleteMessage'](arg1);
 }
 
+export function DeleteMessageForAll(arg1) {
+  return window['go']['main']['App']['DeleteMessageForAll'](arg1);
+}
+
 export function DeleteProfile(arg1) {
   return window['go']['main']['App']['DeleteProfile'](arg1);
 }
@@ -82,6 +118,10 @@ export function GetAppAboutInfo() {
   return window['go']['main']['App']['GetAppAboutInfo']();
 }
 
+export function GetChannels() {
+  return window['go']['main']['App']['GetChannels']();
+}
+
 export function GetContacts() {
   return window['go']['main']['App']['GetContacts']();
 }
@@ -90,6 +130,10 @@ export function GetCurrentProfile() {
   return window['go']['main']['App']['GetCurrentProfile']();
 }
 
+export function GetDiagnostics() {
+  return window['go']['main']['App']['GetDiagnostics']();
+}
+
 export function GetFileBase64(arg1) {
   return window['go']['main']['App']['GetFileBase64'](arg1);
 }
@@ -98,14 +142,30 @@ export function GetFolders() {
   return window['go']['main']['App']['GetFolders']();
 }
 
+export function GetGroupInvites() {
+  return window['go']['main']['App']['GetGroupInvites']();
+}
+
+export function GetGroups() {
+  return window['go']['main']['App']['GetGroups']();
+}
+
 export function GetImageThumbnail(arg1) {
   return window['go']['main']['App']['GetImageThumbnail'](arg1);
 }
 
+export function GetMailboxSettings() {
+  return window['go']['main']['App']['GetMailboxSettings']();
+}
+
 export function GetMediaHandler() {
   return window['go']['main']['App']['GetMediaHandler']();
 }
 
+export function GetMessageRequests() {
+  return window['go']['main']['App']['GetMessageRequests']();
+}
+
 export function GetMessages(arg1, arg2, arg3) {
   return window['go']['main']['App']['GetMessages'](arg1, arg2, arg3);
 }
@@ -122,6 +182,10 @@ export function GetNetworkStatus() {
   return window['go']['main']['App']['GetNetworkStatus']();
 }
 
+export function GetPrivacySettings() {
+  return window['go']['main']['App']['GetPrivacySettings']();
+}
+
 export function GetRouterSettings() {
   return window['go']['main']['App']['GetRouterSettings']();
 }
@@ -138,6 +202,18 @@ export function ImportReseed(arg1) {
   return window['go']['main']['App']['ImportReseed'](arg1);
 }
 
+export function InviteToGroup(arg1, arg2) {
+  return window['go']['main']['App']['InviteToGroup'](arg1, arg2);
+}
+
+export function KickFromGroup(arg1, arg2) {
+  return window['go']['main']['App']['KickFromGroup'](arg1, arg2);
+}
+
+export function LeaveGroup(arg1) {
+  return window['go']['main']['App']['LeaveGroup'](arg1);
+}
+
 export function ListProfiles() {
   return window['go']['main']['App']['ListProfiles']();
 }
@@ -170,10 +246,18 @@ export function RequestProfile(arg1) {
   return window['go']['main']['App']['RequestProfile'](arg1);
 }
 
+export function RetryMessage(arg1) {
+  return window['go']['main']['App']['RetryMessage'](arg1);
+}
+
 export function SaveFileToLocation(arg1, arg2) {
   return window['go']['main']['App']['SaveFileToLocation'](arg1, arg2);
 }
 
+export function SavePrivacySettings(arg1) {
+  return window['go']['main']['App']['SavePrivacySettings'](arg1);
+}
+
 export function SaveRouterSettings(arg1) {
   return window['go']['main']['App']['SaveRouterSettings'](arg1);
 }
@@ -198,6 +282,10 @@ export function SendText(arg1, arg2, arg3) {
   return window['go']['main']['App']['SendText'](arg1, arg2, arg3);
 }
 
+export function SendTyping(arg1, arg2) {
+  return window['go']['main']['App']['SendTyping'](arg1, arg2);
+}
+
 export function SetActiveChat(arg1) {
   return window['go']['main']['App']['SetActiveChat'](arg1);
 }
@@ -206,10 +294,26 @@ export function SetAppFocus(arg1) {
   return window['go']['main']['App']['SetAppFocus'](arg1);
 }
 
+export function SetContactBlocked(arg1, arg2) {
+  return window['go']['main']['App']['SetContactBlocked'](arg1, arg2);
+}
+
+export function SetContactHidePresence(arg1, arg2) {
+  return window['go']['main']['App']['SetContactHidePresence'](arg1, arg2);
+}
+
+export function SetContactReadReceipts(arg1, arg2) {
+  return window['go']['main']['App']['SetContactReadReceipts'](arg1, arg2);
+}
+
 export function SetFileSelector(arg1) {
   return window['go']['main']['App']['SetFileSelector'](arg1);
 }
 
+export function SetMailboxServer(arg1) {
+  return window['go']['main']['App']['SetMailboxServer'](arg1);
+}
+
 export function ShareFile(arg1) {
   return window['go']['main']['App']['ShareFile'](arg1);
 }
@@ -222,10 +326,18 @@ export function ShowWindow() {
   return window['go']['main']['App']['ShowWindow']();
 }
 
+export function SubscribeChannel(arg1) {
+  return window['go']['main']['App']['SubscribeChannel'](arg1);
+}
+
 export function UnlockProfile(arg1, arg2) {
   return window['go']['main']['App']['UnlockProfile'](arg1, arg2);
 }
 
+export function UnsubscribeChannel(arg1) {
+  return window['go']['main']['App']['UnsubscribeChannel'](arg1);
+}
+
 export function UpdateFolder(arg1, arg2, arg3) {
   return window['go']['main']['App']['UpdateFolder'](arg1, arg2, arg3);
 }
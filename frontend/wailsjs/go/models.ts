@@ -39,6 +39,32 @@ export namespace main {
 	        this.license = source["license"];
 	    }
 	}
+	export class ChannelInfo {
+	    ID: string;
+	    Name: string;
+	    Link: string;
+	    IsOwner: boolean;
+	    SubscriberCount: number;
+	    LastMessage: string;
+	    LastMessageTime: number;
+	    UnreadCount: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ChannelInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.Name = source["Name"];
+	        this.Link = source["Link"];
+	        this.IsOwner = source["IsOwner"];
+	        this.SubscriberCount = source["SubscriberCount"];
+	        this.LastMessage = source["LastMessage"];
+	        this.LastMessageTime = source["LastMessageTime"];
+	        this.UnreadCount = source["UnreadCount"];
+	    }
+	}
 	export class ContactInfo {
 	    ID: string;
 	    Nickname: string;
@@ -47,10 +73,14 @@ export namespace main {
 	    I2PAddress: string;
 	    LastMessage: string;
 	    LastMessageTime: number;
-	    LastSeen: string;
+	    LastSeen: number;
 	    IsOnline: boolean;
 	    ChatID: string;
 	    UnreadCount: number;
+	    ReadReceipts: boolean;
+	    HidePresence: boolean;
+	    IsBlocked: boolean;
+	    IsPending: boolean;
 	
 	    static createFrom(source: any = {}) {
 	        return new ContactInfo(source);
@@ -69,6 +99,48 @@ export namespace main {
 	        this.IsOnline = source["IsOnline"];
 	        this.ChatID = source["ChatID"];
 	        this.UnreadCount = source["UnreadCount"];
+	        this.ReadReceipts = source["ReadReceipts"];
+	        this.HidePresence = source["HidePresence"];
+	        this.IsBlocked = source["IsBlocked"];
+	        this.IsPending = source["IsPending"];
+	    }
+	}
+	export class Diagnostics {
+	    PacketsReceived: number;
+	    RejectedSignature: number;
+	    RejectedStale: number;
+	    RejectedReplay: number;
+	    RejectedDowngrade: number;
+	    RejectedDecrypt: number;
+	    RejectedRecipient: number;
+	    RejectedStamp: number;
+	    RejectedBlocked: number;
+	    RejectedRate: number;
+	    RejectedOversize: number;
+	    RejectedConnection: number;
+	    RejectedInFlight: number;
+	    LegacyUnprotected: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Diagnostics(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.PacketsReceived = source["PacketsReceived"];
+	        this.RejectedSignature = source["RejectedSignature"];
+	        this.RejectedStale = source["RejectedStale"];
+	        this.RejectedReplay = source["RejectedReplay"];
+	        this.RejectedDowngrade = source["RejectedDowngrade"];
+	        this.RejectedDecrypt = source["RejectedDecrypt"];
+	        this.RejectedRecipient = source["RejectedRecipient"];
+	        this.RejectedStamp = source["RejectedStamp"];
+	        this.RejectedBlocked = source["RejectedBlocked"];
+	        this.RejectedRate = source["RejectedRate"];
+	        this.RejectedOversize = source["RejectedOversize"];
+	        this.RejectedConnection = source["RejectedConnection"];
+	        this.RejectedInFlight = source["RejectedInFlight"];
+	        this.LegacyUnprotected = source["LegacyUnprotected"];
 	    }
 	}
 	export class FolderInfo {
@@ -93,6 +165,86 @@ export namespace main {
 	        this.UnreadCount = source["UnreadCount"];
 	    }
 	}
+	export class GroupMemberInfo {
+	    PublicKey: string;
+	    Nickname: string;
+	    IsAdmin: boolean;
+	    IsMe: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new GroupMemberInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.PublicKey = source["PublicKey"];
+	        this.Nickname = source["Nickname"];
+	        this.IsAdmin = source["IsAdmin"];
+	        this.IsMe = source["IsMe"];
+	    }
+	}
+	export class GroupInfo {
+	    ID: string;
+	    Name: string;
+	    Members: GroupMemberInfo[];
+	    IsAdmin: boolean;
+	    IsMember: boolean;
+	    LastMessage: string;
+	    LastMessageTime: number;
+	    UnreadCount: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new GroupInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.Name = source["Name"];
+	        this.Members = this.convertValues(source["Members"], GroupMemberInfo);
+	        this.IsAdmin = source["IsAdmin"];
+	        this.IsMember = source["IsMember"];
+	        this.LastMessage = source["LastMessage"];
+	        this.LastMessageTime = source["LastMessageTime"];
+	        this.UnreadCount = source["UnreadCount"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class MailboxSettings {
+	    Server: string;
+	    MaxMessages: number;
+	    MaxBytes: number;
+	    TTLHours: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new MailboxSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Server = source["Server"];
+	        this.MaxMessages = source["MaxMessages"];
+	        this.MaxBytes = source["MaxBytes"];
+	        this.TTLHours = source["TTLHours"];
+	    }
+	}
 	export class MessageInfo {
 	    ID: string;
 	    Content: string;
@@ -106,6 +258,9 @@ export namespace main {
 	    Attachments: any[];
 	    ReplyToID: string;
 	    ReplyPreview?: appcore.ReplyPreview;
+	    EditedAt: number;
+	    SenderID: string;
+	    SenderName: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new MessageInfo(source);
@@ -125,6 +280,9 @@ export namespace main {
 	        this.Attachments = source["Attachments"];
 	        this.ReplyToID = source["ReplyToID"];
 	        this.ReplyPreview = this.convertValues(source["ReplyPreview"], appcore.ReplyPreview);
+	        this.EditedAt = source["EditedAt"];
+	        this.SenderID = source["SenderID"];
+	        this.SenderName = source["SenderName"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -145,9 +303,33 @@ export namespace main {
 		    return a;
 		}
 	}
+	export class PrivacySettings {
+	    ReadReceipts: boolean;
+	    StampAlgorithm: string;
+	    StampDifficulty: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new PrivacySettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ReadReceipts = source["ReadReceipts"];
+	        this.StampAlgorithm = source["StampAlgorithm"];
+	        this.StampDifficulty = source["StampDifficulty"];
+	    }
+	}
 	export class RouterSettings {
+	    SecurityProfile: string;
 	    TunnelLength: number;
+	    TunnelQuantity: number;
+	    TunnelVariance: number;
+	    BackupQuantity: number;
+	    LeaseSetType: number;
+	    LeaseSetAuth: boolean;
 	    LogToFile: boolean;
+	    MessageTTLHours: number;
+	    ClockSkewMinutes: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new RouterSettings(source);
@@ -155,8 +337,16 @@ export namespace main {
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.SecurityProfile = source["SecurityProfile"];
 	        this.TunnelLength = source["TunnelLength"];
+	        this.TunnelQuantity = source["TunnelQuantity"];
+	        this.TunnelVariance = source["TunnelVariance"];
+	        this.BackupQuantity = source["BackupQuantity"];
+	        this.LeaseSetType = source["LeaseSetType"];
+	        this.LeaseSetAuth = source["LeaseSetAuth"];
 	        this.LogToFile = source["LogToFile"];
+	        this.MessageTTLHours = source["MessageTTLHours"];
+	        this.ClockSkewMinutes = source["ClockSkewMinutes"];
 	    }
 	}
 
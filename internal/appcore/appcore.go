@@ -15,6 +15,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -22,10 +23,12 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"teleghost/internal/core"
 	"teleghost/internal/core/identity"
+	"teleghost/internal/network"
 	"teleghost/internal/network/messenger"
 	"teleghost/internal/network/profiles"
 	"teleghost/internal/network/router"
@@ -72,10 +75,11 @@ type PlatformServices interface {
 type NetworkStatus string
 
 const (
-	StatusOffline    NetworkStatus = "offline"
-	StatusConnecting NetworkStatus = "connecting"
-	StatusOnline     NetworkStatus = "online"
-	StatusError      NetworkStatus = "error"
+	StatusOffline      NetworkStatus = "offline"
+	StatusConnecting   NetworkStatus = "connecting"
+	StatusOnline       NetworkStatus = "online"
+	StatusReconnecting NetworkStatus = "reconnecting"
+	StatusError        NetworkStatus = "error"
 )
 
 // ReplyPreview содержит краткую информацию об исходном сообщении для ответа
@@ -105,9 +109,14 @@ type ContactInfo struct {
 	ChatID          string     `json:"ChatID"`
 	IsBlocked       bool       `json:"IsBlocked"`
 	IsVerified      bool       `json:"IsVerified"`
+	IsPending       bool       `json:"IsPending"`
 	LastMessage     string     `json:"LastMessage"`
 	LastMessageTime *time.Time `json:"LastMessageTime"`
 	UnreadCount     int        `json:"UnreadCount"`
+	ReadReceipts    bool       `json:"ReadReceipts"`
+	IsOnline        bool       `json:"IsOnline"`
+	LastSeen        *time.Time `json:"LastSeen"`
+	HidePresence    bool       `json:"HidePresence"`
 }
 
 const (
@@ -150,6 +159,9 @@ type MessageInfo struct {
 	Attachments  []map[string]interface{} `json:"Attachments,omitempty"`
 	FileCount    int                      `json:"FileCount,omitempty"`
 	TotalSize    int64                    `json:"TotalSize,omitempty"`
+	EditedAt     int64                    `json:"EditedAt,omitempty"`
+	SenderID     string                   `json:"SenderID,omitempty"`
+	SenderName   string                   `json:"SenderName,omitempty"`
 }
 
 // UserInfo — информация о пользователе
@@ -181,8 +193,37 @@ type PendingTransfer struct {
 
 // RouterSettings — настройки роутера
 type RouterSettings struct {
-	TunnelLength int  `json:"tunnelLength"`
-	LogToFile    bool `json:"logToFile"`
+	// SecurityProfile — профиль защиты (fast, default, invisible); custom — параметры туннелей заданы вручную
+	SecurityProfile string `json:"securityProfile"`
+	TunnelLength    int    `json:"tunnelLength"`
+	TunnelQuantity  int    `json:"tunnelQuantity"`
+	TunnelVariance  int    `json:"tunnelVariance"`
+	BackupQuantity  int    `json:"backupQuantity"`
+	LeaseSetType    int    `json:"leaseSetType"`
+	LeaseSetAuth    bool   `json:"leaseSetAuth"` // зашифрованный leaseSet открыт только контактам
+
+	LogToFile       bool `json:"logToFile"`
+	MessageTTLHours int  `json:"messageTTLHours"`
+
+	// ClockSkewMinutes — допустимое расхождение часов с собеседником
+	ClockSkewMinutes int `json:"clockSkewMinutes"`
+}
+
+// PrivacySettings — настройки приватности профиля
+type PrivacySettings struct {
+	ReadReceipts bool `json:"readReceipts"`
+
+	// Proof-of-work штампы для незнакомцев: "sha256" или "argon2" и сложность в битах (0 — по умолчанию)
+	StampAlgorithm  string `json:"stampAlgorithm"`
+	StampDifficulty int    `json:"stampDifficulty"`
+}
+
+// MailboxSettings — почтовый сервер GhostMail профиля и его квоты
+type MailboxSettings struct {
+	Server      string `json:"server"`
+	MaxMessages int    `json:"maxMessages"`
+	MaxBytes    int64  `json:"maxBytes"`
+	TTLHours    int    `json:"ttlHours"`
 }
 
 // ─── AppCore — единое ядро приложения ───────────────────────────────────────
@@ -196,6 +237,7 @@ type AppCore struct {
 	Identity       *identity.Identity
 	Repo           *sqlite.Repository
 	Router         *router.SAMRouter
+	Transport      network.NetworkRouter // сеть вместо SAM (задаётся до входа; тесты поднимают узлы в памяти)
 	Messenger      *messenger.Service
 	ProfileManager *profiles.ProfileManager
 	Emitter        EventEmitter
@@ -211,6 +253,15 @@ type AppCore struct {
 	TransferMu       sync.RWMutex
 	PendingTransfers map[string]*PendingTransfer
 
+	outbox    *outbox
+	transfers *transfers
+	presence  *presence
+	channels  *channels
+	mailbox   *mailboxPoller
+
+	// announceLeaseSet — b33 адрес сменился: разослать профиль, когда сессия поднимется
+	announceLeaseSet atomic.Bool
+
 	mu sync.RWMutex
 }
 
@@ -227,6 +278,10 @@ func NewAppCore(dataDir string, emitter EventEmitter, platform PlatformServices)
 		Platform:         platform,
 		IsVisible:        true,
 		PendingTransfers: make(map[string]*PendingTransfer),
+		transfers:        newTransfers(),
+		presence:         newPresence(),
+		channels:         newChannels(),
+		mailbox:          newMailboxPoller(),
 	}
 
 	return app
@@ -257,12 +312,18 @@ func (a *AppCore) Init() error {
 func (a *AppCore) Shutdown() {
 	log.Println("[AppCore] Shutting down...")
 
+	a.stopOutbox()
+	a.stopPresence()
+	a.stopMailbox()
 	if a.Messenger != nil {
 		_ = a.Messenger.Stop()
 	}
 	if a.Router != nil {
 		_ = a.Router.Stop()
 	}
+	if a.Transport != nil {
+		_ = a.Transport.Stop()
+	}
 	if a.Repo != nil {
 		a.Repo.Close()
 	}
@@ -282,6 +343,10 @@ func (a *AppCore) UpdateMyProfile(nickname, bio, avatar string) error {
 	if err := a.Repo.UpdateMyProfile(a.Ctx, nickname, bio, avatar); err != nil {
 		return err
 	}
+	// Имя уходит собеседникам и в handshake
+	if a.Messenger != nil {
+		a.Messenger.SetNickname(nickname)
+	}
 
 	// Синхронизируем с ProfileManager (чтобы на экране входа были актуальные данные)
 	if a.ProfileManager != nil && a.Identity != nil {
@@ -624,12 +689,93 @@ func (a *AppCore) InitUserRepository(userID string) error {
 func (a *AppCore) ConnectToI2P() {
 	a.SetNetworkStatus(StatusConnecting)
 
+	transport := a.Transport
+	if transport == nil {
+		a.Router = a.newSAMRouter()
+		a.syncLeaseSet()
+		transport = a.Router
+	}
+
+	if err := transport.Start(a.Ctx); err != nil {
+		if a.Ctx.Err() != nil {
+			log.Println("[AppCore] I2P connection canceled")
+			return
+		}
+		a.SetNetworkStatus(StatusError)
+		log.Printf("[AppCore] I2P connection failed: %v", err)
+		return
+	}
+	if a.Router != nil {
+		a.saveI2PKeys()
+	}
+
+	// Запускаем messenger
+	a.Messenger = messenger.NewService(transport, a.Identity.Keys, a.OnMessageReceived)
+	a.Messenger.SetAttachmentSaver(a.SaveAttachment)
+	a.Messenger.SetContactHandler(a.OnContactRequest)
+	a.Messenger.SetFileOfferHandler(a.onFileOffer)
+	a.Messenger.SetFileResponseHandler(a.onFileResponse)
+	a.Messenger.SetFileChunkHandler(a.onFileChunk)
+	a.Messenger.SetFileChunkAckHandler(a.onFileChunkAck)
+	a.Messenger.SetFileResumeHandler(a.onFileResume)
+	a.Messenger.SetProfileUpdateHandler(a.onProfileUpdate)
+	a.Messenger.SetProfileRequestHandler(a.onProfileRequest)
+	a.Messenger.SetReceiptHandler(a.onReceipt)
+	a.Messenger.SetMessageEditHandler(a.onMessageEdit)
+	a.Messenger.SetMessageDeleteHandler(a.onMessageDelete)
+	a.Messenger.SetSessionStore(a.Repo)
+	a.Messenger.SetReplayStore(a.Repo)
+	a.Messenger.SetClockSkew(time.Duration(a.GetRouterSettings().ClockSkewMinutes) * time.Minute)
+	a.Messenger.SetPeerResolver(a.resolvePeerPubKey)
+	a.Messenger.SetReplyFilter(a.mayReply)
+	a.Messenger.SetIntroKeyResolver(a.resolveIntroKey)
+	a.updateIntroKey()
+	a.syncBlockList()
+	a.Messenger.SetPeerActivityHandler(a.onPeerActivity)
+	a.Messenger.SetPeerCapabilitiesHandler(a.onPeerCapabilities)
+	a.Messenger.SetTypingHandler(a.onTyping)
+	a.Messenger.SetPresenceHandler(a.onPresence)
+	a.Messenger.SetGroupControlHandler(a.onGroupControl)
+	a.Messenger.SetChannelPostHandler(a.onChannelPost)
+	a.Messenger.SetChannelControlHandler(a.onChannelControl)
+	a.Messenger.SetMailboxResolver(a.resolveMailbox)
+	a.Messenger.SetMailboxAddress(a.GetMailboxSettings().Server)
+	a.Messenger.SetKnownPeerChecker(a.isKnownPeer)
+	a.Messenger.SetStampPolicy(a.stampPolicy())
+	a.Messenger.SetLeaseSetHandler(a.onLeaseSet)
+	a.updateLeaseSetInfo()
+	if user, _ := a.Repo.GetMyProfile(a.Ctx); user != nil && user.Nickname != "" {
+		a.Messenger.SetNickname(user.Nickname)
+	}
+	a.restorePeerProtocols()
+
+	if err := a.Messenger.Start(a.Ctx); err != nil {
+		a.SetNetworkStatus(StatusError)
+		return
+	}
+
+	a.startOutbox()
+	a.startPresence()
+	a.startMailbox()
+	go a.resumeTransfers("")
+	go a.syncChannels()
+
+	a.SetNetworkStatus(StatusOnline)
+}
+
+// newSAMRouter создаёт SAM-роутер по настройкам; ключи берём из БД, чтобы адрес не менялся
+func (a *AppCore) newSAMRouter() *router.SAMRouter {
 	routerSettings := a.GetRouterSettings()
 	cfg := router.DefaultConfig()
-	cfg.InboundLength = routerSettings.TunnelLength
-	cfg.OutboundLength = routerSettings.TunnelLength
+	routerSettings.profile().Apply(cfg)
+	if key, err := a.Identity.Keys.LeaseSetAuthKey(); err == nil {
+		cfg.LeaseSetPrivKey = key.Bytes()
+	} else {
+		log.Printf("[AppCore] Failed to derive leaseSet key: %v", err)
+	}
 
-	a.Router = router.NewSAMRouter(cfg)
+	r := router.NewSAMRouter(cfg)
+	r.SetStateHandler(a.onRouterState)
 
 	// Загружаем существующие ключи из БД
 	if a.Repo != nil {
@@ -640,7 +786,7 @@ func (a *AppCore) ConnectToI2P() {
 			if err := os.WriteFile(keysPath, user.I2PKeys, 0600); err == nil {
 				keys, err := i2pkeys.LoadKeys(keysPath)
 				if err == nil {
-					a.Router.SetKeys(keys)
+					r.SetKeys(keys)
 				} else {
 					log.Printf("[AppCore] Failed to load I2P keys from %s: %v", keysPath, err)
 				}
@@ -648,54 +794,68 @@ func (a *AppCore) ConnectToI2P() {
 			}
 		}
 	}
+	return r
+}
 
-	if err := a.Router.Start(a.Ctx); err != nil {
-		if a.Ctx.Err() != nil {
-			log.Println("[AppCore] I2P connection canceled")
-			return
-		}
+// onRouterState переводит состояние SAM сессии в статус сети. Когда сессия вернулась,
+// старые соединения мертвы: сбрасываем их и сразу досылаем очередь.
+func (a *AppCore) onRouterState(state router.SessionState, err error) {
+	switch state {
+	case router.SessionReconnecting:
+		a.SetNetworkStatus(StatusReconnecting)
+	case router.SessionError:
+		log.Printf("[AppCore] I2P reconnect failed: %v", err)
 		a.SetNetworkStatus(StatusError)
-		log.Printf("[AppCore] I2P connection failed: %v", err)
+	case router.SessionOnline:
+		if m := a.Messenger; m != nil {
+			m.ResetConnections()
+		}
+		if ob := a.getOutbox(); ob != nil {
+			ob.pokeAll()
+		}
+		if a.announceLeaseSet.CompareAndSwap(true, false) {
+			go a.announceProfile()
+		}
+		a.SetNetworkStatus(StatusOnline)
+	}
+}
+
+// saveI2PKeys сохраняет ключи и адрес SAM-роутера (если были сгенерированы заново)
+func (a *AppCore) saveI2PKeys() {
+	if a.Repo == nil || a.Identity == nil {
 		return
 	}
+	keys := a.Router.GetKeys()
+	dest := a.Router.GetDestination()
 
-	// Если ключи были сгенерированы заново, сохраняем их
-	if a.Repo != nil && a.Identity != nil {
-		keys := a.Router.GetKeys()
-		dest := a.Router.GetDestination()
-
-		user, _ := a.Repo.GetMyProfile(a.Ctx)
-		if user != nil {
-			keysPath := filepath.Join(a.DataDir, "users", a.Identity.Keys.UserID, "temp_i2p_keys.dat")
-			if err := i2pkeys.StoreKeys(keys, keysPath); err == nil {
-				if keysData, err := os.ReadFile(keysPath); err == nil {
-					user.I2PKeys = keysData
-					user.I2PAddress = dest
-					log.Printf("[AppCore] Saving I2P destination to DB: %s", dest)
-					if err := a.Repo.SaveUser(a.Ctx, user); err != nil {
-						log.Printf("[AppCore] Failed to save I2P destination to DB: %v", err)
-					}
-				}
-				_ = os.Remove(keysPath)
+	user, _ := a.Repo.GetMyProfile(a.Ctx)
+	if user == nil {
+		return
+	}
+	keysPath := filepath.Join(a.DataDir, "users", a.Identity.Keys.UserID, "temp_i2p_keys.dat")
+	if err := i2pkeys.StoreKeys(keys, keysPath); err == nil {
+		if keysData, err := os.ReadFile(keysPath); err == nil {
+			user.I2PKeys = keysData
+			user.I2PAddress = dest
+			log.Printf("[AppCore] Saving I2P destination to DB: %s", dest)
+			if err := a.Repo.SaveUser(a.Ctx, user); err != nil {
+				log.Printf("[AppCore] Failed to save I2P destination to DB: %v", err)
 			}
 		}
+		_ = os.Remove(keysPath)
 	}
+}
 
-	// Запускаем messenger
-	a.Messenger = messenger.NewService(a.Router, a.Identity.Keys, a.OnMessageReceived)
-	a.Messenger.SetAttachmentSaver(a.SaveAttachment)
-	a.Messenger.SetContactHandler(a.OnContactRequest)
-	a.Messenger.SetFileOfferHandler(a.onFileOffer)
-	a.Messenger.SetFileResponseHandler(a.onFileResponse)
-	a.Messenger.SetProfileUpdateHandler(a.onProfileUpdate)
-	a.Messenger.SetProfileRequestHandler(a.onProfileRequest)
-
-	if err := a.Messenger.Start(a.Ctx); err != nil {
-		a.SetNetworkStatus(StatusError)
-		return
+// resolvePeerPubKey возвращает публичный ключ контакта по I2P адресу (для E2EE-сессий)
+func (a *AppCore) resolvePeerPubKey(destination string) string {
+	if a.Repo == nil {
+		return ""
 	}
-
-	a.SetNetworkStatus(StatusOnline)
+	contact, err := a.Repo.GetContactByAddress(a.Ctx, destination)
+	if err != nil || contact == nil {
+		return ""
+	}
+	return contact.PublicKey
 }
 
 // formatAvatarURL преобразует локальный путь в URL для фронтенда для текущего пользователя
@@ -727,7 +887,7 @@ func (a *AppCore) formatProfileAvatarURL(userID, path string) string {
 }
 
 // onProfileUpdate обрабатывает входящее обновление профиля от контакта
-func (a *AppCore) onProfileUpdate(senderPubKey, nickname, bio string, avatar []byte, senderAddr string) {
+func (a *AppCore) onProfileUpdate(senderPubKey, nickname, bio string, avatar []byte, mailboxAddr, senderAddr string) {
 	if a.Repo == nil {
 		return
 	}
@@ -739,6 +899,10 @@ func (a *AppCore) onProfileUpdate(senderPubKey, nickname, bio string, avatar []b
 
 	contact, _ := a.Repo.GetContactByPublicKey(a.Ctx, senderPubKey)
 	if contact == nil {
+		// Пакет из почтового ящика приходит без адреса — искать не по чему
+		if senderAddr == "" {
+			return
+		}
 		// Try to find by address (important for b32-only contacts discovery)
 		contact, _ = a.Repo.GetContactByAddress(a.Ctx, senderAddr)
 		if contact != nil {
@@ -774,6 +938,11 @@ func (a *AppCore) onProfileUpdate(senderPubKey, nickname, bio string, avatar []b
 	if err := a.Repo.SaveContact(a.Ctx, contact); err != nil {
 		log.Printf("[AppCore] Failed to save contact on profile update: %v", err)
 	}
+	if mailboxAddr != contact.MailboxAddress {
+		if err := a.Repo.SetContactMailbox(a.Ctx, senderPubKey, mailboxAddr); err != nil {
+			log.Printf("[AppCore] Failed to save contact mailbox: %v", err)
+		}
+	}
 	a.Emitter.Emit("contact_updated")
 }
 
@@ -783,6 +952,48 @@ func (a *AppCore) onProfileRequest(requestorPubKey string) {
 		return
 	}
 
+	// Отправляем наш профиль в ответ
+	// Нам нужен адрес контакта, чтобы отправить сообщение.
+	// Но у нас есть только PubKey. Ищем контакт в БД.
+	contact, _ := a.Repo.GetContactByPublicKey(a.Ctx, requestorPubKey)
+	if contact != nil && !contact.IsPending {
+		a.sendMyProfile(contact.I2PAddress)
+	}
+}
+
+// duplicateIncoming разбирает входящее сообщение с уже занятым ID. Повтор своего же
+// сообщения отправитель получает только как новый отчёт о доставке, чужое сообщение не
+// трогаем. true — сообщение заменяет предложение файлов того же отправителя: старый
+// протокол присылает файлы с ID предложения.
+func (a *AppCore) duplicateIncoming(contact *core.Contact, msg *core.Message) bool {
+	existing, err := a.Repo.GetMessage(a.Ctx, msg.ID)
+	if err != nil || existing == nil {
+		return false
+	}
+	own := !existing.IsOutgoing && existing.SenderID == msg.SenderID && existing.ChatID == msg.ChatID
+	switch {
+	case own && existing.ContentType == "file_offer" && len(msg.Attachments) > 0:
+		if err := a.Repo.SaveMessage(a.Ctx, msg); err != nil {
+			log.Printf("[AppCore] Failed to save message: %v", err)
+			return false
+		}
+		return true
+	case own:
+		if !contact.IsPending {
+			a.sendDeliveryReceipt(contact, msg)
+		}
+	default:
+		log.Printf("[AppCore] Rejected message %s from %s: ID already taken", msg.ID, msg.SenderID[:min(16, len(msg.SenderID))])
+	}
+	return false
+}
+
+// sendMyProfile отправляет наш профиль (с адресом почтового ящика) по адресу
+func (a *AppCore) sendMyProfile(destination string) {
+	if a.Repo == nil || a.Messenger == nil || destination == "" {
+		return
+	}
+
 	user, _ := a.Repo.GetMyProfile(a.Ctx)
 	if user == nil {
 		return
@@ -800,14 +1011,8 @@ func (a *AppCore) onProfileRequest(requestorPubKey string) {
 		}
 	}
 
-	// Отправляем наш профиль в ответ
-	// Нам нужен адрес контакта, чтобы отправить сообщение.
-	// Но у нас есть только PubKey. Ищем контакт в БД.
-	contact, _ := a.Repo.GetContactByPublicKey(a.Ctx, requestorPubKey)
-	if contact != nil {
-		if err := a.Messenger.SendProfileUpdate(contact.I2PAddress, user.Nickname, user.Bio, avatarData); err != nil {
-			log.Printf("[AppCore] Failed to send profile update: %v", err)
-		}
+	if err := a.Messenger.SendProfileUpdate(destination, user.Nickname, user.Bio, avatarData); err != nil {
+		log.Printf("[AppCore] Failed to send profile update: %v", err)
 	}
 }
 
@@ -829,8 +1034,18 @@ func (a *AppCore) OnMessageReceived(msg *core.Message, senderPubKey, senderAddr
 	}
 
 	msg.SenderAddr = senderAddr
+	if msg.GroupID != "" {
+		a.onGroupMessage(msg, senderPubKey)
+		return
+	}
+
 	var contact *core.Contact
 	contact, _ = a.Repo.GetContactByPublicKey(a.Ctx, senderPubKey)
+	if contact == nil && senderAddr == "" {
+		// Письмо из почтового ящика от незнакомца: ответить ему некуда
+		log.Printf("[AppCore] Dropped stored message from unknown sender %s", senderPubKey[:min(16, len(senderPubKey))])
+		return
+	}
 	if contact == nil {
 		// Try to find by address (for manual b32 contacts)
 		contact, _ = a.Repo.GetContactByAddress(a.Ctx, senderAddr)
@@ -849,7 +1064,7 @@ func (a *AppCore) OnMessageReceived(msg *core.Message, senderPubKey, senderAddr
 			}
 			a.Emitter.Emit("contact_updated")
 		} else {
-			// Create new contact
+			// Create new contact (незнакомец попадает в запросы переписки)
 			newChatID := identity.CalculateChatID(a.Identity.Keys.PublicKeyBase64, senderPubKey)
 			contact = &core.Contact{
 				ID:         uuid.New().String(),
@@ -857,14 +1072,17 @@ func (a *AppCore) OnMessageReceived(msg *core.Message, senderPubKey, senderAddr
 				Nickname:   "Unknown " + senderPubKey[:8],
 				I2PAddress: senderAddr,
 				ChatID:     newChatID,
+				IsPending:  !a.isRelatedPeer(senderPubKey),
 				AddedAt:    time.Now(),
 			}
 			if err := a.Repo.SaveContact(a.Ctx, contact); err != nil {
 				log.Printf("[AppCore] Failed to auto-save contact: %v", err)
 			}
-			a.Emitter.Emit("contact_updated")
+			if !contact.IsPending {
+				a.Emitter.Emit("contact_updated")
+			}
 			// Запрашиваем профиль у нового контакта
-			if msg.ContentType == "text" && !contact.IsVerified {
+			if msg.ContentType == "text" && !contact.IsVerified && !contact.IsPending {
 				go func(addr string) {
 					if a.Messenger == nil {
 						log.Printf("[AppCore] Messenger not initialized, cannot send auto profile request")
@@ -880,10 +1098,27 @@ func (a *AppCore) OnMessageReceived(msg *core.Message, senderPubKey, senderAddr
 
 	msg.ChatID = contact.ChatID
 
-	if err := a.Repo.SaveMessage(a.Ctx, msg); err != nil {
+	if err := a.Repo.InsertMessage(a.Ctx, msg); errors.Is(err, sqlite.ErrMessageExists) {
+		if !a.duplicateIncoming(contact, msg) {
+			return
+		}
+	} else if err != nil {
+		log.Printf("[AppCore] Failed to save message: %v", err)
+		return
+	}
+
+	if contact.IsPending {
+		// Карантин: без отчёта о доставке и уведомлений, пока запрос не принят
+		log.Printf("[AppCore] Quarantined message from %s", senderPubKey[:min(16, len(senderPubKey))])
+		a.emitMessageRequest(contact)
 		return
 	}
 
+	// Сообщение сохранено — подтверждаем доставку отправителю
+	if !msg.IsOutgoing {
+		a.sendDeliveryReceipt(contact, msg)
+	}
+
 	var replyToIDStr string
 	if msg.ReplyToID != nil {
 		replyToIDStr = *msg.ReplyToID
@@ -922,19 +1157,24 @@ func (a *AppCore) OnMessageReceived(msg *core.Message, senderPubKey, senderAddr
 	})
 
 	if !msg.IsOutgoing {
-		// Помечаем как прочитанное сразу, если чат активен
-		if a.ActiveChatID == msg.ChatID && a.IsFocused {
-			if err := a.Repo.MarkChatAsRead(a.Ctx, msg.ChatID); err != nil {
-				log.Printf("[AppCore] Failed to mark chat as read: %v", err)
-			}
-		}
+		a.afterIncoming(msg, contact.Nickname)
+	}
+}
 
-		// Подавляем уведомление, если приложение видимо, в фокусе и открыт именно этот чат
-		if !a.IsVisible || !(a.IsFocused && a.ActiveChatID == msg.ChatID) {
-			go a.SendNotification(contact.Nickname, msg.Content, msg.ContentType)
+// afterIncoming отмечает прочтение в открытом чате, показывает уведомление и обновляет счётчик
+func (a *AppCore) afterIncoming(msg *core.Message, title string) {
+	// Помечаем как прочитанное сразу, если чат активен
+	if a.ActiveChatID == msg.ChatID && a.IsFocused {
+		if err := a.markChatRead(msg.ChatID); err != nil {
+			log.Printf("[AppCore] Failed to mark chat as read: %v", err)
 		}
-		go a.UpdateUnreadCount()
 	}
+
+	// Подавляем уведомление, если приложение видимо, в фокусе и открыт именно этот чат
+	if !a.IsVisible || !(a.IsFocused && a.ActiveChatID == msg.ChatID) {
+		go a.SendNotification(title, msg.Content, msg.ContentType)
+	}
+	go a.UpdateUnreadCount()
 }
 
 // OnContactRequest — обработчик запросов дружбы.
@@ -961,8 +1201,8 @@ func (a *AppCore) OnContactRequest(pubKey, nickname, i2pAddress string) {
 			publicKeyChanged = true
 		}
 
-		// Update Nickname if meaningful change
-		if nickname != "" && nickname != "Unknown" && contact.Nickname != nickname {
+		// Update Nickname if meaningful change ("User" — имя по умолчанию, оно не затирает профиль)
+		if nickname != "" && nickname != "Unknown" && nickname != "User" && contact.Nickname != nickname {
 			contact.Nickname = nickname
 			updated = true
 		}
@@ -986,7 +1226,7 @@ func (a *AppCore) OnContactRequest(pubKey, nickname, i2pAddress string) {
 
 			// Send handshake back if public key was updated (to ensure they have ours)
 			// But avoid infinite loop if key didn't change (handled by 'updated' flag logic which checks contact.PublicKey != pubKey)
-			if publicKeyChanged && a.Messenger != nil {
+			if publicKeyChanged && !contact.IsPending && a.Messenger != nil {
 				// We just updated it to pubKey, so checking == is always true here.
 				// The guard is that we only enter this block if it was DIFFERENT before.
 				go func(addr string) {
@@ -997,7 +1237,7 @@ func (a *AppCore) OnContactRequest(pubKey, nickname, i2pAddress string) {
 			}
 		}
 	} else {
-		// New contact
+		// New contact. Незнакомцу не отвечаем, пока пользователь не примет запрос переписки.
 		newChatID := identity.CalculateChatID(a.Identity.Keys.PublicKeyBase64, pubKey)
 		contact = &core.Contact{
 			ID:         uuid.New().String(),
@@ -1005,9 +1245,14 @@ func (a *AppCore) OnContactRequest(pubKey, nickname, i2pAddress string) {
 			Nickname:   nickname,
 			I2PAddress: i2pAddress,
 			ChatID:     newChatID,
+			IsPending:  !a.isRelatedPeer(pubKey),
 			AddedAt:    time.Now(),
 		}
-		if err := a.Repo.SaveContact(a.Ctx, contact); err == nil {
+		if err := a.Repo.SaveContact(a.Ctx, contact); err != nil {
+			log.Printf("[AppCore] Failed to save new contact: %v", err)
+		} else if contact.IsPending {
+			a.emitMessageRequest(contact)
+		} else {
 			a.Emitter.Emit("new_contact", map[string]interface{}{
 				"nickname": nickname,
 			})
@@ -1027,10 +1272,11 @@ func (a *AppCore) OnContactRequest(pubKey, nickname, i2pAddress string) {
 					}
 				}(i2pAddress)
 			}
-		} else {
-			log.Printf("[AppCore] Failed to save new contact: %v", err)
 		}
 	}
+
+	a.storePeerProtocol(pubKey)
+	a.storePeerLeaseSet(pubKey)
 }
 
 // UpdateUnreadCount обновляет счётчик непрочитанных.
@@ -216,6 +216,9 @@ func (a *AppCore) CreateAccount() (string, error) {
 func (a *AppCore) Logout() {
 	log.Printf("[AppCore] Logging out...")
 
+	a.stopOutbox()
+	a.stopPresence()
+	a.stopMailbox()
 	if a.Messenger != nil {
 		_ = a.Messenger.Stop()
 		a.Messenger = nil
@@ -224,6 +227,9 @@ func (a *AppCore) Logout() {
 		_ = a.Router.Stop()
 		a.Router = nil
 	}
+	if a.Transport != nil {
+		_ = a.Transport.Stop()
+	}
 	if a.Repo != nil {
 		_ = a.Repo.Close()
 		a.Repo = nil
@@ -254,9 +260,9 @@ func (a *AppCore) GetMyInfo() map[string]interface{} {
 	}
 
 	if a.Messenger != nil {
-		res["Destination"] = a.Messenger.GetDestination()
+		res["Destination"] = a.myContactAddress(a.Messenger.GetDestination())
 	} else if a.Router != nil {
-		res["Destination"] = a.Router.GetDestination()
+		res["Destination"] = a.myContactAddress(a.Router.GetDestination())
 	}
 
 	res["Status"] = string(a.Status)
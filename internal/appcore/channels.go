@@ -0,0 +1,585 @@
+package appcore
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"teleghost/internal/core"
+	pb "teleghost/internal/proto"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxChannelNameLength — максимальная длина названия канала (в символах)
+	maxChannelNameLength = 64
+
+	// channelHistoryLimit — сколько последних пропущенных постов досылается за один запрос.
+	// Давно не заходившему подписчику хватит свежих, старые он не получит.
+	channelHistoryLimit = 100
+
+	// channelSyncInterval — как часто просим владельца дослать посты, пока он в сети
+	channelSyncInterval = 5 * time.Minute
+
+	// channelNotifyWindow — посты старше этого досылаются без уведомлений
+	channelNotifyWindow = 10 * time.Minute
+
+	// channelLinkPrefix — префикс ссылки на канал
+	channelLinkPrefix = "teleghost-channel:"
+)
+
+// ChannelInfo — информация о канале (для фронтенда)
+type ChannelInfo struct {
+	ID              string     `json:"ID"`
+	Name            string     `json:"Name"`
+	Link            string     `json:"Link"`
+	IsOwner         bool       `json:"IsOwner"`
+	SubscriberCount int        `json:"SubscriberCount"`
+	LastMessage     string     `json:"LastMessage"`
+	LastMessageTime *time.Time `json:"LastMessageTime"`
+	UnreadCount     int        `json:"UnreadCount"`
+}
+
+// channels — состояние каналов в памяти
+type channels struct {
+	// publishMu упорядочивает выдачу номеров постов
+	publishMu sync.Mutex
+
+	mu        sync.Mutex
+	requested map[string]time.Time // владелец -> последний запрос пропущенных постов
+}
+
+func newChannels() *channels {
+	return &channels{requested: make(map[string]time.Time)}
+}
+
+// shouldSync отмечает запрос постов у владельца; false — недавно уже спрашивали
+func (c *channels) shouldSync(ownerPubKey string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.requested[ownerPubKey]) < channelSyncInterval {
+		return false
+	}
+	c.requested[ownerPubKey] = now
+	return true
+}
+
+// channelLink — ссылка, по которой можно подписаться на канал
+func channelLink(c *core.Channel) string {
+	return channelLinkPrefix + c.ID + "@" + c.OwnerAddress
+}
+
+// parseChannelLink разбирает ссылку на канал (префикс можно не указывать)
+func parseChannelLink(link string) (channelID, ownerAddress string, err error) {
+	link = strings.TrimPrefix(strings.TrimSpace(link), channelLinkPrefix)
+	channelID, ownerAddress, ok := strings.Cut(link, "@")
+	if !ok || channelID == "" || len(ownerAddress) < 32 {
+		return "", "", fmt.Errorf("invalid channel link")
+	}
+	if _, err := uuid.Parse(channelID); err != nil {
+		return "", "", fmt.Errorf("invalid channel link")
+	}
+	return channelID, ownerAddress, nil
+}
+
+// getChannel возвращает канал по ID (nil, если это не канал)
+func (a *AppCore) getChannel(id string) *core.Channel {
+	if a.Repo == nil || id == "" {
+		return nil
+	}
+	c, err := a.Repo.GetChannel(a.Ctx, id)
+	if err != nil {
+		log.Printf("[AppCore] Failed to load channel: %v", err)
+		return nil
+	}
+	return c
+}
+
+// CreateChannel создаёт канал, в котором мы публикуем посты.
+func (a *AppCore) CreateChannel(name string) (*ChannelInfo, error) {
+	if a.Repo == nil {
+		return nil, fmt.Errorf("not logged in")
+	}
+	if a.Messenger == nil {
+		return nil, fmt.Errorf("not connected to I2P")
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > maxChannelNameLength {
+		return nil, fmt.Errorf("channel name must be 1-%d characters", maxChannelNameLength)
+	}
+
+	c := &core.Channel{
+		ID:           uuid.New().String(),
+		Name:         name,
+		OwnerPubKey:  a.Identity.Keys.PublicKeyBase64,
+		OwnerAddress: a.Messenger.GetDestination(),
+		IsOwner:      true,
+	}
+	if err := a.Repo.SaveChannel(a.Ctx, c); err != nil {
+		return nil, err
+	}
+	a.emitChannelUpdated(c.ID, "CREATE")
+	return a.channelInfo(c, 0), nil
+}
+
+// SubscribeChannel подписывается на канал по ссылке.
+// Название канала и его посты придут от владельца, когда он будет в сети.
+func (a *AppCore) SubscribeChannel(link string) (*ChannelInfo, error) {
+	if a.Repo == nil {
+		return nil, fmt.Errorf("not logged in")
+	}
+	if a.Messenger == nil {
+		return nil, fmt.Errorf("not connected to I2P")
+	}
+
+	channelID, ownerAddress, err := parseChannelLink(link)
+	if err != nil {
+		return nil, err
+	}
+	if ownerAddress == a.Messenger.GetDestination() {
+		return nil, fmt.Errorf("this is your own channel")
+	}
+	if a.getChannel(channelID) != nil {
+		return nil, fmt.Errorf("already subscribed to this channel")
+	}
+
+	c := &core.Channel{
+		ID:           channelID,
+		OwnerAddress: ownerAddress,
+	}
+	if err := a.Repo.SaveChannel(a.Ctx, c); err != nil {
+		return nil, err
+	}
+	a.sendChannelControl(ownerAddress, &pb.ChannelControl{
+		Action:    pb.ChannelAction_CHANNEL_SUBSCRIBE,
+		ChannelId: channelID,
+	})
+
+	a.emitChannelUpdated(c.ID, "SUBSCRIBE")
+	return a.channelInfo(c, 0), nil
+}
+
+// UnsubscribeChannel отписывается от канала и удаляет его вместе с постами.
+// Свой канал удаляется совсем: подписчики просто перестают получать посты.
+func (a *AppCore) UnsubscribeChannel(channelID string) error {
+	if a.Repo == nil {
+		return fmt.Errorf("not logged in")
+	}
+	c := a.getChannel(channelID)
+	if c == nil {
+		return fmt.Errorf("channel not found")
+	}
+
+	if !c.IsOwner {
+		a.sendChannelControl(c.OwnerAddress, &pb.ChannelControl{
+			Action:    pb.ChannelAction_CHANNEL_UNSUBSCRIBE,
+			ChannelId: c.ID,
+		})
+	}
+
+	if err := a.Repo.DeleteChannel(a.Ctx, c.ID); err != nil {
+		return err
+	}
+	a.emitChannelUpdated(c.ID, "UNSUBSCRIBE")
+	go a.UpdateUnreadCount()
+	return nil
+}
+
+// GetChannels возвращает свои каналы и подписки с последним постом.
+func (a *AppCore) GetChannels() ([]*ChannelInfo, error) {
+	if a.Repo == nil {
+		return []*ChannelInfo{}, nil
+	}
+
+	list, err := a.Repo.ListChannels(a.Ctx)
+	if err != nil {
+		return nil, err
+	}
+	unread, _ := a.Repo.GetUnreadCountByChat(a.Ctx)
+
+	result := make([]*ChannelInfo, len(list))
+	for i, c := range list {
+		result[i] = a.channelInfo(c, unread[c.ID])
+	}
+	return result, nil
+}
+
+// publishChannelPost подписывает и сохраняет пост, затем рассылает его подписчикам.
+// Не получившие пост подписчики дозапросят его по номеру.
+func (a *AppCore) publishChannelPost(c *core.Channel, text string) error {
+	if !c.IsOwner {
+		return fmt.Errorf("only the channel owner can post")
+	}
+
+	now := time.Now()
+	msg := &core.Message{
+		ID:          uuid.New().String(),
+		ChatID:      c.ID,
+		SenderID:    a.Identity.Keys.UserID,
+		Content:     text,
+		ContentType: "text",
+		Status:      core.MessageStatusSent,
+		IsOutgoing:  true,
+		Timestamp:   now.UnixMilli(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	a.channels.publishMu.Lock()
+	current := a.getChannel(c.ID)
+	if current == nil {
+		a.channels.publishMu.Unlock()
+		return fmt.Errorf("channel not found")
+	}
+	post := &pb.ChannelPost{
+		ChannelId: c.ID,
+		Seq:       current.LastSeq + 1,
+		PostId:    msg.ID,
+		Content:   text,
+		Timestamp: msg.Timestamp,
+	}
+	a.Messenger.SignChannelPost(post)
+	_, err := a.Repo.AddChannelPost(a.Ctx, &core.ChannelPost{
+		ChannelID: c.ID,
+		Seq:       post.Seq,
+		MessageID: msg.ID,
+		Signature: post.Signature,
+	}, msg)
+	a.channels.publishMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to save post: %w", err)
+	}
+
+	a.Emitter.Emit("new_message", map[string]interface{}{
+		"ID":         msg.ID,
+		"ChatID":     msg.ChatID,
+		"SenderID":   msg.SenderID,
+		"Content":    msg.Content,
+		"Timestamp":  msg.Timestamp,
+		"IsOutgoing": msg.IsOutgoing,
+		"Status":     msg.Status.String(),
+	})
+
+	subs, err := a.Repo.ListChannelSubscribers(a.Ctx, c.ID)
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+	destinations := make([]string, len(subs))
+	for i, sub := range subs {
+		destinations[i] = sub.I2PAddress
+	}
+
+	m := a.Messenger
+	go func() {
+		failed, err := m.PublishChannelPost(destinations, post)
+		if err != nil {
+			log.Printf("[AppCore] Failed to publish channel post: %v", err)
+			return
+		}
+		if len(failed) > 0 {
+			log.Printf("[AppCore] Channel post #%d not delivered to %d of %d subscribers", post.Seq, len(failed), len(destinations))
+		}
+	}()
+	return nil
+}
+
+// sendChannelControl отправляет управляющий пакет канала в фоне
+func (a *AppCore) sendChannelControl(destination string, ctl *pb.ChannelControl) {
+	m := a.Messenger
+	if m == nil || destination == "" {
+		return
+	}
+	go func() {
+		if err := m.SendChannelControl(destination, ctl); err != nil {
+			log.Printf("[AppCore] Failed to send channel %v: %v", ctl.Action, err)
+		}
+	}()
+}
+
+// requestChannelPosts просит владельца дослать посты после последнего полученного.
+// Пока владелец не ответил ни разу, повторяем подписку.
+func (a *AppCore) requestChannelPosts(c *core.Channel) {
+	action := pb.ChannelAction_CHANNEL_SYNC
+	if c.OwnerPubKey == "" {
+		action = pb.ChannelAction_CHANNEL_SUBSCRIBE
+	}
+	a.sendChannelControl(c.OwnerAddress, &pb.ChannelControl{
+		Action:    action,
+		ChannelId: c.ID,
+		AfterSeq:  c.LastSeq,
+	})
+}
+
+// syncChannels запрашивает пропущенные посты всех подписок (при подключении к сети)
+func (a *AppCore) syncChannels() {
+	if a.Repo == nil {
+		return
+	}
+	list, err := a.Repo.ListChannels(a.Ctx)
+	if err != nil {
+		log.Printf("[AppCore] Failed to list channels: %v", err)
+		return
+	}
+
+	now := time.Now()
+	a.channels.mu.Lock()
+	a.channels.requested = make(map[string]time.Time)
+	for _, c := range list {
+		if !c.IsOwner && c.OwnerPubKey != "" {
+			a.channels.requested[c.OwnerPubKey] = now
+		}
+	}
+	a.channels.mu.Unlock()
+
+	for _, c := range list {
+		if !c.IsOwner {
+			a.requestChannelPosts(c)
+		}
+	}
+}
+
+// onChannelOwnerActivity запрашивает пропущенные посты, когда владелец канала появился в сети
+func (a *AppCore) onChannelOwnerActivity(senderPubKey string) {
+	if a.Repo == nil || !a.channels.shouldSync(senderPubKey, time.Now()) {
+		return
+	}
+	list, err := a.Repo.ListChannelsByOwner(a.Ctx, senderPubKey)
+	if err != nil {
+		return
+	}
+	for _, c := range list {
+		a.requestChannelPosts(c)
+	}
+}
+
+// onChannelControl обрабатывает управляющий пакет канала
+func (a *AppCore) onChannelControl(senderPubKey, senderAddr string, ctl *pb.ChannelControl) {
+	if a.Repo == nil || a.Identity == nil {
+		return
+	}
+	c := a.getChannel(ctl.ChannelId)
+	if c == nil {
+		return
+	}
+
+	if ctl.Action == pb.ChannelAction_CHANNEL_INFO {
+		if !c.IsOwner {
+			a.applyChannelInfo(c, senderPubKey, senderAddr, ctl)
+		}
+		return
+	}
+	if !c.IsOwner {
+		return
+	}
+
+	switch ctl.Action {
+	case pb.ChannelAction_CHANNEL_SUBSCRIBE:
+		if senderAddr == "" {
+			return
+		}
+		err := a.Repo.AddChannelSubscriber(a.Ctx, &core.ChannelSubscriber{
+			ChannelID:  c.ID,
+			PublicKey:  senderPubKey,
+			I2PAddress: senderAddr,
+		})
+		if err != nil {
+			log.Printf("[AppCore] %v", err)
+			return
+		}
+		a.emitChannelUpdated(c.ID, "SUBSCRIBER")
+		a.sendChannelHistory(c, senderAddr, ctl.AfterSeq)
+
+	case pb.ChannelAction_CHANNEL_UNSUBSCRIBE:
+		if err := a.Repo.RemoveChannelSubscriber(a.Ctx, c.ID, senderPubKey); err != nil {
+			log.Printf("[AppCore] %v", err)
+			return
+		}
+		a.emitChannelUpdated(c.ID, "SUBSCRIBER")
+
+	case pb.ChannelAction_CHANNEL_SYNC:
+		// Досылаем только подписчикам, по адресу из подписки
+		subs, err := a.Repo.ListChannelSubscribers(a.Ctx, c.ID)
+		if err != nil {
+			return
+		}
+		for _, sub := range subs {
+			if sub.PublicKey == senderPubKey {
+				a.sendChannelHistory(c, sub.I2PAddress, ctl.AfterSeq)
+				return
+			}
+		}
+	}
+}
+
+// sendChannelHistory отправляет подписчику название канала и посты после afterSeq
+func (a *AppCore) sendChannelHistory(c *core.Channel, destination string, afterSeq uint64) {
+	posts, err := a.Repo.ListChannelPosts(a.Ctx, c.ID, afterSeq, channelHistoryLimit)
+	if err != nil {
+		log.Printf("[AppCore] %v", err)
+		return
+	}
+
+	m := a.Messenger
+	if m == nil {
+		return
+	}
+	go func() {
+		err := m.SendChannelControl(destination, &pb.ChannelControl{
+			Action:    pb.ChannelAction_CHANNEL_INFO,
+			ChannelId: c.ID,
+			Name:      c.Name,
+			LastSeq:   c.LastSeq,
+		})
+		if err != nil {
+			log.Printf("[AppCore] Failed to send channel info: %v", err)
+			return
+		}
+		for _, p := range posts {
+			err := m.SendChannelPost(destination, &pb.ChannelPost{
+				ChannelId:   c.ID,
+				Seq:         p.Seq,
+				PostId:      p.MessageID,
+				Content:     p.Content,
+				Timestamp:   p.Timestamp,
+				OwnerPubKey: c.OwnerPubKey,
+				Signature:   p.Signature,
+			})
+			if err != nil {
+				log.Printf("[AppCore] Failed to send channel history: %v", err)
+				return
+			}
+		}
+	}()
+}
+
+// applyChannelInfo принимает название канала от владельца.
+// Первый ответ принимается только с адреса из ссылки и закрепляет ключ владельца.
+func (a *AppCore) applyChannelInfo(c *core.Channel, senderPubKey, senderAddr string, ctl *pb.ChannelControl) {
+	if c.OwnerPubKey == "" {
+		if senderAddr != c.OwnerAddress {
+			return
+		}
+		c.OwnerPubKey = senderPubKey
+	} else if c.OwnerPubKey != senderPubKey {
+		log.Printf("[AppCore] Rejected channel info for %s: not from the owner", c.ID)
+		return
+	}
+
+	name := strings.TrimSpace(ctl.Name)
+	if name != "" && len([]rune(name)) <= maxChannelNameLength {
+		c.Name = name
+	}
+	if err := a.Repo.SaveChannel(a.Ctx, c); err != nil {
+		log.Printf("[AppCore] Failed to save channel: %v", err)
+		return
+	}
+	a.emitChannelUpdated(c.ID, "INFO")
+}
+
+// onChannelPost сохраняет пост канала, подписанный его владельцем
+func (a *AppCore) onChannelPost(_ string, post *pb.ChannelPost) {
+	if a.Repo == nil {
+		return
+	}
+	c := a.getChannel(post.ChannelId)
+	if c == nil || c.IsOwner || c.OwnerPubKey == "" {
+		return
+	}
+	if post.OwnerPubKey != c.OwnerPubKey {
+		log.Printf("[AppCore] Rejected post for channel %s: not signed by the owner", c.ID)
+		return
+	}
+
+	msg := &core.Message{
+		ID:          channelPostID(c.ID, post.PostId),
+		ChatID:      c.ID,
+		SenderID:    post.OwnerPubKey,
+		Content:     post.Content,
+		ContentType: "text",
+		Status:      core.MessageStatusDelivered,
+		Timestamp:   post.Timestamp,
+	}
+	added, err := a.Repo.AddChannelPost(a.Ctx, &core.ChannelPost{
+		ChannelID: c.ID,
+		Seq:       post.Seq,
+		MessageID: msg.ID,
+		Signature: post.Signature,
+	}, msg)
+	if err != nil {
+		log.Printf("[AppCore] Failed to save channel post: %v", err)
+		return
+	}
+	if !added {
+		return
+	}
+
+	a.Emitter.Emit("new_message", map[string]interface{}{
+		"ID":          msg.ID,
+		"ChatID":      msg.ChatID,
+		"SenderID":    msg.SenderID,
+		"Content":     msg.Content,
+		"Timestamp":   msg.Timestamp,
+		"IsOutgoing":  false,
+		"ContentType": msg.ContentType,
+		"Status":      msg.Status.String(),
+	})
+
+	// Досланные старые посты не вызывают уведомлений
+	if time.Since(time.UnixMilli(post.Timestamp)) > channelNotifyWindow {
+		if a.ActiveChatID == msg.ChatID && a.IsFocused {
+			if err := a.markChatRead(msg.ChatID); err != nil {
+				log.Printf("[AppCore] Failed to mark chat as read: %v", err)
+			}
+		}
+		go a.UpdateUnreadCount()
+		return
+	}
+	a.afterIncoming(msg, a.channelName(c))
+}
+
+// channelPostID — ID сообщения для чужого поста. ID поста выбирает владелец канала,
+// поэтому он живёт в пространстве имён канала и не может занять ID сообщения другого чата.
+func channelPostID(channelID, postID string) string {
+	return channelID + "/" + postID
+}
+
+// channelName — название канала (до ответа владельца его ещё нет)
+func (a *AppCore) channelName(c *core.Channel) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return "Канал " + c.ID[:min(8, len(c.ID))]
+}
+
+// channelInfo собирает информацию о канале для фронтенда
+func (a *AppCore) channelInfo(c *core.Channel, unread int) *ChannelInfo {
+	info := &ChannelInfo{
+		ID:              c.ID,
+		Name:            a.channelName(c),
+		Link:            channelLink(c),
+		IsOwner:         c.IsOwner,
+		SubscriberCount: c.SubscriberCount,
+		LastMessage:     c.LastMessage,
+		UnreadCount:     unread,
+	}
+	if !c.LastMessageTime.IsZero() {
+		tm := c.LastMessageTime
+		info.LastMessageTime = &tm
+	}
+	return info
+}
+
+// emitChannelUpdated сообщает фронтенду об изменении канала
+func (a *AppCore) emitChannelUpdated(channelID, action string) {
+	a.Emitter.Emit("channel_updated", map[string]interface{}{
+		"ChannelID": channelID,
+		"Action":    action,
+	})
+}
@@ -1,11 +1,13 @@
 package appcore
 
 import (
+	"encoding/base64"
 	"fmt"
 	"log"
 	"time"
 
 	"teleghost/internal/core"
+	"teleghost/internal/network/messenger"
 
 	"github.com/google/uuid"
 )
@@ -23,36 +25,52 @@ func (a *AppCore) GetContacts() ([]*ContactInfo, error) {
 
 	result := make([]*ContactInfo, len(contacts))
 	for i, c := range contacts {
-		info := &ContactInfo{
-			ID:          c.ID,
-			Nickname:    c.Nickname,
-			Bio:         c.Bio,
-			Avatar:      a.formatAvatarURL(c.Avatar),
-			I2PAddress:  c.I2PAddress,
-			PublicKey:   c.PublicKey,
-			ChatID:      c.ChatID,
-			IsBlocked:   c.IsBlocked,
-			IsVerified:  c.IsVerified,
-			UnreadCount: c.UnreadCount,
-		}
-		if c.LastMessage != "" {
-			info.LastMessage = c.LastMessage
-			// Можно добавить форматирование времени
-			tm := c.LastMessageTime
-			info.LastMessageTime = &tm
-		}
-		result[i] = info
+		result[i] = a.contactInfo(c)
 	}
 
 	return result, nil
 }
 
-// AddContact добавляет контакт по адресу.
-func (a *AppCore) AddContact(name, destination string) (*ContactInfo, error) {
+// contactInfo формирует информацию о контакте для фронтенда
+func (a *AppCore) contactInfo(c *core.Contact) *ContactInfo {
+	info := &ContactInfo{
+		ID:           c.ID,
+		Nickname:     c.Nickname,
+		Bio:          c.Bio,
+		Avatar:       a.formatAvatarURL(c.Avatar),
+		I2PAddress:   c.I2PAddress,
+		PublicKey:    c.PublicKey,
+		ChatID:       c.ChatID,
+		IsBlocked:    c.IsBlocked,
+		IsVerified:   c.IsVerified,
+		IsPending:    c.IsPending,
+		UnreadCount:  c.UnreadCount,
+		ReadReceipts: !c.ReadReceiptsDisabled,
+		HidePresence: c.HidePresence,
+	}
+	if presenceVisible(c) {
+		info.IsOnline = a.isPeerOnline(c)
+		info.LastSeen = c.LastSeen
+	}
+	if c.LastMessage != "" {
+		info.LastMessage = c.LastMessage
+		// Можно добавить форматирование времени
+		tm := c.LastMessageTime
+		info.LastMessageTime = &tm
+	}
+	return info
+}
+
+// AddContact добавляет контакт по адресу (I2P адрес, возможно, со статическим ключом).
+func (a *AppCore) AddContact(name, address string) (*ContactInfo, error) {
 	if a.Repo == nil {
 		return nil, fmt.Errorf("not logged in")
 	}
 
+	destination, introKey, err := parseContactAddress(address)
+	if err != nil {
+		return nil, err
+	}
 	// Минимальная валидация адреса
 	if len(destination) < 32 {
 		return nil, fmt.Errorf("некорректный I2P адрес (слишком короткий)")
@@ -69,6 +87,12 @@ func (a *AppCore) AddContact(name, destination string) (*ContactInfo, error) {
 	if err := a.Repo.SaveContact(a.Ctx, contact); err != nil {
 		return nil, err
 	}
+	// С ключом первое сообщение дойдёт, даже если собеседник не ответит на handshake
+	if len(introKey) > 0 {
+		if err := a.Repo.SetContactIntroKey(a.Ctx, contact.ID, base64.StdEncoding.EncodeToString(introKey)); err != nil {
+			return nil, err
+		}
+	}
 
 	// Отправляем handshake для установления связи
 	if a.Messenger != nil {
@@ -106,9 +130,107 @@ func (a *AppCore) DeleteContact(id string) error {
 	if a.Repo == nil {
 		return fmt.Errorf("not logged in")
 	}
+	contact, _ := a.Repo.GetContact(a.Ctx, id)
 	err := a.Repo.DeleteContact(a.Ctx, id)
 	if err == nil {
+		// Ключи E2EE-сессий удалённого контакта больше не нужны
+		if contact != nil && contact.PublicKey != "" {
+			if a.Messenger != nil {
+				a.Messenger.DropSessions(contact.PublicKey)
+			} else if errS := a.Repo.DeleteRatchetStates(a.Ctx, contact.PublicKey); errS != nil {
+				log.Printf("[AppCore] Failed to delete sessions: %v", errS)
+			}
+		}
+		a.syncLeaseSet()
 		a.Emitter.Emit("contact_updated")
 	}
 	return err
 }
+
+// SetContactBlocked блокирует или разблокирует контакт.
+// Пакеты заблокированного отбрасываются мессенджером до любых обработчиков.
+func (a *AppCore) SetContactBlocked(id string, blocked bool) error {
+	if a.Repo == nil {
+		return fmt.Errorf("not logged in")
+	}
+	contact, err := a.Repo.GetContact(a.Ctx, id)
+	if err != nil {
+		return err
+	}
+	if contact == nil {
+		return fmt.Errorf("contact not found")
+	}
+	if err := a.Repo.BlockContact(a.Ctx, id, blocked); err != nil {
+		return err
+	}
+	a.syncBlockList()
+	a.syncLeaseSet()
+	if blocked && contact.PublicKey != "" {
+		a.markOffline(contact.PublicKey)
+	}
+	a.Emitter.Emit("contact_updated")
+	return nil
+}
+
+// syncBlockList передаёт мессенджеру ключи и адреса заблокированных контактов
+func (a *AppCore) syncBlockList() {
+	if a.Repo == nil || a.Messenger == nil {
+		return
+	}
+	contacts, err := a.Repo.ListContacts(a.Ctx)
+	if err != nil {
+		log.Printf("[AppCore] Failed to load block list: %v", err)
+		return
+	}
+	var keys, dests []string
+	for _, c := range contacts {
+		if c.IsBlocked {
+			keys = append(keys, c.PublicKey)
+			dests = append(dests, c.I2PAddress)
+		}
+	}
+	a.Messenger.SetBlockList(keys, dests)
+}
+
+// onPeerCapabilities сохраняет согласованную в handshake версию протокола
+func (a *AppCore) onPeerCapabilities(senderPubKey string, version uint32, capabilities messenger.Capability) {
+	if a.Repo == nil {
+		return
+	}
+	if err := a.Repo.UpdateContactProtocol(a.Ctx, senderPubKey, int(version), uint64(capabilities)); err != nil {
+		log.Printf("[AppCore] Failed to save contact protocol: %v", err)
+	}
+}
+
+// storePeerProtocol сохраняет версию для только что созданного контакта
+// (handshake согласуется раньше, чем контакт попадает в БД)
+func (a *AppCore) storePeerProtocol(pubKey string) {
+	if a.Messenger == nil {
+		return
+	}
+	if info, ok := a.Messenger.GetPeerInfo(pubKey); ok && info.Negotiated {
+		a.onPeerCapabilities(pubKey, info.Version, info.Capabilities)
+	}
+}
+
+// restorePeerProtocols передаёт мессенджеру сохранённые версии контактов
+func (a *AppCore) restorePeerProtocols() {
+	contacts, err := a.Repo.ListContacts(a.Ctx)
+	if err != nil {
+		return
+	}
+	for _, c := range contacts {
+		if c.PublicKey != "" && c.ProtocolVersion > 0 {
+			// #nosec G115 -- версия из БД, записанная нами же
+			a.Messenger.SetPeerInfo(c.PublicKey, uint32(c.ProtocolVersion), messenger.Capability(c.Capabilities))
+		}
+	}
+}
+
+// peerSupports сообщает, умеет ли клиент контакта указанную возможность
+func (a *AppCore) peerSupports(contact *core.Contact, c messenger.Capability) bool {
+	if a.Messenger == nil || contact == nil {
+		return true
+	}
+	return a.Messenger.PeerSupports(contact.PublicKey, c)
+}
@@ -37,6 +37,11 @@ func (a *AppCore) GetFolders() ([]*FolderInfo, error) {
 	for _, c := range contacts {
 		cidToChatID[c.ID] = c.ChatID
 	}
+	// У групп ID совпадает с ChatID
+	groups, _ := a.Repo.ListGroups(a.Ctx)
+	for _, g := range groups {
+		cidToChatID[g.ID] = g.ID
+	}
 
 	unreadMap, _ := a.Repo.GetUnreadCountByChat(a.Ctx)
 
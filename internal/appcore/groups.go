@@ -0,0 +1,878 @@
+package appcore
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"teleghost/internal/core"
+	"teleghost/internal/network/messenger"
+	pb "teleghost/internal/proto"
+	"teleghost/internal/repository/sqlite"
+
+	"github.com/google/uuid"
+)
+
+// maxGroupNameLength — максимальная длина названия группы (в символах)
+const maxGroupNameLength = 64
+
+// GroupMemberInfo — участник группы (для фронтенда)
+type GroupMemberInfo struct {
+	PublicKey string `json:"PublicKey"`
+	Nickname  string `json:"Nickname"`
+	IsAdmin   bool   `json:"IsAdmin"`
+	IsMe      bool   `json:"IsMe"`
+}
+
+// GroupInfo — информация о группе (для фронтенда)
+type GroupInfo struct {
+	ID              string             `json:"ID"`
+	Name            string             `json:"Name"`
+	Members         []*GroupMemberInfo `json:"Members"`
+	IsAdmin         bool               `json:"IsAdmin"`
+	IsMember        bool               `json:"IsMember"`
+	LastMessage     string             `json:"LastMessage"`
+	LastMessageTime *time.Time         `json:"LastMessageTime"`
+	UnreadCount     int                `json:"UnreadCount"`
+}
+
+// groupToState собирает состав группы для подписи и отправки
+func groupToState(g *core.Group) *pb.GroupState {
+	state := &pb.GroupState{
+		GroupId:      g.ID,
+		Name:         g.Name,
+		Epoch:        g.Epoch,
+		UpdatedAt:    g.StateUpdatedAt,
+		SignerPubKey: g.SignerPubKey,
+		Signature:    g.Signature,
+		Members:      make([]*pb.GroupMember, 0, len(g.Members)),
+	}
+	for _, m := range g.Members {
+		role := pb.GroupRole_GROUP_ROLE_MEMBER
+		if m.Role == core.GroupRoleAdmin {
+			role = pb.GroupRole_GROUP_ROLE_ADMIN
+		}
+		state.Members = append(state.Members, &pb.GroupMember{
+			PubKey:     m.PublicKey,
+			I2PAddress: m.I2PAddress,
+			Nickname:   m.Nickname,
+			Role:       role,
+		})
+	}
+	return state
+}
+
+// groupFromState восстанавливает группу из принятого состава
+func groupFromState(state *pb.GroupState) *core.Group {
+	g := &core.Group{
+		ID:             state.GroupId,
+		Name:           state.Name,
+		Epoch:          state.Epoch,
+		StateUpdatedAt: state.UpdatedAt,
+		SignerPubKey:   state.SignerPubKey,
+		Signature:      state.Signature,
+		Members:        make([]*core.GroupMember, 0, len(state.Members)),
+	}
+	for _, m := range state.Members {
+		role := core.GroupRoleMember
+		if m.Role == pb.GroupRole_GROUP_ROLE_ADMIN {
+			role = core.GroupRoleAdmin
+		}
+		g.Members = append(g.Members, &core.GroupMember{
+			GroupID:    state.GroupId,
+			PublicKey:  m.PubKey,
+			I2PAddress: m.I2PAddress,
+			Nickname:   m.Nickname,
+			Role:       role,
+		})
+	}
+	return g
+}
+
+// validGroupState проверяет состав, пришедший от собеседника
+func validGroupState(state *pb.GroupState) error {
+	// ID задаёт создатель группы: принимаем только UUID, как у наших групп
+	if id, err := uuid.Parse(state.GroupId); err != nil || id.String() != state.GroupId {
+		return fmt.Errorf("bad group id")
+	}
+	if strings.TrimSpace(state.Name) == "" || len([]rune(state.Name)) > maxGroupNameLength {
+		return fmt.Errorf("bad group name")
+	}
+	if len(state.Members) > core.MaxGroupMembers {
+		return fmt.Errorf("too many members: %d", len(state.Members))
+	}
+
+	seen := make(map[string]bool, len(state.Members))
+	hasAdmin := false
+	for _, m := range state.Members {
+		if m.PubKey == "" || m.I2PAddress == "" || seen[m.PubKey] {
+			return fmt.Errorf("bad member list")
+		}
+		seen[m.PubKey] = true
+		hasAdmin = hasAdmin || m.Role == pb.GroupRole_GROUP_ROLE_ADMIN
+	}
+	if len(state.Members) > 0 && !hasAdmin {
+		return fmt.Errorf("group has no admin")
+	}
+	return nil
+}
+
+// primaryAdmin — администратор, который отвечает за выход участников
+// (первый по ключу, кроме except). Так все участники выбирают одного и того же.
+func primaryAdmin(g *core.Group, except string) string {
+	var admins []string
+	for _, m := range g.Members {
+		if m.Role == core.GroupRoleAdmin && m.PublicKey != except {
+			admins = append(admins, m.PublicKey)
+		}
+	}
+	if len(admins) == 0 {
+		return ""
+	}
+	sort.Strings(admins)
+	return admins[0]
+}
+
+// removeGroupMember убирает участника; если администраторов не осталось,
+// им становится первый по ключу из оставшихся
+func removeGroupMember(g *core.Group, pubKey string) {
+	members := make([]*core.GroupMember, 0, len(g.Members))
+	for _, m := range g.Members {
+		if m.PublicKey != pubKey {
+			members = append(members, m)
+		}
+	}
+	g.Members = members
+
+	if len(members) == 0 || primaryAdmin(g, "") != "" {
+		return
+	}
+	next := members[0]
+	for _, m := range members[1:] {
+		if m.PublicKey < next.PublicKey {
+			next = m
+		}
+	}
+	next.Role = core.GroupRoleAdmin
+}
+
+// groupMemberKey — ключ паузы доставки участнику группы в outbox
+func groupMemberKey(groupID, pubKey string) string {
+	return groupID + "|" + pubKey
+}
+
+// getGroup возвращает группу по ID (nil, если это не группа)
+func (a *AppCore) getGroup(id string) *core.Group {
+	if a.Repo == nil || id == "" {
+		return nil
+	}
+	g, err := a.Repo.GetGroup(a.Ctx, id)
+	if err != nil {
+		log.Printf("[AppCore] Failed to load group: %v", err)
+		return nil
+	}
+	return g
+}
+
+// chatIDTaken — ID уже принадлежит другому чату: переписке с контактом, каналу или нам самим.
+// Группа с таким ID подложила бы свои сообщения в чужой чат.
+func (a *AppCore) chatIDTaken(id string) bool {
+	if a.Identity != nil && id == a.Identity.Keys.UserID {
+		return true
+	}
+	return a.findContactByChatID(id) != nil || a.getChannel(id) != nil
+}
+
+// adminGroup возвращает группу, которой мы можем управлять
+func (a *AppCore) adminGroup(groupID string) (*core.Group, error) {
+	if a.Repo == nil {
+		return nil, fmt.Errorf("not logged in")
+	}
+	if a.Messenger == nil {
+		return nil, fmt.Errorf("not connected to I2P")
+	}
+	g := a.getGroup(groupID)
+	if g == nil {
+		return nil, fmt.Errorf("group not found")
+	}
+	if !g.IsMember || !g.IsAdmin(a.Identity.Keys.PublicKeyBase64) {
+		return nil, fmt.Errorf("only group admins can do this")
+	}
+	return g, nil
+}
+
+// contactAsMember проверяет, что контакт можно добавить в группу
+func (a *AppCore) contactAsMember(contactID string) (*core.GroupMember, error) {
+	contact, err := a.Repo.GetContact(a.Ctx, contactID)
+	if err != nil || contact == nil {
+		return nil, fmt.Errorf("contact not found")
+	}
+	if contact.PublicKey == "" || contact.I2PAddress == "" {
+		return nil, fmt.Errorf("%s has not completed the handshake yet", contact.Nickname)
+	}
+	if !a.peerSupports(contact, messenger.CapGroups) {
+		return nil, fmt.Errorf("%s's client does not support groups", contact.Nickname)
+	}
+	return &core.GroupMember{
+		PublicKey:  contact.PublicKey,
+		I2PAddress: contact.I2PAddress,
+		Nickname:   contact.Nickname,
+		Role:       core.GroupRoleMember,
+	}, nil
+}
+
+// CreateGroup создаёт группу, где мы администратор, и приглашает в неё контакты.
+func (a *AppCore) CreateGroup(name string, contactIDs []string) (*GroupInfo, error) {
+	if a.Repo == nil {
+		return nil, fmt.Errorf("not logged in")
+	}
+	if a.Messenger == nil {
+		return nil, fmt.Errorf("not connected to I2P")
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > maxGroupNameLength {
+		return nil, fmt.Errorf("group name must be 1-%d characters", maxGroupNameLength)
+	}
+	if len(contactIDs) == 0 {
+		return nil, fmt.Errorf("group needs at least one member")
+	}
+	if len(contactIDs)+1 > core.MaxGroupMembers {
+		return nil, fmt.Errorf("group cannot have more than %d members", core.MaxGroupMembers)
+	}
+
+	nickname := ""
+	if u, err := a.Repo.GetMyProfile(a.Ctx); err == nil && u != nil {
+		nickname = u.Nickname
+	}
+
+	myKey := a.Identity.Keys.PublicKeyBase64
+	g := &core.Group{
+		ID:       uuid.New().String(),
+		Name:     name,
+		IsMember: true,
+		Members: []*core.GroupMember{{
+			PublicKey:  myKey,
+			I2PAddress: a.Messenger.GetDestination(),
+			Nickname:   nickname,
+			Role:       core.GroupRoleAdmin,
+		}},
+	}
+	for _, id := range contactIDs {
+		m, err := a.contactAsMember(id)
+		if err != nil {
+			return nil, err
+		}
+		if g.Member(m.PublicKey) == nil {
+			g.Members = append(g.Members, m)
+		}
+	}
+
+	if err := a.publishGroupState(g, pb.GroupAction_GROUP_INVITE, nil); err != nil {
+		return nil, err
+	}
+	return a.groupInfo(g, 0), nil
+}
+
+// GetGroups возвращает группы с последним сообщением.
+func (a *AppCore) GetGroups() ([]*GroupInfo, error) {
+	if a.Repo == nil {
+		return []*GroupInfo{}, nil
+	}
+
+	groups, err := a.Repo.ListGroups(a.Ctx)
+	if err != nil {
+		return nil, err
+	}
+	unread, _ := a.Repo.GetUnreadCountByChat(a.Ctx)
+
+	result := make([]*GroupInfo, len(groups))
+	for i, g := range groups {
+		result[i] = a.groupInfo(g, unread[g.ID])
+	}
+	return result, nil
+}
+
+// GetGroupInvites возвращает непринятые приглашения в группы.
+func (a *AppCore) GetGroupInvites() ([]*GroupInfo, error) {
+	if a.Repo == nil {
+		return []*GroupInfo{}, nil
+	}
+
+	groups, err := a.Repo.ListGroupInvites(a.Ctx)
+	if err != nil {
+		return nil, err
+	}
+	unread, _ := a.Repo.GetUnreadCountByChat(a.Ctx)
+
+	result := make([]*GroupInfo, len(groups))
+	for i, g := range groups {
+		result[i] = a.groupInfo(g, unread[g.ID])
+	}
+	return result, nil
+}
+
+// pendingGroup возвращает непринятое приглашение в группу
+func (a *AppCore) pendingGroup(groupID string) (*core.Group, error) {
+	if a.Repo == nil {
+		return nil, fmt.Errorf("not logged in")
+	}
+	g := a.getGroup(groupID)
+	if g == nil || !g.IsPending {
+		return nil, fmt.Errorf("group invite not found")
+	}
+	return g, nil
+}
+
+// AcceptGroupInvite принимает приглашение: группа появляется в списке, её участники — не незнакомцы.
+func (a *AppCore) AcceptGroupInvite(groupID string) error {
+	g, err := a.pendingGroup(groupID)
+	if err != nil {
+		return err
+	}
+	if err := a.Repo.SetGroupPending(a.Ctx, g.ID, false); err != nil {
+		return err
+	}
+	a.emitGroupUpdated(g.ID, pb.GroupAction_GROUP_INVITE)
+	go a.UpdateUnreadCount()
+
+	// Пока приглашение лежало, состав мог измениться
+	var admins []*core.GroupMember
+	for _, m := range g.Members {
+		if m.Role == core.GroupRoleAdmin {
+			admins = append(admins, m)
+		}
+	}
+	a.sendGroupControl(admins, pb.GroupAction_GROUP_SYNC, g.ID, nil)
+	return nil
+}
+
+// DeclineGroupInvite отклоняет приглашение: группа и сообщения из карантина удаляются.
+// Участники ничего не узнают.
+func (a *AppCore) DeclineGroupInvite(groupID string) error {
+	g, err := a.pendingGroup(groupID)
+	if err != nil {
+		return err
+	}
+	return a.Repo.DeleteGroup(a.Ctx, g.ID)
+}
+
+// InviteToGroup добавляет контакт в группу (только для администраторов).
+func (a *AppCore) InviteToGroup(groupID, contactID string) error {
+	g, err := a.adminGroup(groupID)
+	if err != nil {
+		return err
+	}
+	m, err := a.contactAsMember(contactID)
+	if err != nil {
+		return err
+	}
+	if g.Member(m.PublicKey) != nil {
+		return fmt.Errorf("%s is already in the group", m.Nickname)
+	}
+	if len(g.Members) >= core.MaxGroupMembers {
+		return fmt.Errorf("group cannot have more than %d members", core.MaxGroupMembers)
+	}
+
+	g.Members = append(g.Members, m)
+	return a.publishGroupState(g, pb.GroupAction_GROUP_INVITE, nil)
+}
+
+// KickFromGroup исключает участника из группы (только для администраторов).
+func (a *AppCore) KickFromGroup(groupID, memberPubKey string) error {
+	g, err := a.adminGroup(groupID)
+	if err != nil {
+		return err
+	}
+	if memberPubKey == a.Identity.Keys.PublicKeyBase64 {
+		return fmt.Errorf("use LeaveGroup to leave the group")
+	}
+	kicked := g.Member(memberPubKey)
+	if kicked == nil {
+		return fmt.Errorf("member not found")
+	}
+
+	removeGroupMember(g, memberPubKey)
+	// Исключённый тоже получает новый состав, чтобы узнать об исключении
+	return a.publishGroupState(g, pb.GroupAction_GROUP_KICK, []*core.GroupMember{kicked})
+}
+
+// LeaveGroup выходит из группы и удаляет её вместе с историей.
+func (a *AppCore) LeaveGroup(groupID string) error {
+	if a.Repo == nil {
+		return fmt.Errorf("not logged in")
+	}
+	g := a.getGroup(groupID)
+	if g == nil {
+		return fmt.Errorf("group not found")
+	}
+
+	myKey := a.Identity.Keys.PublicKeyBase64
+	if g.IsMember && !g.IsPending && g.Member(myKey) != nil && a.Messenger != nil {
+		if g.IsAdmin(myKey) {
+			// Администратор сам подписывает состав без себя
+			removeGroupMember(g, myKey)
+			if len(g.Members) > 0 {
+				g.Epoch++
+				g.StateUpdatedAt = time.Now().UnixMilli()
+				state := groupToState(g)
+				a.Messenger.SignGroupState(state)
+				a.sendGroupControl(g.Members, pb.GroupAction_GROUP_UPDATE, g.ID, state)
+			}
+		} else {
+			// Остальные просят администратора исключить их
+			var admins []*core.GroupMember
+			for _, m := range g.Members {
+				if m.Role == core.GroupRoleAdmin {
+					admins = append(admins, m)
+				}
+			}
+			a.sendGroupControl(admins, pb.GroupAction_GROUP_LEAVE, g.ID, nil)
+		}
+	}
+
+	if err := a.Repo.DeleteGroup(a.Ctx, groupID); err != nil {
+		return err
+	}
+	a.emitGroupUpdated(groupID, pb.GroupAction_GROUP_LEAVE)
+	go a.UpdateUnreadCount()
+	return nil
+}
+
+// publishGroupState подписывает новую версию состава, сохраняет её и рассылает
+// участникам (и extra — тем, кого в составе уже нет)
+func (a *AppCore) publishGroupState(g *core.Group, action pb.GroupAction, extra []*core.GroupMember) error {
+	g.Epoch++
+	g.StateUpdatedAt = time.Now().UnixMilli()
+
+	state := groupToState(g)
+	a.Messenger.SignGroupState(state)
+	g.SignerPubKey, g.Signature = state.SignerPubKey, state.Signature
+
+	if err := a.Repo.SaveGroup(a.Ctx, g); err != nil {
+		return err
+	}
+	a.emitGroupUpdated(g.ID, action)
+
+	a.sendGroupControl(append(extra, g.Members...), action, g.ID, state)
+	return nil
+}
+
+// sendGroupControl отправляет управляющий пакет участникам, кроме себя.
+// Потерянные пакеты не страшны: отставший состав догоняется по эпохе сообщений.
+func (a *AppCore) sendGroupControl(members []*core.GroupMember, action pb.GroupAction, groupID string, state *pb.GroupState) {
+	m := a.Messenger
+	if m == nil {
+		return
+	}
+	myKey := a.Identity.Keys.PublicKeyBase64
+	for _, member := range members {
+		if member.PublicKey == myKey || member.I2PAddress == "" {
+			continue
+		}
+		go func(addr string) {
+			if err := m.SendGroupControl(addr, action, groupID, state); err != nil {
+				log.Printf("[AppCore] Failed to send group %v: %v", action, err)
+			}
+		}(member.I2PAddress)
+	}
+}
+
+// onGroupControl обрабатывает управляющий пакет группы (подпись состава уже проверена)
+func (a *AppCore) onGroupControl(senderPubKey, senderAddr string, action pb.GroupAction, groupID string, state *pb.GroupState) {
+	if a.Repo == nil || a.Identity == nil {
+		return
+	}
+
+	switch action {
+	case pb.GroupAction_GROUP_LEAVE:
+		a.onGroupLeave(senderPubKey, groupID)
+	case pb.GroupAction_GROUP_SYNC:
+		a.onGroupSync(senderPubKey, groupID)
+	default:
+		if state != nil {
+			a.applyGroupState(senderPubKey, action, state)
+		}
+	}
+}
+
+// applyGroupState принимает новый состав группы, если его подписал администратор
+func (a *AppCore) applyGroupState(senderPubKey string, action pb.GroupAction, state *pb.GroupState) {
+	if err := validGroupState(state); err != nil {
+		log.Printf("[AppCore] Rejected group state from %s: %v", senderPubKey[:min(16, len(senderPubKey))], err)
+		return
+	}
+
+	myKey := a.Identity.Keys.PublicKeyBase64
+	g := groupFromState(state)
+	g.IsMember = g.Member(myKey) != nil
+
+	existing := a.getGroup(state.GroupId)
+	if existing == nil && a.chatIDTaken(state.GroupId) {
+		log.Printf("[AppCore] Rejected group state from %s: group id is taken by another chat", senderPubKey[:min(16, len(senderPubKey))])
+		return
+	}
+	if existing == nil {
+		// Незнакомую группу принимаем только из рук подписавшего её администратора
+		if !g.IsMember || state.SignerPubKey != senderPubKey || !g.IsAdmin(senderPubKey) {
+			return
+		}
+		// Приглашение не от принятого контакта ждёт решения пользователя, как запрос переписки
+		inviter := a.lookupContact(senderPubKey, "")
+		if inviter != nil && inviter.IsBlocked {
+			return
+		}
+		g.IsPending = inviter == nil || inviter.IsPending
+	} else {
+		// При одновременных правках двух администраторов побеждает меньший ключ подписавшего
+		if state.Epoch < existing.Epoch ||
+			(state.Epoch == existing.Epoch && state.SignerPubKey >= existing.SignerPubKey) {
+			return
+		}
+		// Менять состав может только администратор из известного нам состава
+		if !existing.IsAdmin(state.SignerPubKey) {
+			log.Printf("[AppCore] Rejected group state for %s: signer is not an admin", state.GroupId)
+			return
+		}
+		g.CreatedAt = existing.CreatedAt
+		g.IsPending = existing.IsPending
+	}
+
+	if err := a.Repo.SaveGroup(a.Ctx, g); err != nil {
+		log.Printf("[AppCore] Failed to save group: %v", err)
+		return
+	}
+	if existing != nil && existing.IsMember && !g.IsMember {
+		log.Printf("[AppCore] We were removed from group %s", g.ID)
+	}
+	if g.IsPending {
+		a.emitGroupInvite(g)
+		return
+	}
+	a.emitGroupUpdated(g.ID, action)
+}
+
+// onGroupLeave исключает вышедшего участника, если эта обязанность на нас
+func (a *AppCore) onGroupLeave(senderPubKey, groupID string) {
+	g := a.getGroup(groupID)
+	if g == nil || !g.IsMember || g.IsPending || g.Member(senderPubKey) == nil || a.Messenger == nil {
+		return
+	}
+	if primaryAdmin(g, senderPubKey) != a.Identity.Keys.PublicKeyBase64 {
+		return
+	}
+
+	removeGroupMember(g, senderPubKey)
+	if err := a.publishGroupState(g, pb.GroupAction_GROUP_UPDATE, nil); err != nil {
+		log.Printf("[AppCore] Failed to remove member who left: %v", err)
+	}
+}
+
+// onGroupSync отправляет отставшему участнику наш состав группы
+func (a *AppCore) onGroupSync(senderPubKey, groupID string) {
+	g := a.getGroup(groupID)
+	if g == nil || !g.IsMember || g.IsPending || len(g.Signature) == 0 {
+		return
+	}
+	member := g.Member(senderPubKey)
+	if member == nil {
+		return
+	}
+	a.sendGroupControl([]*core.GroupMember{member}, pb.GroupAction_GROUP_UPDATE, g.ID, groupToState(g))
+}
+
+// onGroupMessage сохраняет входящее сообщение группы с настоящим отправителем
+func (a *AppCore) onGroupMessage(msg *core.Message, senderPubKey string) {
+	g := a.getGroup(msg.GroupID)
+	if g == nil || !g.IsMember {
+		log.Printf("[AppCore] Dropping message for unknown group %s", msg.GroupID)
+		return
+	}
+
+	// Расхождение эпох: отстающая сторона получает свежий состав (пока приглашение не принято, молчим)
+	switch {
+	case g.IsPending:
+	case msg.GroupEpoch > g.Epoch:
+		if member := g.Member(senderPubKey); member != nil {
+			a.sendGroupControl([]*core.GroupMember{member}, pb.GroupAction_GROUP_SYNC, g.ID, nil)
+		} else if msg.SenderAddr != "" {
+			a.sendGroupControl([]*core.GroupMember{{PublicKey: senderPubKey, I2PAddress: msg.SenderAddr}}, pb.GroupAction_GROUP_SYNC, g.ID, nil)
+		}
+	case msg.GroupEpoch < g.Epoch:
+		a.onGroupSync(senderPubKey, g.ID)
+	}
+
+	member := g.Member(senderPubKey)
+	if member == nil {
+		log.Printf("[AppCore] Dropping group message from non-member %s", senderPubKey[:min(16, len(senderPubKey))])
+		return
+	}
+
+	msg.ChatID = g.ID
+	msg.SenderID = senderPubKey
+	if err := a.Repo.InsertMessage(a.Ctx, msg); err != nil {
+		// Занятый ID — повтор или попытка перезаписать чужое сообщение
+		log.Printf("[AppCore] Failed to save group message: %v", err)
+		return
+	}
+	if g.IsPending {
+		// Карантин: без уведомлений, пока приглашение не принято
+		a.emitGroupInvite(g)
+		return
+	}
+
+	senderName := a.groupMemberName(member)
+	var replyPreview *ReplyPreview
+	if msg.ReplyToID != nil {
+		replyPreview = a.groupReplyPreview(g, *msg.ReplyToID)
+	}
+
+	a.Emitter.Emit("new_message", map[string]interface{}{
+		"ID":           msg.ID,
+		"ChatID":       msg.ChatID,
+		"SenderID":     msg.SenderID,
+		"SenderName":   senderName,
+		"Content":      msg.Content,
+		"Timestamp":    msg.Timestamp,
+		"IsOutgoing":   false,
+		"ContentType":  msg.ContentType,
+		"Status":       msg.Status.String(),
+		"ReplyToID":    msg.ReplyToID,
+		"ReplyPreview": replyPreview,
+	})
+
+	a.afterIncoming(msg, fmt.Sprintf("%s: %s", g.Name, senderName))
+}
+
+// groupMemberName — имя участника: из контактов, если он у нас есть
+func (a *AppCore) groupMemberName(m *core.GroupMember) string {
+	if contact, err := a.Repo.GetContactByPublicKey(a.Ctx, m.PublicKey); err == nil && contact != nil {
+		return contact.Nickname
+	}
+	if m.Nickname != "" {
+		return m.Nickname
+	}
+	return "Unknown " + m.PublicKey[:min(8, len(m.PublicKey))]
+}
+
+// groupReplyPreview формирует превью ответа с именем автора исходного сообщения
+func (a *AppCore) groupReplyPreview(g *core.Group, replyToID string) *ReplyPreview {
+	if replyToID == "" {
+		return nil
+	}
+	var author *core.Contact
+	if orig, _ := a.Repo.GetMessage(a.Ctx, replyToID); orig != nil && !orig.IsOutgoing {
+		if m := g.Member(orig.SenderID); m != nil {
+			author = &core.Contact{Nickname: a.groupMemberName(m)}
+		}
+	}
+	return a.getReplyPreview(replyToID, author)
+}
+
+// sendGroupText сохраняет сообщение группы и ставит его в очередь на рассылку участникам
+func (a *AppCore) sendGroupText(g *core.Group, text, replyToID string) error {
+	if !g.IsMember {
+		return fmt.Errorf("you are no longer a member of this group")
+	}
+	if g.IsPending {
+		return fmt.Errorf("accept the group invite first")
+	}
+
+	myKey := a.Identity.Keys.PublicKeyBase64
+	var recipients []string
+	for _, m := range g.Members {
+		if m.PublicKey != myKey {
+			recipients = append(recipients, m.PublicKey)
+		}
+	}
+
+	status := core.MessageStatusPending
+	if len(recipients) == 0 {
+		status = core.MessageStatusSent
+	}
+
+	now := time.Now()
+	msg := &core.Message{
+		ID:          uuid.New().String(),
+		ChatID:      g.ID,
+		SenderID:    a.Identity.Keys.UserID,
+		Content:     text,
+		ContentType: "text",
+		Status:      status,
+		IsOutgoing:  true,
+		Timestamp:   now.UnixMilli(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if replyToID != "" {
+		msg.ReplyToID = &replyToID
+	}
+
+	if err := a.Repo.SaveMessage(a.Ctx, msg); err != nil {
+		return fmt.Errorf("failed to save message: %w", err)
+	}
+
+	a.Emitter.Emit("new_message", map[string]interface{}{
+		"ID":           msg.ID,
+		"ChatID":       msg.ChatID,
+		"SenderID":     msg.SenderID,
+		"Content":      msg.Content,
+		"Timestamp":    msg.Timestamp,
+		"IsOutgoing":   msg.IsOutgoing,
+		"Status":       msg.Status.String(),
+		"ReplyToID":    replyToID,
+		"ReplyPreview": a.groupReplyPreview(g, replyToID),
+	})
+
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	// Список получателей фиксируется при отправке: новые участники старых сообщений не получают
+	err := a.Repo.AddGroupDeliveries(a.Ctx, msg.ID, recipients)
+	if err == nil {
+		err = a.enqueueOutgoing(msg)
+	}
+	if err != nil {
+		_ = a.Repo.UpdateMessageStatus(a.Ctx, msg.ID, core.MessageStatusFailed)
+		a.emitMessageStatus(msg.ChatID, msg.ID, core.MessageStatusFailed)
+		return fmt.Errorf("send failed: %w", err)
+	}
+	return nil
+}
+
+// deliverGroup рассылает сообщения группы каждому участнику отдельно.
+// Ошибка доставки одному участнику не задерживает остальных: пауза ведётся по участнику,
+// а сообщение считается отправленным, когда доставлено всем.
+func (o *outbox) deliverGroup(ctx context.Context, repo *sqlite.Repository, m *messenger.Service, g *core.Group, entries []*core.OutboxEntry) {
+	if !g.IsMember {
+		log.Printf("[AppCore] Not a member of group %s, dropping %d queued messages", g.ID, len(entries))
+		for _, e := range entries {
+			o.app.failOutgoing(repo, e)
+		}
+		return
+	}
+
+	now := time.Now()
+	skip := make(map[string]bool) // участники, которым в этом проходе больше не отправляем
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+
+		msg, err := repo.GetMessage(o.app.Ctx, e.MessageID)
+		if err != nil || msg == nil {
+			_ = repo.DeleteOutbox(o.app.Ctx, e.MessageID)
+			continue
+		}
+		replyToID := ""
+		if msg.ReplyToID != nil {
+			replyToID = *msg.ReplyToID
+		}
+
+		pending, err := repo.ListGroupDeliveries(o.app.Ctx, e.MessageID)
+		if err != nil {
+			log.Printf("[AppCore] %v", err)
+			return
+		}
+
+		var lastErr error
+		left := 0
+		for _, pubKey := range pending {
+			member := g.Member(pubKey)
+			if member == nil {
+				// Участника исключили — доставлять больше некому
+				_ = repo.DeleteGroupDelivery(o.app.Ctx, e.MessageID, pubKey)
+				continue
+			}
+
+			key := groupMemberKey(g.ID, pubKey)
+			if skip[pubKey] || o.waiting(key, now) {
+				// Порядок сообщений для участника сохраняется: после ошибки ему не шлём следующие
+				skip[pubKey] = true
+				left++
+				continue
+			}
+
+			if err := m.SendGroupTextMessage(member.I2PAddress, g.ID, g.Epoch, msg.ID, msg.Content, replyToID, msg.Timestamp); err != nil {
+				skip[pubKey] = true
+				left++
+				lastErr = err
+				delay := o.fail(key)
+				log.Printf("[AppCore] Group delivery to %s failed, retry in %v: %v", member.Nickname, delay, err)
+				continue
+			}
+
+			o.resetBackoff(key)
+			if err := repo.DeleteGroupDelivery(o.app.Ctx, e.MessageID, pubKey); err != nil {
+				log.Printf("[AppCore] %v", err)
+			}
+		}
+
+		if left > 0 {
+			if lastErr != nil {
+				if errRec := repo.RecordOutboxAttempt(o.app.Ctx, e.MessageID, lastErr.Error()); errRec != nil {
+					log.Printf("[AppCore] %v", errRec)
+				}
+			}
+			continue
+		}
+
+		if err := repo.DeleteOutbox(o.app.Ctx, e.MessageID); err != nil {
+			log.Printf("[AppCore] %v", err)
+		}
+		o.app.markOutgoingSent(repo, msg)
+	}
+}
+
+// groupInfo собирает информацию о группе для фронтенда
+func (a *AppCore) groupInfo(g *core.Group, unread int) *GroupInfo {
+	myKey := a.Identity.Keys.PublicKeyBase64
+	info := &GroupInfo{
+		ID:          g.ID,
+		Name:        g.Name,
+		Members:     make([]*GroupMemberInfo, 0, len(g.Members)),
+		IsAdmin:     g.IsMember && g.IsAdmin(myKey),
+		IsMember:    g.IsMember,
+		LastMessage: g.LastMessage,
+		UnreadCount: unread,
+	}
+	if !g.LastMessageTime.IsZero() {
+		tm := g.LastMessageTime
+		info.LastMessageTime = &tm
+	}
+	for _, m := range g.Members {
+		isMe := m.PublicKey == myKey
+		name := "Я"
+		if !isMe {
+			name = a.groupMemberName(m)
+		}
+		info.Members = append(info.Members, &GroupMemberInfo{
+			PublicKey: m.PublicKey,
+			Nickname:  name,
+			IsAdmin:   m.Role == core.GroupRoleAdmin,
+			IsMe:      isMe,
+		})
+	}
+	return info
+}
+
+// emitGroupInvite сообщает фронтенду о приглашении в группу или сообщении в его карантине
+func (a *AppCore) emitGroupInvite(g *core.Group) {
+	a.Emitter.Emit("group_invite", map[string]interface{}{
+		"GroupID":      g.ID,
+		"Name":         g.Name,
+		"SignerPubKey": g.SignerPubKey,
+	})
+}
+
+// emitGroupUpdated сообщает фронтенду об изменении группы
+func (a *AppCore) emitGroupUpdated(groupID string, action pb.GroupAction) {
+	a.Emitter.Emit("group_updated", map[string]interface{}{
+		"GroupID": groupID,
+		"Action":  strings.TrimPrefix(action.String(), "GROUP_"),
+	})
+}
@@ -0,0 +1,82 @@
+package appcore
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Адрес, которым делятся, — "<статический ключ>@<I2P адрес>". По ключу незнакомец запечатывает
+// первое сообщение: до принятия запроса мы не отвечаем ему даже handshake.
+// Адрес без ключа (старый формат) тоже принимается — тогда первое сообщение ждёт handshake.
+
+// introKeySep отделяет статический ключ от I2P адреса (в base64 I2P символа '@' нет)
+const introKeySep = "@"
+
+// introKeySize — размер X25519 ключа
+const introKeySize = 32
+
+// contactAddress собирает адрес для обмена из I2P адреса и статического ключа
+func contactAddress(destination string, introKey []byte) string {
+	if destination == "" || len(introKey) == 0 {
+		return destination
+	}
+	return base64.RawURLEncoding.EncodeToString(introKey) + introKeySep + destination
+}
+
+// parseContactAddress разбирает адрес контакта; ключа может не быть
+func parseContactAddress(address string) (destination string, introKey []byte, err error) {
+	address = strings.TrimSpace(address)
+	key, destination, ok := strings.Cut(address, introKeySep)
+	if !ok {
+		return address, nil, nil
+	}
+	introKey, err = base64.RawURLEncoding.DecodeString(key)
+	if err != nil || len(introKey) != introKeySize {
+		return "", nil, fmt.Errorf("некорректный ключ в адресе")
+	}
+	return destination, introKey, nil
+}
+
+// myContactAddress возвращает наш адрес для обмена
+func (a *AppCore) myContactAddress(destination string) string {
+	if a.Identity == nil {
+		return destination
+	}
+	key, err := a.Identity.Keys.IntroKey()
+	if err != nil {
+		log.Printf("[AppCore] Failed to derive intro key: %v", err)
+		return destination
+	}
+	return contactAddress(destination, key.PublicKey().Bytes())
+}
+
+// updateIntroKey передаёт мессенджеру наш статический ключ
+func (a *AppCore) updateIntroKey() {
+	if a.Messenger == nil || a.Identity == nil {
+		return
+	}
+	key, err := a.Identity.Keys.IntroKey()
+	if err != nil {
+		log.Printf("[AppCore] Failed to derive intro key: %v", err)
+		return
+	}
+	a.Messenger.SetIntroKey(key)
+}
+
+// resolveIntroKey возвращает статический ключ контакта по его I2P адресу
+func (a *AppCore) resolveIntroKey(destination string) []byte {
+	if a.Repo == nil {
+		return nil
+	}
+	contact, err := a.Repo.GetContactByAddress(a.Ctx, destination)
+	if err != nil || contact == nil || contact.IntroKey == "" {
+		return nil
+	}
+	key, err := base64.StdEncoding.DecodeString(contact.IntroKey)
+	if err != nil {
+		return nil
+	}
+	return key
+}
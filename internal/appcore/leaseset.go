@@ -0,0 +1,127 @@
+package appcore
+
+import (
+	"encoding/base64"
+	"log"
+
+	"teleghost/internal/core"
+	"teleghost/internal/network/messenger"
+)
+
+// onLeaseSet сохраняет ключ и b33 адрес, присланные собеседником в handshake или профиле
+func (a *AppCore) onLeaseSet(senderPubKey, senderAddr string, ls messenger.PeerLeaseSet) {
+	if a.Repo == nil {
+		return
+	}
+	contact, err := a.Repo.GetContactByPublicKey(a.Ctx, senderPubKey)
+	if err != nil {
+		log.Printf("[AppCore] Failed to load contact for leaseSet: %v", err)
+		return
+	}
+	if contact == nil {
+		// Контакта ещё нет (сохраним в storePeerLeaseSet); ответ пойдёт по адресу из пакета,
+		// если под ним не записан другой контакт
+		if other, _ := a.Repo.GetContactByAddress(a.Ctx, senderAddr); other == nil {
+			a.setPeerBlindedAddress(senderAddr, ls.BlindedAddress)
+		}
+		return
+	}
+	a.saveLeaseSet(contact, ls)
+}
+
+// storePeerLeaseSet сохраняет leaseSet для только что созданного контакта
+// (handshake приходит раньше, чем контакт попадает в БД)
+func (a *AppCore) storePeerLeaseSet(pubKey string) {
+	if a.Repo == nil || a.Messenger == nil {
+		return
+	}
+	ls, ok := a.Messenger.GetPeerLeaseSet(pubKey)
+	if !ok {
+		return
+	}
+	if contact, err := a.Repo.GetContactByPublicKey(a.Ctx, pubKey); err == nil && contact != nil {
+		a.saveLeaseSet(contact, ls)
+	}
+}
+
+// saveLeaseSet записывает leaseSet контакта; новый ключ сразу попадает в список клиентов
+func (a *AppCore) saveLeaseSet(contact *core.Contact, ls messenger.PeerLeaseSet) {
+	if a.setPeerBlindedAddress(contact.I2PAddress, ls.BlindedAddress) && ls.BlindedAddress != contact.BlindedAddress {
+		if err := a.Repo.SetContactBlindedAddress(a.Ctx, contact.PublicKey, ls.BlindedAddress); err != nil {
+			log.Printf("[AppCore] Failed to save blinded address: %v", err)
+		}
+	}
+
+	if len(ls.AuthKey) == 0 {
+		return
+	}
+	key := base64.StdEncoding.EncodeToString(ls.AuthKey)
+	if key == contact.LeaseSetAuthKey {
+		return
+	}
+	if err := a.Repo.SetContactLeaseSetKey(a.Ctx, contact.PublicKey, key); err != nil {
+		log.Printf("[AppCore] Failed to save leaseSet key: %v", err)
+		return
+	}
+	a.syncLeaseSet()
+}
+
+// setPeerBlindedAddress передаёт роутеру b33 адрес собеседника; false — адрес не подходит
+func (a *AppCore) setPeerBlindedAddress(destination, blinded string) bool {
+	r := a.Router
+	if r == nil || destination == "" {
+		return true
+	}
+	if err := r.SetPeerBlindedAddress(destination, blinded); err != nil {
+		log.Printf("[AppCore] Ignoring blinded address of %s: %v", destination[:min(16, len(destination))], err)
+		return false
+	}
+	return true
+}
+
+// syncLeaseSet передаёт роутеру b33 адреса контактов и ключи тех, кому открыт наш leaseSet:
+// незнакомцам из запросов и заблокированным он закрыт
+func (a *AppCore) syncLeaseSet() {
+	if a.Repo == nil || a.Router == nil {
+		return
+	}
+	contacts, err := a.Repo.ListContacts(a.Ctx)
+	if err != nil {
+		log.Printf("[AppCore] Failed to load leaseSet clients: %v", err)
+		return
+	}
+	var keys [][]byte
+	for _, c := range contacts {
+		if c.BlindedAddress != "" {
+			a.setPeerBlindedAddress(c.I2PAddress, c.BlindedAddress)
+		}
+		if c.IsBlocked || c.IsPending || c.LeaseSetAuthKey == "" {
+			continue
+		}
+		if key, err := base64.StdEncoding.DecodeString(c.LeaseSetAuthKey); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	a.Router.SetLeaseSetClients(keys)
+}
+
+// updateLeaseSetInfo передаёт мессенджеру наш ключ и b33 адрес для handshake и профиля.
+// true — b33 адрес изменился, контактам надо о нём сообщить.
+func (a *AppCore) updateLeaseSetInfo() bool {
+	m := a.Messenger
+	if m == nil || a.Identity == nil {
+		return false
+	}
+	key, err := a.Identity.Keys.LeaseSetAuthKey()
+	if err != nil {
+		log.Printf("[AppCore] Failed to derive leaseSet key: %v", err)
+		return false
+	}
+	var blinded string
+	if r := a.Router; r != nil {
+		blinded = r.GetBlindedAddress()
+	}
+	changed := blinded != m.BlindedAddress()
+	m.SetLeaseSetInfo(key.PublicKey().Bytes(), blinded)
+	return changed
+}
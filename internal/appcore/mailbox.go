@@ -0,0 +1,245 @@
+package appcore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"teleghost/internal/network/mailbox"
+)
+
+const (
+	// mailboxSettingsKey — ключ настроек почтового ящика в db_metadata
+	mailboxSettingsKey = "mailbox_settings"
+
+	// MailboxPollInterval — как часто забираем письма из своего ящика
+	MailboxPollInterval = 5 * time.Minute
+)
+
+// mailboxPoller — периодическая проверка своего почтового ящика GhostMail
+type mailboxPoller struct {
+	mu       sync.Mutex
+	wake     chan struct{}
+	cancel   context.CancelFunc
+	done     chan struct{}
+	contacts string // контакты, переданные серверу при последней регистрации
+}
+
+func newMailboxPoller() *mailboxPoller {
+	return &mailboxPoller{wake: make(chan struct{}, 1)}
+}
+
+// poke просит проверить ящик, не дожидаясь таймера
+func (p *mailboxPoller) poke() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// registered запоминает контакты, с которыми ящик зарегистрирован на сервере
+func (p *mailboxPoller) registered(contacts []string) {
+	p.mu.Lock()
+	p.contacts = strings.Join(contacts, "\n")
+	p.mu.Unlock()
+}
+
+// contactsChanged — список контактов разошёлся с переданным серверу
+func (p *mailboxPoller) contactsChanged(contacts []string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.contacts != strings.Join(contacts, "\n")
+}
+
+// GetMailboxSettings возвращает почтовый сервер текущего профиля.
+func (a *AppCore) GetMailboxSettings() *MailboxSettings {
+	settings := &MailboxSettings{}
+	if a.Repo == nil {
+		return settings
+	}
+
+	data, err := a.Repo.GetMetadata(a.Ctx, mailboxSettingsKey)
+	if err != nil || data == "" {
+		return settings
+	}
+	if err := json.Unmarshal([]byte(data), settings); err != nil {
+		log.Printf("[AppCore] Failed to parse mailbox settings: %v", err)
+		return &MailboxSettings{}
+	}
+	return settings
+}
+
+// SetMailboxServer регистрирует ящик на почтовом сервере и сообщает его адрес контактам.
+// Пустой адрес отключает ящик.
+func (a *AppCore) SetMailboxServer(address string) (*MailboxSettings, error) {
+	if a.Repo == nil {
+		return nil, fmt.Errorf("not logged in")
+	}
+	if a.Messenger == nil {
+		return nil, fmt.Errorf("messenger not initialized")
+	}
+
+	address = strings.TrimSpace(address)
+	settings := &MailboxSettings{Server: address}
+	if address != "" {
+		contacts := a.mailboxContacts()
+		quota, err := a.Messenger.RegisterMailbox(address, contacts)
+		if err != nil {
+			return nil, fmt.Errorf("mailbox registration failed: %w", err)
+		}
+		a.mailbox.registered(contacts)
+		settings.MaxMessages = quota.MaxMessages
+		settings.MaxBytes = quota.MaxBytes
+		settings.TTLHours = int(quota.TTL / time.Hour)
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Repo.SetMetadata(a.Ctx, mailboxSettingsKey, string(data)); err != nil {
+		return nil, err
+	}
+
+	a.Messenger.SetMailboxAddress(address)
+	log.Printf("[AppCore] Mailbox server set to %s", address[:min(16, len(address))])
+
+	go a.announceProfile()
+	a.mailbox.poke()
+	return settings, nil
+}
+
+// announceProfile рассылает контактам профиль с новым адресом ящика или b33 адресом
+func (a *AppCore) announceProfile() {
+	if a.Repo == nil {
+		return
+	}
+	contacts, err := a.Repo.ListContacts(a.Ctx)
+	if err != nil {
+		return
+	}
+	for _, contact := range contacts {
+		if contact.PublicKey == "" || contact.IsBlocked || contact.IsPending {
+			continue
+		}
+		a.sendMyProfile(contact.I2PAddress)
+	}
+}
+
+// mailboxContacts — ключи контактов, чьи письма почтовый сервер примет без штампа.
+// Незнакомцы из запросов и заблокированные платят штампом, как и все остальные.
+func (a *AppCore) mailboxContacts() []string {
+	if a.Repo == nil {
+		return nil
+	}
+	contacts, err := a.Repo.ListContacts(a.Ctx)
+	if err != nil {
+		log.Printf("[AppCore] Failed to load mailbox contacts: %v", err)
+		return nil
+	}
+	var keys []string
+	for _, c := range contacts {
+		if c.PublicKey != "" && !c.IsBlocked && !c.IsPending {
+			keys = append(keys, c.PublicKey)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// resolveMailbox возвращает ключ контакта и его почтовый сервер по I2P адресу
+func (a *AppCore) resolveMailbox(destination string) (string, string) {
+	if a.Repo == nil {
+		return "", ""
+	}
+	contact, err := a.Repo.GetContactByAddress(a.Ctx, destination)
+	if err != nil || contact == nil {
+		return "", ""
+	}
+	return contact.PublicKey, contact.MailboxAddress
+}
+
+// startMailbox запускает периодическую проверку своего ящика
+func (a *AppCore) startMailbox() {
+	a.stopMailbox()
+
+	ctx, cancel := context.WithCancel(a.Ctx)
+	p := a.mailbox
+	p.mu.Lock()
+	p.cancel = cancel
+	p.done = make(chan struct{})
+	done := p.done
+	p.mu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(MailboxPollInterval)
+		defer ticker.Stop()
+
+		for {
+			a.fetchMailbox()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-p.wake:
+			}
+		}
+	}()
+}
+
+// stopMailbox останавливает проверку ящика
+func (a *AppCore) stopMailbox() {
+	p := a.mailbox
+	p.mu.Lock()
+	cancel, done := p.cancel, p.done
+	p.cancel, p.done = nil, nil
+	p.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// fetchMailbox забирает письма из своего ящика. Если контакты изменились, сначала передаёт их серверу;
+// если сервер потерял ящик (например, после переустановки), регистрируемся заново.
+func (a *AppCore) fetchMailbox() {
+	m := a.Messenger
+	if m == nil || m.MailboxAddress() == "" {
+		return
+	}
+
+	contacts := a.mailboxContacts()
+	register := func() error {
+		_, err := m.RegisterMailbox(m.MailboxAddress(), contacts)
+		if err == nil {
+			a.mailbox.registered(contacts)
+		}
+		return err
+	}
+	if a.mailbox.contactsChanged(contacts) {
+		if err := register(); err != nil {
+			log.Printf("[AppCore] Failed to update mailbox contacts: %v", err)
+		}
+	}
+
+	_, err := m.FetchMailbox()
+	if errors.Is(err, mailbox.ErrUnknownMailbox) {
+		log.Printf("[AppCore] Mailbox not found on server, registering again")
+		if err = register(); err == nil {
+			_, err = m.FetchMailbox()
+		}
+	}
+	if err != nil {
+		log.Printf("[AppCore] Mailbox fetch failed: %v", err)
+	}
+}
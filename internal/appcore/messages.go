@@ -11,6 +11,7 @@ import (
 
 	"teleghost/internal/core"
 	"teleghost/internal/core/identity"
+	"teleghost/internal/network/messenger"
 	pb "teleghost/internal/proto"
 	"teleghost/internal/utils"
 
@@ -27,6 +28,15 @@ func (a *AppCore) SendText(contactID, text, replyToID string) error {
 		return fmt.Errorf("not logged in")
 	}
 
+	// Сообщения в группу рассылаются каждому участнику
+	if g := a.getGroup(contactID); g != nil {
+		return a.sendGroupText(g, text, replyToID)
+	}
+	// Пост канала рассылается подписчикам
+	if c := a.getChannel(contactID); c != nil {
+		return a.publishChannelPost(c, text)
+	}
+
 	var contact *core.Contact
 	if contactID == a.Identity.Keys.UserID {
 		contact = &core.Contact{
@@ -41,69 +51,26 @@ func (a *AppCore) SendText(contactID, text, replyToID string) error {
 		if err != nil || contact == nil {
 			return fmt.Errorf("contact not found")
 		}
+		if contact.IsBlocked {
+			return fmt.Errorf("contact is blocked")
+		}
 		// ChatID calculation moved to after handshake check
 	}
 
+	// Без ключа собеседника handshake выполнит outbox при отправке
+	a.ensureChatID(contact)
 	log.Printf("[AppCore] Sending message to %s (ChatID: %s)", contact.Nickname, contact.ChatID)
 
-	// Handshake если нет публичного ключа
-	if contact.PublicKey == "" {
-		log.Printf("[AppCore] No public key for %s, sending handshake first...", contact.Nickname)
-		if err := a.Messenger.SendHandshake(contact.I2PAddress); err != nil {
-			log.Printf("[AppCore] Handshake failed: %v", err)
-		}
-
-		// Wait for handshake completion (up to 60 seconds)
-		log.Printf("[AppCore] Waiting for handshake with %s...", contact.Nickname)
-		for i := 0; i < 120; i++ {
-			time.Sleep(500 * time.Millisecond)
-			updatedContact, err := a.Repo.GetContact(a.Ctx, contactID)
-			if err == nil && updatedContact != nil && updatedContact.PublicKey != "" {
-				contact = updatedContact
-				log.Printf("[AppCore] Handshake complete, PublicKey received.")
-				break
-			}
-		}
-
-		if contact.PublicKey == "" {
-			return fmt.Errorf("handshake timeout: recipient did not respond with public key")
-		}
-
-		// Re-calculate ChatID if it was empty (now we have PK)
-		if contact.ChatID == "" {
-			contact.ChatID = identity.CalculateChatID(a.Identity.Keys.PublicKeyBase64, contact.PublicKey)
-			if err := a.Repo.SaveContact(a.Ctx, contact); err != nil {
-				log.Printf("[AppCore] Failed to save contact after handshake: %v", err)
-			}
-			// Critical: Emit event so frontend knows the new ChatID
-			a.Emitter.Emit("contact_updated", contact)
-		}
-	} else if contact.ChatID == "" {
-		// If PK existed but ChatID was empty (shouldn't happen often but possible)
-		contact.ChatID = identity.CalculateChatID(a.Identity.Keys.PublicKeyBase64, contact.PublicKey)
-		if err := a.Repo.SaveContact(a.Ctx, contact); err != nil {
-			log.Printf("[AppCore] Failed to save contact: %v", err)
-		}
-		a.Emitter.Emit("contact_updated", contact)
-	}
-
 	msgID := uuid.New().String()
 	now := time.Now().UnixMilli()
 
-	if contactID != a.Identity.Keys.UserID {
-		if err := a.Messenger.SendTextMessageWithID(contact.I2PAddress, contact.ChatID, msgID, text, replyToID); err != nil {
-			log.Printf("[AppCore] SendTextMessage error to %s: %v", contact.Nickname, err)
-			return fmt.Errorf("send failed: %w", err)
-		}
-	}
-
 	msg := &core.Message{
 		ID:          msgID,
 		ChatID:      contact.ChatID,
 		SenderID:    a.Identity.Keys.UserID,
 		Content:     text,
 		ContentType: "text",
-		Status:      core.MessageStatusSent,
+		Status:      outgoingStatus(isSelf),
 		IsOutgoing:  true,
 		Timestamp:   now,
 		CreatedAt:   time.Now(),
@@ -116,6 +83,7 @@ func (a *AppCore) SendText(contactID, text, replyToID string) error {
 
 	if err := a.Repo.SaveMessage(a.Ctx, msg); err != nil {
 		log.Printf("[AppCore] Failed to save message: %v", err)
+		return fmt.Errorf("failed to save message: %w", err)
 	}
 
 	a.Emitter.Emit("new_message", map[string]interface{}{
@@ -125,12 +93,33 @@ func (a *AppCore) SendText(contactID, text, replyToID string) error {
 		"Content":      msg.Content,
 		"Timestamp":    msg.Timestamp,
 		"IsOutgoing":   msg.IsOutgoing,
-		"Status":       "sent",
+		"Status":       msg.Status.String(),
 		"ReplyToID":    replyToID,
 		"ReplyPreview": a.getReplyPreview(replyToID, contact),
 	})
 
-	return nil
+	if isSelf {
+		return nil
+	}
+	return a.queueOutgoing(msg)
+}
+
+// ensureChatID задаёт контакту ChatID. Пока ключа нет, чат временно живёт под ID контакта:
+// сообщения переедут в настоящий чат, когда handshake принесёт ключ.
+func (a *AppCore) ensureChatID(contact *core.Contact) {
+	if contact.ChatID != "" {
+		return
+	}
+	if contact.PublicKey != "" {
+		contact.ChatID = identity.CalculateChatID(a.Identity.Keys.PublicKeyBase64, contact.PublicKey)
+	} else {
+		contact.ChatID = contact.ID
+	}
+	contact.UpdatedAt = time.Now()
+	if err := a.Repo.UpdateContactAndMigrateChatID(a.Ctx, contact, "", contact.ChatID); err != nil {
+		log.Printf("[AppCore] Failed to save contact: %v", err)
+	}
+	a.Emitter.Emit("contact_updated", contact)
 }
 
 // GetMessages возвращает историю сообщений.
@@ -140,8 +129,13 @@ func (a *AppCore) GetMessages(contactID string, limit, offset int) ([]*MessageIn
 	}
 
 	var chatID string
+	var group *core.Group
 	if contactID == a.Identity.Keys.UserID {
 		chatID = a.Identity.Keys.UserID
+	} else if group = a.getGroup(contactID); group != nil {
+		chatID = group.ID
+	} else if c := a.getChannel(contactID); c != nil {
+		chatID = c.ID
 	} else {
 		contact, err := a.Repo.GetContact(a.Ctx, contactID)
 		if err != nil || contact == nil {
@@ -158,6 +152,11 @@ func (a *AppCore) GetMessages(contactID string, limit, offset int) ([]*MessageIn
 	// Получаем контакты для имен авторов
 	contacts, _ := a.Repo.ListContacts(a.Ctx)
 	cidToName := make(map[string]string)
+	if group != nil {
+		for _, m := range group.Members {
+			cidToName[m.PublicKey] = m.Nickname
+		}
+	}
 	for _, c := range contacts {
 		cidToName[c.ID] = c.Nickname
 		cidToName[c.PublicKey] = c.Nickname
@@ -181,6 +180,11 @@ func (a *AppCore) GetMessages(contactID string, limit, offset int) ([]*MessageIn
 			ContentType: m.ContentType,
 			FileCount:   m.FileCount,
 			TotalSize:   m.TotalSize,
+			EditedAt:    m.EditedAt,
+		}
+		if group != nil && !m.IsOutgoing {
+			info.SenderID = m.SenderID
+			info.SenderName = cidToName[m.SenderID]
 		}
 
 		if m.ReplyToID != nil && *m.ReplyToID != "" {
@@ -231,15 +235,55 @@ func (a *AppCore) GetMessages(contactID string, limit, offset int) ([]*MessageIn
 	return result, nil
 }
 
-// EditMessage редактирует сообщение.
+// EditMessage редактирует своё сообщение и отправляет правку собеседнику.
 func (a *AppCore) EditMessage(messageID, newContent string) error {
 	if a.Repo == nil {
 		return fmt.Errorf("not logged in")
 	}
-	return a.Repo.UpdateMessageContent(a.Ctx, messageID, newContent)
+
+	msg, err := a.Repo.GetMessage(a.Ctx, messageID)
+	if err != nil || msg == nil {
+		return fmt.Errorf("message not found")
+	}
+	if !msg.IsOutgoing {
+		return fmt.Errorf("cannot edit someone else's message")
+	}
+	if len(newContent) > messenger.MaxTextLength {
+		return fmt.Errorf("message is too long")
+	}
+
+	if a.getGroup(msg.ChatID) != nil {
+		return fmt.Errorf("editing is not supported in groups")
+	}
+	if a.getChannel(msg.ChatID) != nil {
+		return fmt.Errorf("editing is not supported in channels")
+	}
+	contact := a.findContactByChatID(msg.ChatID)
+	if !a.peerSupports(contact, messenger.CapMessageEdits) {
+		return fmt.Errorf("contact's client does not support editing")
+	}
+
+	editedAt := time.Now().UnixMilli()
+	if err := a.Repo.UpdateMessageContent(a.Ctx, messageID, newContent, editedAt); err != nil {
+		return err
+	}
+
+	a.emitMessageEdited(msg.ChatID, messageID, newContent, editedAt)
+
+	if contact == nil || contact.I2PAddress == "" {
+		return nil
+	}
+	// Правку доставляет outbox: собеседник может быть не в сети
+	return a.enqueueChange(&core.MessageChange{
+		MessageID: messageID,
+		ChatID:    msg.ChatID,
+		Kind:      core.MessageChangeEdit,
+		Content:   newContent,
+		EditedAt:  editedAt,
+	})
 }
 
-// DeleteMessage удаляет сообщение.
+// DeleteMessage удаляет сообщение только у себя.
 func (a *AppCore) DeleteMessage(messageID string) error {
 	if a.Repo == nil {
 		return fmt.Errorf("not logged in")
@@ -247,12 +291,133 @@ func (a *AppCore) DeleteMessage(messageID string) error {
 	return a.Repo.DeleteMessage(a.Ctx, messageID)
 }
 
+// DeleteMessageForAll удаляет своё сообщение у себя и у собеседника.
+func (a *AppCore) DeleteMessageForAll(messageID string) error {
+	if a.Repo == nil {
+		return fmt.Errorf("not logged in")
+	}
+
+	msg, err := a.Repo.GetMessage(a.Ctx, messageID)
+	if err != nil || msg == nil {
+		return fmt.Errorf("message not found")
+	}
+	if !msg.IsOutgoing {
+		return fmt.Errorf("cannot delete someone else's message for all")
+	}
+
+	if a.getGroup(msg.ChatID) != nil {
+		return fmt.Errorf("deleting for everyone is not supported in groups")
+	}
+	if a.getChannel(msg.ChatID) != nil {
+		return fmt.Errorf("deleting for everyone is not supported in channels")
+	}
+	contact := a.findContactByChatID(msg.ChatID)
+	if !a.peerSupports(contact, messenger.CapMessageEdits) {
+		return fmt.Errorf("contact's client does not support deleting for everyone")
+	}
+
+	if err := a.Repo.DeleteMessage(a.Ctx, messageID); err != nil {
+		return err
+	}
+
+	a.emitMessageDeleted(msg.ChatID, messageID)
+
+	if contact == nil || contact.I2PAddress == "" {
+		return nil
+	}
+	return a.enqueueChange(&core.MessageChange{
+		MessageID: messageID,
+		ChatID:    msg.ChatID,
+		Kind:      core.MessageChangeDelete,
+	})
+}
+
+// onMessageEdit применяет правку, присланную собеседником
+func (a *AppCore) onMessageEdit(senderPubKey, _, messageID, newContent string, editedAt int64) {
+	if len(newContent) > messenger.MaxTextLength {
+		return
+	}
+	msg := a.peerMessage(senderPubKey, messageID)
+	if msg == nil {
+		return
+	}
+
+	// Правки могут прийти не по порядку — старую не применяем
+	if editedAt <= msg.EditedAt {
+		return
+	}
+
+	if err := a.Repo.UpdateMessageContent(a.Ctx, messageID, newContent, editedAt); err != nil {
+		log.Printf("[AppCore] Failed to apply message edit: %v", err)
+		return
+	}
+
+	a.emitMessageEdited(msg.ChatID, messageID, newContent, editedAt)
+}
+
+// onMessageDelete удаляет сообщение по запросу собеседника
+func (a *AppCore) onMessageDelete(senderPubKey, _, messageID string, deleteForAll bool) {
+	// Удаление только у себя нас не касается
+	if !deleteForAll {
+		return
+	}
+
+	msg := a.peerMessage(senderPubKey, messageID)
+	if msg == nil {
+		return
+	}
+
+	if err := a.Repo.DeleteMessage(a.Ctx, messageID); err != nil {
+		log.Printf("[AppCore] Failed to delete message: %v", err)
+		return
+	}
+
+	a.emitMessageDeleted(msg.ChatID, messageID)
+}
+
+// peerMessage возвращает входящее сообщение, если его автор — senderPubKey.
+// Так контакт не может изменить наши или чужие сообщения.
+func (a *AppCore) peerMessage(senderPubKey, messageID string) *core.Message {
+	if a.Repo == nil {
+		return nil
+	}
+
+	msg, err := a.Repo.GetMessage(a.Ctx, messageID)
+	if err != nil || msg == nil {
+		return nil
+	}
+
+	expectedChatID := identity.CalculateChatID(a.Identity.Keys.PublicKeyBase64, senderPubKey)
+	if msg.IsOutgoing || msg.SenderID != senderPubKey || msg.ChatID != expectedChatID {
+		log.Printf("[AppCore] Ignoring change of foreign message %s from %s...", messageID, senderPubKey[:min(16, len(senderPubKey))])
+		return nil
+	}
+
+	return msg
+}
+
+func (a *AppCore) emitMessageEdited(chatID, messageID, content string, editedAt int64) {
+	a.Emitter.Emit("message_edited", map[string]interface{}{
+		"ID":       messageID,
+		"ChatID":   chatID,
+		"Content":  content,
+		"EditedAt": editedAt,
+	})
+}
+
+func (a *AppCore) emitMessageDeleted(chatID, messageID string) {
+	a.Emitter.Emit("message_deleted", map[string]interface{}{
+		"ID":     messageID,
+		"ChatID": chatID,
+	})
+}
+
 // MarkChatAsRead помечает все сообщения в чате как прочитанные.
 func (a *AppCore) MarkChatAsRead(chatID string) error {
 	if a.Repo == nil {
 		return fmt.Errorf("not logged in")
 	}
-	return a.Repo.MarkChatAsRead(a.Ctx, chatID)
+	return a.markChatRead(chatID)
 }
 
 // GetUnreadCount возвращает количество непрочитанных сообщений.
@@ -269,7 +434,14 @@ func (a *AppCore) SendFileMessage(chatID, text, replyToID string, files []string
 		return fmt.Errorf("messenger not started")
 	}
 
-	destination, actualChatID, isSelf, contact, err := a.resolveChatDestination(chatID)
+	if a.getGroup(chatID) != nil {
+		return fmt.Errorf("files are not supported in groups yet")
+	}
+	if a.getChannel(chatID) != nil {
+		return fmt.Errorf("files are not supported in channels yet")
+	}
+
+	actualChatID, isSelf, contact, err := a.resolveChatDestination(chatID)
 	if err != nil {
 		return err
 	}
@@ -288,14 +460,28 @@ func (a *AppCore) SendFileMessage(chatID, text, replyToID string, files []string
 	}
 
 	if !isRaw && allImages {
-		return a.sendAsCompressedImages(destination, actualChatID, msgID, text, replyToID, files, isSelf, now, contact)
+		return a.sendAsCompressedImages(actualChatID, msgID, text, replyToID, files, isSelf, now, contact)
 	}
 
-	return a.sendAsFileOffer(destination, actualChatID, msgID, text, replyToID, files, isSelf, now, contact)
+	return a.sendAsFileOffer(actualChatID, msgID, text, replyToID, files, isSelf, now, contact)
 }
 
 // SaveAttachment сохраняет вложение на диск
 func (a *AppCore) SaveAttachment(filename string, data []byte) (string, error) {
+	fullPath, err := a.newMediaPath(filename)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(fullPath, data, 0600); err != nil {
+		return "", err
+	}
+
+	return fullPath, nil
+}
+
+// newMediaPath возвращает новый путь для вложения в каталоге media
+func (a *AppCore) newMediaPath(filename string) (string, error) {
 	if a.Identity == nil {
 		return "", fmt.Errorf("user not logged in")
 	}
@@ -310,17 +496,29 @@ func (a *AppCore) SaveAttachment(filename string, data []byte) (string, error) {
 		ext = ".bin"
 	}
 	newFilename := fmt.Sprintf("%d_%s%s", time.Now().UnixNano(), uuid.New().String()[:8], ext)
-	fullPath := filepath.Join(mediaDir, newFilename)
+	return filepath.Join(mediaDir, newFilename), nil
+}
 
-	if err := os.WriteFile(fullPath, data, 0600); err != nil {
-		return "", err
+func detectMimeType(path string) string {
+	mimeType := mime.TypeByExtension(filepath.Ext(path))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
 	}
-
-	return fullPath, nil
+	return mimeType
 }
 
 // AcceptFileTransfer accepts an incoming file offer
 func (a *AppCore) AcceptFileTransfer(messageID string) error {
+	if a.Repo != nil {
+		list, err := a.Repo.ListFileTransfers(a.Ctx, messageID, core.TransferIncoming)
+		if err != nil {
+			return err
+		}
+		if len(list) > 0 {
+			return a.acceptIncomingTransfers(messageID, list)
+		}
+	}
+
 	a.TransferMu.RLock()
 	transfer, exists := a.PendingTransfers[messageID]
 	a.TransferMu.RUnlock()
@@ -334,6 +532,16 @@ func (a *AppCore) AcceptFileTransfer(messageID string) error {
 
 // DeclineFileTransfer declines an incoming file offer
 func (a *AppCore) DeclineFileTransfer(messageID string) error {
+	if a.Repo != nil {
+		list, err := a.Repo.ListFileTransfers(a.Ctx, messageID, core.TransferIncoming)
+		if err != nil {
+			return err
+		}
+		if len(list) > 0 {
+			return a.declineIncomingTransfers(messageID, list)
+		}
+	}
+
 	a.TransferMu.RLock()
 	transfer, exists := a.PendingTransfers[messageID]
 	a.TransferMu.RUnlock()
@@ -354,7 +562,7 @@ func (a *AppCore) DeclineFileTransfer(messageID string) error {
 }
 
 // onFileOffer handles incoming file transfer offers
-func (a *AppCore) onFileOffer(senderPubKey, messageID, chatID string, filenames []string, totalSize int64, fileCount int32) {
+func (a *AppCore) onFileOffer(senderPubKey, messageID, chatID string, filenames []string, totalSize int64, fileCount int32, files []*pb.FileManifest) {
 	if a.Repo == nil {
 		return
 	}
@@ -363,16 +571,42 @@ func (a *AppCore) onFileOffer(senderPubKey, messageID, chatID string, filenames
 	if err != nil || contact == nil {
 		return
 	}
+	if contact.IsPending {
+		// Скачивание запрашивает части у отправителя — файлы незнакомцев не принимаем
+		log.Printf("[AppCore] Ignored file offer %s from pending contact", messageID)
+		return
+	}
+	if existing, _ := a.Repo.GetMessage(a.Ctx, messageID); existing != nil {
+		// Повтор предложения или чужой ID: состояние передачи не трогаем
+		log.Printf("[AppCore] Ignored file offer %s: ID already taken", messageID)
+		return
+	}
 
-	a.TransferMu.Lock()
-	a.PendingTransfers[messageID] = &PendingTransfer{
-		Destination: contact.I2PAddress,
-		ChatID:      contact.ChatID,
-		Files:       filenames,
-		MessageID:   messageID,
-		Timestamp:   time.Now().UnixMilli(),
+	// С манифестом файл придёт частями, состояние хранится в БД
+	var attachments []*core.Attachment
+	if len(files) > 0 {
+		attachments, err = a.saveIncomingTransfers(contact.ChatID, messageID, files)
+		if err != nil {
+			log.Printf("[AppCore] Rejected file offer %s: %v", messageID, err)
+			return
+		}
+		// #nosec G115
+		fileCount = int32(len(files))
+		totalSize = 0
+		for _, f := range files {
+			totalSize += f.Size
+		}
+	} else {
+		a.TransferMu.Lock()
+		a.PendingTransfers[messageID] = &PendingTransfer{
+			Destination: contact.I2PAddress,
+			ChatID:      contact.ChatID,
+			Files:       filenames,
+			MessageID:   messageID,
+			Timestamp:   time.Now().UnixMilli(),
+		}
+		a.TransferMu.Unlock()
 	}
-	a.TransferMu.Unlock()
 
 	msg := &core.Message{
 		ID:          messageID,
@@ -386,9 +620,11 @@ func (a *AppCore) onFileOffer(senderPubKey, messageID, chatID string, filenames
 		UpdatedAt:   time.Now(),
 		FileCount:   int(fileCount),
 		TotalSize:   totalSize,
+		Attachments: attachments,
 	}
-	if err := a.Repo.SaveMessage(a.Ctx, msg); err != nil {
+	if err := a.Repo.InsertMessage(a.Ctx, msg); err != nil {
 		log.Printf("[AppCore] Failed to save message: %v", err)
+		return
 	}
 
 	a.Emitter.Emit("new_message", map[string]interface{}{
@@ -406,6 +642,14 @@ func (a *AppCore) onFileOffer(senderPubKey, messageID, chatID string, filenames
 
 // onFileResponse handles response to our file offer
 func (a *AppCore) onFileResponse(senderPubKey, messageID, chatID string, accepted bool) {
+	if a.Repo != nil {
+		list, err := a.Repo.ListFileTransfers(a.Ctx, messageID, core.TransferOutgoing)
+		if err == nil && len(list) > 0 {
+			a.onFileResponseChunked(senderPubKey, messageID, list, accepted)
+			return
+		}
+	}
+
 	a.TransferMu.Lock()
 	transfer, exists := a.PendingTransfers[messageID]
 	a.TransferMu.Unlock()
@@ -422,14 +666,10 @@ func (a *AppCore) onFileResponse(senderPubKey, messageID, chatID string, accepte
 				log.Printf("[AppCore] Failed to read file during transfer: %v", err)
 				continue
 			}
-			mimeType := mime.TypeByExtension(filepath.Ext(filePath))
-			if mimeType == "" {
-				mimeType = "application/octet-stream"
-			}
 			att := &pb.Attachment{
 				Id:           uuid.New().String(),
 				Filename:     filepath.Base(filePath),
-				MimeType:     mimeType,
+				MimeType:     detectMimeType(filePath),
 				Size:         int64(len(data)),
 				Data:         data,
 				IsCompressed: false,
@@ -447,34 +687,23 @@ func (a *AppCore) onFileResponse(senderPubKey, messageID, chatID string, accepte
 	a.TransferMu.Unlock()
 }
 
-func (a *AppCore) resolveChatDestination(chatID string) (string, string, bool, *core.Contact, error) {
+func (a *AppCore) resolveChatDestination(chatID string) (string, bool, *core.Contact, error) {
 	if chatID == a.Identity.Keys.UserID {
-		return "", a.Identity.Keys.UserID, true, nil, nil
+		return a.Identity.Keys.UserID, true, nil, nil
 	}
 	contact, err := a.Repo.GetContact(a.Ctx, chatID)
 	if err != nil || contact == nil {
-		return "", "", false, nil, fmt.Errorf("contact not found")
+		return "", false, nil, fmt.Errorf("contact not found")
 	}
 
-	destination := contact.I2PAddress
-	if contact.ChatID == "" {
-		contact.ChatID = identity.CalculateChatID(a.Identity.Keys.PublicKeyBase64, contact.PublicKey)
-		if errSave := a.Repo.SaveContact(a.Ctx, contact); errSave != nil {
-			log.Printf("[AppCore] Failed to save contact during message send: %v", errSave)
-		}
-	}
-	actualChatID := contact.ChatID
-
-	if contact.PublicKey == "" && a.Messenger != nil {
-		log.Printf("[AppCore] No public key for %s, sending handshake first...", contact.Nickname)
-		if errH := a.Messenger.SendHandshake(contact.I2PAddress); errH != nil {
-			log.Printf("[AppCore] Handshake failed: %v", errH)
-		}
+	if contact.IsBlocked {
+		return "", false, nil, fmt.Errorf("contact is blocked")
 	}
-	return destination, actualChatID, false, contact, nil
+	a.ensureChatID(contact)
+	return contact.ChatID, false, contact, nil
 }
 
-func (a *AppCore) sendAsCompressedImages(destination, actualChatID, msgID, text, replyToID string, files []string, isSelf bool, now int64, contact *core.Contact) error {
+func (a *AppCore) sendAsCompressedImages(actualChatID, msgID, text, replyToID string, files []string, isSelf bool, now int64, contact *core.Contact) error {
 	attachments := make([]*pb.Attachment, 0, len(files))
 	for _, filePath := range files {
 		data, mimeType, width, height, err := utils.CompressImage(filePath, 1280, 1280)
@@ -509,15 +738,13 @@ func (a *AppCore) sendAsCompressedImages(destination, actualChatID, msgID, text,
 		return fmt.Errorf("failed to compress any images")
 	}
 
-	if !isSelf {
-		if err := a.Messenger.SendAttachmentMessageWithID(destination, actualChatID, msgID, text, replyToID, attachments); err != nil {
-			return fmt.Errorf("failed to send message: %w", err)
-		}
-	}
-
 	coreAttachments := make([]*core.Attachment, 0, len(attachments))
 	for _, att := range attachments {
-		savedPath, _ := a.SaveAttachment(att.Filename, att.Data)
+		// Копия в media нужна outbox: из неё он соберёт пакет при повторной отправке
+		savedPath, err := a.SaveAttachment(att.Filename, att.Data)
+		if err != nil {
+			return fmt.Errorf("failed to save attachment: %w", err)
+		}
 		coreAtt := &core.Attachment{
 			ID:           att.Id,
 			Filename:     att.Filename,
@@ -537,7 +764,7 @@ func (a *AppCore) sendAsCompressedImages(destination, actualChatID, msgID, text,
 		SenderID:    a.Identity.Keys.UserID,
 		Content:     text,
 		ContentType: "mixed",
-		Status:      core.MessageStatusSent,
+		Status:      outgoingStatus(isSelf),
 		IsOutgoing:  true,
 		Timestamp:   now,
 		CreatedAt:   time.Now(),
@@ -548,8 +775,9 @@ func (a *AppCore) sendAsCompressedImages(destination, actualChatID, msgID, text,
 	if replyToID != "" {
 		msg.ReplyToID = &replyToID
 	}
+	// Сообщение сначала сохраняется, доставкой занимается outbox
 	if err := a.Repo.SaveMessage(a.Ctx, msg); err != nil {
-		log.Printf("[AppCore] Failed to save message: %v", err)
+		return fmt.Errorf("failed to save message: %w", err)
 	}
 
 	// Формируем вложения для фронтенда
@@ -581,10 +809,13 @@ func (a *AppCore) sendAsCompressedImages(destination, actualChatID, msgID, text,
 		"Attachments":  infoAttachments,
 	})
 
-	return nil
+	if isSelf {
+		return nil
+	}
+	return a.queueOutgoing(msg)
 }
 
-func (a *AppCore) sendAsFileOffer(destination, actualChatID, msgID, text, replyToID string, files []string, isSelf bool, now int64, contact *core.Contact) error {
+func (a *AppCore) sendAsFileOffer(actualChatID, msgID, text, replyToID string, files []string, isSelf bool, now int64, contact *core.Contact) error {
 	// Pre-process images to strip metadata
 	processedFiles := make([]string, len(files))
 	copy(processedFiles, files)
@@ -611,33 +842,42 @@ func (a *AppCore) sendAsFileOffer(destination, actualChatID, msgID, text, replyT
 		}
 	}
 
-	a.TransferMu.Lock()
-	a.PendingTransfers[msgID] = &PendingTransfer{
-		Destination: destination,
-		ChatID:      actualChatID,
-		Files:       processedFiles,
-		MessageID:   msgID,
-		Timestamp:   now,
+	if len(files) > maxOfferFiles {
+		return fmt.Errorf("too many files in one offer")
 	}
-	a.TransferMu.Unlock()
 
+	// Манифест с SHA-256 каждого файла: получатель проверит собранные части
 	var totalSize int64
 	filenames := make([]string, len(processedFiles))
+	manifests := make([]*pb.FileManifest, 0, len(processedFiles))
+	coreAttachments := make([]*core.Attachment, 0, len(processedFiles))
 	for i, f := range processedFiles {
-		info, _ := os.Stat(f)
-		if info != nil {
-			totalSize += info.Size()
-		}
 		filenames[i] = filepath.Base(files[i])
+		fileID := uuid.New().String()
+
+		manifest, err := buildFileManifest(fileID, f, filenames[i])
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", filenames[i], err)
+		}
+		manifests = append(manifests, manifest)
+		totalSize += manifest.Size
+
+		coreAttachments = append(coreAttachments, &core.Attachment{
+			ID:           fileID,
+			MessageID:    msgID,
+			Filename:     filenames[i],
+			MimeType:     manifest.MimeType,
+			Size:         manifest.Size,
+			LocalPath:    f,
+			IsCompressed: false,
+		})
 	}
 
 	if !isSelf {
-		fileCount := len(files)
-		if fileCount > 1000 { // limit for sanity
-			return fmt.Errorf("too many files in one offer")
-		}
-		if err := a.Messenger.SendFileOffer(destination, actualChatID, msgID, filenames, totalSize, int32(fileCount)); err != nil {
-			return fmt.Errorf("failed to send file offer: %w", err)
+		// Состояние сохраняем до отправки: ответ может прийти раньше, чем outbox узнает об успехе.
+		// Из него же outbox соберёт предложение.
+		if err := a.saveOutgoingTransfers(actualChatID, msgID, coreAttachments, manifests); err != nil {
+			return fmt.Errorf("failed to save file transfer: %w", err)
 		}
 	}
 
@@ -647,39 +887,23 @@ func (a *AppCore) sendAsFileOffer(destination, actualChatID, msgID, text, replyT
 		SenderID:    a.Identity.Keys.UserID,
 		Content:     text,
 		ContentType: "file_offer",
-		Status:      core.MessageStatusSent,
+		Status:      outgoingStatus(isSelf),
 		IsOutgoing:  true,
 		Timestamp:   now,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 		FileCount:   len(files),
 		TotalSize:   totalSize,
+		Attachments: coreAttachments,
 	}
 	if replyToID != "" {
 		msg.ReplyToID = &replyToID
 	}
-
-	coreAttachments := make([]*core.Attachment, 0, len(processedFiles))
-	for i, f := range processedFiles {
-		stat, _ := os.Stat(f)
-		size := int64(0)
-		if stat != nil {
-			size = stat.Size()
-		}
-		coreAtt := &core.Attachment{
-			ID:           uuid.New().String(),
-			MessageID:    msgID,
-			Filename:     filepath.Base(files[i]),
-			MimeType:     "application/octet-stream",
-			Size:         size,
-			LocalPath:    f,
-			IsCompressed: false,
-		}
-		coreAttachments = append(coreAttachments, coreAtt)
-	}
-	msg.Attachments = coreAttachments
 	if errRepo := a.Repo.SaveMessage(a.Ctx, msg); errRepo != nil {
-		log.Printf("[AppCore] Failed to save message: %v", errRepo)
+		if errDel := a.Repo.DeleteFileTransfers(a.Ctx, msgID, core.TransferOutgoing); errDel != nil {
+			log.Printf("[AppCore] Failed to delete file transfers: %v", errDel)
+		}
+		return fmt.Errorf("failed to save message: %w", errRepo)
 	}
 
 	a.Emitter.Emit("new_message", map[string]interface{}{
@@ -697,5 +921,8 @@ func (a *AppCore) sendAsFileOffer(destination, actualChatID, msgID, text, replyT
 		"ReplyPreview": a.getReplyPreview(replyToID, contact),
 	})
 
-	return nil
+	if isSelf {
+		return nil
+	}
+	return a.queueOutgoing(msg)
 }
@@ -0,0 +1,577 @@
+package appcore
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"teleghost/internal/core"
+	"teleghost/internal/core/identity"
+	"teleghost/internal/network/messenger"
+	pb "teleghost/internal/proto"
+	"teleghost/internal/repository/sqlite"
+)
+
+const (
+	// DefaultMessageTTL — сколько по умолчанию пытаемся доставить сообщение
+	DefaultMessageTTL = 72 * time.Hour
+
+	// OutboxPollInterval — как часто очередь проверяется без внешних событий
+	OutboxPollInterval = 15 * time.Second
+
+	// OutboxBaseBackoff, OutboxMaxBackoff — границы экспоненциальной паузы между попытками
+	OutboxBaseBackoff = 10 * time.Second
+	OutboxMaxBackoff  = 10 * time.Minute
+)
+
+// outbox — фоновая доставка сообщений из очереди.
+// Сообщения одного чата отправляются по порядку; после ошибки чат ждёт паузу,
+// которая удваивается с каждой неудачей и сбрасывается при активности собеседника.
+type outbox struct {
+	app    *AppCore
+	wake   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	backoff map[string]*chatBackoff // chatID -> пауза после ошибок
+	busy    map[string]bool         // чаты, доставка в которые идёт сейчас; true — за это время появилось новое
+}
+
+// chatBackoff — состояние паузы для одного чата
+type chatBackoff struct {
+	failures int
+	next     time.Time
+}
+
+// startOutbox запускает фоновую доставку (вызывается после старта мессенджера)
+func (a *AppCore) startOutbox() {
+	a.stopOutbox()
+
+	ctx, cancel := context.WithCancel(a.Ctx)
+	ob := &outbox{
+		app:     a,
+		wake:    make(chan struct{}, 1),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		backoff: make(map[string]*chatBackoff),
+		busy:    make(map[string]bool),
+	}
+
+	a.mu.Lock()
+	a.outbox = ob
+	a.mu.Unlock()
+
+	go ob.run(ctx)
+}
+
+// stopOutbox останавливает фоновую доставку. Очередь остаётся в БД.
+func (a *AppCore) stopOutbox() {
+	a.mu.Lock()
+	ob := a.outbox
+	a.outbox = nil
+	a.mu.Unlock()
+
+	if ob != nil {
+		ob.cancel()
+		<-ob.done
+	}
+}
+
+func (a *AppCore) getOutbox() *outbox {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.outbox
+}
+
+// messageTTL возвращает время, после которого недоставленное сообщение считается Failed
+func (a *AppCore) messageTTL() time.Duration {
+	if hours := a.GetRouterSettings().MessageTTLHours; hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return DefaultMessageTTL
+}
+
+// enqueueOutgoing ставит сохранённое сообщение в очередь и будит доставку
+func (a *AppCore) enqueueOutgoing(msg *core.Message) error {
+	entry := &core.OutboxEntry{
+		MessageID: msg.ID,
+		ChatID:    msg.ChatID,
+		ExpiresAt: time.Now().Add(a.messageTTL()).UnixMilli(),
+	}
+	if err := a.Repo.EnqueueOutbox(a.Ctx, entry); err != nil {
+		return err
+	}
+
+	if ob := a.getOutbox(); ob != nil {
+		ob.kick()
+	}
+	return nil
+}
+
+// enqueueChange ставит правку или удаление в очередь и будит доставку
+func (a *AppCore) enqueueChange(c *core.MessageChange) error {
+	c.ExpiresAt = time.Now().Add(a.messageTTL()).UnixMilli()
+	if err := a.Repo.EnqueueMessageChange(a.Ctx, c); err != nil {
+		return err
+	}
+
+	if ob := a.getOutbox(); ob != nil {
+		ob.kick()
+	}
+	return nil
+}
+
+// outgoingStatus — статус нового исходящего: в «Избранное» оно сразу отправлено,
+// остальные сохраняются и ждут outbox
+func outgoingStatus(isSelf bool) core.MessageStatus {
+	if isSelf {
+		return core.MessageStatusSent
+	}
+	return core.MessageStatusPending
+}
+
+// queueOutgoing ставит только что сохранённое сообщение в очередь; при ошибке помечает его Failed
+func (a *AppCore) queueOutgoing(msg *core.Message) error {
+	if err := a.enqueueOutgoing(msg); err != nil {
+		log.Printf("[AppCore] Failed to queue message %s: %v", msg.ID, err)
+		_ = a.Repo.UpdateMessageStatus(a.Ctx, msg.ID, core.MessageStatusFailed)
+		a.emitMessageStatus(msg.ChatID, msg.ID, core.MessageStatusFailed)
+		return fmt.Errorf("send failed: %w", err)
+	}
+	return nil
+}
+
+// RetryMessage заново ставит недоставленное сообщение в очередь.
+func (a *AppCore) RetryMessage(messageID string) error {
+	if a.Repo == nil {
+		return fmt.Errorf("not logged in")
+	}
+
+	msg, err := a.Repo.GetMessage(a.Ctx, messageID)
+	if err != nil || msg == nil {
+		return fmt.Errorf("message not found")
+	}
+	if !msg.IsOutgoing || (msg.Status != core.MessageStatusFailed && msg.Status != core.MessageStatusPending) {
+		return fmt.Errorf("message is not waiting for delivery")
+	}
+
+	if err := a.Repo.UpdateMessageStatus(a.Ctx, messageID, core.MessageStatusPending); err != nil {
+		return err
+	}
+	a.emitMessageStatus(msg.ChatID, messageID, core.MessageStatusPending)
+
+	// Ручной повтор не должен ждать накопленную паузу
+	if ob := a.getOutbox(); ob != nil {
+		ob.resetBackoff(msg.ChatID)
+	}
+
+	return a.enqueueOutgoing(msg)
+}
+
+// CancelMessage отменяет отправку сообщения и удаляет его.
+func (a *AppCore) CancelMessage(messageID string) error {
+	if a.Repo == nil {
+		return fmt.Errorf("not logged in")
+	}
+
+	msg, err := a.Repo.GetMessage(a.Ctx, messageID)
+	if err != nil || msg == nil {
+		return fmt.Errorf("message not found")
+	}
+	if !msg.IsOutgoing || (msg.Status != core.MessageStatusFailed && msg.Status != core.MessageStatusPending) {
+		return fmt.Errorf("message already sent")
+	}
+
+	if err := a.Repo.DeleteOutbox(a.Ctx, messageID); err != nil {
+		return err
+	}
+	if err := a.Repo.DeleteMessage(a.Ctx, messageID); err != nil {
+		return err
+	}
+
+	a.emitMessageDeleted(msg.ChatID, messageID)
+	return nil
+}
+
+// onPeerActivity досылает сообщения и продолжает передачи, как только собеседник появился в сети
+func (a *AppCore) onPeerActivity(senderPubKey, _ string) {
+	if a.Identity == nil {
+		return
+	}
+	a.touchPresence(senderPubKey)
+	a.onChannelOwnerActivity(senderPubKey)
+
+	chatID := identity.CalculateChatID(a.Identity.Keys.PublicKeyBase64, senderPubKey)
+	a.resumeStalledTransfers(chatID)
+
+	ob := a.getOutbox()
+	if ob == nil {
+		return
+	}
+	ob.poke(chatID)
+
+	// Групповые сообщения этому участнику тоже досылаем без ожидания паузы
+	if a.Repo == nil {
+		return
+	}
+	groupIDs, err := a.Repo.ListGroupIDsByMember(a.Ctx, senderPubKey)
+	if err != nil {
+		return
+	}
+	for _, groupID := range groupIDs {
+		ob.poke(groupMemberKey(groupID, senderPubKey))
+	}
+}
+
+func (a *AppCore) emitMessageStatus(chatID, messageID string, status core.MessageStatus) {
+	a.Emitter.Emit("message_status", map[string]interface{}{
+		"ID":     messageID,
+		"ChatID": chatID,
+		"Status": status.String(),
+	})
+}
+
+// run — основной цикл доставки
+func (o *outbox) run(ctx context.Context) {
+	defer close(o.done)
+
+	ticker := time.NewTicker(OutboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		o.process(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+// process просматривает очередь и запускает доставку в чаты, у которых закончилась пауза
+func (o *outbox) process(ctx context.Context) {
+	// Берём локальные ссылки: при выходе из профиля поля AppCore обнуляются
+	repo, m := o.app.Repo, o.app.Messenger
+	if repo == nil || m == nil || ctx.Err() != nil {
+		return
+	}
+
+	entries, err := repo.ListOutbox(o.app.Ctx)
+	if err != nil {
+		log.Printf("[AppCore] Failed to list outbox: %v", err)
+		return
+	}
+
+	now := time.Now()
+	byChat := make(map[string][]*core.OutboxEntry)
+	var order []string
+	for _, e := range entries {
+		if now.UnixMilli() > e.ExpiresAt {
+			o.app.failOutgoing(repo, e)
+			continue
+		}
+		if _, ok := byChat[e.ChatID]; !ok {
+			order = append(order, e.ChatID)
+		}
+		byChat[e.ChatID] = append(byChat[e.ChatID], e)
+	}
+
+	// Правки и удаления идут в тот же чат после сообщений
+	changes, err := repo.ListMessageChanges(o.app.Ctx)
+	if err != nil {
+		log.Printf("[AppCore] Failed to list message changes: %v", err)
+	}
+	changesByChat := make(map[string][]*core.MessageChange)
+	for _, c := range changes {
+		if now.UnixMilli() > c.ExpiresAt {
+			log.Printf("[AppCore] Message %s %s expired after %d attempts: %s", c.Kind, c.MessageID, c.Attempts, c.LastError)
+			if err := repo.DeleteMessageChange(o.app.Ctx, c); err != nil {
+				log.Printf("[AppCore] %v", err)
+			}
+			continue
+		}
+		if _, ok := byChat[c.ChatID]; !ok && changesByChat[c.ChatID] == nil {
+			order = append(order, c.ChatID)
+		}
+		changesByChat[c.ChatID] = append(changesByChat[c.ChatID], c)
+	}
+
+	for _, chatID := range order {
+		if !o.acquire(chatID, now) {
+			continue
+		}
+		go o.deliverChat(ctx, repo, m, chatID, byChat[chatID], changesByChat[chatID])
+	}
+}
+
+// deliverChat отправляет сообщения одного чата, а за ними правки и удаления, по порядку до первой ошибки
+func (o *outbox) deliverChat(ctx context.Context, repo *sqlite.Repository, m *messenger.Service, chatID string, entries []*core.OutboxEntry, changes []*core.MessageChange) {
+	defer o.release(chatID)
+
+	if g, err := repo.GetGroup(o.app.Ctx, chatID); err == nil && g != nil {
+		o.deliverGroup(ctx, repo, m, g, entries)
+		return
+	}
+
+	contact := contactByChatID(o.app.Ctx, repo, chatID)
+	if contact == nil || contact.I2PAddress == "" {
+		log.Printf("[AppCore] No contact for chat %s, dropping %d queued messages", chatID, len(entries)+len(changes))
+		for _, e := range entries {
+			// Handshake мог только что перенести чат под настоящий ChatID: такие доставит следующий проход
+			if msg, err := repo.GetMessage(o.app.Ctx, e.MessageID); err == nil && msg != nil && msg.ChatID != chatID {
+				continue
+			}
+			o.app.failOutgoing(repo, e)
+		}
+		for _, c := range changes {
+			if msg, err := repo.GetMessage(o.app.Ctx, c.MessageID); err == nil && msg != nil && msg.ChatID != chatID {
+				continue
+			}
+			_ = repo.DeleteMessageChange(o.app.Ctx, c)
+		}
+		return
+	}
+
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+
+		msg, err := repo.GetMessage(o.app.Ctx, e.MessageID)
+		if err != nil || msg == nil {
+			_ = repo.DeleteOutbox(o.app.Ctx, e.MessageID)
+			continue
+		}
+
+		if err := deliverOutgoing(o.app.Ctx, repo, m, contact.I2PAddress, msg); err != nil {
+			if errRec := repo.RecordOutboxAttempt(o.app.Ctx, e.MessageID, err.Error()); errRec != nil {
+				log.Printf("[AppCore] %v", errRec)
+			}
+			delay := o.fail(chatID)
+			log.Printf("[AppCore] Delivery to %s failed (attempt %d), retry in %v: %v", contact.Nickname, e.Attempts+1, delay, err)
+			return
+		}
+
+		if err := repo.DeleteOutbox(o.app.Ctx, e.MessageID); err != nil {
+			log.Printf("[AppCore] %v", err)
+		}
+		o.resetBackoff(chatID)
+		o.app.markOutgoingSent(repo, msg)
+	}
+
+	for _, c := range changes {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := deliverChange(m, contact.I2PAddress, c); err != nil {
+			if errRec := repo.RecordMessageChangeAttempt(o.app.Ctx, c.MessageID, err.Error()); errRec != nil {
+				log.Printf("[AppCore] %v", errRec)
+			}
+			delay := o.fail(chatID)
+			log.Printf("[AppCore] Message %s to %s failed (attempt %d), retry in %v: %v", c.Kind, contact.Nickname, c.Attempts+1, delay, err)
+			return
+		}
+		if err := repo.DeleteMessageChange(o.app.Ctx, c); err != nil {
+			log.Printf("[AppCore] %v", err)
+		}
+		o.resetBackoff(chatID)
+	}
+}
+
+// deliverChange отправляет правку или удаление сообщения
+func deliverChange(m *messenger.Service, destination string, c *core.MessageChange) error {
+	switch c.Kind {
+	case core.MessageChangeEdit:
+		return m.SendMessageEdit(destination, c.ChatID, c.MessageID, c.Content, c.EditedAt)
+	case core.MessageChangeDelete:
+		return m.SendMessageDelete(destination, c.ChatID, c.MessageID, true)
+	default:
+		return fmt.Errorf("unsupported message change: %s", c.Kind)
+	}
+}
+
+// deliverOutgoing собирает пакет по сохранённому сообщению и отправляет его
+func deliverOutgoing(ctx context.Context, repo *sqlite.Repository, m *messenger.Service, destination string, msg *core.Message) error {
+	replyToID := ""
+	if msg.ReplyToID != nil {
+		replyToID = *msg.ReplyToID
+	}
+	switch msg.ContentType {
+	case "text":
+		return m.SendTextMessageWithID(destination, msg.ChatID, msg.ID, msg.Content, replyToID)
+	case "mixed":
+		// Сжатые изображения лежат в media в том виде, в каком уходят собеседнику
+		attachments := make([]*pb.Attachment, 0, len(msg.Attachments))
+		for _, att := range msg.Attachments {
+			data, err := os.ReadFile(att.LocalPath)
+			if err != nil {
+				return fmt.Errorf("failed to read attachment %s: %w", att.Filename, err)
+			}
+			attachments = append(attachments, &pb.Attachment{
+				Id:           att.ID,
+				Filename:     att.Filename,
+				MimeType:     att.MimeType,
+				Size:         int64(len(data)),
+				Data:         data,
+				IsCompressed: att.IsCompressed,
+				Width:        int32(att.Width),  // #nosec G115 -- размеры проверены при сжатии
+				Height:       int32(att.Height), // #nosec G115
+			})
+		}
+		return m.SendAttachmentMessageWithID(destination, msg.ChatID, msg.ID, msg.Content, replyToID, attachments)
+	case "file_offer":
+		list, err := repo.ListFileTransfers(ctx, msg.ID, core.TransferOutgoing)
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			return fmt.Errorf("no files for offer %s", msg.ID)
+		}
+		var totalSize int64
+		filenames := make([]string, len(list))
+		manifests := make([]*pb.FileManifest, len(list))
+		for i, t := range list {
+			filenames[i] = t.Filename
+			totalSize += t.Size
+			manifests[i] = &pb.FileManifest{
+				FileId:    t.FileID,
+				Filename:  t.Filename,
+				MimeType:  t.MimeType,
+				Size:      t.Size,
+				Sha256:    t.SHA256,
+				ChunkSize: t.ChunkSize,
+			}
+		}
+		// #nosec G115 -- ограничено maxOfferFiles
+		return m.SendFileOffer(destination, msg.ChatID, msg.ID, filenames, totalSize, int32(len(list)), manifests)
+	default:
+		return fmt.Errorf("unsupported content type for outbox: %s", msg.ContentType)
+	}
+}
+
+// markOutgoingSent отмечает сообщение отправленным, если отчёт о доставке не пришёл раньше
+func (a *AppCore) markOutgoingSent(repo *sqlite.Repository, msg *core.Message) {
+	current, err := repo.GetMessage(a.Ctx, msg.ID)
+	if err != nil || current == nil || !statusAdvances(current.Status, core.MessageStatusSent) {
+		return
+	}
+	if err := repo.UpdateMessageStatus(a.Ctx, msg.ID, core.MessageStatusSent); err != nil {
+		log.Printf("[AppCore] Failed to update message status: %v", err)
+		return
+	}
+	a.emitMessageStatus(msg.ChatID, msg.ID, core.MessageStatusSent)
+}
+
+// failOutgoing убирает сообщение из очереди и помечает его Failed
+func (a *AppCore) failOutgoing(repo *sqlite.Repository, e *core.OutboxEntry) {
+	if err := repo.DeleteOutbox(a.Ctx, e.MessageID); err != nil {
+		log.Printf("[AppCore] %v", err)
+	}
+	if err := repo.UpdateMessageStatus(a.Ctx, e.MessageID, core.MessageStatusFailed); err != nil {
+		log.Printf("[AppCore] Failed to update message status: %v", err)
+		return
+	}
+	log.Printf("[AppCore] Message %s expired after %d attempts: %s", e.MessageID, e.Attempts, e.LastError)
+	a.emitMessageStatus(e.ChatID, e.MessageID, core.MessageStatusFailed)
+}
+
+// acquire занимает чат для доставки, если он свободен и пауза истекла
+func (o *outbox) acquire(chatID string, now time.Time) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.busy[chatID]; ok {
+		// Идущая доставка могла не увидеть новых записей: пройдём ещё раз после неё
+		o.busy[chatID] = true
+		return false
+	}
+	if b := o.backoff[chatID]; b != nil && now.Before(b.next) {
+		return false
+	}
+	o.busy[chatID] = false
+	return true
+}
+
+func (o *outbox) release(chatID string) {
+	o.mu.Lock()
+	again := o.busy[chatID]
+	delete(o.busy, chatID)
+	o.mu.Unlock()
+
+	if again {
+		o.kick()
+	}
+}
+
+// fail увеличивает паузу чата и возвращает её длительность
+func (o *outbox) fail(chatID string) time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	b := o.backoff[chatID]
+	if b == nil {
+		b = &chatBackoff{}
+		o.backoff[chatID] = b
+	}
+	b.failures++
+
+	delay := OutboxBaseBackoff
+	for i := 1; i < b.failures && delay < OutboxMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > OutboxMaxBackoff {
+		delay = OutboxMaxBackoff
+	}
+
+	b.next = time.Now().Add(delay)
+	return delay
+}
+
+// waiting сообщает, не истекла ли ещё пауза по ключу
+func (o *outbox) waiting(key string, now time.Time) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	b := o.backoff[key]
+	return b != nil && now.Before(b.next)
+}
+
+// resetBackoff снимает паузу с чата
+func (o *outbox) resetBackoff(chatID string) {
+	o.mu.Lock()
+	delete(o.backoff, chatID)
+	o.mu.Unlock()
+}
+
+// poke снимает паузу с чата и будит доставку, если в чат есть что дослать
+func (o *outbox) poke(chatID string) {
+	o.mu.Lock()
+	_, waiting := o.backoff[chatID]
+	delete(o.backoff, chatID)
+	o.mu.Unlock()
+
+	if waiting {
+		o.kick()
+	}
+}
+
+// pokeAll снимает паузы со всех чатов и будит доставку
+func (o *outbox) pokeAll() {
+	o.mu.Lock()
+	clear(o.backoff)
+	o.mu.Unlock()
+	o.kick()
+}
+
+// kick будит цикл доставки
+func (o *outbox) kick() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,310 @@
+package appcore
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"teleghost/internal/core"
+	"teleghost/internal/network/messenger"
+)
+
+const (
+	// PresenceTimeout — через сколько без пакетов контакт считается не в сети
+	// (heartbeat приходит раз в минуту)
+	PresenceTimeout = 3 * messenger.HeartbeatInterval
+
+	// lastSeenSaveInterval — как часто записываем LastSeen в БД для активного контакта
+	lastSeenSaveInterval = time.Minute
+
+	// presenceSweepInterval — как часто проверяем, кто из контактов пропал
+	presenceSweepInterval = 30 * time.Second
+)
+
+// presence — кто из контактов сейчас в сети (по ключу контакта)
+type presence struct {
+	mu     sync.Mutex
+	seen   map[string]time.Time // последний принятый пакет
+	saved  map[string]time.Time // последняя запись LastSeen в БД
+	online map[string]bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newPresence() *presence {
+	return &presence{
+		seen:   make(map[string]time.Time),
+		saved:  make(map[string]time.Time),
+		online: make(map[string]bool),
+	}
+}
+
+// isOnline сообщает, в сети ли контакт
+func (p *presence) isOnline(pubKey string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.online[pubKey]
+}
+
+// presenceVisible — показываем ли статус контакта (скрытие работает в обе стороны)
+func presenceVisible(contact *core.Contact) bool {
+	return !contact.HidePresence && !contact.PeerHidesPresence
+}
+
+// startPresence запускает проверку пропавших контактов
+func (a *AppCore) startPresence() {
+	a.stopPresence()
+
+	ctx, cancel := context.WithCancel(a.Ctx)
+	p := a.presence
+	p.mu.Lock()
+	p.cancel = cancel
+	p.done = make(chan struct{})
+	done := p.done
+	p.mu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(presenceSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.sweepPresence(time.Now())
+			}
+		}
+	}()
+}
+
+// stopPresence сообщает контактам, что мы выходим из сети, и останавливает проверку
+func (a *AppCore) stopPresence() {
+	p := a.presence
+	p.mu.Lock()
+	cancel, done := p.cancel, p.done
+	p.cancel, p.done = nil, nil
+	p.seen = make(map[string]time.Time)
+	p.saved = make(map[string]time.Time)
+	p.online = make(map[string]bool)
+	p.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	if a.Messenger != nil {
+		a.Messenger.BroadcastPresence(false, a.hidesPresenceFrom)
+	}
+	cancel()
+	<-done
+}
+
+// hidesPresenceFrom сообщает, скрываем ли статус от собеседника
+func (a *AppCore) hidesPresenceFrom(pubKey string) bool {
+	if a.Repo == nil || pubKey == "" {
+		return false
+	}
+	contact, err := a.Repo.GetContactByPublicKey(a.Ctx, pubKey)
+	return err == nil && contact != nil && contact.HidePresence
+}
+
+// touchPresence отмечает активность собеседника (любой принятый пакет)
+func (a *AppCore) touchPresence(senderPubKey string) {
+	now := time.Now()
+
+	p := a.presence
+	p.mu.Lock()
+	p.seen[senderPubKey] = now
+	cameOnline := !p.online[senderPubKey]
+	p.online[senderPubKey] = true
+	save := now.Sub(p.saved[senderPubKey]) >= lastSeenSaveInterval
+	if save {
+		p.saved[senderPubKey] = now
+	}
+	p.mu.Unlock()
+
+	if save && a.Repo != nil {
+		if err := a.Repo.UpdateContactLastSeen(a.Ctx, senderPubKey, now); err != nil {
+			log.Printf("[AppCore] Failed to update last seen: %v", err)
+		}
+	}
+	if !cameOnline || a.Repo == nil {
+		return
+	}
+
+	contact, err := a.Repo.GetContactByPublicKey(a.Ctx, senderPubKey)
+	if err != nil || contact == nil {
+		return
+	}
+	a.emitPresence(contact, true, now)
+	if !contact.IsPending {
+		a.announcePresence(contact)
+	}
+}
+
+// markOffline помечает собеседника не в сети
+func (a *AppCore) markOffline(senderPubKey string) {
+	p := a.presence
+	p.mu.Lock()
+	wasOnline := p.online[senderPubKey]
+	delete(p.online, senderPubKey)
+	seen := p.seen[senderPubKey]
+	p.mu.Unlock()
+
+	if !wasOnline || a.Repo == nil {
+		return
+	}
+	if err := a.Repo.UpdateContactLastSeen(a.Ctx, senderPubKey, seen); err != nil {
+		log.Printf("[AppCore] Failed to update last seen: %v", err)
+	}
+
+	contact, err := a.Repo.GetContactByPublicKey(a.Ctx, senderPubKey)
+	if err != nil || contact == nil {
+		return
+	}
+	a.emitPresence(contact, false, seen)
+}
+
+// sweepPresence помечает не в сети контакты, от которых давно ничего не было
+func (a *AppCore) sweepPresence(now time.Time) {
+	p := a.presence
+	p.mu.Lock()
+	var gone []string
+	for pubKey := range p.online {
+		if now.Sub(p.seen[pubKey]) > PresenceTimeout {
+			gone = append(gone, pubKey)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, pubKey := range gone {
+		a.markOffline(pubKey)
+	}
+}
+
+// announcePresence сообщает появившемуся собеседнику, видит ли он наш статус
+func (a *AppCore) announcePresence(contact *core.Contact) {
+	if a.Messenger == nil || contact.I2PAddress == "" || !a.peerSupports(contact, messenger.CapPresence) {
+		return
+	}
+
+	go func(addr string, hide bool) {
+		if err := a.Messenger.SendPresence(addr, !hide, hide); err != nil {
+			log.Printf("[AppCore] Failed to send presence: %v", err)
+		}
+	}(contact.I2PAddress, contact.HidePresence)
+}
+
+// emitPresence сообщает фронтенду о смене статуса контакта
+func (a *AppCore) emitPresence(contact *core.Contact, online bool, lastSeen time.Time) {
+	if !presenceVisible(contact) {
+		return
+	}
+	a.Emitter.Emit("presence", map[string]interface{}{
+		"ContactID": contact.ID,
+		"ChatID":    contact.ChatID,
+		"Online":    online,
+		"LastSeen":  lastSeen.UnixMilli(),
+	})
+}
+
+// onPresence обрабатывает статус, присланный собеседником
+func (a *AppCore) onPresence(senderPubKey string, online, hidden bool) {
+	if a.Repo == nil {
+		return
+	}
+
+	contact, err := a.Repo.GetContactByPublicKey(a.Ctx, senderPubKey)
+	if err != nil || contact == nil {
+		return
+	}
+
+	if contact.PeerHidesPresence != hidden {
+		if err := a.Repo.SetContactPeerHidesPresence(a.Ctx, senderPubKey, hidden); err != nil {
+			log.Printf("[AppCore] Failed to save peer presence setting: %v", err)
+			return
+		}
+		a.Emitter.Emit("contact_updated")
+	}
+
+	if !online {
+		a.markOffline(senderPubKey)
+	}
+}
+
+// onTyping обрабатывает индикатор набора текста
+func (a *AppCore) onTyping(senderPubKey, _ string, typing bool) {
+	if a.Repo == nil {
+		return
+	}
+
+	// ID чата берём из контакта, а не из пакета
+	contact, err := a.Repo.GetContactByPublicKey(a.Ctx, senderPubKey)
+	if err != nil || contact == nil || !presenceVisible(contact) {
+		return
+	}
+
+	a.Emitter.Emit("typing", map[string]interface{}{
+		"ChatID":    contact.ChatID,
+		"ContactID": contact.ID,
+		"Typing":    typing,
+	})
+}
+
+// SendTyping сообщает собеседнику, что мы набираем текст.
+func (a *AppCore) SendTyping(chatID string, typing bool) error {
+	if a.Repo == nil {
+		return fmt.Errorf("not logged in")
+	}
+	if a.Messenger == nil {
+		return nil
+	}
+
+	contact := a.findContactByChatID(chatID)
+	if contact == nil || contact.I2PAddress == "" || contact.HidePresence || !a.isPeerOnline(contact) ||
+		!a.peerSupports(contact, messenger.CapPresence) {
+		return nil
+	}
+
+	go func(addr string) {
+		if err := a.Messenger.SendTyping(addr, chatID, typing); err != nil {
+			log.Printf("[AppCore] Failed to send typing: %v", err)
+		}
+	}(contact.I2PAddress)
+	return nil
+}
+
+// SetContactHidePresence скрывает от контакта наш статус в сети и время последней активности.
+// Скрытие взаимное: статус контакта мы тоже перестаём показывать.
+func (a *AppCore) SetContactHidePresence(contactID string, hide bool) error {
+	if a.Repo == nil {
+		return fmt.Errorf("not logged in")
+	}
+	contact, err := a.Repo.GetContact(a.Ctx, contactID)
+	if err != nil {
+		return err
+	}
+	if contact == nil {
+		return fmt.Errorf("contact not found")
+	}
+	if err := a.Repo.SetContactHidePresence(a.Ctx, contactID, hide); err != nil {
+		return err
+	}
+
+	contact.HidePresence = hide
+	if a.isPeerOnline(contact) {
+		a.announcePresence(contact)
+	}
+	a.Emitter.Emit("contact_updated")
+	return nil
+}
+
+// isPeerOnline сообщает, в сети ли контакт (без учёта настроек приватности)
+func (a *AppCore) isPeerOnline(contact *core.Contact) bool {
+	return contact.PublicKey != "" && a.presence.isOnline(contact.PublicKey)
+}
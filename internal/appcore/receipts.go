@@ -0,0 +1,193 @@
+package appcore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"teleghost/internal/core"
+	"teleghost/internal/core/identity"
+	"teleghost/internal/network/messenger"
+	"teleghost/internal/repository/sqlite"
+)
+
+// privacySettingsKey — ключ настроек приватности в db_metadata
+const privacySettingsKey = "privacy_settings"
+
+// GetPrivacySettings возвращает настройки приватности текущего профиля.
+func (a *AppCore) GetPrivacySettings() *PrivacySettings {
+	defaultSettings := &PrivacySettings{
+		ReadReceipts: true,
+	}
+
+	if a.Repo == nil {
+		return defaultSettings
+	}
+
+	data, err := a.Repo.GetMetadata(a.Ctx, privacySettingsKey)
+	if err != nil || data == "" {
+		return defaultSettings
+	}
+
+	settings := *defaultSettings
+	if err := json.Unmarshal([]byte(data), &settings); err != nil {
+		log.Printf("[AppCore] Failed to parse privacy settings: %v", err)
+		return defaultSettings
+	}
+
+	return &settings
+}
+
+// SavePrivacySettings сохраняет настройки приватности.
+func (a *AppCore) SavePrivacySettings(settings map[string]interface{}) error {
+	if a.Repo == nil {
+		return fmt.Errorf("not logged in")
+	}
+
+	current := a.GetPrivacySettings()
+
+	if val, ok := settings["readReceipts"].(bool); ok {
+		current.ReadReceipts = val
+	}
+	if val, ok := settings["stampAlgorithm"].(string); ok {
+		if _, known := stampAlgorithms[val]; !known {
+			return fmt.Errorf("unknown stamp algorithm: %s", val)
+		}
+		current.StampAlgorithm = val
+	}
+	if val, ok := settings["stampDifficulty"].(float64); ok {
+		if val < 0 {
+			return fmt.Errorf("invalid stamp difficulty: %v", val)
+		}
+		current.StampDifficulty = int(val)
+	}
+
+	data, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	if err := a.Repo.SetMetadata(a.Ctx, privacySettingsKey, string(data)); err != nil {
+		return err
+	}
+	if a.Messenger != nil {
+		a.Messenger.SetStampPolicy(a.stampPolicy())
+	}
+	return nil
+}
+
+// SetContactReadReceipts включает или выключает отчёты о прочтении для контакта.
+func (a *AppCore) SetContactReadReceipts(contactID string, enabled bool) error {
+	if a.Repo == nil {
+		return fmt.Errorf("not logged in")
+	}
+	if err := a.Repo.SetContactReadReceipts(a.Ctx, contactID, enabled); err != nil {
+		return err
+	}
+	a.Emitter.Emit("contact_updated")
+	return nil
+}
+
+// sendDeliveryReceipt сообщает отправителю, что сообщение сохранено
+func (a *AppCore) sendDeliveryReceipt(contact *core.Contact, msg *core.Message) {
+	if a.Messenger == nil || contact == nil || contact.I2PAddress == "" || !a.peerSupports(contact, messenger.CapReceipts) {
+		return
+	}
+
+	go func(addr, chatID, msgID string) {
+		if err := a.Messenger.SendReceipt(addr, chatID, []string{msgID}, core.MessageStatusDelivered); err != nil {
+			log.Printf("[AppCore] Failed to send delivery receipt: %v", err)
+		}
+	}(contact.I2PAddress, msg.ChatID, msg.ID)
+}
+
+// markChatRead помечает чат прочитанным и отправляет отчёт о прочтении собеседнику
+func (a *AppCore) markChatRead(chatID string) error {
+	unread, err := a.Repo.ListUnreadMessageIDs(a.Ctx, chatID)
+	if err != nil {
+		log.Printf("[AppCore] Failed to list unread messages: %v", err)
+	}
+
+	if err := a.Repo.MarkChatAsRead(a.Ctx, chatID); err != nil {
+		return err
+	}
+
+	if len(unread) == 0 || a.Messenger == nil || !a.GetPrivacySettings().ReadReceipts {
+		return nil
+	}
+
+	contact := a.findContactByChatID(chatID)
+	if contact == nil || contact.I2PAddress == "" || contact.IsPending || contact.ReadReceiptsDisabled || !a.peerSupports(contact, messenger.CapReceipts) {
+		return nil
+	}
+
+	go func(addr string) {
+		if err := a.Messenger.SendReceipt(addr, chatID, unread, core.MessageStatusRead); err != nil {
+			log.Printf("[AppCore] Failed to send read receipt: %v", err)
+		}
+	}(contact.I2PAddress)
+
+	return nil
+}
+
+// findContactByChatID ищет контакт по ID чата
+func (a *AppCore) findContactByChatID(chatID string) *core.Contact {
+	return contactByChatID(a.Ctx, a.Repo, chatID)
+}
+
+func contactByChatID(ctx context.Context, repo *sqlite.Repository, chatID string) *core.Contact {
+	if repo == nil {
+		return nil
+	}
+	contacts, err := repo.ListContacts(ctx)
+	if err != nil {
+		return nil
+	}
+	for _, c := range contacts {
+		if c.ChatID == chatID {
+			return c
+		}
+	}
+	return nil
+}
+
+// onReceipt обновляет статус исходящих сообщений по отчёту собеседника
+func (a *AppCore) onReceipt(senderPubKey, _ string, messageIDs []string, status core.MessageStatus) {
+	if a.Repo == nil {
+		return
+	}
+
+	// Отчёт принимаем только для сообщений из чата с отправителем
+	expectedChatID := identity.CalculateChatID(a.Identity.Keys.PublicKeyBase64, senderPubKey)
+
+	for _, id := range messageIDs {
+		msg, err := a.Repo.GetMessage(a.Ctx, id)
+		if err != nil || msg == nil {
+			continue
+		}
+		if !msg.IsOutgoing || msg.ChatID != expectedChatID {
+			log.Printf("[AppCore] Ignoring receipt for foreign message %s", id)
+			continue
+		}
+		if !statusAdvances(msg.Status, status) {
+			continue
+		}
+
+		if err := a.Repo.UpdateMessageStatus(a.Ctx, id, status); err != nil {
+			log.Printf("[AppCore] Failed to update message status: %v", err)
+			continue
+		}
+
+		a.emitMessageStatus(msg.ChatID, id, status)
+	}
+}
+
+// statusAdvances проверяет, что новый статус не откатывает сообщение назад
+// (Read не сменится на Delivered, а Failed можно исправить отчётом)
+func statusAdvances(current, next core.MessageStatus) bool {
+	if current == core.MessageStatusFailed {
+		return true
+	}
+	return next > current
+}
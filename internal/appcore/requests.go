@@ -0,0 +1,140 @@
+package appcore
+
+import (
+	"fmt"
+	"log"
+
+	"teleghost/internal/core"
+	"teleghost/internal/network/messenger"
+)
+
+// Запросы переписки: незнакомец, написавший первым, попадает не в контакты, а во входящие запросы.
+// Пока запрос не принят, мы ему ничего не отправляем (ни handshake, ни профиль, ни отчёты),
+// а его сообщения лежат в карантине без уведомлений.
+
+// mayReply — можно ли отвечать собеседнику без действий пользователя
+func (a *AppCore) mayReply(pubKey, addr string) bool {
+	if a.Repo == nil {
+		return false
+	}
+	if contact := a.lookupContact(pubKey, addr); contact != nil {
+		return !contact.IsPending && !contact.IsBlocked
+	}
+	// Участники наших групп и каналов — не незнакомцы
+	return a.isRelatedPeer(pubKey)
+}
+
+// emitMessageRequest сообщает фронтенду о новом запросе или сообщении в карантине
+func (a *AppCore) emitMessageRequest(contact *core.Contact) {
+	a.Emitter.Emit("message_request", map[string]interface{}{
+		"ContactID": contact.ID,
+		"ChatID":    contact.ChatID,
+		"Nickname":  contact.Nickname,
+	})
+}
+
+// GetMessageRequests возвращает непринятые запросы переписки с последним сообщением.
+func (a *AppCore) GetMessageRequests() ([]*ContactInfo, error) {
+	if a.Repo == nil {
+		return []*ContactInfo{}, nil
+	}
+
+	requests, err := a.Repo.ListContactRequests(a.Ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*ContactInfo, len(requests))
+	for i, c := range requests {
+		result[i] = a.contactInfo(c)
+	}
+	return result, nil
+}
+
+// pendingRequest возвращает непринятый запрос переписки по ID контакта
+func (a *AppCore) pendingRequest(id string) (*core.Contact, error) {
+	if a.Repo == nil {
+		return nil, fmt.Errorf("not logged in")
+	}
+	contact, err := a.Repo.GetContact(a.Ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if contact == nil || !contact.IsPending {
+		return nil, fmt.Errorf("message request not found")
+	}
+	return contact, nil
+}
+
+// AcceptMessageRequest принимает запрос: чат появляется в списке, собеседник получает наш ключ и профиль.
+func (a *AppCore) AcceptMessageRequest(id string) error {
+	contact, err := a.pendingRequest(id)
+	if err != nil {
+		return err
+	}
+	if err := a.Repo.SetContactPending(a.Ctx, id, false); err != nil {
+		return err
+	}
+	contact.IsPending = false
+	a.syncLeaseSet()
+	// Почтовый сервер должен принимать письма нового контакта без штампа
+	a.mailbox.poke()
+	a.Emitter.Emit("contact_updated")
+	go a.UpdateUnreadCount()
+
+	if a.Messenger == nil || contact.I2PAddress == "" {
+		return nil
+	}
+	// Сессию устанавливаем сами: на рукопожатия незнакомца мы не отвечали
+	unread, _ := a.Repo.ListUnreadMessageIDs(a.Ctx, contact.ChatID)
+	go func(contact *core.Contact) {
+		if err := a.Messenger.SendHandshake(contact.I2PAddress); err != nil {
+			log.Printf("[AppCore] Failed to send handshake: %v", err)
+			return
+		}
+		if err := a.Messenger.SendProfileRequest(contact.I2PAddress); err != nil {
+			log.Printf("[AppCore] Failed to send profile request: %v", err)
+		}
+		// Сообщения из карантина теперь доставлены
+		if len(unread) > 0 && a.peerSupports(contact, messenger.CapReceipts) {
+			if err := a.Messenger.SendReceipt(contact.I2PAddress, contact.ChatID, unread, core.MessageStatusDelivered); err != nil {
+				log.Printf("[AppCore] Failed to send delivery receipt: %v", err)
+			}
+		}
+	}(contact)
+	return nil
+}
+
+// DeclineMessageRequest отклоняет запрос: контакт и сообщения из карантина удаляются.
+// Собеседник ничего не узнаёт и может написать снова.
+func (a *AppCore) DeclineMessageRequest(id string) error {
+	contact, err := a.pendingRequest(id)
+	if err != nil {
+		return err
+	}
+	if err := a.Repo.DeleteChatMessages(a.Ctx, contact.ChatID); err != nil {
+		return err
+	}
+	return a.DeleteContact(id)
+}
+
+// BlockMessageRequest отклоняет запрос и блокирует отправителя.
+func (a *AppCore) BlockMessageRequest(id string) error {
+	contact, err := a.pendingRequest(id)
+	if err != nil {
+		return err
+	}
+	if err := a.Repo.DeleteChatMessages(a.Ctx, contact.ChatID); err != nil {
+		return err
+	}
+	if err := a.Repo.BlockContact(a.Ctx, id, true); err != nil {
+		return err
+	}
+	a.syncBlockList()
+	a.syncLeaseSet()
+	if contact.PublicKey != "" && a.Messenger != nil {
+		a.Messenger.DropSessions(contact.PublicKey)
+	}
+	a.Emitter.Emit("contact_updated")
+	return nil
+}
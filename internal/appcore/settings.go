@@ -2,17 +2,25 @@ package appcore
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"time"
+
+	"teleghost/internal/network/messenger"
+	"teleghost/internal/network/router"
 )
 
-// GetMyDestination возвращает I2P адрес.
+// DrainTimeout — сколько смена профиля ждёт начатые отправки, прежде чем закрыть соединения
+const DrainTimeout = 5 * time.Second
+
+// GetMyDestination возвращает адрес для обмена: I2P адрес со статическим ключом.
 func (a *AppCore) GetMyDestination() string {
 	if a.Messenger == nil {
 		return ""
 	}
-	return a.Messenger.GetDestination()
+	return a.myContactAddress(a.Messenger.GetDestination())
 }
 
 // GetRouterSettings возвращает настройки роутера.
@@ -21,24 +29,76 @@ func (a *AppCore) GetRouterSettings() *RouterSettings {
 
 	// Значения по умолчанию
 	defaultSettings := &RouterSettings{
-		TunnelLength: 1, // Fast mode by default as requested before
-		LogToFile:    false,
+		LogToFile:       false,
+		MessageTTLHours: int(DefaultMessageTTL / time.Hour),
+
+		ClockSkewMinutes: int(messenger.DefaultClockSkew / time.Minute),
 	}
+	fast, _ := router.LookupProfile(router.ProfileFast)
+	defaultSettings.applyProfile(fast) // Fast mode by default as requested before
 
 	data, err := os.ReadFile(settingsFile)
 	if err != nil {
 		return defaultSettings
 	}
 
-	var settings RouterSettings
+	settings := *defaultSettings
+	settings.SecurityProfile = ""
 	if err := json.Unmarshal(data, &settings); err != nil {
 		log.Printf("[AppCore] Failed to parse router settings: %v", err)
 		return defaultSettings
 	}
+	// Файл старой версии: длина туннелей сохранена без профиля
+	if settings.SecurityProfile == "" {
+		settings.SecurityProfile = router.ProfileCustom
+	}
 
 	return &settings
 }
 
+// applyProfile переносит встроенный профиль защиты в настройки
+func (s *RouterSettings) applyProfile(p router.Profile) {
+	s.SecurityProfile = p.Name
+	s.TunnelLength = p.TunnelLength
+	s.TunnelQuantity = p.TunnelQuantity
+	s.TunnelVariance = p.TunnelVariance
+	s.BackupQuantity = p.BackupQuantity
+	s.LeaseSetType = p.LeaseSetType
+	s.LeaseSetAuth = p.LeaseSetAuth
+}
+
+// profile возвращает параметры туннелей из настроек
+func (s *RouterSettings) profile() router.Profile {
+	return router.Profile{
+		Name:           s.SecurityProfile,
+		TunnelLength:   s.TunnelLength,
+		TunnelQuantity: s.TunnelQuantity,
+		TunnelVariance: s.TunnelVariance,
+		BackupQuantity: s.BackupQuantity,
+		LeaseSetType:   s.LeaseSetType,
+		LeaseSetAuth:   s.LeaseSetAuth,
+	}
+}
+
+// validate проверяет параметры туннелей на пределы I2P
+func (s *RouterSettings) validate() error {
+	switch {
+	case s.TunnelLength < 0 || s.TunnelLength > 7:
+		return fmt.Errorf("invalid tunnel length %d", s.TunnelLength)
+	case s.TunnelQuantity < 1 || s.TunnelQuantity > 16:
+		return fmt.Errorf("invalid tunnel quantity %d", s.TunnelQuantity)
+	case s.TunnelVariance < -7 || s.TunnelVariance > 7:
+		return fmt.Errorf("invalid tunnel variance %d", s.TunnelVariance)
+	case s.BackupQuantity < 0 || s.BackupQuantity > 16:
+		return fmt.Errorf("invalid backup quantity %d", s.BackupQuantity)
+	case s.LeaseSetType != router.LeaseSet2 && s.LeaseSetType != router.LeaseSetEncrypted:
+		return fmt.Errorf("invalid leaseSet type %d", s.LeaseSetType)
+	case s.LeaseSetAuth && s.LeaseSetType != router.LeaseSetEncrypted:
+		return fmt.Errorf("leaseSet auth requires an encrypted leaseSet")
+	}
+	return nil
+}
+
 // GetAppAboutInfo возвращает информацию о приложении.
 func (a *AppCore) GetAppAboutInfo() *AppAboutInfo {
 	return &AppAboutInfo{
@@ -55,20 +115,88 @@ func (a *AppCore) SaveRouterSettings(settings map[string]interface{}) error {
 	settingsFile := filepath.Join(a.DataDir, "router_settings.json")
 
 	current := a.GetRouterSettings()
+	tunnels := current.profile()
 
-	if val, ok := settings["tunnelLength"].(float64); ok {
-		current.TunnelLength = int(val)
+	// Встроенный профиль задаёт туннели целиком; ручные значения делают профиль custom
+	name, _ := settings["securityProfile"].(string)
+	if p, ok := router.LookupProfile(name); ok {
+		current.applyProfile(p)
+	} else {
+		for key, field := range map[string]*int{
+			"tunnelLength":   &current.TunnelLength,
+			"tunnelQuantity": &current.TunnelQuantity,
+			"tunnelVariance": &current.TunnelVariance,
+			"backupQuantity": &current.BackupQuantity,
+			"leaseSetType":   &current.LeaseSetType,
+		} {
+			if val, ok := settings[key].(float64); ok && int(val) != *field {
+				*field = int(val)
+				current.SecurityProfile = router.ProfileCustom
+			}
+		}
+		if val, ok := settings["leaseSetAuth"].(bool); ok && val != current.LeaseSetAuth {
+			current.LeaseSetAuth = val
+			current.SecurityProfile = router.ProfileCustom
+		}
+	}
+	if err := current.validate(); err != nil {
+		return err
 	}
 	if val, ok := settings["logToFile"].(bool); ok {
 		current.LogToFile = val
 	}
+	if val, ok := settings["messageTTLHours"].(float64); ok && val > 0 {
+		current.MessageTTLHours = int(val)
+	}
+	if val, ok := settings["clockSkewMinutes"].(float64); ok && val > 0 {
+		current.ClockSkewMinutes = int(val)
+		if a.Messenger != nil {
+			a.Messenger.SetClockSkew(time.Duration(current.ClockSkewMinutes) * time.Minute)
+		}
+	}
 
 	data, err := json.MarshalIndent(current, "", "  ")
 	if err != nil {
 		return err
 	}
+	if err := os.WriteFile(settingsFile, data, 0600); err != nil {
+		return err
+	}
+
+	if changed := current.profile(); changed != tunnels {
+		a.applyTunnelSettings(changed)
+	}
+	return nil
+}
+
+// applyTunnelSettings перестраивает SAM сессию под новые туннели, не меняя адрес. Сначала
+// даём начатым отправкам дописать пакеты: соединения старой сессии умрут вместе с ней.
+func (a *AppCore) applyTunnelSettings(p router.Profile) {
+	r := a.Router
+	if r == nil {
+		// Не в сети или другой транспорт: параметры применятся при следующем подключении
+		return
+	}
+	log.Printf("[AppCore] Applying security profile %s", p.Name)
 
-	return os.WriteFile(settingsFile, data, 0600)
+	cfg := router.DefaultConfig()
+	p.Apply(cfg)
+	if m := a.Messenger; m != nil {
+		m.DrainConnections(DrainTimeout)
+	}
+	r.Reconfigure(cfg)
+	if a.updateLeaseSetInfo() {
+		a.announceLeaseSet.Store(true)
+	}
+}
+
+// GetDiagnostics возвращает счётчики входящих пакетов (в т.ч. отклонённых).
+func (a *AppCore) GetDiagnostics() *messenger.Diagnostics {
+	if a.Messenger == nil {
+		return &messenger.Diagnostics{}
+	}
+	d := a.Messenger.Diagnostics()
+	return &d
 }
 
 // GetNetworkStatus возвращает текущий статус сети
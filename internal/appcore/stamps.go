@@ -0,0 +1,70 @@
+package appcore
+
+import (
+	"teleghost/internal/core"
+	"teleghost/internal/network/envelope"
+	pb "teleghost/internal/proto"
+)
+
+// stampAlgorithms — алгоритмы штампов в настройках приватности ("" — по умолчанию)
+var stampAlgorithms = map[string]pb.StampAlgorithm{
+	"":       pb.StampAlgorithm_STAMP_SHA256,
+	"sha256": pb.StampAlgorithm_STAMP_SHA256,
+	"argon2": pb.StampAlgorithm_STAMP_ARGON2,
+}
+
+// stampPolicy собирает политику штампов из настроек приватности
+func (a *AppCore) stampPolicy() envelope.StampPolicy {
+	settings := a.GetPrivacySettings()
+	policy := envelope.DefaultStampPolicy()
+	if alg, ok := stampAlgorithms[settings.StampAlgorithm]; ok {
+		policy.Algorithm = alg
+		policy.Bits = 0
+	}
+	if settings.StampDifficulty > 0 {
+		policy.Bits = uint32(settings.StampDifficulty) // #nosec G115
+	}
+	return policy
+}
+
+// isKnownPeer — отправитель есть в принятых контактах, в наших группах или каналах.
+// Остальные (и незнакомцы из запросов) должны приложить proof-of-work штамп, иначе пакет не дойдёт до базы.
+func (a *AppCore) isKnownPeer(pubKey, addr string) bool {
+	if a.Repo == nil {
+		return false
+	}
+	if contact := a.lookupContact(pubKey, addr); contact != nil && !contact.IsPending {
+		return true
+	}
+	return a.isRelatedPeer(pubKey)
+}
+
+// isRelatedPeer — собеседник состоит с нами в группе или связан каналом
+func (a *AppCore) isRelatedPeer(pubKey string) bool {
+	if a.Repo == nil || pubKey == "" {
+		return false
+	}
+	if ids, _ := a.Repo.ListGroupIDsByMember(a.Ctx, pubKey); len(ids) > 0 {
+		return true
+	}
+	if owned, _ := a.Repo.ListChannelsByOwner(a.Ctx, pubKey); len(owned) > 0 {
+		return true
+	}
+	subscriber, _ := a.Repo.IsChannelSubscriber(a.Ctx, pubKey)
+	return subscriber
+}
+
+// lookupContact ищет контакт по ключу, а контакт, добавленный по адресу и ещё без ключа, — по адресу
+func (a *AppCore) lookupContact(pubKey, addr string) *core.Contact {
+	if pubKey != "" {
+		if contact, _ := a.Repo.GetContactByPublicKey(a.Ctx, pubKey); contact != nil {
+			return contact
+		}
+	}
+	if addr != "" {
+		if contact, _ := a.Repo.GetContactByAddress(a.Ctx, addr); contact != nil {
+			return contact
+		}
+	}
+	return nil
+}
@@ -0,0 +1,837 @@
+package appcore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"teleghost/internal/core"
+	"teleghost/internal/core/identity"
+	"teleghost/internal/network/media"
+	"teleghost/internal/network/messenger"
+	pb "teleghost/internal/proto"
+	"teleghost/internal/repository/sqlite"
+)
+
+const (
+	// TransferStallTimeout — сколько ждём новых частей, прежде чем просить продолжить передачу
+	TransferStallTimeout = 30 * time.Second
+
+	// maxOfferFiles — максимальное число файлов в одном предложении
+	maxOfferFiles = 1000
+
+	// maxFileIDLen — ограничение на ID файла из манифеста
+	maxFileIDLen = 64
+)
+
+// transfers — состояние передач файлов в памяти (в БД хранится прогресс)
+type transfers struct {
+	// state сериализует чтение и обновление состояния передач
+	state sync.Mutex
+
+	mu        sync.Mutex
+	uploading map[string]bool      // messageID -> идёт отправка частей
+	lastSeen  map[string]time.Time // messageID -> последняя часть или подтверждение
+	checked   map[string]time.Time // chatID -> последняя проверка зависших передач
+	percent   map[string]int       // messageID/direction -> последний показанный процент
+}
+
+func newTransfers() *transfers {
+	return &transfers{
+		uploading: make(map[string]bool),
+		lastSeen:  make(map[string]time.Time),
+		checked:   make(map[string]time.Time),
+		percent:   make(map[string]int),
+	}
+}
+
+func (t *transfers) touch(messageID string) {
+	t.mu.Lock()
+	t.lastSeen[messageID] = time.Now()
+	t.mu.Unlock()
+}
+
+// stalled — давно ли по передаче не было активности
+func (t *transfers) stalled(messageID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return time.Since(t.lastSeen[messageID]) > TransferStallTimeout
+}
+
+// shouldCheck ограничивает проверку зависших передач одним разом за TransferStallTimeout на чат
+func (t *transfers) shouldCheck(chatID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if time.Since(t.checked[chatID]) < TransferStallTimeout {
+		return false
+	}
+	t.checked[chatID] = time.Now()
+	return true
+}
+
+func (t *transfers) forget(messageID string) {
+	t.mu.Lock()
+	delete(t.lastSeen, messageID)
+	delete(t.percent, messageID+"/"+string(core.TransferIncoming))
+	delete(t.percent, messageID+"/"+string(core.TransferOutgoing))
+	t.mu.Unlock()
+}
+
+// buildFileManifest считает SHA-256 файла потоково и формирует манифест
+func buildFileManifest(fileID, path, filename string) (*pb.FileManifest, error) {
+	// #nosec G304
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.FileManifest{
+		FileId:    fileID,
+		Filename:  filename,
+		MimeType:  detectMimeType(path),
+		Size:      size,
+		Sha256:    h.Sum(nil),
+		ChunkSize: messenger.FileChunkSize,
+	}, nil
+}
+
+// saveOutgoingTransfers запоминает файлы предложения до отправки, чтобы пережить перезапуск
+func (a *AppCore) saveOutgoingTransfers(chatID, messageID string, attachments []*core.Attachment, manifests []*pb.FileManifest) error {
+	for i, mf := range manifests {
+		t := &core.FileTransfer{
+			MessageID: messageID,
+			FileID:    mf.FileId,
+			Direction: core.TransferOutgoing,
+			ChatID:    chatID,
+			Filename:  mf.Filename,
+			MimeType:  mf.MimeType,
+			LocalPath: attachments[i].LocalPath,
+			Size:      mf.Size,
+			SHA256:    mf.Sha256,
+			ChunkSize: mf.ChunkSize,
+			Status:    core.TransferOffered,
+		}
+		if err := a.Repo.SaveFileTransfer(a.Ctx, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveIncomingTransfers проверяет манифест предложения и сохраняет файлы для приёма
+func (a *AppCore) saveIncomingTransfers(chatID, messageID string, files []*pb.FileManifest) ([]*core.Attachment, error) {
+	if len(files) > maxOfferFiles {
+		return nil, fmt.Errorf("too many files: %d", len(files))
+	}
+
+	existing, err := a.Repo.ListFileTransfers(a.Ctx, messageID, core.TransferIncoming)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(files))
+	incoming := make([]*core.FileTransfer, 0, len(files))
+	for _, mf := range files {
+		switch {
+		case mf.FileId == "" || len(mf.FileId) > maxFileIDLen || seen[mf.FileId]:
+			return nil, fmt.Errorf("bad file id %q", mf.FileId)
+		case mf.Size <= 0:
+			return nil, fmt.Errorf("bad file size %d", mf.Size)
+		case mf.ChunkSize == 0 || mf.ChunkSize > messenger.MaxFileChunkSize:
+			return nil, fmt.Errorf("bad chunk size %d", mf.ChunkSize)
+		case len(mf.Sha256) != sha256.Size:
+			return nil, fmt.Errorf("bad checksum for %s", mf.FileId)
+		}
+		seen[mf.FileId] = true
+
+		mimeType := mf.MimeType
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		incoming = append(incoming, &core.FileTransfer{
+			MessageID: messageID,
+			FileID:    mf.FileId,
+			Direction: core.TransferIncoming,
+			ChatID:    chatID,
+			Filename:  filepath.Base(mf.Filename),
+			MimeType:  mimeType,
+			Size:      mf.Size,
+			SHA256:    mf.Sha256,
+			ChunkSize: mf.ChunkSize,
+			Status:    core.TransferOffered,
+		})
+	}
+
+	// Повторное предложение не сбрасывает уже принятые части
+	if len(existing) == 0 {
+		for _, t := range incoming {
+			if err := a.Repo.SaveFileTransfer(a.Ctx, t); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	attachments := make([]*core.Attachment, 0, len(incoming))
+	for _, t := range incoming {
+		attachments = append(attachments, &core.Attachment{
+			ID:        t.FileID,
+			MessageID: messageID,
+			Filename:  t.Filename,
+			MimeType:  t.MimeType,
+			Size:      t.Size,
+		})
+	}
+	return attachments, nil
+}
+
+// acceptIncomingTransfers соглашается на приём сохранённого предложения
+func (a *AppCore) acceptIncomingTransfers(messageID string, list []*core.FileTransfer) error {
+	if a.Messenger == nil {
+		return fmt.Errorf("messenger not started")
+	}
+
+	contact := a.findContactByChatID(list[0].ChatID)
+	if contact == nil || contact.I2PAddress == "" {
+		return fmt.Errorf("contact not found")
+	}
+
+	a.transfers.state.Lock()
+	for _, t := range list {
+		if t.Status != core.TransferOffered {
+			continue
+		}
+		t.Status = core.TransferAccepted
+		if err := a.Repo.SaveFileTransfer(a.Ctx, t); err != nil {
+			a.transfers.state.Unlock()
+			return err
+		}
+	}
+	a.transfers.state.Unlock()
+
+	a.transfers.touch(messageID)
+	return a.Messenger.SendFileResponse(contact.I2PAddress, contact.ChatID, messageID, true)
+}
+
+// declineIncomingTransfers отказывается от сохранённого предложения
+func (a *AppCore) declineIncomingTransfers(messageID string, list []*core.FileTransfer) error {
+	a.transfers.state.Lock()
+	err := a.Repo.DeleteFileTransfers(a.Ctx, messageID, core.TransferIncoming)
+	a.transfers.state.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range list {
+		_ = os.RemoveAll(a.transferStageDir(t))
+	}
+	a.transfers.forget(messageID)
+
+	contact := a.findContactByChatID(list[0].ChatID)
+	if a.Messenger != nil && contact != nil && contact.I2PAddress != "" {
+		if err := a.Messenger.SendFileResponse(contact.I2PAddress, contact.ChatID, messageID, false); err != nil {
+			log.Printf("[AppCore] Failed to send file response: %v", err)
+		}
+	}
+	return nil
+}
+
+// transferFromPeer проверяет, что передача относится к чату с отправителем пакета
+func (a *AppCore) transferFromPeer(t *core.FileTransfer, senderPubKey string) bool {
+	if a.Identity == nil {
+		return false
+	}
+	return t.ChatID == identity.CalculateChatID(a.Identity.Keys.PublicKeyBase64, senderPubKey)
+}
+
+func findTransfer(list []*core.FileTransfer, fileID string) *core.FileTransfer {
+	for _, t := range list {
+		if t.FileID == fileID {
+			return t
+		}
+	}
+	return nil
+}
+
+func allTransfersComplete(list []*core.FileTransfer) bool {
+	for _, t := range list {
+		if t.Status != core.TransferComplete {
+			return false
+		}
+	}
+	return len(list) > 0
+}
+
+// onFileResponseChunked обрабатывает ответ на предложение, отправленное частями
+func (a *AppCore) onFileResponseChunked(senderPubKey, messageID string, list []*core.FileTransfer, accepted bool) {
+	if !a.transferFromPeer(list[0], senderPubKey) {
+		log.Printf("[AppCore] Ignoring file response for foreign transfer %s", messageID)
+		return
+	}
+
+	if !accepted {
+		log.Printf("[AppCore] File transfer %s declined", messageID)
+		if err := a.Repo.DeleteFileTransfers(a.Ctx, messageID, core.TransferOutgoing); err != nil {
+			log.Printf("[AppCore] Failed to delete file transfers: %v", err)
+		}
+		a.transfers.forget(messageID)
+		return
+	}
+
+	a.transfers.state.Lock()
+	for _, t := range list {
+		if t.Status != core.TransferOffered {
+			continue
+		}
+		t.Status = core.TransferAccepted
+		if err := a.Repo.SaveFileTransfer(a.Ctx, t); err != nil {
+			log.Printf("[AppCore] Failed to save file transfer: %v", err)
+		}
+	}
+	a.transfers.state.Unlock()
+
+	// Старые сборки не знают FILE_CHUNK — отправляем файлы одним сообщением, как раньше
+	if a.Messenger != nil && !a.Messenger.PeerSupports(senderPubKey, messenger.CapChunkedFiles) {
+		go a.uploadLegacy(a.Repo, a.Messenger, messageID, list)
+		return
+	}
+
+	a.startUpload(messageID, nil)
+}
+
+// uploadLegacy отправляет принятые файлы вложениями в одном TextMessage
+func (a *AppCore) uploadLegacy(repo *sqlite.Repository, m *messenger.Service, messageID string, list []*core.FileTransfer) {
+	contact := contactByChatID(a.Ctx, repo, list[0].ChatID)
+	if contact == nil || contact.I2PAddress == "" {
+		return
+	}
+
+	attachments := make([]*pb.Attachment, 0, len(list))
+	for _, t := range list {
+		data, err := os.ReadFile(t.LocalPath)
+		if err != nil {
+			log.Printf("[AppCore] Failed to read file during transfer: %v", err)
+			continue
+		}
+		attachments = append(attachments, &pb.Attachment{
+			Id:       t.FileID,
+			Filename: t.Filename,
+			MimeType: t.MimeType,
+			Size:     int64(len(data)),
+			Data:     data,
+		})
+	}
+
+	log.Printf("[AppCore] Peer does not support chunked files, sending %s inline", messageID)
+	if err := m.SendAttachmentMessageWithID(contact.I2PAddress, contact.ChatID, messageID, "", "", attachments); err != nil {
+		log.Printf("[AppCore] Failed to send attachment message: %v", err)
+		return
+	}
+	if err := repo.DeleteFileTransfers(a.Ctx, messageID, core.TransferOutgoing); err != nil {
+		log.Printf("[AppCore] Failed to delete file transfers: %v", err)
+	}
+}
+
+// onFileResume продолжает отправку с частей, которые просит получатель
+func (a *AppCore) onFileResume(senderPubKey, messageID string, nextIndex map[string]uint32) {
+	repo := a.Repo
+	if repo == nil {
+		return
+	}
+
+	list, err := repo.ListFileTransfers(a.Ctx, messageID, core.TransferOutgoing)
+	if err != nil || len(list) == 0 {
+		return
+	}
+	if !a.transferFromPeer(list[0], senderPubKey) {
+		log.Printf("[AppCore] Ignoring resume request for foreign transfer %s", messageID)
+		return
+	}
+
+	a.startUpload(messageID, nextIndex)
+}
+
+// startUpload запускает отправку частей (не более одной на сообщение)
+func (a *AppCore) startUpload(messageID string, from map[string]uint32) {
+	repo, m := a.Repo, a.Messenger
+	if repo == nil || m == nil {
+		return
+	}
+
+	a.transfers.mu.Lock()
+	if a.transfers.uploading[messageID] {
+		a.transfers.mu.Unlock()
+		return
+	}
+	a.transfers.uploading[messageID] = true
+	a.transfers.lastSeen[messageID] = time.Now()
+	a.transfers.mu.Unlock()
+
+	go func() {
+		defer func() {
+			a.transfers.mu.Lock()
+			delete(a.transfers.uploading, messageID)
+			a.transfers.mu.Unlock()
+		}()
+
+		if err := a.uploadFiles(a.Ctx, repo, m, messageID, from); err != nil {
+			log.Printf("[AppCore] Upload of %s interrupted: %v", messageID, err)
+		}
+	}()
+}
+
+func (a *AppCore) uploadFiles(ctx context.Context, repo *sqlite.Repository, m *messenger.Service, messageID string, from map[string]uint32) error {
+	list, err := repo.ListFileTransfers(ctx, messageID, core.TransferOutgoing)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return nil
+	}
+
+	contact := contactByChatID(ctx, repo, list[0].ChatID)
+	if contact == nil || contact.I2PAddress == "" {
+		return fmt.Errorf("contact not found")
+	}
+
+	// Новые сборки принимают файл потоком в отдельном соединении, старые — пакетами FILE_CHUNK
+	streamed := m.PeerSupports(contact.PublicKey, messenger.CapStreamedFiles)
+	for _, t := range list {
+		if t.Status != core.TransferAccepted {
+			continue
+		}
+
+		// Получатель лучше знает, какие части у него есть
+		start := t.NextIndex
+		if idx, ok := from[t.FileID]; ok && idx <= t.ChunkCount() {
+			start = idx
+		}
+
+		upload := uploadFile
+		if streamed {
+			upload = streamFile
+		}
+		if err := upload(ctx, m, contact.I2PAddress, t, start); err != nil {
+			if os.IsNotExist(err) {
+				log.Printf("[AppCore] Source file for %s is gone, giving up", t.FileID)
+				t.Status = core.TransferFailed
+				if errSave := repo.SaveFileTransfer(ctx, t); errSave != nil {
+					log.Printf("[AppCore] Failed to save file transfer: %v", errSave)
+				}
+				a.emitTransferProgress(repo, messageID, core.TransferOutgoing)
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadFile отправляет части файла начиная с start
+func uploadFile(ctx context.Context, m *messenger.Service, destination string, t *core.FileTransfer, start uint32) error {
+	f, err := os.Open(t.LocalPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, t.ChunkSize)
+	for idx := start; idx < t.ChunkCount(); idx++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		n := t.ChunkLen(idx)
+		if _, err := f.ReadAt(buf[:n], int64(idx)*int64(t.ChunkSize)); err != nil {
+			return fmt.Errorf("read chunk %d: %w", idx, err)
+		}
+		if err := m.SendFileChunk(destination, t.ChatID, t.MessageID, t.FileID, idx, buf[:n]); err != nil {
+			return fmt.Errorf("send chunk %d: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+// streamFile отправляет части файла начиная с start одним потоком: с диска в сокет по части
+func streamFile(ctx context.Context, m *messenger.Service, destination string, t *core.FileTransfer, start uint32) error {
+	if start >= t.ChunkCount() {
+		return nil
+	}
+
+	f, err := os.Open(t.LocalPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stream, err := m.OpenFileStream(destination, t.ChatID, t.MessageID, t.FileID, start, t.ChunkCount()-start)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	buf := make([]byte, t.ChunkSize)
+	for idx := start; idx < t.ChunkCount(); idx++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		n := t.ChunkLen(idx)
+		if _, err := f.ReadAt(buf[:n], int64(idx)*int64(t.ChunkSize)); err != nil {
+			return fmt.Errorf("read chunk %d: %w", idx, err)
+		}
+		if err := stream.WriteChunk(buf[:n]); err != nil {
+			return fmt.Errorf("send chunk %d: %w", idx, err)
+		}
+	}
+	return stream.Close()
+}
+
+// onFileChunkAck сохраняет подтверждённый получателем прогресс
+func (a *AppCore) onFileChunkAck(senderPubKey, messageID, fileID string, nextIndex uint32) {
+	repo := a.Repo
+	if repo == nil {
+		return
+	}
+
+	a.transfers.state.Lock()
+	defer a.transfers.state.Unlock()
+
+	list, err := repo.ListFileTransfers(a.Ctx, messageID, core.TransferOutgoing)
+	if err != nil {
+		return
+	}
+	t := findTransfer(list, fileID)
+	if t == nil || t.Status != core.TransferAccepted || !a.transferFromPeer(t, senderPubKey) {
+		return
+	}
+	if nextIndex <= t.NextIndex || nextIndex > t.ChunkCount() {
+		return
+	}
+
+	a.transfers.touch(messageID)
+	t.NextIndex = nextIndex
+	if nextIndex == t.ChunkCount() {
+		t.Status = core.TransferComplete
+		err = repo.SaveFileTransfer(a.Ctx, t)
+	} else {
+		err = repo.UpdateFileTransferProgress(a.Ctx, messageID, fileID, core.TransferOutgoing, nextIndex)
+	}
+	if err != nil {
+		log.Printf("[AppCore] Failed to save transfer progress: %v", err)
+		return
+	}
+
+	a.emitProgress(messageID, core.TransferOutgoing, list)
+
+	if allTransfersComplete(list) {
+		log.Printf("[AppCore] File transfer %s delivered", messageID)
+		if err := repo.DeleteFileTransfers(a.Ctx, messageID, core.TransferOutgoing); err != nil {
+			log.Printf("[AppCore] Failed to delete file transfers: %v", err)
+		}
+		a.transfers.forget(messageID)
+	}
+}
+
+// onFileChunk сохраняет очередную часть файла в зашифрованном виде
+func (a *AppCore) onFileChunk(senderPubKey, messageID, fileID string, index uint32, data []byte) {
+	repo := a.Repo
+	if repo == nil {
+		return
+	}
+
+	a.transfers.state.Lock()
+	defer a.transfers.state.Unlock()
+
+	list, err := repo.ListFileTransfers(a.Ctx, messageID, core.TransferIncoming)
+	if err != nil {
+		return
+	}
+	t := findTransfer(list, fileID)
+	if t == nil || t.Status != core.TransferAccepted || !a.transferFromPeer(t, senderPubKey) {
+		return
+	}
+
+	if index != t.NextIndex {
+		// Повтор уже принятой части — напоминаем отправителю, где мы остановились
+		if index < t.NextIndex {
+			a.sendChunkAck(senderPubKey, t)
+		}
+		return
+	}
+	if index >= t.ChunkCount() || len(data) != t.ChunkLen(index) {
+		log.Printf("[AppCore] Rejected chunk %d of %s: bad size %d", index, fileID, len(data))
+		return
+	}
+
+	mc, err := a.mediaCrypt()
+	if err != nil {
+		log.Printf("[AppCore] Failed to init media crypt: %v", err)
+		return
+	}
+	if err := mc.SaveEncrypted(a.transferChunkPath(t, index), data); err != nil {
+		log.Printf("[AppCore] Failed to stage chunk: %v", err)
+		return
+	}
+
+	t.NextIndex++
+	if err := repo.UpdateFileTransferProgress(a.Ctx, messageID, fileID, core.TransferIncoming, t.NextIndex); err != nil {
+		log.Printf("[AppCore] Failed to save transfer progress: %v", err)
+		return
+	}
+	a.transfers.touch(messageID)
+
+	if t.NextIndex == t.ChunkCount() {
+		if err := a.assembleFile(repo, mc, t); err != nil {
+			log.Printf("[AppCore] Failed to assemble %s: %v", t.Filename, err)
+		}
+	}
+	if t.Status != core.TransferFailed {
+		a.sendChunkAck(senderPubKey, t)
+	}
+
+	a.emitProgress(messageID, core.TransferIncoming, list)
+
+	if allTransfersComplete(list) {
+		a.finishIncoming(repo, messageID, senderPubKey, list)
+	}
+}
+
+func (a *AppCore) sendChunkAck(senderPubKey string, t *core.FileTransfer) {
+	m, repo := a.Messenger, a.Repo
+	if m == nil || repo == nil {
+		return
+	}
+	contact, err := repo.GetContactByPublicKey(a.Ctx, senderPubKey)
+	if err != nil || contact == nil || contact.I2PAddress == "" {
+		return
+	}
+
+	go func(addr, messageID, fileID string, next uint32) {
+		if err := m.SendFileChunkAck(addr, messageID, fileID, next); err != nil {
+			log.Printf("[AppCore] Failed to send chunk ack: %v", err)
+		}
+	}(contact.I2PAddress, t.MessageID, t.FileID, t.NextIndex)
+}
+
+// assembleFile собирает файл из частей и сверяет SHA-256 с манифестом
+func (a *AppCore) assembleFile(repo *sqlite.Repository, mc *media.MediaCrypt, t *core.FileTransfer) error {
+	defer os.RemoveAll(a.transferStageDir(t))
+
+	path, err := a.newMediaPath(t.Filename)
+	if err != nil {
+		return err
+	}
+
+	sum, err := a.writeChunks(mc, t, path)
+	if err == nil && !bytes.Equal(sum, t.SHA256) {
+		err = fmt.Errorf("checksum mismatch")
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		t.Status = core.TransferFailed
+		if errSave := repo.SaveFileTransfer(a.Ctx, t); errSave != nil {
+			log.Printf("[AppCore] Failed to save file transfer: %v", errSave)
+		}
+		return err
+	}
+
+	t.LocalPath = path
+	t.Status = core.TransferComplete
+	return repo.SaveFileTransfer(a.Ctx, t)
+}
+
+func (a *AppCore) writeChunks(mc *media.MediaCrypt, t *core.FileTransfer, path string) ([]byte, error) {
+	// #nosec G304
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	w := io.MultiWriter(f, h)
+	for i := uint32(0); i < t.ChunkCount(); i++ {
+		data, err := mc.LoadEncrypted(a.transferChunkPath(t, i))
+		if err != nil {
+			return nil, fmt.Errorf("load chunk %d: %w", i, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+	}
+
+	return h.Sum(nil), f.Sync()
+}
+
+// finishIncoming превращает предложение в обычное сообщение с вложениями
+func (a *AppCore) finishIncoming(repo *sqlite.Repository, messageID, senderPubKey string, list []*core.FileTransfer) {
+	msg, err := repo.GetMessage(a.Ctx, messageID)
+	if err != nil || msg == nil {
+		log.Printf("[AppCore] Message %s for finished transfer not found", messageID)
+		return
+	}
+
+	attachments := make([]*core.Attachment, 0, len(list))
+	for _, t := range list {
+		attachments = append(attachments, &core.Attachment{
+			ID:        t.FileID,
+			MessageID: messageID,
+			Filename:  t.Filename,
+			MimeType:  t.MimeType,
+			Size:      t.Size,
+			LocalPath: t.LocalPath,
+		})
+	}
+	msg.ContentType = "mixed"
+	msg.Attachments = attachments
+	msg.UpdatedAt = time.Now()
+
+	if err := repo.DeleteFileTransfers(a.Ctx, messageID, core.TransferIncoming); err != nil {
+		log.Printf("[AppCore] Failed to delete file transfers: %v", err)
+	}
+	a.transfers.forget(messageID)
+
+	var senderAddr string
+	if contact, _ := repo.GetContactByPublicKey(a.Ctx, senderPubKey); contact != nil {
+		senderAddr = contact.I2PAddress
+	}
+
+	log.Printf("[AppCore] File transfer %s received (%d files)", messageID, len(list))
+	a.OnMessageReceived(msg, senderPubKey, senderAddr)
+}
+
+// resumeTransfers продолжает зависшие передачи (chatID == "" — во всех чатах)
+func (a *AppCore) resumeTransfers(chatID string) {
+	repo, m := a.Repo, a.Messenger
+	if repo == nil || m == nil {
+		return
+	}
+
+	list, err := repo.ListActiveFileTransfers(a.Ctx)
+	if err != nil {
+		log.Printf("[AppCore] Failed to list file transfers: %v", err)
+		return
+	}
+
+	incoming := make(map[string]map[string]uint32)
+	chats := make(map[string]string)
+	for _, t := range list {
+		if chatID != "" && t.ChatID != chatID {
+			continue
+		}
+		if !a.transfers.stalled(t.MessageID) {
+			continue
+		}
+
+		if t.Direction == core.TransferOutgoing {
+			a.startUpload(t.MessageID, nil)
+			continue
+		}
+		if incoming[t.MessageID] == nil {
+			incoming[t.MessageID] = make(map[string]uint32)
+		}
+		incoming[t.MessageID][t.FileID] = t.NextIndex
+		chats[t.MessageID] = t.ChatID
+	}
+
+	for messageID, next := range incoming {
+		contact := contactByChatID(a.Ctx, repo, chats[messageID])
+		if contact == nil || contact.I2PAddress == "" {
+			continue
+		}
+		a.transfers.touch(messageID)
+		go func(addr, chatID, messageID string, next map[string]uint32) {
+			if err := m.SendFileResume(addr, chatID, messageID, next); err != nil {
+				log.Printf("[AppCore] Failed to request resume: %v", err)
+			}
+		}(contact.I2PAddress, chats[messageID], messageID, next)
+	}
+}
+
+// resumeStalledTransfers вызывается при активности собеседника
+func (a *AppCore) resumeStalledTransfers(chatID string) {
+	if !a.transfers.shouldCheck(chatID) {
+		return
+	}
+	a.resumeTransfers(chatID)
+}
+
+// emitTransferProgress перечитывает состояние из БД и сообщает прогресс
+func (a *AppCore) emitTransferProgress(repo *sqlite.Repository, messageID string, direction core.TransferDirection) {
+	list, err := repo.ListFileTransfers(a.Ctx, messageID, direction)
+	if err != nil || len(list) == 0 {
+		return
+	}
+	a.emitProgress(messageID, direction, list)
+}
+
+// emitProgress отправляет событие file_progress не чаще раза на процент
+func (a *AppCore) emitProgress(messageID string, direction core.TransferDirection, list []*core.FileTransfer) {
+	var transferred, total int64
+	status := core.TransferComplete
+	for _, t := range list {
+		transferred += t.Transferred()
+		total += t.Size
+		switch {
+		case t.Status == core.TransferFailed:
+			status = core.TransferFailed
+		case t.Status != core.TransferComplete && status != core.TransferFailed:
+			status = t.Status
+		}
+	}
+
+	percent := 100
+	if total > 0 {
+		percent = int(transferred * 100 / total)
+	}
+
+	key := messageID + "/" + string(direction)
+	a.transfers.mu.Lock()
+	last, ok := a.transfers.percent[key]
+	a.transfers.percent[key] = percent
+	a.transfers.mu.Unlock()
+	if ok && last == percent && status == core.TransferAccepted {
+		return
+	}
+
+	a.Emitter.Emit("file_progress", map[string]interface{}{
+		"MessageID":   messageID,
+		"ChatID":      list[0].ChatID,
+		"Direction":   string(direction),
+		"Transferred": transferred,
+		"Total":       total,
+		"Status":      string(status),
+	})
+}
+
+func (a *AppCore) mediaCrypt() (*media.MediaCrypt, error) {
+	if a.Identity == nil {
+		return nil, fmt.Errorf("user not logged in")
+	}
+	return media.NewMediaCrypt(a.Identity.Keys.EncryptionKey)
+}
+
+// transferStageDir — каталог с частями файла (ID из сети в путь не попадают)
+func (a *AppCore) transferStageDir(t *core.FileTransfer) string {
+	key := sha256.Sum256([]byte(t.MessageID + "/" + t.FileID))
+	return filepath.Join(a.DataDir, "users", a.Identity.Keys.UserID, "transfers", hex.EncodeToString(key[:16]))
+}
+
+func (a *AppCore) transferChunkPath(t *core.FileTransfer, index uint32) string {
+	return filepath.Join(a.transferStageDir(t), fmt.Sprintf("%d.part", index))
+}
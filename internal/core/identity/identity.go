@@ -3,6 +3,7 @@
 package identity
 
 import (
+	"crypto/ecdh"
 	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/sha256"
@@ -204,6 +205,26 @@ func (k *Keys) Fingerprint() string {
 		hash[0:2], hash[2:4], hash[4:6], hash[6:8])
 }
 
+// LeaseSetAuthKey возвращает X25519 ключ для зашифрованных leaseSet I2P. Публичную часть
+// контакты получают в handshake и открывают нам по ней свой leaseSet.
+func (k *Keys) LeaseSetAuthKey() (*ecdh.PrivateKey, error) {
+	seed, err := deriveKey(k.SigningPrivateKey.Seed(), "teleghost-leaseset-auth-key-v1", 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive leaseSet auth key: %w", err)
+	}
+	return ecdh.X25519().NewPrivateKey(seed)
+}
+
+// IntroKey возвращает статический X25519 ключ для первого сообщения. Публичная часть входит
+// в адрес, которым мы делимся: по ней незнакомец запечатывает сообщение, не дожидаясь ответа.
+func (k *Keys) IntroKey() (*ecdh.PrivateKey, error) {
+	seed, err := deriveKey(k.SigningPrivateKey.Seed(), "teleghost-intro-key-v1", 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive intro key: %w", err)
+	}
+	return ecdh.X25519().NewPrivateKey(seed)
+}
+
 // ValidateMnemonic проверяет валидность мнемонической фразы
 func ValidateMnemonic(mnemonic string) bool {
 	return bip39.IsMnemonicValid(mnemonic)
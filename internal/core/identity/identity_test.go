@@ -147,3 +147,127 @@ func TestFingerprint(t *testing.T) {
 
 	t.Logf("Fingerprint: %s", fingerprint)
 }
+
+func TestLeaseSetAuthKey(t *testing.T) {
+	identity, err := GenerateNewIdentity()
+	if err != nil {
+		t.Fatalf("GenerateNewIdentity failed: %v", err)
+	}
+	key, err := identity.Keys.LeaseSetAuthKey()
+	if err != nil {
+		t.Fatalf("LeaseSetAuthKey failed: %v", err)
+	}
+
+	// Ключ восстанавливается из мнемоники и не совпадает с ключом подписи
+	recovered, _ := RecoverKeys(identity.Mnemonic)
+	again, _ := recovered.LeaseSetAuthKey()
+	if !key.Equal(again) {
+		t.Error("LeaseSetAuthKey is not deterministic")
+	}
+	if bytes.Equal(key.Bytes(), identity.Keys.SigningPrivateKey.Seed()) {
+		t.Error("LeaseSetAuthKey reuses the signing seed")
+	}
+
+	other, _ := GenerateNewIdentity()
+	otherKey, _ := other.Keys.LeaseSetAuthKey()
+	if key.Equal(otherKey) {
+		t.Error("Different identities share a leaseSet auth key")
+	}
+}
+
+func TestDeriveSessionKeys(t *testing.T) {
+	alice, err := GenerateNewIdentity()
+	if err != nil {
+		t.Fatalf("GenerateNewIdentity failed: %v", err)
+	}
+	bob, err := GenerateNewIdentity()
+	if err != nil {
+		t.Fatalf("GenerateNewIdentity failed: %v", err)
+	}
+
+	aliceEph, err := GenerateEphemeralKey()
+	if err != nil {
+		t.Fatalf("GenerateEphemeralKey failed: %v", err)
+	}
+	bobEph, err := GenerateEphemeralKey()
+	if err != nil {
+		t.Fatalf("GenerateEphemeralKey failed: %v", err)
+	}
+
+	// Обе стороны выводят ключи из своего эфемерного ключа и ключа собеседника
+	aliceSess, err := DeriveSessionKeys(aliceEph, bobEph.PublicKey().Bytes(), alice.Keys.PublicKeyBase64, bob.Keys.PublicKeyBase64)
+	if err != nil {
+		t.Fatalf("DeriveSessionKeys failed (alice): %v", err)
+	}
+	bobSess, err := DeriveSessionKeys(bobEph, aliceEph.PublicKey().Bytes(), bob.Keys.PublicKeyBase64, alice.Keys.PublicKeyBase64)
+	if err != nil {
+		t.Fatalf("DeriveSessionKeys failed (bob): %v", err)
+	}
+
+	if !bytes.Equal(aliceSess.ID, bobSess.ID) {
+		t.Error("Session IDs do not match")
+	}
+	if !bytes.Equal(aliceSess.SendKey, bobSess.RecvKey) || !bytes.Equal(aliceSess.RecvKey, bobSess.SendKey) {
+		t.Fatal("Session keys are not mirrored")
+	}
+	if bytes.Equal(aliceSess.SendKey, aliceSess.RecvKey) {
+		t.Error("Send and receive keys must differ")
+	}
+
+	// Шифруем у Алисы, расшифровываем у Боба
+	ad := []byte("2|alice")
+	sealed, err := aliceSess.Seal([]byte("secret"), ad)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	plain, err := bobSess.Open(sealed, ad)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if string(plain) != "secret" {
+		t.Errorf("Expected 'secret', got '%s'", plain)
+	}
+
+	// Другие associated data не должны проходить
+	if _, err := bobSess.Open(sealed, []byte("3|alice")); err == nil {
+		t.Error("Open should fail for different associated data")
+	}
+
+	// Собственным ключом отправки своё сообщение не расшифровать
+	if _, err := aliceSess.Open(sealed, ad); err == nil {
+		t.Error("Open should fail with the wrong direction key")
+	}
+}
+
+func TestSealIntro(t *testing.T) {
+	bob, err := GenerateNewIdentity()
+	if err != nil {
+		t.Fatalf("GenerateNewIdentity failed: %v", err)
+	}
+	key, err := bob.Keys.IntroKey()
+	if err != nil {
+		t.Fatalf("IntroKey failed: %v", err)
+	}
+	leaseSetKey, _ := bob.Keys.LeaseSetAuthKey()
+	if key.Equal(leaseSetKey) {
+		t.Error("IntroKey reuses the leaseSet auth key")
+	}
+
+	ad := []byte("ad")
+	ephemeral, sealed, err := SealIntro(key.PublicKey().Bytes(), []byte("hello"), ad)
+	if err != nil {
+		t.Fatalf("SealIntro failed: %v", err)
+	}
+	plain, err := OpenIntro(key, ephemeral, sealed, ad)
+	if err != nil || string(plain) != "hello" {
+		t.Fatalf("OpenIntro failed: %q, %v", plain, err)
+	}
+
+	// Чужой ключ и другие associated data не открывают сообщение
+	if _, err := OpenIntro(leaseSetKey, ephemeral, sealed, ad); err == nil {
+		t.Error("Message opened with another key")
+	}
+	if _, err := OpenIntro(key, ephemeral, sealed, []byte("other")); err == nil {
+		t.Error("Message opened with different associated data")
+	}
+}
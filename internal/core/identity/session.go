@@ -0,0 +1,191 @@
+package identity
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// SessionIDSize — размер идентификатора сессии в байтах
+	SessionIDSize = 16
+
+	// sessionInfo — контекст HKDF для вывода ключей сессии
+	sessionInfo = "teleghost-session-key-v1"
+
+	// introInfo — контекст HKDF для ключа запечатанного первого сообщения
+	introInfo = "teleghost-intro-seal-v1"
+)
+
+// SessionKeys — симметричные ключи E2EE-сессии с одним контактом.
+// Получаются из X25519 обмена эфемерными ключами в Handshake.
+type SessionKeys struct {
+	// ID — идентификатор сессии (хэш обоих эфемерных ключей)
+	ID []byte `json:"id"`
+
+	// SendKey — ключ для шифрования исходящих пакетов
+	SendKey []byte `json:"send_key"`
+
+	// RecvKey — ключ для расшифровки входящих пакетов
+	RecvKey []byte `json:"recv_key"`
+
+	// RootKey — общий корневой ключ (начальное состояние Double Ratchet)
+	RootKey []byte `json:"root_key"`
+
+	// LocalEphemeral — наш эфемерный ключ из рукопожатия.
+	// Нужен только для инициализации ratchet и никогда не сохраняется.
+	LocalEphemeral *ecdh.PrivateKey `json:"-"`
+
+	// PeerEphemeral — эфемерный ключ собеседника из рукопожатия
+	PeerEphemeral []byte `json:"-"`
+
+	// CreatedAt — время установления сессии
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GenerateEphemeralKey создаёт временную X25519 пару для Handshake
+func GenerateEphemeralKey() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// DeriveSessionKeys выполняет X25519 обмен и выводит ключи сессии через HKDF.
+// myPubKey/peerPubKey — долговременные Ed25519 ключи в base64: они входят в info,
+// чтобы сессия была привязана к обоим участникам, и определяют направление ключей.
+func DeriveSessionKeys(ephemeral *ecdh.PrivateKey, peerEphemeral []byte, myPubKey, peerPubKey string) (*SessionKeys, error) {
+	if myPubKey == peerPubKey {
+		return nil, errors.New("cannot establish session with own key")
+	}
+
+	peerKey, err := ecdh.X25519().NewPublicKey(peerEphemeral)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+
+	shared, err := ephemeral.ECDH(peerKey)
+	if err != nil {
+		return nil, fmt.Errorf("ecdh failed: %w", err)
+	}
+
+	// Порядок ключей не должен зависеть от того, кто инициатор
+	myEph := ephemeral.PublicKey().Bytes()
+	firstEph, secondEph := myEph, peerEphemeral
+	if bytes.Compare(myEph, peerEphemeral) > 0 {
+		firstEph, secondEph = peerEphemeral, myEph
+	}
+	firstPub, secondPub := myPubKey, peerPubKey
+	if myPubKey > peerPubKey {
+		firstPub, secondPub = peerPubKey, myPubKey
+	}
+
+	salt := sha256.Sum256(append(append([]byte{}, firstEph...), secondEph...))
+	info := sessionInfo + "|" + firstPub + "|" + secondPub
+
+	hkdfReader := hkdf.New(sha512.New, shared, salt[:], []byte(info))
+	material := make([]byte, 3*ChaCha20KeySize)
+	if _, err := io.ReadFull(hkdfReader, material); err != nil {
+		return nil, fmt.Errorf("failed to derive session keys: %w", err)
+	}
+
+	// Первый ключ — для направления от "меньшего" публичного ключа к "большему"
+	sendKey, recvKey := material[:ChaCha20KeySize], material[ChaCha20KeySize:2*ChaCha20KeySize]
+	if myPubKey > peerPubKey {
+		sendKey, recvKey = recvKey, sendKey
+	}
+
+	return &SessionKeys{
+		ID:             salt[:SessionIDSize],
+		SendKey:        sendKey,
+		RecvKey:        recvKey,
+		RootKey:        material[2*ChaCha20KeySize:],
+		LocalEphemeral: ephemeral,
+		PeerEphemeral:  append([]byte(nil), peerEphemeral...),
+		CreatedAt:      time.Now(),
+	}, nil
+}
+
+// Seal шифрует данные ключом отправки (XChaCha20-Poly1305).
+// Формат: [24 байта Nonce][Шифротекст]
+func (s *SessionKeys) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(s.SendKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open расшифровывает данные ключом получения
+func (s *SessionKeys) Open(ciphertext, additionalData []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(s.RecvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, additionalData)
+}
+
+// SealIntro запечатывает данные на статический ключ получателя (IntroKey) одноразовым
+// эфемерным ключом. Ответ получателя не нужен, но и прямой секретности нет, поэтому
+// так отправляется только первое сообщение незнакомца. Возвращает эфемерный ключ и шифротекст.
+func SealIntro(recipientKey, plaintext, additionalData []byte) ([]byte, []byte, error) {
+	ephemeral, err := GenerateEphemeralKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := introSealKey(ephemeral, recipientKey, ephemeral.PublicKey().Bytes(), recipientKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	sealed, err := (&SessionKeys{SendKey: key}).Seal(plaintext, additionalData)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ephemeral.PublicKey().Bytes(), sealed, nil
+}
+
+// OpenIntro открывает данные, запечатанные SealIntro на наш статический ключ
+func OpenIntro(key *ecdh.PrivateKey, ephemeralPub, ciphertext, additionalData []byte) ([]byte, error) {
+	sealKey, err := introSealKey(key, ephemeralPub, ephemeralPub, key.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return (&SessionKeys{RecvKey: sealKey}).Open(ciphertext, additionalData)
+}
+
+// introSealKey выводит ключ шифрования из X25519 обмена; оба публичных ключа входят в соль
+func introSealKey(private *ecdh.PrivateKey, peerPub, ephemeralPub, staticPub []byte) ([]byte, error) {
+	peerKey, err := ecdh.X25519().NewPublicKey(peerPub)
+	if err != nil {
+		return nil, fmt.Errorf("invalid intro key: %w", err)
+	}
+	shared, err := private.ECDH(peerKey)
+	if err != nil {
+		return nil, fmt.Errorf("ecdh failed: %w", err)
+	}
+
+	salt := sha256.Sum256(append(append([]byte{}, ephemeralPub...), staticPub...))
+	key := make([]byte, ChaCha20KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha512.New, shared, salt[:], []byte(introInfo)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive intro key: %w", err)
+	}
+	return key, nil
+}
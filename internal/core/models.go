@@ -73,9 +73,42 @@ type Contact struct {
 	// IsVerified — подтверждён ли контакт (fingerprint check)
 	IsVerified bool `json:"is_verified" db:"is_verified"`
 
+	// IsPending — входящий запрос переписки от незнакомца, ещё не принятый.
+	// Пока запрос не принят, собеседнику ничего не отправляется, его сообщения в карантине.
+	IsPending bool `json:"is_pending" db:"is_pending"`
+
 	// LastSeen — время последней активности контакта
 	LastSeen *time.Time `json:"last_seen,omitempty" db:"last_seen"`
 
+	// ReadReceiptsDisabled — не отправлять этому контакту отчёты о прочтении
+	ReadReceiptsDisabled bool `json:"read_receipts_disabled" db:"read_receipts_disabled"`
+
+	// ProtocolVersion — согласованная версия протокола (0 — ещё не известна)
+	ProtocolVersion int `json:"protocol_version" db:"protocol_version"`
+
+	// Capabilities — возможности клиента контакта из handshake
+	Capabilities uint64 `json:"capabilities" db:"capabilities"`
+
+	// HidePresence — скрывать от контакта наш статус в сети (и не показывать его статус)
+	HidePresence bool `json:"hide_presence" db:"hide_presence"`
+
+	// PeerHidesPresence — контакт скрывает от нас свой статус
+	PeerHidesPresence bool `json:"peer_hides_presence" db:"peer_hides_presence"`
+
+	// MailboxAddress — почтовый сервер GhostMail контакта для доставки, пока он не в сети
+	MailboxAddress string `json:"mailbox_address" db:"mailbox_address"`
+
+	// LeaseSetAuthKey — X25519 ключ контакта из handshake (base64): с ним контакт найдёт наш
+	// зашифрованный leaseSet
+	LeaseSetAuthKey string `json:"lease_set_auth_key" db:"lease_set_auth_key"`
+
+	// BlindedAddress — b33 адрес контакта, если его leaseSet зашифрован
+	BlindedAddress string `json:"blinded_address" db:"blinded_address"`
+
+	// IntroKey — статический X25519 ключ контакта из его адреса (base64): на него запечатываем
+	// первое сообщение, пока сессии нет
+	IntroKey string `json:"intro_key" db:"intro_key"`
+
 	// AddedAt — когда контакт был добавлен
 	AddedAt time.Time `json:"added_at" db:"added_at"`
 
@@ -179,6 +212,142 @@ type Message struct {
 
 	// TotalSize — общий размер файлов (для FileOffer)
 	TotalSize int64 `json:"total_size,omitempty" db:"total_size"`
+
+	// EditedAt — время последнего редактирования (Unix ms, 0 — не редактировалось)
+	EditedAt int64 `json:"edited_at,omitempty" db:"edited_at"`
+
+	// GroupID, GroupEpoch — группа входящего сообщения и версия её состава у отправителя
+	// (не хранятся: ChatID группового сообщения равен ID группы)
+	GroupID    string `json:"group_id,omitempty" db:"-"`
+	GroupEpoch uint64 `json:"group_epoch,omitempty" db:"-"`
+}
+
+// OutboxEntry — исходящее сообщение, ожидающее доставки
+type OutboxEntry struct {
+	MessageID string `json:"message_id" db:"message_id"`
+	ChatID    string `json:"chat_id" db:"chat_id"`
+
+	// Attempts — число неудачных попыток отправки
+	Attempts int `json:"attempts" db:"attempts"`
+
+	// LastError — текст последней ошибки отправки
+	LastError string `json:"last_error,omitempty" db:"last_error"`
+
+	// LastAttemptAt — время последней попытки (Unix ms)
+	LastAttemptAt int64 `json:"last_attempt_at" db:"last_attempt_at"`
+
+	// ExpiresAt — после этого времени сообщение помечается Failed (Unix ms)
+	ExpiresAt int64 `json:"expires_at" db:"expires_at"`
+
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// MessageChangeKind — вид изменения сообщения у собеседника
+type MessageChangeKind string
+
+const (
+	MessageChangeEdit   MessageChangeKind = "edit"
+	MessageChangeDelete MessageChangeKind = "delete"
+)
+
+// MessageChange — правка или удаление «у всех», ожидающие доставки собеседнику
+type MessageChange struct {
+	MessageID string            `json:"message_id" db:"message_id"`
+	ChatID    string            `json:"chat_id" db:"chat_id"`
+	Kind      MessageChangeKind `json:"kind" db:"kind"`
+
+	// Content, EditedAt — новая версия (только для правки)
+	Content  string `json:"content,omitempty" db:"content"`
+	EditedAt int64  `json:"edited_at,omitempty" db:"edited_at"`
+
+	Attempts  int    `json:"attempts" db:"attempts"`
+	LastError string `json:"last_error,omitempty" db:"last_error"`
+
+	// ExpiresAt — после этого времени изменение больше не отправляется (Unix ms)
+	ExpiresAt int64 `json:"expires_at" db:"expires_at"`
+
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// TransferDirection — направление передачи файла
+type TransferDirection string
+
+const (
+	// TransferOutgoing — мы отправляем файл
+	TransferOutgoing TransferDirection = "out"
+	// TransferIncoming — мы принимаем файл
+	TransferIncoming TransferDirection = "in"
+)
+
+// TransferStatus — состояние передачи файла
+type TransferStatus string
+
+const (
+	// TransferOffered — файл предложен, ответа ещё нет
+	TransferOffered TransferStatus = "offered"
+	// TransferAccepted — получатель согласился, идёт передача частей
+	TransferAccepted TransferStatus = "accepted"
+	// TransferComplete — файл передан и проверен
+	TransferComplete TransferStatus = "complete"
+	// TransferFailed — файл не совпал с манифестом или исходник пропал
+	TransferFailed TransferStatus = "failed"
+)
+
+// FileTransfer — состояние передачи одного файла частями
+type FileTransfer struct {
+	MessageID string            `json:"message_id" db:"message_id"`
+	FileID    string            `json:"file_id" db:"file_id"`
+	Direction TransferDirection `json:"direction" db:"direction"`
+	ChatID    string            `json:"chat_id" db:"chat_id"`
+	Filename  string            `json:"filename" db:"filename"`
+	MimeType  string            `json:"mime_type" db:"mime_type"`
+
+	// LocalPath — исходный файл (отправка) или собранный файл (приём)
+	LocalPath string `json:"local_path" db:"local_path"`
+
+	Size      int64  `json:"size" db:"size"`
+	SHA256    []byte `json:"sha256" db:"sha256"`
+	ChunkSize uint32 `json:"chunk_size" db:"chunk_size"`
+
+	// NextIndex — номер следующей части (все предыдущие получены/подтверждены)
+	NextIndex uint32 `json:"next_index" db:"next_index"`
+
+	Status    TransferStatus `json:"status" db:"status"`
+	UpdatedAt time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// ChunkCount возвращает число частей файла
+func (t *FileTransfer) ChunkCount() uint32 {
+	if t.ChunkSize == 0 || t.Size <= 0 {
+		return 0
+	}
+	// #nosec G115 -- размер ограничен при приёме манифеста
+	return uint32((t.Size + int64(t.ChunkSize) - 1) / int64(t.ChunkSize))
+}
+
+// ChunkLen возвращает ожидаемый размер части с номером index
+func (t *FileTransfer) ChunkLen(index uint32) int {
+	start := int64(index) * int64(t.ChunkSize)
+	if start >= t.Size {
+		return 0
+	}
+	return int(min(int64(t.ChunkSize), t.Size-start))
+}
+
+// Transferred возвращает число переданных байт
+func (t *FileTransfer) Transferred() int64 {
+	return min(int64(t.NextIndex)*int64(t.ChunkSize), t.Size)
+}
+
+// MessageEdit — предыдущая версия отредактированного сообщения
+type MessageEdit struct {
+	MessageID string `json:"message_id" db:"message_id"`
+
+	// Content — содержимое до редактирования
+	Content string `json:"content" db:"content"`
+
+	// EditedAt — когда появилась эта версия: время прошлой правки или отправки сообщения (Unix ms)
+	EditedAt int64 `json:"edited_at" db:"edited_at"`
 }
 
 // Attachment представляет вложение (файл/изображение)
@@ -232,3 +401,128 @@ type Folder struct {
 	// Position — позиция в списке (для сортировки)
 	Position int `json:"position" db:"position"`
 }
+
+// MaxGroupMembers — максимальное число участников группы (вместе с создателем)
+const MaxGroupMembers = 50
+
+// GroupRole — роль участника группы
+type GroupRole int
+
+const (
+	// GroupRoleMember — обычный участник
+	GroupRoleMember GroupRole = 0
+	// GroupRoleAdmin — администратор: может приглашать и исключать участников
+	GroupRoleAdmin GroupRole = 1
+)
+
+// Group — групповой чат. ID группы одновременно является ChatID её сообщений.
+type Group struct {
+	// ID — уникальный идентификатор группы (UUID)
+	ID string `json:"id" db:"id"`
+
+	// Name — название группы
+	Name string `json:"name" db:"name"`
+
+	// Epoch — версия состава, растёт с каждым изменением
+	Epoch uint64 `json:"epoch" db:"epoch"`
+
+	// StateUpdatedAt — время изменения состава из подписанного состояния (Unix ms)
+	StateUpdatedAt int64 `json:"state_updated_at" db:"state_updated_at"`
+
+	// SignerPubKey, Signature — администратор, подписавший текущий состав, и его подпись
+	SignerPubKey string `json:"signer_pub_key" db:"signer_pub_key"`
+	Signature    []byte `json:"signature" db:"signature"`
+
+	// IsMember — false, если нас исключили из группы (история остаётся)
+	IsMember bool `json:"is_member" db:"is_member"`
+
+	// IsPending — приглашение не от нашего контакта, пока не принято
+	IsPending bool `json:"is_pending" db:"is_pending"`
+
+	// Members — участники группы
+	Members []*GroupMember `json:"members" db:"-"`
+
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// LastMessage — последнее сообщение (не хранится в этой таблице)
+	LastMessage string `json:"last_message,omitempty" db:"-"`
+
+	// LastMessageTime — время последнего сообщения
+	LastMessageTime time.Time `json:"last_message_time,omitempty" db:"-"`
+}
+
+// Member возвращает участника по публичному ключу
+func (g *Group) Member(pubKey string) *GroupMember {
+	for _, m := range g.Members {
+		if m.PublicKey == pubKey {
+			return m
+		}
+	}
+	return nil
+}
+
+// IsAdmin сообщает, является ли участник администратором
+func (g *Group) IsAdmin(pubKey string) bool {
+	m := g.Member(pubKey)
+	return m != nil && m.Role == GroupRoleAdmin
+}
+
+// GroupMember — участник группы
+type GroupMember struct {
+	GroupID    string    `json:"group_id" db:"group_id"`
+	PublicKey  string    `json:"public_key" db:"public_key"`
+	I2PAddress string    `json:"i2p_address" db:"i2p_address"`
+	Nickname   string    `json:"nickname" db:"nickname"`
+	Role       GroupRole `json:"role" db:"role"`
+}
+
+// Channel — канал: посты публикует только владелец, подписчики их читают.
+// ID канала одновременно является ChatID его постов.
+type Channel struct {
+	// ID — уникальный идентификатор канала (UUID)
+	ID string `json:"id" db:"id"`
+
+	// Name — название канала (у подписчика — из ответа владельца)
+	Name string `json:"name" db:"name"`
+
+	// OwnerPubKey — ключ владельца, которым подписаны посты (у подписчика пуст до первого ответа)
+	OwnerPubKey string `json:"owner_pub_key" db:"owner_pub_key"`
+
+	// OwnerAddress — I2P адрес владельца, с которого рассылаются посты
+	OwnerAddress string `json:"owner_address" db:"owner_address"`
+
+	// IsOwner — канал наш
+	IsOwner bool `json:"is_owner" db:"is_owner"`
+
+	// LastSeq — номер последнего поста (у подписчика — последнего полученного)
+	LastSeq uint64 `json:"last_seq" db:"last_seq"`
+
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// SubscriberCount — число подписчиков (только для своих каналов)
+	SubscriberCount int `json:"subscriber_count,omitempty" db:"-"`
+
+	// LastMessage — последний пост (не хранится в этой таблице)
+	LastMessage string `json:"last_message,omitempty" db:"-"`
+
+	// LastMessageTime — время последнего поста
+	LastMessageTime time.Time `json:"last_message_time,omitempty" db:"-"`
+}
+
+// ChannelPost — пост канала с подписью владельца. Текст хранится в messages.
+type ChannelPost struct {
+	ChannelID string `json:"channel_id" db:"channel_id"`
+	Seq       uint64 `json:"seq" db:"seq"`
+	MessageID string `json:"message_id" db:"message_id"`
+	Content   string `json:"content" db:"-"`
+	Timestamp int64  `json:"timestamp" db:"-"`
+	Signature []byte `json:"signature" db:"signature"`
+}
+
+// ChannelSubscriber — подписчик нашего канала
+type ChannelSubscriber struct {
+	ChannelID  string    `json:"channel_id" db:"channel_id"`
+	PublicKey  string    `json:"public_key" db:"public_key"`
+	I2PAddress string    `json:"i2p_address" db:"i2p_address"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,370 @@
+// Package ratchet реализует Double Ratchet (симметричные цепочки + DH ratchet)
+// поверх E2EE-сессии, установленной в рукопожатии
+package ratchet
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"teleghost/internal/core/identity"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// MaxSkip — сколько ключей можно пропустить в одной цепочке
+	MaxSkip = 1000
+
+	// MaxSkippedKeys — сколько пропущенных ключей храним всего (старые вытесняются)
+	MaxSkippedKeys = 2000
+
+	// keySize — размер ключей цепочек и X25519
+	keySize = 32
+
+	// HeaderSize — размер заголовка: DH ключ + PN + N
+	HeaderSize = keySize + 4 + 4
+
+	// rootInfo — контекст HKDF для корневой цепочки
+	rootInfo = "teleghost-ratchet-v1"
+)
+
+var (
+	// ErrTooManySkipped — собеседник пропустил слишком много сообщений
+	ErrTooManySkipped = errors.New("too many skipped messages")
+
+	// ErrNoSendingChain — цепочка отправки ещё не установлена
+	ErrNoSendingChain = errors.New("sending chain not initialized")
+)
+
+// State — состояние Double Ratchet для одной сессии.
+// Сериализуется в JSON и хранится в БД в зашифрованном виде.
+type State struct {
+	// SessionID — ID сессии из рукопожатия (передаётся в Packet.session_id)
+	SessionID []byte `json:"session_id"`
+
+	// DHs — наш текущий приватный X25519 ключ ratchet
+	DHs []byte `json:"dhs"`
+
+	// DHr — текущий публичный ключ ratchet собеседника
+	DHr []byte `json:"dhr"`
+
+	// RK — корневой ключ
+	RK []byte `json:"rk"`
+
+	// CKs, CKr — ключи цепочек отправки и получения
+	CKs []byte `json:"cks"`
+	CKr []byte `json:"ckr"`
+
+	// Ns, Nr — номера сообщений в текущих цепочках, PN — длина предыдущей цепочки отправки
+	Ns uint32 `json:"ns"`
+	Nr uint32 `json:"nr"`
+	PN uint32 `json:"pn"`
+
+	// Skipped — ключи пропущенных сообщений (hex(DH):N -> ключ)
+	Skipped map[string][]byte `json:"skipped"`
+
+	// SkippedOrder — порядок добавления пропущенных ключей (для вытеснения)
+	SkippedOrder []string `json:"skipped_order"`
+
+	// CreatedAt — время установления сессии
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// header — заголовок зашифрованного сообщения
+type header struct {
+	DH []byte
+	PN uint32
+	N  uint32
+}
+
+func (h header) encode() []byte {
+	buf := make([]byte, HeaderSize)
+	copy(buf, h.DH)
+	binary.BigEndian.PutUint32(buf[keySize:], h.PN)
+	binary.BigEndian.PutUint32(buf[keySize+4:], h.N)
+	return buf
+}
+
+func decodeHeader(data []byte) (header, error) {
+	if len(data) < HeaderSize {
+		return header{}, errors.New("message too short")
+	}
+	return header{
+		DH: append([]byte(nil), data[:keySize]...),
+		PN: binary.BigEndian.Uint32(data[keySize:]),
+		N:  binary.BigEndian.Uint32(data[keySize+4:]),
+	}, nil
+}
+
+// New инициализирует ratchet из ключей рукопожатия.
+// Начальные цепочки берутся из ключей сессии, а сторона с меньшим публичным ключом
+// сразу делает шаг DH ratchet, чтобы собеседник сменил ключи при первом же ответе.
+func New(sess *identity.SessionKeys, myPubKey, peerPubKey string) (*State, error) {
+	if sess.LocalEphemeral == nil || len(sess.PeerEphemeral) != keySize {
+		return nil, errors.New("session has no ephemeral keys")
+	}
+
+	s := &State{
+		SessionID: append([]byte(nil), sess.ID...),
+		DHs:       sess.LocalEphemeral.Bytes(),
+		DHr:       append([]byte(nil), sess.PeerEphemeral...),
+		RK:        append([]byte(nil), sess.RootKey...),
+		CKs:       append([]byte(nil), sess.SendKey...),
+		CKr:       append([]byte(nil), sess.RecvKey...),
+		Skipped:   make(map[string][]byte),
+		CreatedAt: sess.CreatedAt,
+	}
+
+	if myPubKey < peerPubKey {
+		if err := s.rotateSending(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Encrypt шифрует сообщение следующим ключом цепочки отправки.
+// Формат: [заголовок][24 байта Nonce][Шифротекст]
+func (s *State) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
+	if len(s.CKs) == 0 {
+		return nil, ErrNoSendingChain
+	}
+
+	dhPub, err := publicKey(s.DHs)
+	if err != nil {
+		return nil, err
+	}
+
+	var mk []byte
+	s.CKs, mk = kdfChain(s.CKs)
+	h := header{DH: dhPub, PN: s.PN, N: s.Ns}
+	s.Ns++
+
+	hdr := h.encode()
+	sealed, err := seal(mk, plaintext, append(append([]byte{}, additionalData...), hdr...))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(hdr, sealed...), nil
+}
+
+// Decrypt расшифровывает сообщение, при необходимости выполняя шаг DH ratchet.
+// При ошибке состояние не меняется.
+func (s *State) Decrypt(data, additionalData []byte) ([]byte, error) {
+	h, err := decodeHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	hdr, ciphertext := data[:HeaderSize], data[HeaderSize:]
+	ad := append(append([]byte{}, additionalData...), hdr...)
+
+	// Сообщение из уже пропущенной части цепочки
+	id := skippedID(h.DH, h.N)
+	if mk, ok := s.Skipped[id]; ok {
+		plain, err := open(mk, ciphertext, ad)
+		if err != nil {
+			return nil, err
+		}
+		s.removeSkipped(id)
+		return plain, nil
+	}
+
+	snapshot := s.clone()
+
+	if !bytes.Equal(h.DH, s.DHr) {
+		if err := s.skipMessageKeys(h.PN); err != nil {
+			*s = *snapshot
+			return nil, err
+		}
+		if err := s.dhRatchet(h.DH); err != nil {
+			*s = *snapshot
+			return nil, err
+		}
+	}
+
+	if err := s.skipMessageKeys(h.N); err != nil {
+		*s = *snapshot
+		return nil, err
+	}
+
+	var mk []byte
+	s.CKr, mk = kdfChain(s.CKr)
+	s.Nr++
+
+	plain, err := open(mk, ciphertext, ad)
+	if err != nil {
+		*s = *snapshot
+		return nil, err
+	}
+
+	return plain, nil
+}
+
+// skipMessageKeys сохраняет ключи сообщений текущей цепочки получения до номера until
+func (s *State) skipMessageKeys(until uint32) error {
+	if len(s.CKr) == 0 || until <= s.Nr {
+		return nil
+	}
+	if until-s.Nr > MaxSkip {
+		return ErrTooManySkipped
+	}
+
+	if s.Skipped == nil {
+		s.Skipped = make(map[string][]byte)
+	}
+
+	for s.Nr < until {
+		var mk []byte
+		s.CKr, mk = kdfChain(s.CKr)
+		id := skippedID(s.DHr, s.Nr)
+		s.Skipped[id] = mk
+		s.SkippedOrder = append(s.SkippedOrder, id)
+		s.Nr++
+	}
+
+	// Вытесняем самые старые ключи
+	for len(s.SkippedOrder) > MaxSkippedKeys {
+		delete(s.Skipped, s.SkippedOrder[0])
+		s.SkippedOrder = s.SkippedOrder[1:]
+	}
+
+	return nil
+}
+
+// dhRatchet выполняет шаг DH ratchet при получении нового ключа собеседника
+func (s *State) dhRatchet(peerDH []byte) error {
+	s.PN = s.Ns
+	s.Ns = 0
+	s.Nr = 0
+	s.DHr = peerDH
+
+	dhOut, err := dh(s.DHs, s.DHr)
+	if err != nil {
+		return err
+	}
+	s.RK, s.CKr = kdfRoot(s.RK, dhOut)
+
+	return s.rotateSending()
+}
+
+// rotateSending генерирует новый ключ ratchet и новую цепочку отправки
+func (s *State) rotateSending() error {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate ratchet key: %w", err)
+	}
+
+	dhOut, err := dh(key.Bytes(), s.DHr)
+	if err != nil {
+		return err
+	}
+
+	s.DHs = key.Bytes()
+	s.RK, s.CKs = kdfRoot(s.RK, dhOut)
+	return nil
+}
+
+func (s *State) removeSkipped(id string) {
+	delete(s.Skipped, id)
+	for i, v := range s.SkippedOrder {
+		if v == id {
+			s.SkippedOrder = append(s.SkippedOrder[:i], s.SkippedOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// clone создаёт копию состояния для отката при ошибке
+func (s *State) clone() *State {
+	c := *s
+	c.Skipped = make(map[string][]byte, len(s.Skipped))
+	for k, v := range s.Skipped {
+		c.Skipped[k] = v
+	}
+	c.SkippedOrder = append([]string(nil), s.SkippedOrder...)
+	return &c
+}
+
+func skippedID(dhPub []byte, n uint32) string {
+	return fmt.Sprintf("%s:%d", hex.EncodeToString(dhPub), n)
+}
+
+// kdfRoot — KDF корневой цепочки: (RK, DH) -> (новый RK, ключ цепочки)
+func kdfRoot(rk, dhOut []byte) ([]byte, []byte) {
+	out := make([]byte, 2*keySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, dhOut, rk, []byte(rootInfo)), out); err != nil {
+		panic(err) // HKDF не может вернуть ошибку на 64 байтах
+	}
+	return out[:keySize], out[keySize:]
+}
+
+// kdfChain — KDF симметричной цепочки: CK -> (новый CK, ключ сообщения)
+func kdfChain(ck []byte) ([]byte, []byte) {
+	mac := hmac.New(sha256.New, ck)
+	mac.Write([]byte{0x01})
+	mk := mac.Sum(nil)
+
+	mac = hmac.New(sha256.New, ck)
+	mac.Write([]byte{0x02})
+	return mac.Sum(nil), mk
+}
+
+func publicKey(priv []byte) ([]byte, error) {
+	key, err := ecdh.X25519().NewPrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ratchet key: %w", err)
+	}
+	return key.PublicKey().Bytes(), nil
+}
+
+func dh(priv, pub []byte) ([]byte, error) {
+	key, err := ecdh.X25519().NewPrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ratchet key: %w", err)
+	}
+	peer, err := ecdh.X25519().NewPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer ratchet key: %w", err)
+	}
+	return key.ECDH(peer)
+}
+
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, additionalData)
+}
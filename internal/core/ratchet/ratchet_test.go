@@ -0,0 +1,183 @@
+// Package ratchet — тесты для Double Ratchet
+package ratchet
+
+import (
+	"encoding/json"
+	"testing"
+
+	"teleghost/internal/core/identity"
+)
+
+// newPair создаёт два связанных ratchet, как после рукопожатия
+func newPair(t *testing.T) (*State, *State) {
+	t.Helper()
+
+	aliceEph, err := identity.GenerateEphemeralKey()
+	if err != nil {
+		t.Fatalf("GenerateEphemeralKey failed: %v", err)
+	}
+	bobEph, err := identity.GenerateEphemeralKey()
+	if err != nil {
+		t.Fatalf("GenerateEphemeralKey failed: %v", err)
+	}
+
+	aliceSess, err := identity.DeriveSessionKeys(aliceEph, bobEph.PublicKey().Bytes(), "alice-key", "bob-key")
+	if err != nil {
+		t.Fatalf("DeriveSessionKeys failed: %v", err)
+	}
+	bobSess, err := identity.DeriveSessionKeys(bobEph, aliceEph.PublicKey().Bytes(), "bob-key", "alice-key")
+	if err != nil {
+		t.Fatalf("DeriveSessionKeys failed: %v", err)
+	}
+
+	alice, err := New(aliceSess, "alice-key", "bob-key")
+	if err != nil {
+		t.Fatalf("New failed (alice): %v", err)
+	}
+	bob, err := New(bobSess, "bob-key", "alice-key")
+	if err != nil {
+		t.Fatalf("New failed (bob): %v", err)
+	}
+	return alice, bob
+}
+
+func roundTrip(t *testing.T, from, to *State, text string) {
+	t.Helper()
+
+	data, err := from.Encrypt([]byte(text), []byte("ad"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	plain, err := to.Decrypt(data, []byte("ad"))
+	if err != nil {
+		t.Fatalf("Decrypt failed for '%s': %v", text, err)
+	}
+	if string(plain) != text {
+		t.Errorf("Expected '%s', got '%s'", text, plain)
+	}
+}
+
+func TestRatchet_Conversation(t *testing.T) {
+	alice, bob := newPair(t)
+
+	// Обе стороны могут писать первыми
+	roundTrip(t, bob, alice, "bob first")
+	roundTrip(t, alice, bob, "alice 1")
+	roundTrip(t, alice, bob, "alice 2")
+	roundTrip(t, bob, alice, "bob 2")
+	roundTrip(t, alice, bob, "alice 3")
+
+	// После обмена ответами ключ ratchet должен смениться
+	before := append([]byte(nil), alice.DHs...)
+	roundTrip(t, bob, alice, "bob 3")
+	roundTrip(t, alice, bob, "alice 4")
+	if string(before) == string(alice.DHs) {
+		t.Error("DH ratchet key did not rotate")
+	}
+}
+
+func TestRatchet_OutOfOrder(t *testing.T) {
+	alice, bob := newPair(t)
+
+	msgs := make([][]byte, 5)
+	for i := range msgs {
+		data, err := alice.Encrypt([]byte{byte('A' + i)}, nil)
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		msgs[i] = data
+	}
+
+	// Получаем в обратном порядке
+	for i := len(msgs) - 1; i >= 0; i-- {
+		plain, err := bob.Decrypt(msgs[i], nil)
+		if err != nil {
+			t.Fatalf("Decrypt failed for message %d: %v", i, err)
+		}
+		if plain[0] != byte('A'+i) {
+			t.Errorf("Message %d: unexpected plaintext %q", i, plain)
+		}
+	}
+
+	if len(bob.Skipped) != 0 {
+		t.Errorf("Expected skipped store to be empty, got %d", len(bob.Skipped))
+	}
+
+	// Повтор уже расшифрованного сообщения не проходит
+	if _, err := bob.Decrypt(msgs[0], nil); err == nil {
+		t.Error("Replayed message should not decrypt")
+	}
+}
+
+func TestRatchet_TooManySkipped(t *testing.T) {
+	alice, bob := newPair(t)
+
+	first, err := alice.Encrypt([]byte("first"), nil)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	var last []byte
+	for i := 0; i <= MaxSkip+1; i++ {
+		data, err := alice.Encrypt([]byte("x"), nil)
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		last = data
+	}
+
+	if _, err := bob.Decrypt(last, nil); err != ErrTooManySkipped {
+		t.Fatalf("Expected ErrTooManySkipped, got %v", err)
+	}
+
+	// Состояние не изменилось — первое сообщение всё ещё расшифровывается
+	plain, err := bob.Decrypt(first, nil)
+	if err != nil {
+		t.Fatalf("Decrypt failed after rejected message: %v", err)
+	}
+	if string(plain) != "first" {
+		t.Errorf("Expected 'first', got '%s'", plain)
+	}
+}
+
+func TestRatchet_TamperedMessage(t *testing.T) {
+	alice, bob := newPair(t)
+
+	data, err := alice.Encrypt([]byte("hello"), nil)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-1] ^= 0xFF
+	if _, err := bob.Decrypt(tampered, nil); err == nil {
+		t.Fatal("Tampered message should not decrypt")
+	}
+
+	// Оригинал после неудачной попытки всё ещё расшифровывается
+	plain, err := bob.Decrypt(data, nil)
+	if err != nil {
+		t.Fatalf("Decrypt failed after tamper attempt: %v", err)
+	}
+	if string(plain) != "hello" {
+		t.Errorf("Expected 'hello', got '%s'", plain)
+	}
+}
+
+func TestRatchet_Persistence(t *testing.T) {
+	alice, bob := newPair(t)
+	roundTrip(t, alice, bob, "before restart")
+
+	// Сериализуем состояние, как при сохранении в БД
+	data, err := json.Marshal(bob)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	restored := &State{}
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	roundTrip(t, alice, restored, "after restart")
+	roundTrip(t, restored, alice, "reply after restart")
+}
@@ -0,0 +1,268 @@
+// Package harness поднимает несколько AppCore в одном процессе поверх сети в памяти.
+//
+// Каждый узел получает свой временный DataDir, аккаунт и роутер loopback.Network;
+// условия сети (задержка, потери, обрывы, разделение) меняются через Cluster.Network.
+// Так сценарии целиком — знакомство, файлы, профили, переподключение — проверяются
+// обычным go test, без i2pd.
+package harness
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"teleghost/internal/appcore"
+	"teleghost/internal/core"
+	"teleghost/internal/core/identity"
+	"teleghost/internal/network/loopback"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+// Timeout — сколько сценарий ждёт события, прежде чем считать шаг проваленным
+var Timeout = 20 * time.Second
+
+// stampBits — сложность штампов узлов: по умолчанию рукопожатие с незнакомцем считалось бы заметно дольше
+const stampBits = 8
+
+// Cluster — набор узлов в общей сети
+type Cluster struct {
+	t       *testing.T
+	Network *loopback.Network
+	Nodes   []*Node
+}
+
+// Node — узел кластера: AppCore с аккаунтом, роутером и журналом событий
+type Node struct {
+	*appcore.AppCore
+	Name   string
+	PubKey string
+	Dest   string
+	Router *loopback.Router
+
+	t        *testing.T
+	mnemonic string
+	events   *recorder
+}
+
+// Start запускает по узлу на каждое имя и дожидается, пока все выйдут в сеть.
+// Узлы останавливаются по завершении теста.
+func Start(t *testing.T, names ...string) *Cluster {
+	t.Helper()
+	c := &Cluster{t: t, Network: loopback.NewNetwork()}
+	for _, name := range names {
+		c.Nodes = append(c.Nodes, c.startNode(name))
+	}
+	return c
+}
+
+func (c *Cluster) startNode(name string) *Node {
+	t := c.t
+	t.Helper()
+
+	n := &Node{
+		Name:   name,
+		Router: c.Network.NewRouter(),
+		t:      t,
+		events: newRecorder(),
+	}
+	n.Dest, _ = n.Router.GetAddress()
+	n.AppCore = appcore.NewAppCore(t.TempDir(), n.events, platform{})
+	n.Transport = n.Router
+	t.Cleanup(n.Shutdown)
+
+	if err := n.Init(); err != nil {
+		t.Fatalf("%s: init failed: %v", name, err)
+	}
+	mnemonic, err := n.CreateAccount()
+	if err != nil {
+		t.Fatalf("%s: create account failed: %v", name, err)
+	}
+	n.mnemonic = mnemonic
+	n.PubKey = n.Identity.Keys.PublicKeyBase64
+
+	n.login()
+	if err := n.UpdateMyProfile(name, "", ""); err != nil {
+		t.Fatalf("%s: update profile failed: %v", name, err)
+	}
+	return n
+}
+
+// login входит в аккаунт и ждёт подключения к сети
+func (n *Node) login() {
+	n.t.Helper()
+	since := n.events.len()
+	if err := n.Login(n.mnemonic); err != nil {
+		n.t.Fatalf("%s: login failed: %v", n.Name, err)
+	}
+	n.waitEvent(since, "network_status", func(data interface{}) bool {
+		return data == string(appcore.StatusOnline)
+	})
+	if err := n.SavePrivacySettings(map[string]interface{}{"stampDifficulty": float64(stampBits)}); err != nil {
+		n.t.Fatalf("%s: save privacy settings failed: %v", n.Name, err)
+	}
+}
+
+// Reconnect снова входит в аккаунт после Logout: адрес, ключи и сессии те же
+func (n *Node) Reconnect() {
+	n.t.Helper()
+	n.login()
+}
+
+// B32 возвращает b32 адрес узла
+func (n *Node) B32() string {
+	return i2pkeys.I2PAddr(n.Dest).Base32()
+}
+
+// ChatID — чат узла с собеседником
+func (n *Node) ChatID(peer *Node) string {
+	return identity.CalculateChatID(n.PubKey, peer.PubKey)
+}
+
+// ContactOf возвращает контакт собеседника (nil — его нет)
+func (n *Node) ContactOf(peer *Node) *core.Contact {
+	contact, err := n.Repo.GetContactByPublicKey(n.Ctx, peer.PubKey)
+	if err != nil {
+		return nil
+	}
+	return contact
+}
+
+// Introduce знакомит узлы: from добавляет to по адресу, to принимает запрос переписки.
+// Возвращается, когда у обоих есть контакт с ключом собеседника.
+func (c *Cluster) Introduce(from, to *Node, address string) *core.Contact {
+	c.t.Helper()
+
+	added, err := from.AddContact(to.Name, address)
+	if err != nil {
+		c.t.Fatalf("%s: add contact failed: %v", from.Name, err)
+	}
+	request := to.WaitEvent("message_request", func(data interface{}) bool {
+		m, ok := data.(map[string]interface{})
+		return ok && m["ChatID"] == to.ChatID(from)
+	})
+	if err := to.AcceptMessageRequest(request.(map[string]interface{})["ContactID"].(string)); err != nil {
+		c.t.Fatalf("%s: accept request failed: %v", to.Name, err)
+	}
+
+	var contact *core.Contact
+	Eventually(c.t, fmt.Sprintf("%s learns the key of %s", from.Name, to.Name), func() bool {
+		contact, _ = from.Repo.GetContact(from.Ctx, added.ID)
+		return contact != nil && contact.PublicKey == to.PubKey && to.ContactOf(from) != nil
+	})
+	return contact
+}
+
+// WaitEvent ждёт событие фронтенда с подходящими данными (первый аргумент Emit)
+func (n *Node) WaitEvent(name string, match func(data interface{}) bool) interface{} {
+	n.t.Helper()
+	return n.waitEvent(0, name, match)
+}
+
+// WaitMessage ждёт входящее сообщение с текстом и возвращает данные события
+func (n *Node) WaitMessage(content string) map[string]interface{} {
+	n.t.Helper()
+	data := n.WaitEvent("new_message", func(data interface{}) bool {
+		m, ok := data.(map[string]interface{})
+		return ok && m["Content"] == content && m["IsOutgoing"] == false
+	})
+	return data.(map[string]interface{})
+}
+
+func (n *Node) waitEvent(since int, name string, match func(data interface{}) bool) interface{} {
+	n.t.Helper()
+	data, ok := n.events.wait(since, name, match, Timeout)
+	if !ok {
+		n.t.Fatalf("%s: timed out waiting for %s event", n.Name, name)
+	}
+	return data
+}
+
+// Eventually ждёт, пока условие станет истинным
+func Eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(Timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting: %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// event — событие, отправленное во фронтенд
+type event struct {
+	name string
+	data interface{}
+}
+
+// recorder — EventEmitter, который запоминает события для проверок
+type recorder struct {
+	mu     sync.Mutex
+	events []event
+	wake   chan struct{}
+}
+
+func newRecorder() *recorder {
+	return &recorder{wake: make(chan struct{})}
+}
+
+func (r *recorder) Emit(name string, data ...interface{}) {
+	var first interface{}
+	if len(data) > 0 {
+		first = data[0]
+	}
+	r.mu.Lock()
+	r.events = append(r.events, event{name: name, data: first})
+	close(r.wake)
+	r.wake = make(chan struct{})
+	r.mu.Unlock()
+}
+
+func (r *recorder) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.events)
+}
+
+// wait ищет событие начиная с номера since, дожидаясь новых до таймаута
+func (r *recorder) wait(since int, name string, match func(interface{}) bool, timeout time.Duration) (interface{}, bool) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		r.mu.Lock()
+		for ; since < len(r.events); since++ {
+			if e := r.events[since]; e.name == name && match(e.data) {
+				r.mu.Unlock()
+				return e.data, true
+			}
+		}
+		wake := r.wake
+		r.mu.Unlock()
+
+		select {
+		case <-wake:
+		case <-timer.C:
+			return nil, false
+		}
+	}
+}
+
+// platform — PlatformServices без окна и диалогов
+type platform struct{}
+
+func (platform) OpenFileDialog(string, []string) (string, error) {
+	return "", fmt.Errorf("no file dialog in tests")
+}
+
+func (platform) SaveFileDialog(string, string) (string, error) {
+	return "", fmt.Errorf("no file dialog in tests")
+}
+
+func (platform) ClipboardSet(string)           {}
+func (platform) ClipboardGet() (string, error) { return "", nil }
+func (platform) ShowWindow()                   {}
+func (platform) HideWindow()                   {}
+func (platform) Notify(string, string)         {}
+func (platform) ShareFile(string) error        { return nil }
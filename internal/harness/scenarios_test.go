@@ -0,0 +1,589 @@
+package harness
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"teleghost/internal/core"
+	"teleghost/internal/network/loopback"
+	pb "teleghost/internal/proto"
+
+	"github.com/google/uuid"
+)
+
+// messageStatus ждёт статус нашего сообщения у отправителя
+func messageStatus(n *Node, messageID string, status core.MessageStatus) {
+	n.t.Helper()
+	Eventually(n.t, "status "+status.String()+" of "+messageID, func() bool {
+		msg, err := n.Repo.GetMessage(n.Ctx, messageID)
+		return err == nil && msg != nil && msg.Status == status
+	})
+}
+
+// lastOutgoing возвращает ID последнего отправленного в чат сообщения
+func lastOutgoing(t *testing.T, n *Node, content string) string {
+	t.Helper()
+	data := n.WaitEvent("new_message", func(data interface{}) bool {
+		m, ok := data.(map[string]interface{})
+		return ok && m["Content"] == content && m["IsOutgoing"] == true
+	})
+	return data.(map[string]interface{})["ID"].(string)
+}
+
+func TestHandshake(t *testing.T) {
+	c := Start(t, "alice", "bob")
+	alice, bob := c.Nodes[0], c.Nodes[1]
+	c.Network.SetConditions(loopback.Conditions{Latency: 5 * time.Millisecond, Jitter: 5 * time.Millisecond})
+
+	contact := c.Introduce(alice, bob, bob.Dest)
+	if contact.ChatID != alice.ChatID(bob) {
+		t.Fatalf("Unexpected ChatID %s", contact.ChatID)
+	}
+
+	if err := alice.SendText(contact.ID, "hello bob", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	if msg := bob.WaitMessage("hello bob"); msg["ChatID"] != bob.ChatID(alice) {
+		t.Errorf("Message landed in chat %v", msg["ChatID"])
+	}
+	// Отчёт о доставке возвращается отправителю
+	messageStatus(alice, lastOutgoing(t, alice, "hello bob"), core.MessageStatusDelivered)
+
+	if err := bob.SendText(bob.ContactOf(alice).ID, "hi alice", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	alice.WaitMessage("hi alice")
+
+	// Обе стороны — проверенные контакты, а не запросы переписки
+	if alice.ContactOf(bob).IsPending || bob.ContactOf(alice).IsPending {
+		t.Error("Contact left pending after handshake")
+	}
+}
+
+func TestLeaseSetKeys(t *testing.T) {
+	c := Start(t, "alice", "bob")
+	alice, bob := c.Nodes[0], c.Nodes[1]
+	c.Introduce(alice, bob, bob.Dest)
+
+	// Bob получает ключ до того, как Alice стала контактом; Alice — в ответном handshake
+	for _, pair := range [][2]*Node{{alice, bob}, {bob, alice}} {
+		n, peer := pair[0], pair[1]
+		key, err := peer.Identity.Keys.LeaseSetAuthKey()
+		if err != nil {
+			t.Fatalf("LeaseSetAuthKey failed: %v", err)
+		}
+		want := base64.StdEncoding.EncodeToString(key.PublicKey().Bytes())
+		Eventually(t, n.Name+" stores the leaseSet key of "+peer.Name, func() bool {
+			contact := n.ContactOf(peer)
+			return contact != nil && contact.LeaseSetAuthKey == want
+		})
+	}
+}
+
+func TestChatIDMigrationFromB32(t *testing.T) {
+	c := Start(t, "alice", "bob")
+	alice, bob := c.Nodes[0], c.Nodes[1]
+
+	// Контакт добавлен вручную по b32: ключа и ChatID пока нет
+	added, err := alice.AddContact("bob", bob.B32())
+	if err != nil {
+		t.Fatalf("AddContact failed: %v", err)
+	}
+	if added.ChatID != "" {
+		t.Fatalf("Expected empty ChatID before handshake, got %s", added.ChatID)
+	}
+	request := bob.WaitEvent("message_request", func(data interface{}) bool {
+		m, ok := data.(map[string]interface{})
+		return ok && m["ChatID"] == bob.ChatID(alice)
+	})
+	if err := bob.AcceptMessageRequest(request.(map[string]interface{})["ContactID"].(string)); err != nil {
+		t.Fatalf("AcceptMessageRequest failed: %v", err)
+	}
+
+	// Ответ приходит с полного адреса: тот же контакт получает ключ и новый ChatID
+	Eventually(t, "ChatID migration", func() bool {
+		contact, _ := alice.Repo.GetContact(alice.Ctx, added.ID)
+		return contact != nil && contact.PublicKey == bob.PubKey && contact.ChatID == alice.ChatID(bob)
+	})
+	contacts, err := alice.Repo.ListContacts(alice.Ctx)
+	if err != nil || len(contacts) != 1 {
+		t.Fatalf("Expected the b32 contact to be reused, got %d contacts (%v)", len(contacts), err)
+	}
+
+	if err := bob.SendText(bob.ContactOf(alice).ID, "found you", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	if msg := alice.WaitMessage("found you"); msg["ChatID"] != alice.ChatID(bob) {
+		t.Errorf("Message landed in chat %v", msg["ChatID"])
+	}
+	if err := alice.SendText(added.ID, "via b32", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	bob.WaitMessage("via b32")
+}
+
+func TestSendBeforeHandshake(t *testing.T) {
+	c := Start(t, "alice", "bob")
+	alice, bob := c.Nodes[0], c.Nodes[1]
+
+	// Ключа bob ещё нет: всё сохраняется сразу и ждёт в outbox
+	added, err := alice.AddContact("bob", bob.B32())
+	if err != nil {
+		t.Fatalf("AddContact failed: %v", err)
+	}
+	if err := alice.SendText(added.ID, "early text", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	textID := lastOutgoing(t, alice, "early text")
+
+	dir := t.TempDir()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode failed: %v", err)
+	}
+	imagePath := filepath.Join(dir, "photo.png")
+	filePath := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(imagePath, buf.Bytes(), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := os.WriteFile(filePath, []byte("notes"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := alice.SendFileMessage(added.ID, "early image", "", []string{imagePath}, false); err != nil {
+		t.Fatalf("SendFileMessage (image) failed: %v", err)
+	}
+	imageID := lastOutgoing(t, alice, "early image")
+	time.Sleep(2 * time.Millisecond) // ID сообщения с файлом строится из времени
+	if err := alice.SendFileMessage(added.ID, "early file", "", []string{filePath}, true); err != nil {
+		t.Fatalf("SendFileMessage (file) failed: %v", err)
+	}
+	fileID := lastOutgoing(t, alice, "early file")
+
+	for _, id := range []string{textID, imageID, fileID} {
+		if msg, err := alice.Repo.GetMessage(alice.Ctx, id); err != nil || msg == nil || msg.Status != core.MessageStatusPending {
+			t.Fatalf("Message %s was not saved as pending: %+v (%v)", id, msg, err)
+		}
+	}
+
+	request := bob.WaitEvent("message_request", func(data interface{}) bool {
+		m, ok := data.(map[string]interface{})
+		return ok && m["ChatID"] == bob.ChatID(alice)
+	})
+	if err := bob.AcceptMessageRequest(request.(map[string]interface{})["ContactID"].(string)); err != nil {
+		t.Fatalf("AcceptMessageRequest failed: %v", err)
+	}
+
+	// После handshake очередь переезжает в настоящий чат и доставляется по порядку
+	for _, content := range []string{"early text", "early image"} {
+		if msg := bob.WaitMessage(content); msg["ChatID"] != bob.ChatID(alice) {
+			t.Errorf("%q landed in chat %v", content, msg["ChatID"])
+		}
+	}
+	bob.WaitEvent("new_message", func(data interface{}) bool {
+		m, ok := data.(map[string]interface{})
+		return ok && m["ID"] == fileID && m["ContentType"] == "file_offer"
+	})
+	// На предложение файла отчёт не приходит: оно остаётся отправленным до ответа
+	messageStatus(alice, textID, core.MessageStatusDelivered)
+	messageStatus(alice, imageID, core.MessageStatusDelivered)
+	messageStatus(alice, fileID, core.MessageStatusSent)
+	for _, id := range []string{textID, imageID, fileID} {
+		if msg, _ := alice.Repo.GetMessage(alice.Ctx, id); msg.ChatID != alice.ChatID(bob) {
+			t.Errorf("Message %s stayed in chat %s", id, msg.ChatID)
+		}
+	}
+	if msg, _ := bob.Repo.GetMessage(bob.Ctx, imageID); msg == nil || len(msg.Attachments) != 1 {
+		t.Errorf("Image did not arrive: %+v", msg)
+	}
+}
+
+func TestFirstMessageFromStranger(t *testing.T) {
+	c := Start(t, "alice", "bob")
+	alice, bob := c.Nodes[0], c.Nodes[1]
+
+	// В адресе bob есть его статический ключ: текст доходит без ответа на handshake
+	added, err := alice.AddContact("bob", bob.GetMyDestination())
+	if err != nil {
+		t.Fatalf("AddContact failed: %v", err)
+	}
+	if err := alice.SendText(added.ID, "hello stranger", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	messageID := lastOutgoing(t, alice, "hello stranger")
+
+	Eventually(t, "bob stores the message in the request inbox", func() bool {
+		msg, _ := bob.Repo.GetMessage(bob.Ctx, messageID)
+		return msg != nil && msg.Content == "hello stranger" && msg.ChatID == bob.ChatID(alice)
+	})
+	requests, err := bob.GetMessageRequests()
+	if err != nil || len(requests) != 1 || requests[0].ChatID != bob.ChatID(alice) {
+		t.Fatalf("Unexpected message requests: %+v (%v)", requests, err)
+	}
+	if n, _ := bob.GetUnreadCount(); n != 0 {
+		t.Errorf("Request counted as unread: %d", n)
+	}
+
+	// bob ничего не ответил: alice не знает его ключа, сессии нет, отчёта о доставке тоже
+	messageStatus(alice, messageID, core.MessageStatusSent)
+	if contact, _ := alice.Repo.GetContact(alice.Ctx, added.ID); contact == nil || contact.PublicKey != "" {
+		t.Errorf("alice learned the key of bob before the request was accepted: %+v", contact)
+	}
+	if alice.Messenger.HasSession(bob.PubKey) || bob.Messenger.HasSession(alice.PubKey) {
+		t.Error("Session established before the request was accepted")
+	}
+
+	// После принятия переписка идёт как обычно
+	if err := bob.AcceptMessageRequest(requests[0].ID); err != nil {
+		t.Fatalf("AcceptMessageRequest failed: %v", err)
+	}
+	if err := bob.SendText(bob.ContactOf(alice).ID, "welcome", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	alice.WaitMessage("welcome")
+}
+
+func TestFileOffer(t *testing.T) {
+	c := Start(t, "alice", "bob")
+	alice, bob := c.Nodes[0], c.Nodes[1]
+	c.Network.SetConditions(loopback.Conditions{Latency: 2 * time.Millisecond})
+	contact := c.Introduce(alice, bob, bob.Dest)
+
+	// Несколько частей, последняя неполная
+	data := make([]byte, 150*1024)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("rand failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "report.bin")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := alice.SendFileMessage(contact.ID, "", "", []string{path}, true); err != nil {
+		t.Fatalf("SendFileMessage failed: %v", err)
+	}
+	offer := bob.WaitEvent("new_message", func(data interface{}) bool {
+		m, ok := data.(map[string]interface{})
+		return ok && m["ContentType"] == "file_offer"
+	}).(map[string]interface{})
+	messageID := offer["ID"].(string)
+
+	if err := bob.AcceptFileTransfer(messageID); err != nil {
+		t.Fatalf("AcceptFileTransfer failed: %v", err)
+	}
+
+	var msg *core.Message
+	Eventually(t, "file received", func() bool {
+		msg, _ = bob.Repo.GetMessage(bob.Ctx, messageID)
+		return msg != nil && msg.ContentType == "mixed"
+	})
+	if len(msg.Attachments) != 1 || msg.Attachments[0].Filename != "report.bin" {
+		t.Fatalf("Unexpected attachments %+v", msg.Attachments)
+	}
+	got, err := os.ReadFile(msg.Attachments[0].LocalPath)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Received file differs from the original (%v)", err)
+	}
+}
+
+func TestProfileSync(t *testing.T) {
+	c := Start(t, "alice", "bob")
+	alice, bob := c.Nodes[0], c.Nodes[1]
+	c.Introduce(alice, bob, bob.Dest)
+
+	// Принимая запрос, Боб запросил профиль Алисы
+	Eventually(t, "initial profile", func() bool {
+		contact := bob.ContactOf(alice)
+		return contact != nil && contact.Nickname == "alice"
+	})
+
+	if err := bob.UpdateMyProfile("Robert", "likes tea", ""); err != nil {
+		t.Fatalf("UpdateMyProfile failed: %v", err)
+	}
+	if err := alice.RequestProfile(bob.Dest); err != nil {
+		t.Fatalf("RequestProfile failed: %v", err)
+	}
+	Eventually(t, "updated profile", func() bool {
+		contact := alice.ContactOf(bob)
+		return contact != nil && contact.Nickname == "Robert" && contact.Bio == "likes tea"
+	})
+}
+
+func TestReconnect(t *testing.T) {
+	c := Start(t, "alice", "bob")
+	alice, bob := c.Nodes[0], c.Nodes[1]
+	contact := c.Introduce(alice, bob, bob.Dest)
+
+	// Уходя, Боб сообщает, что он не в сети
+	bob.Logout()
+	alice.WaitEvent("presence", func(data interface{}) bool {
+		m, ok := data.(map[string]interface{})
+		return ok && m["ChatID"] == alice.ChatID(bob) && m["Online"] == false
+	})
+	if err := alice.SendText(contact.ID, "are you there?", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	messageID := lastOutgoing(t, alice, "are you there?")
+	Eventually(t, "failed delivery attempt", func() bool {
+		entries, _ := alice.Repo.ListOutbox(alice.Ctx)
+		return len(entries) == 1 && entries[0].Attempts > 0
+	})
+
+	// Боб вернулся с тем же адресом и сессией; его сообщение будит очередь Алисы
+	bob.Reconnect()
+	if err := bob.SendText(bob.ContactOf(alice).ID, "back online", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	alice.WaitMessage("back online")
+	bob.WaitMessage("are you there?")
+	messageStatus(alice, messageID, core.MessageStatusDelivered)
+}
+
+func TestUnreliableNetwork(t *testing.T) {
+	c := Start(t, "alice", "bob", "carol")
+	alice, bob, carol := c.Nodes[0], c.Nodes[1], c.Nodes[2]
+	toBob := c.Introduce(alice, bob, bob.Dest)
+	toCarol := c.Introduce(alice, carol, carol.Dest)
+	c.Network.SetConditions(loopback.Conditions{Latency: 3 * time.Millisecond, Jitter: 3 * time.Millisecond})
+
+	// Боб отрезан: письмо ему ждёт в очереди, Кэрол доступна
+	c.Network.Partition([]string{bob.Dest})
+	if err := alice.SendText(toBob.ID, "across the split", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	if err := alice.SendText(toCarol.ID, "same side", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	carol.WaitMessage("same side")
+	Eventually(t, "failed delivery across partition", func() bool {
+		entries, _ := alice.Repo.ListOutbox(alice.Ctx)
+		return len(entries) == 1 && entries[0].Attempts > 0
+	})
+
+	c.Network.Heal()
+	if err := alice.RetryMessage(lastOutgoing(t, alice, "across the split")); err != nil {
+		t.Fatalf("RetryMessage failed: %v", err)
+	}
+	bob.WaitMessage("across the split")
+
+	// Обрыв соединений: первая попытка падает на мёртвом соединении, повтор открывает новое
+	alice.Router.DropConnections()
+	if err := alice.SendText(toBob.ID, "after drop", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	messageID := lastOutgoing(t, alice, "after drop")
+	Eventually(t, "delivery after drop", func() bool {
+		msg, _ := alice.Repo.GetMessage(alice.Ctx, messageID)
+		if msg != nil && msg.Status != core.MessageStatusPending {
+			return true
+		}
+		if entries, _ := alice.Repo.ListOutbox(alice.Ctx); len(entries) == 1 && entries[0].Attempts > 0 {
+			_ = alice.RetryMessage(messageID)
+		}
+		return false
+	})
+	bob.WaitMessage("after drop")
+}
+
+func TestOfflineEditAndDelete(t *testing.T) {
+	c := Start(t, "alice", "bob")
+	alice, bob := c.Nodes[0], c.Nodes[1]
+	contact := c.Introduce(alice, bob, bob.Dest)
+
+	for _, text := range []string{"draft", "oops"} {
+		if err := alice.SendText(contact.ID, text, ""); err != nil {
+			t.Fatalf("SendText failed: %v", err)
+		}
+		bob.WaitMessage(text)
+	}
+	draftID, oopsID := lastOutgoing(t, alice, "draft"), lastOutgoing(t, alice, "oops")
+
+	// Боб отрезан: правка и удаление ждут в очереди
+	c.Network.Partition([]string{bob.Dest})
+	if err := alice.EditMessage(draftID, "final"); err != nil {
+		t.Fatalf("EditMessage failed: %v", err)
+	}
+	if err := alice.DeleteMessageForAll(oopsID); err != nil {
+		t.Fatalf("DeleteMessageForAll failed: %v", err)
+	}
+	Eventually(t, "failed change delivery", func() bool {
+		changes, _ := alice.Repo.ListMessageChanges(alice.Ctx)
+		return len(changes) == 2 && changes[0].Attempts > 0
+	})
+
+	// Боб вернулся: его сообщение будит очередь Алисы. Первая попытка падает на
+	// соединении, оборванном разделом, повтор открывает новое
+	c.Network.Heal()
+	if err := bob.SendText(bob.ContactOf(alice).ID, "back", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	backID := lastOutgoing(t, bob, "back")
+	Eventually(t, "first attempt after heal", func() bool {
+		msg, _ := bob.Repo.GetMessage(bob.Ctx, backID)
+		entries, _ := bob.Repo.ListOutbox(bob.Ctx)
+		return msg != nil && (msg.Status != core.MessageStatusPending || len(entries) == 1 && entries[0].Attempts > 0)
+	})
+	if msg, _ := bob.Repo.GetMessage(bob.Ctx, backID); msg != nil && msg.Status == core.MessageStatusPending {
+		if err := bob.RetryMessage(backID); err != nil {
+			t.Fatalf("RetryMessage failed: %v", err)
+		}
+	}
+	alice.WaitMessage("back")
+	Eventually(t, "edit and delete delivered", func() bool {
+		draft, _ := bob.Repo.GetMessage(bob.Ctx, draftID)
+		oops, _ := bob.Repo.GetMessage(bob.Ctx, oopsID)
+		return draft != nil && draft.Content == "final" && oops == nil
+	})
+	Eventually(t, "change queue drained", func() bool {
+		changes, _ := alice.Repo.ListMessageChanges(alice.Ctx)
+		return len(changes) == 0
+	})
+}
+
+func TestMessageIDReuse(t *testing.T) {
+	c := Start(t, "alice", "bob")
+	alice, bob := c.Nodes[0], c.Nodes[1]
+	contact := c.Introduce(alice, bob, bob.Dest)
+
+	if err := alice.SendText(contact.ID, "mine", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	bob.WaitMessage("mine")
+	messageID := lastOutgoing(t, alice, "mine")
+
+	// Боб присылает новое сообщение с ID сообщения Алисы: оно не должно его перезаписать
+	if err := bob.Messenger.SendTextMessageWithID(alice.Dest, alice.ChatID(bob), messageID, "rewritten", ""); err != nil {
+		t.Fatalf("SendTextMessageWithID failed: %v", err)
+	}
+	if err := bob.SendText(bob.ContactOf(alice).ID, "marker", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	alice.WaitMessage("marker")
+
+	msg, err := alice.Repo.GetMessage(alice.Ctx, messageID)
+	if err != nil || msg == nil || msg.Content != "mine" || !msg.IsOutgoing {
+		t.Fatalf("Message was overwritten: %+v (%v)", msg, err)
+	}
+}
+
+func TestGroupInviteFromStranger(t *testing.T) {
+	c := Start(t, "alice", "bob", "carol")
+	alice, bob, carol := c.Nodes[0], c.Nodes[1], c.Nodes[2]
+	c.Introduce(alice, bob, bob.Dest)
+	c.Introduce(bob, carol, carol.Dest)
+	c.Introduce(alice, carol, carol.Dest)
+
+	// Общая группа bob: приглашение от контакта принимается сразу, alice для carol — участница группы
+	common, err := bob.CreateGroup("common", []string{bob.ContactOf(alice).ID, bob.ContactOf(carol).ID})
+	if err != nil {
+		t.Fatalf("CreateGroup failed: %v", err)
+	}
+	Eventually(t, "carol joins the common group", func() bool {
+		g, _ := carol.Repo.GetGroup(carol.Ctx, common.ID)
+		return g != nil && !g.IsPending
+	})
+
+	// alice снова в запросах переписки carol: её приглашение ждёт решения
+	if err := carol.Repo.SetContactPending(carol.Ctx, carol.ContactOf(alice).ID, true); err != nil {
+		t.Fatalf("SetContactPending failed: %v", err)
+	}
+	private, err := alice.CreateGroup("private", []string{alice.ContactOf(carol).ID})
+	if err != nil {
+		t.Fatalf("CreateGroup failed: %v", err)
+	}
+	carol.WaitEvent("group_invite", func(data interface{}) bool {
+		m, ok := data.(map[string]interface{})
+		return ok && m["GroupID"] == private.ID
+	})
+	if groups, _ := carol.GetGroups(); len(groups) != 1 || groups[0].ID != common.ID {
+		t.Fatalf("Invite listed among groups: %+v", groups)
+	}
+	if invites, _ := carol.GetGroupInvites(); len(invites) != 1 || invites[0].ID != private.ID {
+		t.Fatalf("Unexpected invites: %+v", invites)
+	}
+	if ids, _ := carol.Repo.ListGroupIDsByMember(carol.Ctx, alice.PubKey); len(ids) != 1 || ids[0] != common.ID {
+		t.Errorf("Pending group relates carol to alice: %v", ids)
+	}
+
+	// Сообщение в непринятой группе лежит в карантине без счётчика
+	if err := alice.SendText(private.ID, "secret plans", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	messageID := lastOutgoing(t, alice, "secret plans")
+	Eventually(t, "carol stores the quarantined message", func() bool {
+		msg, _ := carol.Repo.GetMessage(carol.Ctx, messageID)
+		return msg != nil
+	})
+	if n, _ := carol.GetUnreadCount(); n != 0 {
+		t.Errorf("Quarantined message counted as unread: %d", n)
+	}
+	if err := carol.SendText(private.ID, "reply", ""); err == nil {
+		t.Error("Expected sending to an unaccepted group to fail")
+	}
+
+	if err := carol.AcceptGroupInvite(private.ID); err != nil {
+		t.Fatalf("AcceptGroupInvite failed: %v", err)
+	}
+	if groups, _ := carol.GetGroups(); len(groups) != 2 {
+		t.Errorf("Accepted group not listed: %+v", groups)
+	}
+	if n, _ := carol.GetUnreadCount(); n != 1 {
+		t.Errorf("Expected the quarantined message to become unread, got %d", n)
+	}
+	if err := carol.SendText(private.ID, "count me in", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	alice.WaitMessage("count me in")
+}
+
+func TestGroupCannotReuseChatID(t *testing.T) {
+	c := Start(t, "alice", "bob")
+	alice, bob := c.Nodes[0], c.Nodes[1]
+	c.Introduce(alice, bob, bob.Dest)
+	channel, err := bob.CreateChannel("news")
+	if err != nil {
+		t.Fatalf("CreateChannel failed: %v", err)
+	}
+
+	// ID группы выбирает её создатель: личный чат, не UUID, канал и ID самого bob не годятся
+	for _, id := range []string{bob.ChatID(alice), "not-a-uuid", channel.ID, bob.Identity.Keys.UserID} {
+		state := &pb.GroupState{GroupId: id, Name: "trap", Epoch: 1, Members: []*pb.GroupMember{
+			{PubKey: alice.PubKey, I2PAddress: alice.Dest, Role: pb.GroupRole_GROUP_ROLE_ADMIN},
+			{PubKey: bob.PubKey, I2PAddress: bob.Dest},
+		}}
+		alice.Messenger.SignGroupState(state)
+		if err := alice.Messenger.SendGroupControl(bob.Dest, pb.GroupAction_GROUP_INVITE, id, state); err != nil {
+			t.Fatalf("SendGroupControl failed: %v", err)
+		}
+		if err := alice.Messenger.SendGroupTextMessage(bob.Dest, id, 1, uuid.New().String(), "injected", "", time.Now().UnixMilli()); err != nil {
+			t.Fatalf("SendGroupTextMessage failed: %v", err)
+		}
+	}
+	if err := alice.SendText(alice.ContactOf(bob).ID, "marker", ""); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	bob.WaitMessage("marker")
+
+	if groups, _ := bob.GetGroups(); len(groups) != 0 {
+		t.Errorf("Group with a taken ID accepted: %+v", groups)
+	}
+	if invites, _ := bob.GetGroupInvites(); len(invites) != 0 {
+		t.Errorf("Invite with a taken ID stored: %+v", invites)
+	}
+	messages, err := bob.GetMessages(bob.ContactOf(alice).ID, 50, 0)
+	if err != nil {
+		t.Fatalf("GetMessages failed: %v", err)
+	}
+	for _, m := range messages {
+		if m.Content == "injected" {
+			t.Errorf("Group message shown in the private chat: %+v", m)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package envelope описывает подписываемую часть пакета TeleGhost и proof-of-work штампы.
+// Им пользуются мессенджер и почтовый сервер: сервер проверяет подпись и штамп письма,
+// не расшифровывая его.
+package envelope
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"teleghost/internal/core/identity"
+	pb "teleghost/internal/proto"
+)
+
+// domain отделяет подпись пакета от других подписей того же ключа
+const domain = "TeleGhost/packet/v3"
+
+var (
+	// ErrUnsigned — пакет без подписи или старой версии, где подпись не покрывает адресата
+	ErrUnsigned = errors.New("unsigned packet")
+	// ErrBadSignature — подпись не сходится с ключом отправителя
+	ErrBadSignature = errors.New("invalid signature")
+)
+
+// SignedData — данные, которые покрывает подпись пакета
+func SignedData(packet *pb.Packet) []byte {
+	switch {
+	case packet.Version < 2:
+		// Версия 1: подписан только payload
+		return packet.Payload
+	case packet.Version == 2:
+		// Версия 2: payload + заголовок свежести
+		data := make([]byte, 0, len(packet.Payload)+8+len(packet.Nonce))
+		data = append(data, packet.Payload...)
+		data = binary.BigEndian.AppendUint64(data, uint64(packet.Timestamp)) // #nosec G115
+		return append(data, packet.Nonce...)
+	default:
+		return canonical(packet)
+	}
+}
+
+// canonical сериализует подписываемые поля пакета в фиксированном порядке.
+// Поля переменной длины предваряются длиной, поэтому границы нельзя сдвинуть.
+func canonical(packet *pb.Packet) []byte {
+	size := len(domain) + 4 + 4 + 8 + 6*4 +
+		len(packet.SenderPubKey) + len(packet.RecipientPubKey) + len(packet.SessionId) + len(packet.Nonce) + len(packet.Payload) + len(packet.SealedEphemeral)
+
+	data := make([]byte, 0, size)
+	data = append(data, domain...)
+	data = binary.BigEndian.AppendUint32(data, packet.Version)
+	data = binary.BigEndian.AppendUint32(data, uint32(packet.Type)) // #nosec G115
+	data = appendField(data, packet.SenderPubKey)
+	data = appendField(data, packet.RecipientPubKey)
+	data = appendField(data, packet.SessionId)
+	data = binary.BigEndian.AppendUint64(data, uint64(packet.Timestamp)) // #nosec G115
+	data = appendField(data, packet.Nonce)
+	data = appendField(data, packet.Payload)
+	// Ключ запечатанного сообщения дописывается, только если он есть: подписи остальных пакетов не меняются
+	if len(packet.SealedEphemeral) > 0 {
+		data = appendField(data, packet.SealedEphemeral)
+	}
+	return data
+}
+
+// VerifySender проверяет подпись пакета ключом отправителя из самого пакета.
+// Принимаются только пакеты версии 3+, где подпись покрывает тип, адресата и заголовок.
+func VerifySender(packet *pb.Packet) error {
+	if len(packet.Signature) == 0 || packet.Version < 3 {
+		return ErrUnsigned
+	}
+	valid, err := identity.VerifySignatureBase64(string(packet.SenderPubKey), SignedData(packet), packet.Signature)
+	if err != nil || !valid {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+func appendField(data, field []byte) []byte {
+	data = binary.BigEndian.AppendUint32(data, uint32(len(field))) // #nosec G115
+	return append(data, field...)
+}
@@ -0,0 +1,39 @@
+package envelope
+
+import (
+	"testing"
+
+	pb "teleghost/internal/proto"
+)
+
+func TestSignedDataCoversHeader(t *testing.T) {
+	packet := &pb.Packet{Version: 3, Payload: []byte("payload"), Timestamp: 1, Nonce: []byte("nonce")}
+	signed := SignedData(packet)
+
+	packet.Timestamp = 2
+	if string(SignedData(packet)) == string(signed) {
+		t.Error("Timestamp is not covered by signature")
+	}
+
+	// Границы полей нельзя сдвинуть: перенос байта из nonce в payload меняет подпись
+	a := &pb.Packet{Version: 3, Nonce: []byte("ab"), Payload: []byte("c")}
+	b := &pb.Packet{Version: 3, Nonce: []byte("a"), Payload: []byte("bc")}
+	if string(SignedData(a)) == string(SignedData(b)) {
+		t.Error("Envelope fields are ambiguous")
+	}
+}
+
+func TestSignedDataCoversSealedEphemeral(t *testing.T) {
+	packet := &pb.Packet{Version: 3, Payload: []byte("payload")}
+	plain := SignedData(packet)
+
+	packet.SealedEphemeral = []byte("ephemeral")
+	sealed := SignedData(packet)
+	if string(sealed) == string(plain) {
+		t.Error("Sealed ephemeral key is not covered by signature")
+	}
+	packet.SealedEphemeral = []byte("other")
+	if string(SignedData(packet)) == string(sealed) {
+		t.Error("Sealed ephemeral key can be swapped")
+	}
+}
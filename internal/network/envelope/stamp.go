@@ -0,0 +1,152 @@
+package envelope
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+
+	pb "teleghost/internal/proto"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Proof-of-work штампы (hashcash): незнакомец тратит время процессора на каждый пакет,
+// которым начинает разговор, поэтому засыпать нас контактами дорого. Контактам штамп не нужен.
+
+const (
+	// stampDomain отделяет хэши штампов от других хэшей
+	stampDomain = "TeleGhost/stamp/v1"
+
+	// DefaultStampBitsSHA256 — сложность штампа SHA-256 по умолчанию (~миллион хэшей)
+	DefaultStampBitsSHA256 = 20
+	// DefaultStampBitsArgon2 — сложность штампа Argon2id по умолчанию (~250 хэшей по 1 МБ)
+	DefaultStampBitsArgon2 = 8
+
+	// MaxStampBitsSHA256 и MaxStampBitsArgon2 — предел сложности, чтобы отправка не зависала
+	MaxStampBitsSHA256 = 28
+	MaxStampBitsArgon2 = 14
+
+	// argon2StampMemory — память Argon2id на один хэш, КБ
+	argon2StampMemory = 1024
+
+	// argon2BitsOffset — на столько бит штамп Argon2id дороже штампа SHA-256 той же сложности
+	// (сложности по умолчанию равны по времени)
+	argon2BitsOffset = DefaultStampBitsSHA256 - DefaultStampBitsArgon2
+)
+
+// StampPolicy — алгоритм и сложность proof-of-work штампов
+type StampPolicy struct {
+	Algorithm pb.StampAlgorithm // чем считаем свои штампы
+	Bits      uint32            // сложность своих штампов и минимальная для входящих того же алгоритма
+}
+
+// DefaultStampPolicy возвращает политику по умолчанию (SHA-256)
+func DefaultStampPolicy() StampPolicy {
+	return StampPolicy{Algorithm: pb.StampAlgorithm_STAMP_SHA256, Bits: DefaultStampBitsSHA256}
+}
+
+// Normalize ограничивает сложность пределами алгоритма
+func (p StampPolicy) Normalize() StampPolicy {
+	def, limit := stampLimits(p.Algorithm)
+	if p.Bits == 0 {
+		p.Bits = def
+	}
+	if p.Bits > limit {
+		p.Bits = limit
+	}
+	return p
+}
+
+// Required — минимальная сложность входящего штампа. Отправитель не знает наших настроек,
+// поэтому штамп другим алгоритмом принимаем, если он стоит столько же работы, сколько наш.
+func (p StampPolicy) Required(alg pb.StampAlgorithm) uint32 {
+	if alg == p.Algorithm {
+		return p.Bits
+	}
+	if alg == pb.StampAlgorithm_STAMP_ARGON2 {
+		return max(p.Bits, argon2BitsOffset+1) - argon2BitsOffset
+	}
+	_, limit := stampLimits(alg)
+	return min(p.Bits+argon2BitsOffset, limit)
+}
+
+// Accepts проверяет, что штамп пакета, привязанный к recipient, достаточен по этой политике
+func (p StampPolicy) Accepts(packet *pb.Packet, recipient string) bool {
+	if packet.Stamp == nil {
+		return false
+	}
+	return CheckStamp(packet, recipient, p.Required(packet.Stamp.Algorithm))
+}
+
+func stampLimits(alg pb.StampAlgorithm) (def, limit uint32) {
+	if alg == pb.StampAlgorithm_STAMP_ARGON2 {
+		return DefaultStampBitsArgon2, MaxStampBitsArgon2
+	}
+	return DefaultStampBitsSHA256, MaxStampBitsSHA256
+}
+
+// StampRecipient — к чему привязан штамп: ключ получателя, а если он ещё неизвестен — его адрес
+func StampRecipient(packet *pb.Packet, destination string) string {
+	if len(packet.RecipientPubKey) > 0 {
+		return string(packet.RecipientPubKey)
+	}
+	return destination
+}
+
+// stampPrefix сериализует всё, к чему привязан штамп, кроме счётчика
+func stampPrefix(packet *pb.Packet, recipient string, alg pb.StampAlgorithm) []byte {
+	payloadHash := sha256.Sum256(packet.Payload)
+	data := []byte(stampDomain)
+	data = binary.BigEndian.AppendUint32(data, uint32(alg))         // #nosec G115
+	data = binary.BigEndian.AppendUint32(data, uint32(packet.Type)) // #nosec G115
+	data = appendField(data, packet.SenderPubKey)
+	data = appendField(data, []byte(recipient))
+	data = binary.BigEndian.AppendUint64(data, uint64(packet.Timestamp)) // #nosec G115
+	data = appendField(data, packet.Nonce)
+	return appendField(data, payloadHash[:])
+}
+
+// stampHash — хэш префикса со счётчиком выбранным алгоритмом
+func stampHash(alg pb.StampAlgorithm, prefix []byte, counter uint64) []byte {
+	data := binary.BigEndian.AppendUint64(prefix[:len(prefix):len(prefix)], counter)
+	if alg == pb.StampAlgorithm_STAMP_ARGON2 {
+		return argon2.IDKey(data, []byte(stampDomain), 1, argon2StampMemory, 1, 32)
+	}
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// MintStamp подбирает счётчик, при котором хэш начинается с need нулевых бит.
+// Пакет уже зашифрован и подписан: штамп в подпись не входит.
+func MintStamp(packet *pb.Packet, recipient string, alg pb.StampAlgorithm, need uint32) *pb.Stamp {
+	prefix := stampPrefix(packet, recipient, alg)
+	for counter := uint64(0); ; counter++ {
+		if leadingZeroBits(stampHash(alg, prefix, counter)) >= need {
+			return &pb.Stamp{Algorithm: alg, Counter: counter}
+		}
+	}
+}
+
+// CheckStamp проверяет штамп пакета: одно вычисление хэша
+func CheckStamp(packet *pb.Packet, recipient string, need uint32) bool {
+	if packet.Stamp == nil {
+		return false
+	}
+	alg := packet.Stamp.Algorithm
+	if _, ok := pb.StampAlgorithm_name[int32(alg)]; !ok {
+		return false
+	}
+	hash := stampHash(alg, stampPrefix(packet, recipient, alg), packet.Stamp.Counter)
+	return leadingZeroBits(hash) >= need
+}
+
+func leadingZeroBits(hash []byte) uint32 {
+	var n uint32
+	for _, b := range hash {
+		if b != 0 {
+			return n + uint32(bits.LeadingZeros8(b)) // #nosec G115
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,78 @@
+package envelope
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	pb "teleghost/internal/proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestStampBinding(t *testing.T) {
+	packet := &pb.Packet{
+		Type:         pb.PacketType_TEXT_MESSAGE,
+		SenderPubKey: []byte("alice"),
+		Payload:      []byte("hello"),
+		Timestamp:    time.Now().UnixMilli(),
+		Nonce:        []byte("nonce"),
+	}
+
+	for _, tc := range []struct {
+		alg  pb.StampAlgorithm
+		bits uint32
+	}{{pb.StampAlgorithm_STAMP_SHA256, 12}, {pb.StampAlgorithm_STAMP_ARGON2, 3}} {
+		stamped := proto.Clone(packet).(*pb.Packet)
+		stamped.Stamp = MintStamp(stamped, "bob", tc.alg, tc.bits)
+		if !CheckStamp(stamped, "bob", tc.bits) {
+			t.Fatalf("%v: valid stamp rejected", tc.alg)
+		}
+
+		// Штамп не переносится на другого получателя, время или payload: хэш с тем же счётчиком другой
+		hash := func(p *pb.Packet, recipient string) []byte {
+			return stampHash(tc.alg, stampPrefix(p, recipient, tc.alg), stamped.Stamp.Counter)
+		}
+		original := hash(stamped, "bob")
+		if bytes.Equal(hash(stamped, "carol"), original) {
+			t.Errorf("%v: stamp not bound to recipient", tc.alg)
+		}
+		moved := proto.Clone(stamped).(*pb.Packet)
+		moved.Timestamp++
+		if bytes.Equal(hash(moved, "bob"), original) {
+			t.Errorf("%v: stamp not bound to timestamp", tc.alg)
+		}
+		moved = proto.Clone(stamped).(*pb.Packet)
+		moved.Payload = []byte("spam")
+		if bytes.Equal(hash(moved, "bob"), original) {
+			t.Errorf("%v: stamp not bound to payload", tc.alg)
+		}
+		if CheckStamp(stamped, "bob", 64) {
+			t.Errorf("%v: stamp accepted above its difficulty", tc.alg)
+		}
+	}
+}
+
+func TestStampRequiredEquivalentWork(t *testing.T) {
+	sha := pb.StampAlgorithm_STAMP_SHA256
+	argon := pb.StampAlgorithm_STAMP_ARGON2
+
+	for _, tc := range []struct {
+		policy StampPolicy
+		alg    pb.StampAlgorithm
+		want   uint32
+	}{
+		{DefaultStampPolicy(), sha, DefaultStampBitsSHA256},
+		{DefaultStampPolicy(), argon, DefaultStampBitsArgon2},
+		// Повышенная сложность действует и на чужой алгоритм
+		{StampPolicy{Algorithm: sha, Bits: 24}, argon, 12},
+		{StampPolicy{Algorithm: argon, Bits: 10}, sha, 22},
+		{StampPolicy{Algorithm: argon, Bits: MaxStampBitsArgon2}, sha, 26},
+		// Лёгкий SHA-256 не опускает Argon2id до нуля бит
+		{StampPolicy{Algorithm: sha, Bits: 8}, argon, 1},
+	} {
+		if got := tc.policy.Required(tc.alg); got != tc.want {
+			t.Errorf("%+v: Required(%v) = %d, want %d", tc.policy, tc.alg, got, tc.want)
+		}
+	}
+}
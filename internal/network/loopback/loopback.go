@@ -0,0 +1,570 @@
+// Package loopback — сеть в памяти, реализующая network.NetworkRouter. Несколько роутеров одной
+// Network соединяются друг с другом без i2pd: так в тестах можно прогнать целые переписки.
+// Условия сети (задержка, потери, обрывы, разделение) задаются через SetConditions и Partition.
+package loopback
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand/v2"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"teleghost/internal/network"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+var (
+	// ErrUnreachable — по адресу нет запущенного роутера (или он за разделом сети)
+	ErrUnreachable = errors.New("destination unreachable")
+	// ErrConnectionReset — соединение оборвала сеть
+	ErrConnectionReset = errors.New("connection reset by network")
+)
+
+// i2pEncoding — base64 с алфавитом I2P: адреса выглядят как настоящие destination
+var i2pEncoding = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-~")
+
+// destinationSize — размер двоичного destination без сертификата
+const destinationSize = 387
+
+// Conditions — условия сети. Нулевое значение — идеальная сеть.
+type Conditions struct {
+	Latency time.Duration // задержка установки соединения и доставки каждой записи
+	Jitter  time.Duration // случайная добавка к задержке (порядок данных сохраняется)
+
+	// Loss — доля потерянных попыток соединения. Поверх I2P streaming потеря пакетов
+	// видна приложению только как соединение, которое не удалось установить.
+	Loss float64
+	// DropRate — вероятность, что запись оборвёт соединение (данные записи теряются)
+	DropRate float64
+}
+
+// Network — общая сеть для роутеров
+type Network struct {
+	mu         sync.Mutex
+	routers    map[string]*Router
+	conditions Conditions
+	partition  map[string]int // адрес -> номер части сети
+}
+
+// NewNetwork создаёт пустую сеть
+func NewNetwork() *Network {
+	return &Network{routers: make(map[string]*Router)}
+}
+
+// SetConditions меняет условия сети; действует и на открытые соединения
+func (n *Network) SetConditions(c Conditions) {
+	n.mu.Lock()
+	n.conditions = c
+	n.mu.Unlock()
+}
+
+// Partition делит сеть: узлы из разных групп не видят друг друга, узлы вне групп
+// образуют ещё одну часть. Соединения через границу обрываются.
+func (n *Network) Partition(groups ...[]string) {
+	n.mu.Lock()
+	n.partition = make(map[string]int)
+	for i, group := range groups {
+		for _, dest := range group {
+			n.partition[dest] = i + 1
+		}
+	}
+	n.mu.Unlock()
+
+	for _, c := range n.connections() {
+		if !n.reachable(c.local, c.remote) {
+			c.reset()
+		}
+	}
+}
+
+// Heal убирает разделение сети
+func (n *Network) Heal() {
+	n.mu.Lock()
+	n.partition = nil
+	n.mu.Unlock()
+}
+
+// NewRouter создаёт роутер со случайным адресом. До Start он недоступен для других.
+func (n *Network) NewRouter() *Router {
+	raw := make([]byte, destinationSize)
+	if _, err := rand.Read(raw); err != nil {
+		panic(fmt.Sprintf("loopback: %v", err))
+	}
+	dest := i2pEncoding.EncodeToString(raw)
+	return &Router{
+		network:  n,
+		dest:     dest,
+		b32:      i2pkeys.I2PAddr(dest).Base32(),
+		incoming: make(chan *conn, 16),
+		conns:    make(map[*conn]bool),
+	}
+}
+
+// lookup находит запущенный роутер по полному адресу или по b32
+func (n *Network) lookup(dest string) *Router {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if r := n.routers[dest]; r != nil {
+		return r
+	}
+	if !strings.HasSuffix(strings.ToLower(dest), ".b32.i2p") {
+		return nil
+	}
+	for _, r := range n.routers {
+		if strings.EqualFold(r.b32, dest) {
+			return r
+		}
+	}
+	return nil
+}
+
+// reachable — узлы в одной части сети
+func (n *Network) reachable(a, b string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.partition[a] == n.partition[b]
+}
+
+// connections возвращает все открытые соединения запущенных роутеров
+func (n *Network) connections() []*conn {
+	n.mu.Lock()
+	routers := make([]*Router, 0, len(n.routers))
+	for _, r := range n.routers {
+		routers = append(routers, r)
+	}
+	n.mu.Unlock()
+
+	var conns []*conn
+	for _, r := range routers {
+		conns = append(conns, r.connections()...)
+	}
+	return conns
+}
+
+// delay возвращает задержку очередной доставки
+func (n *Network) delay() time.Duration {
+	n.mu.Lock()
+	c := n.conditions
+	n.mu.Unlock()
+	if c.Jitter > 0 {
+		return c.Latency + mrand.N(c.Jitter) // #nosec G404 -- имитация сети, не криптография
+	}
+	return c.Latency
+}
+
+// lost — случилось событие с вероятностью, которую выбирает pick
+func (n *Network) lost(pick func(Conditions) float64) bool {
+	n.mu.Lock()
+	p := pick(n.conditions)
+	n.mu.Unlock()
+	return p > 0 && mrand.Float64() < p // #nosec G404 -- имитация сети, не криптография
+}
+
+// Router — узел сети в памяти
+type Router struct {
+	network  *Network
+	dest     string
+	b32      string
+	incoming chan *conn
+
+	mu    sync.Mutex
+	ready bool
+	done  chan struct{}
+	conns map[*conn]bool
+}
+
+// Start делает роутер доступным по его адресу
+func (r *Router) Start(_ context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ready {
+		return nil
+	}
+	r.ready = true
+	r.done = make(chan struct{})
+
+	r.network.mu.Lock()
+	r.network.routers[r.dest] = r
+	r.network.mu.Unlock()
+	return nil
+}
+
+// Stop отключает роутер от сети и рвёт все его соединения
+func (r *Router) Stop() error {
+	r.network.mu.Lock()
+	delete(r.network.routers, r.dest)
+	r.network.mu.Unlock()
+
+	r.mu.Lock()
+	if !r.ready {
+		r.mu.Unlock()
+		return nil
+	}
+	r.ready = false
+	close(r.done)
+	conns := r.conns
+	r.conns = make(map[*conn]bool)
+	r.mu.Unlock()
+
+	for c := range conns {
+		_ = c.Close()
+	}
+	return nil
+}
+
+// DropConnections обрывает все соединения роутера, как при перестройке туннелей
+func (r *Router) DropConnections() {
+	for _, c := range r.connections() {
+		c.reset()
+	}
+}
+
+// GetAddress возвращает адрес роутера
+func (r *Router) GetAddress() (string, error) {
+	return r.dest, nil
+}
+
+// IsReady — роутер запущен
+func (r *Router) IsReady() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ready
+}
+
+// Connect открывает соединение с запущенным роутером той же сети (по полному адресу или b32)
+func (r *Router) Connect(ctx context.Context, destination string) (network.Connection, error) {
+	if !r.IsReady() {
+		return nil, fmt.Errorf("router not started")
+	}
+	if d := r.network.delay(); d > 0 {
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+
+	target := r.network.lookup(destination)
+	if target == nil || !r.network.reachable(r.dest, target.dest) {
+		return nil, ErrUnreachable
+	}
+	if r.network.lost(func(c Conditions) float64 { return c.Loss }) {
+		return nil, ErrUnreachable
+	}
+
+	local, remote := newConnPair(r.network, r.dest, target.dest)
+	if !r.track(local) || !target.track(remote) {
+		_ = local.Close()
+		_ = remote.Close()
+		return nil, ErrUnreachable
+	}
+
+	target.mu.Lock()
+	done := target.done
+	target.mu.Unlock()
+
+	select {
+	case target.incoming <- remote:
+		return local, nil
+	case <-done:
+		_ = local.Close()
+		_ = remote.Close()
+		return nil, ErrUnreachable
+	case <-ctx.Done():
+		_ = local.Close()
+		_ = remote.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// Accept ждёт входящее соединение; после Stop возвращает net.ErrClosed
+func (r *Router) Accept(ctx context.Context) (network.Connection, error) {
+	r.mu.Lock()
+	done := r.done
+	r.mu.Unlock()
+	if done == nil {
+		return nil, fmt.Errorf("router not started")
+	}
+
+	select {
+	case c := <-r.incoming:
+		return c, nil
+	case <-done:
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// track запоминает соединение, чтобы разорвать его при Stop
+func (r *Router) track(c *conn) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.ready {
+		return false
+	}
+	r.conns[c] = true
+	c.mu.Lock()
+	c.onClose = func() {
+		r.mu.Lock()
+		delete(r.conns, c)
+		r.mu.Unlock()
+	}
+	c.mu.Unlock()
+	return true
+}
+
+func (r *Router) connections() []*conn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conns := make([]*conn, 0, len(r.conns))
+	for c := range r.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+var _ network.NetworkRouter = (*Router)(nil)
+
+// Addr — адрес узла сети в памяти
+type Addr string
+
+// Network возвращает имя сети
+func (a Addr) Network() string { return "loopback" }
+
+func (a Addr) String() string { return string(a) }
+
+// segment — запись, которая ещё «в пути»
+type segment struct {
+	data []byte
+	at   time.Time
+}
+
+// pipe — буфер одного направления. Запись не блокируется, как у сокета с большим буфером:
+// иначе два узла, отвечающие друг другу из обработчиков, зависли бы на записи.
+type pipe struct {
+	mu      sync.Mutex
+	buf     []byte
+	transit []segment // записи с задержкой, по возрастанию времени доставки
+	closed  bool
+	broken  bool
+	wake    chan struct{}
+}
+
+func newPipe() *pipe {
+	return &pipe{wake: make(chan struct{})}
+}
+
+// signalLocked будит ждущих читателей
+func (p *pipe) signalLocked() {
+	close(p.wake)
+	p.wake = make(chan struct{})
+}
+
+func (p *pipe) write(b []byte, delay time.Duration) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.broken {
+		return 0, ErrConnectionReset
+	}
+	if p.closed {
+		return 0, net.ErrClosed
+	}
+	if delay <= 0 && len(p.transit) == 0 {
+		p.buf = append(p.buf, b...)
+	} else {
+		// Данные не обгоняют отправленные раньше
+		at := time.Now().Add(delay)
+		if n := len(p.transit); n > 0 && at.Before(p.transit[n-1].at) {
+			at = p.transit[n-1].at
+		}
+		p.transit = append(p.transit, segment{data: append([]byte(nil), b...), at: at})
+	}
+	p.signalLocked()
+	return len(b), nil
+}
+
+// arriveLocked переносит доставленные записи в буфер и возвращает время следующей доставки
+func (p *pipe) arriveLocked(now time.Time) time.Time {
+	for len(p.transit) > 0 && !p.transit[0].at.After(now) {
+		p.buf = append(p.buf, p.transit[0].data...)
+		p.transit = p.transit[1:]
+	}
+	if len(p.transit) == 0 {
+		p.transit = nil
+		return time.Time{}
+	}
+	return p.transit[0].at
+}
+
+// read ждёт данные; deadline перечитывается после каждого пробуждения
+func (p *pipe) read(b []byte, deadline func() time.Time) (int, error) {
+	for {
+		p.mu.Lock()
+		if p.broken {
+			p.mu.Unlock()
+			return 0, ErrConnectionReset
+		}
+		next := p.arriveLocked(time.Now())
+		if len(p.buf) > 0 {
+			n := copy(b, p.buf)
+			p.buf = p.buf[n:]
+			if len(p.buf) == 0 {
+				p.buf = nil
+			}
+			p.mu.Unlock()
+			return n, nil
+		}
+		if p.closed && next.IsZero() {
+			p.mu.Unlock()
+			return 0, io.EOF
+		}
+		wake := p.wake
+		p.mu.Unlock()
+
+		// Ждём записи, доставки задержанных данных или дедлайна
+		wait := time.Duration(-1)
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		if d := deadline(); !d.IsZero() {
+			left := time.Until(d)
+			if left <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			if wait < 0 || left < wait {
+				wait = left
+			}
+		}
+		if wait < 0 {
+			<-wake
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (p *pipe) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		p.signalLocked()
+	}
+}
+
+// breakDown обрывает направление: непрочитанные данные теряются
+func (p *pipe) breakDown() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.broken = true
+	p.buf, p.transit = nil, nil
+	p.signalLocked()
+}
+
+func (p *pipe) wakeReaders() {
+	p.mu.Lock()
+	p.signalLocked()
+	p.mu.Unlock()
+}
+
+// conn — конец соединения в памяти (network.Connection)
+type conn struct {
+	network       *Network
+	in, out       *pipe
+	local, remote string
+	peer          *conn
+
+	mu           sync.Mutex
+	readDeadline time.Time
+	closeOnce    sync.Once
+	onClose      func()
+}
+
+func newConnPair(n *Network, a, b string) (*conn, *conn) {
+	ab, ba := newPipe(), newPipe()
+	local := &conn{network: n, in: ba, out: ab, local: a, remote: b}
+	remote := &conn{network: n, in: ab, out: ba, local: b, remote: a}
+	local.peer, remote.peer = remote, local
+	return local, remote
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	return c.in.read(b, c.deadline)
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	if !c.network.reachable(c.local, c.remote) || c.network.lost(func(n Conditions) float64 { return n.DropRate }) {
+		c.reset()
+		return 0, ErrConnectionReset
+	}
+	return c.out.write(b, c.network.delay())
+}
+
+// Close закрывает оба направления: собеседник дочитает отправленное и получит io.EOF
+func (c *conn) Close() error {
+	c.closeOnce.Do(func() {
+		c.in.close()
+		c.out.close()
+		c.mu.Lock()
+		onClose := c.onClose
+		c.mu.Unlock()
+		if onClose != nil {
+			onClose()
+		}
+	})
+	return nil
+}
+
+// reset обрывает соединение с обеих сторон
+func (c *conn) reset() {
+	c.in.breakDown()
+	c.out.breakDown()
+	_ = c.Close()
+	_ = c.peer.Close()
+}
+
+func (c *conn) LocalAddr() net.Addr  { return Addr(c.local) }
+func (c *conn) RemoteAddr() net.Addr { return Addr(c.remote) }
+
+// RemoteDestination возвращает адрес собеседника
+func (c *conn) RemoteDestination() string { return c.remote }
+
+func (c *conn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	c.in.wakeReaders()
+	return nil
+}
+
+// SetWriteDeadline ничего не делает: запись в память не блокируется
+func (c *conn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func (c *conn) deadline() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.readDeadline
+}
@@ -0,0 +1,212 @@
+package loopback
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+func TestLoopbackConnect(t *testing.T) {
+	ctx := context.Background()
+	n := NewNetwork()
+	alice, bob := n.NewRouter(), n.NewRouter()
+
+	bobAddr, _ := bob.GetAddress()
+	if _, err := alice.Connect(ctx, bobAddr); err == nil {
+		t.Fatal("Connected to a router that is not started")
+	}
+	_ = alice.Start(ctx)
+	_ = bob.Start(ctx)
+
+	// Адрес разбирается как настоящий I2P destination
+	if i2pkeys.I2PAddr(bobAddr).Base32() == "" {
+		t.Error("Address is not a valid destination")
+	}
+
+	out, err := alice.Connect(ctx, bobAddr)
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	in, err := bob.Accept(ctx)
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	aliceAddr, _ := alice.GetAddress()
+	if in.RemoteDestination() != aliceAddr || out.RemoteDestination() != bobAddr {
+		t.Error("Wrong remote destination")
+	}
+
+	// Запись не ждёт читателя
+	if _, err := out.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	_ = out.Close()
+	data, err := io.ReadAll(in)
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("Expected hello then EOF, got %q, %v", data, err)
+	}
+}
+
+func TestLoopbackStopAndDeadline(t *testing.T) {
+	ctx := context.Background()
+	n := NewNetwork()
+	alice, bob := n.NewRouter(), n.NewRouter()
+	_ = alice.Start(ctx)
+	_ = bob.Start(ctx)
+	bobAddr, _ := bob.GetAddress()
+
+	out, err := alice.Connect(ctx, bobAddr)
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	in, _ := bob.Accept(ctx)
+
+	_ = in.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := in.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Expected deadline error, got %v", err)
+	}
+
+	// Остановка роутера рвёт его соединения и делает адрес недоступным
+	_ = bob.Stop()
+	if _, err := out.Write([]byte("x")); err == nil {
+		t.Error("Write to stopped router succeeded")
+	}
+	if _, err := alice.Connect(ctx, bobAddr); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("Expected ErrUnreachable, got %v", err)
+	}
+	if _, err := bob.Accept(ctx); err == nil {
+		t.Error("Accept on stopped router succeeded")
+	}
+}
+
+func TestLoopbackB32(t *testing.T) {
+	ctx := context.Background()
+	n := NewNetwork()
+	alice, bob := n.NewRouter(), n.NewRouter()
+	_ = alice.Start(ctx)
+	_ = bob.Start(ctx)
+	bobAddr, _ := bob.GetAddress()
+
+	// Контакт, добавленный по b32, достижим; отвечает он с полного адреса
+	out, err := alice.Connect(ctx, strings.ToUpper(i2pkeys.I2PAddr(bobAddr).Base32()))
+	if err != nil {
+		t.Fatalf("Connect by b32 failed: %v", err)
+	}
+	if out.RemoteDestination() != bobAddr {
+		t.Errorf("Expected full destination, got %s", out.RemoteDestination())
+	}
+}
+
+func TestLoopbackLatency(t *testing.T) {
+	ctx := context.Background()
+	n := NewNetwork()
+	alice, bob := n.NewRouter(), n.NewRouter()
+	_ = alice.Start(ctx)
+	_ = bob.Start(ctx)
+	bobAddr, _ := bob.GetAddress()
+	n.SetConditions(Conditions{Latency: 30 * time.Millisecond, Jitter: 20 * time.Millisecond})
+
+	out, _ := alice.Connect(ctx, bobAddr)
+	in, _ := bob.Accept(ctx)
+
+	start := time.Now()
+	for _, s := range []string{"a", "b", "c"} {
+		_, _ = out.Write([]byte(s))
+	}
+	_ = out.Close()
+
+	// Пока данные в пути, дедлайн срабатывает
+	_ = in.SetReadDeadline(time.Now().Add(5 * time.Millisecond))
+	if _, err := in.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Expected deadline error, got %v", err)
+	}
+	_ = in.SetReadDeadline(time.Time{})
+
+	data, err := io.ReadAll(in)
+	if err != nil || string(data) != "abc" {
+		t.Fatalf("Expected abc in order, got %q, %v", data, err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Data arrived after %v, before latency", elapsed)
+	}
+}
+
+func TestLoopbackLossAndDrops(t *testing.T) {
+	ctx := context.Background()
+	n := NewNetwork()
+	alice, bob := n.NewRouter(), n.NewRouter()
+	_ = alice.Start(ctx)
+	_ = bob.Start(ctx)
+	bobAddr, _ := bob.GetAddress()
+
+	n.SetConditions(Conditions{Loss: 1})
+	if _, err := alice.Connect(ctx, bobAddr); !errors.Is(err, ErrUnreachable) {
+		t.Fatalf("Expected lost connection attempt, got %v", err)
+	}
+
+	n.SetConditions(Conditions{})
+	out, _ := alice.Connect(ctx, bobAddr)
+	in, _ := bob.Accept(ctx)
+	_, _ = out.Write([]byte("unread"))
+
+	// Обрыв теряет непрочитанное и виден обеим сторонам
+	n.SetConditions(Conditions{DropRate: 1})
+	if _, err := out.Write([]byte("x")); !errors.Is(err, ErrConnectionReset) {
+		t.Fatalf("Expected reset on write, got %v", err)
+	}
+	if _, err := in.Read(make([]byte, 16)); !errors.Is(err, ErrConnectionReset) {
+		t.Fatalf("Expected reset on read, got %v", err)
+	}
+
+	n.SetConditions(Conditions{})
+	out, _ = alice.Connect(ctx, bobAddr)
+	in, _ = bob.Accept(ctx)
+	bob.DropConnections()
+	if _, err := out.Write([]byte("x")); err == nil {
+		t.Error("Write after DropConnections succeeded")
+	}
+	if _, err := in.Read(make([]byte, 1)); !errors.Is(err, ErrConnectionReset) {
+		t.Errorf("Expected reset after DropConnections, got %v", err)
+	}
+}
+
+func TestLoopbackPartition(t *testing.T) {
+	ctx := context.Background()
+	n := NewNetwork()
+	alice, bob, carol := n.NewRouter(), n.NewRouter(), n.NewRouter()
+	for _, r := range []*Router{alice, bob, carol} {
+		_ = r.Start(ctx)
+	}
+	aliceAddr, _ := alice.GetAddress()
+	bobAddr, _ := bob.GetAddress()
+	carolAddr, _ := carol.GetAddress()
+
+	out, _ := alice.Connect(ctx, bobAddr)
+	in, _ := bob.Accept(ctx)
+
+	// Алиса отрезана; Боб и Кэрол остались в одной части
+	n.Partition([]string{aliceAddr})
+	if _, err := in.Read(make([]byte, 1)); !errors.Is(err, ErrConnectionReset) {
+		t.Fatalf("Expected reset across partition, got %v", err)
+	}
+	if _, err := out.Write([]byte("x")); err == nil {
+		t.Error("Write across partition succeeded")
+	}
+	if _, err := alice.Connect(ctx, bobAddr); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("Expected ErrUnreachable across partition, got %v", err)
+	}
+	if _, err := bob.Connect(ctx, carolAddr); err != nil {
+		t.Errorf("Connect inside partition failed: %v", err)
+	}
+
+	n.Heal()
+	if _, err := alice.Connect(ctx, bobAddr); err != nil {
+		t.Errorf("Connect after Heal failed: %v", err)
+	}
+}
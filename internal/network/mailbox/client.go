@@ -0,0 +1,165 @@
+package mailbox
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"teleghost/internal/core/identity"
+	pb "teleghost/internal/proto"
+)
+
+// maxFetchRounds — сколько пачек писем забираем за один вызов Fetch
+const maxFetchRounds = 100
+
+// Dialer открывает потоковое соединение с почтовым сервером (в I2P — router.Dial)
+type Dialer func(destination string) (net.Conn, error)
+
+// Client — клиент почтового сервера
+type Client struct {
+	dial Dialer
+	keys *identity.Keys
+}
+
+// NewClient создаёт клиента; keys — ключ владельца ящика
+func NewClient(dial Dialer, keys *identity.Keys) *Client {
+	return &Client{dial: dial, keys: keys}
+}
+
+// session — одно соединение с сервером
+type session struct {
+	conn net.Conn
+	keys *identity.Keys
+}
+
+func (c *Client) open(server string) (*session, error) {
+	if server == "" {
+		return nil, errors.New("mailbox server not set")
+	}
+	conn, err := c.dial(server)
+	if err != nil {
+		return nil, fmt.Errorf("mailbox dial failed: %w", err)
+	}
+	return &session{conn: conn, keys: c.keys}, nil
+}
+
+func (s *session) close() {
+	_ = s.conn.Close()
+}
+
+// call отправляет запрос и ждёт ответ; статус ответа превращается в ошибку
+func (s *session) call(req *pb.MailboxRequest) (*pb.MailboxResponse, error) {
+	if err := writeMessage(s.conn, req); err != nil {
+		return nil, fmt.Errorf("mailbox write failed: %w", err)
+	}
+	resp := &pb.MailboxResponse{}
+	if err := readMessage(s.conn, resp); err != nil {
+		return nil, fmt.Errorf("mailbox read failed: %w", err)
+	}
+
+	switch resp.Status {
+	case pb.MailboxStatus_MAILBOX_OK:
+		return resp, nil
+	case pb.MailboxStatus_MAILBOX_UNKNOWN:
+		return nil, ErrUnknownMailbox
+	case pb.MailboxStatus_MAILBOX_QUOTA:
+		return nil, fmt.Errorf("%w: %s", ErrQuotaExceeded, resp.Error)
+	case pb.MailboxStatus_MAILBOX_DENIED:
+		if resp.Error == ErrStampRequired.Error() {
+			return nil, ErrStampRequired
+		}
+		return nil, fmt.Errorf("%w: %s", ErrDenied, resp.Error)
+	default:
+		return nil, fmt.Errorf("mailbox error: %s", resp.Error)
+	}
+}
+
+// signedCall получает challenge и отправляет запрос, подписанный ключом владельца
+func (s *session) signedCall(req *pb.MailboxRequest) (*pb.MailboxResponse, error) {
+	resp, err := s.call(&pb.MailboxRequest{Op: pb.MailboxOp_MAILBOX_CHALLENGE})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Challenge) != ChallengeSize {
+		return nil, errors.New("mailbox returned bad challenge")
+	}
+
+	req.OwnerPubKey = s.keys.PublicKeyBase64
+	req.Signature = s.keys.SignMessage(canonicalRequest(resp.Challenge, req))
+	return s.call(req)
+}
+
+// Register заводит ящик на сервере и передаёт ему контакты, которым штамп не нужен.
+// Повторный вызов безопасен и заменяет список контактов.
+func (c *Client) Register(server string, contacts []string) (*Quota, error) {
+	s, err := c.open(server)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+
+	resp, err := s.signedCall(&pb.MailboxRequest{Op: pb.MailboxOp_MAILBOX_REGISTER, Contacts: contacts})
+	if err != nil {
+		return nil, err
+	}
+	return &Quota{
+		MaxMessages: int(resp.MaxMessages),
+		MaxBytes:    int64(resp.MaxBytes), // #nosec G115
+		TTL:         time.Duration(resp.TtlSeconds) * time.Second,
+	}, nil
+}
+
+// Deposit оставляет письмо в ящике получателя.
+// envelope — сериализованный пакет, уже зашифрованный и подписанный для получателя.
+func (c *Client) Deposit(server, recipientPubKey string, envelope []byte) error {
+	s, err := c.open(server)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	_, err = s.call(&pb.MailboxRequest{
+		Op:          pb.MailboxOp_MAILBOX_DEPOSIT,
+		OwnerPubKey: recipientPubKey,
+		Envelope:    envelope,
+	})
+	return err
+}
+
+// Fetch забирает письма из своего ящика, передаёт их handle и подтверждает получение.
+// Возвращает число принятых писем.
+func (c *Client) Fetch(server string, handle func(item *pb.MailboxItem)) (int, error) {
+	s, err := c.open(server)
+	if err != nil {
+		return 0, err
+	}
+	defer s.close()
+
+	total := 0
+	for round := 0; round < maxFetchRounds; round++ {
+		resp, err := s.signedCall(&pb.MailboxRequest{Op: pb.MailboxOp_MAILBOX_FETCH})
+		if err != nil {
+			return total, err
+		}
+		if len(resp.Items) == 0 {
+			return total, nil
+		}
+
+		ids := make([]string, 0, len(resp.Items))
+		for _, item := range resp.Items {
+			handle(item)
+			ids = append(ids, item.Id)
+		}
+		total += len(ids)
+
+		// Письмо, которое не удалось обработать, повторно уже не поможет — подтверждаем всё
+		if _, err := s.signedCall(&pb.MailboxRequest{Op: pb.MailboxOp_MAILBOX_ACK, Ids: ids}); err != nil {
+			return total, err
+		}
+		if !resp.More {
+			return total, nil
+		}
+	}
+	return total, nil
+}
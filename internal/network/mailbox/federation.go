@@ -0,0 +1,628 @@
+package mailbox
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"teleghost/internal/core/identity"
+	pb "teleghost/internal/proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// DefaultMaxHops — сколько серверов может пройти письмо, если не задано иначе
+	DefaultMaxHops = 3
+
+	// DefaultSyncInterval — как часто сервер проверяет связь с доверенными серверами
+	DefaultSyncInterval = time.Minute
+
+	// DefaultRelayQueue — писем в очереди к одному серверу
+	DefaultRelayQueue = 10000
+
+	// DefaultRelayQueueBytes — суммарный размер писем в очереди к одному серверу
+	DefaultRelayQueueBytes = 128 * 1024 * 1024
+
+	// relayBatch — писем в одном запросе RELAY
+	relayBatch = 50
+
+	// relayClockSkew — насколько письмо может быть «из будущего» из-за расхождения часов
+	relayClockSkew = 5 * time.Minute
+
+	// federationDomain отделяет подписи серверов от подписей владельцев ящиков
+	federationDomain = "TeleGhost/federation/v1"
+
+	peerHelloStage = "hello"
+	peerAuthStage  = "auth"
+)
+
+// Peer — доверенный почтовый сервер
+type Peer struct {
+	Destination string // I2P адрес сервера
+	PubKey      string // Ключ Ed25519 сервера (base64)
+}
+
+// FederationConfig — настройки обмена письмами с доверенными серверами
+type FederationConfig struct {
+	Key          ed25519.PrivateKey // ключ этого сервера
+	Peers        []Peer             // доверенные серверы
+	Dial         Dialer             // соединение с сервером (в I2P — router.Dial)
+	MaxHops      int                // сколько серверов может пройти письмо
+	SyncInterval time.Duration      // проверка связи с серверами
+	QueueLimit   int                // писем в очереди к одному серверу
+	QueueBytes   int64              // суммарный размер писем в очереди к одному серверу
+}
+
+// federation — состояние обмена письмами с доверенными серверами
+type federation struct {
+	cfg    FederationConfig
+	pubKey string
+
+	mu    sync.Mutex
+	peers map[string]*peerState // по ключу сервера
+	order []string              // порядок из конфигурации
+	seen  map[string]time.Time  // хэш письма → до какого времени помним
+	wake  chan struct{}
+}
+
+// peerState — очередь и здоровье связи с одним сервером
+type peerState struct {
+	peer      Peer
+	queue     []*pb.RelayEnvelope
+	queued    int64 // суммарный размер писем в очереди
+	lastSeen  time.Time
+	lastError string
+	sent      uint64
+	received  uint64
+	dropped   uint64
+}
+
+// EnableFederation включает обмен письмами с доверенными серверами.
+// Вызывается до Serve. Письма для ящиков, которых нет на этом сервере,
+// передаются доверенным серверам, пока не кончится лимит пересылок.
+func (s *Server) EnableFederation(cfg FederationConfig) error {
+	if len(cfg.Key) != ed25519.PrivateKeySize {
+		return errors.New("federation: server key required")
+	}
+	if cfg.Dial == nil {
+		return errors.New("federation: dialer required")
+	}
+	if cfg.MaxHops <= 0 {
+		cfg.MaxHops = DefaultMaxHops
+	}
+	if cfg.SyncInterval <= 0 {
+		cfg.SyncInterval = DefaultSyncInterval
+	}
+	if cfg.QueueLimit <= 0 {
+		cfg.QueueLimit = DefaultRelayQueue
+	}
+	if cfg.QueueBytes <= 0 {
+		cfg.QueueBytes = DefaultRelayQueueBytes
+	}
+
+	pubKey := base64.StdEncoding.EncodeToString(cfg.Key.Public().(ed25519.PublicKey))
+	f := &federation{
+		cfg:    cfg,
+		pubKey: pubKey,
+		peers:  make(map[string]*peerState),
+		seen:   make(map[string]time.Time),
+		wake:   make(chan struct{}, 1),
+	}
+	for _, p := range cfg.Peers {
+		key, err := base64.StdEncoding.DecodeString(p.PubKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("federation: bad key for peer %s", shortKey(p.Destination))
+		}
+		if p.Destination == "" {
+			return fmt.Errorf("federation: peer %s has no destination", shortKey(p.PubKey))
+		}
+		if p.PubKey == pubKey {
+			return errors.New("federation: server listed as its own peer")
+		}
+		if _, ok := f.peers[p.PubKey]; ok {
+			continue
+		}
+		f.peers[p.PubKey] = &peerState{peer: p}
+		f.order = append(f.order, p.PubKey)
+	}
+
+	s.fed = f
+	return nil
+}
+
+// ServerPubKey возвращает ключ сервера в федерации ("" — федерация выключена)
+func (s *Server) ServerPubKey() string {
+	if s.fed == nil {
+		return ""
+	}
+	return s.fed.pubKey
+}
+
+// handlePeer обслуживает запросы другого сервера: рукопожатие и передачу писем
+func (s *Server) handlePeer(ctx context.Context, st *connState, req *pb.MailboxRequest) *pb.MailboxResponse {
+	f := s.fed
+	if f == nil {
+		return failure(pb.MailboxStatus_MAILBOX_DENIED, "federation disabled")
+	}
+
+	switch req.Op {
+	case pb.MailboxOp_MAILBOX_PEER_HELLO:
+		hello := req.Hello
+		if hello == nil || len(hello.Challenge) != ChallengeSize {
+			return failure(pb.MailboxStatus_MAILBOX_ERROR, "bad hello")
+		}
+		if !f.trusted(hello.ServerPubKey) {
+			return failure(pb.MailboxStatus_MAILBOX_DENIED, "untrusted server")
+		}
+		st.challenge = make([]byte, ChallengeSize)
+		if _, err := rand.Read(st.challenge); err != nil {
+			return failure(pb.MailboxStatus_MAILBOX_ERROR, "challenge generation failed")
+		}
+		st.candidate = hello.ServerPubKey
+		st.peer = ""
+		return &pb.MailboxResponse{Hello: &pb.PeerHello{
+			ServerPubKey: f.pubKey,
+			Challenge:    st.challenge,
+			Signature:    ed25519.Sign(f.cfg.Key, canonicalHello(peerHelloStage, hello.Challenge, hello.ServerPubKey, f.pubKey)),
+		}}
+
+	case pb.MailboxOp_MAILBOX_PEER_AUTH:
+		// Как и у владельцев ящиков, challenge сгорает при любой попытке
+		challenge, candidate := st.challenge, st.candidate
+		st.challenge, st.candidate = nil, ""
+		if len(challenge) == 0 || candidate == "" || req.Hello == nil {
+			return failure(pb.MailboxStatus_MAILBOX_DENIED, "no challenge")
+		}
+		valid, err := identity.VerifySignatureBase64(candidate, canonicalHello(peerAuthStage, challenge, candidate, f.pubKey), req.Hello.Signature)
+		if err != nil || !valid {
+			return failure(pb.MailboxStatus_MAILBOX_DENIED, "bad signature")
+		}
+		st.peer = candidate
+		f.touch(candidate, s.now())
+		return &pb.MailboxResponse{}
+
+	default:
+		if st.peer == "" {
+			return failure(pb.MailboxStatus_MAILBOX_DENIED, "server not authenticated")
+		}
+		if len(req.Relays) > relayBatch {
+			return failure(pb.MailboxStatus_MAILBOX_ERROR, "too many envelopes")
+		}
+		accepted := 0
+		for _, r := range req.Relays {
+			if s.acceptRelay(ctx, st.peer, r) {
+				accepted++
+			}
+		}
+		return &pb.MailboxResponse{Accepted: uint32(accepted)} // #nosec G115
+	}
+}
+
+// acceptRelay принимает письмо от доверенного сервера: кладёт в ящик, если он здесь,
+// иначе передаёт дальше, пока не кончился лимит пересылок. false — письмо отклонено.
+func (s *Server) acceptRelay(ctx context.Context, from string, r *pb.RelayEnvelope) bool {
+	f := s.fed
+	packet, err := s.checkRelay(r)
+	if err != nil {
+		log.Printf("[GhostMail] Rejected envelope from server %s: %v", shortKey(from), err)
+		return false
+	}
+
+	now := s.now()
+	hash := envelopeHash(r.Envelope)
+	if !f.markSeen(hash, now.Add(s.cfg.TTL)) {
+		return true
+	}
+	f.count(from, func(p *peerState) { p.received++ })
+
+	resp := s.put(ctx, r.RecipientPubKey, packet, r.Envelope)
+	switch resp.Status {
+	case pb.MailboxStatus_MAILBOX_OK:
+		return true
+	case pb.MailboxStatus_MAILBOX_UNKNOWN:
+		hops := min(r.Hops, uint32(f.cfg.MaxHops)) // #nosec G115
+		if hops <= 1 || !s.cfg.Stamp.Accepts(packet, r.RecipientPubKey) {
+			return true
+		}
+		next := proto.Clone(r).(*pb.RelayEnvelope)
+		next.Hops = hops - 1
+		f.enqueue(next, from, r.OriginPubKey)
+		return true
+	case pb.MailboxStatus_MAILBOX_QUOTA, pb.MailboxStatus_MAILBOX_DENIED:
+		log.Printf("[GhostMail] Relayed envelope dropped: %s", resp.Error)
+		return true
+	default:
+		// Внутренняя ошибка: даём письму шанс прийти другим путём
+		f.forget(hash)
+		return false
+	}
+}
+
+// checkRelay проверяет письмо от другого сервера: размер, адресата, подпись отправителя,
+// возраст и подпись сервера-отправителя. Возвращает разобранный пакет.
+func (s *Server) checkRelay(r *pb.RelayEnvelope) (*pb.Packet, error) {
+	if r == nil || r.RecipientPubKey == "" || r.Hops == 0 {
+		return nil, errors.New("malformed envelope")
+	}
+	if len(r.Envelope) > s.cfg.MaxEnvelopeSize {
+		return nil, errors.New("envelope too large")
+	}
+	packet, err := checkEnvelope(r.RecipientPubKey, r.Envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	created := time.UnixMilli(r.CreatedAt)
+	if created.After(now.Add(relayClockSkew)) || now.Sub(created) > s.cfg.TTL {
+		return nil, errors.New("envelope expired")
+	}
+
+	valid, err := identity.VerifySignatureBase64(r.OriginPubKey, canonicalRelay(r), r.Signature)
+	if err != nil || !valid {
+		return nil, errors.New("bad origin signature")
+	}
+	return packet, nil
+}
+
+// relay подписывает письмо для ящика, которого нет на этом сервере, и ставит его в очереди к серверам
+func (s *Server) relay(owner string, envelope []byte) {
+	f := s.fed
+	now := s.now()
+	if !f.markSeen(envelopeHash(envelope), now.Add(s.cfg.TTL)) {
+		return
+	}
+
+	r := &pb.RelayEnvelope{
+		RecipientPubKey: owner,
+		Envelope:        envelope,
+		Hops:            uint32(f.cfg.MaxHops), // #nosec G115
+		OriginPubKey:    f.pubKey,
+		CreatedAt:       now.UnixMilli(),
+	}
+	r.Signature = ed25519.Sign(f.cfg.Key, canonicalRelay(r))
+	f.enqueue(r)
+}
+
+// federationLoop передаёт письма из очередей и периодически проверяет связь со всеми серверами
+func (s *Server) federationLoop(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.fed.cfg.SyncInterval)
+	defer ticker.Stop()
+
+	all := true
+	for {
+		s.syncPeers(ctx, all)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			all = true
+		case <-s.fed.wake:
+			all = false
+		}
+	}
+}
+
+// syncPeers связывается с серверами, у которых есть письма в очереди (all — со всеми)
+func (s *Server) syncPeers(ctx context.Context, all bool) {
+	f := s.fed
+	for _, key := range f.order {
+		if ctx.Err() != nil {
+			return
+		}
+		f.mu.Lock()
+		p := f.peers[key]
+		pending := len(p.queue) > 0
+		f.mu.Unlock()
+		if !all && !pending {
+			continue
+		}
+
+		err := s.pushToPeer(ctx, p)
+		f.mu.Lock()
+		if err != nil {
+			p.lastError = err.Error()
+		} else {
+			p.lastError = ""
+			p.lastSeen = s.now()
+		}
+		f.mu.Unlock()
+		if err != nil {
+			log.Printf("[GhostMail] Server %s unreachable: %v", shortKey(p.peer.Destination), err)
+		}
+	}
+}
+
+// pushToPeer проходит рукопожатие с сервером и передаёт ему очередь
+func (s *Server) pushToPeer(ctx context.Context, p *peerState) error {
+	f := s.fed
+	conn, err := f.cfg.Dial(p.peer.Destination)
+	if err != nil {
+		return fmt.Errorf("dial failed: %w", err)
+	}
+	sess := &session{conn: conn}
+	defer sess.close()
+
+	if err := f.handshake(sess, p.peer.PubKey); err != nil {
+		return err
+	}
+
+	for ctx.Err() == nil {
+		batch := f.nextBatch(p, s.now().Add(-s.cfg.TTL))
+		if len(batch) == 0 {
+			return nil
+		}
+		resp, err := sess.call(&pb.MailboxRequest{Op: pb.MailboxOp_MAILBOX_RELAY, Relays: batch})
+		if err != nil {
+			return err
+		}
+		f.commit(p, batch)
+		if int(resp.Accepted) < len(batch) {
+			log.Printf("[GhostMail] Server %s rejected %d envelopes", shortKey(p.peer.Destination), len(batch)-int(resp.Accepted))
+		}
+	}
+	return ctx.Err()
+}
+
+// handshake — взаимная аутентификация: проверяем подпись сервера над нашим challenge и подписываем его
+func (f *federation) handshake(s *session, peerKey string) error {
+	challenge := make([]byte, ChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return fmt.Errorf("challenge generation failed: %w", err)
+	}
+
+	resp, err := s.call(&pb.MailboxRequest{
+		Op:    pb.MailboxOp_MAILBOX_PEER_HELLO,
+		Hello: &pb.PeerHello{ServerPubKey: f.pubKey, Challenge: challenge},
+	})
+	if err != nil {
+		return err
+	}
+	hello := resp.Hello
+	if hello == nil || hello.ServerPubKey != peerKey {
+		return fmt.Errorf("%w: unexpected server key", ErrDenied)
+	}
+	if len(hello.Challenge) != ChallengeSize {
+		return errors.New("server returned bad challenge")
+	}
+	valid, err := identity.VerifySignatureBase64(peerKey, canonicalHello(peerHelloStage, challenge, f.pubKey, peerKey), hello.Signature)
+	if err != nil || !valid {
+		return fmt.Errorf("%w: bad server signature", ErrDenied)
+	}
+
+	_, err = s.call(&pb.MailboxRequest{
+		Op: pb.MailboxOp_MAILBOX_PEER_AUTH,
+		Hello: &pb.PeerHello{
+			ServerPubKey: f.pubKey,
+			Signature:    ed25519.Sign(f.cfg.Key, canonicalHello(peerAuthStage, hello.Challenge, f.pubKey, peerKey)),
+		},
+	})
+	return err
+}
+
+func (f *federation) trusted(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.peers[key]
+	return ok
+}
+
+// touch отмечает успешную аутентификацию сервера
+func (f *federation) touch(key string, now time.Time) {
+	f.count(key, func(p *peerState) { p.lastSeen = now })
+}
+
+func (f *federation) count(key string, update func(p *peerState)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if p, ok := f.peers[key]; ok {
+		update(p)
+	}
+}
+
+// markSeen запоминает хэш письма; false — письмо уже проходило через сервер
+func (f *federation) markSeen(hash string, until time.Time) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.seen[hash]; ok {
+		return false
+	}
+	f.seen[hash] = until
+	return true
+}
+
+func (f *federation) forget(hash string) {
+	f.mu.Lock()
+	delete(f.seen, hash)
+	f.mu.Unlock()
+}
+
+// enqueue ставит письмо в очереди ко всем серверам, кроме except.
+// При переполнении очереди по числу писем или по размеру выбрасываются самые старые письма.
+func (f *federation) enqueue(r *pb.RelayEnvelope, except ...string) {
+	f.mu.Lock()
+	for _, key := range f.order {
+		if slices.Contains(except, key) {
+			continue
+		}
+		p := f.peers[key]
+		p.queue = append(p.queue, r)
+		p.queued += int64(len(r.Envelope))
+		over := 0
+		for len(p.queue)-over > f.cfg.QueueLimit || p.queued > f.cfg.QueueBytes {
+			p.queued -= int64(len(p.queue[over].Envelope))
+			over++
+		}
+		if over > 0 {
+			clear(p.queue[:over])
+			p.queue = p.queue[over:]
+			p.dropped += uint64(over) // #nosec G115
+		}
+	}
+	f.mu.Unlock()
+
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// nextBatch отбрасывает просроченные письма и возвращает начало очереди (не больше одного кадра)
+func (f *federation) nextBatch(p *peerState, since time.Time) []*pb.RelayEnvelope {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fresh := p.queue[:0]
+	for _, r := range p.queue {
+		if r.CreatedAt >= since.UnixMilli() {
+			fresh = append(fresh, r)
+		} else {
+			p.queued -= int64(len(r.Envelope))
+		}
+	}
+	clear(p.queue[len(fresh):])
+	p.queue = fresh
+
+	var batch []*pb.RelayEnvelope
+	size := 0
+	for i, r := range p.queue {
+		if i == relayBatch || (i > 0 && size+len(r.Envelope) > MaxEnvelopeSize) {
+			break
+		}
+		size += len(r.Envelope)
+		batch = append(batch, r)
+	}
+	return batch
+}
+
+// commit убирает переданные письма из очереди (пока шла передача, очередь могла сдвинуться)
+func (f *federation) commit(p *peerState, batch []*pb.RelayEnvelope) {
+	sent := make(map[*pb.RelayEnvelope]bool, len(batch))
+	for _, r := range batch {
+		sent[r] = true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rest := p.queue[:0]
+	for _, r := range p.queue {
+		if !sent[r] {
+			rest = append(rest, r)
+		} else {
+			p.queued -= int64(len(r.Envelope))
+		}
+	}
+	clear(p.queue[len(rest):])
+	p.queue = rest
+	p.sent += uint64(len(batch))
+}
+
+// expire забывает хэши писем, которые уже не могут прийти повторно
+func (f *federation) expire(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for hash, until := range f.seen {
+		if now.After(until) {
+			delete(f.seen, hash)
+		}
+	}
+}
+
+// canonicalHello сериализует подписываемые поля рукопожатия серверов
+func canonicalHello(stage string, challenge []byte, initiator, responder string) []byte {
+	data := []byte(federationDomain)
+	data = appendField(data, []byte(stage))
+	data = appendField(data, challenge)
+	data = appendField(data, []byte(initiator))
+	return appendField(data, []byte(responder))
+}
+
+// canonicalRelay сериализует подписываемые поля письма (hops не подписывается: его меняет каждый узел)
+func canonicalRelay(r *pb.RelayEnvelope) []byte {
+	hash := sha256.Sum256(r.Envelope)
+	data := []byte(federationDomain)
+	data = appendField(data, []byte("relay"))
+	data = appendField(data, []byte(r.RecipientPubKey))
+	data = appendField(data, hash[:])
+	data = appendField(data, []byte(r.OriginPubKey))
+	return binary.BigEndian.AppendUint64(data, uint64(r.CreatedAt)) // #nosec G115
+}
+
+// envelopeHash — ключ дедупликации письма
+func envelopeHash(envelope []byte) string {
+	hash := sha256.Sum256(envelope)
+	return hex.EncodeToString(hash[:])
+}
+
+// LoadServerKey читает ключ сервера из файла, а если файла нет — создаёт новый
+func LoadServerKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path) // #nosec G304
+	if err == nil {
+		seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid server key in %s", path)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read server key: %w", err)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate server key: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key.Seed())+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("failed to save server key: %w", err)
+	}
+	return key, nil
+}
+
+// LoadPeers читает список доверенных серверов: по строке «I2P-адрес ключ»,
+// пустые строки и строки с # пропускаются
+func LoadPeers(path string) ([]Peer, error) {
+	file, err := os.Open(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to open peers file: %w", err)
+	}
+	defer file.Close()
+
+	var peers []Peer
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 4096), 64*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"destination pubkey\"", path, line)
+		}
+		peers = append(peers, Peer{Destination: fields[0], PubKey: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read peers file: %w", err)
+	}
+	return peers, nil
+}
+
+func shortKey(s string) string {
+	return s[:min(16, len(s))] + "..."
+}
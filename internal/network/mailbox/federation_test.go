@@ -0,0 +1,308 @@
+package mailbox
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "teleghost/internal/proto"
+)
+
+// node — почтовый сервер с ключом федерации, ещё не запущенный
+type node struct {
+	srv  *Server
+	ln   net.Listener
+	addr string
+	key  ed25519.PrivateKey
+}
+
+func newNode(t *testing.T) *node {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	ln := listen(t)
+	t.Cleanup(func() { _ = ln.Close() })
+	cfg := DefaultConfig()
+	cfg.Stamp = testStamp
+	return &node{srv: NewServer(NewMemoryStore(), cfg), ln: ln, addr: ln.Addr().String(), key: key}
+}
+
+func (n *node) pubKey() string {
+	return base64.StdEncoding.EncodeToString(n.key.Public().(ed25519.PublicKey))
+}
+
+func (n *node) peer() Peer {
+	return Peer{Destination: n.addr, PubKey: n.pubKey()}
+}
+
+// federate включает федерацию с указанными серверами и запускает сервер
+func (n *node) federate(t *testing.T, maxHops int, peers ...Peer) {
+	t.Helper()
+	err := n.srv.EnableFederation(FederationConfig{
+		Key:     n.key,
+		Peers:   peers,
+		Dial:    func(addr string) (net.Conn, error) { return net.Dial("tcp", addr) },
+		MaxHops: maxHops,
+	})
+	if err != nil {
+		t.Fatalf("EnableFederation failed: %v", err)
+	}
+	serveOn(t, n.srv, n.ln)
+}
+
+// peerStatus возвращает состояние связи n с сервером peer
+func (n *node) peerStatus(t *testing.T, peer *node) PeerStatus {
+	t.Helper()
+	status, err := n.srv.Status(t.Context())
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	for _, p := range status.Peers {
+		if p.PubKey == peer.pubKey() {
+			return p
+		}
+	}
+	t.Fatalf("Peer %s not in status", peer.addr)
+	return PeerStatus{}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFederation_RelayToHomeServer(t *testing.T) {
+	a, b := newNode(t), newNode(t)
+	a.federate(t, 0, b.peer())
+	b.federate(t, 0, a.peer())
+
+	alice, aliceKeys := newTestClient(t)
+	bob, bobKeys := newTestClient(t)
+	if _, err := alice.Register(b.addr, []string{bobKeys.PublicKeyBase64}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	// Без штампа сервер a не знает, можно ли Бобу писать Алисе, и письмо дальше не идёт
+	if err := bob.Deposit(a.addr, aliceKeys.PublicKeyBase64, letter(t, bobKeys, aliceKeys, "free ride")); !errors.Is(err, ErrUnknownMailbox) {
+		t.Fatalf("Expected ErrUnknownMailbox for unstamped relay, got %v", err)
+	}
+	if p := a.peerStatus(t, b); p.Queued != 0 {
+		t.Fatalf("Unstamped envelope queued for relay: %+v", p)
+	}
+
+	// Боб знает только сервер a: ящика Алисы там нет, письмо уходит на b
+	if err := bob.Deposit(a.addr, aliceKeys.PublicKeyBase64, stampedLetter(t, bobKeys, aliceKeys, "via federation")); err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+
+	var got []string
+	waitFor(t, "relayed envelope", func() bool {
+		got = append(got, fetchAll(t, alice, b.addr)...)
+		return len(got) > 0
+	})
+	if len(got) != 1 || got[0] != "via federation" {
+		t.Fatalf("Unexpected envelopes: %v", got)
+	}
+
+	if p := a.peerStatus(t, b); p.Sent != 1 || !p.Healthy || p.Queued != 0 {
+		t.Errorf("Unexpected status of b on a: %+v", p)
+	}
+	if p := b.peerStatus(t, a); p.Received != 1 || p.LastSeen.IsZero() {
+		t.Errorf("Unexpected status of a on b: %+v", p)
+	}
+
+	rec := httptest.NewRecorder()
+	b.srv.StatusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	var status Status
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("Decode status failed: %v", err)
+	}
+	if !status.Federation || status.Mailboxes != 1 || status.ServerPubKey != b.pubKey() || len(status.Peers) != 1 {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
+
+func TestFederation_MutualAuth(t *testing.T) {
+	a, b, c := newNode(t), newNode(t), newNode(t)
+	b.federate(t, 0, a.peer())
+
+	// c не входит в список b
+	c.federate(t, 0, b.peer())
+	waitFor(t, "failed handshake", func() bool { return c.peerStatus(t, b).LastError != "" })
+	if p := c.peerStatus(t, b); p.Healthy {
+		t.Errorf("Untrusted server reported healthy: %+v", p)
+	}
+
+	// a ждёт по адресу b сервер с другим ключом
+	a.federate(t, 0, Peer{Destination: b.addr, PubKey: c.pubKey()})
+	waitFor(t, "rejected server key", func() bool { return a.peerStatus(t, c).LastError != "" })
+
+	// Без рукопожатия письма не принимаются
+	client, aliceKeys := newTestClient(t)
+	s, err := client.open(b.addr)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer s.close()
+	relay := &pb.RelayEnvelope{RecipientPubKey: aliceKeys.PublicKeyBase64, Envelope: letter(t, aliceKeys, aliceKeys, "spoofed"), Hops: 1}
+	if _, err := s.call(&pb.MailboxRequest{Op: pb.MailboxOp_MAILBOX_RELAY, Relays: []*pb.RelayEnvelope{relay}}); !errors.Is(err, ErrDenied) {
+		t.Errorf("Expected ErrDenied for unauthenticated relay, got %v", err)
+	}
+}
+
+func TestFederation_HopLimit(t *testing.T) {
+	// a — b — c, ящик Алисы на c: письму с a нужно две пересылки
+	chain := func(hops int) (*node, *node, *node) {
+		a, b, c := newNode(t), newNode(t), newNode(t)
+		a.federate(t, hops, b.peer())
+		b.federate(t, 0, a.peer(), c.peer())
+		c.federate(t, 0, b.peer())
+		return a, b, c
+	}
+
+	for _, tc := range []struct {
+		hops      int
+		delivered bool
+	}{{1, false}, {2, true}} {
+		a, b, c := chain(tc.hops)
+		alice, aliceKeys := newTestClient(t)
+		bob, bobKeys := newTestClient(t)
+		if _, err := alice.Register(c.addr, []string{bobKeys.PublicKeyBase64}); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		if err := bob.Deposit(a.addr, aliceKeys.PublicKeyBase64, stampedLetter(t, bobKeys, aliceKeys, "far away")); err != nil {
+			t.Fatalf("Deposit failed: %v", err)
+		}
+
+		if tc.delivered {
+			var got []string
+			waitFor(t, "delivery over two hops", func() bool {
+				got = append(got, fetchAll(t, alice, c.addr)...)
+				return len(got) > 0
+			})
+			continue
+		}
+
+		// b принимает письмо синхронно: после отправки с a решение о пересылке уже принято
+		waitFor(t, "relay to b", func() bool { return a.peerStatus(t, b).Sent == 1 })
+		if p := b.peerStatus(t, c); p.Queued != 0 || p.Sent != 0 {
+			t.Errorf("Envelope forwarded past hop limit: %+v", p)
+		}
+		if got := fetchAll(t, alice, c.addr); len(got) != 0 {
+			t.Errorf("Envelope delivered past hop limit: %v", got)
+		}
+	}
+}
+
+func TestFederation_Dedup(t *testing.T) {
+	// Полный треугольник: письмо приходит на c напрямую и через b
+	a, b, c := newNode(t), newNode(t), newNode(t)
+	a.federate(t, 0, b.peer(), c.peer())
+	b.federate(t, 0, a.peer(), c.peer())
+	c.federate(t, 0, a.peer(), b.peer())
+
+	alice, aliceKeys := newTestClient(t)
+	bob, bobKeys := newTestClient(t)
+	if _, err := alice.Register(c.addr, []string{bobKeys.PublicKeyBase64}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	env := stampedLetter(t, bobKeys, aliceKeys, "once")
+	for i := 0; i < 2; i++ {
+		if err := bob.Deposit(a.addr, aliceKeys.PublicKeyBase64, env); err != nil {
+			t.Fatalf("Deposit failed: %v", err)
+		}
+	}
+
+	waitFor(t, "all relays", func() bool {
+		return a.peerStatus(t, b).Sent == 1 && a.peerStatus(t, c).Sent == 1 && b.peerStatus(t, c).Sent == 1
+	})
+	if got := fetchAll(t, alice, c.addr); len(got) != 1 || got[0] != "once" {
+		t.Fatalf("Expected single envelope, got %v", got)
+	}
+	if p := b.peerStatus(t, a); p.Queued != 0 {
+		t.Errorf("Envelope sent back to its origin: %+v", p)
+	}
+}
+
+func TestFederation_QueueBytes(t *testing.T) {
+	a, b := newNode(t), newNode(t)
+	err := a.srv.EnableFederation(FederationConfig{
+		Key:        a.key,
+		Peers:      []Peer{b.peer()},
+		Dial:       func(addr string) (net.Conn, error) { return net.Dial("tcp", addr) },
+		QueueBytes: 2500,
+	})
+	if err != nil {
+		t.Fatalf("EnableFederation failed: %v", err)
+	}
+
+	// Сервер b не отвечает: в очереди остаются самые новые письма, не больше QueueBytes
+	for i := 0; i < 5; i++ {
+		a.srv.fed.enqueue(&pb.RelayEnvelope{Envelope: make([]byte, 1000), CreatedAt: int64(i)})
+	}
+	if p := a.peerStatus(t, b); p.Queued != 2 || p.QueuedBytes != 2000 || p.Dropped != 3 {
+		t.Fatalf("Unexpected queue: %+v", p)
+	}
+
+	batch := a.srv.fed.nextBatch(a.srv.fed.peers[b.pubKey()], time.UnixMilli(0))
+	if len(batch) != 2 || batch[0].CreatedAt != 3 {
+		t.Fatalf("Unexpected batch: %v", batch)
+	}
+	a.srv.fed.commit(a.srv.fed.peers[b.pubKey()], batch)
+	if p := a.peerStatus(t, b); p.Queued != 0 || p.QueuedBytes != 0 {
+		t.Errorf("Queue not released after commit: %+v", p)
+	}
+}
+
+func TestFederation_Config(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "server_key")
+	key, err := LoadServerKey(keyPath)
+	if err != nil {
+		t.Fatalf("LoadServerKey failed: %v", err)
+	}
+	again, err := LoadServerKey(keyPath)
+	if err != nil || !key.Equal(again) {
+		t.Fatalf("Server key not persisted: %v", err)
+	}
+
+	other := newNode(t)
+	peersPath := filepath.Join(dir, "peers.txt")
+	content := "# доверенные серверы\n\n" + other.addr + " " + other.pubKey() + "\n"
+	if err := os.WriteFile(peersPath, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	peers, err := LoadPeers(peersPath)
+	if err != nil || len(peers) != 1 || peers[0] != other.peer() {
+		t.Fatalf("Unexpected peers: %v (%v)", peers, err)
+	}
+
+	dial := func(string) (net.Conn, error) { return nil, errors.New("offline") }
+	srv := NewServer(NewMemoryStore(), DefaultConfig())
+	self := Peer{Destination: "self", PubKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))}
+	if err := srv.EnableFederation(FederationConfig{Key: key, Peers: []Peer{self}, Dial: dial}); err == nil {
+		t.Error("Server accepted itself as a peer")
+	}
+	if err := srv.EnableFederation(FederationConfig{Key: key, Peers: []Peer{{Destination: "x", PubKey: "bad"}}, Dial: dial}); err == nil {
+		t.Error("Peer with malformed key accepted")
+	}
+	if err := srv.EnableFederation(FederationConfig{Key: key, Peers: peers, Dial: dial}); err != nil || srv.ServerPubKey() != self.PubKey {
+		t.Errorf("EnableFederation failed: %v", err)
+	}
+}
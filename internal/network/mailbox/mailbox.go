@@ -0,0 +1,174 @@
+// Package mailbox реализует GhostMail — почтовый сервер для доставки писем,
+// пока получатель не в сети. Сервер хранит уже зашифрованные E2EE пакеты
+// и отдаёт их только владельцу ящика, подписавшему одноразовый challenge.
+// Серверы федерации передают друг другу письма для ящиков, которых у них нет.
+package mailbox
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"teleghost/internal/network/envelope"
+	pb "teleghost/internal/proto"
+)
+
+const (
+	// ProtocolVersion версия протокола почтового сервера (поле Version пакета)
+	ProtocolVersion = 1
+
+	// MaxEnvelopeSize — предел размера одного письма, больше сервер не примет ни при каких настройках
+	MaxEnvelopeSize = 16 * 1024 * 1024
+
+	// MaxTTL — сколько письмо может пролежать в ящике; старше получатель не примет
+	MaxTTL = 30 * 24 * time.Hour
+
+	// ChallengeSize размер одноразового challenge
+	ChallengeSize = 32
+
+	// RequestTimeout таймаут одного запроса (I2P медленный)
+	RequestTimeout = 3 * time.Minute
+
+	// maxFrameSize — письмо плюс служебные поля
+	maxFrameSize = MaxEnvelopeSize + 64*1024
+
+	// MaxContacts — сколько контактов владелец может передать серверу при регистрации
+	MaxContacts = 10000
+
+	// mailboxDomain отделяет подпись запроса к ящику от других подписей того же ключа
+	mailboxDomain = "TeleGhost/mailbox/v1"
+)
+
+var (
+	// ErrUnknownMailbox — у получателя нет ящика на этом сервере
+	ErrUnknownMailbox = errors.New("mailbox not registered")
+	// ErrQuotaExceeded — сервер не принимает новые ящики или ящик переполнен
+	ErrQuotaExceeded = errors.New("mailbox quota exceeded")
+	// ErrDenied — подпись владельца не прошла проверку
+	ErrDenied = errors.New("mailbox access denied")
+	// ErrStampRequired — отправитель не в контактах владельца и не приложил штамп
+	ErrStampRequired = errors.New("mailbox requires a stamp from non-contacts")
+)
+
+// Config — квоты и срок хранения почтового сервера
+type Config struct {
+	MaxMailboxes    int                  // сколько ящиков принимает сервер
+	MaxMessages     int                  // писем в одном ящике
+	MaxBytes        int64                // суммарный размер писем в ящике
+	MaxEnvelopeSize int                  // размер одного письма
+	TTL             time.Duration        // сколько письмо ждёт владельца
+	FetchLimit      int                  // писем за один FETCH
+	MaxPerSender    int                  // писем одного отправителя в ящике
+	Stamp           envelope.StampPolicy // штамп, который платят отправители не из контактов владельца
+}
+
+// DefaultConfig возвращает конфигурацию по умолчанию
+func DefaultConfig() Config {
+	return Config{
+		MaxMailboxes:    1000,
+		MaxMessages:     500,
+		MaxBytes:        64 * 1024 * 1024,
+		MaxEnvelopeSize: 8 * 1024 * 1024,
+		TTL:             7 * 24 * time.Hour,
+		FetchLimit:      50,
+		MaxPerSender:    100,
+		Stamp:           envelope.DefaultStampPolicy(),
+	}
+}
+
+// normalize подставляет значения по умолчанию и ограничивает предельные
+func (c Config) normalize() Config {
+	def := DefaultConfig()
+	if c.MaxMailboxes <= 0 {
+		c.MaxMailboxes = def.MaxMailboxes
+	}
+	if c.MaxMessages <= 0 {
+		c.MaxMessages = def.MaxMessages
+	}
+	if c.MaxBytes <= 0 {
+		c.MaxBytes = def.MaxBytes
+	}
+	if c.MaxEnvelopeSize <= 0 || c.MaxEnvelopeSize > MaxEnvelopeSize {
+		c.MaxEnvelopeSize = min(def.MaxEnvelopeSize, MaxEnvelopeSize)
+	}
+	if c.TTL <= 0 || c.TTL > MaxTTL {
+		c.TTL = def.TTL
+	}
+	if c.FetchLimit <= 0 {
+		c.FetchLimit = def.FetchLimit
+	}
+	if c.MaxPerSender <= 0 {
+		c.MaxPerSender = def.MaxPerSender
+	}
+	c.Stamp = c.Stamp.Normalize()
+	return c
+}
+
+// Quota — квоты ящика, которые сообщил сервер при регистрации
+type Quota struct {
+	MaxMessages int
+	MaxBytes    int64
+	TTL         time.Duration
+}
+
+// Item — письмо в ящике
+type Item struct {
+	ID         string
+	Sender     string // ключ отправителя из подписанного пакета
+	Envelope   []byte
+	ReceivedAt time.Time
+}
+
+// Store — хранилище ящиков почтового сервера
+type Store interface {
+	// CreateMailbox заводит ящик; false — ящик уже был
+	CreateMailbox(ctx context.Context, ownerPubKey string, createdAt time.Time) (bool, error)
+	// HasMailbox проверяет, зарегистрирован ли ящик
+	HasMailbox(ctx context.Context, ownerPubKey string) (bool, error)
+	// CountMailboxes возвращает число ящиков
+	CountMailboxes(ctx context.Context) (int, error)
+	// SetContacts заменяет список контактов владельца
+	SetContacts(ctx context.Context, ownerPubKey string, contacts []string) error
+	// IsContact проверяет, есть ли отправитель в контактах владельца
+	IsContact(ctx context.Context, ownerPubKey, senderPubKey string) (bool, error)
+	// Usage возвращает число писем в ящике и их суммарный размер
+	Usage(ctx context.Context, ownerPubKey string) (int, int64, error)
+	// SenderUsage возвращает число писем отправителя в ящике
+	SenderUsage(ctx context.Context, ownerPubKey, senderPubKey string) (int, error)
+	// Put кладёт письмо в ящик
+	Put(ctx context.Context, ownerPubKey string, item *Item) error
+	// List возвращает не больше limit писем, полученных не раньше since, от старых к новым
+	List(ctx context.Context, ownerPubKey string, since time.Time, limit int) ([]*Item, error)
+	// Delete удаляет письма владельца по ID
+	Delete(ctx context.Context, ownerPubKey string, ids []string) error
+	// Expire удаляет письма, полученные раньше before; возвращает число удалённых
+	Expire(ctx context.Context, before time.Time) (int, error)
+}
+
+// canonicalRequest сериализует подписываемые поля запроса вместе с challenge сервера
+func canonicalRequest(challenge []byte, req *pb.MailboxRequest) []byte {
+	data := []byte(mailboxDomain)
+	data = binary.BigEndian.AppendUint32(data, uint32(req.Op)) // #nosec G115
+	data = appendField(data, challenge)
+	data = appendField(data, []byte(req.OwnerPubKey))
+	data = binary.BigEndian.AppendUint32(data, req.Limit)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(req.Ids))) // #nosec G115
+	for _, id := range req.Ids {
+		data = appendField(data, []byte(id))
+	}
+	// Контакты добавлены позже: без них подпись совпадает с прежней
+	if len(req.Contacts) > 0 {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(req.Contacts))) // #nosec G115
+		for _, c := range req.Contacts {
+			data = appendField(data, []byte(c))
+		}
+	}
+	return data
+}
+
+// appendField добавляет поле с префиксом длины
+func appendField(data, field []byte) []byte {
+	data = binary.BigEndian.AppendUint32(data, uint32(len(field))) // #nosec G115
+	return append(data, field...)
+}
@@ -0,0 +1,345 @@
+package mailbox
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"teleghost/internal/core/identity"
+	"teleghost/internal/network/envelope"
+	pb "teleghost/internal/proto"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+// startServer поднимает почтовый сервер на локальном TCP-порту
+func startServer(t *testing.T, cfg Config) (*Server, string) {
+	t.Helper()
+	return serve(t, NewServer(NewMemoryStore(), cfg))
+}
+
+func serve(t *testing.T, srv *Server) (*Server, string) {
+	t.Helper()
+	return srv, serveOn(t, srv, listen(t))
+}
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	return ln
+}
+
+func serveOn(t *testing.T, srv *Server, ln net.Listener) string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve failed: %v", err)
+		}
+	})
+	return ln.Addr().String()
+}
+
+func newTestClient(t *testing.T) (*Client, *identity.Keys) {
+	t.Helper()
+	id, err := identity.GenerateNewIdentity()
+	if err != nil {
+		t.Fatalf("GenerateNewIdentity failed: %v", err)
+	}
+	dial := func(addr string) (net.Conn, error) { return net.Dial("tcp", addr) }
+	return NewClient(dial, id.Keys), id.Keys
+}
+
+// testStamp — лёгкий штамп, чтобы тесты не тратили время на proof-of-work
+var testStamp = envelope.StampPolicy{Algorithm: pb.StampAlgorithm_STAMP_SHA256, Bits: 8}
+
+// letter — зашифрованный пакет от sender для получателя, подписанный как в мессенджере
+// (содержимое серверу безразлично)
+func letter(t *testing.T, sender, recipient *identity.Keys, content string) []byte {
+	t.Helper()
+	return mustMarshal(t, signedPacket(sender, recipient, content))
+}
+
+// stampedLetter — письмо незнакомца со штампом testStamp, привязанным к получателю
+func stampedLetter(t *testing.T, sender, recipient *identity.Keys, content string) []byte {
+	t.Helper()
+	packet := signedPacket(sender, recipient, content)
+	packet.Stamp = envelope.MintStamp(packet, recipient.PublicKeyBase64, testStamp.Algorithm, testStamp.Bits)
+	return mustMarshal(t, packet)
+}
+
+func signedPacket(sender, recipient *identity.Keys, content string) *pb.Packet {
+	packet := &pb.Packet{
+		Version:         3,
+		Type:            pb.PacketType_TEXT_MESSAGE,
+		SenderPubKey:    []byte(sender.PublicKeyBase64),
+		RecipientPubKey: []byte(recipient.PublicKeyBase64),
+		SessionId:       []byte("session"),
+		Timestamp:       time.Now().UnixMilli(),
+		Nonce:           []byte(uuid.New().String()),
+		Payload:         []byte(content),
+	}
+	packet.Signature = sender.SignMessage(envelope.SignedData(packet))
+	return packet
+}
+
+func mustMarshal(t *testing.T, packet *pb.Packet) []byte {
+	t.Helper()
+	data, err := proto.Marshal(packet)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	return data
+}
+
+func fetchAll(t *testing.T, c *Client, server string) []string {
+	t.Helper()
+	var got []string
+	if _, err := c.Fetch(server, func(item *pb.MailboxItem) {
+		packet := &pb.Packet{}
+		if err := proto.Unmarshal(item.Envelope, packet); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		got = append(got, string(packet.Payload))
+	}); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	return got
+}
+
+func TestMailbox_DepositFetchAck(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.FetchLimit = 2
+	_, addr := startServer(t, cfg)
+
+	alice, aliceKeys := newTestClient(t)
+	bob, bobKeys := newTestClient(t)
+
+	if err := bob.Deposit(addr, aliceKeys.PublicKeyBase64, letter(t, bobKeys, aliceKeys, "early")); !errors.Is(err, ErrUnknownMailbox) {
+		t.Fatalf("Expected ErrUnknownMailbox, got %v", err)
+	}
+
+	quota, err := alice.Register(addr, []string{bobKeys.PublicKeyBase64})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if quota.MaxMessages != cfg.MaxMessages || quota.TTL != cfg.TTL {
+		t.Errorf("Unexpected quota: %+v", quota)
+	}
+	if _, err := alice.Register(addr, []string{bobKeys.PublicKeyBase64}); err != nil {
+		t.Fatalf("Repeated Register failed: %v", err)
+	}
+
+	for _, text := range []string{"one", "two", "three"} {
+		if err := bob.Deposit(addr, aliceKeys.PublicKeyBase64, letter(t, bobKeys, aliceKeys, text)); err != nil {
+			t.Fatalf("Deposit failed: %v", err)
+		}
+	}
+
+	// Три письма при FetchLimit = 2 — две пачки, порядок сохраняется
+	got := fetchAll(t, alice, addr)
+	if len(got) != 3 || got[0] != "one" || got[1] != "two" || got[2] != "three" {
+		t.Fatalf("Unexpected envelopes: %v", got)
+	}
+	if got := fetchAll(t, alice, addr); len(got) != 0 {
+		t.Errorf("Acked envelopes returned again: %v", got)
+	}
+
+	// Чужой ящик забрать нельзя: у Боба ящика нет, а подпись — его
+	if _, err := bob.Fetch(addr, func(*pb.MailboxItem) {}); !errors.Is(err, ErrUnknownMailbox) {
+		t.Errorf("Expected ErrUnknownMailbox for bob, got %v", err)
+	}
+}
+
+func TestMailbox_SignedChallenge(t *testing.T) {
+	_, addr := startServer(t, DefaultConfig())
+	alice, aliceKeys := newTestClient(t)
+	bob, bobKeys := newTestClient(t)
+
+	if _, err := alice.Register(addr, []string{bobKeys.PublicKeyBase64}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	s, err := bob.open(addr)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer s.close()
+
+	// Без challenge
+	if _, err := s.call(&pb.MailboxRequest{Op: pb.MailboxOp_MAILBOX_FETCH, OwnerPubKey: aliceKeys.PublicKeyBase64}); !errors.Is(err, ErrDenied) {
+		t.Errorf("Expected ErrDenied without challenge, got %v", err)
+	}
+
+	// Боб подписывает запрос к ящику Алисы своим ключом
+	resp, err := s.call(&pb.MailboxRequest{Op: pb.MailboxOp_MAILBOX_CHALLENGE})
+	if err != nil {
+		t.Fatalf("Challenge failed: %v", err)
+	}
+	req := &pb.MailboxRequest{Op: pb.MailboxOp_MAILBOX_FETCH, OwnerPubKey: aliceKeys.PublicKeyBase64}
+	req.Signature = bobKeys.SignMessage(canonicalRequest(resp.Challenge, req))
+	if _, err := s.call(req); !errors.Is(err, ErrDenied) {
+		t.Errorf("Expected ErrDenied for foreign key, got %v", err)
+	}
+
+	// Подпись под использованным challenge повторно не принимается
+	resp, err = s.call(&pb.MailboxRequest{Op: pb.MailboxOp_MAILBOX_CHALLENGE})
+	if err != nil {
+		t.Fatalf("Challenge failed: %v", err)
+	}
+	req = &pb.MailboxRequest{Op: pb.MailboxOp_MAILBOX_REGISTER, OwnerPubKey: bobKeys.PublicKeyBase64}
+	req.Signature = bobKeys.SignMessage(canonicalRequest(resp.Challenge, req))
+	if _, err := s.call(req); err != nil {
+		t.Fatalf("Signed register failed: %v", err)
+	}
+	if _, err := s.call(req); !errors.Is(err, ErrDenied) {
+		t.Errorf("Expected ErrDenied for replayed signature, got %v", err)
+	}
+}
+
+func TestMailbox_Quotas(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxMailboxes = 1
+	cfg.MaxMessages = 2
+	cfg.MaxEnvelopeSize = 1024
+	_, addr := startServer(t, cfg)
+
+	alice, aliceKeys := newTestClient(t)
+	bob, bobKeys := newTestClient(t)
+
+	if _, err := alice.Register(addr, []string{bobKeys.PublicKeyBase64}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if _, err := bob.Register(addr, nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded for second mailbox, got %v", err)
+	}
+
+	large := letter(t, bobKeys, aliceKeys, string(make([]byte, 2048)))
+	if err := bob.Deposit(addr, aliceKeys.PublicKeyBase64, large); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded for large envelope, got %v", err)
+	}
+
+	// Письмо, адресованное другому, или открытый текст сервер не принимает
+	if err := bob.Deposit(addr, aliceKeys.PublicKeyBase64, letter(t, bobKeys, bobKeys, "misdirected")); err == nil {
+		t.Error("Envelope for another recipient accepted")
+	}
+	plain, _ := proto.Marshal(&pb.Packet{Type: pb.PacketType_TEXT_MESSAGE, RecipientPubKey: []byte(aliceKeys.PublicKeyBase64)})
+	if err := bob.Deposit(addr, aliceKeys.PublicKeyBase64, plain); err == nil {
+		t.Error("Unencrypted envelope accepted")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := bob.Deposit(addr, aliceKeys.PublicKeyBase64, letter(t, bobKeys, aliceKeys, "hi")); err != nil {
+			t.Fatalf("Deposit failed: %v", err)
+		}
+	}
+	if err := bob.Deposit(addr, aliceKeys.PublicKeyBase64, letter(t, bobKeys, aliceKeys, "overflow")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded for full mailbox, got %v", err)
+	}
+
+	// После получения место освобождается
+	if got := fetchAll(t, alice, addr); len(got) != 2 {
+		t.Fatalf("Expected 2 envelopes, got %d", len(got))
+	}
+	if err := bob.Deposit(addr, aliceKeys.PublicKeyBase64, letter(t, bobKeys, aliceKeys, "again")); err != nil {
+		t.Errorf("Deposit after fetch failed: %v", err)
+	}
+}
+
+func TestMailbox_SenderChecks(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxPerSender = 2
+	cfg.Stamp = testStamp
+	_, addr := startServer(t, cfg)
+
+	alice, aliceKeys := newTestClient(t)
+	bob, bobKeys := newTestClient(t)
+	_, carolKeys := newTestClient(t)
+	if _, err := alice.Register(addr, nil); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	owner := aliceKeys.PublicKeyBase64
+
+	// Подпись не сходится с ключом отправителя в пакете
+	forged := signedPacket(bobKeys, aliceKeys, "forged")
+	forged.SenderPubKey = []byte(carolKeys.PublicKeyBase64)
+	if err := bob.Deposit(addr, owner, mustMarshal(t, forged)); err == nil {
+		t.Error("Envelope with foreign signature accepted")
+	}
+
+	// Незнакомец платит штампом, привязанным к владельцу ящика
+	if err := bob.Deposit(addr, owner, letter(t, bobKeys, aliceKeys, "unstamped")); !errors.Is(err, ErrStampRequired) {
+		t.Errorf("Expected ErrStampRequired, got %v", err)
+	}
+	misbound := signedPacket(bobKeys, aliceKeys, "misbound")
+	misbound.Stamp = envelope.MintStamp(misbound, carolKeys.PublicKeyBase64, cfg.Stamp.Algorithm, 16)
+	if err := bob.Deposit(addr, owner, mustMarshal(t, misbound)); !errors.Is(err, ErrStampRequired) {
+		t.Errorf("Expected ErrStampRequired for stamp bound to another recipient, got %v", err)
+	}
+	if err := bob.Deposit(addr, owner, stampedLetter(t, bobKeys, aliceKeys, "stamped")); err != nil {
+		t.Fatalf("Stamped deposit failed: %v", err)
+	}
+
+	// Контакту штамп не нужен, но и он не займёт больше MaxPerSender писем
+	if _, err := alice.Register(addr, []string{bobKeys.PublicKeyBase64}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := bob.Deposit(addr, owner, letter(t, bobKeys, aliceKeys, "contact")); err != nil {
+		t.Fatalf("Deposit from contact failed: %v", err)
+	}
+	if err := bob.Deposit(addr, owner, letter(t, bobKeys, aliceKeys, "flood")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded over per-sender limit, got %v", err)
+	}
+	if err := bob.Deposit(addr, owner, letter(t, carolKeys, aliceKeys, "carol")); !errors.Is(err, ErrStampRequired) {
+		t.Errorf("Expected ErrStampRequired for carol, got %v", err)
+	}
+
+	if got := fetchAll(t, alice, addr); len(got) != 2 || got[0] != "stamped" || got[1] != "contact" {
+		t.Fatalf("Unexpected envelopes: %v", got)
+	}
+}
+
+func TestMailbox_Expiry(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TTL = time.Hour
+	var now atomic.Int64
+	now.Store(1_700_000_000_000)
+	srv := NewServer(NewMemoryStore(), cfg)
+	srv.now = func() time.Time { return time.UnixMilli(now.Load()) }
+	_, addr := serve(t, srv)
+
+	alice, aliceKeys := newTestClient(t)
+	bob, bobKeys := newTestClient(t)
+	if _, err := alice.Register(addr, []string{bobKeys.PublicKeyBase64}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := bob.Deposit(addr, aliceKeys.PublicKeyBase64, letter(t, bobKeys, aliceKeys, "old")); err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+
+	now.Add(int64(2 * time.Hour / time.Millisecond))
+	if err := bob.Deposit(addr, aliceKeys.PublicKeyBase64, letter(t, bobKeys, aliceKeys, "new")); err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+
+	// Просроченное письмо не отдаётся ещё до очистки
+	if got := fetchAll(t, alice, addr); len(got) != 1 || got[0] != "new" {
+		t.Fatalf("Unexpected envelopes: %v", got)
+	}
+
+	srv.expire(context.Background())
+	count, _, err := srv.store.Usage(context.Background(), aliceKeys.PublicKeyBase64)
+	if err != nil || count != 0 {
+		t.Errorf("Expected empty mailbox after expire, got %d (%v)", count, err)
+	}
+}
@@ -0,0 +1,426 @@
+package mailbox
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"teleghost/internal/core/identity"
+	"teleghost/internal/network/envelope"
+	"teleghost/internal/network/wire"
+	pb "teleghost/internal/proto"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+// expireInterval — как часто сервер удаляет просроченные письма
+const expireInterval = time.Hour
+
+// Server — почтовый сервер GhostMail
+type Server struct {
+	cfg   Config
+	store Store
+	mu    sync.Mutex // проверка квоты и запись письма — одна операция
+	wg    sync.WaitGroup
+	now   func() time.Time
+	fed   *federation // nil — федерация выключена
+}
+
+// connState — состояние одного соединения с клиентом
+type connState struct {
+	challenge []byte
+	candidate string // сервер, которому выдан challenge в PEER_HELLO
+	peer      string // сервер, прошедший PEER_AUTH
+}
+
+// NewServer создаёт почтовый сервер поверх хранилища
+func NewServer(store Store, cfg Config) *Server {
+	return &Server{
+		cfg:   cfg.normalize(),
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// Config возвращает действующие квоты сервера
+func (s *Server) Config() Config {
+	return s.cfg
+}
+
+// Serve принимает соединения, пока не отменён ctx. При отмене закрывает listener
+// и дожидается обработки начатых запросов.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.wg.Add(1)
+	go s.expireLoop(ctx)
+	if s.fed != nil {
+		s.wg.Add(1)
+		go s.federationLoop(ctx)
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				s.wg.Wait()
+				return nil
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			cancel()
+			s.wg.Wait()
+			return fmt.Errorf("accept failed: %w", err)
+		}
+
+		s.wg.Add(1)
+		go s.handleConn(ctx, conn)
+	}
+}
+
+// expireLoop периодически удаляет просроченные письма
+func (s *Server) expireLoop(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+
+	for {
+		s.expire(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expire удаляет письма старше TTL
+func (s *Server) expire(ctx context.Context) {
+	if s.fed != nil {
+		s.fed.expire(s.now())
+	}
+	n, err := s.store.Expire(ctx, s.now().Add(-s.cfg.TTL))
+	if err != nil {
+		log.Printf("[GhostMail] Expire failed: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("[GhostMail] Expired %d envelopes", n)
+	}
+}
+
+// handleConn обслуживает запросы одного клиента
+func (s *Server) handleConn(ctx context.Context, conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	st := &connState{}
+	for ctx.Err() == nil {
+		data, err := wire.ReadFrame(conn, maxFrameSize, RequestTimeout)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				log.Printf("[GhostMail] Read error: %v", err)
+			}
+			return
+		}
+
+		packet := &pb.Packet{}
+		req := &pb.MailboxRequest{}
+		var resp *pb.MailboxResponse
+		if err := proto.Unmarshal(data, packet); err != nil || packet.Type != pb.PacketType_MAILBOX {
+			resp = failure(pb.MailboxStatus_MAILBOX_ERROR, "not a mailbox packet")
+		} else if err := proto.Unmarshal(packet.Payload, req); err != nil {
+			resp = failure(pb.MailboxStatus_MAILBOX_ERROR, "malformed request")
+		} else {
+			resp = s.handle(ctx, st, req)
+		}
+
+		if err := writeMessage(conn, resp); err != nil {
+			log.Printf("[GhostMail] Write error: %v", err)
+			return
+		}
+	}
+}
+
+// handle выполняет один запрос
+func (s *Server) handle(ctx context.Context, st *connState, req *pb.MailboxRequest) *pb.MailboxResponse {
+	if req.Op == pb.MailboxOp_MAILBOX_CHALLENGE {
+		st.challenge = make([]byte, ChallengeSize)
+		if _, err := rand.Read(st.challenge); err != nil {
+			return failure(pb.MailboxStatus_MAILBOX_ERROR, "challenge generation failed")
+		}
+		return &pb.MailboxResponse{Challenge: st.challenge}
+	}
+
+	switch req.Op {
+	case pb.MailboxOp_MAILBOX_PEER_HELLO, pb.MailboxOp_MAILBOX_PEER_AUTH, pb.MailboxOp_MAILBOX_RELAY:
+		return s.handlePeer(ctx, st, req)
+	}
+
+	if req.OwnerPubKey == "" {
+		return failure(pb.MailboxStatus_MAILBOX_ERROR, "owner required")
+	}
+
+	switch req.Op {
+	case pb.MailboxOp_MAILBOX_DEPOSIT:
+		return s.deposit(ctx, req)
+	case pb.MailboxOp_MAILBOX_REGISTER, pb.MailboxOp_MAILBOX_FETCH, pb.MailboxOp_MAILBOX_ACK:
+	default:
+		return failure(pb.MailboxStatus_MAILBOX_ERROR, "unknown operation")
+	}
+
+	// Challenge одноразовый: сгорает при любой попытке
+	challenge := st.challenge
+	st.challenge = nil
+	if len(challenge) == 0 {
+		return failure(pb.MailboxStatus_MAILBOX_DENIED, "no challenge")
+	}
+	valid, err := identity.VerifySignatureBase64(req.OwnerPubKey, canonicalRequest(challenge, req), req.Signature)
+	if err != nil || !valid {
+		return failure(pb.MailboxStatus_MAILBOX_DENIED, "bad signature")
+	}
+
+	switch req.Op {
+	case pb.MailboxOp_MAILBOX_REGISTER:
+		return s.register(ctx, req.OwnerPubKey, req.Contacts)
+	case pb.MailboxOp_MAILBOX_FETCH:
+		return s.fetch(ctx, req.OwnerPubKey, int(req.Limit))
+	default:
+		return s.ack(ctx, req.OwnerPubKey, req.Ids)
+	}
+}
+
+// register заводит ящик владельцу и запоминает его контакты.
+// Повторная регистрация обновляет контакты и возвращает квоты.
+func (s *Server) register(ctx context.Context, owner string, contacts []string) *pb.MailboxResponse {
+	if len(contacts) > MaxContacts {
+		return failure(pb.MailboxStatus_MAILBOX_QUOTA, "too many contacts")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exists, err := s.store.HasMailbox(ctx, owner)
+	if err != nil {
+		return storeFailure(err)
+	}
+	if !exists {
+		count, err := s.store.CountMailboxes(ctx)
+		if err != nil {
+			return storeFailure(err)
+		}
+		if count >= s.cfg.MaxMailboxes {
+			return failure(pb.MailboxStatus_MAILBOX_QUOTA, "server is full")
+		}
+		if _, err := s.store.CreateMailbox(ctx, owner, s.now()); err != nil {
+			return storeFailure(err)
+		}
+		log.Printf("[GhostMail] Registered mailbox %s...", owner[:min(16, len(owner))])
+	}
+	if err := s.store.SetContacts(ctx, owner, contacts); err != nil {
+		return storeFailure(err)
+	}
+
+	return &pb.MailboxResponse{
+		MaxMessages: uint32(s.cfg.MaxMessages), // #nosec G115
+		MaxBytes:    uint64(s.cfg.MaxBytes),    // #nosec G115
+		TtlSeconds:  int64(s.cfg.TTL / time.Second),
+	}
+}
+
+// deposit кладёт письмо в ящик получателя.
+// Если ящика здесь нет, а федерация включена, письмо со штампом уходит доверенным серверам:
+// контакты владельца ящика известны только его серверу, а без штампа любой мог бы
+// бесплатно рассылать письма по всей федерации.
+func (s *Server) deposit(ctx context.Context, req *pb.MailboxRequest) *pb.MailboxResponse {
+	if len(req.Envelope) > s.cfg.MaxEnvelopeSize {
+		return failure(pb.MailboxStatus_MAILBOX_QUOTA, "envelope too large")
+	}
+	packet, err := checkEnvelope(req.OwnerPubKey, req.Envelope)
+	if err != nil {
+		return failure(pb.MailboxStatus_MAILBOX_ERROR, err.Error())
+	}
+
+	resp := s.put(ctx, req.OwnerPubKey, packet, req.Envelope)
+	if resp.Status == pb.MailboxStatus_MAILBOX_UNKNOWN && s.fed != nil && s.cfg.Stamp.Accepts(packet, req.OwnerPubKey) {
+		s.relay(req.OwnerPubKey, req.Envelope)
+		return &pb.MailboxResponse{}
+	}
+	return resp
+}
+
+// put кладёт проверенное письмо в ящик с учётом квот. Отправитель не из контактов владельца
+// платит штампом, привязанным к владельцу, и ни один отправитель не займёт весь ящик.
+func (s *Server) put(ctx context.Context, owner string, packet *pb.Packet, data []byte) *pb.MailboxResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exists, err := s.store.HasMailbox(ctx, owner)
+	if err != nil {
+		return storeFailure(err)
+	}
+	if !exists {
+		return failure(pb.MailboxStatus_MAILBOX_UNKNOWN, "mailbox not registered")
+	}
+
+	sender := string(packet.SenderPubKey)
+	contact, err := s.store.IsContact(ctx, owner, sender)
+	if err != nil {
+		return storeFailure(err)
+	}
+	if !contact && !s.cfg.Stamp.Accepts(packet, owner) {
+		return failure(pb.MailboxStatus_MAILBOX_DENIED, ErrStampRequired.Error())
+	}
+
+	count, size, err := s.store.Usage(ctx, owner)
+	if err != nil {
+		return storeFailure(err)
+	}
+	if count >= s.cfg.MaxMessages || size+int64(len(data)) > s.cfg.MaxBytes {
+		return failure(pb.MailboxStatus_MAILBOX_QUOTA, "mailbox is full")
+	}
+	fromSender, err := s.store.SenderUsage(ctx, owner, sender)
+	if err != nil {
+		return storeFailure(err)
+	}
+	if fromSender >= s.cfg.MaxPerSender {
+		return failure(pb.MailboxStatus_MAILBOX_QUOTA, "too many envelopes from sender")
+	}
+
+	item := &Item{
+		ID:         uuid.New().String(),
+		Sender:     sender,
+		Envelope:   data,
+		ReceivedAt: s.now(),
+	}
+	if err := s.store.Put(ctx, owner, item); err != nil {
+		return storeFailure(err)
+	}
+	return &pb.MailboxResponse{}
+}
+
+// fetch отдаёт владельцу самые старые письма (не больше FetchLimit и одного кадра)
+func (s *Server) fetch(ctx context.Context, owner string, limit int) *pb.MailboxResponse {
+	exists, err := s.store.HasMailbox(ctx, owner)
+	if err != nil {
+		return storeFailure(err)
+	}
+	if !exists {
+		return failure(pb.MailboxStatus_MAILBOX_UNKNOWN, "mailbox not registered")
+	}
+
+	if limit <= 0 || limit > s.cfg.FetchLimit {
+		limit = s.cfg.FetchLimit
+	}
+	items, err := s.store.List(ctx, owner, s.now().Add(-s.cfg.TTL), limit+1)
+	if err != nil {
+		return storeFailure(err)
+	}
+
+	resp := &pb.MailboxResponse{}
+	size := 0
+	for i, item := range items {
+		// Первое письмо отдаём всегда: оно не больше MaxEnvelopeSize
+		if i == limit || (i > 0 && size+len(item.Envelope) > MaxEnvelopeSize) {
+			resp.More = true
+			break
+		}
+		size += len(item.Envelope)
+		resp.Items = append(resp.Items, &pb.MailboxItem{
+			Id:         item.ID,
+			Envelope:   item.Envelope,
+			ReceivedAt: item.ReceivedAt.UnixMilli(),
+		})
+	}
+	return resp
+}
+
+// ack удаляет принятые владельцем письма
+func (s *Server) ack(ctx context.Context, owner string, ids []string) *pb.MailboxResponse {
+	if len(ids) > s.cfg.FetchLimit {
+		return failure(pb.MailboxStatus_MAILBOX_ERROR, "too many ids")
+	}
+	if err := s.store.Delete(ctx, owner, ids); err != nil {
+		return storeFailure(err)
+	}
+	return &pb.MailboxResponse{}
+}
+
+// checkEnvelope принимает только пакеты, зашифрованные E2EE, адресованные владельцу ящика
+// и подписанные ключом отправителя из пакета
+func checkEnvelope(owner string, data []byte) (*pb.Packet, error) {
+	packet := &pb.Packet{}
+	if err := proto.Unmarshal(data, packet); err != nil {
+		return nil, errors.New("envelope is not a packet")
+	}
+	if string(packet.RecipientPubKey) != owner {
+		return nil, errors.New("envelope addressed to another recipient")
+	}
+	if len(packet.SessionId) == 0 {
+		return nil, errors.New("envelope is not end-to-end encrypted")
+	}
+	if err := envelope.VerifySender(packet); err != nil {
+		return nil, fmt.Errorf("envelope rejected: %w", err)
+	}
+	return packet, nil
+}
+
+func failure(status pb.MailboxStatus, msg string) *pb.MailboxResponse {
+	return &pb.MailboxResponse{Status: status, Error: msg}
+}
+
+func storeFailure(err error) *pb.MailboxResponse {
+	log.Printf("[GhostMail] Store error: %v", err)
+	return failure(pb.MailboxStatus_MAILBOX_ERROR, "internal error")
+}
+
+// writeMessage упаковывает запрос или ответ в пакет MAILBOX и пишет кадр
+func writeMessage(conn net.Conn, msg proto.Message) error {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal failed: %w", err)
+	}
+	data, err := proto.Marshal(&pb.Packet{
+		Version: ProtocolVersion,
+		Type:    pb.PacketType_MAILBOX,
+		Payload: payload,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal failed: %w", err)
+	}
+	return wire.WriteFrame(conn, data, RequestTimeout)
+}
+
+// readMessage читает кадр и распаковывает payload пакета MAILBOX
+func readMessage(conn net.Conn, msg proto.Message) error {
+	data, err := wire.ReadFrame(conn, maxFrameSize, RequestTimeout)
+	if err != nil {
+		return err
+	}
+	packet := &pb.Packet{}
+	if err := proto.Unmarshal(data, packet); err != nil {
+		return fmt.Errorf("unmarshal failed: %w", err)
+	}
+	if packet.Type != pb.PacketType_MAILBOX {
+		return fmt.Errorf("unexpected packet type %v", packet.Type)
+	}
+	if err := proto.Unmarshal(packet.Payload, msg); err != nil {
+		return fmt.Errorf("unmarshal failed: %w", err)
+	}
+	return nil
+}
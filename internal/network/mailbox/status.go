@@ -0,0 +1,84 @@
+package mailbox
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// healthyAfter — сколько интервалов синхронизации сервер считается на связи после последнего контакта
+const healthyAfter = 3
+
+// PeerStatus — состояние связи с доверенным сервером
+type PeerStatus struct {
+	Destination string    `json:"destination"`
+	PubKey      string    `json:"pub_key"`
+	Healthy     bool      `json:"healthy"`
+	LastSeen    time.Time `json:"last_seen"` // последняя успешная аутентификация в любую сторону
+	LastError   string    `json:"last_error,omitempty"`
+	Queued      int       `json:"queued"`
+	QueuedBytes int64     `json:"queued_bytes"`
+	Sent        uint64    `json:"sent"`
+	Received    uint64    `json:"received"`
+	Dropped     uint64    `json:"dropped"` // выброшено из переполненной очереди
+}
+
+// Status — состояние почтового сервера для мониторинга
+type Status struct {
+	Mailboxes    int          `json:"mailboxes"`
+	Federation   bool         `json:"federation"`
+	ServerPubKey string       `json:"server_pub_key,omitempty"`
+	Peers        []PeerStatus `json:"peers,omitempty"`
+}
+
+// Status возвращает число ящиков и здоровье связи с доверенными серверами
+func (s *Server) Status(ctx context.Context) (*Status, error) {
+	count, err := s.store.CountMailboxes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{Mailboxes: count}
+
+	f := s.fed
+	if f == nil {
+		return status, nil
+	}
+	status.Federation = true
+	status.ServerPubKey = f.pubKey
+
+	now := s.now()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range f.order {
+		p := f.peers[key]
+		status.Peers = append(status.Peers, PeerStatus{
+			Destination: p.peer.Destination,
+			PubKey:      p.peer.PubKey,
+			Healthy:     p.lastError == "" && !p.lastSeen.IsZero() && now.Sub(p.lastSeen) < healthyAfter*f.cfg.SyncInterval,
+			LastSeen:    p.lastSeen,
+			LastError:   p.lastError,
+			Queued:      len(p.queue),
+			QueuedBytes: p.queued,
+			Sent:        p.sent,
+			Received:    p.received,
+			Dropped:     p.dropped,
+		})
+	}
+	return status, nil
+}
+
+// StatusHandler отдаёт Status в JSON (GET /status). Слушать его стоит только на localhost.
+func (s *Server) StatusHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		status, err := s.Status(r.Context())
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(status)
+	})
+	return mux
+}
@@ -0,0 +1,383 @@
+package mailbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// MemoryStore — хранилище ящиков в памяти (для тестов и временных серверов)
+type MemoryStore struct {
+	mu        sync.Mutex
+	mailboxes map[string][]*Item
+	contacts  map[string]map[string]bool
+}
+
+// NewMemoryStore создаёт пустое хранилище в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{mailboxes: make(map[string][]*Item), contacts: make(map[string]map[string]bool)}
+}
+
+func (m *MemoryStore) CreateMailbox(_ context.Context, ownerPubKey string, _ time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.mailboxes[ownerPubKey]; ok {
+		return false, nil
+	}
+	m.mailboxes[ownerPubKey] = []*Item{}
+	return true, nil
+}
+
+func (m *MemoryStore) HasMailbox(_ context.Context, ownerPubKey string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.mailboxes[ownerPubKey]
+	return ok, nil
+}
+
+func (m *MemoryStore) CountMailboxes(_ context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.mailboxes), nil
+}
+
+func (m *MemoryStore) SetContacts(_ context.Context, ownerPubKey string, contacts []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	set := make(map[string]bool, len(contacts))
+	for _, c := range contacts {
+		set[c] = true
+	}
+	m.contacts[ownerPubKey] = set
+	return nil
+}
+
+func (m *MemoryStore) IsContact(_ context.Context, ownerPubKey, senderPubKey string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.contacts[ownerPubKey][senderPubKey], nil
+}
+
+func (m *MemoryStore) Usage(_ context.Context, ownerPubKey string) (int, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var size int64
+	for _, item := range m.mailboxes[ownerPubKey] {
+		size += int64(len(item.Envelope))
+	}
+	return len(m.mailboxes[ownerPubKey]), size, nil
+}
+
+func (m *MemoryStore) SenderUsage(_ context.Context, ownerPubKey, senderPubKey string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for _, item := range m.mailboxes[ownerPubKey] {
+		if item.Sender == senderPubKey {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MemoryStore) Put(_ context.Context, ownerPubKey string, item *Item) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	items, ok := m.mailboxes[ownerPubKey]
+	if !ok {
+		return ErrUnknownMailbox
+	}
+	m.mailboxes[ownerPubKey] = append(items, item)
+	return nil
+}
+
+func (m *MemoryStore) List(_ context.Context, ownerPubKey string, since time.Time, limit int) ([]*Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []*Item
+	for _, item := range m.mailboxes[ownerPubKey] {
+		if item.ReceivedAt.Before(since) {
+			continue
+		}
+		result = append(result, item)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].ReceivedAt.Before(result[j].ReceivedAt) })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, ownerPubKey string, ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	remove := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+	items, ok := m.mailboxes[ownerPubKey]
+	if !ok {
+		return nil
+	}
+	kept := items[:0]
+	for _, item := range items {
+		if !remove[item.ID] {
+			kept = append(kept, item)
+		}
+	}
+	m.mailboxes[ownerPubKey] = kept
+	return nil
+}
+
+func (m *MemoryStore) Expire(_ context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	removed := 0
+	for owner, items := range m.mailboxes {
+		kept := items[:0]
+		for _, item := range items {
+			if item.ReceivedAt.Before(before) {
+				removed++
+				continue
+			}
+			kept = append(kept, item)
+		}
+		m.mailboxes[owner] = kept
+	}
+	return removed, nil
+}
+
+// SQLiteStore — хранилище ящиков в SQLite для постоянно работающего сервера
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore открывает (и при необходимости создаёт) базу почтового сервера
+func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	schema := `
+	CREATE TABLE IF NOT EXISTS mailboxes (
+		owner_pub_key TEXT PRIMARY KEY,
+		created_at INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS mailbox_items (
+		id TEXT PRIMARY KEY,
+		owner_pub_key TEXT NOT NULL,
+		sender_pub_key TEXT NOT NULL DEFAULT '',
+		envelope BLOB NOT NULL,
+		received_at INTEGER NOT NULL,
+		FOREIGN KEY (owner_pub_key) REFERENCES mailboxes(owner_pub_key) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_mailbox_items_owner ON mailbox_items(owner_pub_key, received_at);
+	CREATE INDEX IF NOT EXISTS idx_mailbox_items_received ON mailbox_items(received_at);
+
+	CREATE TABLE IF NOT EXISTS mailbox_contacts (
+		owner_pub_key TEXT NOT NULL,
+		contact_pub_key TEXT NOT NULL,
+		PRIMARY KEY (owner_pub_key, contact_pub_key),
+		FOREIGN KEY (owner_pub_key) REFERENCES mailboxes(owner_pub_key) ON DELETE CASCADE
+	);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	// Базы прежних версий: письма без отправителя
+	if err := addSenderColumn(db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_mailbox_items_sender ON mailbox_items(owner_pub_key, sender_pub_key)`); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+// addSenderColumn добавляет колонку отправителя в таблицу писем, если её ещё нет
+func addSenderColumn(db *sql.DB) error {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('mailbox_items') WHERE name = 'sender_pub_key'`).Scan(&n)
+	if err != nil {
+		return fmt.Errorf("failed to check schema: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+	if _, err := db.Exec(`ALTER TABLE mailbox_items ADD COLUMN sender_pub_key TEXT NOT NULL DEFAULT ''`); err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	return nil
+}
+
+// Close закрывает базу
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) CreateMailbox(ctx context.Context, ownerPubKey string, createdAt time.Time) (bool, error) {
+	res, err := s.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO mailboxes (owner_pub_key, created_at) VALUES (?, ?)`,
+		ownerPubKey, createdAt.UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("failed to create mailbox: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (s *SQLiteStore) HasMailbox(ctx context.Context, ownerPubKey string) (bool, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM mailboxes WHERE owner_pub_key = ?`, ownerPubKey).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("failed to check mailbox: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (s *SQLiteStore) CountMailboxes(ctx context.Context) (int, error) {
+	var n int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM mailboxes`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count mailboxes: %w", err)
+	}
+	return n, nil
+}
+
+func (s *SQLiteStore) SetContacts(ctx context.Context, ownerPubKey string, contacts []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mailbox_contacts WHERE owner_pub_key = ?`, ownerPubKey); err != nil {
+		return fmt.Errorf("failed to clear contacts: %w", err)
+	}
+	stmt, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO mailbox_contacts (owner_pub_key, contact_pub_key) VALUES (?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare contacts: %w", err)
+	}
+	defer stmt.Close()
+	for _, c := range contacts {
+		if _, err := stmt.ExecContext(ctx, ownerPubKey, c); err != nil {
+			return fmt.Errorf("failed to save contact: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) IsContact(ctx context.Context, ownerPubKey, senderPubKey string) (bool, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM mailbox_contacts WHERE owner_pub_key = ? AND contact_pub_key = ?`,
+		ownerPubKey, senderPubKey).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("failed to check contact: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (s *SQLiteStore) Usage(ctx context.Context, ownerPubKey string) (int, int64, error) {
+	var count int
+	var size int64
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*), COALESCE(SUM(LENGTH(envelope)), 0) FROM mailbox_items WHERE owner_pub_key = ?`,
+		ownerPubKey).Scan(&count, &size)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get mailbox usage: %w", err)
+	}
+	return count, size, nil
+}
+
+func (s *SQLiteStore) SenderUsage(ctx context.Context, ownerPubKey, senderPubKey string) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM mailbox_items WHERE owner_pub_key = ? AND sender_pub_key = ?`,
+		ownerPubKey, senderPubKey).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get sender usage: %w", err)
+	}
+	return n, nil
+}
+
+func (s *SQLiteStore) Put(ctx context.Context, ownerPubKey string, item *Item) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO mailbox_items (id, owner_pub_key, sender_pub_key, envelope, received_at) VALUES (?, ?, ?, ?, ?)`,
+		item.ID, ownerPubKey, item.Sender, item.Envelope, item.ReceivedAt.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to store envelope: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) List(ctx context.Context, ownerPubKey string, since time.Time, limit int) ([]*Item, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, sender_pub_key, envelope, received_at FROM mailbox_items
+		WHERE owner_pub_key = ? AND received_at >= ?
+		ORDER BY received_at ASC, rowid ASC
+		LIMIT ?`, ownerPubKey, since.UnixMilli(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list envelopes: %w", err)
+	}
+	defer rows.Close()
+
+	var items []*Item
+	for rows.Next() {
+		item := &Item{}
+		var receivedAt int64
+		if err := rows.Scan(&item.ID, &item.Sender, &item.Envelope, &receivedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan envelope: %w", err)
+		}
+		item.ReceivedAt = time.UnixMilli(receivedAt)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *SQLiteStore) Delete(ctx context.Context, ownerPubKey string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, ownerPubKey)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	query := `DELETE FROM mailbox_items WHERE owner_pub_key = ? AND id IN (?` + strings.Repeat(", ?", len(ids)-1) + `)`
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete envelopes: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Expire(ctx context.Context, before time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM mailbox_items WHERE received_at < ?`, before.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire envelopes: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
@@ -51,6 +51,27 @@ func (m *MediaCrypt) SaveEncrypted(filename string, data []byte) error {
 	return os.WriteFile(filename, ciphertext, 0600)
 }
 
+// LoadEncrypted читает и расшифровывает файл, сохранённый через SaveEncrypted
+func (m *MediaCrypt) LoadEncrypted(filename string) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(m.key)
+	if err != nil {
+		return nil, err
+	}
+
+	// #nosec G304
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted file too short")
+	}
+
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
 // NewMediaHandler создает обработчик для AssetsHandler в Wails
 func (m *MediaCrypt) NewMediaHandler(storageDir string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -44,6 +44,14 @@ func TestMediaCrypt(t *testing.T) {
 		t.Error("Encrypted data is same as original")
 	}
 
+	loaded, err := mc.LoadEncrypted(testFile)
+	if err != nil {
+		t.Fatalf("Failed to load encrypted: %v", err)
+	}
+	if !bytes.Equal(originalData, loaded) {
+		t.Error("Loaded data does not match original")
+	}
+
 	// 2. Test Migration
 	plainFile := filepath.Join(tempDir, "plain.txt")
 	plainData := []byte("this is plain text")
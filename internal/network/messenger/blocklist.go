@@ -0,0 +1,82 @@
+package messenger
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+// blockList — заблокированные собеседники по ключу и по I2P адресу (полному или b32)
+type blockList struct {
+	mu    sync.RWMutex
+	keys  map[string]bool
+	dests map[string]bool
+}
+
+func newBlockList() *blockList {
+	return &blockList{
+		keys:  make(map[string]bool),
+		dests: make(map[string]bool),
+	}
+}
+
+// SetBlockList заменяет список заблокированных. Их пакеты отбрасываются до любого обработчика,
+// входящие соединения с их адресов сразу закрываются.
+func (s *Service) SetBlockList(pubKeys, destinations []string) {
+	keys := make(map[string]bool, len(pubKeys))
+	for _, k := range pubKeys {
+		if k != "" {
+			keys[k] = true
+		}
+	}
+	dests := make(map[string]bool, len(destinations))
+	for _, d := range destinations {
+		if d != "" {
+			dests[normalizeDest(d)] = true
+		}
+	}
+
+	b := s.blocked
+	b.mu.Lock()
+	b.keys, b.dests = keys, dests
+	b.mu.Unlock()
+
+	// Уже открытые соединения с заблокированными закрываем
+	for _, d := range destinations {
+		if d != "" {
+			s.removeConnection(d)
+		}
+	}
+}
+
+// IsBlocked проверяет, заблокирован ли собеседник по ключу или по адресу
+func (s *Service) IsBlocked(pubKey, destination string) bool {
+	b := s.blocked
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if pubKey != "" && b.keys[pubKey] {
+		return true
+	}
+	if destination == "" || len(b.dests) == 0 {
+		return false
+	}
+	if b.dests[normalizeDest(destination)] {
+		return true
+	}
+	// Контакт мог быть добавлен по b32, а соединение приходит с полного адреса
+	return isFullDest(destination) && b.dests[normalizeDest(i2pkeys.I2PAddr(destination).Base32())]
+}
+
+// normalizeDest приводит b32 адрес к нижнему регистру; полный адрес чувствителен к регистру
+func normalizeDest(d string) string {
+	if strings.HasSuffix(strings.ToLower(d), ".b32.i2p") {
+		return strings.ToLower(d)
+	}
+	return d
+}
+
+// isFullDest — полный base64 I2P destination, а не b32 адрес
+func isFullDest(d string) bool {
+	return len(d) > 64 && !strings.HasSuffix(strings.ToLower(d), ".i2p")
+}
@@ -0,0 +1,70 @@
+package messenger
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"teleghost/internal/core"
+	pb "teleghost/internal/proto"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+func TestBlockedPeerDropped(t *testing.T) {
+	alice := newTestService(t)
+	bob := newTestService(t)
+	bob.ctx = context.Background()
+	pairSessions(t, alice, bob, "alice-dest", "bob-dest")
+
+	var received []*core.Message
+	bob.handler = func(msg *core.Message, _, _ string) { received = append(received, msg) }
+
+	send := func(id string) {
+		t.Helper()
+		sealed, err := alice.sealPacket("bob-dest", &pb.Packet{Type: pb.PacketType_TEXT_MESSAGE, Payload: mustMarshal(t, &pb.TextMessage{
+			ChatId: "chat", MessageId: id, Content: "hi", Timestamp: time.Now().UnixMilli(),
+		})})
+		if err != nil {
+			t.Fatalf("sealPacket failed: %v", err)
+		}
+		bob.handlePacket(sealed, "alice-dest", false)
+	}
+
+	// Блокировка по ключу действует и на письма из почтового ящика (без адреса)
+	bob.SetBlockList([]string{alice.identity.PublicKeyBase64}, nil)
+	send("m1")
+	if len(received) != 0 {
+		t.Fatal("Message from blocked peer dispatched")
+	}
+	if got := bob.stats.rejectedBlocked.Load(); got != 1 {
+		t.Errorf("Expected 1 blocked packet, got %d", got)
+	}
+
+	bob.SetBlockList(nil, nil)
+	send("m2")
+	if len(received) != 1 {
+		t.Errorf("Message after unblock dropped")
+	}
+}
+
+func TestBlockListByDestination(t *testing.T) {
+	s := newTestService(t)
+	full := strings.Repeat("A", 516)
+	b32 := i2pkeys.I2PAddr(full).Base32()
+
+	// Контакт добавлен по b32, соединение приходит с полного адреса
+	s.SetBlockList(nil, []string{strings.ToUpper(b32)})
+	if !s.IsBlocked("", full) || !s.IsBlocked("", b32) {
+		t.Error("Destination not blocked by its b32 address")
+	}
+	if s.IsBlocked("", "other-dest") || s.IsBlocked("other-key", "") {
+		t.Error("Unrelated peer blocked")
+	}
+
+	s.SetBlockList(nil, []string{full})
+	if !s.IsBlocked("", full) {
+		t.Error("Full destination not blocked")
+	}
+}
@@ -0,0 +1,209 @@
+package messenger
+
+import (
+	"log"
+	"sync"
+
+	pb "teleghost/internal/proto"
+)
+
+// MinProtocolVersion — самая старая версия протокола, с которой умеем общаться
+const MinProtocolVersion = 1
+
+// Capability — возможность клиента, о которой сообщается в handshake
+type Capability uint64
+
+const (
+	// CapReceipts — отчёты о доставке и прочтении
+	CapReceipts Capability = 1 << iota
+	// CapE2EE — сессии Double Ratchet
+	CapE2EE
+	// CapMessageEdits — редактирование и удаление сообщений у собеседника
+	CapMessageEdits
+	// CapChunkedFiles — передача файлов частями (FILE_CHUNK)
+	CapChunkedFiles
+	// CapReactions — реакции на сообщения (зарезервировано)
+	CapReactions
+	// CapPresence — индикатор набора текста и статус в сети
+	CapPresence
+	// CapGroups — групповые чаты
+	CapGroups
+	// CapChannels — каналы (CHANNEL_POST, CHANNEL_CONTROL)
+	CapChannels
+	// CapStreamedFiles — поток частей файла в отдельном соединении (FILE_STREAM)
+	CapStreamedFiles
+	// CapTaggedFrames — тип пакета в заголовке кадра (wire.WriteTaggedFrame)
+	CapTaggedFrames
+)
+
+// LocalCapabilities — возможности этой сборки
+const LocalCapabilities = CapReceipts | CapE2EE | CapMessageEdits | CapChunkedFiles | CapPresence | CapGroups | CapChannels | CapStreamedFiles | CapTaggedFrames
+
+// knownCapabilities — все биты, которые понимает эта сборка
+const knownCapabilities = LocalCapabilities | CapReactions
+
+// PeerInfo — согласованные с собеседником версия протокола и возможности
+type PeerInfo struct {
+	Version      uint32
+	Capabilities Capability
+
+	// Negotiated — данные получены из handshake (иначе версия угадана по входящим пакетам)
+	Negotiated bool
+}
+
+// PeerCapabilitiesHandler вызывается после согласования версии с собеседником
+type PeerCapabilitiesHandler func(senderPubKey string, version uint32, capabilities Capability)
+
+// peerRegistry — версии и возможности собеседников
+type peerRegistry struct {
+	mu    sync.RWMutex
+	peers map[string]PeerInfo
+}
+
+func newPeerRegistry() *peerRegistry {
+	return &peerRegistry{peers: make(map[string]PeerInfo)}
+}
+
+// negotiateVersion выбирает наибольшую общую версию; 0 — диапазоны не пересекаются
+func negotiateVersion(peerMin, peerMax uint32) uint32 {
+	// Старые сборки не присылают диапазон — они знают только версию 1
+	if peerMax == 0 {
+		peerMin, peerMax = 1, 1
+	}
+	if peerMin == 0 {
+		peerMin = 1
+	}
+
+	version := peerMax
+	if version > ProtocolVersion {
+		version = ProtocolVersion
+	}
+	if version < peerMin || version < MinProtocolVersion {
+		return 0
+	}
+	return version
+}
+
+// fillHandshakeVersion добавляет в handshake наш диапазон версий и возможности
+func fillHandshakeVersion(hs *pb.Handshake) {
+	hs.MinVersion = MinProtocolVersion
+	hs.MaxVersion = ProtocolVersion
+	hs.Capabilities = uint64(LocalCapabilities)
+}
+
+// negotiatePeer запоминает результат handshake; false — версии несовместимы
+func (s *Service) negotiatePeer(hs *pb.Handshake, senderPubKey string) bool {
+	version := negotiateVersion(hs.MinVersion, hs.MaxVersion)
+	if version == 0 {
+		log.Printf("[Messenger] Incompatible protocol versions %d-%d from %s", hs.MinVersion, hs.MaxVersion, senderPubKey[:min(16, len(senderPubKey))])
+		return false
+	}
+
+	info := PeerInfo{
+		Version:      version,
+		Capabilities: Capability(hs.Capabilities) & knownCapabilities,
+		Negotiated:   true,
+	}
+
+	s.peerInfo.mu.Lock()
+	prev := s.peerInfo.peers[senderPubKey]
+	s.peerInfo.peers[senderPubKey] = info
+	s.peerInfo.mu.Unlock()
+
+	if prev != info {
+		log.Printf("[Messenger] Negotiated protocol v%d (caps %#x) with %s", info.Version, uint64(info.Capabilities), senderPubKey[:min(16, len(senderPubKey))])
+		if s.capabilitiesHandler != nil {
+			s.capabilitiesHandler(senderPubKey, info.Version, info.Capabilities)
+		}
+	}
+	return true
+}
+
+// observePeerVersion угадывает версию собеседника по входящему пакету, пока не было handshake
+func (s *Service) observePeerVersion(senderPubKey string, version uint32) {
+	if senderPubKey == "" || version == 0 {
+		return
+	}
+
+	s.peerInfo.mu.Lock()
+	defer s.peerInfo.mu.Unlock()
+
+	if info, ok := s.peerInfo.peers[senderPubKey]; ok && info.Negotiated {
+		return
+	}
+	if version > ProtocolVersion {
+		version = ProtocolVersion
+	}
+	s.peerInfo.peers[senderPubKey] = PeerInfo{Version: version}
+}
+
+// sendVersion — версия, в которой отправляем пакеты собеседнику
+func (s *Service) sendVersion(peerPubKey string) uint32 {
+	if peerPubKey == "" {
+		return ProtocolVersion
+	}
+
+	s.peerInfo.mu.RLock()
+	info, ok := s.peerInfo.peers[peerPubKey]
+	s.peerInfo.mu.RUnlock()
+
+	if !ok || info.Version == 0 {
+		return ProtocolVersion
+	}
+	return info.Version
+}
+
+// SetPeerInfo восстанавливает сохранённые версию и возможности собеседника
+func (s *Service) SetPeerInfo(peerPubKey string, version uint32, capabilities Capability) {
+	if peerPubKey == "" || version == 0 {
+		return
+	}
+	s.peerInfo.mu.Lock()
+	s.peerInfo.peers[peerPubKey] = PeerInfo{Version: version, Capabilities: capabilities, Negotiated: true}
+	s.peerInfo.mu.Unlock()
+}
+
+// GetPeerInfo возвращает известные версию и возможности собеседника
+func (s *Service) GetPeerInfo(peerPubKey string) (PeerInfo, bool) {
+	s.peerInfo.mu.RLock()
+	defer s.peerInfo.mu.RUnlock()
+	info, ok := s.peerInfo.peers[peerPubKey]
+	return info, ok
+}
+
+// PeerSupports сообщает, поддерживает ли собеседник возможность.
+// Пока handshake не было, считаем, что поддерживает (собеседник — актуальная сборка).
+func (s *Service) PeerSupports(peerPubKey string, c Capability) bool {
+	info, ok := s.GetPeerInfo(peerPubKey)
+	if !ok || !info.Negotiated {
+		return true
+	}
+	return info.Capabilities&c == c
+}
+
+// SetPeerCapabilitiesHandler устанавливает обработчик согласования версии
+func (s *Service) SetPeerCapabilitiesHandler(h PeerCapabilitiesHandler) {
+	s.capabilitiesHandler = h
+}
+
+// tagsFrames — получатель понимает тип в заголовке кадра: договорились в handshake или он
+// опубликовал статический ключ для первых сообщений (такие сборки метки понимают).
+// Старая сборка кадр с меткой отвергнет, поэтому без уверенности пишем кадр без неё.
+func (s *Service) tagsFrames(packet *pb.Packet) bool {
+	if len(packet.SealedEphemeral) > 0 {
+		return true
+	}
+	info, ok := s.GetPeerInfo(string(packet.RecipientPubKey))
+	return ok && info.Negotiated && info.Capabilities&CapTaggedFrames != 0
+}
+
+// isLegacyFramer — адрес принадлежит известному собеседнику на сборке без меток кадров:
+// от него принимаем большие кадры без типа в заголовке
+func (s *Service) isLegacyFramer(remoteAddr string) bool {
+	peer := s.resolvePeer(remoteAddr)
+	if peer == "" {
+		return false
+	}
+	info, ok := s.GetPeerInfo(peer)
+	return ok && (!info.Negotiated || info.Capabilities&CapTaggedFrames == 0)
+}
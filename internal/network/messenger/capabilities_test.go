@@ -0,0 +1,62 @@
+package messenger
+
+import (
+	"testing"
+
+	pb "teleghost/internal/proto"
+)
+
+func TestNegotiateVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max uint32
+		want     uint32
+	}{
+		{"legacy build without range", 0, 0, 1},
+		{"same build", MinProtocolVersion, ProtocolVersion, ProtocolVersion},
+		{"newer build", 2, ProtocolVersion + 5, ProtocolVersion},
+		{"older build", 1, 2, 2},
+		{"too new", ProtocolVersion + 1, ProtocolVersion + 2, 0},
+	}
+
+	for _, tt := range tests {
+		if got := negotiateVersion(tt.min, tt.max); got != tt.want {
+			t.Errorf("%s: negotiateVersion(%d, %d) = %d, want %d", tt.name, tt.min, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestPeerFallback(t *testing.T) {
+	s := newTestService(t)
+
+	// Неизвестный собеседник — актуальная версия и все возможности
+	if s.sendVersion("peer") != ProtocolVersion || !s.PeerSupports("peer", CapChunkedFiles) {
+		t.Error("Unknown peer should get current protocol")
+	}
+
+	// Старая сборка без диапазона версий
+	if !s.negotiatePeer(&pb.Handshake{}, "old") {
+		t.Fatal("Legacy handshake rejected")
+	}
+	if s.sendVersion("old") != 1 {
+		t.Errorf("Expected version 1 for legacy peer, got %d", s.sendVersion("old"))
+	}
+	if s.PeerSupports("old", CapReceipts) {
+		t.Error("Legacy peer should not support receipts")
+	}
+
+	hs := &pb.Handshake{}
+	fillHandshakeVersion(hs)
+	hs.Capabilities |= 1 << 62 // неизвестный бит отбрасывается
+	s.negotiatePeer(hs, "new")
+	info, _ := s.GetPeerInfo("new")
+	if info.Version != ProtocolVersion || info.Capabilities != LocalCapabilities {
+		t.Errorf("Unexpected peer info: %+v", info)
+	}
+
+	// Версия, угаданная по пакетам, не перекрывает handshake
+	s.observePeerVersion("new", 1)
+	if s.sendVersion("new") != ProtocolVersion {
+		t.Error("Observed version overrode negotiated one")
+	}
+}
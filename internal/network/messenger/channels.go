@@ -0,0 +1,149 @@
+package messenger
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+
+	"teleghost/internal/core/identity"
+	pb "teleghost/internal/proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// channelDomain отделяет подпись поста канала от других подписей того же ключа
+const channelDomain = "TeleGhost/channel/v1"
+
+var (
+	errChannelPostUnsigned     = errors.New("channel post is not signed")
+	errChannelPostBadSignature = errors.New("invalid channel post signature")
+)
+
+// ChannelPostHandler обработчик поста канала (подпись владельца уже проверена)
+type ChannelPostHandler func(senderPubKey string, post *pb.ChannelPost)
+
+// ChannelControlHandler обработчик управляющих пакетов канала
+type ChannelControlHandler func(senderPubKey, senderAddr string, ctl *pb.ChannelControl)
+
+// canonicalChannelPost сериализует подписываемые поля поста
+func canonicalChannelPost(post *pb.ChannelPost) []byte {
+	data := []byte(channelDomain)
+	data = appendField(data, []byte(post.ChannelId))
+	data = binary.BigEndian.AppendUint64(data, post.Seq)
+	data = appendField(data, []byte(post.PostId))
+	data = appendField(data, []byte(post.Content))
+	data = binary.BigEndian.AppendUint64(data, uint64(post.Timestamp)) // #nosec G115
+	return appendField(data, []byte(post.OwnerPubKey))
+}
+
+// SignChannelPost подписывает пост нашим ключом
+func (s *Service) SignChannelPost(post *pb.ChannelPost) {
+	post.OwnerPubKey = s.identity.PublicKeyBase64
+	post.Signature = s.identity.SignMessage(canonicalChannelPost(post))
+}
+
+// VerifyChannelPost проверяет подпись поста ключом OwnerPubKey.
+// Тот ли это владелец канала, решает вызывающий.
+func VerifyChannelPost(post *pb.ChannelPost) error {
+	if post == nil || len(post.Signature) == 0 || post.OwnerPubKey == "" {
+		return errChannelPostUnsigned
+	}
+	valid, err := identity.VerifySignatureBase64(post.OwnerPubKey, canonicalChannelPost(post), post.Signature)
+	if err != nil || !valid {
+		return errChannelPostBadSignature
+	}
+	return nil
+}
+
+// channelPostPacket упаковывает пост в пакет
+func channelPostPacket(post *pb.ChannelPost) (*pb.Packet, error) {
+	payload, err := proto.Marshal(post)
+	if err != nil {
+		return nil, fmt.Errorf("marshal channel post failed: %w", err)
+	}
+	return &pb.Packet{
+		Type:    pb.PacketType_CHANNEL_POST,
+		Payload: payload,
+	}, nil
+}
+
+// SendChannelPost отправляет пост одному подписчику
+func (s *Service) SendChannelPost(destination string, post *pb.ChannelPost) error {
+	packet, err := channelPostPacket(post)
+	if err != nil {
+		return err
+	}
+	return s.SendMessage(destination, packet)
+}
+
+// PublishChannelPost рассылает пост подписчикам.
+// Возвращает адреса, на которые отправить не удалось: они досинхронизируются сами.
+func (s *Service) PublishChannelPost(destinations []string, post *pb.ChannelPost) ([]string, error) {
+	packet, err := channelPostPacket(post)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[Messenger] Publishing post #%d of channel %s to %d subscribers", post.Seq, post.ChannelId[:min(8, len(post.ChannelId))], len(destinations))
+	return s.BroadcastTo(destinations, packet), nil
+}
+
+// SendChannelControl отправляет управляющий пакет канала
+func (s *Service) SendChannelControl(destination string, ctl *pb.ChannelControl) error {
+	payload, err := proto.Marshal(ctl)
+	if err != nil {
+		return fmt.Errorf("marshal channel control failed: %w", err)
+	}
+
+	log.Printf("[Messenger] Sending channel %v (channel=%s) to %s...", ctl.Action, ctl.ChannelId[:min(8, len(ctl.ChannelId))], destination[:min(32, len(destination))])
+	return s.SendMessage(destination, &pb.Packet{
+		Type:    pb.PacketType_CHANNEL_CONTROL,
+		Payload: payload,
+	})
+}
+
+// handleChannelPost обрабатывает пост канала
+func (s *Service) handleChannelPost(packet *pb.Packet, senderPubKey string) {
+	post := &pb.ChannelPost{}
+	if err := proto.Unmarshal(packet.Payload, post); err != nil {
+		log.Printf("[Messenger] Failed to unmarshal ChannelPost: %v", err)
+		return
+	}
+	if post.ChannelId == "" || post.PostId == "" || post.Seq == 0 {
+		return
+	}
+	if err := VerifyChannelPost(post); err != nil {
+		log.Printf("[Messenger] Rejected channel post from %s: %v", senderPubKey[:min(16, len(senderPubKey))], err)
+		return
+	}
+
+	if s.channelPostHandler != nil {
+		s.channelPostHandler(senderPubKey, post)
+	}
+}
+
+// handleChannelControl обрабатывает управляющий пакет канала
+func (s *Service) handleChannelControl(packet *pb.Packet, senderPubKey, remoteAddr string) {
+	ctl := &pb.ChannelControl{}
+	if err := proto.Unmarshal(packet.Payload, ctl); err != nil {
+		log.Printf("[Messenger] Failed to unmarshal ChannelControl: %v", err)
+		return
+	}
+	if ctl.ChannelId == "" {
+		return
+	}
+
+	if s.channelControlHandler != nil {
+		s.channelControlHandler(senderPubKey, remoteAddr, ctl)
+	}
+}
+
+// SetChannelPostHandler sets the channel post handler
+func (s *Service) SetChannelPostHandler(h ChannelPostHandler) {
+	s.channelPostHandler = h
+}
+
+// SetChannelControlHandler sets the channel control handler
+func (s *Service) SetChannelControlHandler(h ChannelControlHandler) {
+	s.channelControlHandler = h
+}
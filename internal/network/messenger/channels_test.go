@@ -0,0 +1,49 @@
+package messenger
+
+import (
+	"testing"
+
+	pb "teleghost/internal/proto"
+)
+
+func TestChannelPostSignature(t *testing.T) {
+	owner := newTestService(t)
+	other := newTestService(t)
+
+	newPost := func() *pb.ChannelPost {
+		post := &pb.ChannelPost{
+			ChannelId: "channel-1",
+			Seq:       7,
+			PostId:    "post-7",
+			Content:   "Hello, subscribers",
+			Timestamp: 1700000000000,
+		}
+		owner.SignChannelPost(post)
+		return post
+	}
+
+	post := newPost()
+	if post.OwnerPubKey != owner.identity.PublicKeyBase64 {
+		t.Fatalf("Owner not set: %s", post.OwnerPubKey)
+	}
+	if err := VerifyChannelPost(post); err != nil {
+		t.Fatalf("Valid post rejected: %v", err)
+	}
+
+	tampered := []func(*pb.ChannelPost){
+		func(p *pb.ChannelPost) { p.Seq++ },
+		func(p *pb.ChannelPost) { p.ChannelId = "channel-2" },
+		func(p *pb.ChannelPost) { p.PostId = "post-8" },
+		func(p *pb.ChannelPost) { p.Content = "Forged" },
+		func(p *pb.ChannelPost) { p.Timestamp++ },
+		func(p *pb.ChannelPost) { p.OwnerPubKey = other.identity.PublicKeyBase64 },
+		func(p *pb.ChannelPost) { p.Signature = nil },
+	}
+	for i, tamper := range tampered {
+		p := newPost()
+		tamper(p)
+		if err := VerifyChannelPost(p); err == nil {
+			t.Errorf("Tampered post %d accepted", i)
+		}
+	}
+}
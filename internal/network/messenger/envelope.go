@@ -0,0 +1,60 @@
+package messenger
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"teleghost/internal/core/identity"
+	"teleghost/internal/network/envelope"
+	pb "teleghost/internal/proto"
+)
+
+var (
+	errUnsignedPacket = envelope.ErrUnsigned
+	errBadSignature   = envelope.ErrBadSignature
+	errWrongRecipient = errors.New("packet addressed to another recipient")
+	errNoRecipient    = errors.New("encrypted packet without recipient")
+)
+
+func appendField(data, field []byte) []byte {
+	data = binary.BigEndian.AppendUint32(data, uint32(len(field))) // #nosec G115
+	return append(data, field...)
+}
+
+// signPacket адресует пакет получателю и подписывает конверт
+func (s *Service) signPacket(peerPubKey string, packet *pb.Packet) {
+	if packet.Version >= 3 {
+		packet.RecipientPubKey = []byte(peerPubKey)
+	}
+	packet.Signature = s.identity.SignMessage(envelope.SignedData(packet))
+}
+
+// verifyPacket проверяет подпись и адресата входящего пакета
+func (s *Service) verifyPacket(packet *pb.Packet, senderPubKey string) error {
+	if len(packet.Signature) == 0 {
+		// Пустые пакеты старых версий (heartbeat) приходят без подписи
+		if packet.Version >= 3 || len(packet.Payload) > 0 || len(packet.SessionId) > 0 || packet.Type == pb.PacketType_HANDSHAKE {
+			return errUnsignedPacket
+		}
+		return nil
+	}
+
+	valid, err := identity.VerifySignatureBase64(senderPubKey, envelope.SignedData(packet), packet.Signature)
+	if err != nil || !valid {
+		return errBadSignature
+	}
+
+	if packet.Version < 3 {
+		return nil
+	}
+
+	// Получатель может быть неизвестен отправителю только до обмена ключами
+	recipient := string(packet.RecipientPubKey)
+	switch {
+	case recipient == "" && len(packet.SessionId) > 0:
+		return errNoRecipient
+	case recipient != "" && recipient != s.identity.PublicKeyBase64:
+		return errWrongRecipient
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package messenger
+
+import (
+	"testing"
+
+	"teleghost/internal/core/identity"
+	"teleghost/internal/network/envelope"
+	pb "teleghost/internal/proto"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	id, err := identity.GenerateNewIdentity()
+	if err != nil {
+		t.Fatalf("GenerateNewIdentity failed: %v", err)
+	}
+	return NewService(nil, id.Keys, nil)
+}
+
+func TestEnvelopeBindsHeader(t *testing.T) {
+	alice := newTestService(t)
+	bob := newTestService(t)
+	carol := newTestService(t)
+
+	newPacket := func() *pb.Packet {
+		packet := &pb.Packet{
+			Version:         ProtocolVersion,
+			Type:            pb.PacketType_PROFILE_UPDATE,
+			SenderPubKey:    []byte(alice.identity.PublicKeyBase64),
+			RecipientPubKey: []byte(bob.identity.PublicKeyBase64),
+			Payload:         []byte("profile"),
+		}
+		if err := stampPacket(packet); err != nil {
+			t.Fatalf("stampPacket failed: %v", err)
+		}
+		packet.Signature = alice.identity.SignMessage(envelope.SignedData(packet))
+		return packet
+	}
+	sender := alice.identity.PublicKeyBase64
+
+	if err := bob.verifyPacket(newPacket(), sender); err != nil {
+		t.Fatalf("Valid packet rejected: %v", err)
+	}
+
+	// Пересылка третьему лицу
+	if err := carol.verifyPacket(newPacket(), sender); err != errWrongRecipient {
+		t.Errorf("Expected forwarded packet to be rejected, got %v", err)
+	}
+
+	// Подмена типа пакета
+	retyped := newPacket()
+	retyped.Type = pb.PacketType_TEXT_MESSAGE
+	if err := bob.verifyPacket(retyped, sender); err != errBadSignature {
+		t.Errorf("Expected retyped packet to be rejected, got %v", err)
+	}
+
+	// Подмена получателя ломает подпись
+	readdressed := newPacket()
+	readdressed.RecipientPubKey = []byte(carol.identity.PublicKeyBase64)
+	if err := carol.verifyPacket(readdressed, sender); err != errBadSignature {
+		t.Errorf("Expected readdressed packet to be rejected, got %v", err)
+	}
+
+	// Payload без подписи
+	unsigned := newPacket()
+	unsigned.Signature = nil
+	if err := bob.verifyPacket(unsigned, sender); err != errUnsignedPacket {
+		t.Errorf("Expected unsigned packet to be rejected, got %v", err)
+	}
+	unsigned.Version = 1
+	if err := bob.verifyPacket(unsigned, sender); err != errUnsignedPacket {
+		t.Errorf("Expected unsigned legacy packet with payload to be rejected, got %v", err)
+	}
+}
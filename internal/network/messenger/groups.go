@@ -0,0 +1,141 @@
+package messenger
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"teleghost/internal/core/identity"
+	pb "teleghost/internal/proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// groupDomain отделяет подпись состава группы от других подписей того же ключа
+const groupDomain = "TeleGhost/group/v1"
+
+var (
+	errGroupUnsigned     = errors.New("group state is not signed")
+	errGroupBadSignature = errors.New("invalid group state signature")
+)
+
+// GroupControlHandler обработчик управляющих пакетов группы (state пуст для LEAVE и SYNC)
+type GroupControlHandler func(senderPubKey, senderAddr string, action pb.GroupAction, groupID string, state *pb.GroupState)
+
+// canonicalGroupState сериализует подписываемые поля состава группы.
+// Участники сортируются по ключу, поэтому порядок в пакете не влияет на подпись.
+func canonicalGroupState(state *pb.GroupState) []byte {
+	members := make([]*pb.GroupMember, len(state.Members))
+	copy(members, state.Members)
+	sort.Slice(members, func(i, j int) bool { return members[i].PubKey < members[j].PubKey })
+
+	data := []byte(groupDomain)
+	data = appendField(data, []byte(state.GroupId))
+	data = appendField(data, []byte(state.Name))
+	data = binary.BigEndian.AppendUint64(data, state.Epoch)
+	data = binary.BigEndian.AppendUint64(data, uint64(state.UpdatedAt)) // #nosec G115
+	data = binary.BigEndian.AppendUint32(data, uint32(len(members)))    // #nosec G115
+	for _, m := range members {
+		data = appendField(data, []byte(m.PubKey))
+		data = appendField(data, []byte(m.I2PAddress))
+		data = appendField(data, []byte(m.Nickname))
+		data = binary.BigEndian.AppendUint32(data, uint32(m.Role)) // #nosec G115
+	}
+	return appendField(data, []byte(state.SignerPubKey))
+}
+
+// SignGroupState подписывает состав группы нашим ключом
+func (s *Service) SignGroupState(state *pb.GroupState) {
+	state.SignerPubKey = s.identity.PublicKeyBase64
+	state.Signature = s.identity.SignMessage(canonicalGroupState(state))
+}
+
+// VerifyGroupState проверяет подпись состава группы ключом SignerPubKey.
+// Имеет ли подписавший право менять состав, решает вызывающий.
+func VerifyGroupState(state *pb.GroupState) error {
+	if state == nil || len(state.Signature) == 0 || state.SignerPubKey == "" {
+		return errGroupUnsigned
+	}
+	valid, err := identity.VerifySignatureBase64(state.SignerPubKey, canonicalGroupState(state), state.Signature)
+	if err != nil || !valid {
+		return errGroupBadSignature
+	}
+	return nil
+}
+
+// SendGroupControl отправляет участнику управляющий пакет группы
+func (s *Service) SendGroupControl(destination string, action pb.GroupAction, groupID string, state *pb.GroupState) error {
+	payload, err := proto.Marshal(&pb.GroupControl{
+		Action:  action,
+		GroupId: groupID,
+		State:   state,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal group control failed: %w", err)
+	}
+
+	log.Printf("[Messenger] Sending group %v (group=%s) to %s...", action, groupID[:min(8, len(groupID))], destination[:min(32, len(destination))])
+	return s.SendMessage(destination, &pb.Packet{
+		Type:    pb.PacketType_GROUP_CONTROL,
+		Payload: payload,
+	})
+}
+
+// SendGroupTextMessage отправляет участнику групповое сообщение.
+// Время сообщения передаётся явно, чтобы у всех участников оно совпадало.
+func (s *Service) SendGroupTextMessage(destination, groupID string, epoch uint64, messageID, content, replyToID string, timestamp int64) error {
+	payload, err := proto.Marshal(&pb.TextMessage{
+		ChatId:     groupID,
+		GroupId:    groupID,
+		GroupEpoch: epoch,
+		Content:    content,
+		Timestamp:  timestamp,
+		MessageId:  messageID,
+		ReplyToId:  replyToID,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal group message failed: %w", err)
+	}
+
+	return s.SendMessage(destination, &pb.Packet{
+		Type:    pb.PacketType_TEXT_MESSAGE,
+		Payload: payload,
+	})
+}
+
+// handleGroupControl обрабатывает управляющий пакет группы
+func (s *Service) handleGroupControl(packet *pb.Packet, senderPubKey, remoteAddr string) {
+	ctl := &pb.GroupControl{}
+	if err := proto.Unmarshal(packet.Payload, ctl); err != nil {
+		log.Printf("[Messenger] Failed to unmarshal GroupControl: %v", err)
+		return
+	}
+
+	groupID := ctl.GroupId
+	if ctl.State != nil {
+		// Подпись проверяем сразу: неподписанный состав дальше не передаём
+		if err := VerifyGroupState(ctl.State); err != nil {
+			log.Printf("[Messenger] Rejected group state from %s: %v", senderPubKey[:min(16, len(senderPubKey))], err)
+			return
+		}
+		if groupID != "" && groupID != ctl.State.GroupId {
+			log.Printf("[Messenger] Rejected group state from %s: group ID mismatch", senderPubKey[:min(16, len(senderPubKey))])
+			return
+		}
+		groupID = ctl.State.GroupId
+	}
+	if groupID == "" {
+		return
+	}
+
+	if s.groupControlHandler != nil {
+		s.groupControlHandler(senderPubKey, remoteAddr, ctl.Action, groupID, ctl.State)
+	}
+}
+
+// SetGroupControlHandler sets the group control handler
+func (s *Service) SetGroupControlHandler(h GroupControlHandler) {
+	s.groupControlHandler = h
+}
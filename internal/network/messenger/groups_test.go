@@ -0,0 +1,58 @@
+package messenger
+
+import (
+	"testing"
+
+	pb "teleghost/internal/proto"
+)
+
+func TestGroupStateSignature(t *testing.T) {
+	alice := newTestService(t)
+	bob := newTestService(t)
+
+	newState := func() *pb.GroupState {
+		state := &pb.GroupState{
+			GroupId:   "group-1",
+			Name:      "Team",
+			Epoch:     2,
+			UpdatedAt: 1700000000000,
+			Members: []*pb.GroupMember{
+				{PubKey: alice.identity.PublicKeyBase64, I2PAddress: "alice.b32.i2p", Nickname: "Alice", Role: pb.GroupRole_GROUP_ROLE_ADMIN},
+				{PubKey: bob.identity.PublicKeyBase64, I2PAddress: "bob.b32.i2p", Nickname: "Bob"},
+			},
+		}
+		alice.SignGroupState(state)
+		return state
+	}
+
+	state := newState()
+	if state.SignerPubKey != alice.identity.PublicKeyBase64 {
+		t.Fatalf("Signer not set: %s", state.SignerPubKey)
+	}
+	if err := VerifyGroupState(state); err != nil {
+		t.Fatalf("Valid state rejected: %v", err)
+	}
+
+	// Порядок участников не влияет на подпись
+	state.Members[0], state.Members[1] = state.Members[1], state.Members[0]
+	if err := VerifyGroupState(state); err != nil {
+		t.Errorf("Reordered state rejected: %v", err)
+	}
+
+	tampered := []func(*pb.GroupState){
+		func(s *pb.GroupState) { s.Epoch++ },
+		func(s *pb.GroupState) { s.Name = "Other" },
+		func(s *pb.GroupState) { s.Members[1].Role = pb.GroupRole_GROUP_ROLE_ADMIN },
+		func(s *pb.GroupState) { s.Members[1].I2PAddress = "mallory.b32.i2p" },
+		func(s *pb.GroupState) { s.Members = s.Members[:1] },
+		func(s *pb.GroupState) { s.SignerPubKey = bob.identity.PublicKeyBase64 },
+		func(s *pb.GroupState) { s.Signature = nil },
+	}
+	for i, tamper := range tampered {
+		s := newState()
+		tamper(s)
+		if err := VerifyGroupState(s); err == nil {
+			t.Errorf("Tampered state %d accepted", i)
+		}
+	}
+}
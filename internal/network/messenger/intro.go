@@ -0,0 +1,80 @@
+package messenger
+
+import (
+	"crypto/ecdh"
+	"errors"
+	"fmt"
+
+	"teleghost/internal/core/identity"
+	pb "teleghost/internal/proto"
+)
+
+// Первое сообщение незнакомца. Незнакомцу с непринятым запросом мы не отвечаем даже handshake,
+// поэтому сессии с нами у него нет. Текст он запечатывает на наш статический ключ из адреса
+// (IntroKey) и отправляет в одну сторону; мы кладём его в запросы, ничем не выдавая, что в сети.
+
+var errBadSealed = errors.New("invalid sealed packet")
+
+// IntroKeyResolver возвращает статический ключ получателя из его адреса (nil — неизвестен)
+type IntroKeyResolver func(destination string) []byte
+
+// SetIntroKey задаёт наш статический ключ для первых сообщений от незнакомцев
+func (s *Service) SetIntroKey(key *ecdh.PrivateKey) {
+	s.mu.Lock()
+	s.introKey = key
+	s.mu.Unlock()
+}
+
+// SetIntroKeyResolver устанавливает поиск статического ключа получателя
+func (s *Service) SetIntroKeyResolver(r IntroKeyResolver) {
+	s.introResolver = r
+}
+
+func (s *Service) getIntroKey() *ecdh.PrivateKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.introKey
+}
+
+// introAD — associated data запечатанного сообщения: тип, отправитель и ключ получателя
+func introAD(packetType pb.PacketType, senderPubKey string, recipientKey []byte) []byte {
+	return append(sessionAD(packetType, senderPubKey), recipientKey...)
+}
+
+// recipientIntroKey возвращает ключ, на который можно запечатать пакет без сессии (nil — нельзя).
+// Запечатываем только текст: без сессии у получателя нет прямой секретности.
+func (s *Service) recipientIntroKey(destination string, packet *pb.Packet) []byte {
+	if packet.Type != pb.PacketType_TEXT_MESSAGE || s.introResolver == nil {
+		return nil
+	}
+	if peer := s.resolvePeer(destination); peer != "" && (s.HasSession(peer) || s.isLegacy(peer)) {
+		return nil
+	}
+	return s.introResolver(destination)
+}
+
+// sealIntro запечатывает payload на статический ключ получателя
+func (s *Service) sealIntro(recipientKey []byte, packet *pb.Packet) error {
+	ephemeral, sealed, err := identity.SealIntro(recipientKey, packet.Payload, introAD(packet.Type, s.identity.PublicKeyBase64, recipientKey))
+	if err != nil {
+		return fmt.Errorf("seal failed: %w", err)
+	}
+	packet.Payload = sealed
+	packet.SealedEphemeral = ephemeral
+	return nil
+}
+
+// openIntro открывает запечатанный на наш статический ключ payload
+func (s *Service) openIntro(packet *pb.Packet, senderPubKey string) error {
+	key := s.getIntroKey()
+	if key == nil || packet.Type != pb.PacketType_TEXT_MESSAGE || packet.Version < 3 || len(packet.SessionId) > 0 {
+		return errBadSealed
+	}
+	plain, err := identity.OpenIntro(key, packet.SealedEphemeral, packet.Payload, introAD(packet.Type, senderPubKey, key.PublicKey().Bytes()))
+	if err != nil {
+		return fmt.Errorf("open sealed failed: %w", err)
+	}
+	packet.Payload = plain
+	packet.SealedEphemeral = nil
+	return nil
+}
@@ -0,0 +1,82 @@
+package messenger
+
+import (
+	"log"
+
+	pb "teleghost/internal/proto"
+)
+
+// leaseSetAuthKeySize — размер X25519 ключа авторизации
+const leaseSetAuthKeySize = 32
+
+// PeerLeaseSet — данные собеседника для зашифрованных leaseSet
+type PeerLeaseSet struct {
+	// AuthKey — его X25519 ключ: с ним он найдёт наш leaseSet (nil — ещё не присылал)
+	AuthKey []byte
+
+	// BlindedAddress — его b33 адрес ("" — leaseSet открытый)
+	BlindedAddress string
+}
+
+// LeaseSetHandler вызывается, когда собеседник прислал ключ или b33 адрес. senderAddr — его
+// адрес из пакета: контакта с этим ключом может ещё не быть.
+type LeaseSetHandler func(senderPubKey, senderAddr string, ls PeerLeaseSet)
+
+// SetLeaseSetHandler задаёт обработчик ключей и b33 адресов собеседников
+func (s *Service) SetLeaseSetHandler(h LeaseSetHandler) {
+	s.leaseSetHandler = h
+}
+
+// SetLeaseSetInfo задаёт наш ключ авторизации и b33 адрес ("" — leaseSet открытый).
+// Оба уходят собеседникам в handshake, адрес — ещё и в ProfileUpdate.
+func (s *Service) SetLeaseSetInfo(authKey []byte, blindedAddr string) {
+	s.mu.Lock()
+	s.leaseSetAuthKey = authKey
+	s.blindedAddr = blindedAddr
+	s.mu.Unlock()
+}
+
+// BlindedAddress возвращает наш b33 адрес
+func (s *Service) BlindedAddress() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blindedAddr
+}
+
+// fillHandshakeLeaseSet добавляет в handshake наш ключ авторизации и b33 адрес
+func (s *Service) fillHandshakeLeaseSet(hs *pb.Handshake) {
+	s.mu.Lock()
+	hs.LeaseSetAuthKey = s.leaseSetAuthKey
+	hs.BlindedAddress = s.blindedAddr
+	s.mu.Unlock()
+}
+
+// GetPeerLeaseSet возвращает последние данные leaseSet собеседника
+func (s *Service) GetPeerLeaseSet(peerPubKey string) (PeerLeaseSet, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ls, ok := s.peerLeaseSets[peerPubKey]
+	return ls, ok
+}
+
+// notifyLeaseSet запоминает данные leaseSet собеседника и передаёт их обработчику.
+// authKey приходит только в handshake: без него прежний ключ остаётся в силе.
+func (s *Service) notifyLeaseSet(senderPubKey, senderAddr string, authKey []byte, blindedAddr string) {
+	if len(authKey) != 0 && len(authKey) != leaseSetAuthKeySize {
+		log.Printf("[Messenger] Invalid leaseSet auth key from %s", senderPubKey[:min(16, len(senderPubKey))])
+		authKey = nil
+	}
+
+	s.mu.Lock()
+	ls := s.peerLeaseSets[senderPubKey]
+	if len(authKey) > 0 {
+		ls.AuthKey = authKey
+	}
+	ls.BlindedAddress = blindedAddr
+	s.peerLeaseSets[senderPubKey] = ls
+	s.mu.Unlock()
+
+	if s.leaseSetHandler != nil {
+		s.leaseSetHandler(senderPubKey, senderAddr, ls)
+	}
+}
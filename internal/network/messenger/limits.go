@@ -0,0 +1,264 @@
+package messenger
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	pb "teleghost/internal/proto"
+)
+
+// Ограничения на входящий трафик: один собеседник не должен занять всю память и процессор.
+// Нарушитель (поток пакетов, слишком большой пакет) временно отключается.
+
+var (
+	errRateLimited = errors.New("packet rate limit exceeded")
+	errInFlight    = errors.New("too many bytes in flight")
+	errFrameTag    = errors.New("frame tag does not match packet type")
+	errBlockedPeer = errors.New("sender is blocked")
+)
+
+// Limits — пределы входящих соединений и пакетов
+type Limits struct {
+	MaxInboundConns int     // одновременных входящих соединений всего
+	MaxConnsPerPeer int     // одновременных входящих соединений с одного адреса
+	PacketRate      float64 // пакетов в секунду с одного адреса в среднем
+	PacketBurst     int     // сколько пакетов подряд можно прислать сверх среднего
+	MaxInFlight     int64   // байт входящих пакетов в обработке одновременно
+	MaxPeerInFlight int64   // из них с одного адреса
+
+	// SizeLimits — предел размера кадра по типу пакета (остальные — до MaxPacketSize).
+	// Тип приходит в заголовке кадра, поэтому предел проверяется до чтения данных.
+	SizeLimits map[pb.PacketType]int
+
+	// MaxUntaggedSize — предел кадра без типа в заголовке. Больше разрешаем только
+	// собеседникам, которые по handshake — старые сборки без меток кадров.
+	MaxUntaggedSize int
+
+	BanDuration time.Duration // на сколько отключаем нарушителя
+}
+
+// DefaultLimits возвращает пределы по умолчанию
+func DefaultLimits() Limits {
+	const kb = 1024
+	return Limits{
+		MaxInboundConns: 64,
+		MaxConnsPerPeer: 4,
+		PacketRate:      20,
+		PacketBurst:     200,
+		MaxInFlight:     128 * 1024 * kb,
+		MaxPeerInFlight: 64 * 1024 * kb,
+		MaxUntaggedSize: 64 * kb,
+		SizeLimits: map[pb.PacketType]int{
+			pb.PacketType_HEARTBEAT:       4 * kb,
+			pb.PacketType_TYPING:          4 * kb,
+			pb.PacketType_PRESENCE:        4 * kb,
+			pb.PacketType_PROFILE_REQUEST: 4 * kb,
+			pb.PacketType_FILE_STREAM:     4 * kb,
+			pb.PacketType_HANDSHAKE:       16 * kb,
+			pb.PacketType_RECEIPT:         64 * kb,
+			pb.PacketType_FILE_RESPONSE:   64 * kb,
+			pb.PacketType_FILE_CHUNK_ACK:  64 * kb,
+			pb.PacketType_FILE_RESUME:     64 * kb,
+			pb.PacketType_MESSAGE_DELETE:  64 * kb,
+			pb.PacketType_PROFILE_UPDATE:  1024 * kb,
+			pb.PacketType_FILE_CHUNK:      MaxFileChunkSize + 64*kb,
+		},
+		BanDuration: 5 * time.Minute,
+	}
+}
+
+// normalize подставляет значения по умолчанию вместо нулевых
+func (l Limits) normalize() Limits {
+	def := DefaultLimits()
+	if l.MaxInboundConns <= 0 {
+		l.MaxInboundConns = def.MaxInboundConns
+	}
+	if l.MaxConnsPerPeer <= 0 {
+		l.MaxConnsPerPeer = def.MaxConnsPerPeer
+	}
+	if l.PacketRate <= 0 {
+		l.PacketRate = def.PacketRate
+	}
+	if l.PacketBurst <= 0 {
+		l.PacketBurst = def.PacketBurst
+	}
+	if l.MaxInFlight <= 0 {
+		l.MaxInFlight = def.MaxInFlight
+	}
+	if l.MaxPeerInFlight <= 0 {
+		l.MaxPeerInFlight = def.MaxPeerInFlight
+	}
+	if l.MaxUntaggedSize <= 0 {
+		l.MaxUntaggedSize = def.MaxUntaggedSize
+	}
+	if l.SizeLimits == nil {
+		l.SizeLimits = def.SizeLimits
+	}
+	if l.BanDuration <= 0 {
+		l.BanDuration = def.BanDuration
+	}
+	return l
+}
+
+// maxSize — предел размера кадра для типа пакета
+func (l Limits) maxSize(t pb.PacketType) int {
+	if n, ok := l.SizeLimits[t]; ok {
+		return n
+	}
+	return MaxPacketSize
+}
+
+// SetLimits задаёт пределы входящего трафика (нулевые поля — по умолчанию)
+func (s *Service) SetLimits(l Limits) {
+	s.limiter.setLimits(l.normalize())
+}
+
+// staleBucketAge — через сколько без пакетов забываем счётчик адреса
+const staleBucketAge = 10 * time.Minute
+
+// tokenBucket — счётчик пакетов одного адреса
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter учитывает соединения, частоту пакетов и занятую ими память
+type limiter struct {
+	mu       sync.Mutex
+	limits   Limits
+	conns    int
+	perPeer  map[string]int
+	buckets  map[string]*tokenBucket
+	bans     map[string]time.Time
+	inFlight int64
+	peerHeld map[string]int64 // байт в обработке по адресам
+	now      func() time.Time
+}
+
+func newLimiter(l Limits) *limiter {
+	return &limiter{
+		limits:   l,
+		perPeer:  make(map[string]int),
+		buckets:  make(map[string]*tokenBucket),
+		bans:     make(map[string]time.Time),
+		peerHeld: make(map[string]int64),
+		now:      time.Now,
+	}
+}
+
+func (l *limiter) setLimits(limits Limits) {
+	l.mu.Lock()
+	l.limits = limits
+	l.mu.Unlock()
+}
+
+func (l *limiter) get() Limits {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limits
+}
+
+// banned сообщает, отключён ли адрес за нарушение
+func (l *limiter) banned(dest string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	until, ok := l.bans[dest]
+	if ok && !l.now().Before(until) {
+		delete(l.bans, dest)
+		return false
+	}
+	return ok
+}
+
+// ban отключает адрес на BanDuration
+func (l *limiter) ban(dest string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bans[dest] = l.now().Add(l.limits.BanDuration)
+	delete(l.buckets, dest)
+}
+
+// acquireConn занимает место под входящее соединение
+func (l *limiter) acquireConn(dest string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conns >= l.limits.MaxInboundConns || l.perPeer[dest] >= l.limits.MaxConnsPerPeer {
+		return false
+	}
+	l.conns++
+	l.perPeer[dest]++
+	return true
+}
+
+func (l *limiter) releaseConn(dest string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.conns--
+	if l.perPeer[dest]--; l.perPeer[dest] <= 0 {
+		delete(l.perPeer, dest)
+	}
+}
+
+// allowPacket списывает токен адреса; false — адрес шлёт пакеты быстрее разрешённого
+func (l *limiter) allowPacket(dest string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[dest]
+	if !ok {
+		if len(l.buckets) >= 4096 {
+			l.pruneLocked(now)
+		}
+		b = &tokenBucket{tokens: float64(l.limits.PacketBurst), last: now}
+		l.buckets[dest] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.limits.PacketRate
+	if limit := float64(l.limits.PacketBurst); b.tokens > limit {
+		b.tokens = limit
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// pruneLocked забывает давно молчащие адреса и истёкшие баны
+func (l *limiter) pruneLocked(now time.Time) {
+	for dest, b := range l.buckets {
+		if now.Sub(b.last) > staleBucketAge {
+			delete(l.buckets, dest)
+		}
+	}
+	for dest, until := range l.bans {
+		if !now.Before(until) {
+			delete(l.bans, dest)
+		}
+	}
+}
+
+// reserve занимает память под входящий пакет адреса; false — он сам или все вместе
+// уже читают слишком много
+func (l *limiter) reserve(dest string, n int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight+n > l.limits.MaxInFlight || l.peerHeld[dest]+n > l.limits.MaxPeerInFlight {
+		return false
+	}
+	l.inFlight += n
+	l.peerHeld[dest] += n
+	return true
+}
+
+func (l *limiter) release(dest string, n int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight -= n
+	if l.peerHeld[dest] -= n; l.peerHeld[dest] <= 0 {
+		delete(l.peerHeld, dest)
+	}
+}
@@ -0,0 +1,201 @@
+package messenger
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"teleghost/internal/network/wire"
+	pb "teleghost/internal/proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func newTestLimiter(l Limits) (*limiter, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	lim := newLimiter(l.normalize())
+	lim.now = func() time.Time { return now }
+	return lim, &now
+}
+
+func TestLimiterPacketRate(t *testing.T) {
+	lim, now := newTestLimiter(Limits{PacketRate: 2, PacketBurst: 3})
+
+	for i := 0; i < 3; i++ {
+		if !lim.allowPacket("peer") {
+			t.Fatalf("Packet %d within burst rejected", i)
+		}
+	}
+	if lim.allowPacket("peer") {
+		t.Fatal("Packet over burst allowed")
+	}
+	if !lim.allowPacket("other") {
+		t.Error("Other peer limited by someone else's burst")
+	}
+
+	// За полсекунды набегает один токен
+	*now = now.Add(500 * time.Millisecond)
+	if !lim.allowPacket("peer") {
+		t.Error("Token not refilled")
+	}
+	if lim.allowPacket("peer") {
+		t.Error("More tokens refilled than rate allows")
+	}
+}
+
+func TestLimiterConnections(t *testing.T) {
+	lim, _ := newTestLimiter(Limits{MaxInboundConns: 3, MaxConnsPerPeer: 2})
+
+	if !lim.acquireConn("a") || !lim.acquireConn("a") {
+		t.Fatal("Connections within per-peer limit rejected")
+	}
+	if lim.acquireConn("a") {
+		t.Fatal("Per-peer connection limit not enforced")
+	}
+	if !lim.acquireConn("b") {
+		t.Fatal("Connection within total limit rejected")
+	}
+	if lim.acquireConn("c") {
+		t.Fatal("Total connection limit not enforced")
+	}
+
+	lim.releaseConn("a")
+	if !lim.acquireConn("c") {
+		t.Error("Released connection slot not reused")
+	}
+}
+
+func TestLimiterBanAndInFlight(t *testing.T) {
+	lim, now := newTestLimiter(Limits{BanDuration: time.Minute, MaxInFlight: 100, MaxPeerInFlight: 70})
+
+	lim.ban("peer")
+	if !lim.banned("peer") || lim.banned("other") {
+		t.Fatal("Ban not applied to the right peer")
+	}
+	*now = now.Add(time.Minute)
+	if lim.banned("peer") {
+		t.Error("Ban not expired")
+	}
+
+	if !lim.reserve("peer", 60) {
+		t.Fatal("Reservation within limit rejected")
+	}
+	if lim.reserve("peer", 20) {
+		t.Fatal("Per-peer in-flight limit not enforced")
+	}
+	if !lim.reserve("other", 20) {
+		t.Fatal("Other peer limited by someone else's reservation")
+	}
+	if lim.reserve("third", 30) {
+		t.Fatal("In-flight limit not enforced")
+	}
+	lim.release("peer", 60)
+	if !lim.reserve("third", 60) {
+		t.Error("Released bytes not reusable")
+	}
+}
+
+func TestOversizePacketDisconnects(t *testing.T) {
+	s := newTestService(t)
+
+	data, err := proto.Marshal(&pb.Packet{Type: pb.PacketType_HEARTBEAT, Payload: make([]byte, 8*1024)})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go wire.WriteFrame(client, data, time.Second)
+
+	// Heartbeat больше своего предела: соединение закрывается, адрес отключается
+	err = s.receivePacket(server, "flood-dest")
+	if !errors.Is(err, wire.ErrFrameTooLarge) {
+		t.Fatalf("Expected ErrFrameTooLarge, got %v", err)
+	}
+	if got := s.stats.rejectedOversize.Load(); got != 1 {
+		t.Errorf("Expected 1 oversize rejection, got %d", got)
+	}
+	if s.admitConnection("flood-dest") {
+		t.Error("Connection from banned peer admitted")
+	}
+	if s.stats.received.Load() != 0 {
+		t.Error("Oversize packet dispatched")
+	}
+}
+
+func TestFrameLimitCheckedBeforeBody(t *testing.T) {
+	s := newTestService(t)
+	legacy := newTestService(t)
+	legacyKey := legacy.identity.PublicKeyBase64
+	s.SetPeerResolver(func(dest string) string {
+		if dest == "legacy-dest" {
+			return legacyKey
+		}
+		return ""
+	})
+	s.SetPeerInfo(legacyKey, ProtocolVersion, LocalCapabilities&^CapTaggedFrames)
+
+	// Пишем только заголовок: если бы пакет читался, receivePacket ждал бы тело до таймаута
+	header := func(t *testing.T, word uint32, tag []byte) net.Conn {
+		t.Helper()
+		client, server := net.Pipe()
+		t.Cleanup(func() {
+			client.Close()
+			server.Close()
+		})
+		go func() {
+			_, _ = client.Write(binary.BigEndian.AppendUint32(nil, word))
+			if tag != nil {
+				_, _ = client.Write(tag)
+			}
+		}()
+		return server
+	}
+	heartbeat := binary.BigEndian.AppendUint16(nil, uint16(pb.PacketType_HEARTBEAT))
+
+	for _, tc := range []struct {
+		name   string
+		conn   net.Conn
+		remote string
+	}{
+		{"tagged heartbeat", header(t, 1<<31|8*1024, heartbeat), "tagged-dest"},
+		{"untagged from stranger", header(t, 1024*1024, nil), "stranger-dest"},
+	} {
+		start := time.Now()
+		if err := s.receivePacket(tc.conn, tc.remote); !errors.Is(err, wire.ErrFrameTooLarge) {
+			t.Errorf("%s: expected ErrFrameTooLarge, got %v", tc.name, err)
+		}
+		if time.Since(start) > time.Second {
+			t.Errorf("%s: frame body was read before the limit check", tc.name)
+		}
+		if s.admitConnection(tc.remote) {
+			t.Errorf("%s: sender not disconnected", tc.name)
+		}
+	}
+
+	// Старой сборке из контактов большие кадры без метки по-прежнему разрешены
+	data, err := proto.Marshal(&pb.Packet{Type: pb.PacketType_TEXT_MESSAGE, Payload: make([]byte, 128*1024)})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go wire.WriteFrame(client, data, time.Second)
+	if err := s.receivePacket(server, "legacy-dest"); err != nil {
+		t.Errorf("Legacy frame rejected: %v", err)
+	}
+
+	// Метка не должна расходиться с типом пакета
+	data, err = proto.Marshal(&pb.Packet{Type: pb.PacketType_PROFILE_UPDATE, Payload: make([]byte, 8*1024)})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	go wire.WriteTaggedFrame(client, uint16(pb.PacketType_TEXT_MESSAGE), data, time.Second)
+	if err := s.receivePacket(server, "mismatch-dest"); !errors.Is(err, errFrameTag) {
+		t.Errorf("Expected errFrameTag, got %v", err)
+	}
+}
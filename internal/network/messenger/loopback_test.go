@@ -0,0 +1,229 @@
+package messenger
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"teleghost/internal/core"
+	"teleghost/internal/core/identity"
+	"teleghost/internal/network/envelope"
+	"teleghost/internal/network/loopback"
+	pb "teleghost/internal/proto"
+)
+
+// peer — участник переписки в сети в памяти; обработчики складывают события в каналы
+type peer struct {
+	*Service
+	pub      string
+	dest     string
+	messages chan *core.Message
+	profiles chan string
+	offers   chan string
+	answers  chan bool
+	chunks   chan []byte
+}
+
+func newLoopbackPeer(t *testing.T, n *loopback.Network) *peer {
+	t.Helper()
+	r := n.NewRouter()
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("Router start failed: %v", err)
+	}
+	id, err := identity.GenerateNewIdentity()
+	if err != nil {
+		t.Fatalf("GenerateNewIdentity failed: %v", err)
+	}
+
+	p := &peer{
+		pub:      id.Keys.PublicKeyBase64,
+		messages: make(chan *core.Message, 16),
+		profiles: make(chan string, 16),
+		offers:   make(chan string, 16),
+		answers:  make(chan bool, 16),
+		chunks:   make(chan []byte, 16),
+	}
+	p.dest, _ = r.GetAddress()
+	p.Service = NewService(r, id.Keys, func(msg *core.Message, _, _ string) { p.messages <- msg })
+	p.SetStampPolicy(envelope.StampPolicy{Bits: 4})
+	p.SetProfileUpdateHandler(func(_, nickname, _ string, _ []byte, _, _ string) { p.profiles <- nickname })
+	p.SetFileOfferHandler(func(_, messageID, _ string, _ []string, _ int64, _ int32, _ []*pb.FileManifest) {
+		p.offers <- messageID
+	})
+	p.SetFileResponseHandler(func(_, _, _ string, accepted bool) { p.answers <- accepted })
+	p.SetFileChunkHandler(func(_, _, _ string, _ uint32, data []byte) { p.chunks <- data })
+
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Service start failed: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = p.Stop()
+		_ = r.Stop()
+	})
+	return p
+}
+
+func receive[T any](t *testing.T, ch chan T, what string) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Timed out waiting for %s", what)
+		panic("unreachable")
+	}
+}
+
+func TestLoopbackConversation(t *testing.T) {
+	n := loopback.NewNetwork()
+	alice, bob, carol := newLoopbackPeer(t, n), newLoopbackPeer(t, n), newLoopbackPeer(t, n)
+	chatAB := identity.CalculateChatID(alice.pub, bob.pub)
+
+	// Первое сообщение само устанавливает E2EE-сессию
+	if err := alice.SendTextMessageWithID(bob.dest, chatAB, "m1", "Hi Bob", ""); err != nil {
+		t.Fatalf("Alice -> Bob failed: %v", err)
+	}
+	if msg := receive(t, bob.messages, "message from Alice"); msg.Content != "Hi Bob" || msg.ChatID != chatAB {
+		t.Fatalf("Unexpected message %q in %s", msg.Content, msg.ChatID)
+	}
+	if !alice.HasSession(bob.pub) || !bob.HasSession(alice.pub) {
+		t.Fatal("E2EE session not established")
+	}
+
+	if err := bob.SendTextMessageWithID(alice.dest, chatAB, "m2", "Hi Alice", "m1"); err != nil {
+		t.Fatalf("Bob -> Alice failed: %v", err)
+	}
+	if msg := receive(t, alice.messages, "reply from Bob"); msg.Content != "Hi Alice" {
+		t.Fatalf("Unexpected reply %q", msg.Content)
+	}
+
+	// Третий участник пишет Бобу параллельно
+	chatCB := identity.CalculateChatID(carol.pub, bob.pub)
+	if err := carol.SendTextMessageWithID(bob.dest, chatCB, "m3", "Hi from Carol", ""); err != nil {
+		t.Fatalf("Carol -> Bob failed: %v", err)
+	}
+	if msg := receive(t, bob.messages, "message from Carol"); msg.Content != "Hi from Carol" || msg.ChatID != chatCB {
+		t.Fatalf("Unexpected message %q in %s", msg.Content, msg.ChatID)
+	}
+
+	// Профиль
+	if err := alice.SendProfileUpdate(bob.dest, "Alice", "bio", nil); err != nil {
+		t.Fatalf("SendProfileUpdate failed: %v", err)
+	}
+	if nick := receive(t, bob.profiles, "profile update"); nick != "Alice" {
+		t.Fatalf("Unexpected nickname %q", nick)
+	}
+
+	// Файл: предложение, согласие и поток частей
+	if err := alice.SendFileOffer(bob.dest, chatAB, "f1", []string{"a.bin"}, 5, 1, nil); err != nil {
+		t.Fatalf("SendFileOffer failed: %v", err)
+	}
+	if id := receive(t, bob.offers, "file offer"); id != "f1" {
+		t.Fatalf("Unexpected offer %q", id)
+	}
+	if err := bob.SendFileResponse(alice.dest, chatAB, "f1", true); err != nil {
+		t.Fatalf("SendFileResponse failed: %v", err)
+	}
+	if !receive(t, alice.answers, "file response") {
+		t.Fatal("File offer declined")
+	}
+
+	chunks := [][]byte{bytes.Repeat([]byte{1}, FileChunkSize), []byte("tail")}
+	stream, err := alice.OpenFileStream(bob.dest, chatAB, "f1", "file", 0, uint32(len(chunks)))
+	if err != nil {
+		t.Fatalf("OpenFileStream failed: %v", err)
+	}
+	for _, c := range chunks {
+		if err := stream.WriteChunk(c); err != nil {
+			t.Fatalf("WriteChunk failed: %v", err)
+		}
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Stream close failed: %v", err)
+	}
+	for i, want := range chunks {
+		if got := receive(t, bob.chunks, "file chunk"); !bytes.Equal(got, want) {
+			t.Fatalf("Chunk %d mismatch", i)
+		}
+	}
+}
+
+func TestLoopbackPeerOffline(t *testing.T) {
+	n := loopback.NewNetwork()
+	alice := newLoopbackPeer(t, n)
+	offline := n.NewRouter()
+	dest, _ := offline.GetAddress()
+
+	// Роутер не запущен, почтового ящика нет — доставить некуда
+	if err := alice.SendHeartbeat(dest); err == nil {
+		t.Error("Delivered to offline peer")
+	}
+}
+
+func TestLoopbackRouterRestart(t *testing.T) {
+	n := loopback.NewNetwork()
+	alice, bob := newLoopbackPeer(t, n), newLoopbackPeer(t, n)
+	chatID := identity.CalculateChatID(alice.pub, bob.pub)
+
+	// Роутер Боба перезапускается под работающим мессенджером: приём продолжается
+	r := bob.router.(*loopback.Router)
+	if err := r.Stop(); err != nil {
+		t.Fatalf("Router stop failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("Router start failed: %v", err)
+	}
+
+	if err := alice.SendTextMessageWithID(bob.dest, chatID, "m1", "after restart", ""); err != nil {
+		t.Fatalf("Alice -> Bob failed: %v", err)
+	}
+	if msg := receive(t, bob.messages, "message after restart"); msg.Content != "after restart" {
+		t.Fatalf("Unexpected message %q", msg.Content)
+	}
+}
+
+func TestLoopbackLeaseSetExchange(t *testing.T) {
+	n := loopback.NewNetwork()
+	alice, bob := newLoopbackPeer(t, n), newLoopbackPeer(t, n)
+
+	type leaseSet struct {
+		from, addr string
+		PeerLeaseSet
+	}
+	watch := func(p *peer) chan leaseSet {
+		ch := make(chan leaseSet, 16)
+		p.SetLeaseSetHandler(func(from, addr string, ls PeerLeaseSet) { ch <- leaseSet{from, addr, ls} })
+		return ch
+	}
+	aliceGot, bobGot := watch(alice), watch(bob)
+	aliceKey, bobKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	alice.SetLeaseSetInfo(aliceKey, "alice.b33")
+	bob.SetLeaseSetInfo(bobKey, "")
+
+	// Ключи и адреса уходят в обе стороны вместе с handshake
+	chatID := identity.CalculateChatID(alice.pub, bob.pub)
+	if err := alice.SendTextMessageWithID(bob.dest, chatID, "m1", "hi", ""); err != nil {
+		t.Fatalf("Alice -> Bob failed: %v", err)
+	}
+	if got := receive(t, bobGot, "Alice's leaseSet"); got.from != alice.pub || got.addr != alice.dest || !bytes.Equal(got.AuthKey, aliceKey) || got.BlindedAddress != "alice.b33" {
+		t.Errorf("Bob got %+v", got)
+	}
+	if got := receive(t, aliceGot, "Bob's leaseSet"); got.from != bob.pub || !bytes.Equal(got.AuthKey, bobKey) || got.BlindedAddress != "" {
+		t.Errorf("Alice got %+v", got)
+	}
+	receive(t, bob.messages, "message")
+
+	// Новый b33 адрес приходит в профиле, ключ при этом остаётся прежним
+	alice.SetLeaseSetInfo(aliceKey, "alice2.b33")
+	if err := alice.SendProfileUpdate(bob.dest, "Alice", "", nil); err != nil {
+		t.Fatalf("SendProfileUpdate failed: %v", err)
+	}
+	if got := receive(t, bobGot, "profile leaseSet"); !bytes.Equal(got.AuthKey, aliceKey) || got.BlindedAddress != "alice2.b33" {
+		t.Errorf("Bob got %+v from profile", got)
+	}
+	if ls, ok := bob.GetPeerLeaseSet(alice.pub); !ok || ls.BlindedAddress != "alice2.b33" {
+		t.Errorf("Bob remembers %+v", ls)
+	}
+}
@@ -0,0 +1,107 @@
+package messenger
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"teleghost/internal/network/mailbox"
+	pb "teleghost/internal/proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// errNoMailbox — письмо нельзя оставить в ящике (ящика нет или пакет не подходит)
+var errNoMailbox = errors.New("no mailbox for destination")
+
+// mailboxTypes — пакеты, которые можно оставить в почтовом ящике получателя.
+// Остальные (handshake, файлы, typing, presence) имеют смысл только в сети.
+var mailboxTypes = map[pb.PacketType]bool{
+	pb.PacketType_TEXT_MESSAGE:   true,
+	pb.PacketType_PROFILE_UPDATE: true,
+	pb.PacketType_RECEIPT:        true,
+	pb.PacketType_MESSAGE_EDIT:   true,
+	pb.PacketType_MESSAGE_DELETE: true,
+	pb.PacketType_GROUP_CONTROL:  true,
+}
+
+// MailboxResolver возвращает ключ собеседника и адрес его почтового сервера ("" — ящика нет)
+type MailboxResolver func(destination string) (peerPubKey, mailboxAddr string)
+
+// SetMailboxResolver устанавливает поиск почтовых ящиков собеседников
+func (s *Service) SetMailboxResolver(r MailboxResolver) {
+	s.mailboxResolver = r
+}
+
+// SetMailboxAddress задаёт наш почтовый сервер (он уходит собеседникам в ProfileUpdate)
+func (s *Service) SetMailboxAddress(addr string) {
+	s.mu.Lock()
+	s.mailboxAddr = addr
+	s.mu.Unlock()
+}
+
+// MailboxAddress возвращает наш почтовый сервер
+func (s *Service) MailboxAddress() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mailboxAddr
+}
+
+// RegisterMailbox заводит наш ящик на почтовом сервере; письма contacts сервер примет без штампа
+func (s *Service) RegisterMailbox(server string, contacts []string) (*mailbox.Quota, error) {
+	return s.mailbox.Register(server, contacts)
+}
+
+// FetchMailbox забирает письма из нашего ящика и обрабатывает их как входящие пакеты.
+// Возвращает число забранных писем.
+func (s *Service) FetchMailbox() (int, error) {
+	server := s.MailboxAddress()
+	if server == "" {
+		return 0, nil
+	}
+	n, err := s.mailbox.Fetch(server, s.handleStoredPacket)
+	if n > 0 {
+		log.Printf("[Messenger] Fetched %d packets from mailbox", n)
+	}
+	return n, err
+}
+
+// depositToMailbox оставляет пакет в почтовом ящике получателя.
+// Кладём только пакеты, зашифрованные E2EE и адресованные получателю: сервер их не прочитает.
+func (s *Service) depositToMailbox(destination string, packet *pb.Packet, data []byte) error {
+	if !mailboxTypes[packet.Type] || len(packet.SessionId) == 0 || len(packet.RecipientPubKey) == 0 || s.mailboxResolver == nil {
+		return errNoMailbox
+	}
+	peer, server := s.mailboxResolver(destination)
+	if server == "" || peer != string(packet.RecipientPubKey) {
+		return errNoMailbox
+	}
+
+	err := s.mailbox.Deposit(server, peer, data)
+	if errors.Is(err, mailbox.ErrStampRequired) && packet.Stamp == nil && stampTypes[packet.Type] {
+		// Сервер не знает нас как контакт получателя: платим штампом, как незнакомец
+		s.addStamp(destination, packet)
+		if data, err = proto.Marshal(packet); err == nil {
+			err = s.mailbox.Deposit(server, peer, data)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("mailbox deposit failed: %w", err)
+	}
+	log.Printf("[Messenger] Packet type %v for %s... left in mailbox", packet.Type, destination[:min(16, len(destination))])
+	return nil
+}
+
+// handleStoredPacket обрабатывает пакет, забранный из почтового ящика
+func (s *Service) handleStoredPacket(item *pb.MailboxItem) {
+	packet := &pb.Packet{}
+	if err := proto.Unmarshal(item.Envelope, packet); err != nil {
+		log.Printf("[Messenger] Mailbox item %s is not a packet: %v", item.Id, err)
+		return
+	}
+	if !mailboxTypes[packet.Type] {
+		log.Printf("[Messenger] Rejected %v packet from mailbox", packet.Type)
+		return
+	}
+	s.handlePacket(packet, "", true)
+}
@@ -0,0 +1,151 @@
+package messenger
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"teleghost/internal/core"
+	"teleghost/internal/core/identity"
+	"teleghost/internal/network/envelope"
+	"teleghost/internal/network/mailbox"
+	pb "teleghost/internal/proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// pairSessions устанавливает E2EE-сессию между сервисами без handshake по сети
+func pairSessions(t *testing.T, a, b *Service, aDest, bDest string) {
+	t.Helper()
+	aKey, err := identity.GenerateEphemeralKey()
+	if err != nil {
+		t.Fatalf("GenerateEphemeralKey failed: %v", err)
+	}
+	bKey, err := identity.GenerateEphemeralKey()
+	if err != nil {
+		t.Fatalf("GenerateEphemeralKey failed: %v", err)
+	}
+	aPub, bPub := a.identity.PublicKeyBase64, b.identity.PublicKeyBase64
+
+	aSess, err := identity.DeriveSessionKeys(aKey, bKey.PublicKey().Bytes(), aPub, bPub)
+	if err != nil {
+		t.Fatalf("DeriveSessionKeys failed: %v", err)
+	}
+	bSess, err := identity.DeriveSessionKeys(bKey, aKey.PublicKey().Bytes(), bPub, aPub)
+	if err != nil {
+		t.Fatalf("DeriveSessionKeys failed: %v", err)
+	}
+	a.addSession(bPub, aSess, bDest)
+	b.addSession(aPub, bSess, aDest)
+}
+
+func TestMailboxDelivery(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	policy := envelope.StampPolicy{Algorithm: pb.StampAlgorithm_STAMP_SHA256, Bits: 8}
+	cfg := mailbox.DefaultConfig()
+	cfg.Stamp = policy
+	go func() { _ = mailbox.NewServer(mailbox.NewMemoryStore(), cfg).Serve(ctx, ln) }()
+	server := ln.Addr().String()
+	dial := func(addr string) (net.Conn, error) { return net.Dial("tcp", addr) }
+
+	alice := newTestService(t)
+	bob := newTestService(t)
+	alice.mailbox = mailbox.NewClient(dial, alice.identity)
+	bob.mailbox = mailbox.NewClient(dial, bob.identity)
+	bob.ctx = ctx
+	alice.SetStampPolicy(policy)
+	pairSessions(t, alice, bob, "alice-dest", "bob-dest")
+	// Боб уже отвечал Алисе: заранее она штамп не считает
+	alice.SetPeerInfo(bob.identity.PublicKeyBase64, ProtocolVersion, LocalCapabilities)
+
+	var received []*core.Message
+	bob.handler = func(msg *core.Message, senderPubKey, _ string) {
+		if senderPubKey == alice.identity.PublicKeyBase64 {
+			received = append(received, msg)
+		}
+	}
+
+	// Алисы ещё нет в контактах ящика Боба
+	if _, err := bob.RegisterMailbox(server, nil); err != nil {
+		t.Fatalf("RegisterMailbox failed: %v", err)
+	}
+	bob.SetMailboxAddress(server)
+	alice.SetMailboxResolver(func(destination string) (string, string) {
+		if destination == "bob-dest" {
+			return bob.identity.PublicKeyBase64, server
+		}
+		return "", ""
+	})
+
+	var last *pb.Packet
+	deposit := func(packet *pb.Packet) error {
+		sealed, err := alice.sealPacket("bob-dest", packet)
+		if err != nil {
+			t.Fatalf("sealPacket failed: %v", err)
+		}
+		last = sealed
+		data := mustMarshal(t, sealed)
+		return alice.depositToMailbox("bob-dest", sealed, data)
+	}
+	text := func(id string) *pb.Packet {
+		return &pb.Packet{Type: pb.PacketType_TEXT_MESSAGE, Payload: mustMarshal(t, &pb.TextMessage{
+			ChatId: "chat", MessageId: id, Content: "while you were away", Timestamp: time.Now().UnixMilli(),
+		})}
+	}
+
+	// Сервер требует штамп от отправителя не из контактов — Алиса досчитывает его
+	if err := deposit(text("m1")); err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+	if last.Stamp == nil {
+		t.Fatal("Deposit from non-contact accepted without stamp")
+	}
+
+	// Квитанцию нельзя оплатить штампом: от незнакомца сервер её не возьмёт
+	receipt := &pb.Packet{Type: pb.PacketType_RECEIPT, Payload: mustMarshal(t, &pb.Receipt{ChatId: "chat", MessageIds: []string{"x"}})}
+	if err := deposit(receipt); !errors.Is(err, mailbox.ErrStampRequired) {
+		t.Errorf("Expected ErrStampRequired for receipt from non-contact, got %v", err)
+	}
+
+	// Пакеты, которые имеют смысл только в сети, в ящик не кладутся
+	if err := deposit(&pb.Packet{Type: pb.PacketType_TYPING, Payload: mustMarshal(t, &pb.Typing{ChatId: "chat", Typing: true})}); err != errNoMailbox {
+		t.Errorf("Expected errNoMailbox for typing, got %v", err)
+	}
+
+	// Боб выходит в сеть через сутки: пакет старше окна skew, но из ящика принимается
+	bob.replay.now = func() time.Time { return time.Now().Add(24 * time.Hour) }
+	n, err := bob.FetchMailbox()
+	if err != nil {
+		t.Fatalf("FetchMailbox failed: %v", err)
+	}
+	if n != 1 || len(received) != 1 || received[0].Content != "while you were away" {
+		t.Fatalf("Expected stored message, got %d fetched, %d received", n, len(received))
+	}
+	if n, _ := bob.FetchMailbox(); n != 0 {
+		t.Errorf("Expected empty mailbox after fetch, got %d", n)
+	}
+
+	// Контакту штамп не нужен
+	if _, err := bob.RegisterMailbox(server, []string{alice.identity.PublicKeyBase64}); err != nil {
+		t.Fatalf("RegisterMailbox failed: %v", err)
+	}
+	if err := deposit(text("m2")); err != nil || last.Stamp != nil {
+		t.Errorf("Deposit from contact failed or stamped: %v", err)
+	}
+}
+
+func mustMarshal(t *testing.T, msg proto.Message) []byte {
+	t.Helper()
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	return data
+}
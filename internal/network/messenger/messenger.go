@@ -3,18 +3,23 @@ package messenger
 
 import (
 	"context"
-	"encoding/binary"
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"math"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"teleghost/internal/core"
 	"teleghost/internal/core/identity"
-	"teleghost/internal/network/router"
+	"teleghost/internal/network"
+	"teleghost/internal/network/envelope"
+	"teleghost/internal/network/mailbox"
+	"teleghost/internal/network/wire"
 	pb "teleghost/internal/proto"
 
 	"google.golang.org/protobuf/proto"
@@ -22,11 +27,14 @@ import (
 
 const (
 	// ProtocolVersion текущая версия протокола
-	ProtocolVersion = 1
+	ProtocolVersion = 3
 
 	// MaxPacketSize максимальный размер пакета (10MB for images)
 	MaxPacketSize = 50 * 1024 * 1024
 
+	// MaxTextLength — предел текста сообщения и его правки (байт)
+	MaxTextLength = 4096
+
 	// HeartbeatInterval интервал отправки heartbeat
 	HeartbeatInterval = 60 * time.Second
 
@@ -35,10 +43,18 @@ const (
 
 	// ReadTimeout таймаут чтения (I2P медленный, особенно при первом подключении)
 	ReadTimeout = 5 * time.Minute
+
+	// broadcastWorkers — сколько адресатов рассылки обслуживаются одновременно
+	broadcastWorkers = 8
+
+	// acceptRetryDelay, maxAcceptRetryDelay — пауза после ошибки приёма соединения
+	// (удваивается до предела, пока роутер не вернётся)
+	acceptRetryDelay    = 500 * time.Millisecond
+	maxAcceptRetryDelay = 30 * time.Second
 )
 
 // FileOfferHandler обработчик входящих предложений файла
-type FileOfferHandler func(senderPubKey, messageID, chatID string, filenames []string, totalSize int64, fileCount int32)
+type FileOfferHandler func(senderPubKey, messageID, chatID string, filenames []string, totalSize int64, fileCount int32, files []*pb.FileManifest)
 
 // FileResponseHandler обработчик ответов на предложение файла
 type FileResponseHandler func(senderPubKey, messageID, chatID string, accepted bool)
@@ -49,12 +65,24 @@ type MessageHandler func(msg *core.Message, senderPubKey, senderAddr string)
 // ContactRequestHandler обработчик запросов дружбы
 type ContactRequestHandler func(senderPubKey, nickname, i2pAddress string)
 
-// ProfileUpdateHandler обработчик обновлений профиля
-type ProfileUpdateHandler func(senderPubKey, nickname, bio string, avatar []byte, senderAddr string)
+// ProfileUpdateHandler обработчик обновлений профиля (mailboxAddr — почтовый сервер собеседника)
+type ProfileUpdateHandler func(senderPubKey, nickname, bio string, avatar []byte, mailboxAddr, senderAddr string)
+
+// ReceiptHandler обработчик отчётов о доставке/прочтении
+type ReceiptHandler func(senderPubKey, chatID string, messageIDs []string, status core.MessageStatus)
+
+// PeerActivityHandler вызывается на каждый принятый пакет от собеседника
+type PeerActivityHandler func(senderPubKey, senderAddr string)
+
+// MessageEditHandler обработчик редактирования сообщений собеседником
+type MessageEditHandler func(senderPubKey, chatID, messageID, newContent string, editedAt int64)
+
+// MessageDeleteHandler обработчик удаления сообщений собеседником
+type MessageDeleteHandler func(senderPubKey, chatID, messageID string, deleteForAll bool)
 
 // Service — мессенджер сервис
 type Service struct {
-	router         *router.SAMRouter
+	router         network.NetworkRouter
 	identity       *identity.Keys
 	handler        MessageHandler
 	contactHandler ContactRequestHandler
@@ -63,27 +91,73 @@ type Service struct {
 	profileRequestHandler ProfileRequestHandler
 	fileOfferHandler      FileOfferHandler
 	fileResponseHandler   FileResponseHandler
+	receiptHandler        ReceiptHandler
+	editHandler           MessageEditHandler
+	deleteHandler         MessageDeleteHandler
+	activityHandler       PeerActivityHandler
+	fileChunkHandler      FileChunkHandler
+	fileChunkAckHandler   FileChunkAckHandler
+	fileResumeHandler     FileResumeHandler
+	capabilitiesHandler   PeerCapabilitiesHandler
+	typingHandler         TypingHandler
+	presenceHandler       PresenceHandler
+	groupControlHandler   GroupControlHandler
+	channelPostHandler    ChannelPostHandler
+	channelControlHandler ChannelControlHandler
+	leaseSetHandler       LeaseSetHandler
 
 	attachmentSaver AttachmentSaver
+	sessions        *sessionManager
+	sessionStore    SessionStore
+	peerResolver    PeerResolver
+	replyFilter     ReplyFilter
+	introResolver   IntroKeyResolver
+	introKey        *ecdh.PrivateKey // наш статический ключ для первых сообщений незнакомцев (под mu)
+	mailbox         *mailbox.Client
+	mailboxResolver MailboxResolver
+	mailboxAddr     string                  // наш почтовый сервер (под mu)
+	leaseSetAuthKey []byte                  // наш X25519 ключ для чужих зашифрованных leaseSet (под mu)
+	blindedAddr     string                  // наш b33 адрес, если leaseSet зашифрован (под mu)
+	peerLeaseSets   map[string]PeerLeaseSet // ключ собеседника -> его leaseSet (под mu)
+	replay          *replayGuard
+	knownPeer       KnownPeerChecker
+	blocked         *blockList
+	limiter         *limiter
+	stampPolicy     envelope.StampPolicy // под mu
+	peerInfo        *peerRegistry
+	stats           packetStats
 	connections     map[string]net.Conn // destination -> connection
+	inbound         map[net.Conn]bool   // входящие соединения (закрываются при Stop)
 	connMu          sync.RWMutex
+	sending         atomic.Int32 // отправки, пишущие в пул прямо сейчас
 	ctx             context.Context
 	cancel          context.CancelFunc
 	wg              sync.WaitGroup
 	started         bool
 	mu              sync.Mutex
-	myNickname      string // Для отправки в handshake
+	myNickname      string // Для отправки в handshake (под mu)
 }
 
 // NewService создаёт новый MessengerService
-func NewService(r *router.SAMRouter, id *identity.Keys, handler MessageHandler) *Service {
-	return &Service{
+func NewService(r network.NetworkRouter, id *identity.Keys, handler MessageHandler) *Service {
+	s := &Service{
 		router:      r,
 		identity:    id,
 		handler:     handler,
+		sessions:    newSessionManager(),
+		replay:      newReplayGuard(),
+		stampPolicy: envelope.DefaultStampPolicy(),
+		peerInfo:    newPeerRegistry(),
+		blocked:     newBlockList(),
+		limiter:     newLimiter(DefaultLimits()),
 		connections: make(map[string]net.Conn),
+		inbound:     make(map[net.Conn]bool),
 		myNickname:  "User", // Default
+
+		peerLeaseSets: make(map[string]PeerLeaseSet),
 	}
+	s.mailbox = mailbox.NewClient(s.dial, id)
+	return s
 }
 
 // SetContactHandler устанавливает обработчик запросов дружбы
@@ -93,7 +167,15 @@ func (s *Service) SetContactHandler(h ContactRequestHandler) {
 
 // SetNickname устанавливает никнейм для handshake
 func (s *Service) SetNickname(nickname string) {
+	s.mu.Lock()
 	s.myNickname = nickname
+	s.mu.Unlock()
+}
+
+func (s *Service) nickname() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.myNickname
 }
 
 // Start запускает сервис: listener и heartbeat
@@ -107,6 +189,8 @@ func (s *Service) Start(ctx context.Context) error {
 	s.ctx, s.cancel = context.WithCancel(ctx)
 	s.mu.Unlock()
 
+	s.loadSessions()
+
 	// Запускаем listener в горутине
 	s.wg.Add(1)
 	go s.listenLoop()
@@ -115,7 +199,7 @@ func (s *Service) Start(ctx context.Context) error {
 	s.wg.Add(1)
 	go s.heartbeatLoop()
 
-	dest := s.router.GetDestination()
+	dest := s.GetDestination()
 	showLen := min(32, len(dest))
 	log.Printf("[Messenger] Started. My destination: %s...", dest[:showLen])
 
@@ -142,6 +226,11 @@ func (s *Service) Stop() error {
 		_ = conn.Close()
 		delete(s.connections, dest)
 	}
+	// Входящие тоже: иначе Stop ждал бы, пока их чтение отвалится по таймауту
+	for conn := range s.inbound {
+		_ = conn.Close()
+		delete(s.inbound, conn)
+	}
 	s.connMu.Unlock()
 
 	// Ждём завершения горутин
@@ -151,40 +240,65 @@ func (s *Service) Stop() error {
 	return nil
 }
 
-// GetDestination возвращает наш I2P Destination
+// GetDestination возвращает наш I2P Destination ("" — роутер не готов)
 func (s *Service) GetDestination() string {
-	return s.router.GetDestination()
+	dest, err := s.router.GetAddress()
+	if err != nil {
+		return ""
+	}
+	return dest
+}
+
+// dial открывает исходящее соединение через роутер
+func (s *Service) dial(destination string) (net.Conn, error) {
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	conn, err := s.router.Connect(ctx, destination)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
 }
 
 // SendMessage отправляет сообщение получателю
 func (s *Service) SendMessage(destination string, packet *pb.Packet) error {
-	// Устанавливаем версию и подписываем
-	packet.Version = ProtocolVersion
-	packet.SenderPubKey = []byte(s.identity.PublicKeyBase64)
+	packet, err := s.sealPacket(destination, packet)
+	if err != nil {
+		return err
+	}
+
+	// Сериализуем пакет
+	data, err := proto.Marshal(packet)
+	if err != nil {
+		return fmt.Errorf("marshal failed: %w", err)
+	}
 
-	// Подписываем payload
-	if len(packet.Payload) > 0 {
-		packet.Signature = s.identity.SignMessage(packet.Payload)
+	if len(data) > MaxPacketSize {
+		return fmt.Errorf("packet too large: %d > %d", len(data), MaxPacketSize)
 	}
 
 	// Получаем или создаём соединение
+	s.sending.Add(1)
+	defer s.sending.Add(-1)
 	showDest := destination[:min(16, len(destination))]
 	log.Printf("[Messenger] Getting connection for %s...", showDest)
 	conn, err := s.getOrCreateConnection(destination)
 	if err != nil {
 		log.Printf("[Messenger] ERROR: connection failed for %s: %v", showDest, err)
+		// Собеседник не в сети — оставляем письмо в его почтовом ящике, если он есть
+		if depErr := s.depositToMailbox(destination, packet, data); depErr == nil {
+			return nil
+		} else if depErr != errNoMailbox {
+			log.Printf("[Messenger] ERROR: %v", depErr)
+		}
 		return fmt.Errorf("connection failed: %w", err)
 	}
 
-	// Сериализуем пакет
-	data, err := proto.Marshal(packet)
-	if err != nil {
-		return fmt.Errorf("marshal failed: %w", err)
-	}
-
 	log.Printf("[Messenger] Sending packet type %v (%d bytes) to %s...", packet.Type, len(data), showDest)
 	// Отправляем: 4 байта размер + данные
-	if err := s.writePacket(conn, data); err != nil {
+	if err := s.writePacket(conn, packet, data); err != nil {
 		log.Printf("[Messenger] ERROR: write failed for %s: %v", showDest, err)
 		// При ошибке удаляем соединение из пула
 		s.removeConnection(destination)
@@ -195,6 +309,39 @@ func (s *Service) SendMessage(destination string, packet *pb.Packet) error {
 	return nil
 }
 
+// sealPacket готовит копию пакета для получателя: шифрует, ставит заголовок свежести и подписывает
+func (s *Service) sealPacket(destination string, packet *pb.Packet) (*pb.Packet, error) {
+	// Пакет шифруется под конкретного получателя, поэтому работаем с копией
+	packet = proto.Clone(packet).(*pb.Packet)
+
+	// Версию выбираем под собеседника: старые сборки понимают только свою
+	peer := s.resolvePeer(destination)
+	packet.Version = s.sendVersion(peer)
+	packet.SenderPubKey = []byte(s.identity.PublicKeyBase64)
+
+	// Шифруем payload ключом E2EE-сессии
+	if err := s.encryptPacket(destination, packet); err != nil {
+		return nil, fmt.Errorf("e2e encryption failed: %w", err)
+	}
+	// Рукопожатие внутри encryptPacket могло только что познакомить нас с получателем
+	if peer == "" {
+		peer = s.resolvePeer(destination)
+		packet.Version = s.sendVersion(peer)
+	}
+
+	// Подписываем конверт (payload уже зашифрован) вместе с заголовком свежести
+	if err := stampPacket(packet); err != nil {
+		return nil, err
+	}
+	s.signPacket(peer, packet)
+
+	// Получатель может нас не знать: платим штампом, чтобы пакет не отбросили
+	if s.needsStamp(peer, packet) {
+		s.addStamp(destination, packet)
+	}
+	return packet, nil
+}
+
 // SendTextMessageWithID создаёт и отправляет текстовое сообщение с указанным ID
 func (s *Service) SendTextMessageWithID(destination, chatID, messageID, content, replyToID string) error {
 	now := time.Now().UnixMilli()
@@ -222,13 +369,14 @@ func (s *Service) SendTextMessageWithID(destination, chatID, messageID, content,
 }
 
 // SendFileOffer отправляет предложение передачи файлов
-func (s *Service) SendFileOffer(destination, chatID, messageID string, filenames []string, totalSize int64, fileCount int32) error {
+func (s *Service) SendFileOffer(destination, chatID, messageID string, filenames []string, totalSize int64, fileCount int32, files []*pb.FileManifest) error {
 	offer := &pb.FileOffer{
 		MessageId: messageID,
 		ChatId:    chatID,
 		Filenames: filenames,
 		TotalSize: totalSize,
 		FileCount: fileCount,
+		Files:     files,
 	}
 
 	payload, err := proto.Marshal(offer)
@@ -267,6 +415,80 @@ func (s *Service) SendFileResponse(destination, chatID, messageID string, accept
 	return s.SendMessage(destination, packet)
 }
 
+// SendReceipt отправляет отчёт о доставке или прочтении сообщений
+func (s *Service) SendReceipt(destination, chatID string, messageIDs []string, status core.MessageStatus) error {
+	kind := pb.ReceiptKind_RECEIPT_DELIVERED
+	if status == core.MessageStatusRead {
+		kind = pb.ReceiptKind_RECEIPT_READ
+	}
+
+	receipt := &pb.Receipt{
+		MessageIds: messageIDs,
+		Kind:       kind,
+		ChatId:     chatID,
+		Timestamp:  time.Now().UnixMilli(),
+	}
+
+	payload, err := proto.Marshal(receipt)
+	if err != nil {
+		return fmt.Errorf("marshal receipt failed: %w", err)
+	}
+
+	packet := &pb.Packet{
+		Type:    pb.PacketType_RECEIPT,
+		Payload: payload,
+	}
+
+	log.Printf("[Messenger] Sending %s receipt for %d messages to %s...", status, len(messageIDs), destination[:min(32, len(destination))])
+	return s.SendMessage(destination, packet)
+}
+
+// SendMessageEdit отправляет собеседнику новое содержимое сообщения
+func (s *Service) SendMessageEdit(destination, chatID, messageID, newContent string, editedAt int64) error {
+	edit := &pb.MessageEdit{
+		MessageId:  messageID,
+		NewContent: newContent,
+		Timestamp:  editedAt,
+		ChatId:     chatID,
+	}
+
+	payload, err := proto.Marshal(edit)
+	if err != nil {
+		return fmt.Errorf("marshal message edit failed: %w", err)
+	}
+
+	packet := &pb.Packet{
+		Type:    pb.PacketType_MESSAGE_EDIT,
+		Payload: payload,
+	}
+
+	log.Printf("[Messenger] Sending edit (id=%s) to %s...", messageID[:min(8, len(messageID))], destination[:min(32, len(destination))])
+	return s.SendMessage(destination, packet)
+}
+
+// SendMessageDelete отправляет собеседнику удаление сообщения
+func (s *Service) SendMessageDelete(destination, chatID, messageID string, deleteForAll bool) error {
+	del := &pb.MessageDelete{
+		MessageId:    messageID,
+		Timestamp:    time.Now().UnixMilli(),
+		ChatId:       chatID,
+		DeleteForAll: deleteForAll,
+	}
+
+	payload, err := proto.Marshal(del)
+	if err != nil {
+		return fmt.Errorf("marshal message delete failed: %w", err)
+	}
+
+	packet := &pb.Packet{
+		Type:    pb.PacketType_MESSAGE_DELETE,
+		Payload: payload,
+	}
+
+	log.Printf("[Messenger] Sending delete (id=%s, for_all=%v) to %s...", messageID[:min(8, len(messageID))], deleteForAll, destination[:min(32, len(destination))])
+	return s.SendMessage(destination, packet)
+}
+
 // SendHeartbeat отправляет heartbeat пакет
 func (s *Service) SendHeartbeat(destination string) error {
 	packet := &pb.Packet{
@@ -277,17 +499,38 @@ func (s *Service) SendHeartbeat(destination string) error {
 	return s.SendMessage(destination, packet)
 }
 
-// SendHandshake отправляет handshake пакет для установления контакта
+// SendHandshake отправляет handshake пакет для установления контакта и E2EE-сессии
 func (s *Service) SendHandshake(destination string) error {
+	key, err := s.newEphemeral(destination)
+	if err != nil {
+		return err
+	}
+	return s.sendHandshake(destination, key.PublicKey().Bytes(), nil)
+}
+
+// sendHandshake отправляет handshake с эфемерным ключом.
+// replyTo — эфемерный ключ инициатора, если это ответ.
+func (s *Service) sendHandshake(destination string, ephemeralPub, replyTo []byte) error {
 	now := time.Now().UnixMilli()
 
-	handshake := &pb.Handshake{
-		InitiatorPubKey: []byte(s.identity.PublicKeyBase64),
-		Timestamp:       now,
-		Nickname:        s.myNickname,
-		I2PAddress:      s.router.GetDestination(),
+	nonce := make([]byte, PacketNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("nonce generation failed: %w", err)
 	}
 
+	handshake := &pb.Handshake{
+		InitiatorPubKey:  []byte(s.identity.PublicKeyBase64),
+		EphemeralPubKey:  ephemeralPub,
+		Nonce:            nonce,
+		Timestamp:        now,
+		Nickname:         s.nickname(),
+		I2PAddress:       s.GetDestination(),
+		IsResponse:       len(replyTo) > 0,
+		ReplyToEphemeral: replyTo,
+	}
+	fillHandshakeVersion(handshake)
+	s.fillHandshakeLeaseSet(handshake)
+
 	payload, err := proto.Marshal(handshake)
 	if err != nil {
 		return fmt.Errorf("marshal handshake failed: %w", err)
@@ -316,9 +559,11 @@ func (s *Service) SendProfileRequest(destination string) error {
 // SendProfileUpdate отправляет обновление нашего профиля получателю
 func (s *Service) SendProfileUpdate(destination, nickname, bio string, avatar []byte) error {
 	update := &pb.ProfileUpdate{
-		Nickname: nickname,
-		Bio:      bio,
-		Avatar:   avatar,
+		Nickname:       nickname,
+		Bio:            bio,
+		Avatar:         avatar,
+		MailboxAddress: s.MailboxAddress(),
+		BlindedAddress: s.BlindedAddress(),
 	}
 
 	payload, err := proto.Marshal(update)
@@ -347,7 +592,7 @@ func (s *Service) getOrCreateConnection(destination string) (net.Conn, error) {
 
 	// Dial с таймаутом БЕЗ блокировки всего пула
 	log.Printf("[Messenger] Dialing %s...", destination[:min(16, len(destination))])
-	newConn, err := s.router.Dial(destination)
+	newConn, err := s.dial(destination)
 	if err != nil {
 		return nil, err
 	}
@@ -379,72 +624,48 @@ func (s *Service) removeConnection(destination string) {
 	}
 }
 
-// writePacket пишет пакет в соединение (length-prefixed)
-func (s *Service) writePacket(conn net.Conn, data []byte) error {
-	// Устанавливаем deadline
-	_ = conn.SetWriteDeadline(time.Now().Add(ConnectionTimeout))
-
-	// Пишем размер (4 байта, big endian)
-	const maxUint32 = math.MaxUint32
-	if uint64(len(data)) > maxUint32 {
-		return fmt.Errorf("packet too large for uint32: %d", len(data))
-	}
-	if len(data) > 100*1024*1024 { // 100 MB limit for safety
-		return fmt.Errorf("packet too large: %d", len(data))
-	}
-	sizeBuf := make([]byte, 4)
-	// #nosec G115
-	binary.BigEndian.PutUint32(sizeBuf, uint32(len(data)))
-
-	if _, err := conn.Write(sizeBuf); err != nil {
-		return err
-	}
-
-	// Пишем данные
-	if _, err := conn.Write(data); err != nil {
-		return err
+// DrainConnections закрывает исходящие соединения, дав начатым отправкам дописать пакеты
+// (не дольше timeout). Следующие отправки откроют соединения заново.
+func (s *Service) DrainConnections(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for s.sending.Load() > 0 {
+		if time.Now().After(deadline) {
+			log.Printf("[Messenger] Drain timed out with %d sends in flight", s.sending.Load())
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
 	}
-
-	return nil
+	s.ResetConnections()
 }
 
-// readPacket читает пакет из соединения
-func (s *Service) readPacket(conn net.Conn) ([]byte, error) {
-	// Устанавливаем deadline
-	_ = conn.SetReadDeadline(time.Now().Add(ReadTimeout))
-
-	// Читаем размер
-	sizeBuf := make([]byte, 4)
-	if _, err := io.ReadFull(conn, sizeBuf); err != nil {
-		return nil, err
-	}
+// ResetConnections закрывает исходящие соединения: после пересоздания сессии роутера
+// они мертвы, а следующая отправка откроет новые
+func (s *Service) ResetConnections() {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
 
-	size := binary.BigEndian.Uint32(sizeBuf)
-	if size > MaxPacketSize {
-		return nil, fmt.Errorf("packet too large: %d", size)
+	for dest, conn := range s.connections {
+		_ = conn.Close()
+		delete(s.connections, dest)
 	}
+}
 
-	// Читаем данные
-	data := make([]byte, size)
-	if _, err := io.ReadFull(conn, data); err != nil {
-		return nil, err
+// writePacket пишет пакет в соединение (length-prefixed). Тип пакета ставим в заголовок кадра,
+// если получатель его понимает: по нему он проверит предел размера до чтения.
+func (s *Service) writePacket(conn net.Conn, packet *pb.Packet, data []byte) error {
+	if s.tagsFrames(packet) {
+		return wire.WriteTaggedFrame(conn, uint16(packet.Type), data, ConnectionTimeout) // #nosec G115 -- типов пакетов немного
 	}
-
-	return data, nil
+	return wire.WriteFrame(conn, data, ConnectionTimeout)
 }
 
 // listenLoop принимает входящие соединения
 func (s *Service) listenLoop() {
 	defer s.wg.Done()
 
-	listener, err := s.router.Listen()
-	if err != nil {
-		log.Printf("[Messenger] Failed to get listener: %v", err)
-		return
-	}
-
 	log.Printf("[Messenger] Listening for incoming connections...")
 
+	delay := acceptRetryDelay
 	for {
 		select {
 		case <-s.ctx.Done():
@@ -452,46 +673,85 @@ func (s *Service) listenLoop() {
 		default:
 		}
 
-		// SAM StreamListener не поддерживает deadline напрямую,
-		// используем Accept() в цикле с проверкой context
-
-		conn, err := listener.Accept()
+		conn, err := s.router.Accept(s.ctx)
 		if err != nil {
-			// Если роутер остановлен, выходим
-			if !s.router.IsReady() {
+			if s.ctx.Err() != nil {
 				return
 			}
-
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 				continue
 			}
-			if s.ctx.Err() != nil {
-				return
+
+			// Роутер переподключается (или его сессия только что упала): приём продолжится,
+			// когда он вернётся. Выходим только по Stop.
+			if s.router.IsReady() {
+				log.Printf("[Messenger] Accept error: %v", err)
+			} else if delay == acceptRetryDelay {
+				log.Printf("[Messenger] Router is not ready, waiting to accept connections...")
 			}
-			// Проверяем на ошибку закрытия (строковое сравнение для надежности с разными реализациями)
-			if err.Error() == "use of closed network connection" || err.Error() == "listener closed" {
+			select {
+			case <-s.ctx.Done():
 				return
+			case <-time.After(delay):
+			}
+			if delay *= 2; delay > maxAcceptRetryDelay {
+				delay = maxAcceptRetryDelay
 			}
+			continue
+		}
+		delay = acceptRetryDelay
 
-			log.Printf("[Messenger] Accept error: %v", err)
+		remoteAddr := conn.RemoteDestination()
+		if !s.admitConnection(remoteAddr) {
+			_ = conn.Close()
 			continue
 		}
 
 		// Обрабатываем соединение в отдельной горутине
 		s.wg.Add(1)
-		go s.handleConnection(conn)
+		go s.handleConnection(conn, remoteAddr)
 	}
 }
 
-// handleConnection обрабатывает входящее соединение
-func (s *Service) handleConnection(conn net.Conn) {
+// admitConnection решает, принимать ли входящее соединение: заблокированные, временно
+// отключённые и лишние соединения закрываются сразу, до чтения первого пакета
+func (s *Service) admitConnection(remoteAddr string) bool {
+	short := remoteAddr[:min(32, len(remoteAddr))]
+	switch {
+	case s.IsBlocked("", remoteAddr):
+		s.stats.rejectedBlocked.Add(1)
+		log.Printf("[Messenger] Closed connection from blocked %s...", short)
+		return false
+	case s.limiter.banned(remoteAddr):
+		s.stats.rejectedConnection.Add(1)
+		log.Printf("[Messenger] Closed connection from temporarily banned %s...", short)
+		return false
+	case !s.limiter.acquireConn(remoteAddr):
+		s.stats.rejectedConnection.Add(1)
+		log.Printf("[Messenger] Too many connections, closed %s...", short)
+		return false
+	}
+	return true
+}
+
+// handleConnection обрабатывает входящее соединение (место под него уже занято в admitConnection)
+func (s *Service) handleConnection(conn net.Conn, remoteAddr string) {
 	defer s.wg.Done()
 	defer conn.Close()
+	defer s.limiter.releaseConn(remoteAddr)
 
-	remoteAddr := conn.RemoteAddr().String()
-	if i2pAddr, ok := conn.RemoteAddr().(interface{ Base64() string }); ok {
-		remoteAddr = i2pAddr.Base64()
+	s.connMu.Lock()
+	s.inbound[conn] = true
+	s.connMu.Unlock()
+	defer func() {
+		s.connMu.Lock()
+		delete(s.inbound, conn)
+		s.connMu.Unlock()
+	}()
+	if s.ctx.Err() != nil {
+		return
 	}
+
 	log.Printf("[Messenger] Incoming connection from %s...", remoteAddr[:min(32, len(remoteAddr))])
 
 	for {
@@ -501,44 +761,105 @@ func (s *Service) handleConnection(conn net.Conn) {
 		default:
 		}
 
-		// Читаем пакет
-		data, err := s.readPacket(conn)
-		if err != nil {
+		if err := s.receivePacket(conn, remoteAddr); err != nil {
 			if err == io.EOF || s.ctx.Err() != nil {
 				return
 			}
 			log.Printf("[Messenger] Read error: %v", err)
 			return
 		}
+	}
+}
 
-		// Десериализуем
-		packet := &pb.Packet{}
-		if err := proto.Unmarshal(data, packet); err != nil {
-			log.Printf("[Messenger] Unmarshal error: %v", err)
-			continue
+// receivePacket читает и обрабатывает один пакет в пределах Limits.
+// Ошибка означает, что соединение нужно закрыть.
+func (s *Service) receivePacket(conn net.Conn, remoteAddr string) error {
+	header, err := wire.ReadFrameHeader(conn, MaxPacketSize, ReadTimeout)
+	if err != nil {
+		if errors.Is(err, wire.ErrFrameTooLarge) {
+			s.penalize(remoteAddr, &s.stats.rejectedOversize, err)
 		}
+		return err
+	}
+	if !s.limiter.allowPacket(remoteAddr) {
+		s.penalize(remoteAddr, &s.stats.rejectedRate, errRateLimited)
+		return errRateLimited
+	}
+
+	// Предел по типу из заголовка проверяем до чтения данных
+	limits := s.limiter.get()
+	limit := limits.MaxUntaggedSize
+	if header.Tagged {
+		limit = limits.maxSize(pb.PacketType(header.Tag))
+	} else if s.isLegacyFramer(remoteAddr) {
+		limit = MaxPacketSize
+	}
+	if int64(header.Size) > int64(limit) {
+		err := fmt.Errorf("%w: frame of %d bytes (tag %d, limit %d)", wire.ErrFrameTooLarge, header.Size, header.Tag, limit)
+		s.penalize(remoteAddr, &s.stats.rejectedOversize, err)
+		return err
+	}
 
-		// Обрабатываем пакет
-		s.handlePacket(packet, remoteAddr)
+	// Память под пакет занимаем до чтения; если адрес или все вместе читают слишком много, отключаем без бана
+	if !s.limiter.reserve(remoteAddr, int64(header.Size)) {
+		s.stats.rejectedInFlight.Add(1)
+		return errInFlight
+	}
+	defer s.limiter.release(remoteAddr, int64(header.Size))
+
+	data, err := wire.ReadFrameData(conn, header.Size)
+	if err != nil {
+		return err
 	}
+
+	// Десериализуем
+	packet := &pb.Packet{}
+	if err := proto.Unmarshal(data, packet); err != nil {
+		log.Printf("[Messenger] Unmarshal error: %v", err)
+		return nil
+	}
+	// Тип в заголовке должен совпадать с пакетом, иначе предел обходится подменой метки
+	if header.Tagged && packet.Type != pb.PacketType(header.Tag) {
+		err := fmt.Errorf("%w: %v packet in frame tagged %d", errFrameTag, packet.Type, header.Tag)
+		s.penalize(remoteAddr, &s.stats.rejectedOversize, err)
+		return err
+	}
+	if limit := limits.maxSize(packet.Type); len(data) > limit {
+		err := fmt.Errorf("%w: %v packet of %d bytes (limit %d)", wire.ErrFrameTooLarge, packet.Type, len(data), limit)
+		s.penalize(remoteAddr, &s.stats.rejectedOversize, err)
+		return err
+	}
+
+	// За заголовком потока следуют кадры тела — читаем их здесь же
+	if packet.Type == pb.PacketType_FILE_STREAM {
+		return s.receiveFileStream(conn, packet, remoteAddr)
+	}
+
+	// Обрабатываем пакет; с заблокированным отправителем соединение не держим
+	s.handlePacket(packet, remoteAddr, false)
+	if s.IsBlocked(string(packet.SenderPubKey), "") {
+		return errBlockedPeer
+	}
+	return nil
 }
 
-// handlePacket обрабатывает входящий пакет
-func (s *Service) handlePacket(packet *pb.Packet, remoteAddr string) {
-	senderPubKey := string(packet.SenderPubKey)
+// penalize учитывает нарушение пределов и временно отключает адрес
+func (s *Service) penalize(remoteAddr string, counter *atomic.Uint64, reason error) {
+	counter.Add(1)
+	s.limiter.ban(remoteAddr)
+	log.Printf("[Messenger] Disconnecting %s... for %v: %v", remoteAddr[:min(32, len(remoteAddr))], s.limiter.get().BanDuration, reason)
+}
 
-	// Проверяем подпись (если есть payload)
-	if len(packet.Payload) > 0 && len(packet.Signature) > 0 {
-		valid, err := identity.VerifySignatureBase64(senderPubKey, packet.Payload, packet.Signature)
-		if err != nil || !valid {
-			log.Printf("[Messenger] Invalid signature from %s...", senderPubKey[:16])
-			return
-		}
+// handlePacket обрабатывает входящий пакет; stored — пакет забран из почтового ящика
+func (s *Service) handlePacket(packet *pb.Packet, remoteAddr string, stored bool) {
+	senderPubKey, ok := s.openPacket(packet, remoteAddr, stored)
+	if !ok {
+		return
 	}
 
 	switch packet.Type {
 	case pb.PacketType_HEARTBEAT:
-		log.Printf("[Messenger] Heartbeat from %s...", senderPubKey[:16])
+		// LastSeen обновляется обработчиком активности выше
 
 	case pb.PacketType_TEXT_MESSAGE:
 		s.handleTextMessage(packet, senderPubKey, remoteAddr)
@@ -547,7 +868,7 @@ func (s *Service) handlePacket(packet *pb.Packet, remoteAddr string) {
 		s.handleProfileUpdate(packet, senderPubKey, remoteAddr)
 
 	case pb.PacketType_HANDSHAKE:
-		s.handleHandshake(packet, senderPubKey)
+		s.handleHandshake(packet, senderPubKey, remoteAddr)
 
 	case pb.PacketType_PROFILE_REQUEST:
 		s.handleProfileRequest(packet, senderPubKey)
@@ -558,11 +879,123 @@ func (s *Service) handlePacket(packet *pb.Packet, remoteAddr string) {
 	case pb.PacketType_FILE_RESPONSE:
 		s.handleFileResponse(packet, senderPubKey)
 
+	case pb.PacketType_RECEIPT:
+		s.handleReceipt(packet, senderPubKey)
+
+	case pb.PacketType_MESSAGE_EDIT:
+		s.handleMessageEdit(packet, senderPubKey)
+
+	case pb.PacketType_MESSAGE_DELETE:
+		s.handleMessageDelete(packet, senderPubKey)
+
+	case pb.PacketType_FILE_CHUNK:
+		s.handleFileChunk(packet, senderPubKey)
+
+	case pb.PacketType_FILE_CHUNK_ACK:
+		s.handleFileChunkAck(packet, senderPubKey)
+
+	case pb.PacketType_FILE_RESUME:
+		s.handleFileResume(packet, senderPubKey)
+
+	case pb.PacketType_FILE_STREAM:
+		// Тело потока идёт следом в том же соединении (receiveFileStream); отдельно заголовок бесполезен
+		log.Printf("[Messenger] Ignoring file stream header outside of a connection")
+
+	case pb.PacketType_TYPING:
+		s.handleTyping(packet, senderPubKey)
+
+	case pb.PacketType_PRESENCE:
+		s.handlePresence(packet, senderPubKey)
+
+	case pb.PacketType_GROUP_CONTROL:
+		s.handleGroupControl(packet, senderPubKey, remoteAddr)
+
+	case pb.PacketType_CHANNEL_POST:
+		s.handleChannelPost(packet, senderPubKey)
+
+	case pb.PacketType_CHANNEL_CONTROL:
+		s.handleChannelControl(packet, senderPubKey, remoteAddr)
+
 	default:
 		log.Printf("[Messenger] Unknown packet type: %v", packet.Type)
 	}
 }
 
+// openPacket проверяет входящий пакет (блокировка, подпись, штамп, свежесть) и расшифровывает payload.
+// false — пакет отброшен.
+func (s *Service) openPacket(packet *pb.Packet, remoteAddr string, stored bool) (string, bool) {
+	senderPubKey := string(packet.SenderPubKey)
+	s.stats.received.Add(1)
+
+	// Заблокированные отбрасываются до проверок и любых обработчиков. Ключ ещё не проверен,
+	// но чужой ключ в пакете только лишает отправителя доставки.
+	if s.IsBlocked(senderPubKey, remoteAddr) {
+		s.stats.rejectedBlocked.Add(1)
+		log.Printf("[Messenger] Dropped %v packet from blocked %s...", packet.Type, senderPubKey[:min(16, len(senderPubKey))])
+		return "", false
+	}
+
+	// Проверяем подпись конверта и адресата
+	if err := s.verifyPacket(packet, senderPubKey); err != nil {
+		if err == errWrongRecipient || err == errNoRecipient {
+			s.stats.rejectedRecipient.Add(1)
+		} else {
+			s.stats.rejectedSignature.Add(1)
+		}
+		log.Printf("[Messenger] Rejected %v packet from %s...: %v", packet.Type, senderPubKey[:min(16, len(senderPubKey))], err)
+		return "", false
+	}
+
+	// Незнакомцы платят штампом; без него пакет отбрасывается до любой записи в базу
+	if !s.stampAccepted(packet, senderPubKey, remoteAddr) {
+		s.stats.rejectedStamp.Add(1)
+		log.Printf("[Messenger] Rejected %v packet from %s...: %v", packet.Type, senderPubKey[:min(16, len(senderPubKey))], errNoStamp)
+		return "", false
+	}
+
+	// Отклоняем устаревшие и повторно присланные пакеты
+	var legacy bool
+	var err error
+	if stored {
+		err = s.replay.checkStored(s.ctx, packet, senderPubKey, mailbox.MaxTTL)
+	} else {
+		legacy, err = s.replay.check(s.ctx, packet, senderPubKey)
+	}
+	if err != nil {
+		switch err {
+		case errStalePacket:
+			s.stats.rejectedStale.Add(1)
+		case errReplayPacket:
+			s.stats.rejectedReplay.Add(1)
+		case errDowngrade:
+			s.stats.rejectedDowngrade.Add(1)
+		default:
+			s.stats.rejectedStale.Add(1)
+		}
+		log.Printf("[Messenger] Rejected %v packet from %s...: %v", packet.Type, senderPubKey[:min(16, len(senderPubKey))], err)
+		return "", false
+	}
+	if legacy {
+		s.stats.legacy.Add(1)
+	}
+	s.observePeerVersion(senderPubKey, packet.Version)
+
+	// Расшифровываем payload E2EE-сессии
+	sealed := len(packet.SealedEphemeral) > 0
+	if err := s.decryptPacket(packet, senderPubKey, remoteAddr); err != nil {
+		s.stats.rejectedDecrypt.Add(1)
+		log.Printf("[Messenger] Rejected %v packet from %s...: %v", packet.Type, senderPubKey[:min(16, len(senderPubKey))], err)
+		return "", false
+	}
+
+	// Собеседник в сети — можно дослать ожидающие сообщения.
+	// Запечатанное сообщение незнакомца ответа не предполагает: досылка выдала бы, что мы в сети.
+	if s.activityHandler != nil && senderPubKey != "" && !stored && !sealed {
+		s.activityHandler(senderPubKey, remoteAddr)
+	}
+	return senderPubKey, true
+}
+
 // handleProfileRequest обрабатывает запрос на обновление профиля
 func (s *Service) handleProfileRequest(_ *pb.Packet, senderPubKey string) {
 	log.Printf("[Messenger] Profile request from %s", senderPubKey[:min(16, len(senderPubKey))])
@@ -637,40 +1070,11 @@ func (s *Service) SendAttachmentMessageWithID(destination, chatID, messageID, co
 	}
 
 	packet := &pb.Packet{
-		Version:      ProtocolVersion,
-		Type:         pb.PacketType_TEXT_MESSAGE,
-		SenderPubKey: []byte(s.identity.PublicKeyBase64),
-		Payload:      payload,
-	}
-
-	// Подписываем payload
-	if len(packet.Payload) > 0 {
-		packet.Signature = s.identity.SignMessage(packet.Payload)
-	}
-
-	// Получаем или создаём соединение
-	conn, err := s.getOrCreateConnection(destination)
-	if err != nil {
-		return fmt.Errorf("connection failed: %w", err)
-	}
-
-	// Сериализуем пакет
-	data, err := proto.Marshal(packet)
-	if err != nil {
-		return fmt.Errorf("marshal packet failed: %w", err)
-	}
-
-	if len(data) > MaxPacketSize {
-		return fmt.Errorf("packet too large: %d > %d", len(data), MaxPacketSize)
-	}
-
-	// Отправляем
-	if err := s.writePacket(conn, data); err != nil {
-		s.removeConnection(destination)
-		return fmt.Errorf("send failed: %w", err)
+		Type:    pb.PacketType_TEXT_MESSAGE,
+		Payload: payload,
 	}
 
-	return nil
+	return s.SendMessage(destination, packet)
 }
 
 // handleTextMessage обрабатывает текстовое сообщение
@@ -682,8 +1086,8 @@ func (s *Service) handleTextMessage(packet *pb.Packet, senderPubKey, remoteAddr
 	}
 
 	// Message Length Limit Check
-	if len(textMsg.Content) > 4096 {
-		log.Printf("[Messenger] Rejected message from %s: content too long (%d > 4096)", senderPubKey[:min(16, len(senderPubKey))], len(textMsg.Content))
+	if len(textMsg.Content) > MaxTextLength {
+		log.Printf("[Messenger] Rejected message from %s: content too long (%d > %d)", senderPubKey[:min(16, len(senderPubKey))], len(textMsg.Content), MaxTextLength)
 		return
 	}
 
@@ -704,6 +1108,10 @@ func (s *Service) handleTextMessage(packet *pb.Packet, senderPubKey, remoteAddr
 	if textMsg.ReplyToId != "" {
 		msg.ReplyToID = &textMsg.ReplyToId
 	}
+	if textMsg.GroupId != "" {
+		msg.GroupID = textMsg.GroupId
+		msg.GroupEpoch = textMsg.GroupEpoch
+	}
 
 	// Обрабатываем вложения
 	if len(textMsg.Attachments) > 0 {
@@ -753,25 +1161,49 @@ func (s *Service) handleProfileUpdate(packet *pb.Packet, senderPubKey, senderAdd
 
 	log.Printf("[Messenger] Profile update from %s: %s", senderPubKey[:min(16, len(senderPubKey))], profileUpdate.Nickname)
 	if s.profileHandler != nil {
-		s.profileHandler(senderPubKey, profileUpdate.Nickname, profileUpdate.Bio, profileUpdate.Avatar, senderAddr)
+		s.profileHandler(senderPubKey, profileUpdate.Nickname, profileUpdate.Bio, profileUpdate.Avatar, profileUpdate.MailboxAddress, senderAddr)
 	}
+	s.notifyLeaseSet(senderPubKey, senderAddr, nil, profileUpdate.BlindedAddress)
 }
 
 // handleHandshake обрабатывает рукопожатие
-func (s *Service) handleHandshake(packet *pb.Packet, senderPubKey string) {
+func (s *Service) handleHandshake(packet *pb.Packet, senderPubKey, remoteAddr string) {
 	handshake := &pb.Handshake{}
 	if err := proto.Unmarshal(packet.Payload, handshake); err != nil {
 		log.Printf("[Messenger] Failed to unmarshal Handshake: %v", err)
 		return
 	}
 
+	if len(handshake.InitiatorPubKey) > 0 && string(handshake.InitiatorPubKey) != senderPubKey {
+		log.Printf("[Messenger] Handshake key mismatch from %s", senderPubKey[:min(16, len(senderPubKey))])
+		return
+	}
+
+	// Старые рукопожатия не должны пересоздавать сессию
+	if handshake.Timestamp != 0 && !s.replay.fresh(handshake.Timestamp) {
+		s.stats.rejectedStale.Add(1)
+		log.Printf("[Messenger] Stale handshake from %s", senderPubKey[:min(16, len(senderPubKey))])
+		return
+	}
+
+	if !s.negotiatePeer(handshake, senderPubKey) {
+		return
+	}
+
+	// Адрес берём у соединения: заявленный в handshake мог принадлежать другому собеседнику
+	i2pAddress := handshakeDestination(handshake.I2PAddress, remoteAddr)
+
+	// b33 адрес нужен до ответа: без него не найти зашифрованный leaseSet инициатора
+	s.notifyLeaseSet(senderPubKey, i2pAddress, handshake.LeaseSetAuthKey, handshake.BlindedAddress)
+
+	// Устанавливаем E2EE-сессию (и отвечаем, если это входящее рукопожатие)
+	s.handleSessionHandshake(handshake, senderPubKey, remoteAddr)
+
 	nickname := handshake.Nickname
 	if nickname == "" {
 		nickname = "Unknown"
 	}
 
-	i2pAddress := handshake.I2PAddress
-
 	log.Printf("[Messenger] Handshake from %s (nickname: %s)", senderPubKey[:min(16, len(senderPubKey))], nickname)
 
 	// Вызываем callback для создания контакта
@@ -790,7 +1222,7 @@ func (s *Service) handleFileOffer(packet *pb.Packet, senderPubKey string) {
 
 	log.Printf("[Messenger] File offer from %s: %d files", senderPubKey[:min(16, len(senderPubKey))], offer.FileCount)
 	if s.fileOfferHandler != nil {
-		s.fileOfferHandler(senderPubKey, offer.MessageId, offer.ChatId, offer.Filenames, offer.TotalSize, offer.FileCount)
+		s.fileOfferHandler(senderPubKey, offer.MessageId, offer.ChatId, offer.Filenames, offer.TotalSize, offer.FileCount, offer.Files)
 	}
 }
 
@@ -808,6 +1240,64 @@ func (s *Service) handleFileResponse(packet *pb.Packet, senderPubKey string) {
 	}
 }
 
+// handleReceipt обрабатывает отчёт о доставке/прочтении
+func (s *Service) handleReceipt(packet *pb.Packet, senderPubKey string) {
+	receipt := &pb.Receipt{}
+	if err := proto.Unmarshal(packet.Payload, receipt); err != nil {
+		log.Printf("[Messenger] Failed to unmarshal Receipt: %v", err)
+		return
+	}
+
+	var status core.MessageStatus
+	switch receipt.Kind {
+	case pb.ReceiptKind_RECEIPT_DELIVERED:
+		status = core.MessageStatusDelivered
+	case pb.ReceiptKind_RECEIPT_READ:
+		status = core.MessageStatusRead
+	default:
+		log.Printf("[Messenger] Unknown receipt kind: %v", receipt.Kind)
+		return
+	}
+
+	log.Printf("[Messenger] %s receipt from %s for %d messages", status, senderPubKey[:min(16, len(senderPubKey))], len(receipt.MessageIds))
+	if s.receiptHandler != nil {
+		s.receiptHandler(senderPubKey, receipt.ChatId, receipt.MessageIds, status)
+	}
+}
+
+// handleMessageEdit обрабатывает редактирование сообщения.
+// Проверка авторства выполняется в обработчике: здесь известен только отправитель пакета.
+func (s *Service) handleMessageEdit(packet *pb.Packet, senderPubKey string) {
+	edit := &pb.MessageEdit{}
+	if err := proto.Unmarshal(packet.Payload, edit); err != nil {
+		log.Printf("[Messenger] Failed to unmarshal MessageEdit: %v", err)
+		return
+	}
+	if len(edit.NewContent) > MaxTextLength {
+		log.Printf("[Messenger] Rejected edit from %s: content too long (%d > %d)", senderPubKey[:min(16, len(senderPubKey))], len(edit.NewContent), MaxTextLength)
+		return
+	}
+
+	log.Printf("[Messenger] Edit from %s for message %s", senderPubKey[:min(16, len(senderPubKey))], edit.MessageId[:min(8, len(edit.MessageId))])
+	if s.editHandler != nil {
+		s.editHandler(senderPubKey, edit.ChatId, edit.MessageId, edit.NewContent, edit.Timestamp)
+	}
+}
+
+// handleMessageDelete обрабатывает удаление сообщения
+func (s *Service) handleMessageDelete(packet *pb.Packet, senderPubKey string) {
+	del := &pb.MessageDelete{}
+	if err := proto.Unmarshal(packet.Payload, del); err != nil {
+		log.Printf("[Messenger] Failed to unmarshal MessageDelete: %v", err)
+		return
+	}
+
+	log.Printf("[Messenger] Delete from %s for message %s (for_all=%v)", senderPubKey[:min(16, len(senderPubKey))], del.MessageId[:min(8, len(del.MessageId))], del.DeleteForAll)
+	if s.deleteHandler != nil {
+		s.deleteHandler(senderPubKey, del.ChatId, del.MessageId, del.DeleteForAll)
+	}
+}
+
 // heartbeatLoop отправляет heartbeat всем активным соединениям
 func (s *Service) heartbeatLoop() {
 	defer s.wg.Done()
@@ -868,6 +1358,26 @@ func (s *Service) SetFileResponseHandler(h FileResponseHandler) {
 	s.fileResponseHandler = h
 }
 
+// SetReceiptHandler sets the receipt handler
+func (s *Service) SetReceiptHandler(h ReceiptHandler) {
+	s.receiptHandler = h
+}
+
+// SetMessageEditHandler sets the message edit handler
+func (s *Service) SetMessageEditHandler(h MessageEditHandler) {
+	s.editHandler = h
+}
+
+// SetMessageDeleteHandler sets the message delete handler
+func (s *Service) SetMessageDeleteHandler(h MessageDeleteHandler) {
+	s.deleteHandler = h
+}
+
+// SetPeerActivityHandler sets the peer activity handler
+func (s *Service) SetPeerActivityHandler(h PeerActivityHandler) {
+	s.activityHandler = h
+}
+
 // Broadcast sends a packet to all connected peers
 func (s *Service) Broadcast(packet *pb.Packet) {
 	s.connMu.RLock()
@@ -877,9 +1387,35 @@ func (s *Service) Broadcast(packet *pb.Packet) {
 	}
 	s.connMu.RUnlock()
 
+	s.BroadcastTo(destinations, packet)
+}
+
+// BroadcastTo рассылает пакет по списку адресов, открывая недостающие соединения.
+// Возвращает адреса, на которые отправить не удалось.
+func (s *Service) BroadcastTo(destinations []string, packet *pb.Packet) []string {
+	var (
+		mu     sync.Mutex
+		failed []string
+		wg     sync.WaitGroup
+	)
+	sem := make(chan struct{}, broadcastWorkers)
 	for _, dest := range destinations {
-		_ = s.SendMessage(dest, packet)
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(dest string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := s.SendMessage(dest, packet); err != nil {
+				mu.Lock()
+				failed = append(failed, dest)
+				mu.Unlock()
+			}
+		}(dest)
 	}
+	wg.Wait()
+	return failed
 }
 
 // SetProfileRequestHandler sets the profile request handler
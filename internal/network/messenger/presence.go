@@ -0,0 +1,96 @@
+package messenger
+
+import (
+	"fmt"
+	"log"
+
+	pb "teleghost/internal/proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// TypingHandler обработчик индикатора набора текста
+type TypingHandler func(senderPubKey, chatID string, typing bool)
+
+// PresenceHandler обработчик статуса собеседника; hidden — собеседник скрывает от нас свой статус
+type PresenceHandler func(senderPubKey string, online, hidden bool)
+
+// SendTyping сообщает собеседнику, что мы набираем (или перестали набирать) текст
+func (s *Service) SendTyping(destination, chatID string, typing bool) error {
+	payload, err := proto.Marshal(&pb.Typing{ChatId: chatID, Typing: typing})
+	if err != nil {
+		return fmt.Errorf("marshal typing failed: %w", err)
+	}
+
+	return s.SendMessage(destination, &pb.Packet{
+		Type:    pb.PacketType_TYPING,
+		Payload: payload,
+	})
+}
+
+// SendPresence отправляет собеседнику наш статус
+func (s *Service) SendPresence(destination string, online, hidden bool) error {
+	payload, err := proto.Marshal(&pb.Presence{Online: online, Hidden: hidden})
+	if err != nil {
+		return fmt.Errorf("marshal presence failed: %w", err)
+	}
+
+	return s.SendMessage(destination, &pb.Packet{
+		Type:    pb.PacketType_PRESENCE,
+		Payload: payload,
+	})
+}
+
+// BroadcastPresence отправляет статус всем подключенным собеседникам.
+// hidden решает по ключу собеседника, скрываем ли от него статус.
+func (s *Service) BroadcastPresence(online bool, hidden func(peerPubKey string) bool) {
+	s.connMu.RLock()
+	destinations := make([]string, 0, len(s.connections))
+	for dest := range s.connections {
+		destinations = append(destinations, dest)
+	}
+	s.connMu.RUnlock()
+
+	for _, dest := range destinations {
+		hide := hidden != nil && hidden(s.resolvePeer(dest))
+		if err := s.SendPresence(dest, online && !hide, hide); err != nil {
+			log.Printf("[Messenger] Presence failed for %s...: %v", dest[:min(32, len(dest))], err)
+		}
+	}
+}
+
+// handleTyping обрабатывает индикатор набора текста
+func (s *Service) handleTyping(packet *pb.Packet, senderPubKey string) {
+	typing := &pb.Typing{}
+	if err := proto.Unmarshal(packet.Payload, typing); err != nil {
+		log.Printf("[Messenger] Failed to unmarshal Typing: %v", err)
+		return
+	}
+
+	if s.typingHandler != nil {
+		s.typingHandler(senderPubKey, typing.ChatId, typing.Typing)
+	}
+}
+
+// handlePresence обрабатывает статус собеседника
+func (s *Service) handlePresence(packet *pb.Packet, senderPubKey string) {
+	presence := &pb.Presence{}
+	if err := proto.Unmarshal(packet.Payload, presence); err != nil {
+		log.Printf("[Messenger] Failed to unmarshal Presence: %v", err)
+		return
+	}
+
+	if s.presenceHandler != nil {
+		s.presenceHandler(senderPubKey, presence.Online, presence.Hidden)
+	}
+}
+
+// SetTypingHandler sets the typing handler
+func (s *Service) SetTypingHandler(h TypingHandler) {
+	s.typingHandler = h
+}
+
+// SetPresenceHandler sets the presence handler
+func (s *Service) SetPresenceHandler(h PresenceHandler) {
+	s.presenceHandler = h
+}
@@ -0,0 +1,308 @@
+package messenger
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "teleghost/internal/proto"
+)
+
+const (
+	// DefaultClockSkew — допустимое расхождение часов отправителя и получателя
+	DefaultClockSkew = 10 * time.Minute
+
+	// PacketNonceSize — размер nonce в заголовке пакета
+	PacketNonceSize = 16
+
+	// replayPruneInterval — как часто чистим устаревшие nonce
+	replayPruneInterval = 10 * time.Minute
+
+	// maxStrangerVersions — сколько версий незнакомых отправителей держим в памяти;
+	// версии контактов хранятся в БД и загружаются при старте
+	maxStrangerVersions = 4096
+)
+
+// ReplayStore хранит уже принятые nonce отправителей
+type ReplayStore interface {
+	// RememberNonce запоминает nonce; false — такой nonce от отправителя уже был
+	RememberNonce(ctx context.Context, senderPubKey string, nonce []byte, timestamp int64) (bool, error)
+	// PruneNonces удаляет nonce с временем раньше before (unix ms)
+	PruneNonces(ctx context.Context, before int64) error
+	// SavePacketVersion поднимает сохранённую версию пакетов отправителя; false — отправитель не контакт
+	SavePacketVersion(ctx context.Context, senderPubKey string, version uint32) (bool, error)
+	// PacketVersions возвращает сохранённые версии пакетов контактов
+	PacketVersions(ctx context.Context) (map[string]uint32, error)
+}
+
+// Diagnostics — счётчики принятых и отклонённых пакетов
+type Diagnostics struct {
+	PacketsReceived   uint64 `json:"packetsReceived"`
+	RejectedSignature uint64 `json:"rejectedSignature"`
+	RejectedStale     uint64 `json:"rejectedStale"`
+	RejectedReplay    uint64 `json:"rejectedReplay"`
+	RejectedDowngrade uint64 `json:"rejectedDowngrade"`
+	RejectedDecrypt   uint64 `json:"rejectedDecrypt"`
+	RejectedRecipient uint64 `json:"rejectedRecipient"`
+	RejectedStamp     uint64 `json:"rejectedStamp"`
+	RejectedBlocked   uint64 `json:"rejectedBlocked"`
+	// Пределы входящего трафика (Limits)
+	RejectedRate       uint64 `json:"rejectedRate"`
+	RejectedOversize   uint64 `json:"rejectedOversize"`
+	RejectedConnection uint64 `json:"rejectedConnection"`
+	RejectedInFlight   uint64 `json:"rejectedInFlight"`
+	LegacyUnprotected  uint64 `json:"legacyUnprotected"`
+}
+
+type packetStats struct {
+	received           atomic.Uint64
+	rejectedSignature  atomic.Uint64
+	rejectedStale      atomic.Uint64
+	rejectedReplay     atomic.Uint64
+	rejectedDowngrade  atomic.Uint64
+	rejectedDecrypt    atomic.Uint64
+	rejectedRecipient  atomic.Uint64
+	rejectedStamp      atomic.Uint64
+	rejectedBlocked    atomic.Uint64
+	rejectedRate       atomic.Uint64
+	rejectedOversize   atomic.Uint64
+	rejectedConnection atomic.Uint64
+	rejectedInFlight   atomic.Uint64
+	legacy             atomic.Uint64
+}
+
+func (p *packetStats) snapshot() Diagnostics {
+	return Diagnostics{
+		PacketsReceived:    p.received.Load(),
+		RejectedSignature:  p.rejectedSignature.Load(),
+		RejectedStale:      p.rejectedStale.Load(),
+		RejectedReplay:     p.rejectedReplay.Load(),
+		RejectedDowngrade:  p.rejectedDowngrade.Load(),
+		RejectedDecrypt:    p.rejectedDecrypt.Load(),
+		RejectedRecipient:  p.rejectedRecipient.Load(),
+		RejectedStamp:      p.rejectedStamp.Load(),
+		RejectedBlocked:    p.rejectedBlocked.Load(),
+		RejectedRate:       p.rejectedRate.Load(),
+		RejectedOversize:   p.rejectedOversize.Load(),
+		RejectedConnection: p.rejectedConnection.Load(),
+		RejectedInFlight:   p.rejectedInFlight.Load(),
+		LegacyUnprotected:  p.legacy.Load(),
+	}
+}
+
+var (
+	errStalePacket  = fmt.Errorf("packet timestamp outside allowed clock skew")
+	errReplayPacket = fmt.Errorf("packet nonce already seen")
+	errDowngrade    = fmt.Errorf("packet version lower than peer already uses")
+)
+
+// replayGuard проверяет свежесть и уникальность пакетов
+type replayGuard struct {
+	mu        sync.Mutex
+	store     ReplayStore
+	skew      time.Duration
+	lastPrune time.Time
+	versions  map[string]uint32 // контакт -> максимальная замеченная версия протокола (копия из БД)
+	strangers map[string]uint32 // то же для незнакомцев, не больше maxStrangerVersions
+	now       func() time.Time
+}
+
+func newReplayGuard() *replayGuard {
+	return &replayGuard{
+		store:     newMemoryReplayStore(),
+		skew:      DefaultClockSkew,
+		versions:  make(map[string]uint32),
+		strangers: make(map[string]uint32),
+		now:       time.Now,
+	}
+}
+
+// check отклоняет устаревшие и повторные пакеты; legacy — пакет старой версии без заголовка
+func (g *replayGuard) check(ctx context.Context, packet *pb.Packet, senderPubKey string) (legacy bool, err error) {
+	return g.checkWithin(ctx, packet, senderPubKey, 0)
+}
+
+// checkStored проверяет пакет, пролежавший в почтовом ящике: он может быть старше
+// расхождения часов, но не старше maxAge
+func (g *replayGuard) checkStored(ctx context.Context, packet *pb.Packet, senderPubKey string, maxAge time.Duration) error {
+	legacy, err := g.checkWithin(ctx, packet, senderPubKey, maxAge)
+	if err == nil && legacy {
+		return errStalePacket
+	}
+	return err
+}
+
+func (g *replayGuard) checkWithin(ctx context.Context, packet *pb.Packet, senderPubKey string, maxAge time.Duration) (legacy bool, err error) {
+	g.mu.Lock()
+	store, skew, now := g.store, g.skew, g.now()
+	maxAge = max(maxAge, skew)
+	floor, contact := g.versions[senderPubKey]
+	if !contact {
+		floor = g.strangers[senderPubKey]
+	}
+	if packet.Version < floor {
+		g.mu.Unlock()
+		return packet.Version < 2, errDowngrade
+	}
+	if packet.Version < 2 {
+		g.mu.Unlock()
+		return true, nil
+	}
+	prune := now.Sub(g.lastPrune) > replayPruneInterval
+	if prune {
+		g.lastPrune = now
+	}
+	g.mu.Unlock()
+
+	if len(packet.Nonce) != PacketNonceSize {
+		return false, fmt.Errorf("bad nonce size %d", len(packet.Nonce))
+	}
+
+	sent := time.UnixMilli(packet.Timestamp)
+	if sent.Before(now.Add(-maxAge)) || sent.After(now.Add(skew)) {
+		return false, errStalePacket
+	}
+
+	// Всё, что старше окна, и так отклоняется по времени — хранить не нужно
+	if prune {
+		if err := store.PruneNonces(ctx, now.Add(-skew).UnixMilli()); err != nil {
+			return false, fmt.Errorf("prune replay cache: %w", err)
+		}
+	}
+
+	// Nonce старого пакета храним со сдвигом: очистка по окну skew не должна удалить его,
+	// пока пакет с такой отметкой времени ещё принимается
+	fresh, err := store.RememberNonce(ctx, senderPubKey, packet.Nonce, packet.Timestamp+(maxAge-skew).Milliseconds())
+	if err != nil {
+		return false, fmt.Errorf("replay cache: %w", err)
+	}
+	if !fresh {
+		return false, errReplayPacket
+	}
+
+	// Незнакомца проверяем каждый раз: он мог стать контактом
+	if packet.Version > floor || !contact {
+		if err := g.raiseVersion(ctx, store, senderPubKey, packet.Version); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// raiseVersion запоминает новую версию отправителя: контакта — в БД, незнакомца — в памяти
+func (g *replayGuard) raiseVersion(ctx context.Context, store ReplayStore, senderPubKey string, version uint32) error {
+	contact, err := store.SavePacketVersion(ctx, senderPubKey, version)
+	if err != nil {
+		return fmt.Errorf("save packet version: %w", err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if contact {
+		delete(g.strangers, senderPubKey)
+		g.versions[senderPubKey] = max(g.versions[senderPubKey], version)
+		return nil
+	}
+	if _, ok := g.strangers[senderPubKey]; !ok && len(g.strangers) >= maxStrangerVersions {
+		clear(g.strangers)
+	}
+	g.strangers[senderPubKey] = max(g.strangers[senderPubKey], version)
+	return nil
+}
+
+// fresh проверяет, что время (unix ms) укладывается в допустимое расхождение часов
+func (g *replayGuard) fresh(timestamp int64) bool {
+	g.mu.Lock()
+	skew, now := g.skew, g.now()
+	g.mu.Unlock()
+
+	sent := time.UnixMilli(timestamp)
+	return !sent.Before(now.Add(-skew)) && !sent.After(now.Add(skew))
+}
+
+// stampPacket заполняет заголовок свежести исходящего пакета
+func stampPacket(packet *pb.Packet) error {
+	packet.Timestamp = time.Now().UnixMilli()
+	packet.Nonce = make([]byte, PacketNonceSize)
+	if _, err := rand.Read(packet.Nonce); err != nil {
+		return fmt.Errorf("nonce generation failed: %w", err)
+	}
+	return nil
+}
+
+// memoryReplayStore — хранилище nonce в памяти (пока не подключена БД)
+type memoryReplayStore struct {
+	mu     sync.Mutex
+	nonces map[string]int64
+}
+
+func newMemoryReplayStore() *memoryReplayStore {
+	return &memoryReplayStore{nonces: make(map[string]int64)}
+}
+
+func (m *memoryReplayStore) RememberNonce(_ context.Context, senderPubKey string, nonce []byte, timestamp int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := senderPubKey + "/" + string(nonce)
+	if _, ok := m.nonces[key]; ok {
+		return false, nil
+	}
+	m.nonces[key] = timestamp
+	return true, nil
+}
+
+func (m *memoryReplayStore) SavePacketVersion(context.Context, string, uint32) (bool, error) {
+	return false, nil
+}
+
+func (m *memoryReplayStore) PacketVersions(context.Context) (map[string]uint32, error) {
+	return nil, nil
+}
+
+func (m *memoryReplayStore) PruneNonces(_ context.Context, before int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, ts := range m.nonces {
+		if ts < before {
+			delete(m.nonces, key)
+		}
+	}
+	return nil
+}
+
+// SetReplayStore устанавливает постоянное хранилище nonce и загружает из него версии контактов
+func (s *Service) SetReplayStore(store ReplayStore) {
+	versions, err := store.PacketVersions(context.Background())
+	if err != nil {
+		log.Printf("[Messenger] Failed to load packet versions: %v", err)
+	}
+
+	g := s.replay
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.store = store
+	for peer, version := range versions {
+		g.versions[peer] = max(g.versions[peer], version)
+	}
+}
+
+// SetClockSkew задаёт допустимое расхождение часов с собеседником
+func (s *Service) SetClockSkew(skew time.Duration) {
+	if skew <= 0 {
+		skew = DefaultClockSkew
+	}
+	s.replay.mu.Lock()
+	s.replay.skew = skew
+	s.replay.mu.Unlock()
+}
+
+// Diagnostics возвращает счётчики входящих пакетов
+func (s *Service) Diagnostics() Diagnostics {
+	return s.stats.snapshot()
+}
@@ -0,0 +1,121 @@
+package messenger
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "teleghost/internal/proto"
+)
+
+func TestReplayGuard(t *testing.T) {
+	g := newReplayGuard()
+	now := time.UnixMilli(1_700_000_000_000)
+	g.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	packet := &pb.Packet{Version: ProtocolVersion, Timestamp: now.UnixMilli(), Nonce: make([]byte, PacketNonceSize)}
+
+	if _, err := g.check(ctx, packet, "alice"); err != nil {
+		t.Fatalf("Fresh packet rejected: %v", err)
+	}
+	if _, err := g.check(ctx, packet, "alice"); err != errReplayPacket {
+		t.Errorf("Expected replay to be rejected, got %v", err)
+	}
+
+	// Пакет старше допустимого расхождения часов
+	stale := &pb.Packet{Version: ProtocolVersion, Timestamp: now.Add(-DefaultClockSkew - time.Second).UnixMilli(), Nonce: []byte("fedcba9876543210")}
+	if _, err := g.check(ctx, stale, "alice"); err != errStalePacket {
+		t.Errorf("Expected stale packet to be rejected, got %v", err)
+	}
+
+	// Отправитель уже пользуется заголовком — пакет старой версии не принимаем
+	if _, err := g.check(ctx, &pb.Packet{Version: 1}, "alice"); err != errDowngrade {
+		t.Errorf("Expected downgrade to be rejected, got %v", err)
+	}
+	legacy, err := g.check(ctx, &pb.Packet{Version: 1}, "bob")
+	if err != nil || !legacy {
+		t.Errorf("Expected legacy packet from old peer to pass, got %v", err)
+	}
+
+	// Пакет из почтового ящика может быть старше расхождения часов, но не старше maxAge
+	stored := &pb.Packet{Version: ProtocolVersion, Timestamp: now.Add(-24 * time.Hour).UnixMilli(), Nonce: []byte("0000111122223333")}
+	if err := g.checkStored(ctx, stored, "alice", 48*time.Hour); err != nil {
+		t.Fatalf("Stored packet rejected: %v", err)
+	}
+	if err := g.checkStored(ctx, stored, "alice", 48*time.Hour); err != errReplayPacket {
+		t.Errorf("Expected stored replay to be rejected, got %v", err)
+	}
+	if err := g.checkStored(ctx, stale, "alice", time.Minute); err != errStalePacket {
+		t.Errorf("Expected stale stored packet to be rejected, got %v", err)
+	}
+
+	// Очистка по окну skew не забывает nonce пакета из ящика
+	now = now.Add(2 * DefaultClockSkew)
+	g.lastPrune = time.Time{}
+	if _, err := g.check(ctx, &pb.Packet{Version: ProtocolVersion, Timestamp: now.UnixMilli(), Nonce: []byte("4444555566667777")}, "alice"); err != nil {
+		t.Fatalf("Fresh packet rejected: %v", err)
+	}
+	if err := g.checkStored(ctx, stored, "alice", 48*time.Hour); err != errReplayPacket {
+		t.Errorf("Expected stored replay to be rejected after prune, got %v", err)
+	}
+}
+
+// versionStore — хранилище с версиями контактов, как в БД
+type versionStore struct {
+	*memoryReplayStore
+	contacts map[string]uint32
+}
+
+func (v *versionStore) SavePacketVersion(_ context.Context, senderPubKey string, version uint32) (bool, error) {
+	cur, ok := v.contacts[senderPubKey]
+	if !ok {
+		return false, nil
+	}
+	v.contacts[senderPubKey] = max(cur, version)
+	return true, nil
+}
+
+func (v *versionStore) PacketVersions(context.Context) (map[string]uint32, error) {
+	return v.contacts, nil
+}
+
+func TestReplayGuardVersions(t *testing.T) {
+	store := &versionStore{memoryReplayStore: newMemoryReplayStore(), contacts: map[string]uint32{"alice": 0}}
+	s := &Service{replay: newReplayGuard()}
+	s.SetReplayStore(store)
+
+	ctx := context.Background()
+	nonce := byte(0)
+	packet := func(version uint32) *pb.Packet {
+		nonce++
+		return &pb.Packet{Version: version, Timestamp: time.Now().UnixMilli(), Nonce: append(make([]byte, PacketNonceSize-1), nonce)}
+	}
+	if _, err := s.replay.check(ctx, packet(ProtocolVersion), "alice"); err != nil {
+		t.Fatalf("Fresh packet rejected: %v", err)
+	}
+	if store.contacts["alice"] != ProtocolVersion {
+		t.Fatalf("Contact version not saved: %d", store.contacts["alice"])
+	}
+
+	// После перезапуска версия контакта загружается из БД
+	restarted := &Service{replay: newReplayGuard()}
+	restarted.SetReplayStore(store)
+	if _, err := restarted.replay.check(ctx, &pb.Packet{Version: 1}, "alice"); err != errDowngrade {
+		t.Errorf("Expected downgrade after restart to be rejected, got %v", err)
+	}
+
+	// Версии незнакомцев в памяти ограничены
+	for i := 0; i <= maxStrangerVersions; i++ {
+		if _, err := restarted.replay.check(ctx, packet(ProtocolVersion), fmt.Sprintf("stranger-%d", i)); err != nil {
+			t.Fatalf("Fresh packet rejected: %v", err)
+		}
+	}
+	if n := len(restarted.replay.strangers); n > maxStrangerVersions {
+		t.Errorf("Stranger versions grew to %d", n)
+	}
+	if _, ok := store.contacts["stranger-0"]; ok {
+		t.Error("Stranger version was persisted")
+	}
+}
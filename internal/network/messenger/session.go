@@ -0,0 +1,570 @@
+package messenger
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-i2p/i2pkeys"
+
+	"teleghost/internal/core/identity"
+	"teleghost/internal/core/ratchet"
+	pb "teleghost/internal/proto"
+)
+
+const (
+	// HandshakeTimeout сколько ждём ответный handshake перед отправкой
+	HandshakeTimeout = 60 * time.Second
+
+	// PendingHandshakeTTL сколько храним эфемерный ключ без ответа
+	PendingHandshakeTTL = 10 * time.Minute
+
+	// MaxSessionsPerPeer сколько последних сессий храним на контакт
+	// (старые нужны, чтобы расшифровать пакеты, отправленные до смены ключей)
+	MaxSessionsPerPeer = 4
+)
+
+// ErrNoSession — с контактом нет E2EE-сессии
+var ErrNoSession = errors.New("e2e session not established")
+
+// encryptedTypes — типы пакетов, payload которых шифруется ключом сессии
+var encryptedTypes = map[pb.PacketType]bool{
+	pb.PacketType_TEXT_MESSAGE:    true,
+	pb.PacketType_FILE_OFFER:      true,
+	pb.PacketType_PROFILE_UPDATE:  true,
+	pb.PacketType_RECEIPT:         true,
+	pb.PacketType_MESSAGE_EDIT:    true,
+	pb.PacketType_MESSAGE_DELETE:  true,
+	pb.PacketType_FILE_CHUNK:      true,
+	pb.PacketType_FILE_CHUNK_ACK:  true,
+	pb.PacketType_FILE_RESUME:     true,
+	pb.PacketType_FILE_STREAM:     true,
+	pb.PacketType_TYPING:          true,
+	pb.PacketType_PRESENCE:        true,
+	pb.PacketType_GROUP_CONTROL:   true,
+	pb.PacketType_CHANNEL_POST:    true,
+	pb.PacketType_CHANNEL_CONTROL: true,
+}
+
+// SessionStore сохраняет состояние Double Ratchet между перезапусками
+type SessionStore interface {
+	SaveRatchetStates(ctx context.Context, peerPubKey string, states []*ratchet.State) error
+	ListRatchetStates(ctx context.Context) (map[string][]*ratchet.State, error)
+	DeleteRatchetStates(ctx context.Context, peerPubKey string) error
+}
+
+// PeerResolver возвращает публичный ключ контакта по его I2P адресу ("" если неизвестен)
+type PeerResolver func(destination string) string
+
+// ReplyFilter сообщает, можно ли отвечать собеседнику без действий пользователя
+// (ответный handshake, переустановка сессии). Незнакомцу с непринятым запросом переписки не отвечаем.
+type ReplyFilter func(senderPubKey, senderAddr string) bool
+
+// pendingHandshake — наш эфемерный ключ, ожидающий ответа
+type pendingHandshake struct {
+	key         *ecdh.PrivateKey
+	destination string
+	createdAt   time.Time
+}
+
+// sessionManager хранит сессии и незавершённые рукопожатия
+type sessionManager struct {
+	mu       sync.Mutex
+	sessions map[string][]*ratchet.State  // pubkey -> сессии (новые первыми)
+	pending  map[string]*pendingHandshake // hex(наш эфемерный ключ) -> handshake
+	waiters  map[string]chan struct{}     // destination -> закрывается при установке сессии
+	peers    map[string]string            // destination -> pubkey (из handshake)
+	legacy   map[string]bool              // pubkey -> пир без поддержки E2EE
+}
+
+func newSessionManager() *sessionManager {
+	return &sessionManager{
+		sessions: make(map[string][]*ratchet.State),
+		pending:  make(map[string]*pendingHandshake),
+		waiters:  make(map[string]chan struct{}),
+		peers:    make(map[string]string),
+		legacy:   make(map[string]bool),
+	}
+}
+
+// SetSessionStore устанавливает хранилище сессий
+func (s *Service) SetSessionStore(store SessionStore) {
+	s.sessionStore = store
+}
+
+// SetPeerResolver устанавливает функцию поиска публичного ключа по адресу
+func (s *Service) SetPeerResolver(r PeerResolver) {
+	s.peerResolver = r
+}
+
+// SetReplyFilter устанавливает проверку, можно ли автоматически отвечать собеседнику
+func (s *Service) SetReplyFilter(f ReplyFilter) {
+	s.replyFilter = f
+}
+
+func (s *Service) mayReply(senderPubKey, senderAddr string) bool {
+	return s.replyFilter == nil || s.replyFilter(senderPubKey, senderAddr)
+}
+
+// HasSession проверяет, есть ли E2EE-сессия с контактом
+func (s *Service) HasSession(peerPubKey string) bool {
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+	return len(s.sessions.sessions[peerPubKey]) > 0
+}
+
+// DropSessions забывает все сессии с контактом (например, при удалении)
+func (s *Service) DropSessions(peerPubKey string) {
+	s.sessions.mu.Lock()
+	delete(s.sessions.sessions, peerPubKey)
+	delete(s.sessions.legacy, peerPubKey)
+	s.sessions.mu.Unlock()
+
+	if s.sessionStore != nil {
+		if err := s.sessionStore.DeleteRatchetStates(context.Background(), peerPubKey); err != nil {
+			log.Printf("[Messenger] Failed to delete sessions: %v", err)
+		}
+	}
+}
+
+// loadSessions загружает сохранённые сессии
+func (s *Service) loadSessions() {
+	if s.sessionStore == nil {
+		return
+	}
+
+	stored, err := s.sessionStore.ListRatchetStates(s.ctx)
+	if err != nil {
+		log.Printf("[Messenger] Failed to load sessions: %v", err)
+		return
+	}
+
+	s.sessions.mu.Lock()
+	for peer, list := range stored {
+		s.sessions.sessions[peer] = list
+	}
+	s.sessions.mu.Unlock()
+
+	log.Printf("[Messenger] Loaded E2EE sessions for %d peers", len(stored))
+}
+
+// resolvePeer ищет публичный ключ по адресу
+func (s *Service) resolvePeer(destination string) string {
+	s.sessions.mu.Lock()
+	peer := s.sessions.peers[normalizeDest(destination)]
+	s.sessions.mu.Unlock()
+
+	if peer == "" && s.peerResolver != nil {
+		peer = s.peerResolver(destination)
+	}
+	return peer
+}
+
+// newEphemeral создаёт эфемерный ключ для handshake и запоминает его
+func (s *Service) newEphemeral(destination string) (*ecdh.PrivateKey, error) {
+	key, err := identity.GenerateEphemeralKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	m := s.sessions
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Чистим просроченные
+	for id, p := range m.pending {
+		if time.Since(p.createdAt) > PendingHandshakeTTL {
+			delete(m.pending, id)
+		}
+	}
+
+	m.pending[hex.EncodeToString(key.PublicKey().Bytes())] = &pendingHandshake{
+		key:         key,
+		destination: destination,
+		createdAt:   time.Now(),
+	}
+
+	return key, nil
+}
+
+// takePending возвращает и удаляет наш эфемерный ключ по публичной части
+func (s *Service) takePending(ephemeralPub []byte) *pendingHandshake {
+	id := hex.EncodeToString(ephemeralPub)
+
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+
+	p := s.sessions.pending[id]
+	delete(s.sessions.pending, id)
+	return p
+}
+
+// addSession инициализирует Double Ratchet из новой сессии и будит ожидающих отправки
+func (s *Service) addSession(peerPubKey string, session *identity.SessionKeys, destinations ...string) {
+	state, err := ratchet.New(session, s.identity.PublicKeyBase64, peerPubKey)
+	if err != nil {
+		log.Printf("[Messenger] Failed to init ratchet: %v", err)
+		return
+	}
+
+	bind := s.bindableDests(peerPubKey, destinations)
+
+	m := s.sessions
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := append([]*ratchet.State{state}, m.sessions[peerPubKey]...)
+	if len(list) > MaxSessionsPerPeer {
+		list = list[:MaxSessionsPerPeer]
+	}
+	m.sessions[peerPubKey] = list
+	delete(m.legacy, peerPubKey)
+	m.bindLocked(peerPubKey, bind)
+
+	log.Printf("[Messenger] E2EE session %x established with %s", state.SessionID[:4], peerPubKey[:min(16, len(peerPubKey))])
+	s.saveSessionsLocked(peerPubKey)
+}
+
+// saveSessionsLocked сохраняет состояние ratchet контакта (вызывается под m.mu,
+// чтобы сериализация не пересекалась с шифрованием)
+func (s *Service) saveSessionsLocked(peerPubKey string) {
+	if s.sessionStore == nil {
+		return
+	}
+	if err := s.sessionStore.SaveRatchetStates(context.Background(), peerPubKey, s.sessions.sessions[peerPubKey]); err != nil {
+		log.Printf("[Messenger] Failed to save ratchet state: %v", err)
+	}
+}
+
+// markLegacy запоминает, что пир прислал handshake без эфемерного ключа.
+// Пир, уже договорившийся об E2EE, и контакт на открытый текст не откатываются.
+func (s *Service) markLegacy(peerPubKey, destination string) {
+	if info, ok := s.GetPeerInfo(peerPubKey); ok && info.Negotiated && info.Capabilities&CapE2EE != 0 {
+		log.Printf("[Messenger] Handshake without ephemeral key from E2EE peer %s ignored", peerPubKey[:min(16, len(peerPubKey))])
+		return
+	}
+	if s.knownPeer != nil && s.knownPeer(peerPubKey, "") {
+		log.Printf("[Messenger] Handshake without ephemeral key from contact %s ignored", peerPubKey[:min(16, len(peerPubKey))])
+		return
+	}
+	bind := s.bindableDests(peerPubKey, []string{destination})
+
+	m := s.sessions
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.sessions[peerPubKey]) > 0 {
+		// Однажды поддержав E2EE, пир не может откатиться на открытый текст
+		return
+	}
+	m.legacy[peerPubKey] = true
+	m.bindLocked(peerPubKey, bind)
+}
+
+// withB32 дополняет полные адреса их b32 формой: контакт мог быть добавлен по b32,
+// а handshake приходит с полного адреса
+func withB32(destinations []string) []string {
+	out := make([]string, 0, 2*len(destinations))
+	for _, dest := range destinations {
+		if dest == "" {
+			continue
+		}
+		out = append(out, normalizeDest(dest))
+		if isFullDest(dest) {
+			out = append(out, i2pkeys.I2PAddr(dest).Base32())
+		}
+	}
+	return out
+}
+
+// sameDest — оба адреса указывают на одного собеседника (полный адрес или его b32)
+func sameDest(a, b string) bool {
+	for _, x := range withB32([]string{a}) {
+		for _, y := range withB32([]string{b}) {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bindableDests возвращает адреса, которые можно закрепить за ключом: адрес, уже принадлежащий
+// другому ключу (сессия или контакт), не переходит, чтобы чужой handshake не перехватил переписку
+func (s *Service) bindableDests(peerPubKey string, destinations []string) []string {
+	var out []string
+	for _, dest := range withB32(destinations) {
+		if owner := s.resolvePeer(dest); owner != "" && owner != peerPubKey {
+			log.Printf("[Messenger] %s claims address of another peer %s...", peerPubKey[:min(16, len(peerPubKey))], dest[:min(16, len(dest))])
+			continue
+		}
+		out = append(out, dest)
+	}
+	return out
+}
+
+// bindLocked закрепляет адреса за ключом и будит ожидающих handshake (вызывается под m.mu)
+func (m *sessionManager) bindLocked(peerPubKey string, destinations []string) {
+	for _, dest := range destinations {
+		if owner := m.peers[dest]; owner != "" && owner != peerPubKey {
+			continue
+		}
+		m.peers[dest] = peerPubKey
+		if ch, ok := m.waiters[dest]; ok {
+			close(ch)
+			delete(m.waiters, dest)
+		}
+	}
+}
+
+// requestSession отправляет handshake, если он ещё не в пути, и возвращает канал ожидания
+func (s *Service) requestSession(destination string) <-chan struct{} {
+	m := s.sessions
+	m.mu.Lock()
+	ch, inFlight := m.waiters[normalizeDest(destination)]
+	if !inFlight {
+		ch = make(chan struct{})
+		m.waiters[normalizeDest(destination)] = ch
+	}
+	m.mu.Unlock()
+
+	if !inFlight {
+		go func() {
+			if err := s.SendHandshake(destination); err != nil {
+				log.Printf("[Messenger] Failed to send handshake for session: %v", err)
+				s.cancelWaiter(destination, ch)
+				return
+			}
+			// Если ответ так и не пришёл, снимаем ожидание, чтобы следующий запрос повторил handshake
+			time.AfterFunc(HandshakeTimeout, func() { s.cancelWaiter(destination, ch) })
+		}()
+	}
+
+	return ch
+}
+
+// cancelWaiter закрывает ожидание, если оно ещё актуально
+func (s *Service) cancelWaiter(destination string, ch chan struct{}) {
+	m := s.sessions
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if cur, ok := m.waiters[normalizeDest(destination)]; ok && cur == ch {
+		close(ch)
+		delete(m.waiters, normalizeDest(destination))
+	}
+}
+
+// sessionPeer возвращает ключ контакта, с которым есть сессия, при необходимости выполняя handshake.
+// Пустая строка без ошибки означает пира без поддержки E2EE.
+func (s *Service) sessionPeer(destination string) (string, error) {
+	peer := s.resolvePeer(destination)
+	if peer != "" {
+		if s.HasSession(peer) {
+			return peer, nil
+		}
+		if s.isLegacy(peer) {
+			return "", nil
+		}
+	}
+
+	log.Printf("[Messenger] No E2EE session with %s..., performing handshake", destination[:min(16, len(destination))])
+
+	select {
+	case <-s.requestSession(destination):
+	case <-time.After(HandshakeTimeout):
+	}
+
+	if peer == "" {
+		peer = s.resolvePeer(destination)
+	}
+	if peer != "" {
+		if s.HasSession(peer) {
+			return peer, nil
+		}
+		if s.isLegacy(peer) {
+			log.Printf("[Messenger] WARNING: peer %s does not support E2EE, sending unencrypted", peer[:min(16, len(peer))])
+			return "", nil
+		}
+	}
+
+	return "", ErrNoSession
+}
+
+// isLegacy проверяет, прислал ли пир handshake без эфемерного ключа
+func (s *Service) isLegacy(peerPubKey string) bool {
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+	return s.sessions.legacy[peerPubKey]
+}
+
+// sessionAD — associated data для AEAD: привязывает шифротекст к типу пакета и отправителю
+func sessionAD(packetType pb.PacketType, senderPubKey string) []byte {
+	return []byte(fmt.Sprintf("%d|%s", packetType, senderPubKey))
+}
+
+// encryptPacket шифрует payload пакета ключом сессии с получателем
+func (s *Service) encryptPacket(destination string, packet *pb.Packet) error {
+	if !encryptedTypes[packet.Type] || len(packet.Payload) == 0 {
+		return nil
+	}
+
+	// Без сессии текст запечатываем на статический ключ получателя: незнакомцу он может
+	// не ответить, пока не примет запрос. Handshake всё равно отправляем — для следующих пакетов.
+	if key := s.recipientIntroKey(destination, packet); key != nil {
+		s.requestSession(destination)
+		return s.sealIntro(key, packet)
+	}
+
+	peer, err := s.sessionPeer(destination)
+	if err != nil {
+		return err
+	}
+	if peer == "" {
+		return nil
+	}
+
+	m := s.sessions
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := m.sessions[peer]
+	if len(list) == 0 {
+		return ErrNoSession
+	}
+
+	// Отправляем всегда в самой свежей сессии
+	state := list[0]
+	sealed, err := state.Encrypt(packet.Payload, sessionAD(packet.Type, s.identity.PublicKeyBase64))
+	if err != nil {
+		return fmt.Errorf("encrypt failed: %w", err)
+	}
+	s.saveSessionsLocked(peer)
+
+	packet.Payload = sealed
+	packet.SessionId = state.SessionID
+	return nil
+}
+
+// decryptPacket расшифровывает payload входящего пакета.
+// Открытый текст шифруемых типов от пиров с E2EE отклоняется.
+func (s *Service) decryptPacket(packet *pb.Packet, senderPubKey, remoteAddr string) error {
+	if len(packet.SealedEphemeral) > 0 {
+		return s.openIntro(packet, senderPubKey)
+	}
+	if len(packet.SessionId) == 0 {
+		// Пустой payload не шифруется (например, PRESENCE «не в сети» без полей)
+		if encryptedTypes[packet.Type] && len(packet.Payload) > 0 && s.HasSession(senderPubKey) {
+			return errors.New("unencrypted packet from E2EE peer")
+		}
+		return nil
+	}
+
+	m := s.sessions
+	m.mu.Lock()
+	var state *ratchet.State
+	for _, st := range m.sessions[senderPubKey] {
+		if bytes.Equal(st.SessionID, packet.SessionId) {
+			state = st
+			break
+		}
+	}
+
+	if state == nil {
+		m.mu.Unlock()
+		// Пир использует сессию, которой у нас нет (например, после потери БД) — переустанавливаем
+		if remoteAddr != "" && s.mayReply(senderPubKey, remoteAddr) {
+			s.requestSession(remoteAddr)
+		}
+		return fmt.Errorf("unknown session %x", packet.SessionId)
+	}
+
+	plain, err := state.Decrypt(packet.Payload, sessionAD(packet.Type, senderPubKey))
+	if err == nil {
+		s.saveSessionsLocked(senderPubKey)
+	}
+	m.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("decrypt failed: %w", err)
+	}
+
+	packet.Payload = plain
+	packet.SessionId = nil
+	return nil
+}
+
+// handshakeDestination — адрес собеседника для сессии. Адрес в handshake выбирает отправитель,
+// поэтому он принимается, только если совпадает с адресом соединения. У пакета из почтового
+// ящика соединения нет: заявленный адрес берём, но чужой адрес он не перепишет (bindableDests).
+func handshakeDestination(claimed, remoteAddr string) string {
+	if remoteAddr == "" || (claimed != "" && sameDest(claimed, remoteAddr)) {
+		return claimed
+	}
+	return remoteAddr
+}
+
+// handleSessionHandshake обрабатывает эфемерный ключ из handshake.
+// Для входящего рукопожатия отвечает своим эфемерным ключом, если собеседнику можно отвечать.
+func (s *Service) handleSessionHandshake(handshake *pb.Handshake, senderPubKey, remoteAddr string) {
+	destination := handshakeDestination(handshake.I2PAddress, remoteAddr)
+	if destination != handshake.I2PAddress && handshake.I2PAddress != "" {
+		log.Printf("[Messenger] Handshake from %s names address %s... of another connection", senderPubKey[:min(16, len(senderPubKey))], handshake.I2PAddress[:min(16, len(handshake.I2PAddress))])
+	}
+
+	if len(handshake.EphemeralPubKey) == 0 {
+		s.markLegacy(senderPubKey, destination)
+		return
+	}
+
+	if handshake.IsResponse {
+		pending := s.takePending(handshake.ReplyToEphemeral)
+		if pending == nil {
+			log.Printf("[Messenger] Handshake response for unknown ephemeral key from %s", senderPubKey[:min(16, len(senderPubKey))])
+			return
+		}
+
+		sess, err := identity.DeriveSessionKeys(pending.key, handshake.EphemeralPubKey, s.identity.PublicKeyBase64, senderPubKey)
+		if err != nil {
+			log.Printf("[Messenger] Failed to derive session keys: %v", err)
+			return
+		}
+		s.addSession(senderPubKey, sess, destination, pending.destination)
+		return
+	}
+
+	if !s.mayReply(senderPubKey, destination) {
+		// Ответ выдал бы, что мы в сети: сессию установим сами, когда запрос примут.
+		// Текст незнакомец до этого присылает запечатанным на наш статический ключ (openIntro).
+		log.Printf("[Messenger] Handshake from %s left unanswered", senderPubKey[:min(16, len(senderPubKey))])
+		return
+	}
+
+	key, err := identity.GenerateEphemeralKey()
+	if err != nil {
+		log.Printf("[Messenger] Failed to generate ephemeral key: %v", err)
+		return
+	}
+
+	sess, err := identity.DeriveSessionKeys(key, handshake.EphemeralPubKey, s.identity.PublicKeyBase64, senderPubKey)
+	if err != nil {
+		log.Printf("[Messenger] Failed to derive session keys: %v", err)
+		return
+	}
+	s.addSession(senderPubKey, sess, destination)
+
+	if destination == "" {
+		return
+	}
+	// Отвечаем до разбора следующих пакетов соединения: иначе наш ответ на них,
+	// зашифрованный новой сессией, может обогнать handshake, и собеседник его отбросит
+	if err := s.sendHandshake(destination, key.PublicKey().Bytes(), handshake.EphemeralPubKey); err != nil {
+		log.Printf("[Messenger] Failed to send handshake response: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package messenger
+
+import (
+	"testing"
+
+	"teleghost/internal/core/identity"
+	pb "teleghost/internal/proto"
+)
+
+func TestHandshakeNotAnsweredWhenFiltered(t *testing.T) {
+	bob := newTestService(t)
+	alicePub := "alice-pub"
+
+	key, err := identity.GenerateEphemeralKey()
+	if err != nil {
+		t.Fatalf("GenerateEphemeralKey failed: %v", err)
+	}
+	handshake := &pb.Handshake{EphemeralPubKey: key.PublicKey().Bytes()}
+
+	allowed := false
+	var asked []string
+	bob.SetReplyFilter(func(pubKey, _ string) bool {
+		asked = append(asked, pubKey)
+		return allowed
+	})
+
+	// Запрос переписки не принят: ни ответа, ни сессии
+	bob.handleSessionHandshake(handshake, alicePub, "alice-dest")
+	if bob.HasSession(alicePub) {
+		t.Fatal("Session established with filtered peer")
+	}
+	if len(asked) != 1 || asked[0] != alicePub {
+		t.Fatalf("Reply filter not consulted: %v", asked)
+	}
+
+	// Без адреса ответ не отправляется, сессия устанавливается
+	allowed = true
+	bob.handleSessionHandshake(handshake, alicePub, "")
+	if !bob.HasSession(alicePub) {
+		t.Error("Session not established with allowed peer")
+	}
+}
+
+func TestHandshakeCannotClaimContactAddress(t *testing.T) {
+	bob := newTestService(t)
+	alicePub, malloryPub := "alice-pub", "mallory-pub"
+	bob.SetKnownPeerChecker(func(pubKey, _ string) bool { return pubKey == alicePub })
+	bob.SetPeerResolver(func(dest string) string {
+		if dest == "alice-dest" {
+			return alicePub
+		}
+		return ""
+	})
+
+	// Чужой адрес в handshake по соединению не принимается
+	bob.handleSessionHandshake(&pb.Handshake{I2PAddress: "alice-dest"}, malloryPub, "mallory-dest")
+	if got := bob.resolvePeer("alice-dest"); got != alicePub {
+		t.Errorf("Contact address moved to %q by legacy handshake", got)
+	}
+	if got := bob.resolvePeer("mallory-dest"); got != malloryPub {
+		t.Errorf("Handshake not bound to its connection: %q", got)
+	}
+
+	// Из почтового ящика адрес не проверить, но занятый он не переписывает
+	bob.handleSessionHandshake(&pb.Handshake{I2PAddress: "alice-dest"}, "eve-pub", "")
+	ours, err := bob.newEphemeral("eve-dest")
+	if err != nil {
+		t.Fatalf("newEphemeral failed: %v", err)
+	}
+	theirs, err := identity.GenerateEphemeralKey()
+	if err != nil {
+		t.Fatalf("GenerateEphemeralKey failed: %v", err)
+	}
+	bob.handleSessionHandshake(&pb.Handshake{
+		EphemeralPubKey:  theirs.PublicKey().Bytes(),
+		I2PAddress:       "alice-dest",
+		IsResponse:       true,
+		ReplyToEphemeral: ours.PublicKey().Bytes(),
+	}, "eve-pub", "")
+	if !bob.HasSession("eve-pub") {
+		t.Fatal("Session not established")
+	}
+	if got := bob.resolvePeer("alice-dest"); got != alicePub {
+		t.Errorf("Contact address moved to %q by stored handshake", got)
+	}
+	if got := bob.resolvePeer("eve-dest"); got != "eve-pub" {
+		t.Errorf("Session not bound to dialed address: %q", got)
+	}
+
+	// Контакт и пир, договорившийся об E2EE, не откатываются на открытый текст
+	bob.handleSessionHandshake(&pb.Handshake{}, alicePub, "alice-dest")
+	if bob.isLegacy(alicePub) {
+		t.Error("Contact marked legacy")
+	}
+	bob.SetPeerInfo("carol-pub", ProtocolVersion, LocalCapabilities)
+	bob.handleSessionHandshake(&pb.Handshake{}, "carol-pub", "carol-dest")
+	if bob.isLegacy("carol-pub") {
+		t.Error("E2EE peer marked legacy")
+	}
+}
@@ -0,0 +1,101 @@
+package messenger
+
+import (
+	"errors"
+
+	"teleghost/internal/network/envelope"
+	pb "teleghost/internal/proto"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+// Proof-of-work штампы (hashcash) считаются и проверяются в пакете envelope;
+// здесь — какие пакеты их требуют и от кого.
+
+var errNoStamp = errors.New("missing or invalid proof-of-work stamp")
+
+// stampTypes — пакеты, которыми можно начать разговор. К ним отправитель прикладывает штамп,
+// остальные пакеты от незнакомцев отбрасываются всегда.
+var stampTypes = map[pb.PacketType]bool{
+	pb.PacketType_HANDSHAKE:       true,
+	pb.PacketType_TEXT_MESSAGE:    true,
+	pb.PacketType_PROFILE_REQUEST: true,
+	pb.PacketType_PROFILE_UPDATE:  true,
+	pb.PacketType_FILE_OFFER:      true,
+	pb.PacketType_GROUP_CONTROL:   true,
+	pb.PacketType_CHANNEL_CONTROL: true,
+}
+
+// KnownPeerChecker сообщает, знаком ли отправитель (контакт, участник группы, канал).
+// Пакеты незнакомцев без штампа отбрасываются до любой записи в базу.
+type KnownPeerChecker func(senderPubKey, senderAddr string) bool
+
+// SetKnownPeerChecker включает проверку штампов у незнакомцев
+func (s *Service) SetKnownPeerChecker(c KnownPeerChecker) {
+	s.knownPeer = c
+}
+
+// SetStampPolicy задаёт алгоритм и сложность штампов
+func (s *Service) SetStampPolicy(p envelope.StampPolicy) {
+	s.mu.Lock()
+	s.stampPolicy = p.Normalize()
+	s.mu.Unlock()
+}
+
+func (s *Service) getStampPolicy() envelope.StampPolicy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stampPolicy
+}
+
+// needsStamp — получатель ещё ни разу нам не ответил, значит, может не знать нас
+func (s *Service) needsStamp(peerPubKey string, packet *pb.Packet) bool {
+	if !stampTypes[packet.Type] {
+		return false
+	}
+	if peerPubKey == "" {
+		return true
+	}
+	_, ok := s.GetPeerInfo(peerPubKey)
+	return !ok
+}
+
+// addStamp считает штамп для пакета. Пакет уже зашифрован и подписан: штамп в подпись не входит.
+func (s *Service) addStamp(destination string, packet *pb.Packet) {
+	policy := s.getStampPolicy()
+	packet.Stamp = envelope.MintStamp(packet, envelope.StampRecipient(packet, destination), policy.Algorithm, policy.Bits)
+}
+
+// stampAccepted проверяет, что пакет от знакомого или несёт достаточный штамп
+func (s *Service) stampAccepted(packet *pb.Packet, senderPubKey, remoteAddr string) bool {
+	if s.knownPeer == nil || s.knownPeer(senderPubKey, remoteAddr) {
+		return true
+	}
+	if packet.Stamp == nil || !stampTypes[packet.Type] {
+		return false
+	}
+
+	policy := s.getStampPolicy()
+	if len(packet.RecipientPubKey) > 0 {
+		// Адресат уже сверен с нашим ключом в verifyPacket
+		return policy.Accepts(packet, string(packet.RecipientPubKey))
+	}
+	for _, recipient := range s.localAddresses() {
+		if policy.Accepts(packet, recipient) {
+			return true
+		}
+	}
+	return false
+}
+
+// localAddresses — наш I2P адрес в полном и в b32 виде (пакет без ключа получателя адресуют по нему)
+func (s *Service) localAddresses() []string {
+	if s.router == nil {
+		return nil
+	}
+	dest := s.GetDestination()
+	if dest == "" {
+		return nil
+	}
+	return []string{dest, i2pkeys.I2PAddr(dest).Base32()}
+}
@@ -0,0 +1,83 @@
+package messenger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"teleghost/internal/core"
+	"teleghost/internal/network/envelope"
+	pb "teleghost/internal/proto"
+)
+
+func TestStampRequiredFromStrangers(t *testing.T) {
+	alice := newTestService(t)
+	bob := newTestService(t)
+	bob.ctx = context.Background()
+	pairSessions(t, alice, bob, "alice-dest", "bob-dest")
+
+	policy := envelope.StampPolicy{Algorithm: pb.StampAlgorithm_STAMP_SHA256, Bits: 8}
+	alice.SetStampPolicy(policy)
+	bob.SetStampPolicy(policy)
+
+	known := false
+	bob.SetKnownPeerChecker(func(string, string) bool { return known })
+	var received []*core.Message
+	bob.handler = func(msg *core.Message, _, _ string) { received = append(received, msg) }
+
+	seal := func(packet *pb.Packet) *pb.Packet {
+		sealed, err := alice.sealPacket("bob-dest", packet)
+		if err != nil {
+			t.Fatalf("sealPacket failed: %v", err)
+		}
+		return sealed
+	}
+	text := func(id string) *pb.Packet {
+		return &pb.Packet{Type: pb.PacketType_TEXT_MESSAGE, Payload: mustMarshal(t, &pb.TextMessage{
+			ChatId: "chat", MessageId: id, Content: "hi", Timestamp: time.Now().UnixMilli(),
+		})}
+	}
+
+	// Алиса ещё не слышала Боба — прикладывает штамп, и Боб его принимает
+	stamped := seal(text("m1"))
+	if stamped.Stamp == nil {
+		t.Fatal("Packet to unknown peer sent without stamp")
+	}
+	bob.handlePacket(stamped, "alice-dest", false)
+	if len(received) != 1 {
+		t.Fatalf("Stamped message dropped")
+	}
+
+	// Без штампа, с чужим штампом и пакеты, которыми не начинают разговор, отбрасываются
+	unstamped := seal(text("m2"))
+	unstamped.Stamp = nil
+	bob.handlePacket(unstamped, "alice-dest", false)
+
+	forged := seal(text("m3"))
+	forged.Stamp = stamped.Stamp
+	bob.handlePacket(forged, "alice-dest", false)
+
+	bob.handlePacket(seal(&pb.Packet{Type: pb.PacketType_HEARTBEAT}), "alice-dest", false)
+
+	if len(received) != 1 {
+		t.Fatalf("Expected only stamped message, got %d", len(received))
+	}
+	if got := bob.stats.rejectedStamp.Load(); got != 3 {
+		t.Errorf("Expected 3 rejected stamps, got %d", got)
+	}
+
+	// Контакту штамп не нужен
+	known = true
+	unstamped = seal(text("m4"))
+	unstamped.Stamp = nil
+	bob.handlePacket(unstamped, "alice-dest", false)
+	if len(received) != 2 {
+		t.Errorf("Unstamped message from contact dropped")
+	}
+
+	// Собеседник нам отвечал — штамп больше не считаем
+	alice.SetPeerInfo(bob.identity.PublicKeyBase64, ProtocolVersion, LocalCapabilities)
+	if seal(text("m5")).Stamp != nil {
+		t.Error("Stamp computed for a peer that already knows us")
+	}
+}
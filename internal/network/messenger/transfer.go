@@ -0,0 +1,319 @@
+package messenger
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+
+	"teleghost/internal/network/wire"
+	pb "teleghost/internal/proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// FileChunkSize — размер части файла при передаче
+	FileChunkSize = 64 * 1024
+
+	// MaxFileChunkSize — максимальный размер части, который принимаем от собеседника
+	MaxFileChunkSize = 1024 * 1024
+)
+
+// FileChunkHandler обработчик входящих частей файла
+type FileChunkHandler func(senderPubKey, messageID, fileID string, index uint32, data []byte)
+
+// FileChunkAckHandler обработчик подтверждений принятых частей
+type FileChunkAckHandler func(senderPubKey, messageID, fileID string, nextIndex uint32)
+
+// FileResumeHandler обработчик запросов продолжить передачу (fileID -> номер части)
+type FileResumeHandler func(senderPubKey, messageID string, nextIndex map[string]uint32)
+
+// SendFileChunk отправляет одну часть файла
+func (s *Service) SendFileChunk(destination, chatID, messageID, fileID string, index uint32, data []byte) error {
+	chunk := &pb.FileChunk{
+		MessageId: messageID,
+		FileId:    fileID,
+		Index:     index,
+		Data:      data,
+		ChatId:    chatID,
+	}
+
+	payload, err := proto.Marshal(chunk)
+	if err != nil {
+		return fmt.Errorf("marshal file chunk failed: %w", err)
+	}
+
+	return s.SendMessage(destination, &pb.Packet{
+		Type:    pb.PacketType_FILE_CHUNK,
+		Payload: payload,
+	})
+}
+
+// SendFileChunkAck подтверждает, что получены все части файла до nextIndex
+func (s *Service) SendFileChunkAck(destination, messageID, fileID string, nextIndex uint32) error {
+	ack := &pb.FileChunkAck{
+		MessageId: messageID,
+		FileId:    fileID,
+		NextIndex: nextIndex,
+	}
+
+	payload, err := proto.Marshal(ack)
+	if err != nil {
+		return fmt.Errorf("marshal file chunk ack failed: %w", err)
+	}
+
+	return s.SendMessage(destination, &pb.Packet{
+		Type:    pb.PacketType_FILE_CHUNK_ACK,
+		Payload: payload,
+	})
+}
+
+// SendFileResume просит отправителя продолжить передачу с указанных частей
+func (s *Service) SendFileResume(destination, chatID, messageID string, nextIndex map[string]uint32) error {
+	resume := &pb.FileResume{
+		MessageId: messageID,
+		ChatId:    chatID,
+	}
+	for fileID, idx := range nextIndex {
+		resume.Files = append(resume.Files, &pb.FileProgress{FileId: fileID, NextIndex: idx})
+	}
+
+	payload, err := proto.Marshal(resume)
+	if err != nil {
+		return fmt.Errorf("marshal file resume failed: %w", err)
+	}
+
+	log.Printf("[Messenger] Requesting resume of %s (%d files) from %s...", messageID[:min(8, len(messageID))], len(nextIndex), destination[:min(32, len(destination))])
+	return s.SendMessage(destination, &pb.Packet{
+		Type:    pb.PacketType_FILE_RESUME,
+		Payload: payload,
+	})
+}
+
+// FileStream — исходящий поток частей одного файла. Идёт в отдельном соединении, чтобы
+// большой файл не задерживал остальные пакеты; в памяти одновременно только одна часть.
+type FileStream struct {
+	conn   net.Conn
+	w      *wire.StreamWriter
+	left   uint32
+	closed bool
+}
+
+// OpenFileStream открывает поток count частей файла начиная с части start
+func (s *Service) OpenFileStream(destination, chatID, messageID, fileID string, start, count uint32) (*FileStream, error) {
+	conn, err := s.dial(destination)
+	if err != nil {
+		return nil, fmt.Errorf("connection failed: %w", err)
+	}
+
+	stream, err := s.startFileStream(conn, destination, &pb.FileStream{
+		MessageId:  messageID,
+		FileId:     fileID,
+		ChatId:     chatID,
+		StartIndex: start,
+		ChunkCount: count,
+	})
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	log.Printf("[Messenger] Streaming %d chunks of %s to %s...", count, fileID[:min(8, len(fileID))], destination[:min(32, len(destination))])
+	return stream, nil
+}
+
+// startFileStream отправляет заголовок потока обычным пакетом (E2EE, подпись, штамп)
+// и готовит шифрование кадров тела одноразовым ключом из заголовка
+func (s *Service) startFileStream(conn net.Conn, destination string, header *pb.FileStream) (*FileStream, error) {
+	key := make([]byte, wire.StreamKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("stream key generation failed: %w", err)
+	}
+	header.Key = key
+
+	payload, err := proto.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("marshal file stream failed: %w", err)
+	}
+	packet, err := s.sealPacket(destination, &pb.Packet{
+		Type:    pb.PacketType_FILE_STREAM,
+		Payload: payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Ключ кадров тела передаётся только внутри E2EE-сессии
+	if len(packet.SessionId) == 0 {
+		return nil, fmt.Errorf("file stream requires an E2EE session")
+	}
+	data, err := proto.Marshal(packet)
+	if err != nil {
+		return nil, fmt.Errorf("marshal failed: %w", err)
+	}
+	if err := s.writePacket(conn, packet, data); err != nil {
+		return nil, fmt.Errorf("send failed: %w", err)
+	}
+
+	w, err := wire.NewStreamWriter(conn, key, ConnectionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStream{conn: conn, w: w, left: header.ChunkCount}, nil
+}
+
+// WriteChunk отправляет очередную часть
+func (f *FileStream) WriteChunk(data []byte) error {
+	if f.left == 0 {
+		return fmt.Errorf("file stream: more chunks than announced")
+	}
+	if len(data) == 0 || len(data) > MaxFileChunkSize {
+		return fmt.Errorf("file stream: bad chunk size %d", len(data))
+	}
+	f.left--
+	return f.w.WriteFrame(data)
+}
+
+// Close завершает поток и закрывает соединение. Если отправлены не все части,
+// завершающий кадр не пишется и получатель считает поток оборванным.
+func (f *FileStream) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	var err error
+	if f.left == 0 {
+		err = f.w.Close()
+	}
+	if errClose := f.conn.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+// receiveFileStream проверяет заголовок потока и передаёт кадры тела обработчику частей по одному
+func (s *Service) receiveFileStream(conn net.Conn, packet *pb.Packet, remoteAddr string) error {
+	// Ключ кадров тела принимаем только зашифрованным (после расшифровки SessionId сбрасывается)
+	if len(packet.SessionId) == 0 {
+		return fmt.Errorf("unencrypted file stream header")
+	}
+	senderPubKey, ok := s.openPacket(packet, remoteAddr, false)
+	if !ok {
+		// Без ключа из заголовка тело не разобрать — соединение закрываем
+		return fmt.Errorf("file stream header rejected")
+	}
+
+	header := &pb.FileStream{}
+	if err := proto.Unmarshal(packet.Payload, header); err != nil {
+		return fmt.Errorf("unmarshal file stream failed: %w", err)
+	}
+	if len(header.Key) != wire.StreamKeySize || header.ChunkCount == 0 || header.StartIndex > math.MaxUint32-header.ChunkCount {
+		return fmt.Errorf("bad file stream header")
+	}
+
+	// Память под одну часть занимаем на всё время потока
+	const reserved = int64(MaxFileChunkSize + wire.StreamOverhead)
+	if !s.limiter.reserve(remoteAddr, reserved) {
+		s.stats.rejectedInFlight.Add(1)
+		return errInFlight
+	}
+	defer s.limiter.release(remoteAddr, reserved)
+
+	r, err := wire.NewStreamReader(conn, header.Key, MaxFileChunkSize, ReadTimeout)
+	if err != nil {
+		return err
+	}
+
+	short := senderPubKey[:min(16, len(senderPubKey))]
+	log.Printf("[Messenger] Receiving %d chunks of %s from %s", header.ChunkCount, header.FileId[:min(8, len(header.FileId))], short)
+	for i := uint32(0); ; i++ {
+		data, err := r.Next()
+		if err == io.EOF {
+			if i != header.ChunkCount {
+				return fmt.Errorf("file stream ended after %d of %d chunks", i, header.ChunkCount)
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("file stream from %s: %w", short, err)
+		}
+		if i >= header.ChunkCount {
+			return fmt.Errorf("file stream from %s: more chunks than announced", short)
+		}
+		if s.IsBlocked(senderPubKey, "") {
+			return errBlockedPeer
+		}
+
+		if s.fileChunkHandler != nil {
+			s.fileChunkHandler(senderPubKey, header.MessageId, header.FileId, header.StartIndex+i, data)
+		}
+	}
+}
+
+// handleFileChunk обрабатывает часть файла
+func (s *Service) handleFileChunk(packet *pb.Packet, senderPubKey string) {
+	chunk := &pb.FileChunk{}
+	if err := proto.Unmarshal(packet.Payload, chunk); err != nil {
+		log.Printf("[Messenger] Failed to unmarshal FileChunk: %v", err)
+		return
+	}
+
+	if len(chunk.Data) == 0 || len(chunk.Data) > MaxFileChunkSize {
+		log.Printf("[Messenger] Rejected file chunk from %s: bad size %d", senderPubKey[:min(16, len(senderPubKey))], len(chunk.Data))
+		return
+	}
+
+	if s.fileChunkHandler != nil {
+		s.fileChunkHandler(senderPubKey, chunk.MessageId, chunk.FileId, chunk.Index, chunk.Data)
+	}
+}
+
+// handleFileChunkAck обрабатывает подтверждение частей
+func (s *Service) handleFileChunkAck(packet *pb.Packet, senderPubKey string) {
+	ack := &pb.FileChunkAck{}
+	if err := proto.Unmarshal(packet.Payload, ack); err != nil {
+		log.Printf("[Messenger] Failed to unmarshal FileChunkAck: %v", err)
+		return
+	}
+
+	if s.fileChunkAckHandler != nil {
+		s.fileChunkAckHandler(senderPubKey, ack.MessageId, ack.FileId, ack.NextIndex)
+	}
+}
+
+// handleFileResume обрабатывает запрос продолжить передачу
+func (s *Service) handleFileResume(packet *pb.Packet, senderPubKey string) {
+	resume := &pb.FileResume{}
+	if err := proto.Unmarshal(packet.Payload, resume); err != nil {
+		log.Printf("[Messenger] Failed to unmarshal FileResume: %v", err)
+		return
+	}
+
+	nextIndex := make(map[string]uint32, len(resume.Files))
+	for _, f := range resume.Files {
+		nextIndex[f.FileId] = f.NextIndex
+	}
+
+	log.Printf("[Messenger] Resume request from %s for %s (%d files)", senderPubKey[:min(16, len(senderPubKey))], resume.MessageId[:min(8, len(resume.MessageId))], len(nextIndex))
+	if s.fileResumeHandler != nil {
+		s.fileResumeHandler(senderPubKey, resume.MessageId, nextIndex)
+	}
+}
+
+// SetFileChunkHandler sets the file chunk handler
+func (s *Service) SetFileChunkHandler(h FileChunkHandler) {
+	s.fileChunkHandler = h
+}
+
+// SetFileChunkAckHandler sets the file chunk ack handler
+func (s *Service) SetFileChunkAckHandler(h FileChunkAckHandler) {
+	s.fileChunkAckHandler = h
+}
+
+// SetFileResumeHandler sets the file resume handler
+func (s *Service) SetFileResumeHandler(h FileResumeHandler) {
+	s.fileResumeHandler = h
+}
@@ -0,0 +1,79 @@
+package messenger
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+
+	pb "teleghost/internal/proto"
+)
+
+// streamChunks отправляет части потоком от alice к bob и возвращает принятые bob части
+func streamChunks(t *testing.T, chunks [][]byte, count uint32) ([]uint32, [][]byte, error) {
+	t.Helper()
+	alice := newTestService(t)
+	bob := newTestService(t)
+	bob.ctx = context.Background()
+	pairSessions(t, alice, bob, "alice-dest", "bob-dest")
+
+	var indexes []uint32
+	var received [][]byte
+	bob.SetFileChunkHandler(func(senderPubKey, messageID, fileID string, index uint32, data []byte) {
+		if senderPubKey != alice.identity.PublicKeyBase64 || messageID != "msg" || fileID != "file" {
+			t.Errorf("Unexpected chunk %s/%s from %s", messageID, fileID, senderPubKey)
+		}
+		indexes = append(indexes, index)
+		received = append(received, data)
+	})
+
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		stream, err := alice.startFileStream(client, "bob-dest", &pb.FileStream{
+			MessageId: "msg", FileId: "file", ChatId: "chat", StartIndex: 5, ChunkCount: count,
+		})
+		if err != nil {
+			t.Errorf("startFileStream failed: %v", err)
+			client.Close()
+			return
+		}
+		for _, c := range chunks {
+			if err := stream.WriteChunk(c); err != nil {
+				t.Errorf("WriteChunk failed: %v", err)
+			}
+		}
+		stream.Close()
+	}()
+
+	err := bob.receivePacket(server, "alice-dest")
+	return indexes, received, err
+}
+
+func TestFileStream(t *testing.T) {
+	chunks := [][]byte{bytes.Repeat([]byte{1}, FileChunkSize), bytes.Repeat([]byte{2}, FileChunkSize), []byte("tail")}
+
+	indexes, received, err := streamChunks(t, chunks, uint32(len(chunks)))
+	if err != nil {
+		t.Fatalf("receivePacket failed: %v", err)
+	}
+	if len(received) != len(chunks) {
+		t.Fatalf("Expected %d chunks, got %d", len(chunks), len(received))
+	}
+	for i := range chunks {
+		if indexes[i] != uint32(5+i) || !bytes.Equal(received[i], chunks[i]) {
+			t.Errorf("Chunk %d mismatch (index %d)", i, indexes[i])
+		}
+	}
+}
+
+func TestFileStreamInterrupted(t *testing.T) {
+	// Заявлено две части, отправлена одна: поток оборван, принятая часть остаётся
+	_, received, err := streamChunks(t, [][]byte{[]byte("first")}, 2)
+	if err == nil {
+		t.Fatal("Interrupted stream reported as complete")
+	}
+	if len(received) != 1 {
+		t.Errorf("Expected 1 chunk before interruption, got %d", len(received))
+	}
+}
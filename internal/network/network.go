@@ -3,18 +3,16 @@ package network
 
 import (
 	"context"
-	"io"
+	"net"
 )
 
-// Connection представляет соединение с пиром
+// Connection представляет соединение с пиром. Это обычный net.Conn (дедлайны нужны
+// кадрированию пакетов), у которого известен I2P адрес собеседника.
 type Connection interface {
-	io.ReadWriteCloser
+	net.Conn
 
-	// RemoteAddr возвращает I2P адрес удалённого пира
-	RemoteAddr() string
-
-	// LocalAddr возвращает наш I2P адрес
-	LocalAddr() string
+	// RemoteDestination возвращает I2P адрес удалённого пира (base64)
+	RemoteDestination() string
 }
 
 // NetworkRouter — интерфейс для работы с I2P сетью
@@ -0,0 +1,79 @@
+package router
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strings"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+// LeaseSetEncrypted — i2cp.leaseSetType зашифрованного LS2. В netDb он лежит под ослеплённым
+// ключом, который меняется каждые сутки: найти его может только тот, кто знает наш b33 адрес,
+// а с LeaseSetAuth — ещё и держит ключ из LeaseSetClients.
+const LeaseSetEncrypted = 5
+
+const (
+	// sigTypeEd25519, sigTypeRedDSA — тип подписи destination и его ослеплённой версии
+	sigTypeEd25519 = 7
+	sigTypeRedDSA  = 11
+
+	// certKey — сертификат ключа: в нём записан тип подписи destination
+	certKey = 5
+
+	// b33FlagAuth — флаг b33 адреса: leaseSet открыт только авторизованным клиентам
+	b33FlagAuth = 0x04
+
+	// b32Length — длина обычного b32 адреса без .b32.i2p; b33 длиннее
+	b32Length = 52
+)
+
+var (
+	b32Encoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+	// i2pBase64 — base64 с алфавитом I2P (в нём роутер ждёт ключи авторизации)
+	i2pBase64 = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-~")
+)
+
+// BlindedAddress возвращает b33 адрес destination для зашифрованного leaseSet.
+// clientAuth выставляет в адресе флаг авторизации клиентов.
+func BlindedAddress(destination string, clientAuth bool) (string, error) {
+	key, err := signingKey(destination)
+	if err != nil {
+		return "", err
+	}
+
+	// флаги, тип подписи, тип ослеплённой подписи, ключ; CRC32 ключа маскирует первые три байта
+	data := []byte{0, sigTypeEd25519, sigTypeRedDSA}
+	if clientAuth {
+		data[0] = b33FlagAuth
+	}
+	data = append(data, key...)
+	sum := crc32.ChecksumIEEE(data[3:])
+	data[0] ^= byte(sum)
+	data[1] ^= byte(sum >> 8)
+	data[2] ^= byte(sum >> 16)
+	return b32Encoding.EncodeToString(data) + ".b32.i2p", nil
+}
+
+// IsBlindedAddress проверяет, что имя — b33 адрес, а не обычный b32
+func IsBlindedAddress(name string) bool {
+	label, ok := strings.CutSuffix(strings.ToLower(name), ".b32.i2p")
+	return ok && len(label) > b32Length
+}
+
+// signingKey достаёт из destination ключ подписи Ed25519: ослепить можно только его
+func signingKey(destination string) ([]byte, error) {
+	raw, err := i2pkeys.I2PAddr(destination).ToBytes()
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination: %w", err)
+	}
+	// 256 байт ключа шифрования, 128 байт под ключ подписи (выровнен вправо), сертификат
+	if len(raw) < 391 || raw[384] != certKey || binary.BigEndian.Uint16(raw[387:389]) != sigTypeEd25519 {
+		return nil, fmt.Errorf("destination is not Ed25519")
+	}
+	return raw[352:384], nil
+}
@@ -0,0 +1,53 @@
+package router
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+// testDestination собирает destination с сертификатом ключа заданного типа подписи
+func testDestination(sigType byte) string {
+	raw := make([]byte, 391)
+	for i := range raw {
+		raw[i] = byte(i)
+	}
+	copy(raw[384:], []byte{certKey, 0, 4, 0, sigType, 0, 0})
+	addr, _ := i2pkeys.NewI2PAddrFromBytes(raw)
+	return addr.Base64()
+}
+
+func TestBlindedAddress(t *testing.T) {
+	dest := testDestination(sigTypeEd25519)
+	open, err := BlindedAddress(dest, false)
+	if err != nil {
+		t.Fatalf("BlindedAddress failed: %v", err)
+	}
+	auth, _ := BlindedAddress(dest, true)
+
+	// 35 байт: флаги, два типа подписи, ключ
+	if len(open) != 56+len(".b32.i2p") || !IsBlindedAddress(open) || !IsBlindedAddress(strings.ToUpper(auth)) {
+		t.Errorf("Unexpected b33 address %q", open)
+	}
+	if open == auth {
+		t.Error("Client auth flag does not change the address")
+	}
+	// Контрольная сумма маскирует флаги: меняются только первые символы
+	if open[6:] != auth[6:] {
+		t.Errorf("Addresses differ beyond the header: %q, %q", open, auth)
+	}
+	if again, _ := BlindedAddress(dest, false); again != open {
+		t.Error("BlindedAddress is not deterministic")
+	}
+
+	if IsBlindedAddress(i2pkeys.I2PAddr(dest).Base32()) || IsBlindedAddress(dest) {
+		t.Error("Regular address taken for b33")
+	}
+	if _, err := BlindedAddress(testDestination(0), false); err == nil {
+		t.Error("Blinded a DSA destination")
+	}
+	if _, err := BlindedAddress("not a destination", false); err == nil {
+		t.Error("Blinded garbage")
+	}
+}
@@ -0,0 +1,66 @@
+package router
+
+// Profile — профиль защиты: параметры туннелей и тип leaseSet, одинаковые для входящих и исходящих
+type Profile struct {
+	Name           string
+	TunnelLength   int
+	TunnelQuantity int
+	TunnelVariance int
+	BackupQuantity int
+	LeaseSetType   int
+	LeaseSetAuth   bool
+}
+
+// Имена профилей; ProfileCustom — параметры заданы вручную
+const (
+	ProfileFast      = "fast"
+	ProfileDefault   = "default"
+	ProfileInvisible = "invisible"
+	ProfileCustom    = "custom"
+)
+
+// LeaseSet2 — i2cp.leaseSetType стандартного LS2, открытого в netDb
+const LeaseSet2 = 3
+
+// profiles — встроенные профили: Fast жертвует анонимностью ради задержек, Invisible — наоборот.
+// Invisible шифрует leaseSet для контактов, приславших ключ в handshake: новый собеседник
+// не сможет нам ответить, пока мы в этом профиле.
+var profiles = map[string]Profile{
+	ProfileFast: {
+		Name:           ProfileFast,
+		TunnelLength:   1,
+		TunnelQuantity: 2,
+		LeaseSetType:   LeaseSet2,
+	},
+	ProfileDefault: {
+		Name:           ProfileDefault,
+		TunnelLength:   3,
+		TunnelQuantity: 3,
+		BackupQuantity: 1,
+		LeaseSetType:   LeaseSet2,
+	},
+	ProfileInvisible: {
+		Name:           ProfileInvisible,
+		TunnelLength:   4,
+		TunnelQuantity: 4,
+		TunnelVariance: 1,
+		BackupQuantity: 2,
+		LeaseSetType:   LeaseSetEncrypted,
+		LeaseSetAuth:   true,
+	},
+}
+
+// LookupProfile возвращает встроенный профиль по имени
+func LookupProfile(name string) (Profile, bool) {
+	p, ok := profiles[name]
+	return p, ok
+}
+
+// Apply переносит параметры профиля в конфигурацию
+func (p Profile) Apply(c *Config) {
+	c.InboundLength, c.OutboundLength = p.TunnelLength, p.TunnelLength
+	c.InboundQuantity, c.OutboundQuantity = p.TunnelQuantity, p.TunnelQuantity
+	c.InboundVariance, c.OutboundVariance = p.TunnelVariance, p.TunnelVariance
+	c.InboundBackupQuantity, c.OutboundBackupQuantity = p.BackupQuantity, p.BackupQuantity
+	c.LeaseSetType, c.LeaseSetAuth = p.LeaseSetType, p.LeaseSetAuth
+}
@@ -2,13 +2,18 @@
 package router
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
 	"net"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"teleghost/internal/network"
+
 	"github.com/go-i2p/i2pkeys"
 	"github.com/go-i2p/sam3"
 )
@@ -54,10 +59,59 @@ type Config struct {
 	// OutboundQuantity — количество исходящих туннелей
 	OutboundQuantity int
 
+	// InboundVariance, OutboundVariance — случайный разброс длины туннелей (в хопах)
+	InboundVariance  int
+	OutboundVariance int
+
+	// InboundBackupQuantity, OutboundBackupQuantity — запасные туннели
+	InboundBackupQuantity  int
+	OutboundBackupQuantity int
+
+	// LeaseSetType — i2cp.leaseSetType (0 — по умолчанию роутера)
+	LeaseSetType int
+
+	// LeaseSetAuth — зашифрованный leaseSet открыт только LeaseSetClients
+	LeaseSetAuth bool
+
+	// LeaseSetClients — X25519 ключи контактов, которым открыт наш leaseSet
+	LeaseSetClients [][]byte
+
+	// LeaseSetPrivKey — наш X25519 ключ для чужих leaseSet с авторизацией
+	LeaseSetPrivKey []byte
+
 	// UseNTCP2Only — использовать только NTCP2 (SSU2 нестабилен)
 	UseNTCP2Only bool
+
+	// ConnectAttempts — сколько раз пробовать подключиться к SAM (0 — по умолчанию)
+	ConnectAttempts int
+
+	// RetryDelay — пауза между попытками подключения (0 — по умолчанию)
+	RetryDelay time.Duration
 }
 
+const (
+	defaultConnectAttempts = 30
+	defaultRetryDelay      = 3 * time.Second
+
+	// maxRetryDelay — предел паузы между попытками восстановить сессию
+	maxRetryDelay = time.Minute
+)
+
+// SessionState — состояние SAM сессии, о котором сообщает супервизор
+type SessionState string
+
+const (
+	// SessionOnline — сессия (вос)создана
+	SessionOnline SessionState = "online"
+	// SessionReconnecting — сессия потеряна, идёт восстановление
+	SessionReconnecting SessionState = "reconnecting"
+	// SessionError — попытка восстановления не удалась, следующая — после паузы
+	SessionError SessionState = "error"
+)
+
+// StateHandler получает смену состояния сессии; err — причина потери или сбоя
+type StateHandler func(state SessionState, err error)
+
 // DefaultConfig возвращает конфигурацию по умолчанию
 func DefaultConfig() *Config {
 	return &Config{
@@ -69,17 +123,35 @@ func DefaultConfig() *Config {
 		InboundQuantity:  2,
 		OutboundQuantity: 2,
 		UseNTCP2Only:     true,
+		ConnectAttempts:  defaultConnectAttempts,
+		RetryDelay:       defaultRetryDelay,
 	}
 }
 
+// retryPolicy возвращает число попыток подключения к SAM и паузу между ними
+func (c *Config) retryPolicy() (int, time.Duration) {
+	attempts, delay := c.ConnectAttempts, c.RetryDelay
+	if attempts <= 0 {
+		attempts = defaultConnectAttempts
+	}
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+	return attempts, delay
+}
+
 // SAMRouter — реализация роутера через SAM API
 type SAMRouter struct {
 	config      *Config
-	sam         *sam3.SAM
+	sam         *sam3.SAM // управляющее соединение сессии
+	resolver    *sam3.SAM // отдельное соединение для NAMING LOOKUP
+	lookupMu    sync.Mutex
 	session     *sam3.StreamSession
 	keys        i2pkeys.I2PKeys
 	destination string
 	listener    *sam3.StreamListener
+	blinded     map[string]string // destination → b33 адрес собеседника с зашифрованным leaseSet
+	onState     StateHandler
 	ready       bool
 	mu          sync.RWMutex
 	ctx         context.Context
@@ -92,7 +164,8 @@ func NewSAMRouter(config *Config) *SAMRouter {
 		config = DefaultConfig()
 	}
 	return &SAMRouter{
-		config: config,
+		config:  config,
+		blinded: make(map[string]string),
 	}
 }
 
@@ -102,32 +175,9 @@ func (r *SAMRouter) Start(ctx context.Context) error {
 	r.ctx, r.cancel = context.WithCancel(ctx)
 	r.mu.Unlock() // Разблокируем сразу, чтобы можно было вызвать Stop()
 
-	// Пробуем подключиться к SAM с ретраями
-	// Используем r.config.SAMAddress без лока (он не меняется)
-	var samConn *sam3.SAM
-	var err error
-
-	log.Printf("[SAMRouter] Connecting to SAM at %s...", r.config.SAMAddress)
-	for i := 0; i < 30; i++ {
-		// Проверяем отмену перед попыткой
-		if r.ctx.Err() != nil {
-			return r.ctx.Err()
-		}
-
-		samConn, err = sam3.NewSAM(r.config.SAMAddress)
-		if err == nil {
-			break
-		}
-		log.Printf("[SAMRouter] Attempt %d failed: %v. Retrying in 3s...", i+1, err)
-		select {
-		case <-r.ctx.Done():
-			return r.ctx.Err()
-		case <-time.After(3 * time.Second):
-		}
-	}
-
+	samConn, err := r.connect(r.ctx)
 	if err != nil {
-		return fmt.Errorf("failed to connect to SAM at %s: %w", r.config.SAMAddress, err)
+		return err
 	}
 
 	// Сохраняем samConn сразу, чтобы Stop мог его закрыть
@@ -178,17 +228,6 @@ func (r *SAMRouter) Start(ctx context.Context) error {
 		return r.ctx.Err()
 	}
 
-	// Формируем опции для сессии
-	// Формируем опции для сессии
-	opts := []string{
-		fmt.Sprintf("inbound.length=%d", r.config.InboundLength),
-		fmt.Sprintf("outbound.length=%d", r.config.OutboundLength),
-		fmt.Sprintf("inbound.quantity=%d", r.config.InboundQuantity),
-		fmt.Sprintf("outbound.quantity=%d", r.config.OutboundQuantity),
-		"inbound.allowZeroHop=true",
-		"outbound.allowZeroHop=true",
-	}
-
 	// Создаём Streaming сессию
 	log.Printf("[SAMRouter] Creating stream session '%s'...", r.config.SessionName)
 
@@ -197,7 +236,7 @@ func (r *SAMRouter) Start(ctx context.Context) error {
 	currentKeys := r.keys
 	r.mu.RUnlock()
 
-	session, errSession := samConn.NewStreamSession(r.config.SessionName, currentKeys, opts)
+	session, errSession := samConn.NewStreamSession(r.config.SessionName, currentKeys, r.sessionOptions())
 	if errSession != nil {
 		r.mu.Lock()
 		defer r.mu.Unlock()
@@ -218,11 +257,258 @@ func (r *SAMRouter) Start(ctx context.Context) error {
 
 	r.session = session
 	r.ready = true
+	go r.supervise(r.ctx, session)
 
 	log.Printf("[SAMRouter] Session established")
 	return nil
 }
 
+// connect подключается к SAM bridge, повторяя попытки по Config
+func (r *SAMRouter) connect(ctx context.Context) (*sam3.SAM, error) {
+	// Используем r.config.SAMAddress без лока (он не меняется)
+	attempts, delay := r.config.retryPolicy()
+	var err error
+
+	log.Printf("[SAMRouter] Connecting to SAM at %s...", r.config.SAMAddress)
+	for i := 0; i < attempts; i++ {
+		// Проверяем отмену перед попыткой
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		samConn, errConn := sam3.NewSAM(r.config.SAMAddress)
+		if errConn == nil {
+			return samConn, nil
+		}
+		err = errConn
+		log.Printf("[SAMRouter] Attempt %d failed: %v. Retrying in %s...", i+1, err, delay)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	return nil, fmt.Errorf("failed to connect to SAM at %s: %w", r.config.SAMAddress, err)
+}
+
+// sessionOptions — опции туннелей для SESSION CREATE (параметры туннелей меняет Reconfigure)
+func (r *SAMRouter) sessionOptions() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c := r.config
+
+	opts := []string{
+		fmt.Sprintf("inbound.length=%d", c.InboundLength),
+		fmt.Sprintf("outbound.length=%d", c.OutboundLength),
+		fmt.Sprintf("inbound.quantity=%d", c.InboundQuantity),
+		fmt.Sprintf("outbound.quantity=%d", c.OutboundQuantity),
+		fmt.Sprintf("inbound.lengthVariance=%d", c.InboundVariance),
+		fmt.Sprintf("outbound.lengthVariance=%d", c.OutboundVariance),
+		fmt.Sprintf("inbound.backupQuantity=%d", c.InboundBackupQuantity),
+		fmt.Sprintf("outbound.backupQuantity=%d", c.OutboundBackupQuantity),
+		"inbound.allowZeroHop=true",
+		"outbound.allowZeroHop=true",
+	}
+	if c.LeaseSetType > 0 {
+		opts = append(opts, fmt.Sprintf("i2cp.leaseSetType=%d", c.LeaseSetType))
+	}
+	// Авторизация по X25519 (тип 1): каждый клиент — отдельная опция "имя:ключ"
+	if c.LeaseSetType == LeaseSetEncrypted && c.LeaseSetAuth {
+		opts = append(opts, "i2cp.leaseSetAuthType=1")
+		for i, key := range c.LeaseSetClients {
+			opts = append(opts, fmt.Sprintf("i2cp.leaseSetClient.dh.%d=c%d:%s", i, i, i2pBase64.EncodeToString(key)))
+		}
+	}
+	if len(c.LeaseSetPrivKey) > 0 {
+		opts = append(opts, "i2cp.leaseSetPrivKey="+i2pBase64.EncodeToString(c.LeaseSetPrivKey))
+	}
+	return opts
+}
+
+// Reconfigure применяет параметры туннелей из tunnels на ходу: текущая сессия закрывается,
+// супервизор пересоздаёт её с теми же ключами, так что адрес не меняется. Возвращается сразу;
+// о восстановлении сообщит StateHandler.
+func (r *SAMRouter) Reconfigure(tunnels *Config) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.config
+	c.InboundLength, c.OutboundLength = tunnels.InboundLength, tunnels.OutboundLength
+	c.InboundQuantity, c.OutboundQuantity = tunnels.InboundQuantity, tunnels.OutboundQuantity
+	c.InboundVariance, c.OutboundVariance = tunnels.InboundVariance, tunnels.OutboundVariance
+	c.InboundBackupQuantity, c.OutboundBackupQuantity = tunnels.InboundBackupQuantity, tunnels.OutboundBackupQuantity
+	c.LeaseSetType, c.LeaseSetAuth = tunnels.LeaseSetType, tunnels.LeaseSetAuth
+
+	if r.session == nil || !r.ready {
+		// Не запущен или уже переподключается: новые параметры подхватит следующая сессия
+		return
+	}
+	log.Printf("[SAMRouter] Applying new tunnel settings: length %d/%d, quantity %d/%d",
+		c.InboundLength, c.OutboundLength, c.InboundQuantity, c.OutboundQuantity)
+	r.ready = false
+	_ = r.session.Close()
+}
+
+// SetLeaseSetClients задаёт ключи контактов, которым открыт зашифрованный leaseSet. Список
+// входит в опции сессии, поэтому при LeaseSetAuth сессия пересоздаётся, как в Reconfigure.
+func (r *SAMRouter) SetLeaseSetClients(keys [][]byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.config
+	if slices.EqualFunc(c.LeaseSetClients, keys, bytes.Equal) {
+		return
+	}
+	c.LeaseSetClients = keys
+
+	if r.session == nil || !r.ready || c.LeaseSetType != LeaseSetEncrypted || !c.LeaseSetAuth {
+		return
+	}
+	log.Printf("[SAMRouter] Updating leaseSet clients (%d)", len(keys))
+	r.ready = false
+	_ = r.session.Close()
+}
+
+// GetBlindedAddress возвращает наш b33 адрес, если leaseSet зашифрован, иначе ""
+func (r *SAMRouter) GetBlindedAddress() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.config.LeaseSetType != LeaseSetEncrypted || r.destination == "" {
+		return ""
+	}
+	addr, err := BlindedAddress(r.destination, r.config.LeaseSetAuth)
+	if err != nil {
+		log.Printf("[SAMRouter] Cannot encrypt leaseSet: %v", err)
+		return ""
+	}
+	return addr
+}
+
+// SetPeerBlindedAddress запоминает b33 адрес собеседника: Dial пойдёт к нему по b33.
+// Пустой blinded забывает адрес.
+func (r *SAMRouter) SetPeerBlindedAddress(destination, blinded string) error {
+	if blinded != "" {
+		auth, err := BlindedAddress(destination, true)
+		if err != nil {
+			return err
+		}
+		open, _ := BlindedAddress(destination, false)
+		if blinded = strings.ToLower(blinded); blinded != auth && blinded != open {
+			return fmt.Errorf("blinded address does not match destination")
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if blinded == "" {
+		delete(r.blinded, destination)
+	} else {
+		r.blinded[destination] = blinded
+	}
+	return nil
+}
+
+// supervise следит за управляющим сокетом сессии. SAM закрывает его, когда сессия уничтожена
+// (перезапуск или сбой роутера); тогда сессия пересоздаётся с теми же ключами.
+func (r *SAMRouter) supervise(ctx context.Context, session *sam3.StreamSession) {
+	buf := make([]byte, 256)
+	for {
+		var err error
+		for err == nil {
+			_, err = session.Read(buf)
+		}
+
+		r.mu.Lock()
+		if ctx.Err() != nil {
+			r.mu.Unlock()
+			return
+		}
+		r.ready = false
+		r.mu.Unlock()
+
+		log.Printf("[SAMRouter] Session lost: %v. Re-creating...", err)
+		r.notify(ctx, SessionReconnecting, err)
+
+		if session = r.reopen(ctx); session == nil {
+			return
+		}
+		log.Printf("[SAMRouter] Session re-established")
+		r.notify(ctx, SessionOnline, nil)
+	}
+}
+
+// reopen пересоздаёт сессию, удваивая паузу после каждой неудачи. nil — роутер остановлен.
+func (r *SAMRouter) reopen(ctx context.Context) *sam3.StreamSession {
+	_, delay := r.config.retryPolicy()
+	for {
+		session, err := r.openSession(ctx)
+		if err == nil {
+			return session
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		log.Printf("[SAMRouter] Failed to re-create session: %v. Retrying in %s...", err, delay)
+		r.notify(ctx, SessionError, err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// openSession подключается к SAM и создаёт сессию с текущими ключами
+func (r *SAMRouter) openSession(ctx context.Context) (*sam3.StreamSession, error) {
+	samConn, err := sam3.NewSAM(r.config.SAMAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	keys := r.keys
+	r.mu.RUnlock()
+
+	session, err := samConn.NewStreamSession(r.config.SessionName, keys, r.sessionOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ctx.Err() != nil {
+		_ = session.Close()
+		return nil, ctx.Err()
+	}
+	// Старый listener привязан к уничтоженной сессии
+	r.listener = nil
+	if r.session != nil {
+		_ = r.session.Close()
+	}
+	r.sam = samConn
+	r.session = session
+	r.ready = true
+	return session, nil
+}
+
+// SetStateHandler задаёт обработчик смены состояния сессии (вызывается из горутины супервизора)
+func (r *SAMRouter) SetStateHandler(handler StateHandler) {
+	r.mu.Lock()
+	r.onState = handler
+	r.mu.Unlock()
+}
+
+func (r *SAMRouter) notify(ctx context.Context, state SessionState, err error) {
+	r.mu.RLock()
+	handler := r.onState
+	r.mu.RUnlock()
+	if handler != nil && ctx.Err() == nil {
+		handler(state, err)
+	}
+}
+
 // SetKeys устанавливает I2P ключи
 func (r *SAMRouter) SetKeys(keys i2pkeys.I2PKeys) {
 	r.mu.Lock()
@@ -261,6 +547,11 @@ func (r *SAMRouter) Stop() error {
 		r.sam = nil
 	}
 
+	if r.resolver != nil {
+		_ = r.resolver.Close()
+		r.resolver = nil
+	}
+
 	log.Printf("[SAMRouter] Stopped")
 	return nil
 }
@@ -302,51 +593,30 @@ func (r *SAMRouter) GetKeys() i2pkeys.I2PKeys {
 func (r *SAMRouter) Dial(destination string) (net.Conn, error) {
 	r.mu.RLock()
 	session := r.session
-	samConn := r.sam
+	ready := r.ready
+	blinded := r.blinded[destination]
 	r.mu.RUnlock()
 
-	if session == nil || samConn == nil {
+	if session == nil {
 		return nil, fmt.Errorf("router not started")
 	}
+	if !ready {
+		return nil, fmt.Errorf("SAM session is reconnecting")
+	}
+	if blinded == "" && IsBlindedAddress(destination) {
+		blinded = destination
+	}
 
-	// Parse destination address
-	// Point 6: Ensure SAM connection is still alive, reconnect if needed
-	var addr i2pkeys.I2PAddr
+	// Полный адрес разбираем сами, в SAM идут только имена (.b32.i2p, .i2p). Зашифрованный
+	// leaseSet ищет сама сессия по b33: NAMING LOOKUP идёт без нашего ключа авторизации.
+	addr := i2pkeys.I2PAddr(blinded)
 	var err error
-
-	for i := 0; i < 2; i++ {
-		addr, err = samConn.Lookup(destination)
-		if err == nil {
-			break
-		}
-
-		// If lookup fails, maybe SAM connection is stale
-		if i == 0 {
-			log.Printf("[SAMRouter] Lookup failed: %v. Attempting to refresh SAM connection...", err)
-			// We can't easily recreate the whole session here without locking everything,
-			// but we can try to re-create the SAM bridge connection if possible.
-			// Actually, if Lookup fails, it's likely the sam socket is dead.
-			// Try to recreate sam3.SAM
-			newSam, samErr := sam3.NewSAM(r.config.SAMAddress)
-			if samErr == nil {
-				r.mu.Lock()
-				_ = r.sam.Close()
-				r.sam = newSam
-				samConn = newSam
-				r.mu.Unlock()
-				continue
+	if blinded == "" {
+		if addr, err = i2pkeys.NewI2PAddrFromString(destination); err != nil {
+			if addr, err = r.lookup(destination); err != nil {
+				return nil, fmt.Errorf("invalid destination: %w", err)
 			}
 		}
-
-		// Try direct parsing as fallback
-		addr, err = i2pkeys.NewI2PAddrFromString(destination)
-		if err == nil {
-			break
-		}
-	}
-
-	if err != nil {
-		return nil, fmt.Errorf("invalid destination: %w", err)
 	}
 
 	conn, err := session.DialI2P(addr)
@@ -357,6 +627,49 @@ func (r *SAMRouter) Dial(destination string) (net.Conn, error) {
 	return conn, nil
 }
 
+// lookup разрешает имя через отдельное соединение с SAM. Сокет сессии для этого не годится:
+// закрыв его после сбоя, мы уничтожили бы и сессию. Упавшее соединение пересоздаётся один раз.
+func (r *SAMRouter) lookup(name string) (i2pkeys.I2PAddr, error) {
+	r.lookupMu.Lock()
+	defer r.lookupMu.Unlock()
+
+	var err error
+	for i := 0; i < 2; i++ {
+		r.mu.RLock()
+		resolver := r.resolver
+		r.mu.RUnlock()
+
+		if resolver == nil {
+			if resolver, err = sam3.NewSAM(r.config.SAMAddress); err != nil {
+				return "", err
+			}
+			r.mu.Lock()
+			if !r.ready {
+				r.mu.Unlock()
+				_ = resolver.Close()
+				return "", fmt.Errorf("router not started")
+			}
+			r.resolver = resolver
+			r.mu.Unlock()
+		}
+
+		var addr i2pkeys.I2PAddr
+		if addr, err = resolver.Lookup(name); err == nil {
+			return addr, nil
+		}
+
+		// Возможно, соединение устарело: закрываем и пробуем через новое
+		log.Printf("[SAMRouter] Lookup of %s failed: %v", name, err)
+		r.mu.Lock()
+		if r.resolver == resolver {
+			r.resolver = nil
+		}
+		r.mu.Unlock()
+		_ = resolver.Close()
+	}
+	return "", err
+}
+
 // Listen создаёт listener для входящих соединений
 func (r *SAMRouter) Listen() (*sam3.StreamListener, error) {
 	r.mu.Lock()
@@ -365,6 +678,9 @@ func (r *SAMRouter) Listen() (*sam3.StreamListener, error) {
 	if r.session == nil {
 		return nil, fmt.Errorf("router not started")
 	}
+	if !r.ready {
+		return nil, fmt.Errorf("SAM session is reconnecting")
+	}
 
 	if r.listener == nil {
 		listener, err := r.session.Listen()
@@ -377,12 +693,81 @@ func (r *SAMRouter) Listen() (*sam3.StreamListener, error) {
 	return r.listener, nil
 }
 
-// Accept ожидает входящее соединение
-func (r *SAMRouter) Accept() (net.Conn, error) {
+// Accept ожидает входящее соединение (network.NetworkRouter). SAM listener не знает о контексте,
+// поэтому при отмене ctx ожидание бросается, а пришедшее позже соединение закрывается.
+func (r *SAMRouter) Accept(ctx context.Context) (network.Connection, error) {
 	listener, err := r.Listen()
 	if err != nil {
 		return nil, err
 	}
 
-	return listener.Accept()
+	type accepted struct {
+		conn net.Conn
+		err  error
+	}
+	ch := make(chan accepted, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			// sam3 возвращает типизированный nil: такой net.Conn не равен nil
+			conn = nil
+		}
+		ch <- accepted{conn, err}
+	}()
+
+	select {
+	case res := <-ch:
+		if res.err != nil {
+			return nil, res.err
+		}
+		return &samConn{Conn: res.conn}, nil
+	case <-ctx.Done():
+		go func() {
+			if res := <-ch; res.conn != nil {
+				_ = res.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// Connect устанавливает соединение с пиром (network.NetworkRouter)
+func (r *SAMRouter) Connect(ctx context.Context, destination string) (network.Connection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	conn, err := r.Dial(destination)
+	if err != nil {
+		return nil, err
+	}
+	return &samConn{Conn: conn, remote: destination}, nil
+}
+
+// GetAddress возвращает наш I2P Destination (network.NetworkRouter)
+func (r *SAMRouter) GetAddress() (string, error) {
+	dest := r.GetDestination()
+	if dest == "" {
+		return "", fmt.Errorf("router not started")
+	}
+	return dest, nil
 }
+
+// samConn — SAM поток с известным адресом собеседника
+type samConn struct {
+	net.Conn
+	remote string
+}
+
+// RemoteDestination возвращает адрес собеседника: для исходящих — тот, что набрали (SAM знает
+// только b33, если leaseSet зашифрован), для входящих — из SAM
+func (c *samConn) RemoteDestination() string {
+	if c.remote != "" {
+		return c.remote
+	}
+	if addr, ok := c.Conn.RemoteAddr().(interface{ Base64() string }); ok {
+		return addr.Base64()
+	}
+	return c.Conn.RemoteAddr().String()
+}
+
+var _ network.NetworkRouter = (*SAMRouter)(nil)
@@ -0,0 +1,438 @@
+package router
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"io"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"teleghost/internal/core"
+	"teleghost/internal/core/identity"
+	"teleghost/internal/network/envelope"
+	"teleghost/internal/network/messenger"
+	"teleghost/internal/network/router/samtest"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+// newBridge поднимает SAM мост на стандартном адресе: на нём sam3 открывает потоки
+func newBridge(t *testing.T) *samtest.Server {
+	t.Helper()
+	bridge, err := samtest.NewServer(samtest.DefaultAddress)
+	if err != nil {
+		t.Skipf("SAM port is busy: %v", err)
+	}
+	t.Cleanup(func() { _ = bridge.Close() })
+	return bridge
+}
+
+// startRouter запускает роутер на мосту; configure дополняет конфигурацию
+func startRouter(t *testing.T, samAddr, name string, configure ...func(*Config)) *SAMRouter {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.SAMAddress = samAddr
+	cfg.SessionName = name
+	cfg.RetryDelay = 50 * time.Millisecond
+	for _, f := range configure {
+		f(cfg)
+	}
+	r := NewSAMRouter(cfg)
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { _ = r.Stop() })
+	return r
+}
+
+// exchange открывает поток от a к адресу и проверяет, что b получил данные от a
+func exchange(t *testing.T, a, b *SAMRouter, address string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	type result struct {
+		remote string
+		data   string
+		err    error
+	}
+	accepted := make(chan result, 1)
+	go func() {
+		conn, err := b.Accept(ctx)
+		if err != nil {
+			accepted <- result{err: err}
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(conn, buf)
+		accepted <- result{remote: conn.RemoteDestination(), data: string(buf), err: err}
+	}()
+
+	conn, err := a.Connect(ctx, address)
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	res := <-accepted
+	if res.err != nil {
+		t.Fatalf("Accept failed: %v", res.err)
+	}
+	if res.data != "ping" {
+		t.Errorf("Received %q", res.data)
+	}
+	if want, _ := a.GetAddress(); res.remote != want {
+		t.Error("Remote destination does not match the dialer")
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting: %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSAMRouterStartRetries(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	// Мост появляется, пока роутер повторяет попытки
+	cfg := DefaultConfig()
+	cfg.SAMAddress = addr
+	cfg.SessionName = "late-bridge"
+	cfg.RetryDelay = 50 * time.Millisecond
+	r := NewSAMRouter(cfg)
+	defer r.Stop()
+	started := make(chan error, 1)
+	go func() { started <- r.Start(context.Background()) }()
+
+	time.Sleep(200 * time.Millisecond)
+	bridge, err := samtest.NewServer(addr)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	defer bridge.Close()
+
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Start did not finish")
+	}
+	if !r.IsReady() || !slices.Contains(bridge.Sessions(), "late-bridge") {
+		t.Fatal("Session not created")
+	}
+	if _, err := i2pkeys.NewI2PAddrFromString(r.GetDestination()); err != nil {
+		t.Errorf("Invalid destination: %v", err)
+	}
+
+	// Без моста попытки заканчиваются ошибкой
+	cfg = DefaultConfig()
+	cfg.SAMAddress = "127.0.0.1:1"
+	cfg.ConnectAttempts = 2
+	cfg.RetryDelay = 10 * time.Millisecond
+	if err := NewSAMRouter(cfg).Start(context.Background()); err == nil {
+		t.Error("Start succeeded without a bridge")
+	}
+}
+
+func TestSAMRouterDialAccept(t *testing.T) {
+	bridge := newBridge(t)
+	alice := startRouter(t, bridge.Addr(), "alice")
+	bob := startRouter(t, bridge.Addr(), "bob")
+
+	bobDest, _ := bob.GetAddress()
+	exchange(t, alice, bob, bobDest)
+	// b32 разрешается через NAMING LOOKUP
+	exchange(t, alice, bob, i2pkeys.I2PAddr(bobDest).Base32())
+
+	if _, err := alice.Dial("unknown.b32.i2p"); err == nil {
+		t.Error("Dialed an unknown name")
+	}
+}
+
+func TestSAMRouterLookupFailure(t *testing.T) {
+	bridge := newBridge(t)
+	alice := startRouter(t, bridge.Addr(), "alice")
+	bob := startRouter(t, bridge.Addr(), "bob")
+	bobDest, _ := bob.GetAddress()
+	b32 := i2pkeys.I2PAddr(bobDest).Base32()
+
+	// Один сбой переживается повтором через новое соединение
+	bridge.FailLookups(1)
+	exchange(t, alice, bob, b32)
+
+	// Соединение для разрешения имён оборвано — тоже
+	bridge.DropControl()
+	waitFor(t, "sessions restored", func() bool { return len(bridge.Sessions()) == 2 })
+	exchange(t, alice, bob, b32)
+
+	bridge.FailLookups(2)
+	if _, err := alice.Dial(b32); err == nil {
+		t.Error("Dial succeeded despite failed lookups")
+	}
+	// Полный адрес в SAM не разрешается
+	exchange(t, alice, bob, bobDest)
+}
+
+// stateLog записывает состояния, о которых сообщает супервизор
+type stateLog struct {
+	mu     sync.Mutex
+	states []SessionState
+}
+
+func (l *stateLog) handle(state SessionState, _ error) {
+	l.mu.Lock()
+	l.states = append(l.states, state)
+	l.mu.Unlock()
+}
+
+func (l *stateLog) get() []SessionState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.states)
+}
+
+func TestSAMRouterSessionRestore(t *testing.T) {
+	bridge := newBridge(t)
+	alice := startRouter(t, bridge.Addr(), "alice")
+	bob := startRouter(t, bridge.Addr(), "bob")
+	aliceDest, _ := alice.GetAddress()
+	bobDest, _ := bob.GetAddress()
+	var states stateLog
+	alice.SetStateHandler(states.handle)
+
+	// Мост потерял сессии: роутеры пересоздают их с теми же адресами
+	bridge.DropControl()
+	if len(bridge.Sessions()) != 0 {
+		t.Fatal("Sessions survived the drop")
+	}
+	waitFor(t, "sessions restored", func() bool { return len(bridge.Sessions()) == 2 })
+	waitFor(t, "online state", func() bool {
+		return slices.Equal(states.get(), []SessionState{SessionReconnecting, SessionOnline}) && bob.IsReady()
+	})
+	if got, _ := alice.GetAddress(); got != aliceDest {
+		t.Error("Address changed after restore")
+	}
+	exchange(t, alice, bob, bobDest)
+
+	// После Stop сессия не возвращается
+	_ = bob.Stop()
+	waitFor(t, "session closed", func() bool { return len(bridge.Sessions()) == 1 })
+	time.Sleep(200 * time.Millisecond)
+	if len(bridge.Sessions()) != 1 {
+		t.Error("Stopped router re-created its session")
+	}
+}
+
+func TestSAMRouterBridgeRestart(t *testing.T) {
+	bridge := newBridge(t)
+	alice := startRouter(t, bridge.Addr(), "alice")
+	var states stateLog
+	alice.SetStateHandler(states.handle)
+
+	// Мост пропал: роутер не готов и повторяет попытки с растущей паузой
+	_ = bridge.Close()
+	waitFor(t, "failed attempts", func() bool {
+		return slices.Contains(states.get(), SessionError)
+	})
+	if alice.IsReady() {
+		t.Error("Router ready without a bridge")
+	}
+	if _, err := alice.Dial(alice.GetDestination()); err == nil {
+		t.Error("Dial succeeded without a session")
+	}
+
+	bridge = newBridge(t)
+	waitFor(t, "session restored", func() bool {
+		got := states.get()
+		return got[len(got)-1] == SessionOnline
+	})
+	if got := states.get(); got[0] != SessionReconnecting || !alice.IsReady() {
+		t.Errorf("Unexpected states %v", got)
+	}
+	if !slices.Contains(bridge.Sessions(), "alice") {
+		t.Error("Session not re-created on the new bridge")
+	}
+}
+
+func TestSAMRouterReconfigure(t *testing.T) {
+	bridge := newBridge(t)
+	alice := startRouter(t, bridge.Addr(), "alice")
+	bob := startRouter(t, bridge.Addr(), "bob")
+	aliceDest, _ := alice.GetAddress()
+	bobDest, _ := bob.GetAddress()
+	var states stateLog
+	alice.SetStateHandler(states.handle)
+
+	// Смена профиля пересоздаёт сессию с теми же ключами и новыми туннелями
+	profile, _ := LookupProfile(ProfileDefault)
+	cfg := DefaultConfig()
+	profile.Apply(cfg)
+	alice.Reconfigure(cfg)
+	waitFor(t, "online state", func() bool {
+		return slices.Equal(states.get(), []SessionState{SessionReconnecting, SessionOnline})
+	})
+	if got, _ := alice.GetAddress(); got != aliceDest {
+		t.Error("Address changed after reconfigure")
+	}
+	opts := bridge.Options("alice")
+	for key, want := range map[string]string{
+		"inbound.length":          "3",
+		"outbound.quantity":       "3",
+		"inbound.lengthVariance":  "0",
+		"outbound.backupQuantity": "1",
+		"i2cp.leaseSetType":       "3",
+	} {
+		if opts[key] != want {
+			t.Errorf("%s = %q, want %q", key, opts[key], want)
+		}
+	}
+	exchange(t, alice, bob, bobDest)
+	exchange(t, bob, alice, aliceDest)
+}
+
+func TestSAMRouterEncryptedLeaseSet(t *testing.T) {
+	bridge := newBridge(t)
+	newAuthKey := func() *ecdh.PrivateKey {
+		key, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKey failed: %v", err)
+		}
+		return key
+	}
+	aliceKey, carolKey := newAuthKey(), newAuthKey()
+	withAuthKey := func(key *ecdh.PrivateKey) func(*Config) {
+		return func(c *Config) { c.LeaseSetPrivKey = key.Bytes() }
+	}
+
+	alice := startRouter(t, bridge.Addr(), "alice", withAuthKey(aliceKey))
+	carol := startRouter(t, bridge.Addr(), "carol", withAuthKey(carolKey))
+	bob := startRouter(t, bridge.Addr(), "bob", func(c *Config) {
+		invisible, _ := LookupProfile(ProfileInvisible)
+		invisible.Apply(c)
+		c.LeaseSetClients = [][]byte{aliceKey.PublicKey().Bytes()}
+	})
+	bobDest, _ := bob.GetAddress()
+	blinded := bob.GetBlindedAddress()
+	if !IsBlindedAddress(blinded) {
+		t.Fatalf("Unexpected blinded address %q", blinded)
+	}
+	if alice.GetBlindedAddress() != "" {
+		t.Error("Public leaseSet has a blinded address")
+	}
+	if got := bridge.Options("bob")["i2cp.leaseSetAuthType"]; got != "1" {
+		t.Errorf("Client auth not requested: %q", got)
+	}
+
+	// По destination зашифрованный leaseSet не найти
+	if _, err := alice.Dial(bobDest); err == nil {
+		t.Error("Reached an encrypted leaseSet by destination")
+	}
+	if err := alice.SetPeerBlindedAddress(bobDest, blinded); err != nil {
+		t.Fatalf("SetPeerBlindedAddress failed: %v", err)
+	}
+	exchange(t, alice, bob, bobDest)
+
+	// b33 без ключа в списке Боба не помогает
+	if err := carol.SetPeerBlindedAddress(bobDest, blinded); err != nil {
+		t.Fatalf("SetPeerBlindedAddress failed: %v", err)
+	}
+	if _, err := carol.Dial(bobDest); err == nil {
+		t.Error("Unauthorized client reached the leaseSet")
+	}
+	aliceDest, _ := alice.GetAddress()
+	if other, _ := BlindedAddress(aliceDest, true); carol.SetPeerBlindedAddress(bobDest, other) == nil {
+		t.Error("Accepted a blinded address of another destination")
+	}
+
+	// Новый клиент: сессия пересоздаётся с тем же адресом
+	var states stateLog
+	bob.SetStateHandler(states.handle)
+	bob.SetLeaseSetClients([][]byte{aliceKey.PublicKey().Bytes(), carolKey.PublicKey().Bytes()})
+	waitFor(t, "online state", func() bool {
+		return slices.Equal(states.get(), []SessionState{SessionReconnecting, SessionOnline})
+	})
+	if bob.GetBlindedAddress() != blinded {
+		t.Error("Blinded address changed")
+	}
+	exchange(t, carol, bob, bobDest)
+	exchange(t, bob, alice, aliceDest)
+}
+
+func TestMessengerOverSAM(t *testing.T) {
+	bridge := newBridge(t)
+
+	type node struct {
+		*messenger.Service
+		pub      string
+		dest     string
+		messages chan *core.Message
+	}
+	newNode := func(name string) *node {
+		r := startRouter(t, bridge.Addr(), name)
+		id, err := identity.GenerateNewIdentity()
+		if err != nil {
+			t.Fatalf("GenerateNewIdentity failed: %v", err)
+		}
+		n := &node{pub: id.Keys.PublicKeyBase64, messages: make(chan *core.Message, 16)}
+		n.dest, _ = r.GetAddress()
+		n.Service = messenger.NewService(r, id.Keys, func(msg *core.Message, _, _ string) { n.messages <- msg })
+		n.SetStampPolicy(envelope.StampPolicy{Bits: 4})
+		if err := n.Start(context.Background()); err != nil {
+			t.Fatalf("Service start failed: %v", err)
+		}
+		t.Cleanup(func() { _ = n.Stop() })
+		return n
+	}
+	receive := func(n *node, content string) {
+		t.Helper()
+		select {
+		case msg := <-n.messages:
+			if msg.Content != content {
+				t.Fatalf("Unexpected message %q", msg.Content)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Timed out waiting for %q", content)
+		}
+	}
+
+	alice, bob := newNode("alice"), newNode("bob")
+	chatID := identity.CalculateChatID(alice.pub, bob.pub)
+
+	if err := alice.SendTextMessageWithID(bob.dest, chatID, "m1", "hello over SAM", ""); err != nil {
+		t.Fatalf("Alice -> Bob failed: %v", err)
+	}
+	receive(bob, "hello over SAM")
+	if err := bob.SendTextMessageWithID(alice.dest, chatID, "m2", "hi back", "m1"); err != nil {
+		t.Fatalf("Bob -> Alice failed: %v", err)
+	}
+	receive(alice, "hi back")
+
+	// Сессии упали и пересоздались: отправка снова проходит, приём у Боба возобновился
+	bridge.DropControl()
+	waitFor(t, "sessions restored", func() bool { return len(bridge.Sessions()) == 2 })
+	waitFor(t, "delivery after restore", func() bool {
+		return alice.SendTextMessageWithID(bob.dest, chatID, "m3", "after restore", "") == nil
+	})
+	receive(bob, "after restore")
+}
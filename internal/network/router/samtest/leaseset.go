@@ -0,0 +1,79 @@
+package samtest
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/base32"
+	"fmt"
+	"hash/crc32"
+	"slices"
+	"strings"
+)
+
+// b32Encoding — base32 адресов .b32.i2p
+var b32Encoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// leaseSet — параметры leaseSet сессии из опций i2cp
+type leaseSet struct {
+	encrypted bool     // i2cp.leaseSetType=5
+	auth      bool     // i2cp.leaseSetAuthType=1: открыт только clients
+	clients   [][]byte // X25519 ключи из i2cp.leaseSetClient.dh.*
+	authKey   []byte   // X25519 ключ из i2cp.leaseSetPrivKey (публичная часть)
+}
+
+// parseLeaseSet разбирает опции leaseSet так же строго, как роутер
+func parseLeaseSet(args map[string]string) (leaseSet, error) {
+	ls := leaseSet{
+		encrypted: args["i2cp.leaseSetType"] == "5",
+		auth:      args["i2cp.leaseSetAuthType"] == "1",
+	}
+	for key, value := range args {
+		if !strings.HasPrefix(key, "i2cp.leaseSetClient.dh.") {
+			continue
+		}
+		_, encoded, ok := strings.Cut(value, ":")
+		pub, err := i2pEncoding.DecodeString(encoded)
+		if !ok || err != nil || len(pub) != 32 {
+			return ls, fmt.Errorf("invalid %s", key)
+		}
+		ls.clients = append(ls.clients, pub)
+	}
+	if encoded, ok := args["i2cp.leaseSetPrivKey"]; ok {
+		raw, err := i2pEncoding.DecodeString(encoded)
+		if err != nil {
+			return ls, fmt.Errorf("invalid i2cp.leaseSetPrivKey")
+		}
+		priv, err := ecdh.X25519().NewPrivateKey(raw)
+		if err != nil {
+			return ls, fmt.Errorf("invalid i2cp.leaseSetPrivKey")
+		}
+		ls.authKey = priv.PublicKey().Bytes()
+	}
+	return ls, nil
+}
+
+// authorized проверяет, что клиент с ключом key может расшифровать leaseSet
+func (ls leaseSet) authorized(key []byte) bool {
+	return key != nil && slices.ContainsFunc(ls.clients, func(c []byte) bool { return bytes.Equal(c, key) })
+}
+
+// parseBlinded разбирает b33 адрес: ключ подписи Ed25519 и флаг авторизации клиентов
+func parseBlinded(name string) (key []byte, auth bool, ok bool) {
+	label, found := strings.CutSuffix(strings.ToLower(name), ".b32.i2p")
+	if !found {
+		return nil, false, false
+	}
+	data, err := b32Encoding.DecodeString(label)
+	if err != nil || len(data) != 35 {
+		return nil, false, false
+	}
+	sum := crc32.ChecksumIEEE(data[3:])
+	data[0] ^= byte(sum)
+	data[1] ^= byte(sum >> 8)
+	data[2] ^= byte(sum >> 16)
+	// Однобайтные типы подписи: Ed25519, ослеплённая — RedDSA; секрет не поддерживается
+	if data[0]&^0x04 != 0 || data[1] != 7 || data[2] != 11 {
+		return nil, false, false
+	}
+	return data[3:], data[0]&0x04 != 0, true
+}
@@ -0,0 +1,606 @@
+// Package samtest — SAM v3 мост в памяти процесса для тестов SAMRouter.
+//
+// Сервер понимает то подмножество SAM v3.1–3.3, которым пользуется sam3: HELLO,
+// DEST GENERATE, SESSION CREATE STYLE=STREAM, NAMING LOOKUP, STREAM CONNECT и
+// STREAM ACCEPT. Потоки соединяют сессии, открытые на этом же сервере; туннелей и
+// шифрования нет. Сессия с зашифрованным leaseSet (i2cp.leaseSetType=5) доступна только
+// по b33 адресу и, с авторизацией, только клиентам из её списка. Сбои (отказ в разрешении
+// имени, обрыв управляющих сокетов) включаются методами сервера.
+//
+// sam3 открывает потоки на 127.0.0.1:7656 независимо от адреса, переданного в NewSAM,
+// поэтому для Dial и Accept сервер должен слушать именно этот адрес.
+package samtest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+// DefaultAddress — адрес, на котором sam3 ищет мост для потоков
+const DefaultAddress = "127.0.0.1:7656"
+
+const (
+	// destinationSize — размер destination с сертификатом ключа Ed25519 (524 символа base64, как у i2pd)
+	destinationSize = 391
+
+	// privateKeySize — сколько байт закрытых ключей следует за destination в PRIV
+	privateKeySize = 96
+
+	// minVersion, maxVersion — поддерживаемые версии протокола
+	minVersion = 3.1
+	maxVersion = 3.3
+
+	// connectTimeout — сколько STREAM CONNECT ждёт STREAM ACCEPT на стороне адресата
+	connectTimeout = 5 * time.Second
+
+	// settleDelay — пауза между ответом на CONNECT/ACCEPT и первыми данными. sam3 читает
+	// ответ с запасом и теряет всё, что пришло с ним в одном сегменте.
+	settleDelay = 20 * time.Millisecond
+)
+
+// i2pEncoding — base64 с алфавитом I2P
+var i2pEncoding = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-~")
+
+// pubLength — длина destination в base64
+var pubLength = i2pEncoding.EncodedLen(destinationSize)
+
+// Server — SAM мост. Сессии живут, пока открыт их управляющий сокет.
+type Server struct {
+	listener net.Listener
+
+	mu          sync.Mutex
+	sessions    map[string]*session // по ID
+	byDest      map[string]*session // по destination
+	names       map[string]string   // b32 → destination всех выданных адресов
+	control     map[net.Conn]bool   // управляющие сокеты (до STREAM CONNECT/ACCEPT)
+	streams     map[net.Conn]bool   // сокеты потоков
+	failLookups int
+	closed      bool
+
+	wg sync.WaitGroup
+}
+
+// session — STREAM сессия
+type session struct {
+	id      string
+	dest    string
+	options map[string]string // параметры SESSION CREATE, включая опции туннелей
+	leaseSet
+	control net.Conn
+	accepts chan *socket  // сокеты, ждущие входящий поток
+	done    chan struct{} // закрыт, когда сессия уничтожена
+}
+
+// socket — соединение клиента с буфером чтения команд
+type socket struct {
+	net.Conn
+	r       *bufio.Reader
+	version float64 // согласованная в HELLO версия
+}
+
+// NewServer запускает мост на addr ("127.0.0.1:0" — свободный порт)
+func NewServer(addr string) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	s := &Server{
+		listener: l,
+		sessions: make(map[string]*session),
+		byDest:   make(map[string]*session),
+		names:    make(map[string]string),
+		control:  make(map[net.Conn]bool),
+		streams:  make(map[net.Conn]bool),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr возвращает адрес моста для Config.SAMAddress
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close останавливает мост и рвёт все соединения
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	err := s.listener.Close()
+	for c := range s.control {
+		_ = c.Close()
+	}
+	for c := range s.streams {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// FailLookups заставляет следующие n запросов NAMING LOOKUP вернуть KEY_NOT_FOUND
+func (s *Server) FailLookups(n int) {
+	s.mu.Lock()
+	s.failLookups = n
+	s.mu.Unlock()
+}
+
+// DropControl рвёт все управляющие сокеты, как при перезапуске роутера: сессии
+// уничтожаются вместе с их потоками и ожидающими ACCEPT
+func (s *Server) DropControl() {
+	s.mu.Lock()
+	for c := range s.control {
+		_ = c.Close()
+	}
+	for _, sess := range s.sessions {
+		s.destroyLocked(sess)
+	}
+	s.mu.Unlock()
+}
+
+// Sessions возвращает ID открытых сессий
+func (s *Server) Sessions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.sessions))
+	for id := range s.sessions {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Options возвращает параметры, с которыми создана сессия, или nil
+func (s *Server) Options(id string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess, ok := s.sessions[id]; ok {
+		return maps.Clone(sess.options)
+	}
+	return nil
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.control[conn] = true
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(&socket{Conn: conn, r: bufio.NewReader(conn)})
+	}
+}
+
+// handle обслуживает управляющий сокет. После STREAM CONNECT/ACCEPT сокет становится
+// потоком и уходит из-под handle.
+func (s *Server) handle(c *socket) {
+	defer s.wg.Done()
+
+	var own *session
+	handedOff := false
+	defer func() {
+		s.mu.Lock()
+		delete(s.control, c.Conn)
+		if own != nil {
+			s.destroyLocked(own)
+		}
+		s.mu.Unlock()
+		if !handedOff {
+			_ = c.Close()
+		}
+	}()
+
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return
+	}
+	cmd, args := parse(line)
+	if cmd != "HELLO VERSION" {
+		_ = reply(c, "HELLO REPLY RESULT=I2P_ERROR MESSAGE=%q", "HELLO expected")
+		return
+	}
+	version, ok := negotiate(args["MIN"], args["MAX"])
+	if !ok {
+		_ = reply(c, "HELLO REPLY RESULT=NOVERSION")
+		return
+	}
+	c.version = version
+	if reply(c, "HELLO REPLY RESULT=OK VERSION=%.1f", version) != nil {
+		return
+	}
+
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, args := parse(line)
+		switch cmd {
+		case "DEST GENERATE":
+			pub, priv := generate()
+			s.remember(pub)
+			err = reply(c, "DEST REPLY PUB=%s PRIV=%s", pub, priv)
+		case "SESSION CREATE":
+			if own != nil {
+				err = reply(c, "SESSION STATUS RESULT=I2P_ERROR MESSAGE=%q", "session already created")
+				break
+			}
+			own, err = s.createSession(c, args)
+		case "NAMING LOOKUP":
+			err = s.lookup(c, own, args["NAME"])
+		case "STREAM CONNECT":
+			handedOff = true
+			s.handOff(c)
+			s.connect(c, args)
+			return
+		case "STREAM ACCEPT":
+			handedOff = s.accept(c, args)
+			return
+		case "QUIT":
+			return
+		default:
+			err = reply(c, "%s STATUS RESULT=I2P_ERROR MESSAGE=%q", cmd, "unsupported command")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// createSession открывает STREAM сессию на управляющем сокете
+func (s *Server) createSession(c *socket, args map[string]string) (*session, error) {
+	if args["STYLE"] != "STREAM" {
+		return nil, reply(c, "SESSION STATUS RESULT=I2P_ERROR MESSAGE=%q", "only STYLE=STREAM is supported")
+	}
+	id, priv := args["ID"], args["DESTINATION"]
+	if id == "" {
+		return nil, reply(c, "SESSION STATUS RESULT=I2P_ERROR MESSAGE=%q", "missing ID")
+	}
+	var pub string
+	if priv == "TRANSIENT" {
+		pub, priv = generate()
+	} else if raw, err := i2pEncoding.DecodeString(priv); err != nil || len(raw) <= destinationSize {
+		return nil, reply(c, "SESSION STATUS RESULT=INVALID_KEY")
+	} else {
+		pub = i2pEncoding.EncodeToString(raw[:destinationSize])
+	}
+	ls, err := parseLeaseSet(args)
+	if err != nil {
+		return nil, reply(c, "SESSION STATUS RESULT=I2P_ERROR MESSAGE=%q", err.Error())
+	}
+
+	s.mu.Lock()
+	if _, ok := s.sessions[id]; ok {
+		s.mu.Unlock()
+		return nil, reply(c, "SESSION STATUS RESULT=DUPLICATED_ID")
+	}
+	if _, ok := s.byDest[pub]; ok {
+		s.mu.Unlock()
+		return nil, reply(c, "SESSION STATUS RESULT=DUPLICATED_DEST")
+	}
+	sess := &session{
+		id:       id,
+		dest:     pub,
+		options:  args,
+		leaseSet: ls,
+		control:  c.Conn,
+		accepts:  make(chan *socket, 16),
+		done:     make(chan struct{}),
+	}
+	s.sessions[id] = sess
+	s.byDest[pub] = sess
+	s.names[i2pkeys.I2PAddr(pub).Base32()] = pub
+	s.mu.Unlock()
+
+	log.Printf("[SAMTest] Session %s created", id)
+	return sess, reply(c, "SESSION STATUS RESULT=OK DESTINATION=%s", priv)
+}
+
+// destroyLocked уничтожает сессию и закрывает ждущие ACCEPT сокеты (под mu)
+func (s *Server) destroyLocked(sess *session) {
+	if s.sessions[sess.id] != sess {
+		return
+	}
+	delete(s.sessions, sess.id)
+	delete(s.byDest, sess.dest)
+	close(sess.done)
+	_ = sess.control.Close()
+	for {
+		select {
+		case c := <-sess.accepts:
+			delete(s.streams, c.Conn)
+			_ = c.Close()
+		default:
+			log.Printf("[SAMTest] Session %s destroyed", sess.id)
+			return
+		}
+	}
+}
+
+// lookup отвечает на NAMING LOOKUP: ME, полный адрес или b32 известного destination
+func (s *Server) lookup(c *socket, own *session, name string) error {
+	s.mu.Lock()
+	fail := s.failLookups > 0
+	if fail {
+		s.failLookups--
+	}
+	var value string
+	switch {
+	case fail:
+	case name == "ME" && own != nil:
+		value = own.dest
+	case strings.HasSuffix(strings.ToLower(name), ".b32.i2p"):
+		value = s.names[strings.ToLower(name)]
+		// Зашифрованный leaseSet не найти по хэшу destination
+		if sess := s.byDest[value]; sess != nil && sess.encrypted {
+			value = ""
+		}
+	default:
+		if addr, err := i2pkeys.NewI2PAddrFromString(name); err == nil {
+			value = addr.Base64()
+		}
+	}
+	s.mu.Unlock()
+
+	if value == "" {
+		return reply(c, "NAMING REPLY RESULT=KEY_NOT_FOUND NAME=%s", name)
+	}
+	return reply(c, "NAMING REPLY RESULT=OK NAME=%s VALUE=%s", name, value)
+}
+
+// accept ставит сокет в очередь входящих потоков сессии
+func (s *Server) accept(c *socket, args map[string]string) bool {
+	s.mu.Lock()
+	sess := s.sessions[args["ID"]]
+	s.mu.Unlock()
+	if sess == nil {
+		_ = reply(c, "STREAM STATUS RESULT=INVALID_ID")
+		return false
+	}
+
+	s.handOff(c)
+	if reply(c, "STREAM STATUS RESULT=OK") != nil {
+		s.closeStream(c)
+		return true
+	}
+	// Под mu: уничтожение сессии не разминётся с постановкой в очередь
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions[sess.id] == sess {
+		select {
+		case sess.accepts <- c:
+			return true
+		default:
+		}
+	}
+	delete(s.streams, c.Conn)
+	_ = c.Close()
+	return true
+}
+
+// connect соединяет сокет с очередным ACCEPT адресата и перекачивает данные
+func (s *Server) connect(c *socket, args map[string]string) {
+	s.mu.Lock()
+	from := s.sessions[args["ID"]]
+	to := s.target(from, args["DESTINATION"])
+	s.mu.Unlock()
+
+	switch {
+	case from == nil:
+		_ = reply(c, "STREAM STATUS RESULT=INVALID_ID")
+		s.closeStream(c)
+		return
+	case to == nil:
+		_ = reply(c, "STREAM STATUS RESULT=CANT_REACH_PEER")
+		s.closeStream(c)
+		return
+	}
+
+	timeout := time.NewTimer(connectTimeout)
+	defer timeout.Stop()
+	for {
+		var peer *socket
+		select {
+		case peer = <-to.accepts:
+		case <-to.done:
+		case <-from.done:
+		case <-timeout.C:
+			_ = reply(c, "STREAM STATUS RESULT=TIMEOUT")
+			s.closeStream(c)
+			return
+		}
+		if peer == nil {
+			_ = reply(c, "STREAM STATUS RESULT=CANT_REACH_PEER")
+			s.closeStream(c)
+			return
+		}
+		// Порты в строке адреса появились в 3.2. Клиент мог бросить ACCEPT — тогда берём следующий.
+		destLine := from.dest + "\n"
+		if peer.version >= 3.2 {
+			destLine = from.dest + " FROM_PORT=0 TO_PORT=0\n"
+		}
+		if _, err := io.WriteString(peer, destLine); err != nil {
+			s.closeStream(peer)
+			continue
+		}
+		if reply(c, "STREAM STATUS RESULT=OK") != nil {
+			s.closeStream(peer)
+			s.closeStream(c)
+			return
+		}
+		time.Sleep(settleDelay)
+		s.pipe(c, peer)
+		return
+	}
+}
+
+// pipe перекачивает данные между сокетами, пока одна из сторон не закроется
+func (s *Server) pipe(a, b *socket) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	relay := func(dst, src *socket) {
+		defer wg.Done()
+		_, _ = io.Copy(dst, src.r)
+		s.closeStream(dst)
+		s.closeStream(src)
+	}
+	go relay(a, b)
+	go relay(b, a)
+	wg.Wait()
+}
+
+// handOff переводит сокет из управляющих в потоки
+func (s *Server) handOff(c *socket) {
+	s.mu.Lock()
+	delete(s.control, c.Conn)
+	if s.closed {
+		_ = c.Close()
+	}
+	s.streams[c.Conn] = true
+	s.mu.Unlock()
+}
+
+func (s *Server) closeStream(c *socket) {
+	s.mu.Lock()
+	delete(s.streams, c.Conn)
+	s.mu.Unlock()
+	_ = c.Close()
+}
+
+// remember запоминает b32 выданного адреса для NAMING LOOKUP
+func (s *Server) remember(pub string) {
+	s.mu.Lock()
+	s.names[i2pkeys.I2PAddr(pub).Base32()] = pub
+	s.mu.Unlock()
+}
+
+// target находит сессию адресата STREAM CONNECT (под mu). Обычный leaseSet ищется по
+// destination, зашифрованный — только по b33 и, с авторизацией, только клиентами из списка.
+func (s *Server) target(from *session, dest string) *session {
+	key, auth, ok := parseBlinded(dest)
+	if !ok {
+		if to := s.byDest[dest]; to != nil && !to.encrypted {
+			return to
+		}
+		return nil
+	}
+	for _, to := range s.sessions {
+		if !to.encrypted || to.auth != auth || !bytes.Equal(signingKey(to.dest), key) {
+			continue
+		}
+		if to.auth && (from == nil || !to.authorized(from.authKey)) {
+			return nil
+		}
+		return to
+	}
+	return nil
+}
+
+// generate создаёт destination с сертификатом ключа Ed25519 и закрытые ключи; PRIV — base64
+// destination вместе с ключами, как в I2P
+func generate() (pub, priv string) {
+	raw := make([]byte, destinationSize+privateKeySize)
+	if _, err := rand.Read(raw); err != nil {
+		panic(fmt.Sprintf("samtest: %v", err))
+	}
+	// KEY сертификат: длина 4, подпись Ed25519, шифрование ElGamal
+	copy(raw[destinationSize-7:], []byte{5, 0, 4, 0, 7, 0, 0})
+	return i2pEncoding.EncodeToString(raw[:destinationSize]), i2pEncoding.EncodeToString(raw)
+}
+
+// signingKey — ключ подписи Ed25519 в конце области ключа подписи destination
+func signingKey(dest string) []byte {
+	raw, _ := i2pEncoding.DecodeString(dest)
+	if len(raw) < destinationSize {
+		return nil
+	}
+	return raw[352:384]
+}
+
+// negotiate выбирает версию протокола из диапазона клиента
+func negotiate(minArg, maxArg string) (float64, bool) {
+	lo, hi := minVersion, maxVersion
+	if v, err := strconv.ParseFloat(minArg, 64); err == nil && v > lo {
+		lo = v
+	}
+	if v, err := strconv.ParseFloat(maxArg, 64); err == nil && v < hi {
+		hi = v
+	}
+	if lo > hi {
+		return 0, false
+	}
+	return hi, true
+}
+
+// parse разбирает команду: два первых слова и пары KEY=VALUE (значения могут быть в кавычках)
+func parse(line string) (string, map[string]string) {
+	fields := split(strings.TrimSpace(line))
+	args := make(map[string]string)
+	var words []string
+	for _, f := range fields {
+		if k, v, ok := strings.Cut(f, "="); ok {
+			args[k] = strings.Trim(v, `"`)
+		} else if len(words) < 2 {
+			words = append(words, strings.ToUpper(f))
+		}
+	}
+	return strings.Join(words, " "), args
+}
+
+// split делит строку по пробелам, не разрывая значения в кавычках
+func split(line string) []string {
+	var fields []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case (r == ' ' || r == '\t') && !quoted:
+			if cur.Len() > 0 {
+				fields = append(fields, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+// reply пишет строку ответа одним вызовом Write: sam3 читает ответ за одно чтение
+func reply(c net.Conn, format string, args ...interface{}) error {
+	_, err := fmt.Fprintf(c, format+"\n", args...)
+	return err
+}
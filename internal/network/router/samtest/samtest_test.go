@@ -0,0 +1,169 @@
+package samtest
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+// client — управляющий сокет с построчным чтением ответов
+type client struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func dial(t *testing.T, s *Server, hello string) (*client, string) {
+	t.Helper()
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	c := &client{Conn: conn, r: bufio.NewReader(conn)}
+	return c, c.command(t, hello)
+}
+
+func (c *client) command(t *testing.T, line string) string {
+	t.Helper()
+	if _, err := c.Write([]byte(line + "\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	reply, err := c.r.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Read failed after %q: %v", line, err)
+	}
+	return strings.TrimSpace(reply)
+}
+
+// destinationOf выделяет destination из PRIV
+func destinationOf(t *testing.T, priv string) string {
+	t.Helper()
+	raw, err := i2pEncoding.DecodeString(priv)
+	if err != nil || len(raw) <= destinationSize {
+		t.Fatalf("Invalid private keys %q", priv)
+	}
+	return i2pEncoding.EncodeToString(raw[:destinationSize])
+}
+
+func newServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := NewServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestHello(t *testing.T) {
+	s := newServer(t)
+	tests := []struct {
+		hello string
+		want  string
+	}{
+		{"HELLO VERSION MIN=3.0 MAX=3.1", "HELLO REPLY RESULT=OK VERSION=3.1"},
+		{"HELLO VERSION MIN=3.1 MAX=3.3", "HELLO REPLY RESULT=OK VERSION=3.3"},
+		{"HELLO VERSION", "HELLO REPLY RESULT=OK VERSION=3.3"},
+		{"HELLO VERSION MIN=2.0 MAX=3.0", "HELLO REPLY RESULT=NOVERSION"},
+		{"NAMING LOOKUP NAME=ME", `HELLO REPLY RESULT=I2P_ERROR MESSAGE="HELLO expected"`},
+	}
+	for _, tt := range tests {
+		if _, got := dial(t, s, tt.hello); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.hello, got, tt.want)
+		}
+	}
+}
+
+func TestSessionAndLookup(t *testing.T) {
+	s := newServer(t)
+	c, _ := dial(t, s, "HELLO VERSION MIN=3.1 MAX=3.1")
+
+	keys := c.command(t, "DEST GENERATE SIGNATURE_TYPE=7")
+	var pub, priv string
+	for _, f := range strings.Fields(keys) {
+		if v, ok := strings.CutPrefix(f, "PUB="); ok {
+			pub = v
+		} else if v, ok := strings.CutPrefix(f, "PRIV="); ok {
+			priv = v
+		}
+	}
+	if len(pub) != pubLength || destinationOf(t, priv) != pub {
+		t.Fatalf("Unexpected keys %q", keys)
+	}
+
+	if got := c.command(t, "SESSION CREATE STYLE=DATAGRAM ID=x DESTINATION="+priv); !strings.Contains(got, "RESULT=I2P_ERROR") {
+		t.Errorf("DATAGRAM session: %q", got)
+	}
+	if got := c.command(t, "SESSION CREATE STYLE=STREAM ID=x DESTINATION="+priv+" inbound.length=1"); got != "SESSION STATUS RESULT=OK DESTINATION="+priv {
+		t.Fatalf("SESSION CREATE: %q", got)
+	}
+	if got := s.Options("x")["inbound.length"]; got != "1" {
+		t.Errorf("Session option: %q", got)
+	}
+	other, _ := dial(t, s, "HELLO VERSION MIN=3.1 MAX=3.1")
+	if got := other.command(t, "SESSION CREATE STYLE=STREAM ID=x DESTINATION=TRANSIENT"); got != "SESSION STATUS RESULT=DUPLICATED_ID" {
+		t.Errorf("Duplicate ID: %q", got)
+	}
+	if got := other.command(t, "SESSION CREATE STYLE=STREAM ID=y DESTINATION="+priv); got != "SESSION STATUS RESULT=DUPLICATED_DEST" {
+		t.Errorf("Duplicate destination: %q", got)
+	}
+
+	b32 := strings.ToUpper(i2pkeys.I2PAddr(pub).Base32())
+	if got := other.command(t, "NAMING LOOKUP NAME="+b32); got != "NAMING REPLY RESULT=OK NAME="+b32+" VALUE="+pub {
+		t.Errorf("Lookup by b32: %q", got)
+	}
+	if got := c.command(t, "NAMING LOOKUP NAME=ME"); !strings.HasSuffix(got, "VALUE="+pub) {
+		t.Errorf("Lookup of ME: %q", got)
+	}
+	s.FailLookups(1)
+	if got := c.command(t, "NAMING LOOKUP NAME="+pub); !strings.Contains(got, "RESULT=KEY_NOT_FOUND") {
+		t.Errorf("Injected failure: %q", got)
+	}
+	if got := c.command(t, "NAMING LOOKUP NAME="+pub); got != "NAMING REPLY RESULT=OK NAME="+pub+" VALUE="+pub {
+		t.Errorf("Lookup of a full destination: %q", got)
+	}
+
+	// Сессия уничтожается вместе с управляющим сокетом
+	s.DropControl()
+	if _, err := c.r.ReadString('\n'); err == nil {
+		t.Error("Control socket survived the drop")
+	}
+	if len(s.Sessions()) != 0 {
+		t.Error("Session survived the drop")
+	}
+}
+
+func TestStream(t *testing.T) {
+	s := newServer(t)
+	a, _ := dial(t, s, "HELLO VERSION MIN=3.1 MAX=3.1")
+	b, _ := dial(t, s, "HELLO VERSION MIN=3.1 MAX=3.3")
+	a.command(t, "SESSION CREATE STYLE=STREAM ID=a DESTINATION=TRANSIENT")
+	reply := b.command(t, "SESSION CREATE STYLE=STREAM ID=b DESTINATION=TRANSIENT")
+	bDest := destinationOf(t, strings.TrimPrefix(reply, "SESSION STATUS RESULT=OK DESTINATION="))
+	aDest := strings.Fields(a.command(t, "NAMING LOOKUP NAME=ME"))[4][len("VALUE="):]
+
+	acceptor, _ := dial(t, s, "HELLO VERSION MIN=3.1 MAX=3.3")
+	if got := acceptor.command(t, "STREAM ACCEPT ID=b SILENT=false"); got != "STREAM STATUS RESULT=OK" {
+		t.Fatalf("STREAM ACCEPT: %q", got)
+	}
+	dialer, _ := dial(t, s, "HELLO VERSION MIN=3.1 MAX=3.1")
+	if got := dialer.command(t, "STREAM CONNECT ID=a DESTINATION="+bDest+" SILENT=false"); got != "STREAM STATUS RESULT=OK" {
+		t.Fatalf("STREAM CONNECT: %q", got)
+	}
+	// Начиная с 3.2 в строке адреса есть порты
+	if line, _ := acceptor.r.ReadString('\n'); line != aDest+" FROM_PORT=0 TO_PORT=0\n" {
+		t.Fatalf("Unexpected destination line %q", line)
+	}
+	if _, err := acceptor.Write([]byte("pong\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if got := dialer.command(t, "ping"); got != "pong" {
+		t.Fatalf("Dialer got %q", got)
+	}
+	if line, _ := acceptor.r.ReadString('\n'); line != "ping\n" {
+		t.Fatalf("Acceptor got %q", line)
+	}
+}
@@ -0,0 +1,141 @@
+// Package wire реализует кадрирование пакетов TeleGhost поверх потоковых соединений
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"time"
+)
+
+// MaxFrameSize — абсолютный предел размера кадра
+const MaxFrameSize = 100 * 1024 * 1024
+
+// ErrFrameTooLarge — заявленная длина кадра больше допустимой
+var ErrFrameTooLarge = errors.New("packet too large")
+
+// taggedFlag — старший бит слова длины: за длиной следуют 2 байта метки кадра (тип пакета).
+// По метке получатель выбирает предел размера до того, как читать данные.
+// Старые сборки такой кадр отвергают как слишком большой, поэтому метку ставят только тем, кто её понимает.
+const taggedFlag = 1 << 31
+
+// WriteFrame пишет кадр: 4 байта длины (big endian) и данные
+func WriteFrame(conn net.Conn, data []byte, timeout time.Duration) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(timeout))
+
+	const maxUint32 = math.MaxUint32
+	if uint64(len(data)) > maxUint32 {
+		return fmt.Errorf("packet too large for uint32: %d", len(data))
+	}
+	if len(data) > MaxFrameSize {
+		return fmt.Errorf("packet too large: %d", len(data))
+	}
+	sizeBuf := make([]byte, 4)
+	// #nosec G115
+	binary.BigEndian.PutUint32(sizeBuf, uint32(len(data)))
+
+	if _, err := conn.Write(sizeBuf); err != nil {
+		return err
+	}
+	if _, err := conn.Write(data); err != nil {
+		return err
+	}
+	return nil
+}
+
+// WriteTaggedFrame пишет кадр с меткой: 4 байта длины с флагом, 2 байта метки (big endian) и данные
+func WriteTaggedFrame(conn net.Conn, tag uint16, data []byte, timeout time.Duration) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(timeout))
+
+	if len(data) > MaxFrameSize {
+		return fmt.Errorf("packet too large: %d", len(data))
+	}
+	header := make([]byte, 6)
+	// #nosec G115 -- длина ограничена MaxFrameSize
+	binary.BigEndian.PutUint32(header, uint32(len(data))|taggedFlag)
+	binary.BigEndian.PutUint16(header[4:], tag)
+
+	if _, err := conn.Write(header); err != nil {
+		return err
+	}
+	if _, err := conn.Write(data); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReadFrame читает кадр не длиннее maxSize
+func ReadFrame(conn net.Conn, maxSize uint32, timeout time.Duration) ([]byte, error) {
+	size, err := ReadFrameSize(conn, maxSize, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return ReadFrameData(conn, size)
+}
+
+// ReadFrameSize читает заголовок кадра и возвращает длину данных
+func ReadFrameSize(conn net.Conn, maxSize uint32, timeout time.Duration) (uint32, error) {
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+
+	sizeBuf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, sizeBuf); err != nil {
+		return 0, err
+	}
+
+	size := binary.BigEndian.Uint32(sizeBuf)
+	if size > maxSize {
+		return 0, fmt.Errorf("%w: %d", ErrFrameTooLarge, size)
+	}
+	return size, nil
+}
+
+// FrameHeader — заголовок кадра
+type FrameHeader struct {
+	Size   uint32
+	Tag    uint16
+	Tagged bool // false — кадр старого формата без метки
+}
+
+// ReadFrameHeader читает заголовок кадра с меткой или без неё. Длина сверяется с maxSize;
+// предел для конкретной метки вызывающий проверяет сам, до ReadFrameData.
+func ReadFrameHeader(conn net.Conn, maxSize uint32, timeout time.Duration) (FrameHeader, error) {
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+
+	buf := make([]byte, 4, 6)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return FrameHeader{}, err
+	}
+
+	var h FrameHeader
+	word := binary.BigEndian.Uint32(buf)
+	h.Size = word &^ taggedFlag
+	if h.Size > maxSize {
+		return FrameHeader{}, fmt.Errorf("%w: %d", ErrFrameTooLarge, h.Size)
+	}
+	if word&taggedFlag != 0 {
+		buf = buf[:6]
+		if _, err := io.ReadFull(conn, buf[4:]); err != nil {
+			return FrameHeader{}, err
+		}
+		h.Tag = binary.BigEndian.Uint16(buf[4:])
+		h.Tagged = true
+	}
+	return h, nil
+}
+
+// ReadFrameData читает данные кадра после ReadFrameSize. Память выделяется по мере прихода данных,
+// а не по заявленной длине: объявить большой кадр и не прислать его ничего не стоит.
+func ReadFrameData(conn net.Conn, size uint32) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, conn, int64(size)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
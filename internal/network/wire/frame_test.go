@@ -0,0 +1,51 @@
+package wire
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReadFrameHeader(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		_ = WriteTaggedFrame(client, 7, []byte("tagged"), time.Second)
+		_ = WriteFrame(client, []byte("plain"), time.Second)
+		_ = WriteTaggedFrame(client, 1, make([]byte, 64), time.Second)
+	}()
+
+	h, err := ReadFrameHeader(server, 32, time.Second)
+	if err != nil || !h.Tagged || h.Tag != 7 || h.Size != 6 {
+		t.Fatalf("Unexpected tagged header %+v (%v)", h, err)
+	}
+	if data, err := ReadFrameData(server, h.Size); err != nil || string(data) != "tagged" {
+		t.Fatalf("Unexpected tagged data %q (%v)", data, err)
+	}
+
+	// Кадр старого формата читается без метки
+	h, err = ReadFrameHeader(server, 32, time.Second)
+	if err != nil || h.Tagged || h.Size != 5 {
+		t.Fatalf("Unexpected plain header %+v (%v)", h, err)
+	}
+	if data, err := ReadFrameData(server, h.Size); err != nil || string(data) != "plain" {
+		t.Fatalf("Unexpected plain data %q (%v)", data, err)
+	}
+
+	// Флаг метки не засчитывается в длину, но предел к ней применяется
+	if _, err := ReadFrameHeader(server, 32, time.Second); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("Expected ErrFrameTooLarge, got %v", err)
+	}
+
+	// Старый читатель отвергает кадр с меткой как слишком большой
+	legacyClient, legacyServer := net.Pipe()
+	defer legacyClient.Close()
+	defer legacyServer.Close()
+	go func() { _ = WriteTaggedFrame(legacyClient, 1, []byte("x"), time.Second) }()
+	if _, err := ReadFrameSize(legacyServer, MaxFrameSize, time.Second); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("Legacy reader accepted a tagged frame: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package wire
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Поток — последовательность кадров тела после заголовка. Каждый кадр шифруется отдельно
+// (ChaCha20-Poly1305) одноразовым ключом потока; nonce — номер кадра и признак последнего кадра.
+// Кадры нельзя переставить, повторить или отрезать хвост: последний кадр пустой и помечен.
+
+// StreamKeySize — размер ключа потока
+const StreamKeySize = chacha20poly1305.KeySize
+
+// StreamOverhead — на сколько зашифрованный кадр длиннее данных
+const StreamOverhead = chacha20poly1305.Overhead
+
+// ErrStreamCorrupt — кадр тела не расшифровался или пришёл не по порядку
+var ErrStreamCorrupt = errors.New("stream frame corrupt")
+
+// streamNonce — nonce кадра: 8 байт номера (big endian), последний байт — признак конца
+func streamNonce(counter uint64, final bool) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.BigEndian.PutUint64(nonce, counter)
+	if final {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// StreamWriter шифрует и пишет кадры тела. Данные кадра не копируются сверх одного буфера.
+type StreamWriter struct {
+	conn    net.Conn
+	aead    cipher.AEAD
+	buf     []byte
+	counter uint64
+	timeout time.Duration
+	closed  bool
+}
+
+// NewStreamWriter создаёт поток кадров с ключом key
+func NewStreamWriter(conn net.Conn, key []byte, timeout time.Duration) (*StreamWriter, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamWriter{conn: conn, aead: aead, timeout: timeout}, nil
+}
+
+// WriteFrame шифрует и отправляет один кадр тела
+func (w *StreamWriter) WriteFrame(data []byte) error {
+	if w.closed {
+		return fmt.Errorf("stream closed")
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("empty stream frame")
+	}
+	return w.write(data, false)
+}
+
+// Close отправляет завершающий кадр. Соединение не закрывается.
+func (w *StreamWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.write(nil, true)
+}
+
+func (w *StreamWriter) write(data []byte, final bool) error {
+	w.buf = w.aead.Seal(w.buf[:0], streamNonce(w.counter, final), data, nil)
+	w.counter++
+	return WriteFrame(w.conn, w.buf, w.timeout)
+}
+
+// StreamReader читает и расшифровывает кадры тела
+type StreamReader struct {
+	conn     net.Conn
+	aead     cipher.AEAD
+	maxFrame uint32
+	counter  uint64
+	timeout  time.Duration
+	done     bool
+}
+
+// NewStreamReader читает поток с ключом key; maxFrame — предел размера данных кадра
+func NewStreamReader(conn net.Conn, key []byte, maxFrame uint32, timeout time.Duration) (*StreamReader, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamReader{conn: conn, aead: aead, maxFrame: maxFrame, timeout: timeout}, nil
+}
+
+// Next возвращает данные следующего кадра; io.EOF — получен завершающий кадр.
+// Обрыв соединения до завершающего кадра — io.ErrUnexpectedEOF.
+func (r *StreamReader) Next() ([]byte, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+
+	size, err := ReadFrameSize(r.conn, r.maxFrame+StreamOverhead, r.timeout)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if size < StreamOverhead {
+		return nil, ErrStreamCorrupt
+	}
+	frame, err := ReadFrameData(r.conn, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// Завершающий кадр пустой, поэтому размер подсказывает, какой nonce пробовать
+	final := size == StreamOverhead
+	data, err := r.aead.Open(frame[:0], streamNonce(r.counter, final), frame, nil)
+	if err != nil {
+		return nil, ErrStreamCorrupt
+	}
+	r.counter++
+	if final {
+		r.done = true
+		return nil, io.EOF
+	}
+	return data, nil
+}
@@ -0,0 +1,99 @@
+package wire
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func newStreamPair(t *testing.T) (*StreamWriter, *StreamReader, net.Conn) {
+	t.Helper()
+	key := make([]byte, StreamKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("rand failed: %v", err)
+	}
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	w, err := NewStreamWriter(client, key, time.Second)
+	if err != nil {
+		t.Fatalf("NewStreamWriter failed: %v", err)
+	}
+	r, err := NewStreamReader(server, key, 1024, time.Second)
+	if err != nil {
+		t.Fatalf("NewStreamReader failed: %v", err)
+	}
+	return w, r, client
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	w, r, _ := newStreamPair(t)
+	frames := [][]byte{[]byte("first"), bytes.Repeat([]byte{7}, 1024), []byte("last")}
+
+	go func() {
+		for _, f := range frames {
+			_ = w.WriteFrame(f)
+		}
+		_ = w.Close()
+	}()
+
+	for i, want := range frames {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("Frame %d: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("Frame %d mismatch", i)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("Expected io.EOF after final frame, got %v", err)
+	}
+}
+
+func TestStreamTruncated(t *testing.T) {
+	w, r, client := newStreamPair(t)
+
+	go func() {
+		_ = w.WriteFrame([]byte("only"))
+		client.Close()
+	}()
+
+	if _, err := r.Next(); err != nil {
+		t.Fatalf("First frame: %v", err)
+	}
+	// Без завершающего кадра поток не считается полученным
+	if _, err := r.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestStreamRejectsReorderedFrames(t *testing.T) {
+	w, r, client := newStreamPair(t)
+
+	// Кадр, зашифрованный вторым, подставлен первым
+	second := w.aead.Seal(nil, streamNonce(1, false), []byte("b"), nil)
+
+	go func() { _ = WriteFrame(client, second, time.Second) }()
+
+	if _, err := r.Next(); !errors.Is(err, ErrStreamCorrupt) {
+		t.Fatalf("Expected ErrStreamCorrupt, got %v", err)
+	}
+}
+
+func TestStreamFrameTooLarge(t *testing.T) {
+	w, r, _ := newStreamPair(t)
+
+	go func() { _ = w.WriteFrame(make([]byte, 2048)) }()
+
+	if _, err := r.Next(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("Expected ErrFrameTooLarge, got %v", err)
+	}
+}
@@ -26,30 +26,52 @@ type PacketType int32
 
 const (
 	PacketType_PACKET_TYPE_UNSPECIFIED PacketType = 0
-	PacketType_HEARTBEAT               PacketType = 1 // Keep-alive сигнал
-	PacketType_TEXT_MESSAGE            PacketType = 2 // Текстовое сообщение
-	PacketType_PROFILE_UPDATE          PacketType = 3 // Обновление профиля
-	PacketType_HANDSHAKE               PacketType = 4 // Рукопожатие для установки соединения
-	PacketType_MESSAGE_EDIT            PacketType = 5 // Редактирование сообщения
-	PacketType_MESSAGE_DELETE          PacketType = 6 // Удаление сообщения
-	PacketType_PROFILE_REQUEST         PacketType = 7 // Запрос обновления профиля
-	PacketType_FILE_OFFER              PacketType = 8 // Предложение файла
-	PacketType_FILE_RESPONSE           PacketType = 9 // Ответ на предложение
+	PacketType_HEARTBEAT               PacketType = 1  // Keep-alive сигнал
+	PacketType_TEXT_MESSAGE            PacketType = 2  // Текстовое сообщение
+	PacketType_PROFILE_UPDATE          PacketType = 3  // Обновление профиля
+	PacketType_HANDSHAKE               PacketType = 4  // Рукопожатие для установки соединения
+	PacketType_MESSAGE_EDIT            PacketType = 5  // Редактирование сообщения
+	PacketType_MESSAGE_DELETE          PacketType = 6  // Удаление сообщения
+	PacketType_PROFILE_REQUEST         PacketType = 7  // Запрос обновления профиля
+	PacketType_FILE_OFFER              PacketType = 8  // Предложение файла
+	PacketType_FILE_RESPONSE           PacketType = 9  // Ответ на предложение
+	PacketType_RECEIPT                 PacketType = 10 // Отчёт о доставке/прочтении
+	PacketType_FILE_CHUNK              PacketType = 11 // Часть файла
+	PacketType_FILE_CHUNK_ACK          PacketType = 12 // Подтверждение принятых частей
+	PacketType_FILE_RESUME             PacketType = 13 // Запрос продолжения передачи
+	PacketType_TYPING                  PacketType = 14 // Собеседник набирает текст (не сохраняется)
+	PacketType_PRESENCE                PacketType = 15 // Статус в сети (не сохраняется)
+	PacketType_GROUP_CONTROL           PacketType = 16 // Управление составом группы
+	PacketType_CHANNEL_POST            PacketType = 17 // Пост канала
+	PacketType_CHANNEL_CONTROL         PacketType = 18 // Подписка на канал и запрос пропущенных постов
+	PacketType_MAILBOX                 PacketType = 19 // Запрос к почтовому серверу GhostMail и его ответ
+	PacketType_FILE_STREAM             PacketType = 20 // Заголовок потока частей файла (за ним идут кадры тела)
 )
 
 // Enum value maps for PacketType.
 var (
 	PacketType_name = map[int32]string{
-		0: "PACKET_TYPE_UNSPECIFIED",
-		1: "HEARTBEAT",
-		2: "TEXT_MESSAGE",
-		3: "PROFILE_UPDATE",
-		4: "HANDSHAKE",
-		5: "MESSAGE_EDIT",
-		6: "MESSAGE_DELETE",
-		7: "PROFILE_REQUEST",
-		8: "FILE_OFFER",
-		9: "FILE_RESPONSE",
+		0:  "PACKET_TYPE_UNSPECIFIED",
+		1:  "HEARTBEAT",
+		2:  "TEXT_MESSAGE",
+		3:  "PROFILE_UPDATE",
+		4:  "HANDSHAKE",
+		5:  "MESSAGE_EDIT",
+		6:  "MESSAGE_DELETE",
+		7:  "PROFILE_REQUEST",
+		8:  "FILE_OFFER",
+		9:  "FILE_RESPONSE",
+		10: "RECEIPT",
+		11: "FILE_CHUNK",
+		12: "FILE_CHUNK_ACK",
+		13: "FILE_RESUME",
+		14: "TYPING",
+		15: "PRESENCE",
+		16: "GROUP_CONTROL",
+		17: "CHANNEL_POST",
+		18: "CHANNEL_CONTROL",
+		19: "MAILBOX",
+		20: "FILE_STREAM",
 	}
 	PacketType_value = map[string]int32{
 		"PACKET_TYPE_UNSPECIFIED": 0,
@@ -62,6 +84,17 @@ var (
 		"PROFILE_REQUEST":         7,
 		"FILE_OFFER":              8,
 		"FILE_RESPONSE":           9,
+		"RECEIPT":                 10,
+		"FILE_CHUNK":              11,
+		"FILE_CHUNK_ACK":          12,
+		"FILE_RESUME":             13,
+		"TYPING":                  14,
+		"PRESENCE":                15,
+		"GROUP_CONTROL":           16,
+		"CHANNEL_POST":            17,
+		"CHANNEL_CONTROL":         18,
+		"MAILBOX":                 19,
+		"FILE_STREAM":             20,
 	}
 )
 
@@ -92,38 +125,1914 @@ func (PacketType) EnumDescriptor() ([]byte, []int) {
 	return file_proto_teleghost_proto_rawDescGZIP(), []int{0}
 }
 
+// StampAlgorithm — функция proof-of-work штампа
+type StampAlgorithm int32
+
+const (
+	StampAlgorithm_STAMP_SHA256 StampAlgorithm = 0
+	StampAlgorithm_STAMP_ARGON2 StampAlgorithm = 1 // Argon2id, требует памяти на каждый хэш
+)
+
+// Enum value maps for StampAlgorithm.
+var (
+	StampAlgorithm_name = map[int32]string{
+		0: "STAMP_SHA256",
+		1: "STAMP_ARGON2",
+	}
+	StampAlgorithm_value = map[string]int32{
+		"STAMP_SHA256": 0,
+		"STAMP_ARGON2": 1,
+	}
+)
+
+func (x StampAlgorithm) Enum() *StampAlgorithm {
+	p := new(StampAlgorithm)
+	*p = x
+	return p
+}
+
+func (x StampAlgorithm) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (StampAlgorithm) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_teleghost_proto_enumTypes[1].Descriptor()
+}
+
+func (StampAlgorithm) Type() protoreflect.EnumType {
+	return &file_proto_teleghost_proto_enumTypes[1]
+}
+
+func (x StampAlgorithm) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use StampAlgorithm.Descriptor instead.
+func (StampAlgorithm) EnumDescriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{1}
+}
+
+// ReceiptKind — вид отчёта о сообщении
+type ReceiptKind int32
+
+const (
+	ReceiptKind_RECEIPT_KIND_UNSPECIFIED ReceiptKind = 0
+	ReceiptKind_RECEIPT_DELIVERED        ReceiptKind = 1 // Сообщение сохранено у получателя
+	ReceiptKind_RECEIPT_READ             ReceiptKind = 2 // Сообщение прочитано
+)
+
+// Enum value maps for ReceiptKind.
+var (
+	ReceiptKind_name = map[int32]string{
+		0: "RECEIPT_KIND_UNSPECIFIED",
+		1: "RECEIPT_DELIVERED",
+		2: "RECEIPT_READ",
+	}
+	ReceiptKind_value = map[string]int32{
+		"RECEIPT_KIND_UNSPECIFIED": 0,
+		"RECEIPT_DELIVERED":        1,
+		"RECEIPT_READ":             2,
+	}
+)
+
+func (x ReceiptKind) Enum() *ReceiptKind {
+	p := new(ReceiptKind)
+	*p = x
+	return p
+}
+
+func (x ReceiptKind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ReceiptKind) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_teleghost_proto_enumTypes[2].Descriptor()
+}
+
+func (ReceiptKind) Type() protoreflect.EnumType {
+	return &file_proto_teleghost_proto_enumTypes[2]
+}
+
+func (x ReceiptKind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ReceiptKind.Descriptor instead.
+func (ReceiptKind) EnumDescriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{2}
+}
+
+// GroupRole — роль участника группы
+type GroupRole int32
+
+const (
+	GroupRole_GROUP_ROLE_MEMBER GroupRole = 0
+	GroupRole_GROUP_ROLE_ADMIN  GroupRole = 1
+)
+
+// Enum value maps for GroupRole.
+var (
+	GroupRole_name = map[int32]string{
+		0: "GROUP_ROLE_MEMBER",
+		1: "GROUP_ROLE_ADMIN",
+	}
+	GroupRole_value = map[string]int32{
+		"GROUP_ROLE_MEMBER": 0,
+		"GROUP_ROLE_ADMIN":  1,
+	}
+)
+
+func (x GroupRole) Enum() *GroupRole {
+	p := new(GroupRole)
+	*p = x
+	return p
+}
+
+func (x GroupRole) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (GroupRole) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_teleghost_proto_enumTypes[3].Descriptor()
+}
+
+func (GroupRole) Type() protoreflect.EnumType {
+	return &file_proto_teleghost_proto_enumTypes[3]
+}
+
+func (x GroupRole) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use GroupRole.Descriptor instead.
+func (GroupRole) EnumDescriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{3}
+}
+
+// GroupAction — вид управляющего пакета группы
+type GroupAction int32
+
+const (
+	GroupAction_GROUP_ACTION_UNSPECIFIED GroupAction = 0
+	GroupAction_GROUP_INVITE             GroupAction = 1 // Приглашение (state — новый состав)
+	GroupAction_GROUP_KICK               GroupAction = 2 // Исключение (state — новый состав)
+	GroupAction_GROUP_LEAVE              GroupAction = 3 // Участник выходит из группы
+	GroupAction_GROUP_UPDATE             GroupAction = 4 // Актуальный состав
+	GroupAction_GROUP_SYNC               GroupAction = 5 // Запрос актуального состава
+)
+
+// Enum value maps for GroupAction.
+var (
+	GroupAction_name = map[int32]string{
+		0: "GROUP_ACTION_UNSPECIFIED",
+		1: "GROUP_INVITE",
+		2: "GROUP_KICK",
+		3: "GROUP_LEAVE",
+		4: "GROUP_UPDATE",
+		5: "GROUP_SYNC",
+	}
+	GroupAction_value = map[string]int32{
+		"GROUP_ACTION_UNSPECIFIED": 0,
+		"GROUP_INVITE":             1,
+		"GROUP_KICK":               2,
+		"GROUP_LEAVE":              3,
+		"GROUP_UPDATE":             4,
+		"GROUP_SYNC":               5,
+	}
+)
+
+func (x GroupAction) Enum() *GroupAction {
+	p := new(GroupAction)
+	*p = x
+	return p
+}
+
+func (x GroupAction) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (GroupAction) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_teleghost_proto_enumTypes[4].Descriptor()
+}
+
+func (GroupAction) Type() protoreflect.EnumType {
+	return &file_proto_teleghost_proto_enumTypes[4]
+}
+
+func (x GroupAction) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use GroupAction.Descriptor instead.
+func (GroupAction) EnumDescriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{4}
+}
+
+// ChannelAction — вид управляющего пакета канала
+type ChannelAction int32
+
+const (
+	ChannelAction_CHANNEL_ACTION_UNSPECIFIED ChannelAction = 0
+	ChannelAction_CHANNEL_SUBSCRIBE          ChannelAction = 1 // Подписка (after_seq — последний полученный пост)
+	ChannelAction_CHANNEL_UNSUBSCRIBE        ChannelAction = 2 // Отписка
+	ChannelAction_CHANNEL_SYNC               ChannelAction = 3 // Запрос постов после after_seq
+	ChannelAction_CHANNEL_INFO               ChannelAction = 4 // Ответ владельца: название и номер последнего поста
+)
+
+// Enum value maps for ChannelAction.
+var (
+	ChannelAction_name = map[int32]string{
+		0: "CHANNEL_ACTION_UNSPECIFIED",
+		1: "CHANNEL_SUBSCRIBE",
+		2: "CHANNEL_UNSUBSCRIBE",
+		3: "CHANNEL_SYNC",
+		4: "CHANNEL_INFO",
+	}
+	ChannelAction_value = map[string]int32{
+		"CHANNEL_ACTION_UNSPECIFIED": 0,
+		"CHANNEL_SUBSCRIBE":          1,
+		"CHANNEL_UNSUBSCRIBE":        2,
+		"CHANNEL_SYNC":               3,
+		"CHANNEL_INFO":               4,
+	}
+)
+
+func (x ChannelAction) Enum() *ChannelAction {
+	p := new(ChannelAction)
+	*p = x
+	return p
+}
+
+func (x ChannelAction) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ChannelAction) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_teleghost_proto_enumTypes[5].Descriptor()
+}
+
+func (ChannelAction) Type() protoreflect.EnumType {
+	return &file_proto_teleghost_proto_enumTypes[5]
+}
+
+func (x ChannelAction) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ChannelAction.Descriptor instead.
+func (ChannelAction) EnumDescriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{5}
+}
+
+// MailboxOp — операция почтового сервера GhostMail
+type MailboxOp int32
+
+const (
+	MailboxOp_MAILBOX_OP_UNSPECIFIED MailboxOp = 0
+	MailboxOp_MAILBOX_CHALLENGE      MailboxOp = 1 // Получить одноразовый challenge для подписи
+	MailboxOp_MAILBOX_REGISTER       MailboxOp = 2 // Завести ящик (подписано владельцем)
+	MailboxOp_MAILBOX_DEPOSIT        MailboxOp = 3 // Оставить письмо в чужом ящике
+	MailboxOp_MAILBOX_FETCH          MailboxOp = 4 // Забрать письма (подписано владельцем)
+	MailboxOp_MAILBOX_ACK            MailboxOp = 5 // Подтвердить получение писем (подписано владельцем)
+	MailboxOp_MAILBOX_PEER_HELLO     MailboxOp = 6 // Федерация: представиться доверенному серверу
+	MailboxOp_MAILBOX_PEER_AUTH      MailboxOp = 7 // Федерация: подписать challenge сервера
+	MailboxOp_MAILBOX_RELAY          MailboxOp = 8 // Федерация: передать письма (после PEER_AUTH)
+)
+
+// Enum value maps for MailboxOp.
+var (
+	MailboxOp_name = map[int32]string{
+		0: "MAILBOX_OP_UNSPECIFIED",
+		1: "MAILBOX_CHALLENGE",
+		2: "MAILBOX_REGISTER",
+		3: "MAILBOX_DEPOSIT",
+		4: "MAILBOX_FETCH",
+		5: "MAILBOX_ACK",
+		6: "MAILBOX_PEER_HELLO",
+		7: "MAILBOX_PEER_AUTH",
+		8: "MAILBOX_RELAY",
+	}
+	MailboxOp_value = map[string]int32{
+		"MAILBOX_OP_UNSPECIFIED": 0,
+		"MAILBOX_CHALLENGE":      1,
+		"MAILBOX_REGISTER":       2,
+		"MAILBOX_DEPOSIT":        3,
+		"MAILBOX_FETCH":          4,
+		"MAILBOX_ACK":            5,
+		"MAILBOX_PEER_HELLO":     6,
+		"MAILBOX_PEER_AUTH":      7,
+		"MAILBOX_RELAY":          8,
+	}
+)
+
+func (x MailboxOp) Enum() *MailboxOp {
+	p := new(MailboxOp)
+	*p = x
+	return p
+}
+
+func (x MailboxOp) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MailboxOp) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_teleghost_proto_enumTypes[6].Descriptor()
+}
+
+func (MailboxOp) Type() protoreflect.EnumType {
+	return &file_proto_teleghost_proto_enumTypes[6]
+}
+
+func (x MailboxOp) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MailboxOp.Descriptor instead.
+func (MailboxOp) EnumDescriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{6}
+}
+
+// MailboxStatus — результат запроса к почтовому серверу
+type MailboxStatus int32
+
+const (
+	MailboxStatus_MAILBOX_OK      MailboxStatus = 0
+	MailboxStatus_MAILBOX_ERROR   MailboxStatus = 1 // Некорректный запрос
+	MailboxStatus_MAILBOX_UNKNOWN MailboxStatus = 2 // Ящик не зарегистрирован
+	MailboxStatus_MAILBOX_QUOTA   MailboxStatus = 3 // Превышена квота
+	MailboxStatus_MAILBOX_DENIED  MailboxStatus = 4 // Неверная подпись или нет challenge
+)
+
+// Enum value maps for MailboxStatus.
+var (
+	MailboxStatus_name = map[int32]string{
+		0: "MAILBOX_OK",
+		1: "MAILBOX_ERROR",
+		2: "MAILBOX_UNKNOWN",
+		3: "MAILBOX_QUOTA",
+		4: "MAILBOX_DENIED",
+	}
+	MailboxStatus_value = map[string]int32{
+		"MAILBOX_OK":      0,
+		"MAILBOX_ERROR":   1,
+		"MAILBOX_UNKNOWN": 2,
+		"MAILBOX_QUOTA":   3,
+		"MAILBOX_DENIED":  4,
+	}
+)
+
+func (x MailboxStatus) Enum() *MailboxStatus {
+	p := new(MailboxStatus)
+	*p = x
+	return p
+}
+
+func (x MailboxStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MailboxStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_teleghost_proto_enumTypes[7].Descriptor()
+}
+
+func (MailboxStatus) Type() protoreflect.EnumType {
+	return &file_proto_teleghost_proto_enumTypes[7]
+}
+
+func (x MailboxStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MailboxStatus.Descriptor instead.
+func (MailboxStatus) EnumDescriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{7}
+}
+
 // Packet — универсальная обёртка для всех сообщений в сети
 type Packet struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
-	// Версия протокола для обратной совместимости
-	Version uint32 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
-	// Тип содержимого пакета
-	Type PacketType `protobuf:"varint,2,opt,name=type,proto3,enum=teleghost.PacketType" json:"type,omitempty"`
-	// Публичный ключ отправителя (Ed25519, 32 bytes)
-	SenderPubKey []byte `protobuf:"bytes,3,opt,name=sender_pub_key,json=senderPubKey,proto3" json:"sender_pub_key,omitempty"`
-	// Подпись payload (Ed25519 signature, 64 bytes)
-	Signature []byte `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
-	// Зашифрованное/сериализованное содержимое (в зависимости от type)
-	Payload       []byte `protobuf:"bytes,5,opt,name=payload,proto3" json:"payload,omitempty"`
+	// Версия протокола для обратной совместимости
+	Version uint32 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	// Тип содержимого пакета
+	Type PacketType `protobuf:"varint,2,opt,name=type,proto3,enum=teleghost.PacketType" json:"type,omitempty"`
+	// Публичный ключ отправителя (Ed25519, 32 bytes)
+	SenderPubKey []byte `protobuf:"bytes,3,opt,name=sender_pub_key,json=senderPubKey,proto3" json:"sender_pub_key,omitempty"`
+	// Подпись payload (Ed25519 signature, 64 bytes)
+	Signature []byte `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	// Зашифрованное/сериализованное содержимое (в зависимости от type)
+	Payload []byte `protobuf:"bytes,5,opt,name=payload,proto3" json:"payload,omitempty"`
+	// ID E2EE-сессии, ключом которой зашифрован payload (пусто — открытый текст)
+	SessionId []byte `protobuf:"bytes,6,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// Время отправки (unix ms) и случайный nonce — защита от повтора пакетов.
+	// Входят в подпись начиная с версии 2.
+	Timestamp int64  `protobuf:"varint,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Nonce     []byte `protobuf:"bytes,8,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	// Публичный ключ получателя (пусто — ещё неизвестен, только для открытых пакетов).
+	// Начиная с версии 3 подпись покрывает весь конверт: версию, тип, ключи отправителя
+	// и получателя, ID сессии, payload, timestamp и nonce.
+	RecipientPubKey []byte `protobuf:"bytes,9,opt,name=recipient_pub_key,json=recipientPubKey,proto3" json:"recipient_pub_key,omitempty"`
+	// Proof-of-work штамп: незнакомцы прикладывают его к пакетам, которыми начинают разговор.
+	// В подпись не входит — штамп сам привязан к получателю, времени и payload.
+	Stamp *Stamp `protobuf:"bytes,10,opt,name=stamp,proto3" json:"stamp,omitempty"`
+	// Эфемерный X25519 ключ отправителя, если payload запечатан на статический ключ получателя
+	// из его адреса: так незнакомец доставляет первое сообщение без ответного handshake.
+	// Входит в подпись.
+	SealedEphemeral []byte `protobuf:"bytes,11,opt,name=sealed_ephemeral,json=sealedEphemeral,proto3" json:"sealed_ephemeral,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Packet) Reset() {
+	*x = Packet{}
+	mi := &file_proto_teleghost_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Packet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Packet) ProtoMessage() {}
+
+func (x *Packet) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Packet.ProtoReflect.Descriptor instead.
+func (*Packet) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Packet) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Packet) GetType() PacketType {
+	if x != nil {
+		return x.Type
+	}
+	return PacketType_PACKET_TYPE_UNSPECIFIED
+}
+
+func (x *Packet) GetSenderPubKey() []byte {
+	if x != nil {
+		return x.SenderPubKey
+	}
+	return nil
+}
+
+func (x *Packet) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *Packet) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Packet) GetSessionId() []byte {
+	if x != nil {
+		return x.SessionId
+	}
+	return nil
+}
+
+func (x *Packet) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Packet) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+func (x *Packet) GetRecipientPubKey() []byte {
+	if x != nil {
+		return x.RecipientPubKey
+	}
+	return nil
+}
+
+func (x *Packet) GetStamp() *Stamp {
+	if x != nil {
+		return x.Stamp
+	}
+	return nil
+}
+
+func (x *Packet) GetSealedEphemeral() []byte {
+	if x != nil {
+		return x.SealedEphemeral
+	}
+	return nil
+}
+
+// Stamp — штамп hashcash: хэш пакета со счётчиком начинается с нужного числа нулевых бит
+type Stamp struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Algorithm     StampAlgorithm         `protobuf:"varint,1,opt,name=algorithm,proto3,enum=teleghost.StampAlgorithm" json:"algorithm,omitempty"`
+	Counter       uint64                 `protobuf:"varint,2,opt,name=counter,proto3" json:"counter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Stamp) Reset() {
+	*x = Stamp{}
+	mi := &file_proto_teleghost_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stamp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stamp) ProtoMessage() {}
+
+func (x *Stamp) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stamp.ProtoReflect.Descriptor instead.
+func (*Stamp) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Stamp) GetAlgorithm() StampAlgorithm {
+	if x != nil {
+		return x.Algorithm
+	}
+	return StampAlgorithm_STAMP_SHA256
+}
+
+func (x *Stamp) GetCounter() uint64 {
+	if x != nil {
+		return x.Counter
+	}
+	return 0
+}
+
+// Attachment — вложение к сообщению (изображение, файл)
+type Attachment struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Filename      string                 `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	MimeType      string                 `protobuf:"bytes,3,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"`
+	Size          int64                  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	Data          []byte                 `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`                                      // Данные файла
+	IsCompressed  bool                   `protobuf:"varint,6,opt,name=is_compressed,json=isCompressed,proto3" json:"is_compressed,omitempty"` // true если это сжатая версия
+	Width         int32                  `protobuf:"varint,7,opt,name=width,proto3" json:"width,omitempty"`                                   // Ширина (для изображений)
+	Height        int32                  `protobuf:"varint,8,opt,name=height,proto3" json:"height,omitempty"`                                 // Высота (для изображений)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Attachment) Reset() {
+	*x = Attachment{}
+	mi := &file_proto_teleghost_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Attachment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Attachment) ProtoMessage() {}
+
+func (x *Attachment) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Attachment.ProtoReflect.Descriptor instead.
+func (*Attachment) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Attachment) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Attachment) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *Attachment) GetMimeType() string {
+	if x != nil {
+		return x.MimeType
+	}
+	return ""
+}
+
+func (x *Attachment) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Attachment) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Attachment) GetIsCompressed() bool {
+	if x != nil {
+		return x.IsCompressed
+	}
+	return false
+}
+
+func (x *Attachment) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *Attachment) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+// TextMessage — текстовое сообщение в чате
+type TextMessage struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Уникальный ID чата (обычно hash публичных ключей обоих участников)
+	ChatId string `protobuf:"bytes,1,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	// Содержимое сообщения (UTF-8)
+	Content string `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	// Unix timestamp в миллисекундах
+	Timestamp int64 `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// Уникальный ID сообщения (UUID)
+	MessageId string `protobuf:"bytes,4,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	// Вложения
+	Attachments []*Attachment `protobuf:"bytes,5,rep,name=attachments,proto3" json:"attachments,omitempty"`
+	// ID сообщения, на которое это ответ (опционально)
+	ReplyToId string `protobuf:"bytes,6,opt,name=reply_to_id,json=replyToId,proto3" json:"reply_to_id,omitempty"`
+	// ID группы (пусто — личное сообщение); chat_id в этом случае равен group_id
+	GroupId string `protobuf:"bytes,7,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	// Версия состава группы, которую видит отправитель
+	GroupEpoch    uint64 `protobuf:"varint,8,opt,name=group_epoch,json=groupEpoch,proto3" json:"group_epoch,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TextMessage) Reset() {
+	*x = TextMessage{}
+	mi := &file_proto_teleghost_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TextMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TextMessage) ProtoMessage() {}
+
+func (x *TextMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TextMessage.ProtoReflect.Descriptor instead.
+func (*TextMessage) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *TextMessage) GetChatId() string {
+	if x != nil {
+		return x.ChatId
+	}
+	return ""
+}
+
+func (x *TextMessage) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *TextMessage) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *TextMessage) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *TextMessage) GetAttachments() []*Attachment {
+	if x != nil {
+		return x.Attachments
+	}
+	return nil
+}
+
+func (x *TextMessage) GetReplyToId() string {
+	if x != nil {
+		return x.ReplyToId
+	}
+	return ""
+}
+
+func (x *TextMessage) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *TextMessage) GetGroupEpoch() uint64 {
+	if x != nil {
+		return x.GroupEpoch
+	}
+	return 0
+}
+
+// ProfileUpdate — обновление профиля пользователя
+type ProfileUpdate struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Никнейм пользователя (max 64 символа)
+	Nickname string `protobuf:"bytes,1,opt,name=nickname,proto3" json:"nickname,omitempty"`
+	// Описание/био (max 256 символов)
+	Bio string `protobuf:"bytes,2,opt,name=bio,proto3" json:"bio,omitempty"`
+	// Аватар (сжатое изображение, max 64KB, WebP/JPEG)
+	Avatar []byte `protobuf:"bytes,3,opt,name=avatar,proto3" json:"avatar,omitempty"`
+	// I2P адрес почтового сервера GhostMail, куда можно оставить письмо,
+	// пока мы не в сети (пусто — ящика нет)
+	MailboxAddress string `protobuf:"bytes,4,opt,name=mailbox_address,json=mailboxAddress,proto3" json:"mailbox_address,omitempty"`
+	// b33 адрес, если наш leaseSet зашифрован (пусто — обычный адрес)
+	BlindedAddress string `protobuf:"bytes,5,opt,name=blinded_address,json=blindedAddress,proto3" json:"blinded_address,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ProfileUpdate) Reset() {
+	*x = ProfileUpdate{}
+	mi := &file_proto_teleghost_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProfileUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProfileUpdate) ProtoMessage() {}
+
+func (x *ProfileUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProfileUpdate.ProtoReflect.Descriptor instead.
+func (*ProfileUpdate) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ProfileUpdate) GetNickname() string {
+	if x != nil {
+		return x.Nickname
+	}
+	return ""
+}
+
+func (x *ProfileUpdate) GetBio() string {
+	if x != nil {
+		return x.Bio
+	}
+	return ""
+}
+
+func (x *ProfileUpdate) GetAvatar() []byte {
+	if x != nil {
+		return x.Avatar
+	}
+	return nil
+}
+
+func (x *ProfileUpdate) GetMailboxAddress() string {
+	if x != nil {
+		return x.MailboxAddress
+	}
+	return ""
+}
+
+func (x *ProfileUpdate) GetBlindedAddress() string {
+	if x != nil {
+		return x.BlindedAddress
+	}
+	return ""
+}
+
+// Handshake — начало сессии между двумя пирами
+type Handshake struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Публичный ключ инициатора
+	InitiatorPubKey []byte `protobuf:"bytes,1,opt,name=initiator_pub_key,json=initiatorPubKey,proto3" json:"initiator_pub_key,omitempty"`
+	// Временный ключ для ECDH (X25519)
+	EphemeralPubKey []byte `protobuf:"bytes,2,opt,name=ephemeral_pub_key,json=ephemeralPubKey,proto3" json:"ephemeral_pub_key,omitempty"`
+	// Nonce для предотвращения replay атак
+	Nonce []byte `protobuf:"bytes,3,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	// Timestamp для проверки свежести
+	Timestamp int64 `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// Никнейм отправителя (для отображения в UI)
+	Nickname string `protobuf:"bytes,5,opt,name=nickname,proto3" json:"nickname,omitempty"`
+	// I2P адрес отправителя (для обратной связи)
+	I2PAddress string `protobuf:"bytes,6,opt,name=i2p_address,json=i2pAddress,proto3" json:"i2p_address,omitempty"`
+	// Аватар отправителя (сжатое изображение)
+	Avatar []byte `protobuf:"bytes,7,opt,name=avatar,proto3" json:"avatar,omitempty"`
+	// true — это ответ на чужой handshake
+	IsResponse bool `protobuf:"varint,8,opt,name=is_response,json=isResponse,proto3" json:"is_response,omitempty"`
+	// Эфемерный ключ инициатора, на который отвечаем (только для ответа)
+	ReplyToEphemeral []byte `protobuf:"bytes,9,opt,name=reply_to_ephemeral,json=replyToEphemeral,proto3" json:"reply_to_ephemeral,omitempty"`
+	// Поддерживаемый диапазон версий протокола (0 — старая сборка, только версия 1)
+	MinVersion uint32 `protobuf:"varint,10,opt,name=min_version,json=minVersion,proto3" json:"min_version,omitempty"`
+	MaxVersion uint32 `protobuf:"varint,11,opt,name=max_version,json=maxVersion,proto3" json:"max_version,omitempty"`
+	// Битовая маска возможностей клиента (messenger.Capability)
+	Capabilities uint64 `protobuf:"varint,12,opt,name=capabilities,proto3" json:"capabilities,omitempty"`
+	// X25519 ключ отправителя для авторизации в зашифрованных leaseSet (32 байта)
+	LeaseSetAuthKey []byte `protobuf:"bytes,13,opt,name=lease_set_auth_key,json=leaseSetAuthKey,proto3" json:"lease_set_auth_key,omitempty"`
+	// b33 адрес отправителя, если его leaseSet зашифрован (пусто — обычный адрес)
+	BlindedAddress string `protobuf:"bytes,14,opt,name=blinded_address,json=blindedAddress,proto3" json:"blinded_address,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Handshake) Reset() {
+	*x = Handshake{}
+	mi := &file_proto_teleghost_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Handshake) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Handshake) ProtoMessage() {}
+
+func (x *Handshake) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Handshake.ProtoReflect.Descriptor instead.
+func (*Handshake) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Handshake) GetInitiatorPubKey() []byte {
+	if x != nil {
+		return x.InitiatorPubKey
+	}
+	return nil
+}
+
+func (x *Handshake) GetEphemeralPubKey() []byte {
+	if x != nil {
+		return x.EphemeralPubKey
+	}
+	return nil
+}
+
+func (x *Handshake) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+func (x *Handshake) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Handshake) GetNickname() string {
+	if x != nil {
+		return x.Nickname
+	}
+	return ""
+}
+
+func (x *Handshake) GetI2PAddress() string {
+	if x != nil {
+		return x.I2PAddress
+	}
+	return ""
+}
+
+func (x *Handshake) GetAvatar() []byte {
+	if x != nil {
+		return x.Avatar
+	}
+	return nil
+}
+
+func (x *Handshake) GetIsResponse() bool {
+	if x != nil {
+		return x.IsResponse
+	}
+	return false
+}
+
+func (x *Handshake) GetReplyToEphemeral() []byte {
+	if x != nil {
+		return x.ReplyToEphemeral
+	}
+	return nil
+}
+
+func (x *Handshake) GetMinVersion() uint32 {
+	if x != nil {
+		return x.MinVersion
+	}
+	return 0
+}
+
+func (x *Handshake) GetMaxVersion() uint32 {
+	if x != nil {
+		return x.MaxVersion
+	}
+	return 0
+}
+
+func (x *Handshake) GetCapabilities() uint64 {
+	if x != nil {
+		return x.Capabilities
+	}
+	return 0
+}
+
+func (x *Handshake) GetLeaseSetAuthKey() []byte {
+	if x != nil {
+		return x.LeaseSetAuthKey
+	}
+	return nil
+}
+
+func (x *Handshake) GetBlindedAddress() string {
+	if x != nil {
+		return x.BlindedAddress
+	}
+	return ""
+}
+
+// MessageEdit — редактирование существующего сообщения
+type MessageEdit struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID редактируемого сообщения
+	MessageId string `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	// Новое содержимое сообщения
+	NewContent string `protobuf:"bytes,2,opt,name=new_content,json=newContent,proto3" json:"new_content,omitempty"`
+	// Timestamp редактирования
+	Timestamp int64 `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// ID чата (для маршрутизации)
+	ChatId        string `protobuf:"bytes,4,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageEdit) Reset() {
+	*x = MessageEdit{}
+	mi := &file_proto_teleghost_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessageEdit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageEdit) ProtoMessage() {}
+
+func (x *MessageEdit) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageEdit.ProtoReflect.Descriptor instead.
+func (*MessageEdit) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *MessageEdit) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *MessageEdit) GetNewContent() string {
+	if x != nil {
+		return x.NewContent
+	}
+	return ""
+}
+
+func (x *MessageEdit) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *MessageEdit) GetChatId() string {
+	if x != nil {
+		return x.ChatId
+	}
+	return ""
+}
+
+// MessageDelete — удаление сообщения
+type MessageDelete struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID удаляемого сообщения
+	MessageId string `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	// Timestamp удаления
+	Timestamp int64 `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// ID чата (для маршрутизации)
+	ChatId string `protobuf:"bytes,3,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	// Удалить у всех (true) или только локально (false)
+	DeleteForAll  bool `protobuf:"varint,4,opt,name=delete_for_all,json=deleteForAll,proto3" json:"delete_for_all,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageDelete) Reset() {
+	*x = MessageDelete{}
+	mi := &file_proto_teleghost_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessageDelete) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageDelete) ProtoMessage() {}
+
+func (x *MessageDelete) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageDelete.ProtoReflect.Descriptor instead.
+func (*MessageDelete) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *MessageDelete) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *MessageDelete) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *MessageDelete) GetChatId() string {
+	if x != nil {
+		return x.ChatId
+	}
+	return ""
+}
+
+func (x *MessageDelete) GetDeleteForAll() bool {
+	if x != nil {
+		return x.DeleteForAll
+	}
+	return false
+}
+
+// FileOffer — предложение отправки файлов (для подтверждения)
+type FileOffer struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	MessageId string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Filenames []string               `protobuf:"bytes,2,rep,name=filenames,proto3" json:"filenames,omitempty"`
+	TotalSize int64                  `protobuf:"varint,3,opt,name=total_size,json=totalSize,proto3" json:"total_size,omitempty"`
+	FileCount int32                  `protobuf:"varint,4,opt,name=file_count,json=fileCount,proto3" json:"file_count,omitempty"`
+	// Можно добавить previews, но пока опустим для простоты
+	ChatId string `protobuf:"bytes,5,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	// Описание файлов для передачи частями (пусто у старых клиентов)
+	Files         []*FileManifest `protobuf:"bytes,6,rep,name=files,proto3" json:"files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileOffer) Reset() {
+	*x = FileOffer{}
+	mi := &file_proto_teleghost_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileOffer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileOffer) ProtoMessage() {}
+
+func (x *FileOffer) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileOffer.ProtoReflect.Descriptor instead.
+func (*FileOffer) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *FileOffer) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *FileOffer) GetFilenames() []string {
+	if x != nil {
+		return x.Filenames
+	}
+	return nil
+}
+
+func (x *FileOffer) GetTotalSize() int64 {
+	if x != nil {
+		return x.TotalSize
+	}
+	return 0
+}
+
+func (x *FileOffer) GetFileCount() int32 {
+	if x != nil {
+		return x.FileCount
+	}
+	return 0
+}
+
+func (x *FileOffer) GetChatId() string {
+	if x != nil {
+		return x.ChatId
+	}
+	return ""
+}
+
+func (x *FileOffer) GetFiles() []*FileManifest {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+// FileManifest — описание одного файла в предложении
+type FileManifest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	FileId   string                 `protobuf:"bytes,1,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	Filename string                 `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	MimeType string                 `protobuf:"bytes,3,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"`
+	Size     int64                  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	// SHA-256 всего файла
+	Sha256 []byte `protobuf:"bytes,5,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	// Размер части (последняя может быть меньше)
+	ChunkSize     uint32 `protobuf:"varint,6,opt,name=chunk_size,json=chunkSize,proto3" json:"chunk_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileManifest) Reset() {
+	*x = FileManifest{}
+	mi := &file_proto_teleghost_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileManifest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileManifest) ProtoMessage() {}
+
+func (x *FileManifest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileManifest.ProtoReflect.Descriptor instead.
+func (*FileManifest) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *FileManifest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *FileManifest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *FileManifest) GetMimeType() string {
+	if x != nil {
+		return x.MimeType
+	}
+	return ""
+}
+
+func (x *FileManifest) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *FileManifest) GetSha256() []byte {
+	if x != nil {
+		return x.Sha256
+	}
+	return nil
+}
+
+func (x *FileManifest) GetChunkSize() uint32 {
+	if x != nil {
+		return x.ChunkSize
+	}
+	return 0
+}
+
+// FileResponse — ответ на предложение
+type FileResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Accepted      bool                   `protobuf:"varint,2,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	ChatId        string                 `protobuf:"bytes,3,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileResponse) Reset() {
+	*x = FileResponse{}
+	mi := &file_proto_teleghost_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileResponse) ProtoMessage() {}
+
+func (x *FileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileResponse.ProtoReflect.Descriptor instead.
+func (*FileResponse) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *FileResponse) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *FileResponse) GetAccepted() bool {
+	if x != nil {
+		return x.Accepted
+	}
+	return false
+}
+
+func (x *FileResponse) GetChatId() string {
+	if x != nil {
+		return x.ChatId
+	}
+	return ""
+}
+
+// FileChunk — часть файла
+type FileChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	FileId        string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	Index         uint32                 `protobuf:"varint,3,opt,name=index,proto3" json:"index,omitempty"`
+	Data          []byte                 `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	ChatId        string                 `protobuf:"bytes,5,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileChunk) Reset() {
+	*x = FileChunk{}
+	mi := &file_proto_teleghost_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileChunk) ProtoMessage() {}
+
+func (x *FileChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileChunk.ProtoReflect.Descriptor instead.
+func (*FileChunk) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *FileChunk) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *FileChunk) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *FileChunk) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *FileChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *FileChunk) GetChatId() string {
+	if x != nil {
+		return x.ChatId
+	}
+	return ""
+}
+
+// FileChunkAck — подтверждение: все части до next_index получены
+type FileChunkAck struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	FileId        string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	NextIndex     uint32                 `protobuf:"varint,3,opt,name=next_index,json=nextIndex,proto3" json:"next_index,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileChunkAck) Reset() {
+	*x = FileChunkAck{}
+	mi := &file_proto_teleghost_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileChunkAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileChunkAck) ProtoMessage() {}
+
+func (x *FileChunkAck) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileChunkAck.ProtoReflect.Descriptor instead.
+func (*FileChunkAck) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *FileChunkAck) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *FileChunkAck) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *FileChunkAck) GetNextIndex() uint32 {
+	if x != nil {
+		return x.NextIndex
+	}
+	return 0
+}
+
+// FileProgress — с какой части продолжить файл
+type FileProgress struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FileId        string                 `protobuf:"bytes,1,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	NextIndex     uint32                 `protobuf:"varint,2,opt,name=next_index,json=nextIndex,proto3" json:"next_index,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileProgress) Reset() {
+	*x = FileProgress{}
+	mi := &file_proto_teleghost_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileProgress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileProgress) ProtoMessage() {}
+
+func (x *FileProgress) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileProgress.ProtoReflect.Descriptor instead.
+func (*FileProgress) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *FileProgress) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *FileProgress) GetNextIndex() uint32 {
+	if x != nil {
+		return x.NextIndex
+	}
+	return 0
+}
+
+// FileResume — запрос получателя продолжить прерванную передачу
+type FileResume struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Files         []*FileProgress        `protobuf:"bytes,2,rep,name=files,proto3" json:"files,omitempty"`
+	ChatId        string                 `protobuf:"bytes,3,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileResume) Reset() {
+	*x = FileResume{}
+	mi := &file_proto_teleghost_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileResume) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileResume) ProtoMessage() {}
+
+func (x *FileResume) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileResume.ProtoReflect.Descriptor instead.
+func (*FileResume) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *FileResume) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *FileResume) GetFiles() []*FileProgress {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *FileResume) GetChatId() string {
+	if x != nil {
+		return x.ChatId
+	}
+	return ""
+}
+
+// FileStream — заголовок потоковой передачи файла. За ним в том же соединении идут
+// кадры тела: по одной части файла в кадре, каждая зашифрована ключом key
+type FileStream struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	MessageId string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	FileId    string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	ChatId    string                 `protobuf:"bytes,3,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	// Номер первой части в потоке
+	StartIndex uint32 `protobuf:"varint,4,opt,name=start_index,json=startIndex,proto3" json:"start_index,omitempty"`
+	// Сколько частей будет в потоке
+	ChunkCount uint32 `protobuf:"varint,5,opt,name=chunk_count,json=chunkCount,proto3" json:"chunk_count,omitempty"`
+	// Одноразовый ключ ChaCha20-Poly1305 для кадров тела
+	Key           []byte `protobuf:"bytes,6,opt,name=key,proto3" json:"key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileStream) Reset() {
+	*x = FileStream{}
+	mi := &file_proto_teleghost_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileStream) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileStream) ProtoMessage() {}
+
+func (x *FileStream) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileStream.ProtoReflect.Descriptor instead.
+func (*FileStream) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *FileStream) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *FileStream) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *FileStream) GetChatId() string {
+	if x != nil {
+		return x.ChatId
+	}
+	return ""
+}
+
+func (x *FileStream) GetStartIndex() uint32 {
+	if x != nil {
+		return x.StartIndex
+	}
+	return 0
+}
+
+func (x *FileStream) GetChunkCount() uint32 {
+	if x != nil {
+		return x.ChunkCount
+	}
+	return 0
+}
+
+func (x *FileStream) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+// Receipt — отчёт о доставке или прочтении сообщений
+type Receipt struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID сообщений, к которым относится отчёт
+	MessageIds []string `protobuf:"bytes,1,rep,name=message_ids,json=messageIds,proto3" json:"message_ids,omitempty"`
+	// Вид отчёта
+	Kind ReceiptKind `protobuf:"varint,2,opt,name=kind,proto3,enum=teleghost.ReceiptKind" json:"kind,omitempty"`
+	// ID чата
+	ChatId string `protobuf:"bytes,3,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	// Timestamp отчёта
+	Timestamp     int64 `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Receipt) Reset() {
+	*x = Receipt{}
+	mi := &file_proto_teleghost_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Receipt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Receipt) ProtoMessage() {}
+
+func (x *Receipt) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Receipt.ProtoReflect.Descriptor instead.
+func (*Receipt) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Receipt) GetMessageIds() []string {
+	if x != nil {
+		return x.MessageIds
+	}
+	return nil
+}
+
+func (x *Receipt) GetKind() ReceiptKind {
+	if x != nil {
+		return x.Kind
+	}
+	return ReceiptKind_RECEIPT_KIND_UNSPECIFIED
+}
+
+func (x *Receipt) GetChatId() string {
+	if x != nil {
+		return x.ChatId
+	}
+	return ""
+}
+
+func (x *Receipt) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+// Typing — индикатор набора текста
+type Typing struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID чата
+	ChatId string `protobuf:"bytes,1,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	// true — начал набирать, false — перестал
+	Typing        bool `protobuf:"varint,2,opt,name=typing,proto3" json:"typing,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Typing) Reset() {
+	*x = Typing{}
+	mi := &file_proto_teleghost_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Typing) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Typing) ProtoMessage() {}
+
+func (x *Typing) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Typing.ProtoReflect.Descriptor instead.
+func (*Typing) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Typing) GetChatId() string {
+	if x != nil {
+		return x.ChatId
+	}
+	return ""
+}
+
+func (x *Typing) GetTyping() bool {
+	if x != nil {
+		return x.Typing
+	}
+	return false
+}
+
+// Presence — статус в сети
+type Presence struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// true — в сети, false — выходит из сети
+	Online bool `protobuf:"varint,1,opt,name=online,proto3" json:"online,omitempty"`
+	// true — отправитель скрывает от нас свой статус
+	Hidden        bool `protobuf:"varint,2,opt,name=hidden,proto3" json:"hidden,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Packet) Reset() {
-	*x = Packet{}
-	mi := &file_proto_teleghost_proto_msgTypes[0]
+func (x *Presence) Reset() {
+	*x = Presence{}
+	mi := &file_proto_teleghost_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Packet) String() string {
+func (x *Presence) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Packet) ProtoMessage() {}
+func (*Presence) ProtoMessage() {}
 
-func (x *Packet) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_teleghost_proto_msgTypes[0]
+func (x *Presence) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -134,76 +2043,126 @@ func (x *Packet) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Packet.ProtoReflect.Descriptor instead.
-func (*Packet) Descriptor() ([]byte, []int) {
-	return file_proto_teleghost_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use Presence.ProtoReflect.Descriptor instead.
+func (*Presence) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{18}
 }
 
-func (x *Packet) GetVersion() uint32 {
+func (x *Presence) GetOnline() bool {
 	if x != nil {
-		return x.Version
+		return x.Online
 	}
-	return 0
+	return false
 }
 
-func (x *Packet) GetType() PacketType {
+func (x *Presence) GetHidden() bool {
 	if x != nil {
-		return x.Type
+		return x.Hidden
 	}
-	return PacketType_PACKET_TYPE_UNSPECIFIED
+	return false
 }
 
-func (x *Packet) GetSenderPubKey() []byte {
+// GroupMember — участник группы
+type GroupMember struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PubKey        string                 `protobuf:"bytes,1,opt,name=pub_key,json=pubKey,proto3" json:"pub_key,omitempty"`
+	I2PAddress    string                 `protobuf:"bytes,2,opt,name=i2p_address,json=i2pAddress,proto3" json:"i2p_address,omitempty"`
+	Nickname      string                 `protobuf:"bytes,3,opt,name=nickname,proto3" json:"nickname,omitempty"`
+	Role          GroupRole              `protobuf:"varint,4,opt,name=role,proto3,enum=teleghost.GroupRole" json:"role,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GroupMember) Reset() {
+	*x = GroupMember{}
+	mi := &file_proto_teleghost_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GroupMember) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GroupMember) ProtoMessage() {}
+
+func (x *GroupMember) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[19]
 	if x != nil {
-		return x.SenderPubKey
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return nil
+	return mi.MessageOf(x)
 }
 
-func (x *Packet) GetSignature() []byte {
+// Deprecated: Use GroupMember.ProtoReflect.Descriptor instead.
+func (*GroupMember) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *GroupMember) GetPubKey() string {
 	if x != nil {
-		return x.Signature
+		return x.PubKey
 	}
-	return nil
+	return ""
 }
 
-func (x *Packet) GetPayload() []byte {
+func (x *GroupMember) GetI2PAddress() string {
 	if x != nil {
-		return x.Payload
+		return x.I2PAddress
 	}
-	return nil
+	return ""
 }
 
-// Attachment — вложение к сообщению (изображение, файл)
-type Attachment struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Filename      string                 `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
-	MimeType      string                 `protobuf:"bytes,3,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"`
-	Size          int64                  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
-	Data          []byte                 `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`                                      // Данные файла
-	IsCompressed  bool                   `protobuf:"varint,6,opt,name=is_compressed,json=isCompressed,proto3" json:"is_compressed,omitempty"` // true если это сжатая версия
-	Width         int32                  `protobuf:"varint,7,opt,name=width,proto3" json:"width,omitempty"`                                   // Ширина (для изображений)
-	Height        int32                  `protobuf:"varint,8,opt,name=height,proto3" json:"height,omitempty"`                                 // Высота (для изображений)
+func (x *GroupMember) GetNickname() string {
+	if x != nil {
+		return x.Nickname
+	}
+	return ""
+}
+
+func (x *GroupMember) GetRole() GroupRole {
+	if x != nil {
+		return x.Role
+	}
+	return GroupRole_GROUP_ROLE_MEMBER
+}
+
+// GroupState — состав группы, подписанный администратором
+type GroupState struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	GroupId string                 `protobuf:"bytes,1,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	Name    string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// Растёт с каждым изменением состава
+	Epoch uint64 `protobuf:"varint,3,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	// Время изменения (unix ms)
+	UpdatedAt int64          `protobuf:"varint,4,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Members   []*GroupMember `protobuf:"bytes,5,rep,name=members,proto3" json:"members,omitempty"`
+	// Администратор, подписавший состав
+	SignerPubKey  string `protobuf:"bytes,6,opt,name=signer_pub_key,json=signerPubKey,proto3" json:"signer_pub_key,omitempty"`
+	Signature     []byte `protobuf:"bytes,7,opt,name=signature,proto3" json:"signature,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Attachment) Reset() {
-	*x = Attachment{}
-	mi := &file_proto_teleghost_proto_msgTypes[1]
+func (x *GroupState) Reset() {
+	*x = GroupState{}
+	mi := &file_proto_teleghost_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Attachment) String() string {
+func (x *GroupState) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Attachment) ProtoMessage() {}
+func (*GroupState) ProtoMessage() {}
 
-func (x *Attachment) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_teleghost_proto_msgTypes[1]
+func (x *GroupState) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -214,101 +2173,152 @@ func (x *Attachment) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Attachment.ProtoReflect.Descriptor instead.
-func (*Attachment) Descriptor() ([]byte, []int) {
-	return file_proto_teleghost_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use GroupState.ProtoReflect.Descriptor instead.
+func (*GroupState) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{20}
 }
 
-func (x *Attachment) GetId() string {
+func (x *GroupState) GetGroupId() string {
 	if x != nil {
-		return x.Id
+		return x.GroupId
 	}
 	return ""
 }
 
-func (x *Attachment) GetFilename() string {
+func (x *GroupState) GetName() string {
 	if x != nil {
-		return x.Filename
+		return x.Name
 	}
 	return ""
 }
 
-func (x *Attachment) GetMimeType() string {
+func (x *GroupState) GetEpoch() uint64 {
 	if x != nil {
-		return x.MimeType
+		return x.Epoch
 	}
-	return ""
+	return 0
 }
 
-func (x *Attachment) GetSize() int64 {
+func (x *GroupState) GetUpdatedAt() int64 {
 	if x != nil {
-		return x.Size
+		return x.UpdatedAt
 	}
 	return 0
 }
 
-func (x *Attachment) GetData() []byte {
+func (x *GroupState) GetMembers() []*GroupMember {
 	if x != nil {
-		return x.Data
+		return x.Members
 	}
 	return nil
 }
 
-func (x *Attachment) GetIsCompressed() bool {
+func (x *GroupState) GetSignerPubKey() string {
 	if x != nil {
-		return x.IsCompressed
+		return x.SignerPubKey
 	}
-	return false
+	return ""
 }
 
-func (x *Attachment) GetWidth() int32 {
+func (x *GroupState) GetSignature() []byte {
 	if x != nil {
-		return x.Width
+		return x.Signature
 	}
-	return 0
+	return nil
 }
 
-func (x *Attachment) GetHeight() int32 {
+// GroupControl — управляющий пакет группы
+type GroupControl struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Action        GroupAction            `protobuf:"varint,1,opt,name=action,proto3,enum=teleghost.GroupAction" json:"action,omitempty"`
+	GroupId       string                 `protobuf:"bytes,2,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	State         *GroupState            `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GroupControl) Reset() {
+	*x = GroupControl{}
+	mi := &file_proto_teleghost_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GroupControl) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GroupControl) ProtoMessage() {}
+
+func (x *GroupControl) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[21]
 	if x != nil {
-		return x.Height
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return 0
+	return mi.MessageOf(x)
 }
 
-// TextMessage — текстовое сообщение в чате
-type TextMessage struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	// Уникальный ID чата (обычно hash публичных ключей обоих участников)
-	ChatId string `protobuf:"bytes,1,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
-	// Содержимое сообщения (UTF-8)
-	Content string `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
-	// Unix timestamp в миллисекундах
-	Timestamp int64 `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	// Уникальный ID сообщения (UUID)
-	MessageId string `protobuf:"bytes,4,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
-	// Вложения
-	Attachments []*Attachment `protobuf:"bytes,5,rep,name=attachments,proto3" json:"attachments,omitempty"`
-	// ID сообщения, на которое это ответ (опционально)
-	ReplyToId     string `protobuf:"bytes,6,opt,name=reply_to_id,json=replyToId,proto3" json:"reply_to_id,omitempty"`
+// Deprecated: Use GroupControl.ProtoReflect.Descriptor instead.
+func (*GroupControl) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *GroupControl) GetAction() GroupAction {
+	if x != nil {
+		return x.Action
+	}
+	return GroupAction_GROUP_ACTION_UNSPECIFIED
+}
+
+func (x *GroupControl) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *GroupControl) GetState() *GroupState {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+// ChannelPost — пост канала, подписанный владельцем
+type ChannelPost struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	ChannelId string                 `protobuf:"bytes,1,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
+	// Порядковый номер поста в канале (с 1, без пропусков)
+	Seq       uint64 `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	PostId    string `protobuf:"bytes,3,opt,name=post_id,json=postId,proto3" json:"post_id,omitempty"`
+	Content   string `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+	Timestamp int64  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// Владелец канала, подписавший пост
+	OwnerPubKey   string `protobuf:"bytes,6,opt,name=owner_pub_key,json=ownerPubKey,proto3" json:"owner_pub_key,omitempty"`
+	Signature     []byte `protobuf:"bytes,7,opt,name=signature,proto3" json:"signature,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *TextMessage) Reset() {
-	*x = TextMessage{}
-	mi := &file_proto_teleghost_proto_msgTypes[2]
+func (x *ChannelPost) Reset() {
+	*x = ChannelPost{}
+	mi := &file_proto_teleghost_proto_msgTypes[22]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *TextMessage) String() string {
+func (x *ChannelPost) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*TextMessage) ProtoMessage() {}
+func (*ChannelPost) ProtoMessage() {}
 
-func (x *TextMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_teleghost_proto_msgTypes[2]
+func (x *ChannelPost) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[22]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -319,81 +2329,88 @@ func (x *TextMessage) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use TextMessage.ProtoReflect.Descriptor instead.
-func (*TextMessage) Descriptor() ([]byte, []int) {
-	return file_proto_teleghost_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use ChannelPost.ProtoReflect.Descriptor instead.
+func (*ChannelPost) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{22}
 }
 
-func (x *TextMessage) GetChatId() string {
+func (x *ChannelPost) GetChannelId() string {
 	if x != nil {
-		return x.ChatId
+		return x.ChannelId
 	}
 	return ""
 }
 
-func (x *TextMessage) GetContent() string {
+func (x *ChannelPost) GetSeq() uint64 {
 	if x != nil {
-		return x.Content
+		return x.Seq
 	}
-	return ""
+	return 0
 }
 
-func (x *TextMessage) GetTimestamp() int64 {
+func (x *ChannelPost) GetPostId() string {
 	if x != nil {
-		return x.Timestamp
+		return x.PostId
 	}
-	return 0
+	return ""
 }
 
-func (x *TextMessage) GetMessageId() string {
+func (x *ChannelPost) GetContent() string {
 	if x != nil {
-		return x.MessageId
+		return x.Content
 	}
 	return ""
 }
 
-func (x *TextMessage) GetAttachments() []*Attachment {
+func (x *ChannelPost) GetTimestamp() int64 {
 	if x != nil {
-		return x.Attachments
+		return x.Timestamp
 	}
-	return nil
+	return 0
 }
 
-func (x *TextMessage) GetReplyToId() string {
+func (x *ChannelPost) GetOwnerPubKey() string {
 	if x != nil {
-		return x.ReplyToId
+		return x.OwnerPubKey
 	}
 	return ""
 }
 
-// ProfileUpdate — обновление профиля пользователя
-type ProfileUpdate struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	// Никнейм пользователя (max 64 символа)
-	Nickname string `protobuf:"bytes,1,opt,name=nickname,proto3" json:"nickname,omitempty"`
-	// Описание/био (max 256 символов)
-	Bio string `protobuf:"bytes,2,opt,name=bio,proto3" json:"bio,omitempty"`
-	// Аватар (сжатое изображение, max 64KB, WebP/JPEG)
-	Avatar        []byte `protobuf:"bytes,3,opt,name=avatar,proto3" json:"avatar,omitempty"`
+func (x *ChannelPost) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// ChannelControl — управляющий пакет канала
+type ChannelControl struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Action    ChannelAction          `protobuf:"varint,1,opt,name=action,proto3,enum=teleghost.ChannelAction" json:"action,omitempty"`
+	ChannelId string                 `protobuf:"bytes,2,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
+	AfterSeq  uint64                 `protobuf:"varint,3,opt,name=after_seq,json=afterSeq,proto3" json:"after_seq,omitempty"`
+	// Только для CHANNEL_INFO
+	Name          string `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	LastSeq       uint64 `protobuf:"varint,5,opt,name=last_seq,json=lastSeq,proto3" json:"last_seq,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ProfileUpdate) Reset() {
-	*x = ProfileUpdate{}
-	mi := &file_proto_teleghost_proto_msgTypes[3]
+func (x *ChannelControl) Reset() {
+	*x = ChannelControl{}
+	mi := &file_proto_teleghost_proto_msgTypes[23]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ProfileUpdate) String() string {
+func (x *ChannelControl) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ProfileUpdate) ProtoMessage() {}
+func (*ChannelControl) ProtoMessage() {}
 
-func (x *ProfileUpdate) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_teleghost_proto_msgTypes[3]
+func (x *ChannelControl) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[23]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -404,68 +2421,85 @@ func (x *ProfileUpdate) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ProfileUpdate.ProtoReflect.Descriptor instead.
-func (*ProfileUpdate) Descriptor() ([]byte, []int) {
-	return file_proto_teleghost_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use ChannelControl.ProtoReflect.Descriptor instead.
+func (*ChannelControl) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{23}
 }
 
-func (x *ProfileUpdate) GetNickname() string {
+func (x *ChannelControl) GetAction() ChannelAction {
 	if x != nil {
-		return x.Nickname
+		return x.Action
 	}
-	return ""
+	return ChannelAction_CHANNEL_ACTION_UNSPECIFIED
 }
 
-func (x *ProfileUpdate) GetBio() string {
+func (x *ChannelControl) GetChannelId() string {
 	if x != nil {
-		return x.Bio
+		return x.ChannelId
 	}
 	return ""
 }
 
-func (x *ProfileUpdate) GetAvatar() []byte {
+func (x *ChannelControl) GetAfterSeq() uint64 {
 	if x != nil {
-		return x.Avatar
+		return x.AfterSeq
 	}
-	return nil
+	return 0
 }
 
-// Handshake — начало сессии между двумя пирами
-type Handshake struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	// Публичный ключ инициатора
-	InitiatorPubKey []byte `protobuf:"bytes,1,opt,name=initiator_pub_key,json=initiatorPubKey,proto3" json:"initiator_pub_key,omitempty"`
-	// Временный ключ для ECDH (X25519)
-	EphemeralPubKey []byte `protobuf:"bytes,2,opt,name=ephemeral_pub_key,json=ephemeralPubKey,proto3" json:"ephemeral_pub_key,omitempty"`
-	// Nonce для предотвращения replay атак
-	Nonce []byte `protobuf:"bytes,3,opt,name=nonce,proto3" json:"nonce,omitempty"`
-	// Timestamp для проверки свежести
-	Timestamp int64 `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	// Никнейм отправителя (для отображения в UI)
-	Nickname string `protobuf:"bytes,5,opt,name=nickname,proto3" json:"nickname,omitempty"`
-	// I2P адрес отправителя (для обратной связи)
-	I2PAddress string `protobuf:"bytes,6,opt,name=i2p_address,json=i2pAddress,proto3" json:"i2p_address,omitempty"`
-	// Аватар отправителя (сжатое изображение)
-	Avatar        []byte `protobuf:"bytes,7,opt,name=avatar,proto3" json:"avatar,omitempty"`
+func (x *ChannelControl) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ChannelControl) GetLastSeq() uint64 {
+	if x != nil {
+		return x.LastSeq
+	}
+	return 0
+}
+
+// MailboxRequest — запрос к почтовому серверу (payload пакета MAILBOX)
+type MailboxRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Op    MailboxOp              `protobuf:"varint,1,opt,name=op,proto3,enum=teleghost.MailboxOp" json:"op,omitempty"`
+	// Владелец ящика; для DEPOSIT — получатель письма
+	OwnerPubKey string `protobuf:"bytes,2,opt,name=owner_pub_key,json=ownerPubKey,proto3" json:"owner_pub_key,omitempty"`
+	// DEPOSIT: готовый пакет, зашифрованный E2EE и подписанный для получателя
+	Envelope []byte `protobuf:"bytes,3,opt,name=envelope,proto3" json:"envelope,omitempty"`
+	// ACK: ID принятых писем
+	Ids []string `protobuf:"bytes,4,rep,name=ids,proto3" json:"ids,omitempty"`
+	// FETCH: сколько писем вернуть (0 — сколько разрешит сервер)
+	Limit uint32 `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`
+	// Подпись владельца над challenge и запросом (REGISTER, FETCH, ACK)
+	Signature []byte `protobuf:"bytes,6,opt,name=signature,proto3" json:"signature,omitempty"`
+	// PEER_HELLO / PEER_AUTH: рукопожатие между серверами
+	Hello *PeerHello `protobuf:"bytes,7,opt,name=hello,proto3" json:"hello,omitempty"`
+	// RELAY: письма для пользователей других серверов
+	Relays []*RelayEnvelope `protobuf:"bytes,8,rep,name=relays,proto3" json:"relays,omitempty"`
+	// REGISTER: контакты владельца — их письма сервер принимает без штампа
+	Contacts      []string `protobuf:"bytes,9,rep,name=contacts,proto3" json:"contacts,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Handshake) Reset() {
-	*x = Handshake{}
-	mi := &file_proto_teleghost_proto_msgTypes[4]
+func (x *MailboxRequest) Reset() {
+	*x = MailboxRequest{}
+	mi := &file_proto_teleghost_proto_msgTypes[24]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Handshake) String() string {
+func (x *MailboxRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Handshake) ProtoMessage() {}
+func (*MailboxRequest) ProtoMessage() {}
 
-func (x *Handshake) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_teleghost_proto_msgTypes[4]
+func (x *MailboxRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[24]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -476,90 +2510,99 @@ func (x *Handshake) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Handshake.ProtoReflect.Descriptor instead.
-func (*Handshake) Descriptor() ([]byte, []int) {
-	return file_proto_teleghost_proto_rawDescGZIP(), []int{4}
+// Deprecated: Use MailboxRequest.ProtoReflect.Descriptor instead.
+func (*MailboxRequest) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{24}
 }
 
-func (x *Handshake) GetInitiatorPubKey() []byte {
+func (x *MailboxRequest) GetOp() MailboxOp {
 	if x != nil {
-		return x.InitiatorPubKey
+		return x.Op
 	}
-	return nil
+	return MailboxOp_MAILBOX_OP_UNSPECIFIED
 }
 
-func (x *Handshake) GetEphemeralPubKey() []byte {
+func (x *MailboxRequest) GetOwnerPubKey() string {
 	if x != nil {
-		return x.EphemeralPubKey
+		return x.OwnerPubKey
+	}
+	return ""
+}
+
+func (x *MailboxRequest) GetEnvelope() []byte {
+	if x != nil {
+		return x.Envelope
 	}
 	return nil
 }
 
-func (x *Handshake) GetNonce() []byte {
+func (x *MailboxRequest) GetIds() []string {
 	if x != nil {
-		return x.Nonce
+		return x.Ids
 	}
 	return nil
 }
 
-func (x *Handshake) GetTimestamp() int64 {
+func (x *MailboxRequest) GetLimit() uint32 {
 	if x != nil {
-		return x.Timestamp
+		return x.Limit
 	}
 	return 0
 }
 
-func (x *Handshake) GetNickname() string {
+func (x *MailboxRequest) GetSignature() []byte {
 	if x != nil {
-		return x.Nickname
+		return x.Signature
 	}
-	return ""
+	return nil
 }
 
-func (x *Handshake) GetI2PAddress() string {
+func (x *MailboxRequest) GetHello() *PeerHello {
 	if x != nil {
-		return x.I2PAddress
+		return x.Hello
 	}
-	return ""
+	return nil
 }
 
-func (x *Handshake) GetAvatar() []byte {
+func (x *MailboxRequest) GetRelays() []*RelayEnvelope {
 	if x != nil {
-		return x.Avatar
+		return x.Relays
 	}
 	return nil
 }
 
-// MessageEdit — редактирование существующего сообщения
-type MessageEdit struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	// ID редактируемого сообщения
-	MessageId string `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
-	// Новое содержимое сообщения
-	NewContent string `protobuf:"bytes,2,opt,name=new_content,json=newContent,proto3" json:"new_content,omitempty"`
-	// Timestamp редактирования
-	Timestamp int64 `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	// ID чата (для маршрутизации)
-	ChatId        string `protobuf:"bytes,4,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+func (x *MailboxRequest) GetContacts() []string {
+	if x != nil {
+		return x.Contacts
+	}
+	return nil
+}
+
+// PeerHello — взаимная аутентификация почтовых серверов ключами Ed25519
+type PeerHello struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ServerPubKey  string                 `protobuf:"bytes,1,opt,name=server_pub_key,json=serverPubKey,proto3" json:"server_pub_key,omitempty"` // Ключ сервера (base64)
+	Challenge     []byte                 `protobuf:"bytes,2,opt,name=challenge,proto3" json:"challenge,omitempty"`                             // Challenge для подписи собеседником
+	Signature     []byte                 `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`                             // Подпись challenge собеседника
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *MessageEdit) Reset() {
-	*x = MessageEdit{}
-	mi := &file_proto_teleghost_proto_msgTypes[5]
+func (x *PeerHello) Reset() {
+	*x = PeerHello{}
+	mi := &file_proto_teleghost_proto_msgTypes[25]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *MessageEdit) String() string {
+func (x *PeerHello) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*MessageEdit) ProtoMessage() {}
+func (*PeerHello) ProtoMessage() {}
 
-func (x *MessageEdit) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_teleghost_proto_msgTypes[5]
+func (x *PeerHello) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[25]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -570,69 +2613,61 @@ func (x *MessageEdit) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use MessageEdit.ProtoReflect.Descriptor instead.
-func (*MessageEdit) Descriptor() ([]byte, []int) {
-	return file_proto_teleghost_proto_rawDescGZIP(), []int{5}
-}
-
-func (x *MessageEdit) GetMessageId() string {
-	if x != nil {
-		return x.MessageId
-	}
-	return ""
+// Deprecated: Use PeerHello.ProtoReflect.Descriptor instead.
+func (*PeerHello) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{25}
 }
 
-func (x *MessageEdit) GetNewContent() string {
+func (x *PeerHello) GetServerPubKey() string {
 	if x != nil {
-		return x.NewContent
+		return x.ServerPubKey
 	}
 	return ""
 }
 
-func (x *MessageEdit) GetTimestamp() int64 {
+func (x *PeerHello) GetChallenge() []byte {
 	if x != nil {
-		return x.Timestamp
+		return x.Challenge
 	}
-	return 0
+	return nil
 }
 
-func (x *MessageEdit) GetChatId() string {
+func (x *PeerHello) GetSignature() []byte {
 	if x != nil {
-		return x.ChatId
+		return x.Signature
 	}
-	return ""
+	return nil
 }
 
-// MessageDelete — удаление сообщения
-type MessageDelete struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	// ID удаляемого сообщения
-	MessageId string `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
-	// Timestamp удаления
-	Timestamp int64 `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	// ID чата (для маршрутизации)
-	ChatId string `protobuf:"bytes,3,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
-	// Удалить у всех (true) или только локально (false)
-	DeleteForAll  bool `protobuf:"varint,4,opt,name=delete_for_all,json=deleteForAll,proto3" json:"delete_for_all,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+// RelayEnvelope — письмо, которое серверы передают друг другу.
+// Подписано сервером, принявшим его от отправителя; hops уменьшается на каждом узле.
+type RelayEnvelope struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	RecipientPubKey string                 `protobuf:"bytes,1,opt,name=recipient_pub_key,json=recipientPubKey,proto3" json:"recipient_pub_key,omitempty"`
+	Envelope        []byte                 `protobuf:"bytes,2,opt,name=envelope,proto3" json:"envelope,omitempty"`
+	Hops            uint32                 `protobuf:"varint,3,opt,name=hops,proto3" json:"hops,omitempty"`
+	OriginPubKey    string                 `protobuf:"bytes,4,opt,name=origin_pub_key,json=originPubKey,proto3" json:"origin_pub_key,omitempty"`
+	CreatedAt       int64                  `protobuf:"varint,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Signature       []byte                 `protobuf:"bytes,6,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
-func (x *MessageDelete) Reset() {
-	*x = MessageDelete{}
-	mi := &file_proto_teleghost_proto_msgTypes[6]
+func (x *RelayEnvelope) Reset() {
+	*x = RelayEnvelope{}
+	mi := &file_proto_teleghost_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *MessageDelete) String() string {
+func (x *RelayEnvelope) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*MessageDelete) ProtoMessage() {}
+func (*RelayEnvelope) ProtoMessage() {}
 
-func (x *MessageDelete) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_teleghost_proto_msgTypes[6]
+func (x *RelayEnvelope) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -643,67 +2678,78 @@ func (x *MessageDelete) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use MessageDelete.ProtoReflect.Descriptor instead.
-func (*MessageDelete) Descriptor() ([]byte, []int) {
-	return file_proto_teleghost_proto_rawDescGZIP(), []int{6}
+// Deprecated: Use RelayEnvelope.ProtoReflect.Descriptor instead.
+func (*RelayEnvelope) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{26}
 }
 
-func (x *MessageDelete) GetMessageId() string {
+func (x *RelayEnvelope) GetRecipientPubKey() string {
 	if x != nil {
-		return x.MessageId
+		return x.RecipientPubKey
 	}
 	return ""
 }
 
-func (x *MessageDelete) GetTimestamp() int64 {
+func (x *RelayEnvelope) GetEnvelope() []byte {
 	if x != nil {
-		return x.Timestamp
+		return x.Envelope
+	}
+	return nil
+}
+
+func (x *RelayEnvelope) GetHops() uint32 {
+	if x != nil {
+		return x.Hops
 	}
 	return 0
 }
 
-func (x *MessageDelete) GetChatId() string {
+func (x *RelayEnvelope) GetOriginPubKey() string {
 	if x != nil {
-		return x.ChatId
+		return x.OriginPubKey
 	}
 	return ""
 }
 
-func (x *MessageDelete) GetDeleteForAll() bool {
+func (x *RelayEnvelope) GetCreatedAt() int64 {
 	if x != nil {
-		return x.DeleteForAll
+		return x.CreatedAt
 	}
-	return false
+	return 0
 }
 
-// FileOffer — предложение отправки файлов (для подтверждения)
-type FileOffer struct {
-	state     protoimpl.MessageState `protogen:"open.v1"`
-	MessageId string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
-	Filenames []string               `protobuf:"bytes,2,rep,name=filenames,proto3" json:"filenames,omitempty"`
-	TotalSize int64                  `protobuf:"varint,3,opt,name=total_size,json=totalSize,proto3" json:"total_size,omitempty"`
-	FileCount int32                  `protobuf:"varint,4,opt,name=file_count,json=fileCount,proto3" json:"file_count,omitempty"`
-	// Можно добавить previews, но пока опустим для простоты
-	ChatId        string `protobuf:"bytes,5,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+func (x *RelayEnvelope) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// MailboxItem — письмо в ящике
+type MailboxItem struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Envelope      []byte                 `protobuf:"bytes,2,opt,name=envelope,proto3" json:"envelope,omitempty"`
+	ReceivedAt    int64                  `protobuf:"varint,3,opt,name=received_at,json=receivedAt,proto3" json:"received_at,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *FileOffer) Reset() {
-	*x = FileOffer{}
-	mi := &file_proto_teleghost_proto_msgTypes[7]
+func (x *MailboxItem) Reset() {
+	*x = MailboxItem{}
+	mi := &file_proto_teleghost_proto_msgTypes[27]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *FileOffer) String() string {
+func (x *MailboxItem) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*FileOffer) ProtoMessage() {}
+func (*MailboxItem) ProtoMessage() {}
 
-func (x *FileOffer) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_teleghost_proto_msgTypes[7]
+func (x *MailboxItem) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[27]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -714,71 +2760,69 @@ func (x *FileOffer) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use FileOffer.ProtoReflect.Descriptor instead.
-func (*FileOffer) Descriptor() ([]byte, []int) {
-	return file_proto_teleghost_proto_rawDescGZIP(), []int{7}
+// Deprecated: Use MailboxItem.ProtoReflect.Descriptor instead.
+func (*MailboxItem) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{27}
 }
 
-func (x *FileOffer) GetMessageId() string {
+func (x *MailboxItem) GetId() string {
 	if x != nil {
-		return x.MessageId
+		return x.Id
 	}
 	return ""
 }
 
-func (x *FileOffer) GetFilenames() []string {
+func (x *MailboxItem) GetEnvelope() []byte {
 	if x != nil {
-		return x.Filenames
+		return x.Envelope
 	}
 	return nil
 }
 
-func (x *FileOffer) GetTotalSize() int64 {
-	if x != nil {
-		return x.TotalSize
-	}
-	return 0
-}
-
-func (x *FileOffer) GetFileCount() int32 {
+func (x *MailboxItem) GetReceivedAt() int64 {
 	if x != nil {
-		return x.FileCount
+		return x.ReceivedAt
 	}
 	return 0
 }
 
-func (x *FileOffer) GetChatId() string {
-	if x != nil {
-		return x.ChatId
-	}
-	return ""
-}
-
-// FileResponse — ответ на предложение
-type FileResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
-	Accepted      bool                   `protobuf:"varint,2,opt,name=accepted,proto3" json:"accepted,omitempty"`
-	ChatId        string                 `protobuf:"bytes,3,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+// MailboxResponse — ответ почтового сервера
+type MailboxResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Status MailboxStatus          `protobuf:"varint,1,opt,name=status,proto3,enum=teleghost.MailboxStatus" json:"status,omitempty"`
+	Error  string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	// CHALLENGE
+	Challenge []byte `protobuf:"bytes,3,opt,name=challenge,proto3" json:"challenge,omitempty"`
+	// FETCH: письма и признак, что в ящике есть ещё
+	Items []*MailboxItem `protobuf:"bytes,4,rep,name=items,proto3" json:"items,omitempty"`
+	More  bool           `protobuf:"varint,5,opt,name=more,proto3" json:"more,omitempty"`
+	// REGISTER: квоты ящика
+	MaxMessages uint32 `protobuf:"varint,6,opt,name=max_messages,json=maxMessages,proto3" json:"max_messages,omitempty"`
+	MaxBytes    uint64 `protobuf:"varint,7,opt,name=max_bytes,json=maxBytes,proto3" json:"max_bytes,omitempty"`
+	TtlSeconds  int64  `protobuf:"varint,8,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"`
+	// PEER_HELLO: ответное приветствие сервера
+	Hello *PeerHello `protobuf:"bytes,9,opt,name=hello,proto3" json:"hello,omitempty"`
+	// RELAY: сколько писем принято (включая уже виденные)
+	Accepted      uint32 `protobuf:"varint,10,opt,name=accepted,proto3" json:"accepted,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *FileResponse) Reset() {
-	*x = FileResponse{}
-	mi := &file_proto_teleghost_proto_msgTypes[8]
+func (x *MailboxResponse) Reset() {
+	*x = MailboxResponse{}
+	mi := &file_proto_teleghost_proto_msgTypes[28]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *FileResponse) String() string {
+func (x *MailboxResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*FileResponse) ProtoMessage() {}
+func (*MailboxResponse) ProtoMessage() {}
 
-func (x *FileResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_teleghost_proto_msgTypes[8]
+func (x *MailboxResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_teleghost_proto_msgTypes[28]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -789,43 +2833,103 @@ func (x *FileResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use FileResponse.ProtoReflect.Descriptor instead.
-func (*FileResponse) Descriptor() ([]byte, []int) {
-	return file_proto_teleghost_proto_rawDescGZIP(), []int{8}
+// Deprecated: Use MailboxResponse.ProtoReflect.Descriptor instead.
+func (*MailboxResponse) Descriptor() ([]byte, []int) {
+	return file_proto_teleghost_proto_rawDescGZIP(), []int{28}
 }
 
-func (x *FileResponse) GetMessageId() string {
+func (x *MailboxResponse) GetStatus() MailboxStatus {
 	if x != nil {
-		return x.MessageId
+		return x.Status
+	}
+	return MailboxStatus_MAILBOX_OK
+}
+
+func (x *MailboxResponse) GetError() string {
+	if x != nil {
+		return x.Error
 	}
 	return ""
 }
 
-func (x *FileResponse) GetAccepted() bool {
+func (x *MailboxResponse) GetChallenge() []byte {
 	if x != nil {
-		return x.Accepted
+		return x.Challenge
+	}
+	return nil
+}
+
+func (x *MailboxResponse) GetItems() []*MailboxItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *MailboxResponse) GetMore() bool {
+	if x != nil {
+		return x.More
 	}
 	return false
 }
 
-func (x *FileResponse) GetChatId() string {
+func (x *MailboxResponse) GetMaxMessages() uint32 {
 	if x != nil {
-		return x.ChatId
+		return x.MaxMessages
 	}
-	return ""
+	return 0
+}
+
+func (x *MailboxResponse) GetMaxBytes() uint64 {
+	if x != nil {
+		return x.MaxBytes
+	}
+	return 0
+}
+
+func (x *MailboxResponse) GetTtlSeconds() int64 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+func (x *MailboxResponse) GetHello() *PeerHello {
+	if x != nil {
+		return x.Hello
+	}
+	return nil
+}
+
+func (x *MailboxResponse) GetAccepted() uint32 {
+	if x != nil {
+		return x.Accepted
+	}
+	return 0
 }
 
 var File_proto_teleghost_proto protoreflect.FileDescriptor
 
 const file_proto_teleghost_proto_rawDesc = "" +
 	"\n" +
-	"\x15proto/teleghost.proto\x12\tteleghost\"\xab\x01\n" +
+	"\x15proto/teleghost.proto\x12\tteleghost\"\xfd\x02\n" +
 	"\x06Packet\x12\x18\n" +
 	"\aversion\x18\x01 \x01(\rR\aversion\x12)\n" +
 	"\x04type\x18\x02 \x01(\x0e2\x15.teleghost.PacketTypeR\x04type\x12$\n" +
 	"\x0esender_pub_key\x18\x03 \x01(\fR\fsenderPubKey\x12\x1c\n" +
 	"\tsignature\x18\x04 \x01(\fR\tsignature\x12\x18\n" +
-	"\apayload\x18\x05 \x01(\fR\apayload\"\xd0\x01\n" +
+	"\apayload\x18\x05 \x01(\fR\apayload\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x06 \x01(\fR\tsessionId\x12\x1c\n" +
+	"\ttimestamp\x18\a \x01(\x03R\ttimestamp\x12\x14\n" +
+	"\x05nonce\x18\b \x01(\fR\x05nonce\x12*\n" +
+	"\x11recipient_pub_key\x18\t \x01(\fR\x0frecipientPubKey\x12&\n" +
+	"\x05stamp\x18\n" +
+	" \x01(\v2\x10.teleghost.StampR\x05stamp\x12)\n" +
+	"\x10sealed_ephemeral\x18\v \x01(\fR\x0fsealedEphemeral\"Z\n" +
+	"\x05Stamp\x127\n" +
+	"\talgorithm\x18\x01 \x01(\x0e2\x19.teleghost.StampAlgorithmR\talgorithm\x12\x18\n" +
+	"\acounter\x18\x02 \x01(\x04R\acounter\"\xd0\x01\n" +
 	"\n" +
 	"Attachment\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
@@ -835,7 +2939,7 @@ const file_proto_teleghost_proto_rawDesc = "" +
 	"\x04data\x18\x05 \x01(\fR\x04data\x12#\n" +
 	"\ris_compressed\x18\x06 \x01(\bR\fisCompressed\x12\x14\n" +
 	"\x05width\x18\a \x01(\x05R\x05width\x12\x16\n" +
-	"\x06height\x18\b \x01(\x05R\x06height\"\xd6\x01\n" +
+	"\x06height\x18\b \x01(\x05R\x06height\"\x92\x02\n" +
 	"\vTextMessage\x12\x17\n" +
 	"\achat_id\x18\x01 \x01(\tR\x06chatId\x12\x18\n" +
 	"\acontent\x18\x02 \x01(\tR\acontent\x12\x1c\n" +
@@ -843,11 +2947,16 @@ const file_proto_teleghost_proto_rawDesc = "" +
 	"\n" +
 	"message_id\x18\x04 \x01(\tR\tmessageId\x127\n" +
 	"\vattachments\x18\x05 \x03(\v2\x15.teleghost.AttachmentR\vattachments\x12\x1e\n" +
-	"\vreply_to_id\x18\x06 \x01(\tR\treplyToId\"U\n" +
+	"\vreply_to_id\x18\x06 \x01(\tR\treplyToId\x12\x19\n" +
+	"\bgroup_id\x18\a \x01(\tR\agroupId\x12\x1f\n" +
+	"\vgroup_epoch\x18\b \x01(\x04R\n" +
+	"groupEpoch\"\xa7\x01\n" +
 	"\rProfileUpdate\x12\x1a\n" +
 	"\bnickname\x18\x01 \x01(\tR\bnickname\x12\x10\n" +
 	"\x03bio\x18\x02 \x01(\tR\x03bio\x12\x16\n" +
-	"\x06avatar\x18\x03 \x01(\fR\x06avatar\"\xec\x01\n" +
+	"\x06avatar\x18\x03 \x01(\fR\x06avatar\x12'\n" +
+	"\x0fmailbox_address\x18\x04 \x01(\tR\x0emailboxAddress\x12'\n" +
+	"\x0fblinded_address\x18\x05 \x01(\tR\x0eblindedAddress\"\xf7\x03\n" +
 	"\tHandshake\x12*\n" +
 	"\x11initiator_pub_key\x18\x01 \x01(\fR\x0finitiatorPubKey\x12*\n" +
 	"\x11ephemeral_pub_key\x18\x02 \x01(\fR\x0fephemeralPubKey\x12\x14\n" +
@@ -856,7 +2965,18 @@ const file_proto_teleghost_proto_rawDesc = "" +
 	"\bnickname\x18\x05 \x01(\tR\bnickname\x12\x1f\n" +
 	"\vi2p_address\x18\x06 \x01(\tR\n" +
 	"i2pAddress\x12\x16\n" +
-	"\x06avatar\x18\a \x01(\fR\x06avatar\"\x84\x01\n" +
+	"\x06avatar\x18\a \x01(\fR\x06avatar\x12\x1f\n" +
+	"\vis_response\x18\b \x01(\bR\n" +
+	"isResponse\x12,\n" +
+	"\x12reply_to_ephemeral\x18\t \x01(\fR\x10replyToEphemeral\x12\x1f\n" +
+	"\vmin_version\x18\n" +
+	" \x01(\rR\n" +
+	"minVersion\x12\x1f\n" +
+	"\vmax_version\x18\v \x01(\rR\n" +
+	"maxVersion\x12\"\n" +
+	"\fcapabilities\x18\f \x01(\x04R\fcapabilities\x12+\n" +
+	"\x12lease_set_auth_key\x18\r \x01(\fR\x0fleaseSetAuthKey\x12'\n" +
+	"\x0fblinded_address\x18\x0e \x01(\tR\x0eblindedAddress\"\x84\x01\n" +
 	"\vMessageEdit\x12\x1d\n" +
 	"\n" +
 	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x1f\n" +
@@ -869,7 +2989,7 @@ const file_proto_teleghost_proto_rawDesc = "" +
 	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x1c\n" +
 	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\x12\x17\n" +
 	"\achat_id\x18\x03 \x01(\tR\x06chatId\x12$\n" +
-	"\x0edelete_for_all\x18\x04 \x01(\bR\fdeleteForAll\"\x9f\x01\n" +
+	"\x0edelete_for_all\x18\x04 \x01(\bR\fdeleteForAll\"\xce\x01\n" +
 	"\tFileOffer\x12\x1d\n" +
 	"\n" +
 	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x1c\n" +
@@ -878,12 +2998,143 @@ const file_proto_teleghost_proto_rawDesc = "" +
 	"total_size\x18\x03 \x01(\x03R\ttotalSize\x12\x1d\n" +
 	"\n" +
 	"file_count\x18\x04 \x01(\x05R\tfileCount\x12\x17\n" +
-	"\achat_id\x18\x05 \x01(\tR\x06chatId\"b\n" +
+	"\achat_id\x18\x05 \x01(\tR\x06chatId\x12-\n" +
+	"\x05files\x18\x06 \x03(\v2\x17.teleghost.FileManifestR\x05files\"\xab\x01\n" +
+	"\fFileManifest\x12\x17\n" +
+	"\afile_id\x18\x01 \x01(\tR\x06fileId\x12\x1a\n" +
+	"\bfilename\x18\x02 \x01(\tR\bfilename\x12\x1b\n" +
+	"\tmime_type\x18\x03 \x01(\tR\bmimeType\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06sha256\x18\x05 \x01(\fR\x06sha256\x12\x1d\n" +
+	"\n" +
+	"chunk_size\x18\x06 \x01(\rR\tchunkSize\"b\n" +
 	"\fFileResponse\x12\x1d\n" +
 	"\n" +
 	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x1a\n" +
 	"\baccepted\x18\x02 \x01(\bR\baccepted\x12\x17\n" +
-	"\achat_id\x18\x03 \x01(\tR\x06chatId*\xcb\x01\n" +
+	"\achat_id\x18\x03 \x01(\tR\x06chatId\"\x86\x01\n" +
+	"\tFileChunk\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\x12\x14\n" +
+	"\x05index\x18\x03 \x01(\rR\x05index\x12\x12\n" +
+	"\x04data\x18\x04 \x01(\fR\x04data\x12\x17\n" +
+	"\achat_id\x18\x05 \x01(\tR\x06chatId\"e\n" +
+	"\fFileChunkAck\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\x12\x1d\n" +
+	"\n" +
+	"next_index\x18\x03 \x01(\rR\tnextIndex\"F\n" +
+	"\fFileProgress\x12\x17\n" +
+	"\afile_id\x18\x01 \x01(\tR\x06fileId\x12\x1d\n" +
+	"\n" +
+	"next_index\x18\x02 \x01(\rR\tnextIndex\"s\n" +
+	"\n" +
+	"FileResume\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\x12-\n" +
+	"\x05files\x18\x02 \x03(\v2\x17.teleghost.FileProgressR\x05files\x12\x17\n" +
+	"\achat_id\x18\x03 \x01(\tR\x06chatId\"\xb1\x01\n" +
+	"\n" +
+	"FileStream\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\x12\x17\n" +
+	"\achat_id\x18\x03 \x01(\tR\x06chatId\x12\x1f\n" +
+	"\vstart_index\x18\x04 \x01(\rR\n" +
+	"startIndex\x12\x1f\n" +
+	"\vchunk_count\x18\x05 \x01(\rR\n" +
+	"chunkCount\x12\x10\n" +
+	"\x03key\x18\x06 \x01(\fR\x03key\"\x8d\x01\n" +
+	"\aReceipt\x12\x1f\n" +
+	"\vmessage_ids\x18\x01 \x03(\tR\n" +
+	"messageIds\x12*\n" +
+	"\x04kind\x18\x02 \x01(\x0e2\x16.teleghost.ReceiptKindR\x04kind\x12\x17\n" +
+	"\achat_id\x18\x03 \x01(\tR\x06chatId\x12\x1c\n" +
+	"\ttimestamp\x18\x04 \x01(\x03R\ttimestamp\"9\n" +
+	"\x06Typing\x12\x17\n" +
+	"\achat_id\x18\x01 \x01(\tR\x06chatId\x12\x16\n" +
+	"\x06typing\x18\x02 \x01(\bR\x06typing\":\n" +
+	"\bPresence\x12\x16\n" +
+	"\x06online\x18\x01 \x01(\bR\x06online\x12\x16\n" +
+	"\x06hidden\x18\x02 \x01(\bR\x06hidden\"\x8d\x01\n" +
+	"\vGroupMember\x12\x17\n" +
+	"\apub_key\x18\x01 \x01(\tR\x06pubKey\x12\x1f\n" +
+	"\vi2p_address\x18\x02 \x01(\tR\n" +
+	"i2pAddress\x12\x1a\n" +
+	"\bnickname\x18\x03 \x01(\tR\bnickname\x12(\n" +
+	"\x04role\x18\x04 \x01(\x0e2\x14.teleghost.GroupRoleR\x04role\"\xe6\x01\n" +
+	"\n" +
+	"GroupState\x12\x19\n" +
+	"\bgroup_id\x18\x01 \x01(\tR\agroupId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05epoch\x18\x03 \x01(\x04R\x05epoch\x12\x1d\n" +
+	"\n" +
+	"updated_at\x18\x04 \x01(\x03R\tupdatedAt\x120\n" +
+	"\amembers\x18\x05 \x03(\v2\x16.teleghost.GroupMemberR\amembers\x12$\n" +
+	"\x0esigner_pub_key\x18\x06 \x01(\tR\fsignerPubKey\x12\x1c\n" +
+	"\tsignature\x18\a \x01(\fR\tsignature\"\x86\x01\n" +
+	"\fGroupControl\x12.\n" +
+	"\x06action\x18\x01 \x01(\x0e2\x16.teleghost.GroupActionR\x06action\x12\x19\n" +
+	"\bgroup_id\x18\x02 \x01(\tR\agroupId\x12+\n" +
+	"\x05state\x18\x03 \x01(\v2\x15.teleghost.GroupStateR\x05state\"\xd1\x01\n" +
+	"\vChannelPost\x12\x1d\n" +
+	"\n" +
+	"channel_id\x18\x01 \x01(\tR\tchannelId\x12\x10\n" +
+	"\x03seq\x18\x02 \x01(\x04R\x03seq\x12\x17\n" +
+	"\apost_id\x18\x03 \x01(\tR\x06postId\x12\x18\n" +
+	"\acontent\x18\x04 \x01(\tR\acontent\x12\x1c\n" +
+	"\ttimestamp\x18\x05 \x01(\x03R\ttimestamp\x12\"\n" +
+	"\rowner_pub_key\x18\x06 \x01(\tR\vownerPubKey\x12\x1c\n" +
+	"\tsignature\x18\a \x01(\fR\tsignature\"\xad\x01\n" +
+	"\x0eChannelControl\x120\n" +
+	"\x06action\x18\x01 \x01(\x0e2\x18.teleghost.ChannelActionR\x06action\x12\x1d\n" +
+	"\n" +
+	"channel_id\x18\x02 \x01(\tR\tchannelId\x12\x1b\n" +
+	"\tafter_seq\x18\x03 \x01(\x04R\bafterSeq\x12\x12\n" +
+	"\x04name\x18\x04 \x01(\tR\x04name\x12\x19\n" +
+	"\blast_seq\x18\x05 \x01(\x04R\alastSeq\"\xb6\x02\n" +
+	"\x0eMailboxRequest\x12$\n" +
+	"\x02op\x18\x01 \x01(\x0e2\x14.teleghost.MailboxOpR\x02op\x12\"\n" +
+	"\rowner_pub_key\x18\x02 \x01(\tR\vownerPubKey\x12\x1a\n" +
+	"\benvelope\x18\x03 \x01(\fR\benvelope\x12\x10\n" +
+	"\x03ids\x18\x04 \x03(\tR\x03ids\x12\x14\n" +
+	"\x05limit\x18\x05 \x01(\rR\x05limit\x12\x1c\n" +
+	"\tsignature\x18\x06 \x01(\fR\tsignature\x12*\n" +
+	"\x05hello\x18\a \x01(\v2\x14.teleghost.PeerHelloR\x05hello\x120\n" +
+	"\x06relays\x18\b \x03(\v2\x18.teleghost.RelayEnvelopeR\x06relays\x12\x1a\n" +
+	"\bcontacts\x18\t \x03(\tR\bcontacts\"m\n" +
+	"\tPeerHello\x12$\n" +
+	"\x0eserver_pub_key\x18\x01 \x01(\tR\fserverPubKey\x12\x1c\n" +
+	"\tchallenge\x18\x02 \x01(\fR\tchallenge\x12\x1c\n" +
+	"\tsignature\x18\x03 \x01(\fR\tsignature\"\xce\x01\n" +
+	"\rRelayEnvelope\x12*\n" +
+	"\x11recipient_pub_key\x18\x01 \x01(\tR\x0frecipientPubKey\x12\x1a\n" +
+	"\benvelope\x18\x02 \x01(\fR\benvelope\x12\x12\n" +
+	"\x04hops\x18\x03 \x01(\rR\x04hops\x12$\n" +
+	"\x0eorigin_pub_key\x18\x04 \x01(\tR\foriginPubKey\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x05 \x01(\x03R\tcreatedAt\x12\x1c\n" +
+	"\tsignature\x18\x06 \x01(\fR\tsignature\"Z\n" +
+	"\vMailboxItem\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
+	"\benvelope\x18\x02 \x01(\fR\benvelope\x12\x1f\n" +
+	"\vreceived_at\x18\x03 \x01(\x03R\n" +
+	"receivedAt\"\xe2\x02\n" +
+	"\x0fMailboxResponse\x120\n" +
+	"\x06status\x18\x01 \x01(\x0e2\x18.teleghost.MailboxStatusR\x06status\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x12\x1c\n" +
+	"\tchallenge\x18\x03 \x01(\fR\tchallenge\x12,\n" +
+	"\x05items\x18\x04 \x03(\v2\x16.teleghost.MailboxItemR\x05items\x12\x12\n" +
+	"\x04more\x18\x05 \x01(\bR\x04more\x12!\n" +
+	"\fmax_messages\x18\x06 \x01(\rR\vmaxMessages\x12\x1b\n" +
+	"\tmax_bytes\x18\a \x01(\x04R\bmaxBytes\x12\x1f\n" +
+	"\vttl_seconds\x18\b \x01(\x03R\n" +
+	"ttlSeconds\x12*\n" +
+	"\x05hello\x18\t \x01(\v2\x14.teleghost.PeerHelloR\x05hello\x12\x1a\n" +
+	"\baccepted\x18\n" +
+	" \x01(\rR\baccepted*\xff\x02\n" +
 	"\n" +
 	"PacketType\x12\x1b\n" +
 	"\x17PACKET_TYPE_UNSPECIFIED\x10\x00\x12\r\n" +
@@ -896,7 +3147,63 @@ const file_proto_teleghost_proto_rawDesc = "" +
 	"\x0fPROFILE_REQUEST\x10\a\x12\x0e\n" +
 	"\n" +
 	"FILE_OFFER\x10\b\x12\x11\n" +
-	"\rFILE_RESPONSE\x10\tB+Z)github.com/teleghost/internal/proto;protob\x06proto3"
+	"\rFILE_RESPONSE\x10\t\x12\v\n" +
+	"\aRECEIPT\x10\n" +
+	"\x12\x0e\n" +
+	"\n" +
+	"FILE_CHUNK\x10\v\x12\x12\n" +
+	"\x0eFILE_CHUNK_ACK\x10\f\x12\x0f\n" +
+	"\vFILE_RESUME\x10\r\x12\n" +
+	"\n" +
+	"\x06TYPING\x10\x0e\x12\f\n" +
+	"\bPRESENCE\x10\x0f\x12\x11\n" +
+	"\rGROUP_CONTROL\x10\x10\x12\x10\n" +
+	"\fCHANNEL_POST\x10\x11\x12\x13\n" +
+	"\x0fCHANNEL_CONTROL\x10\x12\x12\v\n" +
+	"\aMAILBOX\x10\x13\x12\x0f\n" +
+	"\vFILE_STREAM\x10\x14*4\n" +
+	"\x0eStampAlgorithm\x12\x10\n" +
+	"\fSTAMP_SHA256\x10\x00\x12\x10\n" +
+	"\fSTAMP_ARGON2\x10\x01*T\n" +
+	"\vReceiptKind\x12\x1c\n" +
+	"\x18RECEIPT_KIND_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11RECEIPT_DELIVERED\x10\x01\x12\x10\n" +
+	"\fRECEIPT_READ\x10\x02*8\n" +
+	"\tGroupRole\x12\x15\n" +
+	"\x11GROUP_ROLE_MEMBER\x10\x00\x12\x14\n" +
+	"\x10GROUP_ROLE_ADMIN\x10\x01*\x80\x01\n" +
+	"\vGroupAction\x12\x1c\n" +
+	"\x18GROUP_ACTION_UNSPECIFIED\x10\x00\x12\x10\n" +
+	"\fGROUP_INVITE\x10\x01\x12\x0e\n" +
+	"\n" +
+	"GROUP_KICK\x10\x02\x12\x0f\n" +
+	"\vGROUP_LEAVE\x10\x03\x12\x10\n" +
+	"\fGROUP_UPDATE\x10\x04\x12\x0e\n" +
+	"\n" +
+	"GROUP_SYNC\x10\x05*\x83\x01\n" +
+	"\rChannelAction\x12\x1e\n" +
+	"\x1aCHANNEL_ACTION_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11CHANNEL_SUBSCRIBE\x10\x01\x12\x17\n" +
+	"\x13CHANNEL_UNSUBSCRIBE\x10\x02\x12\x10\n" +
+	"\fCHANNEL_SYNC\x10\x03\x12\x10\n" +
+	"\fCHANNEL_INFO\x10\x04*\xcf\x01\n" +
+	"\tMailboxOp\x12\x1a\n" +
+	"\x16MAILBOX_OP_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11MAILBOX_CHALLENGE\x10\x01\x12\x14\n" +
+	"\x10MAILBOX_REGISTER\x10\x02\x12\x13\n" +
+	"\x0fMAILBOX_DEPOSIT\x10\x03\x12\x11\n" +
+	"\rMAILBOX_FETCH\x10\x04\x12\x0f\n" +
+	"\vMAILBOX_ACK\x10\x05\x12\x16\n" +
+	"\x12MAILBOX_PEER_HELLO\x10\x06\x12\x15\n" +
+	"\x11MAILBOX_PEER_AUTH\x10\a\x12\x11\n" +
+	"\rMAILBOX_RELAY\x10\b*n\n" +
+	"\rMailboxStatus\x12\x0e\n" +
+	"\n" +
+	"MAILBOX_OK\x10\x00\x12\x11\n" +
+	"\rMAILBOX_ERROR\x10\x01\x12\x13\n" +
+	"\x0fMAILBOX_UNKNOWN\x10\x02\x12\x11\n" +
+	"\rMAILBOX_QUOTA\x10\x03\x12\x12\n" +
+	"\x0eMAILBOX_DENIED\x10\x04B+Z)github.com/teleghost/internal/proto;protob\x06proto3"
 
 var (
 	file_proto_teleghost_proto_rawDescOnce sync.Once
@@ -910,28 +3217,71 @@ func file_proto_teleghost_proto_rawDescGZIP() []byte {
 	return file_proto_teleghost_proto_rawDescData
 }
 
-var file_proto_teleghost_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_proto_teleghost_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_proto_teleghost_proto_enumTypes = make([]protoimpl.EnumInfo, 8)
+var file_proto_teleghost_proto_msgTypes = make([]protoimpl.MessageInfo, 29)
 var file_proto_teleghost_proto_goTypes = []any{
-	(PacketType)(0),       // 0: teleghost.PacketType
-	(*Packet)(nil),        // 1: teleghost.Packet
-	(*Attachment)(nil),    // 2: teleghost.Attachment
-	(*TextMessage)(nil),   // 3: teleghost.TextMessage
-	(*ProfileUpdate)(nil), // 4: teleghost.ProfileUpdate
-	(*Handshake)(nil),     // 5: teleghost.Handshake
-	(*MessageEdit)(nil),   // 6: teleghost.MessageEdit
-	(*MessageDelete)(nil), // 7: teleghost.MessageDelete
-	(*FileOffer)(nil),     // 8: teleghost.FileOffer
-	(*FileResponse)(nil),  // 9: teleghost.FileResponse
+	(PacketType)(0),         // 0: teleghost.PacketType
+	(StampAlgorithm)(0),     // 1: teleghost.StampAlgorithm
+	(ReceiptKind)(0),        // 2: teleghost.ReceiptKind
+	(GroupRole)(0),          // 3: teleghost.GroupRole
+	(GroupAction)(0),        // 4: teleghost.GroupAction
+	(ChannelAction)(0),      // 5: teleghost.ChannelAction
+	(MailboxOp)(0),          // 6: teleghost.MailboxOp
+	(MailboxStatus)(0),      // 7: teleghost.MailboxStatus
+	(*Packet)(nil),          // 8: teleghost.Packet
+	(*Stamp)(nil),           // 9: teleghost.Stamp
+	(*Attachment)(nil),      // 10: teleghost.Attachment
+	(*TextMessage)(nil),     // 11: teleghost.TextMessage
+	(*ProfileUpdate)(nil),   // 12: teleghost.ProfileUpdate
+	(*Handshake)(nil),       // 13: teleghost.Handshake
+	(*MessageEdit)(nil),     // 14: teleghost.MessageEdit
+	(*MessageDelete)(nil),   // 15: teleghost.MessageDelete
+	(*FileOffer)(nil),       // 16: teleghost.FileOffer
+	(*FileManifest)(nil),    // 17: teleghost.FileManifest
+	(*FileResponse)(nil),    // 18: teleghost.FileResponse
+	(*FileChunk)(nil),       // 19: teleghost.FileChunk
+	(*FileChunkAck)(nil),    // 20: teleghost.FileChunkAck
+	(*FileProgress)(nil),    // 21: teleghost.FileProgress
+	(*FileResume)(nil),      // 22: teleghost.FileResume
+	(*FileStream)(nil),      // 23: teleghost.FileStream
+	(*Receipt)(nil),         // 24: teleghost.Receipt
+	(*Typing)(nil),          // 25: teleghost.Typing
+	(*Presence)(nil),        // 26: teleghost.Presence
+	(*GroupMember)(nil),     // 27: teleghost.GroupMember
+	(*GroupState)(nil),      // 28: teleghost.GroupState
+	(*GroupControl)(nil),    // 29: teleghost.GroupControl
+	(*ChannelPost)(nil),     // 30: teleghost.ChannelPost
+	(*ChannelControl)(nil),  // 31: teleghost.ChannelControl
+	(*MailboxRequest)(nil),  // 32: teleghost.MailboxRequest
+	(*PeerHello)(nil),       // 33: teleghost.PeerHello
+	(*RelayEnvelope)(nil),   // 34: teleghost.RelayEnvelope
+	(*MailboxItem)(nil),     // 35: teleghost.MailboxItem
+	(*MailboxResponse)(nil), // 36: teleghost.MailboxResponse
 }
 var file_proto_teleghost_proto_depIdxs = []int32{
-	0, // 0: teleghost.Packet.type:type_name -> teleghost.PacketType
-	2, // 1: teleghost.TextMessage.attachments:type_name -> teleghost.Attachment
-	2, // [2:2] is the sub-list for method output_type
-	2, // [2:2] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	0,  // 0: teleghost.Packet.type:type_name -> teleghost.PacketType
+	9,  // 1: teleghost.Packet.stamp:type_name -> teleghost.Stamp
+	1,  // 2: teleghost.Stamp.algorithm:type_name -> teleghost.StampAlgorithm
+	10, // 3: teleghost.TextMessage.attachments:type_name -> teleghost.Attachment
+	17, // 4: teleghost.FileOffer.files:type_name -> teleghost.FileManifest
+	21, // 5: teleghost.FileResume.files:type_name -> teleghost.FileProgress
+	2,  // 6: teleghost.Receipt.kind:type_name -> teleghost.ReceiptKind
+	3,  // 7: teleghost.GroupMember.role:type_name -> teleghost.GroupRole
+	27, // 8: teleghost.GroupState.members:type_name -> teleghost.GroupMember
+	4,  // 9: teleghost.GroupControl.action:type_name -> teleghost.GroupAction
+	28, // 10: teleghost.GroupControl.state:type_name -> teleghost.GroupState
+	5,  // 11: teleghost.ChannelControl.action:type_name -> teleghost.ChannelAction
+	6,  // 12: teleghost.MailboxRequest.op:type_name -> teleghost.MailboxOp
+	33, // 13: teleghost.MailboxRequest.hello:type_name -> teleghost.PeerHello
+	34, // 14: teleghost.MailboxRequest.relays:type_name -> teleghost.RelayEnvelope
+	7,  // 15: teleghost.MailboxResponse.status:type_name -> teleghost.MailboxStatus
+	35, // 16: teleghost.MailboxResponse.items:type_name -> teleghost.MailboxItem
+	33, // 17: teleghost.MailboxResponse.hello:type_name -> teleghost.PeerHello
+	18, // [18:18] is the sub-list for method output_type
+	18, // [18:18] is the sub-list for method input_type
+	18, // [18:18] is the sub-list for extension type_name
+	18, // [18:18] is the sub-list for extension extendee
+	0,  // [0:18] is the sub-list for field type_name
 }
 
 func init() { file_proto_teleghost_proto_init() }
@@ -944,8 +3294,8 @@ func file_proto_teleghost_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_teleghost_proto_rawDesc), len(file_proto_teleghost_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   9,
+			NumEnums:      8,
+			NumMessages:   29,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -5,16 +5,24 @@ import (
 	"context"
 	"database/sql"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"teleghost/internal/core"
 	"teleghost/internal/core/identity"
+	"teleghost/internal/core/ratchet"
 
+	"github.com/go-i2p/i2pkeys"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// ErrMessageExists — сообщение с таким ID уже сохранено
+var ErrMessageExists = errors.New("message already exists")
+
 // Repository — SQLite реализация репозитория
 type Repository struct {
 	db   *sql.DB
@@ -54,6 +62,14 @@ func (r *Repository) Migrate(ctx context.Context) error {
 
 	r.migrateContactsTable(ctx)
 	r.migrateMessagesTable(ctx)
+	r.addMissingColumns(ctx, "chat_groups", []columnDef{
+		{"is_pending", "INTEGER DEFAULT 0"},
+	})
+
+	// Миграция: статические ключи сессий заменены состоянием Double Ratchet
+	if _, err := r.db.ExecContext(ctx, "DROP TABLE IF EXISTS e2e_sessions"); err != nil {
+		log.Printf("[Repo] Failed to drop e2e_sessions: %v", err)
+	}
 
 	// Миграция: Исправление пустых ChatID
 	if err := r.FixMissingChatIDs(ctx); err != nil {
@@ -93,7 +109,18 @@ func (r *Repository) createSchema(ctx context.Context) error {
 		is_verified INTEGER DEFAULT 0,
 		last_seen DATETIME,
 		added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		read_receipts_disabled INTEGER DEFAULT 0,
+		protocol_version INTEGER DEFAULT 0,
+		capabilities INTEGER DEFAULT 0,
+		hide_presence INTEGER DEFAULT 0,
+		peer_hides_presence INTEGER DEFAULT 0,
+		mailbox_address TEXT DEFAULT '',
+		is_pending INTEGER DEFAULT 0,
+		lease_set_auth_key TEXT DEFAULT '',
+		blinded_address TEXT DEFAULT '',
+		packet_version INTEGER DEFAULT 0,
+		intro_key TEXT DEFAULT ''
 	);
 
 	-- Таблица чатов
@@ -121,6 +148,7 @@ func (r *Repository) createSchema(ctx context.Context) error {
 		is_read INTEGER DEFAULT 0,
 		reply_to_id TEXT,
 		timestamp INTEGER NOT NULL,
+		edited_at INTEGER DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
@@ -167,6 +195,151 @@ func (r *Repository) createSchema(ctx context.Context) error {
 		FOREIGN KEY(message_id) REFERENCES messages(id) ON DELETE CASCADE
 	);
 	CREATE INDEX IF NOT EXISTS idx_attachments_message_id ON message_attachments(message_id);
+
+	-- История редактирования сообщений (content зашифрован ключом БД)
+	CREATE TABLE IF NOT EXISTS message_edits (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		message_id TEXT NOT NULL,
+		content TEXT NOT NULL,
+		edited_at INTEGER NOT NULL,
+		FOREIGN KEY(message_id) REFERENCES messages(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_message_edits_message_id ON message_edits(message_id);
+
+	-- Принятые nonce пакетов (защита от повтора), хранятся в пределах окна допустимого расхождения часов
+	CREATE TABLE IF NOT EXISTS replay_nonces (
+		sender_pub_key TEXT NOT NULL,
+		nonce BLOB NOT NULL,
+		timestamp INTEGER NOT NULL,
+		PRIMARY KEY(sender_pub_key, nonce)
+	);
+	CREATE INDEX IF NOT EXISTS idx_replay_nonces_timestamp ON replay_nonces(timestamp);
+
+	-- Передачи файлов частями (filename и local_path зашифрованы ключом БД)
+	CREATE TABLE IF NOT EXISTS file_transfers (
+		message_id TEXT NOT NULL,
+		file_id TEXT NOT NULL,
+		direction TEXT NOT NULL,
+		chat_id TEXT NOT NULL,
+		filename TEXT NOT NULL,
+		mime_type TEXT DEFAULT '',
+		local_path TEXT DEFAULT '',
+		size INTEGER NOT NULL,
+		sha256 BLOB NOT NULL,
+		chunk_size INTEGER NOT NULL,
+		next_index INTEGER DEFAULT 0,
+		status TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY(message_id, file_id, direction)
+	);
+
+	-- Очередь исходящих сообщений, ожидающих доставки
+	CREATE TABLE IF NOT EXISTS outbox (
+		message_id TEXT PRIMARY KEY,
+		chat_id TEXT NOT NULL,
+		attempts INTEGER DEFAULT 0,
+		last_error TEXT DEFAULT '',
+		last_attempt_at INTEGER DEFAULT 0,
+		expires_at INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(message_id) REFERENCES messages(id) ON DELETE CASCADE
+	);
+
+	-- Правки и удаления у собеседника, ожидающие доставки (content зашифрован ключом БД).
+	-- Ссылки на messages нет: удалённое сообщение уже стёрто, а собеседнику о нём сообщить нужно
+	CREATE TABLE IF NOT EXISTS message_changes (
+		message_id TEXT PRIMARY KEY,
+		chat_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		content TEXT DEFAULT '',
+		edited_at INTEGER DEFAULT 0,
+		attempts INTEGER DEFAULT 0,
+		last_error TEXT DEFAULT '',
+		expires_at INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Групповые чаты (name зашифрован ключом БД); ID группы — это ChatID её сообщений
+	CREATE TABLE IF NOT EXISTS chat_groups (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		epoch INTEGER NOT NULL DEFAULT 0,
+		state_updated_at INTEGER DEFAULT 0,
+		signer_pub_key TEXT DEFAULT '',
+		signature BLOB,
+		is_member INTEGER DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		is_pending INTEGER DEFAULT 0
+	);
+
+	-- Участники групп (i2p_address и nickname зашифрованы ключом БД)
+	CREATE TABLE IF NOT EXISTS group_members (
+		group_id TEXT NOT NULL,
+		public_key TEXT NOT NULL,
+		i2p_address TEXT NOT NULL,
+		nickname TEXT DEFAULT '',
+		role INTEGER DEFAULT 0,
+		PRIMARY KEY(group_id, public_key),
+		FOREIGN KEY(group_id) REFERENCES chat_groups(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_group_members_public_key ON group_members(public_key);
+
+	-- Группы в папках (контакты — в folder_chats)
+	CREATE TABLE IF NOT EXISTS folder_groups (
+		folder_id TEXT NOT NULL,
+		group_id TEXT NOT NULL,
+		PRIMARY KEY(folder_id, group_id),
+		FOREIGN KEY(folder_id) REFERENCES folders(id) ON DELETE CASCADE,
+		FOREIGN KEY(group_id) REFERENCES chat_groups(id) ON DELETE CASCADE
+	);
+
+	-- Участники, которым ещё не доставлено групповое сообщение из outbox
+	CREATE TABLE IF NOT EXISTS group_deliveries (
+		message_id TEXT NOT NULL,
+		member_pub_key TEXT NOT NULL,
+		PRIMARY KEY(message_id, member_pub_key),
+		FOREIGN KEY(message_id) REFERENCES messages(id) ON DELETE CASCADE
+	);
+
+	-- Каналы: свои и те, на которые мы подписаны (name и owner_address зашифрованы ключом БД).
+	-- ID канала — это ChatID его постов
+	CREATE TABLE IF NOT EXISTS channels (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		owner_pub_key TEXT DEFAULT '',
+		owner_address TEXT NOT NULL,
+		is_owner INTEGER DEFAULT 0,
+		last_seq INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Номера и подписи постов каналов (текст — в messages)
+	CREATE TABLE IF NOT EXISTS channel_posts (
+		channel_id TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		message_id TEXT NOT NULL UNIQUE,
+		signature BLOB,
+		PRIMARY KEY(channel_id, seq),
+		FOREIGN KEY(channel_id) REFERENCES channels(id) ON DELETE CASCADE,
+		FOREIGN KEY(message_id) REFERENCES messages(id) ON DELETE CASCADE
+	);
+
+	-- Подписчики наших каналов (i2p_address зашифрован ключом БД)
+	CREATE TABLE IF NOT EXISTS channel_subscribers (
+		channel_id TEXT NOT NULL,
+		public_key TEXT NOT NULL,
+		i2p_address TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY(channel_id, public_key),
+		FOREIGN KEY(channel_id) REFERENCES channels(id) ON DELETE CASCADE
+	);
+
+	-- Состояние Double Ratchet по контактам (state зашифрован ключом БД)
+	CREATE TABLE IF NOT EXISTS ratchet_states (
+		peer_pub_key TEXT PRIMARY KEY,
+		state BLOB NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
 	`
 
 	_, err := r.db.ExecContext(ctx, schema)
@@ -231,6 +404,56 @@ func (r *Repository) migrateContactsTable(ctx context.Context) {
 			log.Println("[Repo] Contacts table migrated successfully.")
 		}
 	}
+
+	r.addMissingColumns(ctx, "contacts", []columnDef{
+		{"read_receipts_disabled", "INTEGER DEFAULT 0"},
+		{"protocol_version", "INTEGER DEFAULT 0"},
+		{"capabilities", "INTEGER DEFAULT 0"},
+		{"hide_presence", "INTEGER DEFAULT 0"},
+		{"peer_hides_presence", "INTEGER DEFAULT 0"},
+		{"mailbox_address", "TEXT DEFAULT ''"},
+		{"is_pending", "INTEGER DEFAULT 0"},
+		{"lease_set_auth_key", "TEXT DEFAULT ''"},
+		{"blinded_address", "TEXT DEFAULT ''"},
+		{"packet_version", "INTEGER DEFAULT 0"},
+		{"intro_key", "TEXT DEFAULT ''"},
+	})
+}
+
+// columnDef — описание колонки для addMissingColumns
+type columnDef struct {
+	name       string
+	definition string
+}
+
+// addMissingColumns добавляет в таблицу колонки, которых в ней ещё нет
+func (r *Repository) addMissingColumns(ctx context.Context, table string, columns []columnDef) {
+	rows, err := r.db.QueryContext(ctx, "PRAGMA table_info("+table+")")
+	if err != nil {
+		return
+	}
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var cid int
+		var name, ctype string
+		var notnull, pk int
+		var dfltValue interface{}
+		if errScan := rows.Scan(&cid, &name, &ctype, &notnull, &dfltValue, &pk); errScan == nil {
+			existing[name] = true
+		}
+	}
+	rows.Close()
+
+	for _, col := range columns {
+		if existing[col.name] {
+			continue
+		}
+		log.Printf("[Repo] Adding %s column to %s table...", col.name, table)
+		if _, err := r.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, col.name, col.definition)); err != nil {
+			log.Printf("[Repo] Failed to add column %s.%s: %v", table, col.name, err)
+		}
+	}
 }
 
 func (r *Repository) migrateMessagesTable(ctx context.Context) {
@@ -276,6 +499,10 @@ func (r *Repository) migrateMessagesTable(ctx context.Context) {
 		log.Println("[Repo] Adding total_size column to messages table...")
 		_, _ = r.db.ExecContext(ctx, "ALTER TABLE messages ADD COLUMN total_size INTEGER DEFAULT 0")
 	}
+
+	r.addMissingColumns(ctx, "messages", []columnDef{
+		{"edited_at", "INTEGER DEFAULT 0"},
+	})
 }
 
 // FixMissingChatIDs проверяет контакты на наличие пустых ChatID и исправляет их
@@ -562,8 +789,8 @@ func (r *Repository) UpdateMyProfile(ctx context.Context, nickname, bio, avatar
 func (r *Repository) SaveContact(ctx context.Context, contact *core.Contact) error {
 	query := `
 		INSERT INTO contacts (id, public_key, nickname, bio, avatar, i2p_address, chat_id, 
-		                      is_blocked, is_verified, last_seen, added_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		                      is_blocked, is_verified, is_pending, last_seen, added_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(id) DO UPDATE SET
 			nickname = excluded.nickname,
 			bio = excluded.bio,
@@ -571,6 +798,7 @@ func (r *Repository) SaveContact(ctx context.Context, contact *core.Contact) err
 			i2p_address = excluded.i2p_address,
 			is_blocked = excluded.is_blocked,
 			is_verified = excluded.is_verified,
+			is_pending = excluded.is_pending,
 			last_seen = excluded.last_seen,
 			updated_at = excluded.updated_at
 	`
@@ -589,7 +817,7 @@ func (r *Repository) SaveContact(ctx context.Context, contact *core.Contact) err
 
 	_, err := r.db.ExecContext(ctx, query,
 		contact.ID, pubKey, nickname, bio, contact.Avatar,
-		address, contact.ChatID, contact.IsBlocked, contact.IsVerified,
+		address, contact.ChatID, contact.IsBlocked, contact.IsVerified, contact.IsPending,
 		contact.LastSeen, contact.AddedAt, contact.UpdatedAt,
 	)
 
@@ -600,16 +828,36 @@ func (r *Repository) SaveContact(ctx context.Context, contact *core.Contact) err
 	return nil
 }
 
+// contactColumns возвращает колонки контакта в порядке scanContact (prefix — алиас таблицы)
+func contactColumns(prefix string) string {
+	columns := []string{
+		"id", "public_key", "nickname", "bio", "avatar", "i2p_address", "chat_id",
+		"is_blocked", "is_verified", "last_seen", "added_at", "updated_at",
+		"read_receipts_disabled", "protocol_version", "capabilities",
+		"hide_presence", "peer_hides_presence", "mailbox_address", "is_pending",
+		"lease_set_auth_key", "blinded_address", "intro_key",
+	}
+	for i, c := range columns {
+		columns[i] = prefix + c
+	}
+	return strings.Join(columns, ", ")
+}
+
+// scanContact читает контакт; extra — дополнительные колонки после колонок контакта
 func (r *Repository) scanContact(row interface {
 	Scan(dest ...interface{}) error
-}) (*core.Contact, error) {
+}, extra ...interface{}) (*core.Contact, error) {
 	contact := &core.Contact{}
 	var pubKey sql.NullString
-	err := row.Scan(
+	dest := []interface{}{
 		&contact.ID, &pubKey, &contact.Nickname, &contact.Bio, &contact.Avatar,
 		&contact.I2PAddress, &contact.ChatID, &contact.IsBlocked, &contact.IsVerified,
 		&contact.LastSeen, &contact.AddedAt, &contact.UpdatedAt,
-	)
+		&contact.ReadReceiptsDisabled, &contact.ProtocolVersion, &contact.Capabilities,
+		&contact.HidePresence, &contact.PeerHidesPresence, &contact.MailboxAddress, &contact.IsPending,
+		&contact.LeaseSetAuthKey, &contact.BlindedAddress, &contact.IntroKey,
+	}
+	err := row.Scan(append(dest, extra...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -618,6 +866,8 @@ func (r *Repository) scanContact(row interface {
 	contact.Nickname = r.decryptString(contact.Nickname)
 	contact.Bio = r.decryptString(contact.Bio)
 	contact.I2PAddress = r.decryptString(contact.I2PAddress)
+	contact.MailboxAddress = r.decryptString(contact.MailboxAddress)
+	contact.BlindedAddress = r.decryptString(contact.BlindedAddress)
 
 	return contact, nil
 }
@@ -625,8 +875,7 @@ func (r *Repository) scanContact(row interface {
 // GetContact возвращает контакт по ID
 func (r *Repository) GetContact(ctx context.Context, id string) (*core.Contact, error) {
 	query := `
-		SELECT id, public_key, nickname, bio, avatar, i2p_address, chat_id,
-		       is_blocked, is_verified, last_seen, added_at, updated_at
+		SELECT ` + contactColumns("") + `
 		FROM contacts WHERE id = ?
 	`
 
@@ -644,8 +893,7 @@ func (r *Repository) GetContact(ctx context.Context, id string) (*core.Contact,
 // GetContactByPublicKey возвращает контакт по его публичному ключу
 func (r *Repository) GetContactByPublicKey(ctx context.Context, publicKey string) (*core.Contact, error) {
 	query := `
-		SELECT id, public_key, nickname, bio, avatar, i2p_address, chat_id,
-		       is_blocked, is_verified, last_seen, added_at, updated_at
+		SELECT ` + contactColumns("") + `
 		FROM contacts WHERE public_key = ?
 	`
 
@@ -677,15 +925,34 @@ func (r *Repository) GetContactByAddress(ctx context.Context, address string) (*
 		}
 	}
 
+	// Контакт мог быть добавлен по b32, а пакеты от него приходят с полного адреса
+	if len(address) > 64 && !strings.HasSuffix(strings.ToLower(address), ".i2p") {
+		b32 := i2pkeys.I2PAddr(address).Base32()
+		for _, c := range contacts {
+			if strings.EqualFold(c.I2PAddress, b32) {
+				return c, nil
+			}
+		}
+	}
+
 	return nil, nil
 }
 
 // ListContactsWithLastMessage возвращает список контактов с их последним сообщением
+// (без непринятых запросов переписки)
 func (r *Repository) ListContactsWithLastMessage(ctx context.Context) ([]*core.Contact, error) {
+	return r.listContactsWithLastMessage(ctx, false)
+}
+
+// ListContactRequests возвращает непринятые запросы переписки с последним сообщением
+func (r *Repository) ListContactRequests(ctx context.Context) ([]*core.Contact, error) {
+	return r.listContactsWithLastMessage(ctx, true)
+}
+
+func (r *Repository) listContactsWithLastMessage(ctx context.Context, pending bool) ([]*core.Contact, error) {
 	// Используем JOIN для получения последнего сообщения для каждого контакта (оптимизировано)
 	query := `
-		SELECT c.id, c.public_key, c.nickname, c.bio, c.avatar, c.i2p_address, c.chat_id,
-		       c.is_blocked, c.is_verified, c.last_seen, c.added_at, c.updated_at,
+		SELECT ` + contactColumns("c.") + `,
 		       m.content as last_msg_content, m.timestamp as last_msg_time
 		FROM contacts c
 		LEFT JOIN (
@@ -694,10 +961,11 @@ func (r *Repository) ListContactsWithLastMessage(ctx context.Context) ([]*core.C
 			GROUP BY chat_id
 		) last_msg_meta ON c.chat_id = last_msg_meta.chat_id
 		LEFT JOIN messages m ON m.chat_id = last_msg_meta.chat_id AND m.timestamp = last_msg_meta.max_ts
+		WHERE c.is_pending = ?
 		ORDER BY last_msg_time DESC NULLS LAST, c.nickname ASC
 	`
 
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := r.db.QueryContext(ctx, query, pending)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list contacts with messages: %w", err)
 	}
@@ -705,25 +973,13 @@ func (r *Repository) ListContactsWithLastMessage(ctx context.Context) ([]*core.C
 
 	var contacts []*core.Contact
 	for rows.Next() {
-		contact := &core.Contact{}
-		var pubKey sql.NullString
 		var lastMsgContent sql.NullString
 		var lastMsgTime sql.NullInt64
 
-		err := rows.Scan(
-			&contact.ID, &pubKey, &contact.Nickname, &contact.Bio, &contact.Avatar,
-			&contact.I2PAddress, &contact.ChatID, &contact.IsBlocked, &contact.IsVerified,
-			&contact.LastSeen, &contact.AddedAt, &contact.UpdatedAt,
-			&lastMsgContent, &lastMsgTime,
-		)
+		contact, err := r.scanContact(rows, &lastMsgContent, &lastMsgTime)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan contact with msg: %w", err)
 		}
-		contact.PublicKey = pubKey.String
-
-		contact.Nickname = r.decryptString(contact.Nickname)
-		contact.Bio = r.decryptString(contact.Bio)
-		contact.I2PAddress = r.decryptString(contact.I2PAddress)
 
 		if lastMsgContent.Valid {
 			contact.LastMessage = r.decryptString(lastMsgContent.String)
@@ -741,8 +997,7 @@ func (r *Repository) ListContactsWithLastMessage(ctx context.Context) ([]*core.C
 // ListContacts возвращает список всех контактов
 func (r *Repository) ListContacts(ctx context.Context) ([]*core.Contact, error) {
 	query := `
-		SELECT id, public_key, nickname, bio, avatar, i2p_address, chat_id,
-		       is_blocked, is_verified, last_seen, added_at, updated_at
+		SELECT ` + contactColumns("") + `
 		FROM contacts
 		ORDER BY nickname ASC
 	`
@@ -765,6 +1020,117 @@ func (r *Repository) ListContacts(ctx context.Context) ([]*core.Contact, error)
 	return contacts, rows.Err()
 }
 
+// UpdateContactProtocol сохраняет согласованную версию протокола и возможности контакта
+func (r *Repository) UpdateContactProtocol(ctx context.Context, publicKey string, version int, capabilities uint64) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE contacts SET protocol_version = ?, capabilities = ? WHERE public_key = ?",
+		version, int64(capabilities), publicKey) // #nosec G115 -- битовая маска хранится как есть
+	if err != nil {
+		return fmt.Errorf("failed to update contact protocol: %w", err)
+	}
+	return nil
+}
+
+// UpdateContactLastSeen обновляет время последней активности контакта
+func (r *Repository) UpdateContactLastSeen(ctx context.Context, publicKey string, seen time.Time) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE contacts SET last_seen = ? WHERE public_key = ?", seen, publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to update last seen: %w", err)
+	}
+	return nil
+}
+
+// SetContactHidePresence скрывает или показывает контакту наш статус в сети
+func (r *Repository) SetContactHidePresence(ctx context.Context, id string, hide bool) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE contacts SET hide_presence = ? WHERE id = ?", hide, id)
+	if err != nil {
+		return fmt.Errorf("failed to update presence setting: %w", err)
+	}
+	return nil
+}
+
+// SetContactPeerHidesPresence запоминает, скрывает ли контакт от нас свой статус
+func (r *Repository) SetContactPeerHidesPresence(ctx context.Context, publicKey string, hidden bool) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE contacts SET peer_hides_presence = ? WHERE public_key = ?", hidden, publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to update peer presence setting: %w", err)
+	}
+	return nil
+}
+
+// SetContactMailbox запоминает почтовый сервер контакта ("" — ящика нет)
+func (r *Repository) SetContactMailbox(ctx context.Context, publicKey, address string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE contacts SET mailbox_address = ? WHERE public_key = ?", r.encryptString(address), publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to update contact mailbox: %w", err)
+	}
+	return nil
+}
+
+// SetContactLeaseSetKey запоминает X25519 ключ контакта для нашего зашифрованного leaseSet
+func (r *Repository) SetContactLeaseSetKey(ctx context.Context, publicKey, authKey string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE contacts SET lease_set_auth_key = ? WHERE public_key = ?", authKey, publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to update contact leaseSet key: %w", err)
+	}
+	return nil
+}
+
+// SetContactIntroKey запоминает статический ключ контакта из его адреса (по ID: ключ отправителя
+// ещё может быть неизвестен)
+func (r *Repository) SetContactIntroKey(ctx context.Context, id, introKey string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE contacts SET intro_key = ? WHERE id = ?", introKey, id)
+	if err != nil {
+		return fmt.Errorf("failed to update contact intro key: %w", err)
+	}
+	return nil
+}
+
+// SetContactBlindedAddress запоминает b33 адрес контакта ("" — leaseSet открытый)
+func (r *Repository) SetContactBlindedAddress(ctx context.Context, publicKey, address string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE contacts SET blinded_address = ? WHERE public_key = ?", r.encryptString(address), publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to update contact blinded address: %w", err)
+	}
+	return nil
+}
+
+// SetContactReadReceipts включает или выключает отчёты о прочтении для контакта
+func (r *Repository) SetContactReadReceipts(ctx context.Context, id string, enabled bool) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE contacts SET read_receipts_disabled = ? WHERE id = ?", !enabled, id)
+	if err != nil {
+		return fmt.Errorf("failed to update read receipts setting: %w", err)
+	}
+	return nil
+}
+
+// SetContactPending помечает контакт непринятым запросом переписки или снимает пометку
+func (r *Repository) SetContactPending(ctx context.Context, id string, pending bool) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE contacts SET is_pending = ?, updated_at = ? WHERE id = ?", pending, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update contact request state: %w", err)
+	}
+	return nil
+}
+
+// BlockContact блокирует или разблокирует контакт. Блокировка снимает пометку запроса переписки.
+func (r *Repository) BlockContact(ctx context.Context, id string, blocked bool) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE contacts SET is_blocked = ?, is_pending = 0, updated_at = ? WHERE id = ?", blocked, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to block contact: %w", err)
+	}
+	return nil
+}
+
+// DeleteChatMessages удаляет все сообщения чата (например, карантин отклонённого запроса)
+func (r *Repository) DeleteChatMessages(ctx context.Context, chatID string) error {
+	// Вложения и outbox удаляются каскадом
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM messages WHERE chat_id = ?", chatID); err != nil {
+		return fmt.Errorf("failed to delete chat messages: %w", err)
+	}
+	return nil
+}
+
 // DeleteContact удаляет контакт по ID
 func (r *Repository) DeleteContact(ctx context.Context, id string) error {
 	_, err := r.db.ExecContext(ctx, "DELETE FROM contacts WHERE id = ?", id)
@@ -776,12 +1142,16 @@ func (r *Repository) DeleteContact(ctx context.Context, id string) error {
 
 // === Message Methods ===
 
-// SaveMessage сохраняет сообщение
+// insertMessageQuery вставляет строку сообщения; SaveMessage и InsertMessage дописывают поведение при конфликте
+const insertMessageQuery = `
+	INSERT INTO messages (id, chat_id, sender_id, content, content_type, status,
+	                      is_outgoing, reply_to_id, timestamp, created_at, updated_at, file_count, total_size)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+// SaveMessage сохраняет сообщение (существующее с тем же ID перезаписывается)
 func (r *Repository) SaveMessage(ctx context.Context, msg *core.Message) error {
-	query := `
-		INSERT INTO messages (id, chat_id, sender_id, content, content_type, status, 
-		                      is_outgoing, reply_to_id, timestamp, created_at, updated_at, file_count, total_size)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	return r.saveMessage(ctx, msg, insertMessageQuery+`
 		ON CONFLICT(id) DO UPDATE SET
 			content = excluded.content,
 			content_type = excluded.content_type,
@@ -789,8 +1159,16 @@ func (r *Repository) SaveMessage(ctx context.Context, msg *core.Message) error {
 			updated_at = excluded.updated_at,
 			file_count = excluded.file_count,
 			total_size = excluded.total_size
-	`
+	`)
+}
+
+// InsertMessage сохраняет входящее сообщение, только если его ID ещё не занят.
+// Иначе возвращает ErrMessageExists: ID задаёт отправитель, и чужое сообщение он перезаписать не должен.
+func (r *Repository) InsertMessage(ctx context.Context, msg *core.Message) error {
+	return r.saveMessage(ctx, msg, insertMessageQuery+" ON CONFLICT(id) DO NOTHING")
+}
 
+func (r *Repository) saveMessage(ctx context.Context, msg *core.Message, query string) error {
 	now := time.Now()
 	if msg.CreatedAt.IsZero() {
 		msg.CreatedAt = now
@@ -805,7 +1183,7 @@ func (r *Repository) SaveMessage(ctx context.Context, msg *core.Message) error {
 		}
 	}
 
-	_, err := r.db.ExecContext(ctx, query,
+	res, err := r.db.ExecContext(ctx, query,
 		msg.ID, msg.ChatID, msg.SenderID, content, msg.ContentType, msg.Status,
 		msg.IsOutgoing, msg.ReplyToID, msg.Timestamp, msg.CreatedAt, msg.UpdatedAt,
 		msg.FileCount, msg.TotalSize,
@@ -814,6 +1192,9 @@ func (r *Repository) SaveMessage(ctx context.Context, msg *core.Message) error {
 	if err != nil {
 		return fmt.Errorf("failed to save message: %w", err)
 	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrMessageExists
+	}
 
 	// Сохраняем вложения
 	// Сначала удаляем старые, чтобы обновить список (например, при переходе от Offer к Real файлам)
@@ -851,7 +1232,7 @@ func (r *Repository) SaveMessage(ctx context.Context, msg *core.Message) error {
 func (r *Repository) GetMessage(ctx context.Context, id string) (*core.Message, error) {
 	query := `
 		SELECT id, chat_id, sender_id, content, content_type, status,
-		       is_outgoing, reply_to_id, timestamp, created_at, updated_at, file_count, total_size
+		       is_outgoing, reply_to_id, timestamp, created_at, updated_at, file_count, total_size, edited_at
 		FROM messages WHERE id = ?
 	`
 
@@ -877,7 +1258,7 @@ func (r *Repository) scanMessage(row interface {
 	err := row.Scan(
 		&msg.ID, &msg.ChatID, &msg.SenderID, &msg.Content, &msg.ContentType, &msg.Status,
 		&msg.IsOutgoing, &msg.ReplyToID, &msg.Timestamp, &msg.CreatedAt, &msg.UpdatedAt,
-		&msg.FileCount, &msg.TotalSize,
+		&msg.FileCount, &msg.TotalSize, &msg.EditedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -959,7 +1340,7 @@ func (r *Repository) GetChatHistory(ctx context.Context, chatID string, limit, o
 	query := `
 		SELECT * FROM (
 			SELECT id, chat_id, sender_id, content, content_type, status,
-			       is_outgoing, reply_to_id, timestamp, created_at, updated_at, file_count, total_size
+			       is_outgoing, reply_to_id, timestamp, created_at, updated_at, file_count, total_size, edited_at
 			FROM messages
 			WHERE chat_id = ?
 			ORDER BY timestamp DESC
@@ -1008,134 +1389,1005 @@ func (r *Repository) DeleteMessage(ctx context.Context, id string) error {
 	return nil
 }
 
-// UpdateMessageContent обновляет содержимое сообщения (редактирование)
-func (r *Repository) UpdateMessageContent(ctx context.Context, id, newContent string) error {
-	query := `UPDATE messages SET content = ?, updated_at = ? WHERE id = ?`
+// UpdateMessageContent обновляет содержимое сообщения (редактирование).
+// Предыдущая версия сохраняется в историю правок.
+func (r *Repository) UpdateMessageContent(ctx context.Context, id, newContent string, editedAt int64) error {
+	var oldContent string
+	var writtenAt int64 // когда появилась текущая версия: прошлая правка или отправка
+	err := r.db.QueryRowContext(ctx, "SELECT content, COALESCE(NULLIF(edited_at, 0), timestamp) FROM messages WHERE id = ?", id).Scan(&oldContent, &writtenAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("message not found: %s", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get message content: %w", err)
+	}
 
-	result, err := r.db.ExecContext(ctx, query, newContent, time.Now(), id)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to update message content: %w", err)
+		return fmt.Errorf("failed to begin tx: %w", err)
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	rows, _ := result.RowsAffected()
-	if rows == 0 {
-		return fmt.Errorf("message not found: %s", id)
+	// Старое содержимое уже зашифровано — переносим как есть
+	_, err = tx.ExecContext(ctx, "INSERT INTO message_edits (message_id, content, edited_at) VALUES (?, ?, ?)", id, oldContent, writtenAt)
+	if err != nil {
+		return fmt.Errorf("failed to save edit history: %w", err)
 	}
 
-	return nil
-}
+	_, err = tx.ExecContext(ctx, "UPDATE messages SET content = ?, edited_at = ?, updated_at = ? WHERE id = ?",
+		r.encryptString(newContent), editedAt, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update message content: %w", err)
+	}
 
-// SearchMessages ищет сообщения по тексту в чате
-func (r *Repository) SearchMessages(ctx context.Context, chatID, queryStr string) ([]*core.Message, error) {
-	query := `
-		SELECT id, chat_id, sender_id, content, content_type, status,
-		       is_outgoing, reply_to_id, timestamp, created_at, updated_at, file_count, total_size
-		FROM messages
-		WHERE chat_id = ? AND content LIKE ?
-		ORDER BY timestamp DESC
-		LIMIT 50
-	`
+	return tx.Commit()
+}
 
-	rows, err := r.db.QueryContext(ctx, query, chatID, "%"+queryStr+"%")
+// GetMessageEdits возвращает предыдущие версии сообщения (от старых к новым)
+func (r *Repository) GetMessageEdits(ctx context.Context, messageID string) ([]*core.MessageEdit, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT message_id, content, edited_at FROM message_edits WHERE message_id = ? ORDER BY id ASC", messageID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search messages: %w", err)
+		return nil, fmt.Errorf("failed to get message edits: %w", err)
 	}
 	defer rows.Close()
 
-	var messages []*core.Message
+	var edits []*core.MessageEdit
 	for rows.Next() {
-		msg := &core.Message{}
-		err := rows.Scan(
-			&msg.ID, &msg.ChatID, &msg.SenderID, &msg.Content, &msg.ContentType, &msg.Status,
-			&msg.IsOutgoing, &msg.ReplyToID, &msg.Timestamp, &msg.CreatedAt, &msg.UpdatedAt,
-			&msg.FileCount, &msg.TotalSize,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan message: %w", err)
+		edit := &core.MessageEdit{}
+		if err := rows.Scan(&edit.MessageID, &edit.Content, &edit.EditedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan message edit: %w", err)
 		}
-		messages = append(messages, msg)
+		edit.Content = r.decryptString(edit.Content)
+		edits = append(edits, edit)
 	}
 
-	return messages, r.enrichMessagesWithAttachments(ctx, messages)
+	return edits, rows.Err()
 }
 
-// === Chat Methods ===
-
-// GetChat возвращает чат по ID
-func (r *Repository) GetChat(ctx context.Context, id string) (*core.Chat, error) {
-	query := `SELECT id, contact_id, last_message_id, unread_count, is_muted, created_at, updated_at FROM chats WHERE id = ?`
-
-	chat := &core.Chat{}
-	var lastMsgID sql.NullString
+// === Replay Cache Methods ===
 
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&chat.ID, &chat.ContactID, &lastMsgID, &chat.UnreadCount, &chat.IsMuted, &chat.CreatedAt, &chat.UpdatedAt,
-	)
-
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("chat not found")
-	}
+// RememberNonce запоминает nonce пакета; false — такой nonce от отправителя уже встречался
+func (r *Repository) RememberNonce(ctx context.Context, senderPubKey string, nonce []byte, timestamp int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		"INSERT OR IGNORE INTO replay_nonces (sender_pub_key, nonce, timestamp) VALUES (?, ?, ?)",
+		senderPubKey, nonce, timestamp)
 	if err != nil {
-		return nil, err
+		return false, fmt.Errorf("failed to remember nonce: %w", err)
 	}
 
-	if lastMsgID.Valid {
-		chat.LastMessageID = &lastMsgID.String
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
 	}
+	return n > 0, nil
+}
 
-	return chat, nil
+// PruneNonces удаляет nonce старше before (unix ms)
+func (r *Repository) PruneNonces(ctx context.Context, before int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM replay_nonces WHERE timestamp < ?", before)
+	if err != nil {
+		return fmt.Errorf("failed to prune nonces: %w", err)
+	}
+	return nil
 }
 
-// === Folder Methods ===
+// SavePacketVersion поднимает версию пакетов контакта до version; false — отправитель не контакт
+func (r *Repository) SavePacketVersion(ctx context.Context, senderPubKey string, version uint32) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE contacts SET packet_version = MAX(packet_version, ?) WHERE public_key = ?",
+		version, senderPubKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to save packet version: %w", err)
+	}
 
-// CreateFolder создаёт новую папку
-func (r *Repository) CreateFolder(ctx context.Context, folder *core.Folder) error {
-	query := `INSERT INTO folders (id, name, icon, position) VALUES (?, ?, ?, ?) ON CONFLICT(id) DO UPDATE SET name=excluded.name, icon=excluded.icon, position=excluded.position`
-	_, err := r.db.ExecContext(ctx, query, folder.ID, folder.Name, folder.Icon, folder.Position)
-	return err
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
 }
 
-// GetFolders возвращает все папки
-func (r *Repository) GetFolders(ctx context.Context) ([]*core.Folder, error) {
-	query := `SELECT id, name, icon, position FROM folders ORDER BY position ASC`
-	rows, err := r.db.QueryContext(ctx, query)
+// PacketVersions возвращает наибольшие замеченные версии пакетов контактов
+func (r *Repository) PacketVersions(ctx context.Context) (map[string]uint32, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT public_key, packet_version FROM contacts WHERE public_key IS NOT NULL AND packet_version > 0")
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to list packet versions: %w", err)
 	}
 	defer rows.Close()
 
-	var folders []*core.Folder
+	versions := make(map[string]uint32)
 	for rows.Next() {
-		f := &core.Folder{}
-		if err := rows.Scan(&f.ID, &f.Name, &f.Icon, &f.Position); err != nil {
+		var pubKey string
+		var version uint32
+		if err := rows.Scan(&pubKey, &version); err != nil {
 			return nil, err
 		}
-		folders = append(folders, f)
+		versions[pubKey] = version
 	}
-	return folders, nil
+	return versions, rows.Err()
 }
 
-// DeleteFolder удаляет папку
-func (r *Repository) DeleteFolder(ctx context.Context, id string) error {
-	_, err := r.db.ExecContext(ctx, "DELETE FROM folders WHERE id = ?", id)
-	return err
+// === File Transfer Methods ===
+
+// SaveFileTransfer сохраняет состояние передачи файла
+func (r *Repository) SaveFileTransfer(ctx context.Context, t *core.FileTransfer) error {
+	t.UpdatedAt = time.Now()
+
+	query := `
+		INSERT INTO file_transfers (message_id, file_id, direction, chat_id, filename, mime_type, local_path,
+		                            size, sha256, chunk_size, next_index, status, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(message_id, file_id, direction) DO UPDATE SET
+			local_path = excluded.local_path,
+			next_index = excluded.next_index,
+			status = excluded.status,
+			updated_at = excluded.updated_at
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		t.MessageID, t.FileID, string(t.Direction), t.ChatID, r.encryptString(t.Filename), t.MimeType,
+		r.encryptString(t.LocalPath), t.Size, t.SHA256, t.ChunkSize, t.NextIndex, string(t.Status), t.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save file transfer: %w", err)
+	}
+	return nil
+}
+
+// ListFileTransfers возвращает файлы одной передачи
+func (r *Repository) ListFileTransfers(ctx context.Context, messageID string, direction core.TransferDirection) ([]*core.FileTransfer, error) {
+	return r.queryFileTransfers(ctx, "WHERE message_id = ? AND direction = ? ORDER BY rowid", messageID, string(direction))
+}
+
+// ListActiveFileTransfers возвращает незавершённые принятые передачи (для продолжения)
+func (r *Repository) ListActiveFileTransfers(ctx context.Context) ([]*core.FileTransfer, error) {
+	return r.queryFileTransfers(ctx, "WHERE status = ? ORDER BY rowid", string(core.TransferAccepted))
+}
+
+func (r *Repository) queryFileTransfers(ctx context.Context, where string, args ...interface{}) ([]*core.FileTransfer, error) {
+	// #nosec G202
+	query := `
+		SELECT message_id, file_id, direction, chat_id, filename, mime_type, local_path,
+		       size, sha256, chunk_size, next_index, status, updated_at
+		FROM file_transfers ` + where
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list file transfers: %w", err)
+	}
+	defer rows.Close()
+
+	var transfers []*core.FileTransfer
+	for rows.Next() {
+		t := &core.FileTransfer{}
+		var direction, status string
+		err := rows.Scan(&t.MessageID, &t.FileID, &direction, &t.ChatID, &t.Filename, &t.MimeType, &t.LocalPath,
+			&t.Size, &t.SHA256, &t.ChunkSize, &t.NextIndex, &status, &t.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan file transfer: %w", err)
+		}
+		t.Direction = core.TransferDirection(direction)
+		t.Status = core.TransferStatus(status)
+		t.Filename = r.decryptString(t.Filename)
+		t.LocalPath = r.decryptString(t.LocalPath)
+		transfers = append(transfers, t)
+	}
+
+	return transfers, rows.Err()
+}
+
+// UpdateFileTransferProgress сохраняет номер следующей части
+func (r *Repository) UpdateFileTransferProgress(ctx context.Context, messageID, fileID string, direction core.TransferDirection, nextIndex uint32) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE file_transfers SET next_index = ?, updated_at = ? WHERE message_id = ? AND file_id = ? AND direction = ?",
+		nextIndex, time.Now(), messageID, fileID, string(direction))
+	if err != nil {
+		return fmt.Errorf("failed to update file transfer progress: %w", err)
+	}
+	return nil
+}
+
+// DeleteFileTransfers удаляет состояние передачи
+func (r *Repository) DeleteFileTransfers(ctx context.Context, messageID string, direction core.TransferDirection) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM file_transfers WHERE message_id = ? AND direction = ?", messageID, string(direction))
+	if err != nil {
+		return fmt.Errorf("failed to delete file transfers: %w", err)
+	}
+	return nil
+}
+
+// === Outbox Methods ===
+
+// EnqueueOutbox ставит сообщение в очередь на отправку (повторная постановка сбрасывает попытки)
+func (r *Repository) EnqueueOutbox(ctx context.Context, entry *core.OutboxEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO outbox (message_id, chat_id, attempts, last_error, last_attempt_at, expires_at, created_at)
+		VALUES (?, ?, 0, '', 0, ?, ?)
+		ON CONFLICT(message_id) DO UPDATE SET
+			attempts = 0,
+			last_error = '',
+			last_attempt_at = 0,
+			expires_at = excluded.expires_at
+	`
+
+	_, err := r.db.ExecContext(ctx, query, entry.MessageID, entry.ChatID, entry.ExpiresAt, entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue message: %w", err)
+	}
+	return nil
+}
+
+// ListOutbox возвращает очередь отправки в порядке постановки
+func (r *Repository) ListOutbox(ctx context.Context) ([]*core.OutboxEntry, error) {
+	query := `
+		SELECT o.message_id, o.chat_id, o.attempts, o.last_error, o.last_attempt_at, o.expires_at, o.created_at
+		FROM outbox o
+		JOIN messages m ON m.id = o.message_id
+		ORDER BY m.timestamp ASC, o.created_at ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*core.OutboxEntry
+	for rows.Next() {
+		e := &core.OutboxEntry{}
+		if err := rows.Scan(&e.MessageID, &e.ChatID, &e.Attempts, &e.LastError, &e.LastAttemptAt, &e.ExpiresAt, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// RecordOutboxAttempt фиксирует неудачную попытку отправки
+func (r *Repository) RecordOutboxAttempt(ctx context.Context, messageID, lastError string) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE outbox SET attempts = attempts + 1, last_error = ?, last_attempt_at = ? WHERE message_id = ?",
+		lastError, time.Now().UnixMilli(), messageID)
+	if err != nil {
+		return fmt.Errorf("failed to record outbox attempt: %w", err)
+	}
+	return nil
+}
+
+// DeleteOutbox убирает сообщение из очереди отправки
+func (r *Repository) DeleteOutbox(ctx context.Context, messageID string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM outbox WHERE message_id = ?", messageID)
+	if err != nil {
+		return fmt.Errorf("failed to delete outbox entry: %w", err)
+	}
+	return nil
+}
+
+// EnqueueMessageChange ставит правку или удаление в очередь. Новое изменение того же
+// сообщения заменяет прежнее: собеседнику нужна только последняя версия.
+func (r *Repository) EnqueueMessageChange(ctx context.Context, c *core.MessageChange) error {
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO message_changes (message_id, chat_id, kind, content, edited_at, attempts, last_error, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, 0, '', ?, ?)
+		ON CONFLICT(message_id) DO UPDATE SET
+			kind = excluded.kind,
+			content = excluded.content,
+			edited_at = excluded.edited_at,
+			attempts = 0,
+			last_error = '',
+			expires_at = excluded.expires_at,
+			created_at = excluded.created_at
+	`
+
+	_, err := r.db.ExecContext(ctx, query, c.MessageID, c.ChatID, c.Kind, r.encryptString(c.Content), c.EditedAt, c.ExpiresAt, c.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue message change: %w", err)
+	}
+	return nil
+}
+
+// ListMessageChanges возвращает очередь правок и удалений в порядке постановки
+func (r *Repository) ListMessageChanges(ctx context.Context) ([]*core.MessageChange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT message_id, chat_id, kind, content, edited_at, attempts, last_error, expires_at, created_at
+		FROM message_changes ORDER BY created_at ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list message changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []*core.MessageChange
+	for rows.Next() {
+		c := &core.MessageChange{}
+		if err := rows.Scan(&c.MessageID, &c.ChatID, &c.Kind, &c.Content, &c.EditedAt, &c.Attempts, &c.LastError, &c.ExpiresAt, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan message change: %w", err)
+		}
+		c.Content = r.decryptString(c.Content)
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// RecordMessageChangeAttempt фиксирует неудачную попытку отправить изменение
+func (r *Repository) RecordMessageChangeAttempt(ctx context.Context, messageID, lastError string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE message_changes SET attempts = attempts + 1, last_error = ? WHERE message_id = ?", lastError, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to record message change attempt: %w", err)
+	}
+	return nil
+}
+
+// DeleteMessageChange убирает отправленное изменение из очереди. Более новое изменение
+// того же сообщения, поставленное за время отправки, остаётся.
+func (r *Repository) DeleteMessageChange(ctx context.Context, c *core.MessageChange) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM message_changes WHERE message_id = ? AND kind = ? AND edited_at = ?", c.MessageID, c.Kind, c.EditedAt)
+	if err != nil {
+		return fmt.Errorf("failed to delete message change: %w", err)
+	}
+	return nil
+}
+
+// AddGroupDeliveries запоминает участников, которым нужно доставить групповое сообщение
+func (r *Repository) AddGroupDeliveries(ctx context.Context, messageID string, memberPubKeys []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, pubKey := range memberPubKeys {
+		_, err := tx.ExecContext(ctx,
+			"INSERT OR IGNORE INTO group_deliveries (message_id, member_pub_key) VALUES (?, ?)", messageID, pubKey)
+		if err != nil {
+			return fmt.Errorf("failed to add group delivery: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ListGroupDeliveries возвращает участников, которым сообщение ещё не доставлено
+func (r *Repository) ListGroupDeliveries(ctx context.Context, messageID string) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT member_pub_key FROM group_deliveries WHERE message_id = ? ORDER BY rowid", messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var pubKeys []string
+	for rows.Next() {
+		var pubKey string
+		if err := rows.Scan(&pubKey); err != nil {
+			return nil, fmt.Errorf("failed to scan group delivery: %w", err)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	return pubKeys, rows.Err()
+}
+
+// DeleteGroupDelivery отмечает, что участнику сообщение доставлено
+func (r *Repository) DeleteGroupDelivery(ctx context.Context, messageID, memberPubKey string) error {
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM group_deliveries WHERE message_id = ? AND member_pub_key = ?", messageID, memberPubKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete group delivery: %w", err)
+	}
+	return nil
+}
+
+// === Group Methods ===
+
+// SaveGroup сохраняет группу и заменяет список её участников
+func (r *Repository) SaveGroup(ctx context.Context, g *core.Group) error {
+	if g.CreatedAt.IsZero() {
+		g.CreatedAt = time.Now()
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO chat_groups (id, name, epoch, state_updated_at, signer_pub_key, signature, is_member, is_pending, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			epoch = excluded.epoch,
+			state_updated_at = excluded.state_updated_at,
+			signer_pub_key = excluded.signer_pub_key,
+			signature = excluded.signature,
+			is_member = excluded.is_member,
+			is_pending = excluded.is_pending`,
+		g.ID, r.encryptString(g.Name), int64(g.Epoch), g.StateUpdatedAt, g.SignerPubKey, g.Signature, g.IsMember, g.IsPending, g.CreatedAt) // #nosec G115
+	if err != nil {
+		return fmt.Errorf("failed to save group: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM group_members WHERE group_id = ?", g.ID); err != nil {
+		return fmt.Errorf("failed to reset group members: %w", err)
+	}
+	for _, m := range g.Members {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO group_members (group_id, public_key, i2p_address, nickname, role) VALUES (?, ?, ?, ?, ?)",
+			g.ID, m.PublicKey, r.encryptString(m.I2PAddress), r.encryptString(m.Nickname), int(m.Role))
+		if err != nil {
+			return fmt.Errorf("failed to save group member: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+const groupColumns = "g.id, g.name, g.epoch, g.state_updated_at, g.signer_pub_key, g.signature, g.is_member, g.is_pending, g.created_at"
+
+// scanGroup читает группу; extra — дополнительные колонки после колонок группы
+func (r *Repository) scanGroup(row interface {
+	Scan(dest ...interface{}) error
+}, extra ...interface{}) (*core.Group, error) {
+	g := &core.Group{}
+	var epoch int64
+	dest := []interface{}{&g.ID, &g.Name, &epoch, &g.StateUpdatedAt, &g.SignerPubKey, &g.Signature, &g.IsMember, &g.IsPending, &g.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	g.Epoch = uint64(epoch) // #nosec G115
+	g.Name = r.decryptString(g.Name)
+	return g, nil
+}
+
+// GetGroup возвращает группу с участниками (nil, если группы нет)
+func (r *Repository) GetGroup(ctx context.Context, id string) (*core.Group, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+groupColumns+" FROM chat_groups g WHERE g.id = ?", id)
+	g, err := r.scanGroup(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+
+	if g.Members, err = r.listGroupMembers(ctx, id); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// ListGroups возвращает принятые группы с участниками и последним сообщением
+func (r *Repository) ListGroups(ctx context.Context) ([]*core.Group, error) {
+	return r.listGroups(ctx, false)
+}
+
+// ListGroupInvites возвращает непринятые приглашения в группы
+func (r *Repository) ListGroupInvites(ctx context.Context) ([]*core.Group, error) {
+	return r.listGroups(ctx, true)
+}
+
+func (r *Repository) listGroups(ctx context.Context, pending bool) ([]*core.Group, error) {
+	query := `
+		SELECT ` + groupColumns + `,
+		       m.content as last_msg_content, m.timestamp as last_msg_time
+		FROM chat_groups g
+		LEFT JOIN (
+			SELECT chat_id, MAX(timestamp) as max_ts
+			FROM messages
+			GROUP BY chat_id
+		) last_msg_meta ON g.id = last_msg_meta.chat_id
+		LEFT JOIN messages m ON m.chat_id = last_msg_meta.chat_id AND m.timestamp = last_msg_meta.max_ts
+		WHERE g.is_pending = ?
+		ORDER BY last_msg_time DESC NULLS LAST, g.created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []*core.Group
+	for rows.Next() {
+		var lastMsgContent sql.NullString
+		var lastMsgTime sql.NullInt64
+
+		g, err := r.scanGroup(rows, &lastMsgContent, &lastMsgTime)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan group: %w", err)
+		}
+		if lastMsgContent.Valid {
+			g.LastMessage = r.decryptString(lastMsgContent.String)
+			if lastMsgTime.Valid {
+				g.LastMessageTime = time.UnixMilli(lastMsgTime.Int64)
+			}
+		}
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, g := range groups {
+		if g.Members, err = r.listGroupMembers(ctx, g.ID); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+func (r *Repository) listGroupMembers(ctx context.Context, groupID string) ([]*core.GroupMember, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT group_id, public_key, i2p_address, nickname, role FROM group_members WHERE group_id = ? ORDER BY role DESC, rowid",
+		groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []*core.GroupMember
+	for rows.Next() {
+		m := &core.GroupMember{}
+		var role int
+		if err := rows.Scan(&m.GroupID, &m.PublicKey, &m.I2PAddress, &m.Nickname, &role); err != nil {
+			return nil, fmt.Errorf("failed to scan group member: %w", err)
+		}
+		m.Role = core.GroupRole(role)
+		m.I2PAddress = r.decryptString(m.I2PAddress)
+		m.Nickname = r.decryptString(m.Nickname)
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// ListGroupIDsByMember возвращает ID принятых групп, в которых состоит участник
+func (r *Repository) ListGroupIDsByMember(ctx context.Context, pubKey string) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT m.group_id FROM group_members m
+		JOIN chat_groups g ON g.id = m.group_id
+		WHERE m.public_key = ? AND g.is_pending = 0`, pubKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list member groups: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan member group: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SetGroupPending отмечает группу как непринятое приглашение или снимает отметку
+func (r *Repository) SetGroupPending(ctx context.Context, id string, pending bool) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE chat_groups SET is_pending = ? WHERE id = ?", pending, id)
+	if err != nil {
+		return fmt.Errorf("failed to update group: %w", err)
+	}
+	return nil
+}
+
+// DeleteGroup удаляет группу вместе с её сообщениями
+func (r *Repository) DeleteGroup(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Вложения, outbox и group_deliveries удаляются каскадом
+	if _, err := tx.ExecContext(ctx, "DELETE FROM messages WHERE chat_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete group messages: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM chat_groups WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+	return tx.Commit()
+}
+
+// === Channel Methods ===
+
+// SaveChannel сохраняет канал. Номер последнего поста не уменьшается.
+func (r *Repository) SaveChannel(ctx context.Context, c *core.Channel) error {
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO channels (id, name, owner_pub_key, owner_address, is_owner, last_seq, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			owner_pub_key = excluded.owner_pub_key,
+			owner_address = excluded.owner_address,
+			last_seq = MAX(last_seq, excluded.last_seq)`,
+		c.ID, r.encryptString(c.Name), c.OwnerPubKey, r.encryptString(c.OwnerAddress), c.IsOwner, int64(c.LastSeq), c.CreatedAt) // #nosec G115
+	if err != nil {
+		return fmt.Errorf("failed to save channel: %w", err)
+	}
+	return nil
+}
+
+const channelColumns = `c.id, c.name, c.owner_pub_key, c.owner_address, c.is_owner, c.last_seq, c.created_at,
+	(SELECT COUNT(*) FROM channel_subscribers s WHERE s.channel_id = c.id)`
+
+// scanChannel читает канал; extra — дополнительные колонки после колонок канала
+func (r *Repository) scanChannel(row interface {
+	Scan(dest ...interface{}) error
+}, extra ...interface{}) (*core.Channel, error) {
+	c := &core.Channel{}
+	var lastSeq int64
+	dest := []interface{}{&c.ID, &c.Name, &c.OwnerPubKey, &c.OwnerAddress, &c.IsOwner, &lastSeq, &c.CreatedAt, &c.SubscriberCount}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	c.LastSeq = uint64(lastSeq) // #nosec G115
+	c.Name = r.decryptString(c.Name)
+	c.OwnerAddress = r.decryptString(c.OwnerAddress)
+	return c, nil
+}
+
+// GetChannel возвращает канал по ID (nil, если канала нет)
+func (r *Repository) GetChannel(ctx context.Context, id string) (*core.Channel, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+channelColumns+" FROM channels c WHERE c.id = ?", id)
+	c, err := r.scanChannel(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel: %w", err)
+	}
+	return c, nil
+}
+
+// ListChannels возвращает каналы с последним постом
+func (r *Repository) ListChannels(ctx context.Context) ([]*core.Channel, error) {
+	query := `
+		SELECT ` + channelColumns + `,
+		       m.content as last_msg_content, m.timestamp as last_msg_time
+		FROM channels c
+		LEFT JOIN (
+			SELECT chat_id, MAX(timestamp) as max_ts
+			FROM messages
+			GROUP BY chat_id
+		) last_msg_meta ON c.id = last_msg_meta.chat_id
+		LEFT JOIN messages m ON m.chat_id = last_msg_meta.chat_id AND m.timestamp = last_msg_meta.max_ts
+		ORDER BY last_msg_time DESC NULLS LAST, c.created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list channels: %w", err)
+	}
+	defer rows.Close()
+
+	var channels []*core.Channel
+	for rows.Next() {
+		var lastMsgContent sql.NullString
+		var lastMsgTime sql.NullInt64
+
+		c, err := r.scanChannel(rows, &lastMsgContent, &lastMsgTime)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan channel: %w", err)
+		}
+		if lastMsgContent.Valid {
+			c.LastMessage = r.decryptString(lastMsgContent.String)
+			if lastMsgTime.Valid {
+				c.LastMessageTime = time.UnixMilli(lastMsgTime.Int64)
+			}
+		}
+		channels = append(channels, c)
+	}
+	return channels, rows.Err()
+}
+
+// ListChannelsByOwner возвращает каналы владельца, на которые мы подписаны
+func (r *Repository) ListChannelsByOwner(ctx context.Context, ownerPubKey string) ([]*core.Channel, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+channelColumns+" FROM channels c WHERE c.owner_pub_key = ? AND c.is_owner = 0", ownerPubKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list owner channels: %w", err)
+	}
+	defer rows.Close()
+
+	var channels []*core.Channel
+	for rows.Next() {
+		c, err := r.scanChannel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan channel: %w", err)
+		}
+		channels = append(channels, c)
+	}
+	return channels, rows.Err()
+}
+
+// AddChannelPost сохраняет пост канала и продвигает номер последнего поста.
+// Возвращает false, если пост с таким номером уже есть или ID сообщения занят.
+func (r *Repository) AddChannelPost(ctx context.Context, post *core.ChannelPost, msg *core.Message) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM channel_posts WHERE channel_id = ? AND (seq = ? OR message_id = ?))",
+		post.ChannelID, int64(post.Seq), post.MessageID).Scan(&exists) // #nosec G115
+	if err != nil {
+		return false, fmt.Errorf("failed to check channel post: %w", err)
+	}
+	if exists {
+		return false, nil
+	}
+
+	if err := r.InsertMessage(ctx, msg); errors.Is(err, ErrMessageExists) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO channel_posts (channel_id, seq, message_id, signature) VALUES (?, ?, ?, ?)",
+		post.ChannelID, int64(post.Seq), post.MessageID, post.Signature) // #nosec G115
+	if err != nil {
+		return false, fmt.Errorf("failed to save channel post: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE channels SET last_seq = MAX(last_seq, ?) WHERE id = ?", int64(post.Seq), post.ChannelID) // #nosec G115
+	if err != nil {
+		return false, fmt.Errorf("failed to update channel seq: %w", err)
+	}
+	return true, tx.Commit()
+}
+
+// ListChannelPosts возвращает последние limit постов с номером больше afterSeq (по возрастанию номера)
+func (r *Repository) ListChannelPosts(ctx context.Context, channelID string, afterSeq uint64, limit int) ([]*core.ChannelPost, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT p.channel_id, p.seq, p.message_id, p.signature, m.content, m.timestamp
+		FROM channel_posts p
+		JOIN messages m ON m.id = p.message_id
+		WHERE p.channel_id = ? AND p.seq > ?
+		ORDER BY p.seq DESC
+		LIMIT ?`, channelID, int64(afterSeq), limit) // #nosec G115
+	if err != nil {
+		return nil, fmt.Errorf("failed to list channel posts: %w", err)
+	}
+	defer rows.Close()
+
+	var posts []*core.ChannelPost
+	for rows.Next() {
+		p := &core.ChannelPost{}
+		var seq int64
+		if err := rows.Scan(&p.ChannelID, &seq, &p.MessageID, &p.Signature, &p.Content, &p.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan channel post: %w", err)
+		}
+		p.Seq = uint64(seq) // #nosec G115
+		p.Content = r.decryptString(p.Content)
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(posts)-1; i < j; i, j = i+1, j-1 {
+		posts[i], posts[j] = posts[j], posts[i]
+	}
+	return posts, nil
+}
+
+// AddChannelSubscriber добавляет подписчика или обновляет его адрес
+func (r *Repository) AddChannelSubscriber(ctx context.Context, sub *core.ChannelSubscriber) error {
+	if sub.CreatedAt.IsZero() {
+		sub.CreatedAt = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO channel_subscribers (channel_id, public_key, i2p_address, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(channel_id, public_key) DO UPDATE SET i2p_address = excluded.i2p_address`,
+		sub.ChannelID, sub.PublicKey, r.encryptString(sub.I2PAddress), sub.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add channel subscriber: %w", err)
+	}
+	return nil
+}
+
+// RemoveChannelSubscriber удаляет подписчика
+func (r *Repository) RemoveChannelSubscriber(ctx context.Context, channelID, pubKey string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM channel_subscribers WHERE channel_id = ? AND public_key = ?", channelID, pubKey)
+	if err != nil {
+		return fmt.Errorf("failed to remove channel subscriber: %w", err)
+	}
+	return nil
+}
+
+// ListChannelSubscribers возвращает подписчиков канала
+func (r *Repository) ListChannelSubscribers(ctx context.Context, channelID string) ([]*core.ChannelSubscriber, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT channel_id, public_key, i2p_address, created_at FROM channel_subscribers WHERE channel_id = ? ORDER BY created_at",
+		channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list channel subscribers: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []*core.ChannelSubscriber
+	for rows.Next() {
+		sub := &core.ChannelSubscriber{}
+		if err := rows.Scan(&sub.ChannelID, &sub.PublicKey, &sub.I2PAddress, &sub.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan channel subscriber: %w", err)
+		}
+		sub.I2PAddress = r.decryptString(sub.I2PAddress)
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// IsChannelSubscriber сообщает, подписан ли ключ хотя бы на один наш канал
+func (r *Repository) IsChannelSubscriber(ctx context.Context, pubKey string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM channel_subscribers WHERE public_key = ?)", pubKey).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check channel subscriber: %w", err)
+	}
+	return exists, nil
+}
+
+// DeleteChannel удаляет канал вместе с постами и подписчиками
+func (r *Repository) DeleteChannel(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// channel_posts и channel_subscribers удаляются каскадом
+	if _, err := tx.ExecContext(ctx, "DELETE FROM messages WHERE chat_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete channel posts: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM channels WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete channel: %w", err)
+	}
+	return tx.Commit()
+}
+
+// SearchMessages ищет сообщения по тексту в чате
+func (r *Repository) SearchMessages(ctx context.Context, chatID, queryStr string) ([]*core.Message, error) {
+	query := `
+		SELECT id, chat_id, sender_id, content, content_type, status,
+		       is_outgoing, reply_to_id, timestamp, created_at, updated_at, file_count, total_size, edited_at
+		FROM messages
+		WHERE chat_id = ? AND content LIKE ?
+		ORDER BY timestamp DESC
+		LIMIT 50
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, chatID, "%"+queryStr+"%")
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*core.Message
+	for rows.Next() {
+		msg := &core.Message{}
+		err := rows.Scan(
+			&msg.ID, &msg.ChatID, &msg.SenderID, &msg.Content, &msg.ContentType, &msg.Status,
+			&msg.IsOutgoing, &msg.ReplyToID, &msg.Timestamp, &msg.CreatedAt, &msg.UpdatedAt,
+			&msg.FileCount, &msg.TotalSize, &msg.EditedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages, r.enrichMessagesWithAttachments(ctx, messages)
+}
+
+// === Chat Methods ===
+
+// GetChat возвращает чат по ID
+func (r *Repository) GetChat(ctx context.Context, id string) (*core.Chat, error) {
+	query := `SELECT id, contact_id, last_message_id, unread_count, is_muted, created_at, updated_at FROM chats WHERE id = ?`
+
+	chat := &core.Chat{}
+	var lastMsgID sql.NullString
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&chat.ID, &chat.ContactID, &lastMsgID, &chat.UnreadCount, &chat.IsMuted, &chat.CreatedAt, &chat.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("chat not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if lastMsgID.Valid {
+		chat.LastMessageID = &lastMsgID.String
+	}
+
+	return chat, nil
+}
+
+// === Folder Methods ===
+
+// CreateFolder создаёт новую папку
+func (r *Repository) CreateFolder(ctx context.Context, folder *core.Folder) error {
+	query := `INSERT INTO folders (id, name, icon, position) VALUES (?, ?, ?, ?) ON CONFLICT(id) DO UPDATE SET name=excluded.name, icon=excluded.icon, position=excluded.position`
+	_, err := r.db.ExecContext(ctx, query, folder.ID, folder.Name, folder.Icon, folder.Position)
+	return err
+}
+
+// GetFolders возвращает все папки
+func (r *Repository) GetFolders(ctx context.Context) ([]*core.Folder, error) {
+	query := `SELECT id, name, icon, position FROM folders ORDER BY position ASC`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var folders []*core.Folder
+	for rows.Next() {
+		f := &core.Folder{}
+		if err := rows.Scan(&f.ID, &f.Name, &f.Icon, &f.Position); err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+	return folders, nil
+}
+
+// DeleteFolder удаляет папку
+func (r *Repository) DeleteFolder(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM folders WHERE id = ?", id)
+	return err
 }
 
-// AddChatToFolder добавляет чат (контакт) в папку
+// AddChatToFolder добавляет чат (контакт или группу) в папку
 func (r *Repository) AddChatToFolder(ctx context.Context, folderID, contactID string) error {
-	_, err := r.db.ExecContext(ctx, "INSERT OR IGNORE INTO folder_chats (folder_id, contact_id) VALUES (?, ?)", folderID, contactID)
+	var isGroup bool
+	err := r.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM chat_groups WHERE id = ?)", contactID).Scan(&isGroup)
+	if err != nil {
+		return err
+	}
+	if isGroup {
+		_, err = r.db.ExecContext(ctx, "INSERT OR IGNORE INTO folder_groups (folder_id, group_id) VALUES (?, ?)", folderID, contactID)
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, "INSERT OR IGNORE INTO folder_chats (folder_id, contact_id) VALUES (?, ?)", folderID, contactID)
 	return err
 }
 
 // RemoveChatFromFolder удаляет чат из папки
 func (r *Repository) RemoveChatFromFolder(ctx context.Context, folderID, contactID string) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM folder_groups WHERE folder_id = ? AND group_id = ?", folderID, contactID); err != nil {
+		return err
+	}
 	_, err := r.db.ExecContext(ctx, "DELETE FROM folder_chats WHERE folder_id = ? AND contact_id = ?", folderID, contactID)
 	return err
 }
 
-// GetFolderChats возвращает список ID контактов в папке
+// GetFolderChats возвращает список ID контактов и групп в папке
 func (r *Repository) GetFolderChats(ctx context.Context, folderID string) ([]string, error) {
-	rows, err := r.db.QueryContext(ctx, "SELECT contact_id FROM folder_chats WHERE folder_id = ?", folderID)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT contact_id FROM folder_chats WHERE folder_id = ?
+		UNION ALL
+		SELECT group_id FROM folder_groups WHERE folder_id = ?`, folderID, folderID)
 	if err != nil {
 		return nil, err
 	}
@@ -1175,7 +2427,10 @@ func (r *Repository) UpdateChatID(ctx context.Context, oldID, newID string) erro
 
 // GetUnreadCount возвращает общее количество непрочитанных сообщений
 func (r *Repository) GetUnreadCount(ctx context.Context) (int, error) {
-	query := `SELECT COUNT(*) FROM messages WHERE is_outgoing = 0 AND is_read = 0`
+	// Сообщения непринятых запросов переписки и приглашений в группы в счётчик не входят
+	query := `SELECT COUNT(*) FROM messages WHERE is_outgoing = 0 AND is_read = 0
+		AND chat_id NOT IN (SELECT chat_id FROM contacts WHERE is_pending = 1)
+		AND chat_id NOT IN (SELECT id FROM chat_groups WHERE is_pending = 1)`
 
 	var count int
 	err := r.db.QueryRowContext(ctx, query).Scan(&count)
@@ -1209,6 +2464,47 @@ func (r *Repository) GetUnreadCountByChat(ctx context.Context) (map[string]int,
 	return result, rows.Err()
 }
 
+// ListUnreadMessageIDs возвращает ID непрочитанных входящих сообщений чата
+func (r *Repository) ListUnreadMessageIDs(ctx context.Context, chatID string) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id FROM messages WHERE chat_id = ? AND is_outgoing = 0 AND is_read = 0", chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unread messages: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetMetadata возвращает значение из db_metadata ("" если ключа нет)
+func (r *Repository) GetMetadata(ctx context.Context, key string) (string, error) {
+	var val string
+	err := r.db.QueryRowContext(ctx, "SELECT value FROM db_metadata WHERE key = ?", key).Scan(&val)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get metadata: %w", err)
+	}
+	return val, nil
+}
+
+// SetMetadata сохраняет значение в db_metadata
+func (r *Repository) SetMetadata(ctx context.Context, key, value string) error {
+	_, err := r.db.ExecContext(ctx, "INSERT OR REPLACE INTO db_metadata (key, value) VALUES (?, ?)", key, value)
+	if err != nil {
+		return fmt.Errorf("failed to set metadata: %w", err)
+	}
+	return nil
+}
+
 // MarkChatAsRead помечает все сообщения в чате как прочитанные
 func (r *Repository) MarkChatAsRead(ctx context.Context, chatID string) error {
 	_, err := r.db.ExecContext(ctx, "UPDATE messages SET is_read = 1 WHERE chat_id = ? AND is_read = 0", chatID)
@@ -1260,17 +2556,27 @@ func (r *Repository) UpdateContactAndMigrateChatID(ctx context.Context, contact
 	}
 
 	// 2. Migrate Messages if ChatID changed
-	if oldChatID != newChatID {
+	if oldChatID != "" && oldChatID != newChatID {
 		log.Printf("[Repo] Migrating messages from %s to %s inside tx...", oldChatID, newChatID)
-		_, err = tx.ExecContext(ctx, "UPDATE messages SET chat_id = ? WHERE chat_id = ?", newChatID, oldChatID)
-		if err != nil {
-			return fmt.Errorf("failed to update messages chat ID in tx: %w", err)
+		if err := migrateChatID(ctx, tx, oldChatID, newChatID); err != nil {
+			return fmt.Errorf("failed to update chat ID in tx: %w", err)
 		}
 	}
 
 	return tx.Commit()
 }
 
+// migrateChatID переносит в новый чат сообщения и всё, что ждёт их отправки
+func migrateChatID(ctx context.Context, tx *sql.Tx, oldChatID, newChatID string) error {
+	for _, table := range []string{"messages", "outbox", "message_changes", "file_transfers"} {
+		// #nosec G202 -- имя таблицы из фиксированного списка
+		if _, err := tx.ExecContext(ctx, "UPDATE "+table+" SET chat_id = ? WHERE chat_id = ?", newChatID, oldChatID); err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+	}
+	return nil
+}
+
 // MigrateChatID updates the chat_id for all messages and contact
 func (r *Repository) MigrateChatID(ctx context.Context, oldChatID, newChatID string) error {
 	tx, err := r.db.BeginTx(ctx, nil)
@@ -1279,11 +2585,82 @@ func (r *Repository) MigrateChatID(ctx context.Context, oldChatID, newChatID str
 	}
 	defer tx.Rollback()
 
-	// Update messages
-	_, err = tx.ExecContext(ctx, "UPDATE messages SET chat_id = ? WHERE chat_id = ?", newChatID, oldChatID)
-	if err != nil {
-		return fmt.Errorf("failed to update messages chat ID: %w", err)
+	if oldChatID == "" || oldChatID == newChatID {
+		return nil
+	}
+	if err := migrateChatID(ctx, tx, oldChatID, newChatID); err != nil {
+		return fmt.Errorf("failed to update chat ID: %w", err)
 	}
 
 	return tx.Commit()
 }
+
+// === Ratchet State Methods ===
+
+// SaveRatchetStates сохраняет состояние Double Ratchet контакта (заменяет предыдущее)
+func (r *Repository) SaveRatchetStates(ctx context.Context, peerPubKey string, states []*ratchet.State) error {
+	data, err := json.Marshal(states)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ratchet state: %w", err)
+	}
+
+	state, err := r.keys.Encrypt(data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt ratchet state: %w", err)
+	}
+
+	query := `
+		INSERT INTO ratchet_states (peer_pub_key, state, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(peer_pub_key) DO UPDATE SET
+			state = excluded.state,
+			updated_at = excluded.updated_at
+	`
+	_, err = r.db.ExecContext(ctx, query, peerPubKey, state, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save ratchet state: %w", err)
+	}
+	return nil
+}
+
+// ListRatchetStates возвращает сохранённые состояния Double Ratchet всех контактов
+func (r *Repository) ListRatchetStates(ctx context.Context) (map[string][]*ratchet.State, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT peer_pub_key, state FROM ratchet_states")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ratchet states: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]*ratchet.State)
+	for rows.Next() {
+		var peer string
+		var state []byte
+		if err := rows.Scan(&peer, &state); err != nil {
+			return nil, err
+		}
+
+		data, err := r.keys.Decrypt(state)
+		if err != nil {
+			log.Printf("[Repo] Failed to decrypt ratchet state for %s: %v", peer, err)
+			continue
+		}
+
+		var states []*ratchet.State
+		if err := json.Unmarshal(data, &states); err != nil {
+			log.Printf("[Repo] Failed to unmarshal ratchet state for %s: %v", peer, err)
+			continue
+		}
+		result[peer] = states
+	}
+
+	return result, rows.Err()
+}
+
+// DeleteRatchetStates удаляет состояние Double Ratchet контакта
+func (r *Repository) DeleteRatchetStates(ctx context.Context, peerPubKey string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM ratchet_states WHERE peer_pub_key = ?", peerPubKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete ratchet state: %w", err)
+	}
+	return nil
+}
@@ -3,13 +3,19 @@ package sqlite
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"teleghost/internal/core"
 	"teleghost/internal/core/identity"
+	"teleghost/internal/core/ratchet"
 
+	"github.com/go-i2p/i2pkeys"
 	"github.com/google/uuid"
 )
 
@@ -177,6 +183,41 @@ func TestRepository_Contacts(t *testing.T) {
 	t.Log("Contact tests passed")
 }
 
+func TestRepository_ContactByB32Address(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	raw := make([]byte, 387)
+	raw[0] = 1
+	dest := base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-~").EncodeToString(raw)
+	b32 := i2pkeys.I2PAddr(dest).Base32()
+
+	// Контакт добавлен вручную по b32, ключа ещё нет
+	contact := &core.Contact{
+		ID:         uuid.New().String(),
+		Nickname:   "Bob",
+		I2PAddress: strings.ToUpper(b32),
+	}
+	if err := repo.SaveContact(ctx, contact); err != nil {
+		t.Fatalf("SaveContact failed: %v", err)
+	}
+
+	// Пакет пришёл с полного адреса
+	found, err := repo.GetContactByAddress(ctx, dest)
+	if err != nil {
+		t.Fatalf("GetContactByAddress failed: %v", err)
+	}
+	if found == nil || found.ID != contact.ID {
+		t.Fatal("Contact added by b32 not found by full destination")
+	}
+
+	if other, _ := repo.GetContactByAddress(ctx, "unknown.b32.i2p"); other != nil {
+		t.Error("Unexpected contact for unknown address")
+	}
+}
+
 func TestRepository_Messages(t *testing.T) {
 	repo, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -245,3 +286,1002 @@ func TestRepository_Messages(t *testing.T) {
 
 	t.Log("Message tests passed")
 }
+
+func TestRepository_RatchetStates(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	states := []*ratchet.State{
+		{
+			SessionID: []byte("0123456789abcdef"),
+			RK:        make([]byte, 32),
+			Ns:        3,
+			Skipped:   map[string][]byte{"aa:1": make([]byte, 32)},
+			CreatedAt: time.Now(),
+		},
+	}
+
+	if err := repo.SaveRatchetStates(ctx, "peer-public-key", states); err != nil {
+		t.Fatalf("SaveRatchetStates failed: %v", err)
+	}
+
+	loaded, err := repo.ListRatchetStates(ctx)
+	if err != nil {
+		t.Fatalf("ListRatchetStates failed: %v", err)
+	}
+	if len(loaded["peer-public-key"]) != 1 {
+		t.Fatalf("Expected 1 state, got %d", len(loaded["peer-public-key"]))
+	}
+	got := loaded["peer-public-key"][0]
+	if string(got.SessionID) != "0123456789abcdef" || got.Ns != 3 || len(got.Skipped) != 1 {
+		t.Errorf("Ratchet state not restored: %+v", got)
+	}
+
+	// Удаляем состояние
+	if err := repo.DeleteRatchetStates(ctx, "peer-public-key"); err != nil {
+		t.Fatalf("DeleteRatchetStates failed: %v", err)
+	}
+	loaded, _ = repo.ListRatchetStates(ctx)
+	if len(loaded) != 0 {
+		t.Errorf("Expected no states after delete, got %d", len(loaded))
+	}
+}
+
+func TestRepository_ReadReceipts(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	contact := &core.Contact{
+		ID:         uuid.New().String(),
+		PublicKey:  "carol-public-key",
+		Nickname:   "Carol",
+		I2PAddress: "carol.i2p.address.base64",
+		ChatID:     "chat-with-carol",
+	}
+	if err := repo.SaveContact(ctx, contact); err != nil {
+		t.Fatalf("SaveContact failed: %v", err)
+	}
+
+	// По умолчанию отчёты о прочтении включены
+	saved, _ := repo.GetContact(ctx, contact.ID)
+	if saved.ReadReceiptsDisabled {
+		t.Error("Expected read receipts to be enabled by default")
+	}
+
+	if err := repo.SetContactReadReceipts(ctx, contact.ID, false); err != nil {
+		t.Fatalf("SetContactReadReceipts failed: %v", err)
+	}
+	saved, _ = repo.GetContact(ctx, contact.ID)
+	if !saved.ReadReceiptsDisabled {
+		t.Error("Expected read receipts to be disabled")
+	}
+
+	// Непрочитанные входящие сообщения
+	for i := 0; i < 3; i++ {
+		msg := &core.Message{
+			ID:          uuid.New().String(),
+			ChatID:      contact.ChatID,
+			SenderID:    contact.PublicKey,
+			Content:     "Unread",
+			ContentType: "text",
+			IsOutgoing:  i == 2,
+			Timestamp:   time.Now().UnixMilli(),
+		}
+		if err := repo.SaveMessage(ctx, msg); err != nil {
+			t.Fatalf("SaveMessage failed: %v", err)
+		}
+	}
+
+	ids, err := repo.ListUnreadMessageIDs(ctx, contact.ChatID)
+	if err != nil {
+		t.Fatalf("ListUnreadMessageIDs failed: %v", err)
+	}
+	if len(ids) != 2 {
+		t.Errorf("Expected 2 unread incoming messages, got %d", len(ids))
+	}
+
+	if err := repo.MarkChatAsRead(ctx, contact.ChatID); err != nil {
+		t.Fatalf("MarkChatAsRead failed: %v", err)
+	}
+	ids, _ = repo.ListUnreadMessageIDs(ctx, contact.ChatID)
+	if len(ids) != 0 {
+		t.Errorf("Expected no unread messages after MarkChatAsRead, got %d", len(ids))
+	}
+
+	// Метаданные (глобальные настройки)
+	if err := repo.SetMetadata(ctx, "privacy_settings", `{"readReceipts":false}`); err != nil {
+		t.Fatalf("SetMetadata failed: %v", err)
+	}
+	val, err := repo.GetMetadata(ctx, "privacy_settings")
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if val != `{"readReceipts":false}` {
+		t.Errorf("Unexpected metadata value: %s", val)
+	}
+}
+
+func TestRepository_MessageEdits(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	msg := &core.Message{
+		ID:          uuid.New().String(),
+		ChatID:      "chat-1",
+		SenderID:    "sender",
+		Content:     "Первая версия",
+		ContentType: "text",
+		Timestamp:   500,
+	}
+	if err := repo.SaveMessage(ctx, msg); err != nil {
+		t.Fatalf("SaveMessage failed: %v", err)
+	}
+
+	if err := repo.UpdateMessageContent(ctx, msg.ID, "Вторая версия", 1000); err != nil {
+		t.Fatalf("UpdateMessageContent failed: %v", err)
+	}
+	if err := repo.UpdateMessageContent(ctx, msg.ID, "Третья версия", 2000); err != nil {
+		t.Fatalf("UpdateMessageContent failed: %v", err)
+	}
+
+	saved, err := repo.GetMessage(ctx, msg.ID)
+	if err != nil {
+		t.Fatalf("GetMessage failed: %v", err)
+	}
+	if saved.Content != "Третья версия" || saved.EditedAt != 2000 {
+		t.Errorf("Unexpected message after edit: %q at %d", saved.Content, saved.EditedAt)
+	}
+
+	// Новое содержимое хранится зашифрованным
+	var raw string
+	if err := repo.db.QueryRowContext(ctx, "SELECT content FROM messages WHERE id = ?", msg.ID).Scan(&raw); err != nil {
+		t.Fatalf("Raw select failed: %v", err)
+	}
+	if raw == "Третья версия" {
+		t.Error("Edited content stored in plaintext")
+	}
+
+	edits, err := repo.GetMessageEdits(ctx, msg.ID)
+	if err != nil {
+		t.Fatalf("GetMessageEdits failed: %v", err)
+	}
+	if len(edits) != 2 {
+		t.Fatalf("Expected 2 edits, got %d", len(edits))
+	}
+	// У каждой версии — время, когда она появилась
+	if edits[0].Content != "Первая версия" || edits[0].EditedAt != 500 {
+		t.Errorf("Unexpected first edit: %+v", edits[0])
+	}
+	if edits[1].Content != "Вторая версия" || edits[1].EditedAt != 1000 {
+		t.Errorf("Unexpected second edit: %+v", edits[1])
+	}
+
+	if err := repo.UpdateMessageContent(ctx, "missing", "x", 3000); err == nil {
+		t.Error("Expected error for missing message")
+	}
+
+	// История удаляется вместе с сообщением
+	if err := repo.DeleteMessage(ctx, msg.ID); err != nil {
+		t.Fatalf("DeleteMessage failed: %v", err)
+	}
+	edits, _ = repo.GetMessageEdits(ctx, msg.ID)
+	if len(edits) != 0 {
+		t.Errorf("Expected edits to be deleted with message, got %d", len(edits))
+	}
+}
+
+func TestRepository_InsertMessage(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	msg := &core.Message{ID: uuid.New().String(), ChatID: "chat-1", SenderID: "alice", Content: "original", ContentType: "text"}
+	if err := repo.InsertMessage(ctx, msg); err != nil {
+		t.Fatalf("InsertMessage failed: %v", err)
+	}
+
+	// Чужое сообщение с тем же ID не перезаписывает сохранённое
+	forged := &core.Message{ID: msg.ID, ChatID: "chat-2", SenderID: "mallory", Content: "forged", ContentType: "text"}
+	if err := repo.InsertMessage(ctx, forged); !errors.Is(err, ErrMessageExists) {
+		t.Fatalf("Expected ErrMessageExists, got %v", err)
+	}
+	saved, err := repo.GetMessage(ctx, msg.ID)
+	if err != nil || saved.Content != "original" || saved.ChatID != "chat-1" {
+		t.Errorf("Message overwritten: %+v (%v)", saved, err)
+	}
+}
+
+func TestRepository_MessageChanges(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	edit := &core.MessageChange{MessageID: "m1", ChatID: "chat-1", Kind: core.MessageChangeEdit, Content: "v2", EditedAt: 1000, ExpiresAt: 5000}
+	if err := repo.EnqueueMessageChange(ctx, edit); err != nil {
+		t.Fatalf("EnqueueMessageChange failed: %v", err)
+	}
+	if err := repo.RecordMessageChangeAttempt(ctx, "m1", "offline"); err != nil {
+		t.Fatalf("RecordMessageChangeAttempt failed: %v", err)
+	}
+
+	// Новая правка заменяет прежнюю и сбрасывает попытки
+	newer := &core.MessageChange{MessageID: "m1", ChatID: "chat-1", Kind: core.MessageChangeEdit, Content: "v3", EditedAt: 2000, ExpiresAt: 6000}
+	if err := repo.EnqueueMessageChange(ctx, newer); err != nil {
+		t.Fatalf("EnqueueMessageChange failed: %v", err)
+	}
+	changes, err := repo.ListMessageChanges(ctx)
+	if err != nil || len(changes) != 1 {
+		t.Fatalf("Expected 1 change, got %d (%v)", len(changes), err)
+	}
+	if c := changes[0]; c.Content != "v3" || c.EditedAt != 2000 || c.Attempts != 0 {
+		t.Errorf("Unexpected change: %+v", c)
+	}
+
+	// Содержимое правки хранится зашифрованным
+	var raw string
+	if err := repo.db.QueryRowContext(ctx, "SELECT content FROM message_changes WHERE message_id = 'm1'").Scan(&raw); err != nil {
+		t.Fatalf("Raw select failed: %v", err)
+	}
+	if raw == "v3" {
+		t.Error("Change content stored in plaintext")
+	}
+
+	// Отправленная старая правка не убирает из очереди новую
+	if err := repo.DeleteMessageChange(ctx, edit); err != nil {
+		t.Fatalf("DeleteMessageChange failed: %v", err)
+	}
+	if changes, _ = repo.ListMessageChanges(ctx); len(changes) != 1 {
+		t.Fatalf("Newer change removed by stale delete")
+	}
+	if err := repo.DeleteMessageChange(ctx, changes[0]); err != nil {
+		t.Fatalf("DeleteMessageChange failed: %v", err)
+	}
+	if changes, _ = repo.ListMessageChanges(ctx); len(changes) != 0 {
+		t.Errorf("Expected empty queue, got %d", len(changes))
+	}
+}
+
+func TestRepository_Outbox(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	// Два сообщения в одном чате; второе создано раньше по timestamp
+	ids := []string{uuid.New().String(), uuid.New().String()}
+	for i, id := range ids {
+		msg := &core.Message{
+			ID:          id,
+			ChatID:      "chat-1",
+			SenderID:    "me",
+			Content:     "Queued",
+			ContentType: "text",
+			Status:      core.MessageStatusPending,
+			IsOutgoing:  true,
+			Timestamp:   int64(2000 - i*1000),
+		}
+		if err := repo.SaveMessage(ctx, msg); err != nil {
+			t.Fatalf("SaveMessage failed: %v", err)
+		}
+		entry := &core.OutboxEntry{MessageID: id, ChatID: "chat-1", ExpiresAt: 5000}
+		if err := repo.EnqueueOutbox(ctx, entry); err != nil {
+			t.Fatalf("EnqueueOutbox failed: %v", err)
+		}
+	}
+
+	entries, err := repo.ListOutbox(ctx)
+	if err != nil {
+		t.Fatalf("ListOutbox failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	// Очередь упорядочена по времени сообщения
+	if entries[0].MessageID != ids[1] {
+		t.Error("Expected older message first")
+	}
+
+	if err := repo.RecordOutboxAttempt(ctx, ids[1], "peer unreachable"); err != nil {
+		t.Fatalf("RecordOutboxAttempt failed: %v", err)
+	}
+	entries, _ = repo.ListOutbox(ctx)
+	if entries[0].Attempts != 1 || entries[0].LastError != "peer unreachable" || entries[0].LastAttemptAt == 0 {
+		t.Errorf("Attempt not recorded: %+v", entries[0])
+	}
+
+	// Повторная постановка (ручной повтор) сбрасывает попытки
+	if err := repo.EnqueueOutbox(ctx, &core.OutboxEntry{MessageID: ids[1], ChatID: "chat-1", ExpiresAt: 9000}); err != nil {
+		t.Fatalf("EnqueueOutbox failed: %v", err)
+	}
+	entries, _ = repo.ListOutbox(ctx)
+	if entries[0].Attempts != 0 || entries[0].ExpiresAt != 9000 {
+		t.Errorf("Re-enqueue did not reset entry: %+v", entries[0])
+	}
+
+	if err := repo.DeleteOutbox(ctx, ids[1]); err != nil {
+		t.Fatalf("DeleteOutbox failed: %v", err)
+	}
+
+	// Удаление сообщения убирает его из очереди
+	if err := repo.DeleteMessage(ctx, ids[0]); err != nil {
+		t.Fatalf("DeleteMessage failed: %v", err)
+	}
+	entries, _ = repo.ListOutbox(ctx)
+	if len(entries) != 0 {
+		t.Errorf("Expected empty outbox, got %d", len(entries))
+	}
+}
+
+func TestRepository_FileTransfers(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	transfer := &core.FileTransfer{
+		MessageID: "msg-1",
+		FileID:    "file-1",
+		Direction: core.TransferIncoming,
+		ChatID:    "chat-1",
+		Filename:  "report.pdf",
+		MimeType:  "application/pdf",
+		Size:      150 * 1024,
+		SHA256:    make([]byte, 32),
+		ChunkSize: 64 * 1024,
+		Status:    core.TransferAccepted,
+	}
+	if err := repo.SaveFileTransfer(ctx, transfer); err != nil {
+		t.Fatalf("SaveFileTransfer failed: %v", err)
+	}
+
+	if transfer.ChunkCount() != 3 || transfer.ChunkLen(2) != 22*1024 {
+		t.Errorf("Unexpected chunking: count=%d last=%d", transfer.ChunkCount(), transfer.ChunkLen(2))
+	}
+
+	if err := repo.UpdateFileTransferProgress(ctx, "msg-1", "file-1", core.TransferIncoming, 2); err != nil {
+		t.Fatalf("UpdateFileTransferProgress failed: %v", err)
+	}
+
+	// Состояние переживает перезапуск: читаем его заново из БД
+	active, err := repo.ListActiveFileTransfers(ctx)
+	if err != nil {
+		t.Fatalf("ListActiveFileTransfers failed: %v", err)
+	}
+	if len(active) != 1 {
+		t.Fatalf("Expected 1 active transfer, got %d", len(active))
+	}
+	got := active[0]
+	if got.NextIndex != 2 || got.Filename != "report.pdf" || got.Direction != core.TransferIncoming || len(got.SHA256) != 32 {
+		t.Errorf("Transfer not restored: %+v", got)
+	}
+
+	// Исходящие с тем же ID хранятся отдельно
+	list, err := repo.ListFileTransfers(ctx, "msg-1", core.TransferOutgoing)
+	if err != nil {
+		t.Fatalf("ListFileTransfers failed: %v", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("Expected no outgoing transfers, got %d", len(list))
+	}
+
+	got.Status = core.TransferComplete
+	got.LocalPath = "/tmp/report.pdf"
+	if err := repo.SaveFileTransfer(ctx, got); err != nil {
+		t.Fatalf("SaveFileTransfer failed: %v", err)
+	}
+	active, _ = repo.ListActiveFileTransfers(ctx)
+	if len(active) != 0 {
+		t.Errorf("Completed transfer is still active")
+	}
+
+	if err := repo.DeleteFileTransfers(ctx, "msg-1", core.TransferIncoming); err != nil {
+		t.Fatalf("DeleteFileTransfers failed: %v", err)
+	}
+	list, _ = repo.ListFileTransfers(ctx, "msg-1", core.TransferIncoming)
+	if len(list) != 0 {
+		t.Errorf("Expected transfers to be deleted, got %d", len(list))
+	}
+}
+
+func TestRepository_ReplayNonces(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	nonce := []byte("0123456789abcdef")
+
+	fresh, err := repo.RememberNonce(ctx, "sender-1", nonce, 1000)
+	if err != nil {
+		t.Fatalf("RememberNonce failed: %v", err)
+	}
+	if !fresh {
+		t.Error("Expected first nonce to be fresh")
+	}
+
+	// Повтор от того же отправителя отклоняется, от другого — нет
+	fresh, _ = repo.RememberNonce(ctx, "sender-1", nonce, 1000)
+	if fresh {
+		t.Error("Expected repeated nonce to be rejected")
+	}
+	fresh, _ = repo.RememberNonce(ctx, "sender-2", nonce, 1000)
+	if !fresh {
+		t.Error("Expected nonce from another sender to be fresh")
+	}
+
+	if err := repo.PruneNonces(ctx, 2000); err != nil {
+		t.Fatalf("PruneNonces failed: %v", err)
+	}
+	fresh, _ = repo.RememberNonce(ctx, "sender-1", nonce, 3000)
+	if !fresh {
+		t.Error("Expected pruned nonce to be forgotten")
+	}
+}
+
+func TestRepository_PacketVersions(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	contact := &core.Contact{
+		ID:         uuid.New().String(),
+		PublicKey:  "alice-key",
+		Nickname:   "alice",
+		I2PAddress: "alice.b32.i2p",
+		ChatID:     "chat-alice",
+	}
+	if err := repo.SaveContact(ctx, contact); err != nil {
+		t.Fatalf("SaveContact failed: %v", err)
+	}
+
+	if ok, err := repo.SavePacketVersion(ctx, "alice-key", 3); err != nil || !ok {
+		t.Fatalf("SavePacketVersion failed: %v (%v)", ok, err)
+	}
+	// Версия только растёт
+	if ok, _ := repo.SavePacketVersion(ctx, "alice-key", 2); !ok {
+		t.Error("Expected contact to be found")
+	}
+	if ok, _ := repo.SavePacketVersion(ctx, "stranger-key", 3); ok {
+		t.Error("Expected stranger version not to be saved")
+	}
+
+	versions, err := repo.PacketVersions(ctx)
+	if err != nil {
+		t.Fatalf("PacketVersions failed: %v", err)
+	}
+	if len(versions) != 1 || versions["alice-key"] != 3 {
+		t.Errorf("Unexpected versions %v", versions)
+	}
+}
+
+func TestRepository_ContactProtocol(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	contact := &core.Contact{
+		ID:         uuid.New().String(),
+		PublicKey:  "pubkey-1",
+		Nickname:   "Alice",
+		I2PAddress: "alice.b32.i2p",
+		ChatID:     "chat-1",
+	}
+	if err := repo.SaveContact(ctx, contact); err != nil {
+		t.Fatalf("SaveContact failed: %v", err)
+	}
+
+	if err := repo.UpdateContactProtocol(ctx, "pubkey-1", 3, 0b1011); err != nil {
+		t.Fatalf("UpdateContactProtocol failed: %v", err)
+	}
+
+	got, err := repo.GetContactByPublicKey(ctx, "pubkey-1")
+	if err != nil {
+		t.Fatalf("GetContactByPublicKey failed: %v", err)
+	}
+	if got.ProtocolVersion != 3 || got.Capabilities != 0b1011 {
+		t.Errorf("Protocol not stored: version=%d caps=%b", got.ProtocolVersion, got.Capabilities)
+	}
+
+	// Сохранение контакта не затирает согласованную версию
+	got.Nickname = "Alice 2"
+	if err := repo.SaveContact(ctx, got); err != nil {
+		t.Fatalf("SaveContact failed: %v", err)
+	}
+	got, _ = repo.GetContactByPublicKey(ctx, "pubkey-1")
+	if got.ProtocolVersion != 3 {
+		t.Errorf("Protocol version lost on save: %d", got.ProtocolVersion)
+	}
+}
+
+func TestRepository_ContactPresence(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	contact := &core.Contact{
+		ID:         uuid.New().String(),
+		PublicKey:  "pubkey-1",
+		Nickname:   "Alice",
+		I2PAddress: "alice.b32.i2p",
+		ChatID:     "chat-1",
+	}
+	if err := repo.SaveContact(ctx, contact); err != nil {
+		t.Fatalf("SaveContact failed: %v", err)
+	}
+
+	seen := time.Now().Truncate(time.Second)
+	if err := repo.UpdateContactLastSeen(ctx, "pubkey-1", seen); err != nil {
+		t.Fatalf("UpdateContactLastSeen failed: %v", err)
+	}
+	if err := repo.SetContactHidePresence(ctx, contact.ID, true); err != nil {
+		t.Fatalf("SetContactHidePresence failed: %v", err)
+	}
+	if err := repo.SetContactPeerHidesPresence(ctx, "pubkey-1", true); err != nil {
+		t.Fatalf("SetContactPeerHidesPresence failed: %v", err)
+	}
+
+	got, err := repo.GetContactByPublicKey(ctx, "pubkey-1")
+	if err != nil {
+		t.Fatalf("GetContactByPublicKey failed: %v", err)
+	}
+	if got.LastSeen == nil || !got.LastSeen.Equal(seen) {
+		t.Errorf("LastSeen not stored: %v", got.LastSeen)
+	}
+	if !got.HidePresence || !got.PeerHidesPresence {
+		t.Errorf("Presence settings not stored: hide=%v peerHides=%v", got.HidePresence, got.PeerHidesPresence)
+	}
+
+	// Собеседник снова показывает статус
+	if err := repo.SetContactPeerHidesPresence(ctx, "pubkey-1", false); err != nil {
+		t.Fatalf("SetContactPeerHidesPresence failed: %v", err)
+	}
+	got, _ = repo.GetContactByPublicKey(ctx, "pubkey-1")
+	if got.PeerHidesPresence {
+		t.Error("PeerHidesPresence should be cleared")
+	}
+}
+
+func TestRepository_ContactMailbox(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	contact := &core.Contact{
+		ID:         uuid.New().String(),
+		PublicKey:  "pubkey-1",
+		Nickname:   "Alice",
+		I2PAddress: "alice.b32.i2p",
+		ChatID:     "chat-1",
+	}
+	if err := repo.SaveContact(ctx, contact); err != nil {
+		t.Fatalf("SaveContact failed: %v", err)
+	}
+	if err := repo.SetContactMailbox(ctx, "pubkey-1", "mailbox.b32.i2p"); err != nil {
+		t.Fatalf("SetContactMailbox failed: %v", err)
+	}
+
+	got, err := repo.GetContactByPublicKey(ctx, "pubkey-1")
+	if err != nil {
+		t.Fatalf("GetContactByPublicKey failed: %v", err)
+	}
+	if got.MailboxAddress != "mailbox.b32.i2p" {
+		t.Errorf("Mailbox not stored: %q", got.MailboxAddress)
+	}
+
+	// Обновление профиля не затирает почтовый сервер
+	got.Nickname = "Alice Updated"
+	if err := repo.SaveContact(ctx, got); err != nil {
+		t.Fatalf("SaveContact failed: %v", err)
+	}
+	got, _ = repo.GetContactByPublicKey(ctx, "pubkey-1")
+	if got.MailboxAddress != "mailbox.b32.i2p" {
+		t.Errorf("Mailbox lost after SaveContact: %q", got.MailboxAddress)
+	}
+}
+
+func TestRepository_ContactLeaseSet(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	contact := &core.Contact{
+		ID:         uuid.New().String(),
+		PublicKey:  "pubkey-1",
+		Nickname:   "Alice",
+		I2PAddress: "alice-destination",
+		ChatID:     "chat-1",
+	}
+	if err := repo.SaveContact(ctx, contact); err != nil {
+		t.Fatalf("SaveContact failed: %v", err)
+	}
+	if err := repo.SetContactLeaseSetKey(ctx, "pubkey-1", "auth-key"); err != nil {
+		t.Fatalf("SetContactLeaseSetKey failed: %v", err)
+	}
+	if err := repo.SetContactBlindedAddress(ctx, "pubkey-1", "alice.b33.b32.i2p"); err != nil {
+		t.Fatalf("SetContactBlindedAddress failed: %v", err)
+	}
+	if err := repo.SetContactIntroKey(ctx, contact.ID, "intro-key"); err != nil {
+		t.Fatalf("SetContactIntroKey failed: %v", err)
+	}
+
+	got, err := repo.GetContactByPublicKey(ctx, "pubkey-1")
+	if err != nil {
+		t.Fatalf("GetContactByPublicKey failed: %v", err)
+	}
+	if got.LeaseSetAuthKey != "auth-key" || got.BlindedAddress != "alice.b33.b32.i2p" {
+		t.Errorf("LeaseSet data not stored: %q, %q", got.LeaseSetAuthKey, got.BlindedAddress)
+	}
+
+	// Обновление профиля их не затирает, пустой адрес — leaseSet снова открытый
+	got.Nickname = "Alice Updated"
+	if err := repo.SaveContact(ctx, got); err != nil {
+		t.Fatalf("SaveContact failed: %v", err)
+	}
+	_ = repo.SetContactBlindedAddress(ctx, "pubkey-1", "")
+	got, _ = repo.GetContactByPublicKey(ctx, "pubkey-1")
+	if got.LeaseSetAuthKey != "auth-key" || got.BlindedAddress != "" || got.IntroKey != "intro-key" {
+		t.Errorf("Unexpected leaseSet data: %q, %q, %q", got.LeaseSetAuthKey, got.BlindedAddress, got.IntroKey)
+	}
+}
+
+func TestRepository_ContactRequests(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	friend := &core.Contact{ID: uuid.New().String(), PublicKey: "pubkey-1", Nickname: "Alice", I2PAddress: "alice.b32.i2p", ChatID: "chat-1"}
+	stranger := &core.Contact{ID: uuid.New().String(), PublicKey: "pubkey-2", Nickname: "Mallory", I2PAddress: "mallory.b32.i2p", ChatID: "chat-2", IsPending: true}
+	for _, c := range []*core.Contact{friend, stranger} {
+		if err := repo.SaveContact(ctx, c); err != nil {
+			t.Fatalf("SaveContact failed: %v", err)
+		}
+		msg := &core.Message{
+			ID:          uuid.New().String(),
+			ChatID:      c.ChatID,
+			SenderID:    c.PublicKey,
+			Content:     "hi from " + c.Nickname,
+			ContentType: "text",
+			Status:      core.MessageStatusDelivered,
+			Timestamp:   time.Now().UnixMilli(),
+		}
+		if err := repo.SaveMessage(ctx, msg); err != nil {
+			t.Fatalf("SaveMessage failed: %v", err)
+		}
+	}
+
+	// Запрос не попадает в список чатов и в счётчик непрочитанных
+	contacts, err := repo.ListContactsWithLastMessage(ctx)
+	if err != nil || len(contacts) != 1 || contacts[0].ID != friend.ID {
+		t.Fatalf("Unexpected contacts: %v (%v)", contacts, err)
+	}
+	requests, err := repo.ListContactRequests(ctx)
+	if err != nil || len(requests) != 1 || requests[0].ID != stranger.ID || !requests[0].IsPending {
+		t.Fatalf("Unexpected requests: %v (%v)", requests, err)
+	}
+	if requests[0].LastMessage != "hi from Mallory" {
+		t.Errorf("Request preview not loaded: %q", requests[0].LastMessage)
+	}
+	if count, _ := repo.GetUnreadCount(ctx); count != 1 {
+		t.Errorf("Expected 1 unread message, got %d", count)
+	}
+
+	// Принятый запрос становится обычным чатом
+	if err := repo.SetContactPending(ctx, stranger.ID, false); err != nil {
+		t.Fatalf("SetContactPending failed: %v", err)
+	}
+	if contacts, _ := repo.ListContactsWithLastMessage(ctx); len(contacts) != 2 {
+		t.Errorf("Accepted request not in contacts: %d", len(contacts))
+	}
+	if count, _ := repo.GetUnreadCount(ctx); count != 2 {
+		t.Errorf("Expected 2 unread messages, got %d", count)
+	}
+
+	// Блокировка снимает пометку запроса
+	if err := repo.SetContactPending(ctx, stranger.ID, true); err != nil {
+		t.Fatalf("SetContactPending failed: %v", err)
+	}
+	if err := repo.BlockContact(ctx, stranger.ID, true); err != nil {
+		t.Fatalf("BlockContact failed: %v", err)
+	}
+	got, _ := repo.GetContact(ctx, stranger.ID)
+	if !got.IsBlocked || got.IsPending {
+		t.Errorf("Unexpected state after block: blocked=%v pending=%v", got.IsBlocked, got.IsPending)
+	}
+
+	if err := repo.DeleteChatMessages(ctx, stranger.ChatID); err != nil {
+		t.Fatalf("DeleteChatMessages failed: %v", err)
+	}
+	if history, _ := repo.GetChatHistory(ctx, stranger.ChatID, 10, 0); len(history) != 0 {
+		t.Errorf("Quarantined messages not deleted: %d", len(history))
+	}
+}
+
+func TestRepository_Groups(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	group := &core.Group{
+		ID:           uuid.New().String(),
+		Name:         "Команда",
+		Epoch:        1,
+		SignerPubKey: "alice",
+		Signature:    []byte("sig"),
+		IsMember:     true,
+		Members: []*core.GroupMember{
+			{PublicKey: "alice", I2PAddress: "alice.b32.i2p", Nickname: "Alice", Role: core.GroupRoleAdmin},
+			{PublicKey: "bob", I2PAddress: "bob.b32.i2p", Nickname: "Bob"},
+			{PublicKey: "carol", I2PAddress: "carol.b32.i2p", Nickname: "Carol"},
+		},
+	}
+	if err := repo.SaveGroup(ctx, group); err != nil {
+		t.Fatalf("SaveGroup failed: %v", err)
+	}
+
+	got, err := repo.GetGroup(ctx, group.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetGroup failed: %v", err)
+	}
+	if got.Name != "Команда" || got.Epoch != 1 || len(got.Members) != 3 || !got.IsAdmin("alice") || got.IsAdmin("bob") {
+		t.Errorf("Unexpected group: %+v", got)
+	}
+	if m := got.Member("bob"); m == nil || m.I2PAddress != "bob.b32.i2p" || m.Nickname != "Bob" {
+		t.Errorf("Member not decrypted: %+v", m)
+	}
+
+	// Новый состав заменяет старый
+	got.Epoch = 2
+	got.Members = got.Members[:2]
+	if err := repo.SaveGroup(ctx, got); err != nil {
+		t.Fatalf("SaveGroup failed: %v", err)
+	}
+	got, _ = repo.GetGroup(ctx, group.ID)
+	if got.Epoch != 2 || len(got.Members) != 2 || got.Member("carol") != nil {
+		t.Errorf("Members not replaced: epoch=%d members=%d", got.Epoch, len(got.Members))
+	}
+
+	ids, err := repo.ListGroupIDsByMember(ctx, "bob")
+	if err != nil || len(ids) != 1 || ids[0] != group.ID {
+		t.Errorf("ListGroupIDsByMember = %v, %v", ids, err)
+	}
+
+	// Непринятое приглашение не попадает в список групп и не связывает с участниками
+	invite := &core.Group{
+		ID:           uuid.New().String(),
+		Name:         "Приглашение",
+		SignerPubKey: "dave",
+		IsMember:     true,
+		IsPending:    true,
+		Members: []*core.GroupMember{
+			{PublicKey: "dave", I2PAddress: "dave.b32.i2p", Role: core.GroupRoleAdmin},
+			{PublicKey: "bob", I2PAddress: "bob.b32.i2p"},
+		},
+	}
+	if err := repo.SaveGroup(ctx, invite); err != nil {
+		t.Fatalf("SaveGroup failed: %v", err)
+	}
+	if groups, _ := repo.ListGroups(ctx); len(groups) != 1 || groups[0].ID != group.ID {
+		t.Errorf("ListGroups returned %d groups", len(groups))
+	}
+	if invites, _ := repo.ListGroupInvites(ctx); len(invites) != 1 || invites[0].ID != invite.ID || !invites[0].IsPending {
+		t.Errorf("ListGroupInvites = %+v", invites)
+	}
+	if ids, _ := repo.ListGroupIDsByMember(ctx, "dave"); len(ids) != 0 {
+		t.Errorf("Pending group counted for its admin: %v", ids)
+	}
+	if err := repo.SetGroupPending(ctx, invite.ID, false); err != nil {
+		t.Fatalf("SetGroupPending failed: %v", err)
+	}
+	if ids, _ := repo.ListGroupIDsByMember(ctx, "dave"); len(ids) != 1 {
+		t.Errorf("Accepted group not counted: %v", ids)
+	}
+	if err := repo.DeleteGroup(ctx, invite.ID); err != nil {
+		t.Fatalf("DeleteGroup failed: %v", err)
+	}
+
+	// Группа кладётся в папку так же, как контакт
+	folder := &core.Folder{ID: uuid.New().String(), Name: "Работа"}
+	if err := repo.CreateFolder(ctx, folder); err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	if err := repo.AddChatToFolder(ctx, folder.ID, group.ID); err != nil {
+		t.Fatalf("AddChatToFolder failed: %v", err)
+	}
+	chats, _ := repo.GetFolderChats(ctx, folder.ID)
+	if len(chats) != 1 || chats[0] != group.ID {
+		t.Errorf("Group not in folder: %v", chats)
+	}
+
+	// Недоставленные участникам сообщения
+	msg := &core.Message{
+		ID:          uuid.New().String(),
+		ChatID:      group.ID,
+		SenderID:    "alice",
+		Content:     "Всем привет",
+		ContentType: "text",
+		IsOutgoing:  true,
+		Timestamp:   time.Now().UnixMilli(),
+	}
+	if err := repo.SaveMessage(ctx, msg); err != nil {
+		t.Fatalf("SaveMessage failed: %v", err)
+	}
+	if err := repo.AddGroupDeliveries(ctx, msg.ID, []string{"bob", "carol"}); err != nil {
+		t.Fatalf("AddGroupDeliveries failed: %v", err)
+	}
+	if err := repo.DeleteGroupDelivery(ctx, msg.ID, "bob"); err != nil {
+		t.Fatalf("DeleteGroupDelivery failed: %v", err)
+	}
+	pending, _ := repo.ListGroupDeliveries(ctx, msg.ID)
+	if len(pending) != 1 || pending[0] != "carol" {
+		t.Errorf("Unexpected pending deliveries: %v", pending)
+	}
+
+	groups, err := repo.ListGroups(ctx)
+	if err != nil || len(groups) != 1 {
+		t.Fatalf("ListGroups failed: %v (%d)", err, len(groups))
+	}
+	if groups[0].LastMessage != "Всем привет" || len(groups[0].Members) != 2 {
+		t.Errorf("Unexpected listed group: %+v", groups[0])
+	}
+
+	// Удаление группы убирает сообщения и папку
+	if err := repo.DeleteGroup(ctx, group.ID); err != nil {
+		t.Fatalf("DeleteGroup failed: %v", err)
+	}
+	if g, _ := repo.GetGroup(ctx, group.ID); g != nil {
+		t.Error("Group should be deleted")
+	}
+	if m, _ := repo.GetMessage(ctx, msg.ID); m != nil {
+		t.Error("Group messages should be deleted")
+	}
+	if pending, _ := repo.ListGroupDeliveries(ctx, msg.ID); len(pending) != 0 {
+		t.Errorf("Deliveries should be deleted: %v", pending)
+	}
+	if chats, _ := repo.GetFolderChats(ctx, folder.ID); len(chats) != 0 {
+		t.Errorf("Folder should be empty: %v", chats)
+	}
+}
+
+func TestRepository_Channels(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	channel := &core.Channel{
+		ID:           uuid.New().String(),
+		Name:         "Новости",
+		OwnerPubKey:  "owner",
+		OwnerAddress: "owner.b32.i2p",
+	}
+	if err := repo.SaveChannel(ctx, channel); err != nil {
+		t.Fatalf("SaveChannel failed: %v", err)
+	}
+
+	// Посты сохраняются по номерам, повтор номера игнорируется
+	base := time.Now().UnixMilli()
+	for seq := uint64(1); seq <= 3; seq++ {
+		msg := &core.Message{
+			ID:          uuid.New().String(),
+			ChatID:      channel.ID,
+			SenderID:    "owner",
+			Content:     fmt.Sprintf("Пост %d", seq),
+			ContentType: "text",
+			Timestamp:   base + int64(seq),
+		}
+		post := &core.ChannelPost{ChannelID: channel.ID, Seq: seq, MessageID: msg.ID, Signature: []byte{byte(seq)}}
+		added, err := repo.AddChannelPost(ctx, post, msg)
+		if err != nil || !added {
+			t.Fatalf("AddChannelPost failed: %v (added=%v)", err, added)
+		}
+	}
+	dup := &core.Message{ID: uuid.New().String(), ChatID: channel.ID, Content: "Дубль", ContentType: "text"}
+	if added, err := repo.AddChannelPost(ctx, &core.ChannelPost{ChannelID: channel.ID, Seq: 2, MessageID: dup.ID}, dup); err != nil || added {
+		t.Errorf("Duplicate seq should be ignored: %v (added=%v)", err, added)
+	}
+	if m, _ := repo.GetMessage(ctx, dup.ID); m != nil {
+		t.Error("Duplicate post should not be saved")
+	}
+
+	// Пост не перезаписывает сообщение другого чата с тем же ID
+	other := &core.Message{ID: uuid.New().String(), ChatID: "chat-bob", Content: "Личное", ContentType: "text"}
+	if err := repo.SaveMessage(ctx, other); err != nil {
+		t.Fatalf("SaveMessage failed: %v", err)
+	}
+	hijack := &core.Message{ID: other.ID, ChatID: channel.ID, Content: "Подмена", ContentType: "text"}
+	if added, err := repo.AddChannelPost(ctx, &core.ChannelPost{ChannelID: channel.ID, Seq: 4, MessageID: hijack.ID}, hijack); err != nil || added {
+		t.Errorf("Post with a taken message ID should be ignored: %v (added=%v)", err, added)
+	}
+	if m, _ := repo.GetMessage(ctx, other.ID); m == nil || m.ChatID != "chat-bob" || m.Content != "Личное" {
+		t.Errorf("Message of another chat was overwritten: %+v", m)
+	}
+
+	got, err := repo.GetChannel(ctx, channel.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetChannel failed: %v", err)
+	}
+	if got.Name != "Новости" || got.OwnerAddress != "owner.b32.i2p" || got.LastSeq != 3 {
+		t.Errorf("Unexpected channel: %+v", got)
+	}
+
+	// Сохранение канала не откатывает номер последнего поста
+	channel.LastSeq = 1
+	if err := repo.SaveChannel(ctx, channel); err != nil {
+		t.Fatalf("SaveChannel failed: %v", err)
+	}
+	if got, _ := repo.GetChannel(ctx, channel.ID); got.LastSeq != 3 {
+		t.Errorf("LastSeq went back: %d", got.LastSeq)
+	}
+
+	posts, err := repo.ListChannelPosts(ctx, channel.ID, 1, 10)
+	if err != nil {
+		t.Fatalf("ListChannelPosts failed: %v", err)
+	}
+	if len(posts) != 2 || posts[0].Seq != 2 || posts[1].Seq != 3 || posts[1].Content != "Пост 3" {
+		t.Errorf("Unexpected posts after seq 1: %+v", posts)
+	}
+	// При ограничении отдаются самые свежие посты
+	if posts, _ := repo.ListChannelPosts(ctx, channel.ID, 0, 1); len(posts) != 1 || posts[0].Seq != 3 {
+		t.Errorf("Limit should keep the latest post: %+v", posts)
+	}
+
+	// Подписчики
+	for _, pk := range []string{"bob", "carol"} {
+		if err := repo.AddChannelSubscriber(ctx, &core.ChannelSubscriber{ChannelID: channel.ID, PublicKey: pk, I2PAddress: pk + ".b32.i2p"}); err != nil {
+			t.Fatalf("AddChannelSubscriber failed: %v", err)
+		}
+	}
+	if err := repo.AddChannelSubscriber(ctx, &core.ChannelSubscriber{ChannelID: channel.ID, PublicKey: "bob", I2PAddress: "bob2.b32.i2p"}); err != nil {
+		t.Fatalf("AddChannelSubscriber failed: %v", err)
+	}
+	if err := repo.RemoveChannelSubscriber(ctx, channel.ID, "carol"); err != nil {
+		t.Fatalf("RemoveChannelSubscriber failed: %v", err)
+	}
+	subs, _ := repo.ListChannelSubscribers(ctx, channel.ID)
+	if len(subs) != 1 || subs[0].PublicKey != "bob" || subs[0].I2PAddress != "bob2.b32.i2p" {
+		t.Errorf("Unexpected subscribers: %+v", subs)
+	}
+	if ok, err := repo.IsChannelSubscriber(ctx, "bob"); err != nil || !ok {
+		t.Errorf("IsChannelSubscriber(bob) = %v, %v", ok, err)
+	}
+	if ok, _ := repo.IsChannelSubscriber(ctx, "carol"); ok {
+		t.Error("Removed subscriber still reported")
+	}
+
+	channels, err := repo.ListChannels(ctx)
+	if err != nil || len(channels) != 1 {
+		t.Fatalf("ListChannels failed: %v (%d)", err, len(channels))
+	}
+	if channels[0].LastMessage != "Пост 3" || channels[0].SubscriberCount != 1 {
+		t.Errorf("Unexpected listed channel: %+v", channels[0])
+	}
+	if byOwner, _ := repo.ListChannelsByOwner(ctx, "owner"); len(byOwner) != 1 {
+		t.Errorf("Channel not found by owner: %d", len(byOwner))
+	}
+
+	// Удаление канала убирает посты и подписчиков
+	if err := repo.DeleteChannel(ctx, channel.ID); err != nil {
+		t.Fatalf("DeleteChannel failed: %v", err)
+	}
+	if c, _ := repo.GetChannel(ctx, channel.ID); c != nil {
+		t.Error("Channel should be deleted")
+	}
+	if posts, _ := repo.ListChannelPosts(ctx, channel.ID, 0, 10); len(posts) != 0 {
+		t.Errorf("Posts should be deleted: %d", len(posts))
+	}
+	if subs, _ := repo.ListChannelSubscribers(ctx, channel.ID); len(subs) != 0 {
+		t.Errorf("Subscribers should be deleted: %d", len(subs))
+	}
+}
@@ -455,6 +455,9 @@ func dispatch(app *appcore.AppCore, method string, args []json.RawMessage) (inte
 	case "GetCurrentProfile":
 		return app.GetCurrentProfile(), nil
 
+	case "GetDiagnostics":
+		return app.GetDiagnostics(), nil
+
 	case "UpdateMyProfile":
 		var nickname, bio, avatar string
 		parseArgs(args, &nickname, &bio, &avatar)
@@ -479,6 +482,42 @@ func dispatch(app *appcore.AppCore, method string, args []json.RawMessage) (inte
 		parseArgs(args, &id)
 		return nil, app.DeleteContact(id)
 
+	case "SetContactReadReceipts":
+		var id string
+		var enabled bool
+		parseArgs(args, &id, &enabled)
+		return nil, app.SetContactReadReceipts(id, enabled)
+
+	case "SetContactHidePresence":
+		var id string
+		var hide bool
+		parseArgs(args, &id, &hide)
+		return nil, app.SetContactHidePresence(id, hide)
+
+	case "SetContactBlocked":
+		var id string
+		var blocked bool
+		parseArgs(args, &id, &blocked)
+		return nil, app.SetContactBlocked(id, blocked)
+
+	case "GetMessageRequests":
+		return app.GetMessageRequests()
+
+	case "AcceptMessageRequest":
+		var id string
+		parseArgs(args, &id)
+		return nil, app.AcceptMessageRequest(id)
+
+	case "DeclineMessageRequest":
+		var id string
+		parseArgs(args, &id)
+		return nil, app.DeclineMessageRequest(id)
+
+	case "BlockMessageRequest":
+		var id string
+		parseArgs(args, &id)
+		return nil, app.BlockMessageRequest(id)
+
 	case "RequestProfile":
 		var address string
 		parseArgs(args, &address)
@@ -506,11 +545,32 @@ func dispatch(app *appcore.AppCore, method string, args []json.RawMessage) (inte
 		parseArgs(args, &messageID)
 		return nil, app.DeleteMessage(messageID)
 
+	case "DeleteMessageForAll":
+		var messageID string
+		parseArgs(args, &messageID)
+		return nil, app.DeleteMessageForAll(messageID)
+
+	case "RetryMessage":
+		var messageID string
+		parseArgs(args, &messageID)
+		return nil, app.RetryMessage(messageID)
+
+	case "CancelMessage":
+		var messageID string
+		parseArgs(args, &messageID)
+		return nil, app.CancelMessage(messageID)
+
 	case "MarkChatAsRead":
 		var chatID string
 		parseArgs(args, &chatID)
 		return nil, app.MarkChatAsRead(chatID)
 
+	case "SendTyping":
+		var chatID string
+		var typing bool
+		parseArgs(args, &chatID, &typing)
+		return nil, app.SendTyping(chatID, typing)
+
 	case "GetUnreadCount":
 		return app.GetUnreadCount()
 
@@ -567,6 +627,63 @@ func dispatch(app *appcore.AppCore, method string, args []json.RawMessage) (inte
 		parseArgs(args, &folderID, &contactID)
 		return nil, app.RemoveChatFromFolder(folderID, contactID)
 
+	// === Groups ===
+	case "CreateGroup":
+		var name string
+		var contactIDs []string
+		parseArgs(args, &name, &contactIDs)
+		return app.CreateGroup(name, contactIDs)
+
+	case "GetGroups":
+		return app.GetGroups()
+
+	case "GetGroupInvites":
+		return app.GetGroupInvites()
+
+	case "AcceptGroupInvite":
+		var groupID string
+		parseArgs(args, &groupID)
+		return nil, app.AcceptGroupInvite(groupID)
+
+	case "DeclineGroupInvite":
+		var groupID string
+		parseArgs(args, &groupID)
+		return nil, app.DeclineGroupInvite(groupID)
+
+	case "InviteToGroup":
+		var groupID, contactID string
+		parseArgs(args, &groupID, &contactID)
+		return nil, app.InviteToGroup(groupID, contactID)
+
+	case "KickFromGroup":
+		var groupID, memberPubKey string
+		parseArgs(args, &groupID, &memberPubKey)
+		return nil, app.KickFromGroup(groupID, memberPubKey)
+
+	case "LeaveGroup":
+		var groupID string
+		parseArgs(args, &groupID)
+		return nil, app.LeaveGroup(groupID)
+
+	// === Channels ===
+	case "CreateChannel":
+		var name string
+		parseArgs(args, &name)
+		return app.CreateChannel(name)
+
+	case "GetChannels":
+		return app.GetChannels()
+
+	case "SubscribeChannel":
+		var link string
+		parseArgs(args, &link)
+		return app.SubscribeChannel(link)
+
+	case "UnsubscribeChannel":
+		var channelID string
+		parseArgs(args, &channelID)
+		return nil, app.UnsubscribeChannel(channelID)
+
 	// === Settings ===
 	case "GetMyDestination":
 		return app.GetMyDestination(), nil
@@ -577,6 +694,22 @@ func dispatch(app *appcore.AppCore, method string, args []json.RawMessage) (inte
 	case "GetNetworkStatus":
 		return app.GetNetworkStatus(), nil
 
+	case "GetPrivacySettings":
+		return app.GetPrivacySettings(), nil
+
+	case "SavePrivacySettings":
+		var settings map[string]interface{}
+		parseArgs(args, &settings)
+		return nil, app.SavePrivacySettings(settings)
+
+	case "GetMailboxSettings":
+		return app.GetMailboxSettings(), nil
+
+	case "SetMailboxServer":
+		var address string
+		parseArgs(args, &address)
+		return app.SetMailboxServer(address)
+
 	case "GetAppAboutInfo":
 		return app.GetAppAboutInfo(), nil
 